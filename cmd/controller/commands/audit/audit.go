@@ -0,0 +1,218 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	pkgaudit "github.com/asjdf/p2p-playground-lite/pkg/audit"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+	headHash  string
+	showAll   bool
+
+	sessionApp  string
+	sessionPeer string
+)
+
+// verifyResult is the structured result of an audit verification
+type verifyResult struct {
+	NodeID   string            `json:"node_id"`
+	Valid    bool              `json:"valid"`
+	Count    int               `json:"count"`
+	HeadHash string            `json:"head_hash,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Entries  []*pkgaudit.Entry `json:"entries,omitempty"`
+}
+
+// Cmd represents the audit command
+var Cmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the deployment and session audit log of a node",
+}
+
+// verifyCmd fetches and verifies a node's transparency log
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the hash chain of a node's deployment log",
+	Long: `Fetch the append-only deployment log of a node and verify its hash chain locally.
+
+Every deployed package is recorded with its checksum, signer, time and manifest
+digest, and each entry commits to the previous one. Editing, removing or
+reordering a historical entry breaks the chain.
+
+Apps being started, stopped or exiting are recorded in the same chain, with
+who or what initiated it: an operator's deploy or rollback, the restart
+policy, or the app itself. --show prints them along with the deployments.
+
+A node could still replace its whole log with a new, self-consistent one. To
+detect that, save the head hash printed by a previous run and pass it with --head:
+verification fails unless that entry is still part of the chain.
+
+--node takes the peer ID or name of the node to verify. If it is not
+specified, the local daemon is verified, or else the only node discovered.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		entries, err := common.FetchAuditLog(ctx, host, targetPeerID, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to fetch audit log: %w", err)
+		}
+
+		verifyErr := pkgaudit.Verify(entries)
+		if verifyErr == nil && headHash != "" && !pkgaudit.Contains(entries, headHash) {
+			verifyErr = fmt.Errorf("%w: head %s is no longer part of the log (log was rewritten or truncated)", types.ErrInvalidChecksum, headHash)
+		}
+
+		result := verifyResult{
+			NodeID: targetPeerID,
+			Valid:  verifyErr == nil,
+			Count:  len(entries),
+		}
+		if len(entries) > 0 {
+			result.HeadHash = entries[len(entries)-1].Hash
+		}
+		if verifyErr != nil {
+			result.Error = verifyErr.Error()
+		}
+		if showAll {
+			result.Entries = entries
+		}
+
+		if err := out.Result(result, func() {
+			if showAll {
+				out.Println()
+				for _, e := range entries {
+					if e.Kind == pkgaudit.KindSession {
+						out.Printf("%4d  %s  %-30s  %s session by %s\n",
+							e.Seq, e.Time.Format("2006-01-02 15:04:05"), e.AppID, e.Session, e.Peer)
+						continue
+					}
+					if e.Kind == pkgaudit.KindLifecycle {
+						initiator := "(unknown)"
+						if e.Initiator != nil {
+							initiator = e.Initiator.String()
+						}
+						out.Printf("%4d  %s  %-30s  %s by %s\n",
+							e.Seq, e.Time.Format("2006-01-02 15:04:05"), e.AppID, e.Event, initiator)
+						continue
+					}
+					signer := e.Signer
+					if signer == "" {
+						signer = "(unsigned)"
+					}
+					out.Printf("%4d  %s  %-30s  sha256:%s  %s\n",
+						e.Seq, e.Time.Format("2006-01-02 15:04:05"), e.AppID, e.PackageChecksum, signer)
+				}
+			}
+
+			if verifyErr != nil {
+				out.Printf("\n✗ Audit log of %s failed verification: %v\n", targetPeerID, verifyErr)
+				return
+			}
+			out.Printf("\n✓ Audit log of %s is intact (%d entries)\n", targetPeerID, len(entries))
+			if result.HeadHash != "" {
+				out.Printf("  Head: %s\n", result.HeadHash)
+			}
+		}); err != nil {
+			return err
+		}
+
+		if verifyErr != nil {
+			return fmt.Errorf("audit log verification failed: %w", verifyErr)
+		}
+		return nil
+	},
+}
+
+// sessionsCmd lists the sessions recorded in a node's audit log
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List the sessions recorded in a node's audit log",
+	Long: `List the sessions a node recorded in its audit log: which peer read the logs
+of which app, when, for how long, and the SHA-256 of what it was sent.
+
+Nodes only record sessions with security.record_sessions enabled. Sessions are
+part of the same hash chain as deployments, so "audit verify" covers them too;
+the chain is verified before listing and a broken chain fails the command.
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is used, or else the only node discovered.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		entries, err := common.FetchAuditLog(ctx, host, targetPeerID, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to fetch audit log: %w", err)
+		}
+		if err := pkgaudit.Verify(entries); err != nil {
+			return fmt.Errorf("audit log verification failed: %w", err)
+		}
+
+		sessions := make([]*pkgaudit.Entry, 0)
+		for _, e := range pkgaudit.Sessions(entries) {
+			if (sessionApp == "" || e.AppID == sessionApp) && (sessionPeer == "" || e.Peer == sessionPeer) {
+				sessions = append(sessions, e)
+			}
+		}
+
+		return out.Result(sessions, func() {
+			if len(sessions) == 0 {
+				out.Println("No sessions recorded (is security.record_sessions enabled on the node?)")
+				return
+			}
+			for _, e := range sessions {
+				duration := time.Duration(e.DurationMillis) * time.Millisecond
+				out.Printf("%4d  %s  %-6s  %-30s  %s  %s  %d bytes  sha256:%s\n",
+					e.Seq, e.Time.Format("2006-01-02 15:04:05"), e.Session, e.AppID, e.Peer,
+					duration, e.TranscriptSize, e.TranscriptHash)
+			}
+		})
+	},
+}
+
+func init() {
+	common.AddSelectionFlags(verifyCmd.Flags(), &selection, false)
+	verifyCmd.Flags().StringVar(&headHash, "head", "", "previously recorded head hash that must still be in the log")
+	verifyCmd.Flags().BoolVar(&showAll, "show", false, "print all entries")
+
+	common.AddSelectionFlags(sessionsCmd.Flags(), &selection, false)
+	sessionsCmd.Flags().StringVar(&sessionApp, "app", "", "only list sessions with this app ID")
+	sessionsCmd.Flags().StringVar(&sessionPeer, "peer", "", "only list sessions opened by this peer ID")
+
+	Cmd.AddCommand(verifyCmd)
+	Cmd.AddCommand(sessionsCmd)
+}
@@ -0,0 +1,187 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	pkgbackup "github.com/asjdf/p2p-playground-lite/pkg/backup"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+	to        []string
+	from      string
+)
+
+// backupResult is the structured result of a backup
+type backupResult struct {
+	Snapshot *volume.Snapshot         `json:"snapshot"`
+	Targets  []pkgbackup.TargetResult `json:"targets"`
+}
+
+// listResult is the structured result of listing snapshots
+type listResult struct {
+	From      string             `json:"from,omitempty"`
+	Snapshots []*volume.Snapshot `json:"snapshots"`
+}
+
+// Cmd represents the backup command
+var Cmd = &cobra.Command{
+	Use:   "backup <app>",
+	Short: "Snapshot the volumes of an app and back them up",
+	Long: `Snapshot the volumes of an app on a node and back the snapshot up to other
+nodes or an S3-compatible bucket.
+
+Volumes are the directories a manifest declares under volumes:, which keep
+their contents across deployments and restarts of the app. The snapshot is a
+tar.gz archive of them with its SHA-256 checksum. It is taken while the app
+runs, so an app writing to its volumes should be stopped or quiesced first
+for a consistent snapshot.
+
+The node keeps the snapshot, and the last backup.keep (5 by default), then
+sends it to each --to target: a node, by peer ID or name, or s3 for the
+bucket the node is configured with (backup.s3). Without --to, the snapshot
+goes to the targets the node is configured with (backup.peers and the
+bucket). Nodes with backup.interval set also back up every app with volumes
+on their own. Restore a snapshot with 'controller restore'.
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is used, or else the only node discovered.
+
+Example:
+  controller backup web --node lab-1 --to lab-2,s3
+  controller backup list web --node lab-2`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app := args[0]
+		var resp *pkgbackup.Response
+		names := make(map[string]string)
+		err := withNode(func(ctx context.Context, host *p2p.Host, peerID string) error {
+			var targets []string
+			for _, target := range to {
+				if target == pkgbackup.TargetS3 {
+					targets = append(targets, target)
+					continue
+				}
+				id, err := common.ResolveNode(ctx, host, &common.Selection{Node: target})
+				if err != nil {
+					return err
+				}
+				names[id] = target
+				targets = append(targets, id)
+			}
+
+			common.Out.Statusf("Backing up the volumes of %s...\n", app)
+			var err error
+			resp, err = common.BackupApp(ctx, host, peerID, app, targets, common.GlobalLogger)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to back up %s: %w", app, err)
+		}
+
+		failed := 0
+		for _, target := range resp.Targets {
+			if target.Error != "" {
+				failed++
+			}
+		}
+		out := common.Out
+		snap := resp.Snapshot
+		err = out.Result(backupResult{Snapshot: snap, Targets: resp.Targets}, func() {
+			out.Printf("✓ Snapshot %s of %v (%d bytes)\n", snap.ID, snap.Volumes, snap.Size)
+			if len(resp.Targets) == 0 {
+				out.Println("  Kept on the node only, no backup targets are configured")
+			}
+			for _, target := range resp.Targets {
+				name := target.Target
+				if names[name] != "" {
+					name = names[name]
+				}
+				if target.Error != "" {
+					out.Printf("  ✗ %s: %s\n", name, target.Error)
+				} else {
+					out.Printf("  ✓ %s\n", name)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("snapshot %s was not backed up to %d of %d targets", snap.ID, failed, len(resp.Targets))
+		}
+		return nil
+	},
+}
+
+// listCmd lists snapshots
+var listCmd = &cobra.Command{
+	Use:   "list [app]",
+	Short: "List the snapshots a node keeps, newest first",
+	Long: `List the snapshots of an app, or of all apps, a node keeps: those it took
+and those other nodes backed up to it. With --from s3, list those in the
+bucket the node is configured with instead.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app := ""
+		if len(args) == 1 {
+			app = args[0]
+		}
+		var snapshots []*volume.Snapshot
+		err := withNode(func(ctx context.Context, host *p2p.Host, peerID string) error {
+			resp, err := common.ListSnapshots(ctx, host, peerID, app, from, common.GlobalLogger)
+			if err != nil {
+				return err
+			}
+			snapshots = resp.Snapshots
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list snapshots: %w", err)
+		}
+		if snapshots == nil {
+			snapshots = []*volume.Snapshot{}
+		}
+
+		out := common.Out
+		return out.Result(listResult{From: from, Snapshots: snapshots}, func() {
+			if len(snapshots) == 0 {
+				out.Println("No snapshots")
+				return
+			}
+			for _, snap := range snapshots {
+				out.Printf("%s  %-40s %10d bytes  %v\n", snap.CreatedAt.Local().Format("2006-01-02 15:04:05"), snap.ID, snap.Size, snap.Volumes)
+			}
+		})
+	},
+}
+
+// withNode runs fn with a host connected to the selected node
+func withNode(fn func(ctx context.Context, host *p2p.Host, peerID string) error) error {
+	// Create P2P host using configuration
+	ctx := context.Background()
+	host, err := common.CreateP2PHost(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = host.Close() }()
+
+	targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+	if err != nil {
+		return err
+	}
+	return fn(ctx, host, targetPeerID)
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.PersistentFlags(), &selection, false)
+	Cmd.Flags().StringSliceVar(&to, "to", nil, "nodes (peer ID or name) or s3 to back up to (default: backup.peers and backup.s3 of the node)")
+	listCmd.Flags().StringVar(&from, "from", "", "list the snapshots in the bucket of the node (s3)")
+
+	Cmd.AddCommand(listCmd)
+}
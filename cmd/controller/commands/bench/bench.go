@@ -0,0 +1,145 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	pkgbench "github.com/asjdf/p2p-playground-lite/pkg/bench"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection  common.Selection
+	from       string
+	size       string
+	opts       pkgbench.Options
+	reportPath string
+)
+
+// Cmd represents the bench command
+var Cmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure the latency and throughput of the connection to a node",
+	Long: `Measure the latency and throughput of the P2P connection between the
+controller and a node, to validate a network before large deployments.
+
+Latency is measured with --pings libp2p pings. Throughput is measured by
+sending --size bytes to the node and then receiving as many from it, split
+over --streams concurrent streams; --direction measures only one of them.
+The data is discarded on arrival, so nothing is written to disk.
+
+With --from, the node --from measures its connection to --node instead, and
+sends back its report. Both take a peer ID or name.
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is measured, or else the only node discovered. The local
+daemon is reached over its local socket, which measures no network and
+has no pings.
+
+Example:
+  controller bench --node lab-1 --size 100MB --streams 4
+  controller bench --from lab-1 --node lab-2 --report bench.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		if opts.Size, err = common.ParseSize(size); err != nil {
+			return err
+		}
+		if err := opts.WithDefaults().Validate(); err != nil {
+			return err
+		}
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		to, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+		var fromID string
+		if from != "" {
+			if fromID, err = common.ResolveNode(ctx, host, &common.Selection{Node: from}); err != nil {
+				return err
+			}
+			if fromID == to {
+				return fmt.Errorf("--from and --node are the same node %s", to)
+			}
+		}
+
+		if fromID == "" {
+			out.Statusf("Benchmarking the connection to %s...\n", to)
+		} else {
+			out.Statusf("Benchmarking the connection from %s to %s...\n", fromID, to)
+		}
+		report, err := common.Benchmark(ctx, host, fromID, to, opts, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("benchmark failed: %w", err)
+		}
+
+		if reportPath != "" {
+			if err := writeReport(report, reportPath); err != nil {
+				return err
+			}
+		}
+
+		return out.Result(report, func() {
+			out.Printf("\nFrom: %s\n", report.From)
+			out.Printf("To:   %s\n", report.To)
+			if report.Local {
+				out.Println("      (over the local socket, not the network)")
+			}
+			if l := report.Latency; l != nil {
+				loss := float64(l.Sent-l.Received) / float64(l.Sent) * 100
+				out.Printf("\nLatency:  min %s  avg %s  max %s  (%d pings, %.0f%% lost)\n",
+					round(l.Min), round(l.Avg), round(l.Max), l.Sent, loss)
+			}
+			out.Printf("\nThroughput (%s over %d stream(s)):\n", common.FormatSize(report.Size), report.Streams)
+			if t := report.Upload; t != nil {
+				out.Printf("  Upload:    %8.1f Mbit/s  (%s)\n", t.MbitPerSec, round(t.Elapsed))
+			}
+			if t := report.Download; t != nil {
+				out.Printf("  Download:  %8.1f Mbit/s  (%s)\n", t.MbitPerSec, round(t.Elapsed))
+			}
+		})
+	},
+}
+
+// round rounds a duration for display
+func round(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
+
+// writeReport writes report as JSON to path
+func writeReport(report *pkgbench.Report, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+	Cmd.Flags().StringVar(&from, "from", "", "measure from this node instead of the controller, by peer ID or name")
+	Cmd.Flags().StringVar(&size, "size", "100MB", "bytes to send in each direction, e.g. 1GB")
+	Cmd.Flags().IntVar(&opts.Streams, "streams", pkgbench.DefaultStreams, "concurrent streams to send the bytes over")
+	Cmd.Flags().IntVar(&opts.Pings, "pings", pkgbench.DefaultPings, "pings to measure latency with, -1 for none")
+	Cmd.Flags().StringVar(&opts.Direction, "direction", "", "measure only upload or download")
+	Cmd.Flags().StringVar(&reportPath, "report", "", "write the report as JSON to this file")
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/spf13/cobra"
+)
+
+var pruneAll bool
+
+// pruneResult is the structured result of a prune
+type pruneResult struct {
+	Removed []string `json:"removed"`
+}
+
+// Cmd represents the cache command
+var Cmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the files the controller keeps under storage.packages_dir",
+}
+
+// lsCmd lists the cached packages
+var lsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the cached packages",
+	Long: `List the packages built from app directories by 'run' and 'deploy', most
+recently used first.
+
+A package is reused while the sources in its app directory and the signing key
+are unchanged; the least recently used packages are evicted beyond
+package_cache.max_size_mb.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+
+		cache, err := common.OpenPackageCache()
+		if err != nil {
+			return err
+		}
+		pkgs, err := cache.List()
+		if err != nil {
+			return err
+		}
+
+		return out.Result(pkgs, func() {
+			if len(pkgs) == 0 {
+				out.Printf("No cached packages\n")
+				return
+			}
+			var total int64
+			for _, pkg := range pkgs {
+				total += pkg.Size
+				out.Printf("%s  %s\n", pkg.Key, filepath.Base(pkg.Path))
+				out.Printf("   App directory: %s\n", pkg.AppDir)
+				if pkg.Signer != "" {
+					out.Printf("   Signed by: %s\n", pkg.Signer[:16])
+				} else {
+					out.Printf("   Signed by: -\n")
+				}
+				out.Printf("   Size: %d bytes\n", pkg.Size)
+				out.Printf("   Last used: %s\n", pkg.LastUsed.Format(time.RFC3339))
+			}
+			out.Printf("\n%d package(s), %d bytes\n", len(pkgs), total)
+		})
+	},
+}
+
+// pruneCmd removes workspaces left behind and evicts cached packages
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove temporary workspaces left behind and evict cached packages",
+	Long: `Remove the temporary workspaces of commands that are no longer running, and
+the least recently used cached packages beyond package_cache.max_size_mb.
+
+'controller run' builds packages in a workspace under storage.packages_dir and
+removes it on exit, including when interrupted. Workspaces survive only if the
+controller was killed, or with --cleanup=false; prune removes those.
+Workspaces of commands still running are kept.
+
+With --all, every cached package is removed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+
+		removed, err := common.PruneWorkspaces()
+		if err != nil {
+			return fmt.Errorf("failed to prune workspaces: %w", err)
+		}
+
+		cache, err := common.OpenPackageCache()
+		if err != nil {
+			return err
+		}
+		evicted, err := cache.Prune(cmd.Context(), pruneAll)
+		removed = append(removed, evicted...)
+		if err != nil {
+			return fmt.Errorf("failed to prune package cache: %w", err)
+		}
+		if removed == nil {
+			removed = []string{}
+		}
+
+		return out.Result(pruneResult{Removed: removed}, func() {
+			for _, path := range removed {
+				out.Printf("Removed %s\n", path)
+			}
+			out.Printf("%d workspace(s) and package(s) removed\n", len(removed))
+		})
+	},
+}
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneAll, "all", false, "remove every cached package")
+	Cmd.AddCommand(lsCmd)
+	Cmd.AddCommand(pruneCmd)
+}
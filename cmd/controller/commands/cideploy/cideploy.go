@@ -0,0 +1,324 @@
+package cideploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	packagePath string
+	bootstrap   []string
+	nodes       []string
+	psk         string
+	autoStart   bool
+	waitHealthy bool
+	timeout     time.Duration
+	retries     int
+	retryDelay  time.Duration
+	reportPath  string
+)
+
+// envFlags maps each flag to the environment variable it defaults to
+var envFlags = []struct {
+	flag string
+	env  string
+}{
+	{"package", "P2P_PACKAGE"},
+	{"bootstrap", "P2P_BOOTSTRAP"},
+	{"nodes", "P2P_NODES"},
+	{"psk", "P2P_PSK"},
+	{"start", "P2P_START"},
+	{"wait-healthy", "P2P_WAIT_HEALTHY"},
+	{"timeout", "P2P_TIMEOUT"},
+	{"retries", "P2P_RETRIES"},
+	{"retry-delay", "P2P_RETRY_DELAY"},
+	{"report", "P2P_REPORT"},
+}
+
+// ciResult is the structured result of a CI deployment
+type ciResult struct {
+	AppID string   `json:"app_id"`
+	Nodes []string `json:"nodes"`
+}
+
+// Cmd represents the ci-deploy command
+var Cmd = &cobra.Command{
+	Use:   "ci-deploy [package]",
+	Short: "Deploy a package from a CI pipeline",
+	Long: `Deploy a package non-interactively, as in a GitHub Actions job.
+
+Every flag can also be set through an environment variable, which the flag
+overrides:
+
+  --package       P2P_PACKAGE        package to deploy (or the argument)
+  --bootstrap     P2P_BOOTSTRAP      comma-separated multiaddrs of cluster nodes
+  --nodes         P2P_NODES          comma-separated peer IDs to deploy to
+  --psk           P2P_PSK            cluster pre-shared key
+  --start         P2P_START          start the app once deployed
+  --wait-healthy  P2P_WAIT_HEALTHY   succeed only once the app is healthy
+  --timeout       P2P_TIMEOUT        how long --wait-healthy waits
+  --retries       P2P_RETRIES        retries per node after a failed attempt
+  --retry-delay   P2P_RETRY_DELAY    delay before the first retry, doubled after each
+  --report        P2P_REPORT         file to write the JSON deploy report to
+
+Nodes are reached through the bootstrap addresses only; mDNS is never used and
+nothing is ever prompted for. Without --nodes, the package is deployed to every
+bootstrap peer. A "<package>.sig" file next to the package is sent as its signature.
+
+Attempts that fail to reach a node, time out, or find the app locked by another
+operation are retried with exponential backoff; a node rejecting the package
+is not. Retries and the per-attempt timeout default to the deployment section
+of the controller config.
+
+Progress is reported as GitHub Actions workflow commands (::group::, ::notice::,
+::error::), so failures show up as annotations on the run.
+
+` + common.ExitCodeHelp,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if err := applyEnv(cmd); err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			packagePath = args[0]
+		}
+		if packagePath == "" {
+			return fmt.Errorf("%w: no package given (argument, --package or P2P_PACKAGE)", types.ErrInvalidInput)
+		}
+
+		report := common.NewDeployReport("ci-deploy")
+		if reportPath != "" {
+			defer func() {
+				report.Finish(err)
+				if writeErr := report.Write(reportPath); writeErr != nil && err == nil {
+					err = writeErr
+				}
+			}()
+		}
+		defer func() {
+			// Failures on nodes were annotated as they happened
+			if err != nil && len(report.Nodes) == 0 {
+				annotate("error", "Deployment failed", err.Error())
+			}
+		}()
+
+		return ciDeploy(report)
+	},
+}
+
+// applyEnv sets the flags not given on the command line from their environment variables
+func applyEnv(cmd *cobra.Command) error {
+	for _, ef := range envFlags {
+		value, ok := os.LookupEnv(ef.env)
+		if !ok || value == "" || cmd.Flags().Changed(ef.flag) {
+			continue
+		}
+		if err := cmd.Flags().Set(ef.flag, value); err != nil {
+			return fmt.Errorf("%w: %s: %w", types.ErrInvalidInput, ef.env, err)
+		}
+	}
+	return nil
+}
+
+// ciDeploy deploys the package to every target node
+func ciDeploy(report *common.DeployReport) error {
+	ctx := context.Background()
+	cfg := common.GlobalConfig
+
+	info, err := os.Stat(packagePath)
+	if err != nil {
+		return fmt.Errorf("failed to access package file: %w", err)
+	}
+	manifest, err := pkgmanager.New().GetManifest(ctx, packagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if err := report.SetPackage(packagePath, manifest); err != nil {
+		return err
+	}
+	if err := common.CheckPolicy(manifest, fileExists(packagePath+".sig"), nil); err != nil {
+		return err
+	}
+
+	// Reach the cluster through the bootstrap addresses only
+	cfg.Node.EnableMDNS = false
+	cfg.Node.Discovery = []string{discovery.BackendGossip}
+	if len(bootstrap) > 0 {
+		cfg.Node.BootstrapPeers = bootstrap
+	}
+	if psk != "" {
+		cfg.Security.PSK = psk
+	}
+	peers, err := bootstrapPeers(cfg.Node.BootstrapPeers)
+	if err != nil {
+		return err
+	}
+	targets := nodes
+	if len(targets) == 0 {
+		for _, p := range peers {
+			targets = append(targets, p.ID.String())
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: no target nodes (set --bootstrap or --nodes)", common.ErrNoNodes)
+	}
+
+	host, err := common.CreateP2PHost(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = host.Close() }()
+	for _, nodeID := range targets {
+		if err := common.CheckTrusted(host, nodeID); err != nil {
+			return err
+		}
+	}
+	connect(ctx, host, peers)
+
+	opts := common.DeployOptions{
+		AutoStart:     autoStart,
+		WaitHealthy:   waitHealthy,
+		HealthTimeout: timeout,
+	}
+
+	if retries < 0 {
+		retries = cfg.Deployment.RetryAttempts
+	}
+	if retryDelay <= 0 {
+		retryDelay = cfg.Deployment.RetryDelay
+	}
+
+	// One node at a time, so each node's output stays in its own log group
+	var appID string
+	var deployed []string
+	var errs []error
+	for _, nodeID := range targets {
+		start := time.Now()
+		id, attempts, err := deployWithRetry(ctx, host, nodeID, info.Size(), opts, cfg.Deployment.Timeout)
+		report.AddNode(nodeID, id, opts, start, err)
+		report.Nodes[len(report.Nodes)-1].Attempts = attempts
+		if err != nil {
+			annotate("error", "Deployment failed on node "+nodeID, err.Error())
+			errs = append(errs, fmt.Errorf("node %s: %w", nodeID, err))
+			continue
+		}
+		annotate("notice", "Deployed", fmt.Sprintf("%s on node %s", id, nodeID))
+		appID = id
+		deployed = append(deployed, nodeID)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("deployment failed on %d of %d node(s): %w", len(errs), len(targets), errors.Join(errs...))
+	}
+
+	return common.Out.Result(ciResult{AppID: appID, Nodes: deployed}, func() {
+		common.Out.Printf("Deployed %s to %d node(s)\n", appID, len(deployed))
+	})
+}
+
+// deployWithRetry deploys to a node, retrying with exponential backoff after
+// failures that may be transient. It returns the app ID and the attempts made.
+func deployWithRetry(ctx context.Context, host *p2p.Host, nodeID string, size int64, opts common.DeployOptions, attemptTimeout time.Duration) (string, int, error) {
+	delay := max(retryDelay, time.Second)
+	for attempt := 1; ; attempt++ {
+		annotate("group", "", fmt.Sprintf("Deploy to %s (attempt %d of %d)", nodeID, attempt, retries+1))
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if attemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, attemptTimeout)
+		}
+		appID, err := common.DeployPackage(attemptCtx, host, nodeID, packagePath, size, opts, common.GlobalLogger)
+		cancel()
+		annotate("endgroup", "", "")
+
+		if err == nil || attempt > retries || !common.Retryable(err) {
+			return appID, attempt, err
+		}
+
+		annotate("warning", "Retrying deployment to "+nodeID, fmt.Sprintf("attempt %d failed, retrying in %s: %v", attempt, delay, err))
+		select {
+		case <-ctx.Done():
+			return "", attempt, ctx.Err()
+		case <-time.After(delay):
+		}
+		common.ClearBackoff(host, nodeID)
+		delay = min(2*delay, common.MaxRetryDelay)
+	}
+}
+
+// bootstrapPeers parses the bootstrap multiaddrs, which must name their peer
+func bootstrapPeers(addrs []string) ([]peer.AddrInfo, error) {
+	var peers []peer.AddrInfo
+	for _, addr := range addrs {
+		info, err := peer.AddrInfoFromString(strings.TrimSpace(addr))
+		if err != nil {
+			return nil, fmt.Errorf("%w: bootstrap address %q: %w", types.ErrInvalidInput, addr, err)
+		}
+		peers = append(peers, *info)
+	}
+	return peers, nil
+}
+
+// connect dials the bootstrap peers up front, so the first deploy attempt does not race the host's own dialing
+func connect(ctx context.Context, host *p2p.Host, peers []peer.AddrInfo) {
+	for _, info := range peers {
+		connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		if err := host.LibP2PHost().Connect(connectCtx, info); err != nil {
+			annotate("warning", "Bootstrap peer unreachable", fmt.Sprintf("%s: %v", info.ID, err))
+		}
+		cancel()
+	}
+}
+
+// annotate writes a GitHub Actions workflow command to stdout, e.g.
+// "::error title=Deployment failed::message". Text mode only: in JSON mode
+// stdout carries the result.
+func annotate(command string, title string, message string) {
+	if common.Out.IsJSON() {
+		return
+	}
+	line := "::" + command
+	if title != "" {
+		line += " title=" + escapeProperty(title)
+	}
+	common.Out.Printf("%s::%s\n", line, escapeData(message))
+}
+
+// escapeData escapes a workflow command message
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a workflow command property value
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// fileExists returns true if a file exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func init() {
+	Cmd.Flags().StringVar(&packagePath, "package", "", "package to deploy")
+	Cmd.Flags().StringSliceVar(&bootstrap, "bootstrap", nil, "multiaddrs of cluster nodes to connect through (default: node.bootstrap_peers)")
+	Cmd.Flags().StringSliceVar(&nodes, "nodes", nil, "peer IDs to deploy to (default: every bootstrap peer)")
+	Cmd.Flags().StringVar(&psk, "psk", "", "cluster pre-shared key (default: security.psk)")
+	Cmd.Flags().BoolVar(&autoStart, "start", true, "start the application once deployed")
+	Cmd.Flags().BoolVar(&waitHealthy, "wait-healthy", false, "succeed only once the app passes its health check on each node")
+	Cmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "how long --wait-healthy waits for the app to become healthy")
+	Cmd.Flags().IntVar(&retries, "retries", -1, "retries per node after a failed attempt (default: deployment.retry_attempts)")
+	Cmd.Flags().DurationVar(&retryDelay, "retry-delay", 0, "delay before the first retry, doubled after each (default: deployment.retry_delay)")
+	Cmd.Flags().StringVar(&reportPath, "report", "", "write a JSON summary of the deployment to this file")
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/audit"
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// AuditRequest represents a transparency log request
+type AuditRequest struct {
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// AuditResponse represents a transparency log response
+type AuditResponse struct {
+	Success   bool           `json:"success"`
+	Entries   []*audit.Entry `json:"entries,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Code      string         `json:"code,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+}
+
+// FetchAuditLog fetches the deployment transparency log from a target node.
+// The entries are returned as received; callers verify the chain themselves.
+func FetchAuditLog(ctx context.Context, host *p2p.Host, peerID string, logger types.Logger) ([]*audit.Entry, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.AuditProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	req := AuditRequest{
+		RequestID: logging.NewRequestID(),
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting audit log", "peer", peerID)
+
+	var resp AuditResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("audit request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	logger.Info("received audit log", "count", len(resp.Entries))
+	return resp.Entries, nil
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"context"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/backup"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// BackupApp has a target node snapshot the volumes of app and send the
+// snapshot to targets (peer IDs or backup.TargetS3), or to those the node is
+// configured with if there are none
+func BackupApp(ctx context.Context, host *p2p.Host, peerID string, app string, targets []string, logger types.Logger) (*backup.Response, error) {
+	return backupRequest(ctx, host, peerID, &backup.Request{Action: backup.ActionCreate, App: app, Targets: targets}, logger)
+}
+
+// ListSnapshots lists the snapshots of app, or of all apps if app is empty,
+// kept by a target node or, with from set to backup.TargetS3, in its bucket
+func ListSnapshots(ctx context.Context, host *p2p.Host, peerID string, app, from string, logger types.Logger) (*backup.Response, error) {
+	return backupRequest(ctx, host, peerID, &backup.Request{Action: backup.ActionList, App: app, From: from}, logger)
+}
+
+// RestoreVolumes has a target node replace the volumes of app with the
+// snapshot id, or the latest one if id is empty, kept by the node itself, in
+// its bucket (backup.TargetS3), or by the node from
+func RestoreVolumes(ctx context.Context, host *p2p.Host, peerID string, app, id, from string, logger types.Logger) (*backup.Response, error) {
+	return backupRequest(ctx, host, peerID, &backup.Request{Action: backup.ActionRestore, App: app, Snapshot: id, From: from}, logger)
+}
+
+// backupRequest sends req to a target node and returns its response
+func backupRequest(ctx context.Context, host *p2p.Host, peerID string, req *backup.Request, logger types.Logger) (*backup.Response, error) {
+	req.RequestID = logging.NewRequestID()
+	logger = logger.With("request_id", req.RequestID)
+	logger.Info("sending backup request", "peer", peerID, "action", req.Action, "app", req.App)
+
+	resp, err := backup.Do(ctx, host, peerID, req, nil)
+	return resp, withRequestID(err, req.RequestID)
+}
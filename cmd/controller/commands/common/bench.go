@@ -0,0 +1,30 @@
+package common
+
+import (
+	"context"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/bench"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Benchmark measures the latency and throughput of the connection to the
+// node to, from the controller or, if from is set, from that node
+func Benchmark(ctx context.Context, host *p2p.Host, from string, to string, opts bench.Options, logger types.Logger) (*bench.Report, error) {
+	requestID := logging.NewRequestID()
+	logger = logger.With("request_id", requestID)
+	logger.Info("benchmarking connection", "from", from, "to", to, "size", opts.Size, "streams", opts.Streams)
+
+	var report *bench.Report
+	var err error
+	if from == "" {
+		report, err = bench.Run(ctx, host, to, opts, requestID)
+	} else {
+		report, err = bench.RunRemote(ctx, host, from, to, opts, requestID)
+	}
+	if err != nil {
+		return nil, withRequestID(err, requestID)
+	}
+	return report, nil
+}
@@ -1,38 +1,57 @@
 package common
 
 import (
+	"bufio"
 	"context"
-	"encoding/binary"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/asjdf/p2p-playground-lite/pkg/config"
 	"github.com/asjdf/p2p-playground-lite/pkg/consts"
 	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/output"
 	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/pex"
+	"github.com/asjdf/p2p-playground-lite/pkg/transfer"
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
 )
 
 var (
 	CfgFile      string
 	GlobalConfig *config.ControllerConfig
 	GlobalLogger types.Logger
+
+	// Out renders command results to stdout and status messages to stderr
+	Out = output.NewDefault()
 )
 
-// InitConfig initializes configuration and logger
-func InitConfig(cfgFile string) error {
+// InitConfig initializes configuration, output reporter and logger
+func InitConfig(cfgFile string, outputFormat string) error {
 	CfgFile = cfgFile
 
+	format, err := output.ParseFormat(outputFormat)
+	if err != nil {
+		return err
+	}
+	Out = output.New(format, os.Stdout, os.Stderr)
+
 	// Load configuration
-	var err error
 	GlobalConfig, err = LoadConfig(cfgFile)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	// Logs never go to stdout, which is reserved for command results
+	if GlobalConfig.Logging.OutputPath == "" || GlobalConfig.Logging.OutputPath == "stdout" {
+		GlobalConfig.Logging.OutputPath = "stderr"
+	}
+
 	// Initialize logger
 	GlobalLogger, err = logging.New(&GlobalConfig.Logging)
 	if err != nil {
@@ -72,21 +91,31 @@ func LoadConfig(configPath string) (*config.ControllerConfig, error) {
 	return cfg, nil
 }
 
+// defaultLocalSocket is where a daemon with the default data directory serves its local socket
+const defaultLocalSocket = "~/.p2p-playground/" + p2p.LocalSocketName
+
 // CreateP2PHost creates a P2P host using global configuration
 func CreateP2PHost(ctx context.Context) (*p2p.Host, error) {
+	trusted, err := GatedPeers()
+	if err != nil {
+		return nil, err
+	}
+
 	hostConfig := &p2p.HostConfig{
-		ListenAddrs:         GlobalConfig.Node.ListenAddrs,
-		PSK:                 GlobalConfig.Security.PSK,
-		EnableAuth:          GlobalConfig.Security.EnableAuth,
-		TrustedPeers:        []string{}, // Controller doesn't restrict trusted peers
-		BootstrapPeers:      GlobalConfig.Node.BootstrapPeers,
-		DisableDHT:          GlobalConfig.Node.DisableDHT,
-		DHTMode:             GlobalConfig.Node.DHTMode,
-		DisableNATService:   GlobalConfig.Node.DisableNATService,
-		DisableAutoRelay:    GlobalConfig.Node.DisableAutoRelay,
-		DisableHolePunching: GlobalConfig.Node.DisableHolePunching,
-		DisableRelayService: GlobalConfig.Node.DisableRelayService,
-		StaticRelays:        GlobalConfig.Node.StaticRelays,
+		ListenAddrs:            GlobalConfig.Node.ListenAddrs,
+		AddressFamily:          GlobalConfig.Node.AddressFamily,
+		PSK:                    GlobalConfig.Security.PSK,
+		EnableAuth:             GlobalConfig.Security.EnableAuth,
+		TrustedPeers:           trusted,
+		BootstrapPeers:         GlobalConfig.Node.BootstrapPeers,
+		DisableDHT:             GlobalConfig.Node.DisableDHT,
+		DHTMode:                GlobalConfig.Node.DHTMode,
+		DisablePublicBootstrap: GlobalConfig.Node.DisablePublicBootstrap,
+		DisableNATService:      GlobalConfig.Node.DisableNATService,
+		DisableAutoRelay:       GlobalConfig.Node.DisableAutoRelay,
+		DisableHolePunching:    GlobalConfig.Node.DisableHolePunching,
+		DisableRelayService:    GlobalConfig.Node.DisableRelayService,
+		StaticRelays:           GlobalConfig.Node.StaticRelays,
 	}
 
 	host, err := p2p.NewHost(ctx, hostConfig, GlobalLogger)
@@ -94,54 +123,163 @@ func CreateP2PHost(ctx context.Context) (*p2p.Host, error) {
 		return nil, fmt.Errorf("failed to create P2P host: %w", err)
 	}
 
-	// Enable mDNS discovery if configured
-	if GlobalConfig.Node.EnableMDNS {
-		if err := host.EnableMDNS(ctx); err != nil {
-			GlobalLogger.Warn("failed to enable mDNS", "error", err)
+	// Find nodes with the configured discovery backends
+	if err := startDiscovery(host); err != nil {
+		_ = host.Close()
+		return nil, err
+	}
+
+	// Prefer a daemon on this machine over the network
+	if !GlobalConfig.Node.DisableLocalSocket {
+		socket := GlobalConfig.Node.LocalSocket
+		if socket == "" {
+			socket = defaultLocalSocket
+		}
+		if _, err := host.ConnectLocal(ctx, socket); err != nil && GlobalConfig.Node.LocalSocket != "" {
+			GlobalLogger.Warn("failed to connect to local daemon", "socket", socket, "error", err)
+		}
+	}
+
+	// Learn the other cluster members from the daemons we connect to
+	if !GlobalConfig.Node.DisablePeerExchange {
+		if err := pex.NewClient(host.LibP2PHost(), GlobalLogger).Start(); err != nil {
+			GlobalLogger.Warn("failed to start peer exchange", "error", err)
 		}
 	}
 
 	return host, nil
 }
 
+// openStream opens a stream to peerID for a request. The stream is reset if
+// ctx ends before it is closed, so that a cancelled or timed out request stops
+// waiting, and the node stops working on it.
+func openStream(ctx context.Context, host *p2p.Host, peerID string, protocolID string) (types.Stream, error) {
+	stream, err := host.NewStream(ctx, peerID, protocolID)
+	if err != nil {
+		return nil, err
+	}
+	return &requestStream{Stream: stream, stop: context.AfterFunc(ctx, func() { _ = stream.Reset() })}, nil
+}
+
+// requestStream is a stream reset when the context of its request ends
+type requestStream struct {
+	types.Stream
+	stop func() bool
+}
+
+// Close implements io.Closer
+func (s *requestStream) Close() error {
+	s.stop()
+	return s.Stream.Close()
+}
+
 // DeployRequest represents a deployment request
 type DeployRequest struct {
 	FileName  string `json:"file_name"`
 	FileSize  int64  `json:"file_size"`
 	AutoStart bool   `json:"auto_start"`
-	Signature []byte `json:"signature,omitempty"` // Ed25519 signature of the package file
+	Signature []byte `json:"signature,omitempty"`  // Ed25519 signature of the package file
+	RequestID string `json:"request_id,omitempty"` // Correlation ID echoed by the daemon
+
+	// ForceUnlock breaks the lock of another operation on the app instead of failing
+	ForceUnlock bool `json:"force_unlock,omitempty"`
+
+	// Progress asks for status frames, and heartbeats, ahead of the response
+	Progress bool `json:"progress,omitempty"`
+
+	// WaitHealthy succeeds only once the started app is healthy, rolling back otherwise
+	WaitHealthy bool `json:"wait_healthy,omitempty"`
+
+	// HealthTimeout bounds the wait for the app to become healthy
+	HealthTimeout time.Duration `json:"health_timeout,omitempty"`
+
+	// Digest names a package of the registry for the node to fetch instead
+	// of receiving it
+	Digest string `json:"digest,omitempty"`
 }
 
-// DeployResponse represents a deployment response
-type DeployResponse struct {
-	Success bool   `json:"success"`
-	AppID   string `json:"app_id,omitempty"`
-	Error   string `json:"error,omitempty"`
+// DeployOptions controls how a package is deployed
+type DeployOptions struct {
+	// AutoStart starts the application once deployed
+	AutoStart bool
+
+	// ForceUnlock breaks the node's lock on the app held by another operation
+	ForceUnlock bool
+
+	// WaitHealthy reports success only once the started app passes its health
+	// check on the node; otherwise the node rolls the deployment back
+	WaitHealthy bool
+
+	// HealthTimeout bounds the wait for the app to become healthy
+	HealthTimeout time.Duration
+
+	// Quiet leaves out the transfer progress and deploy status of the node,
+	// which would interleave with those of nodes deployed to at the same time
+	Quiet bool
 }
 
-// ListAppsResponse represents the response for list apps request
-type ListAppsResponse struct {
-	Success bool                 `json:"success"`
-	Apps    []*types.Application `json:"apps,omitempty"`
-	Error   string               `json:"error,omitempty"`
+// DeployResponse represents a deployment response, or a status frame ahead of it if Stage is set
+type DeployResponse struct {
+	Success   bool              `json:"success"`
+	AppID     string            `json:"app_id,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Code      string            `json:"code,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Stage     types.DeployStage `json:"stage,omitempty"`
+	Message   string            `json:"message,omitempty"`
+
+	RolledBack bool     `json:"rolled_back,omitempty"` // The new version failed and the previous one was restored
+	Restarted  []string `json:"restarted,omitempty"`   // Previous versions the rollback restarted
 }
 
 // LogsRequest represents a logs request
 type LogsRequest struct {
-	AppID  string `json:"app_id"`
-	Follow bool   `json:"follow"`
-	Tail   int    `json:"tail"`
+	AppID     string        `json:"app_id"`
+	Follow    bool          `json:"follow"`
+	Tail      int           `json:"tail"`
+	Since     time.Duration `json:"since,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
 }
 
 // LogsResponse represents a logs response
 type LogsResponse struct {
-	Success bool   `json:"success"`
-	Logs    string `json:"logs,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success   bool   `json:"success"`
+	Logs      string `json:"logs,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// RequestError annotates an error with the request ID of the protocol exchange,
+// so it can be matched against the daemon's logs on the remote machine.
+type RequestError struct {
+	RequestID string
+	Err       error
+}
+
+// Error implements the error interface
+func (e *RequestError) Error() string {
+	if e.RequestID == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v, request id %s", e.Err, e.RequestID)
+}
+
+// Unwrap returns the underlying error
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// withRequestID wraps err in a RequestError when a request ID is known
+func withRequestID(err error, requestID string) error {
+	if err == nil || requestID == "" {
+		return err
+	}
+	return &RequestError{RequestID: requestID, Err: err}
 }
 
 // DeployPackage deploys a package to a target node
-func DeployPackage(ctx context.Context, host *p2p.Host, peerID string, packagePath string, fileSize int64, autoStart bool, logger types.Logger) (string, error) {
+func DeployPackage(ctx context.Context, host *p2p.Host, peerID string, packagePath string, fileSize int64, opts DeployOptions, logger types.Logger) (string, error) {
 	// Open package file
 	file, err := os.Open(packagePath)
 	if err != nil {
@@ -149,8 +287,20 @@ func DeployPackage(ctx context.Context, host *p2p.Host, peerID string, packagePa
 	}
 	defer func() { _ = file.Close() }()
 
-	// Create stream to target peer
-	stream, err := host.NewStream(ctx, peerID, consts.DeployProtocolID)
+	// Relays are slow and limit what they forward, so the package goes
+	// over a direct connection if one comes up in time
+	route := host.UpgradeDirect(ctx, peerID, DirectUpgradeTimeout)
+
+	// Create stream to target peer. Version 2 checks the package chunk by
+	// chunk; the local daemon is tried with it and version 1 is the fallback.
+	protocolID := consts.Negotiate(consts.CapabilityDeploy, func(id string) bool {
+		return host.IsLocal(peerID) || host.Supports(peerID, id)
+	})
+	stream, err := openStream(ctx, host, peerID, protocolID)
+	if err != nil && protocolID != consts.DeployProtocolID {
+		protocolID = consts.DeployProtocolID
+		stream, err = openStream(ctx, host, peerID, protocolID)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to create stream: %w", err)
 	}
@@ -168,31 +318,85 @@ func DeployPackage(ctx context.Context, host *p2p.Host, peerID string, packagePa
 
 	// Prepare request
 	req := DeployRequest{
-		FileName:  filepath.Base(packagePath),
-		FileSize:  fileSize,
-		AutoStart: autoStart,
-		Signature: signature,
+		FileName:      filepath.Base(packagePath),
+		FileSize:      fileSize,
+		AutoStart:     opts.AutoStart,
+		Signature:     signature,
+		RequestID:     logging.NewRequestID(),
+		ForceUnlock:   opts.ForceUnlock,
+		Progress:      true,
+		WaitHealthy:   opts.WaitHealthy && opts.AutoStart,
+		HealthTimeout: opts.HealthTimeout,
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return "", fmt.Errorf("failed to send header: %w", err)
 	}
 
-	reqBytes, err := json.Marshal(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+	logger.Info("sending package", "file", req.FileName, "size", fileSize)
+
+	// Listen for the response while sending: a node refusing the package
+	// answers before reading any of it
+	responses := make(chan deployResult, 1)
+	go func() {
+		resp, healthy, err := awaitDeployResponse(ctx, stream, opts.Quiet, logger)
+		responses <- deployResult{resp: resp, healthy: healthy, err: err}
+	}()
+	sendErrs := make(chan error, 1)
+	sendStart := time.Now()
+	var dst io.Writer = stream
+	if protocolID != consts.DeployProtocolID {
+		dst = transfer.NewFrameWriter(stream)
+	}
+	go func() {
+		sendErrs <- sendPackage(dst, file, fileSize, opts.Quiet)
+	}()
+
+	var result deployResult
+	select {
+	case err := <-sendErrs:
+		if err != nil {
+			_ = stream.Reset()
+			return "", withRequestID(err, req.RequestID)
+		}
+		elapsed := time.Since(sendStart)
+		logger.Info("package sent", "size", fileSize, "route", route, "duration", elapsed)
+		reportTransfer(peerID, route, fileSize, elapsed, opts.Quiet, logger)
+		result = <-responses
+	case result = <-responses:
+		// Unblock the sender, which the node stopped reading from
+		_ = stream.Reset()
+		<-sendErrs
 	}
 
-	// Send request header size
-	reqSize := uint32(len(reqBytes))
-	if err := binary.Write(stream, binary.BigEndian, reqSize); err != nil {
-		return "", fmt.Errorf("failed to send header size: %w", err)
+	resp, healthy, err := result.resp, result.healthy, result.err
+	if err != nil {
+		return "", withRequestID(err, req.RequestID)
 	}
+	logger.Info("received deploy response", "success", resp.Success)
 
-	// Send request header
-	if _, err := stream.Write(reqBytes); err != nil {
-		return "", fmt.Errorf("failed to send header: %w", err)
+	if !resp.Success {
+		return "", withRequestID(fmt.Errorf("deployment failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), resp.RequestID)
+	}
+	if req.WaitHealthy && !healthy {
+		// Nodes that predate health-gated deploys ignore the request
+		return "", withRequestID(fmt.Errorf("%w: node deployed %s but cannot wait for it to become healthy; upgrade the node", types.ErrNotImplemented, resp.AppID), resp.RequestID)
 	}
 
-	logger.Info("sending package", "file", req.FileName, "size", fileSize)
+	return resp.AppID, nil
+}
 
-	// Send file content
+// deployResult is the outcome of waiting for a deploy response
+type deployResult struct {
+	resp    *DeployResponse
+	healthy bool
+	err     error
+}
+
+// sendPackage writes the content of a package of fileSize bytes to dst,
+// reporting progress in steps of 10% unless quiet
+func sendPackage(dst io.Writer, file *os.File, fileSize int64, quiet bool) error {
 	buf := make([]byte, 64*1024) // 64KB chunks
 	var sent int64
 	lastProgress := 0
@@ -200,143 +404,230 @@ func DeployPackage(ctx context.Context, host *p2p.Host, peerID string, packagePa
 	for {
 		n, err := file.Read(buf)
 		if err != nil && err != io.EOF {
-			return "", fmt.Errorf("failed to read file: %w", err)
+			return fmt.Errorf("failed to read file: %w", err)
 		}
 
 		if n == 0 {
 			break
 		}
 
-		if _, err := stream.Write(buf[:n]); err != nil {
-			return "", fmt.Errorf("failed to send chunk: %w", err)
+		if _, err := dst.Write(buf[:n]); err != nil {
+			return fmt.Errorf("failed to send chunk: %w", err)
 		}
 
 		sent += int64(n)
-		progress := int(float64(sent) / float64(fileSize) * 100)
-		if progress > lastProgress && progress%10 == 0 {
-			fmt.Printf("  Progress: %d%%\n", progress)
-			lastProgress = progress
+		if fileSize <= 0 || quiet {
+			continue
+		}
+		// Integer math keeps this exact for any size, and each step is
+		// reported however many a chunk spans
+		if step := int(sent*100/fileSize) / 10 * 10; step > lastProgress {
+			Out.Statusf("  Progress: %d%%\n", step)
+			lastProgress = step
 		}
 	}
 
-	fmt.Printf("  Progress: 100%%\n")
-	logger.Info("package sent", "size", sent)
-
-	// Read response header size
-	var respSize uint32
-	if err := binary.Read(stream, binary.BigEndian, &respSize); err != nil {
-		return "", fmt.Errorf("failed to read response size: %w", err)
+	if lastProgress < 100 && !quiet {
+		Out.Statusf("  Progress: 100%%\n")
 	}
+	return nil
+}
 
-	// Read response
-	respBytes := make([]byte, respSize)
-	if _, err := io.ReadFull(stream, respBytes); err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
+// deployFrame is a frame read from a deploy stream
+type deployFrame struct {
+	resp *DeployResponse
+	err  error
+}
 
-	var resp DeployResponse
-	if err := json.Unmarshal(respBytes, &resp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
-	}
+// awaitDeployResponse renders the status frames of a deployment until its
+// response arrives, unless quiet, and reports whether the node said the app
+// is healthy.
+// Once the node has sent a first status, it must send another within
+// consts.DeployHeartbeatTimeout or it is considered dead; nodes that predate
+// status frames are waited on as long as ctx allows.
+func awaitDeployResponse(ctx context.Context, stream types.Stream, quiet bool, logger types.Logger) (*DeployResponse, bool, error) {
+	frames := make(chan deployFrame)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		for {
+			resp, err := readDeployFrame(stream)
+			select {
+			case frames <- deployFrame{resp: resp, err: err}:
+			case <-quit:
+				return
+			}
+			if err != nil || resp.Stage == "" {
+				return
+			}
+		}
+	}()
 
-	if !resp.Success {
-		return "", fmt.Errorf("deployment failed on node: %s", resp.Error)
+	var timeout <-chan time.Time
+	healthy := false
+	for {
+		select {
+		case <-ctx.Done():
+			_ = stream.Reset()
+			return nil, false, ctx.Err()
+		case <-timeout:
+			_ = stream.Reset()
+			return nil, false, fmt.Errorf("%w: node sent no deploy status for %s", types.ErrTimeout, consts.DeployHeartbeatTimeout)
+		case frame := <-frames:
+			if frame.err != nil {
+				return nil, false, frame.err
+			}
+			if frame.resp.Stage == "" {
+				return frame.resp, healthy, nil
+			}
+			timeout = time.After(consts.DeployHeartbeatTimeout)
+			if frame.resp.Stage == types.DeployStageHeartbeat {
+				continue
+			}
+			if frame.resp.Stage == types.DeployStageHealthy {
+				healthy = true
+			}
+			logger.Debug("deploy status", "stage", frame.resp.Stage, "message", frame.resp.Message)
+			if !quiet {
+				Out.Statusf("  %s\n", frame.resp.Message)
+			}
+		}
 	}
-
-	return resp.AppID, nil
 }
 
-// ListApplications lists applications on a target node
-func ListApplications(ctx context.Context, host *p2p.Host, peerID string, logger types.Logger) ([]*types.Application, error) {
-	// Create stream to target peer
-	stream, err := host.NewStream(ctx, peerID, consts.ListProtocolID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create stream: %w", err)
+// readDeployFrame reads one length-prefixed deploy response or status frame
+func readDeployFrame(stream types.Stream) (*DeployResponse, error) {
+	var resp DeployResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
-	defer func() { _ = stream.Close() }()
-
-	logger.Info("requesting application list", "peer", peerID)
+	return &resp, nil
+}
 
-	// Read response header size
-	var respSize uint32
-	if err := binary.Read(stream, binary.BigEndian, &respSize); err != nil {
-		return nil, fmt.Errorf("failed to read response size: %w", err)
+// FormatMetrics formats app metrics as name=value pairs sorted by name
+func FormatMetrics(metrics map[string]float64) string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	// Read response
-	respBytes := make([]byte, respSize)
-	if _, err := io.ReadFull(stream, respBytes); err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%g", name, metrics[name]))
 	}
+	return strings.Join(pairs, ", ")
+}
 
-	var resp ListAppsResponse
-	if err := json.Unmarshal(respBytes, &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+// FormatPorts formats the allocated ports of an app as name=port pairs sorted by name
+func FormatPorts(ports map[string]int) string {
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	if !resp.Success {
-		return nil, fmt.Errorf("list failed on node: %s", resp.Error)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", name, ports[name]))
 	}
+	return strings.Join(pairs, ", ")
+}
 
-	logger.Info("received application list", "count", len(resp.Apps))
-	return resp.Apps, nil
+// LogOptions selects the application log lines fetched from a node
+type LogOptions struct {
+	Tail  int           // Number of lines from end, 0 for all
+	Since time.Duration // Only lines written within this long, 0 for all
 }
 
 // FetchLogs fetches logs from an application on a target node
-func FetchLogs(ctx context.Context, host *p2p.Host, peerID string, appID string, follow bool, tail int, logger types.Logger) (string, error) {
-	// Create stream to target peer
-	stream, err := host.NewStream(ctx, peerID, consts.LogsProtocolID)
+func FetchLogs(ctx context.Context, host *p2p.Host, peerID string, appID string, opts LogOptions, logger types.Logger) (string, error) {
+	stream, resp, err := requestLogs(ctx, host, peerID, appID, opts, false, logger)
 	if err != nil {
-		return "", fmt.Errorf("failed to create stream: %w", err)
+		return "", err
+	}
+	_ = stream.Close()
+	return resp.Logs, nil
+}
+
+// FollowLogs streams logs from an application on a target node, calling onLine
+// for every line, until ctx is done or the node ends the stream
+func FollowLogs(ctx context.Context, host *p2p.Host, peerID string, appID string, opts LogOptions, onLine func(line string), logger types.Logger) error {
+	stream, resp, err := requestLogs(ctx, host, peerID, appID, opts, true, logger)
+	if err != nil {
+		return err
 	}
 	defer func() { _ = stream.Close() }()
 
-	// Prepare request
-	req := LogsRequest{
-		AppID:  appID,
-		Follow: follow,
-		Tail:   tail,
+	// Nodes stream every line after the response; older ones sent them all in it
+	for _, line := range strings.Split(resp.Logs, "\n") {
+		if line != "" {
+			onLine(line)
+		}
+	}
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			onLine(line)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
 	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading log stream: %w", err)
+	}
+	return nil
+}
+
+// maxLogLineSize bounds a single line of a followed log
+const maxLogLineSize = 1 << 20
 
-	reqBytes, err := json.Marshal(req)
+// requestLogs sends a logs request and reads the response, leaving the stream
+// open for the lines a node streams after it when following
+func requestLogs(ctx context.Context, host *p2p.Host, peerID string, appID string, opts LogOptions, follow bool, logger types.Logger) (types.Stream, *LogsResponse, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.LogsProtocolID)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create stream: %w", err)
 	}
 
-	// Send request header size
-	reqSize := uint32(len(reqBytes))
-	if err := binary.Write(stream, binary.BigEndian, reqSize); err != nil {
-		return "", fmt.Errorf("failed to send header size: %w", err)
+	resp, err := exchangeLogs(stream, appID, opts, follow, logger)
+	if err != nil {
+		_ = stream.Close()
+		return nil, nil, err
 	}
+	return stream, resp, nil
+}
 
-	// Send request header
-	if _, err := stream.Write(reqBytes); err != nil {
-		return "", fmt.Errorf("failed to send header: %w", err)
+// exchangeLogs sends a logs request on stream and reads the response
+func exchangeLogs(stream types.Stream, appID string, opts LogOptions, follow bool, logger types.Logger) (*LogsResponse, error) {
+	// Prepare request
+	req := LogsRequest{
+		AppID:     appID,
+		Follow:    follow,
+		Tail:      opts.Tail,
+		Since:     opts.Since,
+		RequestID: logging.NewRequestID(),
 	}
+	logger = logger.With("request_id", req.RequestID)
 
-	logger.Info("requesting logs", "app_id", appID, "tail", tail)
-
-	// Read response header size
-	var respSize uint32
-	if err := binary.Read(stream, binary.BigEndian, &respSize); err != nil {
-		return "", fmt.Errorf("failed to read response size: %w", err)
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
 	}
 
-	// Read response
-	respBytes := make([]byte, respSize)
-	if _, err := io.ReadFull(stream, respBytes); err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
+	logger.Info("requesting logs", "app_id", appID, "follow", follow, "tail", opts.Tail, "since", opts.Since)
 
 	var resp LogsResponse
-	if err := json.Unmarshal(respBytes, &resp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
 	}
 
 	if !resp.Success {
-		return "", fmt.Errorf("logs request failed on node: %s", resp.Error)
+		return nil, withRequestID(fmt.Errorf("logs request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), resp.RequestID)
 	}
 
 	logger.Info("received logs", "size", len(resp.Logs))
-	return resp.Logs, nil
+	return &resp, nil
 }
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// ControlRequest represents a request to pause or resume a deployed app
+type ControlRequest struct {
+	AppID     string          `json:"app_id"`
+	Action    types.AppAction `json:"action"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// ControlResponse represents a control response
+type ControlResponse struct {
+	Success   bool               `json:"success"`
+	App       *types.Application `json:"app,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	Code      string             `json:"code,omitempty"`
+	RequestID string             `json:"request_id,omitempty"`
+}
+
+// ControlApp applies action to a deployed app on a target node and returns
+// the app after it
+func ControlApp(ctx context.Context, host *p2p.Host, peerID string, appID string, action types.AppAction, logger types.Logger) (*types.Application, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.ControlProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	req := ControlRequest{
+		AppID:     appID,
+		Action:    action,
+		RequestID: logging.NewRequestID(),
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting app control", "peer", peerID, "app_id", appID, "action", action)
+
+	var resp ControlResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("%s failed on node: %w", action, types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	logger.Info("app controlled", "action", action, "status", resp.App.Status)
+	return resp.App, nil
+}
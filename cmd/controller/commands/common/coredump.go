@@ -0,0 +1,93 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/coredump"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// CoreDumpRequest represents a request to list the core dumps of an app or to fetch one
+type CoreDumpRequest struct {
+	AppID     string `json:"app_id"`
+	Name      string `json:"name,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// CoreDumpResponse represents a core dump response
+type CoreDumpResponse struct {
+	Success   bool            `json:"success"`
+	Dumps     []coredump.Dump `json:"dumps,omitempty"`
+	Dump      *coredump.Dump  `json:"dump,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Code      string          `json:"code,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// ListCoreDumps lists the core dumps of an app on a target node, newest first
+func ListCoreDumps(ctx context.Context, host *p2p.Host, peerID string, appID string, logger types.Logger) ([]coredump.Dump, error) {
+	var dumps []coredump.Dump
+	err := coreDumpRequest(ctx, host, peerID, CoreDumpRequest{AppID: appID}, logger, func(resp *CoreDumpResponse, _ io.Reader) error {
+		dumps = resp.Dumps
+		return nil
+	})
+	return dumps, err
+}
+
+// FetchCoreDump writes the core dump called name of an app on a target node to w
+func FetchCoreDump(ctx context.Context, host *p2p.Host, peerID string, appID, name string, w io.Writer, logger types.Logger) (*coredump.Dump, error) {
+	var dump *coredump.Dump
+	err := coreDumpRequest(ctx, host, peerID, CoreDumpRequest{AppID: appID, Name: name}, logger, func(resp *CoreDumpResponse, stream io.Reader) error {
+		dump = resp.Dump
+		if dump == nil {
+			return fmt.Errorf("node sent no core dump")
+		}
+		n, err := io.Copy(w, io.LimitReader(stream, dump.Size))
+		if err != nil {
+			return fmt.Errorf("failed to receive core dump: %w", err)
+		}
+		if n != dump.Size {
+			return fmt.Errorf("core dump truncated: received %d of %d bytes", n, dump.Size)
+		}
+		return nil
+	})
+	return dump, err
+}
+
+// coreDumpRequest sends req to a target node and hands the response, and the
+// stream carrying what follows it, to handle
+func coreDumpRequest(ctx context.Context, host *p2p.Host, peerID string, req CoreDumpRequest, logger types.Logger, handle func(resp *CoreDumpResponse, stream io.Reader) error) error {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.CoreDumpProtocolID)
+	if err != nil {
+		return fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	req.RequestID = logging.NewRequestID()
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting core dumps", "peer", peerID, "app_id", req.AppID, "name", req.Name)
+
+	var resp CoreDumpResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return withRequestID(fmt.Errorf("core dump request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	return withRequestID(handle(&resp, stream), req.RequestID)
+}
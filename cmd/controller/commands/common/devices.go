@@ -0,0 +1,114 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/device"
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/interpreter"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/platform"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// DeviceDiscoveryTimeout is how long to wait for node announcements when placing
+// an app that needs devices. Nodes announce every discovery.AnnounceInterval.
+const DeviceDiscoveryTimeout = discovery.AnnounceInterval + 2*time.Second
+
+// Restricted reports whether manifest limits the nodes that can run the app
+// other than by devices: to a platform or to nodes having its interpreter
+func Restricted(manifest *types.Manifest) bool {
+	return platform.Specific(manifest.Platform) || manifest.Interpreter != ""
+}
+
+// CapableNodes returns the peers among peerIDs whose announced devices satisfy the
+// manifest's device requests, whose platform runs the app and whose labels advertise
+// its interpreter. Peers that do not announce themselves in time are left out, and
+// peers that do not announce their platform are kept, to refuse the app themselves.
+// Without device requests or restrictions peerIDs is returned unchanged.
+func CapableNodes(ctx context.Context, host *p2p.Host, peerIDs []string, manifest *types.Manifest, logger types.Logger) ([]string, error) {
+	if len(manifest.Devices) == 0 && !Restricted(manifest) {
+		return peerIDs, nil
+	}
+
+	nodes, err := Discovery(host)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]peer.ID, 0, len(peerIDs))
+	for _, id := range peerIDs {
+		decoded, err := peer.Decode(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid peer ID %s: %v", types.ErrInvalidInput, id, err)
+		}
+		ids = append(ids, decoded)
+	}
+
+	// Wait until every candidate has announced its devices
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(DeviceDiscoveryTimeout)
+	for !allAnnounced(nodes, ids) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			logger.Warn("not all nodes announced themselves in time")
+			return capable(nodes, ids, manifest)
+		case <-ticker.C:
+		}
+	}
+
+	return capable(nodes, ids, manifest)
+}
+
+// allAnnounced reports whether every peer in ids has announced itself
+func allAnnounced(nodes *discovery.Merged, ids []peer.ID) bool {
+	for _, id := range ids {
+		// Only announcements carry devices and platforms
+		if node := nodes.Node(id); node == nil || !slices.Contains(node.Sources, discovery.BackendGossip) {
+			return false
+		}
+	}
+	return true
+}
+
+// capable returns the discovered peers providing the devices of manifest,
+// running its platform and having its interpreter
+func capable(nodes *discovery.Merged, ids []peer.ID, manifest *types.Manifest) ([]string, error) {
+	var result []string
+	for _, id := range ids {
+		node := nodes.Node(id)
+		if node == nil || !device.Satisfies(manifest.Devices, node.Devices) {
+			continue
+		}
+		if node.Platform != "" && !platform.Matches(manifest.Platform, node.Platform) {
+			continue
+		}
+		if manifest.Interpreter != "" && node.Labels[interpreter.Label(manifest.Interpreter)] != "true" {
+			continue
+		}
+		result = append(result, id.String())
+	}
+
+	if len(result) == 0 {
+		var needs []string
+		if len(manifest.Devices) > 0 {
+			needs = append(needs, "provides devices "+strings.Join(manifest.Devices, ", "))
+		}
+		if platform.Specific(manifest.Platform) {
+			needs = append(needs, "runs "+manifest.Platform)
+		}
+		if manifest.Interpreter != "" {
+			needs = append(needs, "has interpreter "+manifest.Interpreter)
+		}
+		return nil, fmt.Errorf("%w: no node %s", types.ErrUnavailable, strings.Join(needs, " and "))
+	}
+	return result, nil
+}
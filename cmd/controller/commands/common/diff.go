@@ -0,0 +1,123 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// ChangeKind tells how a manifest field or file differs between the deployed
+// version of an app and a new one
+type ChangeKind string
+
+const (
+	// ChangeAdded marks what only the new version has
+	ChangeAdded ChangeKind = "added"
+
+	// ChangeRemoved marks what only the deployed version has
+	ChangeRemoved ChangeKind = "removed"
+
+	// ChangeModified marks what both have with different values
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change is a difference in a manifest field or a file
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+
+	// Path is the manifest field as named in manifest.yaml, dotted for nested
+	// fields such as "env.PORT", or the slash-separated path of a file
+	Path string `json:"path"`
+
+	// Old and New are the deployed and new values of a field, or the
+	// SHA-256 of a file
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// DiffManifests returns the fields that differ between the deployed and the
+// new manifest of an app, sorted by field
+func DiffManifests(deployed, updated *types.Manifest) ([]Change, error) {
+	old, err := manifestFields(deployed)
+	if err != nil {
+		return nil, err
+	}
+	current, err := manifestFields(updated)
+	if err != nil {
+		return nil, err
+	}
+	return diffValues(old, current), nil
+}
+
+// DiffFiles returns the files that differ between the deployed and the new
+// version of an app, given the SHA-256 of each file by path, sorted by path
+func DiffFiles(deployed, updated map[string]string) []Change {
+	return diffValues(deployed, updated)
+}
+
+// diffValues compares two sets of values by key
+func diffValues(old, current map[string]string) []Change {
+	var changes []Change
+	for key, value := range old {
+		newValue, ok := current[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: ChangeRemoved, Path: key, Old: value})
+		case newValue != value:
+			changes = append(changes, Change{Kind: ChangeModified, Path: key, Old: value, New: newValue})
+		}
+	}
+	for key, value := range current {
+		if _, ok := old[key]; !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Path: key, New: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// manifestFields flattens a manifest into its fields as named in
+// manifest.yaml, formatting each value on one line
+func manifestFields(manifest *types.Manifest) (map[string]string, error) {
+	fields := make(map[string]string)
+	if manifest == nil {
+		return fields, nil
+	}
+
+	// Going through YAML names the fields and formats durations as in manifest.yaml
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to marshal manifest")
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, types.WrapError(err, "failed to parse manifest")
+	}
+	flatten("", tree, fields)
+	return fields, nil
+}
+
+// flatten adds the leaves of tree to fields, keyed by their dotted path
+func flatten(prefix string, tree map[string]interface{}, fields map[string]string) {
+	for key, value := range tree {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(path, v, fields)
+		case []interface{}:
+			data, err := json.Marshal(v)
+			if err != nil {
+				data = []byte(fmt.Sprint(v))
+			}
+			fields[path] = string(data)
+		default:
+			fields[path] = fmt.Sprint(v)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package common_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestDiffManifests(t *testing.T) {
+	deployed := &types.Manifest{
+		Name:       "web",
+		Version:    "1.0.0",
+		Entrypoint: "bin/web",
+		Args:       []string{"-addr", ":8080"},
+		Env:        map[string]string{"PORT": "8080", "DEBUG": "1"},
+		Resources:  &types.ResourceLimits{MemoryMB: 256},
+		HealthCheck: &types.HealthCheckConfig{
+			Type:     "http",
+			Endpoint: "http://localhost:8080/health",
+			Interval: 10 * time.Second,
+		},
+	}
+	updated := &types.Manifest{
+		Name:       "web",
+		Version:    "1.1.0",
+		Entrypoint: "bin/web",
+		Args:       []string{"-addr", ":9090"},
+		Env:        map[string]string{"PORT": "9090", "LOG_LEVEL": "info"},
+		Resources:  &types.ResourceLimits{MemoryMB: 256, CPUPercent: 50},
+		HealthCheck: &types.HealthCheckConfig{
+			Type:     "http",
+			Endpoint: "http://localhost:9090/health",
+			Interval: 30 * time.Second,
+		},
+	}
+
+	changes, err := common.DiffManifests(deployed, updated)
+	if err != nil {
+		t.Fatalf("DiffManifests() error = %v", err)
+	}
+	want := []common.Change{
+		{Kind: common.ChangeModified, Path: "args", Old: `["-addr",":8080"]`, New: `["-addr",":9090"]`},
+		{Kind: common.ChangeRemoved, Path: "env.DEBUG", Old: "1"},
+		{Kind: common.ChangeAdded, Path: "env.LOG_LEVEL", New: "info"},
+		{Kind: common.ChangeModified, Path: "env.PORT", Old: "8080", New: "9090"},
+		{Kind: common.ChangeModified, Path: "health_check.endpoint", Old: "http://localhost:8080/health", New: "http://localhost:9090/health"},
+		{Kind: common.ChangeModified, Path: "health_check.interval", Old: "10s", New: "30s"},
+		{Kind: common.ChangeAdded, Path: "resources.cpu_percent", New: "50"},
+		{Kind: common.ChangeModified, Path: "version", Old: "1.0.0", New: "1.1.0"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("DiffManifests() =\n%v\nwant\n%v", changes, want)
+	}
+
+	if changes, err := common.DiffManifests(deployed, deployed); err != nil || len(changes) != 0 {
+		t.Errorf("DiffManifests() of the same manifest = %v, %v, want no changes", changes, err)
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	deployed := map[string]string{"bin/web": "aaa", "static/app.js": "bbb", "old.txt": "ccc"}
+	updated := map[string]string{"bin/web": "ddd", "static/app.js": "bbb", "static/new.js": "eee"}
+
+	want := []common.Change{
+		{Kind: common.ChangeModified, Path: "bin/web", Old: "aaa", New: "ddd"},
+		{Kind: common.ChangeRemoved, Path: "old.txt", Old: "ccc"},
+		{Kind: common.ChangeAdded, Path: "static/new.js", New: "eee"},
+	}
+	if got := common.DiffFiles(deployed, updated); !slices.Equal(got, want) {
+		t.Errorf("DiffFiles() = %v, want %v", got, want)
+	}
+}
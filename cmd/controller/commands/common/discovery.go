@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
+)
+
+// discoveries holds the node view of each host created by CreateP2PHost
+var (
+	discoveriesMu sync.Mutex
+	discoveries   = make(map[*p2p.Host]*discovery.Merged)
+)
+
+// startDiscovery starts the configured discovery backends for host until it
+// closes. The gossip backend is only joined by Discovery, so commands that
+// talk to a single node do not announce the controller.
+func startDiscovery(host *p2p.Host) error {
+	nodes, err := discovery.New(host.LibP2PHost(), GlobalLogger, &discovery.Options{
+		Backends:    discovery.Backends(&GlobalConfig.Node),
+		Routing:     host.Routing(),
+		StaticPeers: GlobalConfig.Node.StaticPeers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create discovery: %w", err)
+	}
+	if err := nodes.Start(); err != nil {
+		return fmt.Errorf("failed to start discovery: %w", err)
+	}
+
+	discoveriesMu.Lock()
+	discoveries[host] = nodes
+	discoveriesMu.Unlock()
+
+	host.OnClose(func() {
+		discoveriesMu.Lock()
+		delete(discoveries, host)
+		discoveriesMu.Unlock()
+		nodes.Stop()
+	})
+	return nil
+}
+
+// Discovery returns the merged view of the nodes found by the discovery
+// backends of a host created by CreateP2PHost. Unless configured otherwise it
+// joins the gossip backend, so the nodes carry the details they announce.
+func Discovery(host *p2p.Host) (*discovery.Merged, error) {
+	discoveriesMu.Lock()
+	nodes := discoveries[host]
+	discoveriesMu.Unlock()
+	if nodes == nil {
+		return nil, fmt.Errorf("%w: host was not created by CreateP2PHost", types.ErrInvalidState)
+	}
+
+	if nodes.Gossip() == nil && slices.Contains(discovery.Backends(&GlobalConfig.Node), discovery.BackendGossip) {
+		svc, err := discovery.NewService(host.LibP2PHost(), GlobalLogger, &discovery.Config{
+			NodeName: "controller",
+			Version:  version.Get().Version,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create discovery service: %w", err)
+		}
+		if err := nodes.Add(svc); err != nil {
+			return nil, fmt.Errorf("failed to start discovery service: %w", err)
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,154 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/capacity"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/policy"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Dry-run actions describing what a deployment would do on a node
+const (
+	DryRunActionInstall = "install"
+	DryRunActionReplace = "replace"
+	DryRunActionSkip    = "skip"
+)
+
+// DryRunReport describes what a deployment would do without doing it
+type DryRunReport struct {
+	Package     string             `json:"package"`
+	PackageSize int64              `json:"package_size"`
+	Checksum    string             `json:"checksum"`
+	Signed      bool               `json:"signed"`
+	Manifest    *types.Manifest    `json:"manifest"`
+	AutoStart   bool               `json:"auto_start"`
+	Violations  []policy.Violation `json:"policy_violations,omitempty"`
+	Nodes       []DryRunNode       `json:"nodes"`
+}
+
+// DryRunNode describes the planned action on a single node
+type DryRunNode struct {
+	NodeID        string              `json:"node_id"`
+	AppID         string              `json:"app_id"`
+	Action        string              `json:"action"`
+	CurrentStatus types.AppStatusType `json:"current_status,omitempty"`
+	Warnings      []string            `json:"warnings,omitempty"`
+	Error         string              `json:"error,omitempty"`
+}
+
+// PlanDeployment checks each target node without transferring anything.
+// It uses the read-only list protocol to confirm the node is reachable and to
+// find out whether the application is already deployed there.
+func PlanDeployment(ctx context.Context, host *p2p.Host, peerIDs []string, manifest *types.Manifest, logger types.Logger) []DryRunNode {
+	// The node picks the ID from its app ID format; <name>-<version> names the
+	// version whatever the format
+	appID := types.LegacyAppID(manifest.Name, manifest.Version)
+
+	nodes := make([]DryRunNode, 0, len(peerIDs))
+	for _, peerID := range peerIDs {
+		node := DryRunNode{
+			NodeID: peerID,
+			AppID:  appID,
+			Action: DryRunActionInstall,
+		}
+
+		apps, err := ListApplications(ctx, host, peerID, logger)
+		if err != nil {
+			node.Action = DryRunActionSkip
+			node.Error = err.Error()
+			nodes = append(nodes, node)
+			continue
+		}
+
+		for _, app := range apps {
+			if app.Name == manifest.Name && app.Version == manifest.Version {
+				node.Action = DryRunActionReplace
+				node.CurrentStatus = app.Status
+				if app.Status == types.AppStatusRunning {
+					node.Warnings = append(node.Warnings, "application is running and would be restarted")
+				}
+			}
+		}
+
+		if manifest.Resources != nil && (manifest.Resources.MemoryMB > 0 || manifest.Resources.CPUPercent > 0) {
+			node.Warnings = append(node.Warnings, capacityWarnings(ctx, host, peerID, manifest, apps, logger)...)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
+// capacityWarnings checks the resource limits of manifest against the
+// capacity a node advertises, apps being the apps deployed there
+func capacityWarnings(ctx context.Context, host *p2p.Host, peerID string, manifest *types.Manifest, apps []*types.Application, logger types.Logger) []string {
+	info, err := FetchNodeInfo(ctx, host, peerID, logger)
+	if err != nil || info.Capacity == nil {
+		// Nodes that predate capacity reporting
+		return []string{"declares resource limits; node capacity is not reported and was not checked"}
+	}
+
+	// The versions of the app being replaced free their share
+	c := info.Capacity
+	for _, app := range apps {
+		if app.Name == manifest.Name && capacity.Allocates(app) && app.Manifest != nil && app.Manifest.Resources != nil {
+			c.Allocated.CPUPercent -= app.Manifest.Resources.CPUPercent
+			c.Allocated.MemoryMB -= app.Manifest.Resources.MemoryMB
+		}
+	}
+	if err := capacity.Check(c, manifest.Resources); err != nil {
+		if c.Enforced {
+			return []string{fmt.Sprintf("would be refused: %v", err)}
+		}
+		return []string{fmt.Sprintf("does not fit in the node's free capacity: %v", err)}
+	}
+	return nil
+}
+
+// PrintDryRun renders a dry-run report
+func PrintDryRun(report *DryRunReport) error {
+	return Out.Result(report, func() {
+		Out.Printf("\nDry run: nothing was transferred or started\n\n")
+		Out.Printf("Package:   %s (%d bytes)\n", report.Package, report.PackageSize)
+		Out.Printf("Checksum:  sha256:%s\n", report.Checksum)
+		Out.Printf("App:       %s %s (entrypoint: %s)\n", report.Manifest.Name, report.Manifest.Version, report.Manifest.Entrypoint)
+		if report.Signed {
+			Out.Printf("Signature: present\n")
+		} else {
+			Out.Printf("Signature: none (nodes without allow_unsigned_packages will reject it)\n")
+		}
+
+		if len(report.Violations) > 0 {
+			Out.Printf("\nPolicy violations (the deployment would be refused):\n")
+			for _, v := range report.Violations {
+				Out.Printf("  ✗ %s\n", v)
+			}
+		}
+
+		Out.Printf("\nPlanned actions on %d node(s):\n", len(report.Nodes))
+		for _, node := range report.Nodes {
+			switch node.Action {
+			case DryRunActionSkip:
+				Out.Printf("  ✗ %s: unreachable, would fail (%s)\n", node.NodeID, node.Error)
+			case DryRunActionReplace:
+				Out.Printf("  ~ %s: replace %s (currently %s)", node.NodeID, node.AppID, node.CurrentStatus)
+			default:
+				Out.Printf("  + %s: install %s", node.NodeID, node.AppID)
+			}
+			if node.Action != DryRunActionSkip {
+				if report.AutoStart {
+					Out.Printf(" and start\n")
+				} else {
+					Out.Printf("\n")
+				}
+			}
+			for _, w := range node.Warnings {
+				Out.Printf("      ! %s\n", w)
+			}
+		}
+	})
+}
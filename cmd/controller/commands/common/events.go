@@ -0,0 +1,66 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/events"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// EventsRequest represents a request for the logged app events
+type EventsRequest struct {
+	Since     time.Duration    `json:"since,omitempty"`
+	AppID     string           `json:"app_id,omitempty"`
+	Types     []types.AppEvent `json:"types,omitempty"`
+	RequestID string           `json:"request_id,omitempty"`
+}
+
+// EventsResponse represents an events response
+type EventsResponse struct {
+	Success   bool            `json:"success"`
+	Events    []*events.Event `json:"events,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Code      string          `json:"code,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// FetchEvents fetches the app lifecycle events a target node logged that
+// pass the filters of req, oldest first
+func FetchEvents(ctx context.Context, host *p2p.Host, peerID string, req EventsRequest, logger types.Logger) ([]*events.Event, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.EventsProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	if req.RequestID == "" {
+		req.RequestID = logging.NewRequestID()
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting app events", "peer", peerID, "app_id", req.AppID, "since", req.Since, "types", req.Types)
+
+	var resp EventsResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("events request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	logger.Info("received app events", "count", len(resp.Events))
+	return resp.Events, nil
+}
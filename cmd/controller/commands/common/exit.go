@@ -0,0 +1,101 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Process exit codes returned by the controller.
+// They are part of the CLI contract: CI scripts branch on them, so existing values must not change.
+const (
+	// ExitError is returned for any failure without a more specific code
+	ExitError = 1
+
+	// ExitNoNodes is returned when no target node could be discovered
+	ExitNoNodes = 2
+
+	// ExitRejected is returned when a node rejected the request
+	ExitRejected = 3
+
+	// ExitSignatureInvalid is returned when a node rejected the package signature
+	ExitSignatureInvalid = 4
+
+	// ExitTimeout is returned when an operation timed out
+	ExitTimeout = 5
+
+	// ExitUnhealthy is returned when a deployed app failed to become healthy
+	ExitUnhealthy = 6
+)
+
+// ErrNoNodes indicates peer discovery found no target nodes
+var ErrNoNodes = errors.New("no nodes discovered")
+
+// ExitStatus is an exit code to return without an error message, for failures
+// that were already reported, such as a plugin exiting non-zero
+type ExitStatus int
+
+func (s ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+// ExitCodeHelp documents the exit codes for command help text
+const ExitCodeHelp = `Exit codes:
+  0  success
+  1  general error
+  2  no nodes discovered
+  3  request rejected by node or by policy
+  4  package signature missing or invalid
+  5  timeout
+  6  app failed to become healthy (--wait-healthy)`
+
+// ExitCode maps an error returned by a command to a process exit code
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var status ExitStatus
+	if errors.As(err, &status) {
+		return int(status)
+	}
+
+	switch {
+	case errors.Is(err, ErrNoNodes):
+		return ExitNoNodes
+	case errors.Is(err, types.ErrInvalidSignature), errors.Is(err, types.ErrPackageNotSigned):
+		return ExitSignatureInvalid
+	case errors.Is(err, types.ErrAppUnhealthy):
+		return ExitUnhealthy
+	case errors.Is(err, types.ErrPSKMismatch):
+		// The handshake may have ended with a timeout, but waiting longer will not help
+		return ExitError
+	case isTimeout(err):
+		return ExitTimeout
+	case errors.Is(err, types.ErrPolicyViolation):
+		return ExitRejected
+	}
+
+	var appErr *types.AppError
+	if errors.As(err, &appErr) {
+		return ExitRejected
+	}
+
+	return ExitError
+}
+
+// isTimeout reports whether err represents a timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, types.ErrTimeout) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,39 @@
+package common_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, 0},
+		{"generic", errors.New("boom"), common.ExitError},
+		{"no nodes", fmt.Errorf("deploy: %w", common.ErrNoNodes), common.ExitNoNodes},
+		{"rejected", types.ErrorFromCode(types.CodeInsufficientStorage, "disk full"), common.ExitRejected},
+		{"unsigned", types.ErrorFromCode(types.CodePackageNotSigned, "unsigned"), common.ExitSignatureInvalid},
+		{"signature", fmt.Errorf("deploy: %w", types.ErrorFromCode(types.CodeInvalidSignature, "bad")), common.ExitSignatureInvalid},
+		{"deadline", fmt.Errorf("stream: %w", context.DeadlineExceeded), common.ExitTimeout},
+		{"psk mismatch", fmt.Errorf("%w: %w", types.ErrPSKMismatch, context.DeadlineExceeded), common.ExitError},
+		{"unhealthy", fmt.Errorf("deploy: %w", types.ErrorFromCode(types.CodeAppUnhealthy, "not healthy")), common.ExitUnhealthy},
+		{"exit status", common.ExitStatus(7), 7},
+		{"joined", errors.Join(errors.New("node a"), types.ErrorFromCode(types.CodeNotFound, "gone")), common.ExitRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := common.ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
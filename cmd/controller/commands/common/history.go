@@ -0,0 +1,67 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/history"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// HistoryRequest represents an app history request
+type HistoryRequest struct {
+	AppID     string        `json:"app_id,omitempty"`
+	Since     time.Duration `json:"since"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+// HistoryResponse represents an app history response
+type HistoryResponse struct {
+	Success   bool             `json:"success"`
+	Entries   []*history.Entry `json:"entries,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Code      string           `json:"code,omitempty"`
+	RequestID string           `json:"request_id,omitempty"`
+}
+
+// FetchHistory fetches the status samples and events recorded by a target node
+// within since, oldest first. An empty appID fetches the history of all apps.
+func FetchHistory(ctx context.Context, host *p2p.Host, peerID string, appID string, since time.Duration, logger types.Logger) ([]*history.Entry, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.HistoryProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	req := HistoryRequest{
+		AppID:     appID,
+		Since:     since,
+		RequestID: logging.NewRequestID(),
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting app history", "peer", peerID, "app_id", appID, "since", since)
+
+	var resp HistoryResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("history request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	logger.Info("received app history", "count", len(resp.Entries))
+	return resp.Entries, nil
+}
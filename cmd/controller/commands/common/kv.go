@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// KVRequest represents a cluster key-value store request
+type KVRequest struct {
+	Op        string `json:"op"`
+	Namespace string `json:"namespace,omitempty"`
+	Key       string `json:"key,omitempty"`
+	Value     []byte `json:"value,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// KVResponse represents a cluster key-value store response
+type KVResponse struct {
+	Success   bool     `json:"success"`
+	Value     []byte   `json:"value,omitempty"`
+	Keys      []string `json:"keys,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Code      string   `json:"code,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+}
+
+// ClusterKV performs a cluster key-value store operation on a target node.
+// The store is replicated, so every node answers for the whole cluster once
+// updates have propagated.
+func ClusterKV(ctx context.Context, host *p2p.Host, peerID string, req KVRequest, logger types.Logger) (*KVResponse, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.KVProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	req.RequestID = logging.NewRequestID()
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("sending kv request", "peer", peerID, "op", req.Op, "namespace", req.Namespace, "key", req.Key)
+
+	var resp KVResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("kv %s failed on node: %w", req.Op, types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// LabelRequest represents a request to set and remove labels of a deployed app
+type LabelRequest struct {
+	AppID     string            `json:"app_id"`
+	Set       map[string]string `json:"set,omitempty"`
+	Remove    []string          `json:"remove,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// LabelResponse represents a label response
+type LabelResponse struct {
+	Success   bool              `json:"success"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Code      string            `json:"code,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// ParseLabelChanges parses label arguments: "key=value" sets a label and
+// "key-" removes it
+func ParseLabelChanges(args []string) (map[string]string, []string, error) {
+	set := make(map[string]string)
+	var remove []string
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			set[key] = value
+			continue
+		}
+		if key, ok := strings.CutSuffix(arg, "-"); ok && key != "" {
+			remove = append(remove, key)
+			continue
+		}
+		return nil, nil, fmt.Errorf("%w: invalid label change %q, want key=value or key-", types.ErrInvalidInput, arg)
+	}
+	return set, remove, nil
+}
+
+// LabelApp sets and removes labels of a deployed app on a target node and
+// returns its labels after the change. With neither, it only returns them.
+func LabelApp(ctx context.Context, host *p2p.Host, peerID string, appID string, set map[string]string, remove []string, logger types.Logger) (map[string]string, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.LabelProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	req := LabelRequest{
+		AppID:     appID,
+		Set:       set,
+		Remove:    remove,
+		RequestID: logging.NewRequestID(),
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting label change", "peer", peerID, "app_id", appID, "set", set, "remove", remove)
+
+	var resp LabelResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("label request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	logger.Info("received app labels", "labels", resp.Labels)
+	return resp.Labels, nil
+}
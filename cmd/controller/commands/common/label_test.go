@@ -0,0 +1,47 @@
+package common_test
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestParseLabelChanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantSet    map[string]string
+		wantRemove []string
+		wantErr    bool
+	}{
+		{"none", nil, map[string]string{}, nil, false},
+		{"set", []string{"env=canary", "gpu="}, map[string]string{"env": "canary", "gpu": ""}, nil, false},
+		{"remove", []string{"owner-"}, map[string]string{}, []string{"owner"}, false},
+		{"value ending in dash", []string{"tier=a-"}, map[string]string{"tier": "a-"}, nil, false},
+		{"both", []string{"env=canary", "owner-"}, map[string]string{"env": "canary"}, []string{"owner"}, false},
+		{"bare key", []string{"env"}, nil, nil, true},
+		{"bare dash", []string{"-"}, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, remove, err := common.ParseLabelChanges(tt.args)
+			if tt.wantErr {
+				if !errors.Is(err, types.ErrInvalidInput) {
+					t.Fatalf("ParseLabelChanges() error = %v, want ErrInvalidInput", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLabelChanges() error = %v", err)
+			}
+			if !maps.Equal(set, tt.wantSet) || !slices.Equal(remove, tt.wantRemove) {
+				t.Errorf("ParseLabelChanges() = %v, %v; want %v, %v", set, remove, tt.wantSet, tt.wantRemove)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// ListAppsRequest is the request of version 3 of the list protocol
+type ListAppsRequest struct {
+	Cursor    string   `json:"cursor,omitempty"`
+	Limit     int      `json:"limit,omitempty"`
+	PageSize  int      `json:"page_size,omitempty"`
+	Fields    []string `json:"fields,omitempty"`
+	Sort      string   `json:"sort,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+
+	types.AppFilter
+}
+
+// ListAppsResponse represents the response for list apps request, or a page
+// of it in version 3 of the protocol
+type ListAppsResponse struct {
+	Success   bool                 `json:"success"`
+	Apps      []*types.Application `json:"apps,omitempty"`
+	Statuses  []*types.AppStatus   `json:"statuses,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	Code      string               `json:"code,omitempty"`
+	RequestID string               `json:"request_id,omitempty"`
+
+	// Records replace Apps and Statuses in version 2 of the protocol
+	Records []*types.AppRecord `json:"records,omitempty"`
+
+	// More and NextCursor page the records in version 3 of the protocol
+	More       bool   `json:"more,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// statuses returns the apps of the response, whichever version of the
+// protocol it is from
+func (r *ListAppsResponse) statuses() []*types.AppStatus {
+	statuses := make([]*types.AppStatus, 0, len(r.Records)+len(r.Apps))
+	for _, record := range r.Records {
+		statuses = append(statuses, record.Status())
+	}
+	if len(r.Statuses) == len(r.Apps) {
+		return append(statuses, r.Statuses...)
+	}
+
+	// Older nodes only report the process state
+	for _, app := range r.Apps {
+		statuses = append(statuses, &types.AppStatus{
+			App: app,
+			AppHealth: types.AppHealth{
+				Healthy: app.Status == types.AppStatusRunning,
+				Message: string(app.Status),
+			},
+		})
+	}
+	return statuses
+}
+
+// ListOptions selects the applications listed from a node, and what of them
+type ListOptions struct {
+	Cursor   string          // List the apps after the one with this ID, in the order listed
+	Limit    int             // Most apps to list, 0 for all
+	PageSize int             // Apps per page the node sends, 0 for its default
+	Fields   []string        // Fields to fetch (types.RecordField*), empty for all
+	Sort     string          // Order of the apps (types.AppOrder*), by ID if empty
+	Filter   types.AppFilter // Apps to list, all if empty
+}
+
+// ListApplications lists applications on a target node
+func ListApplications(ctx context.Context, host *p2p.Host, peerID string, logger types.Logger) ([]*types.Application, error) {
+	statuses, err := ListAppStatuses(ctx, host, peerID, logger)
+	if err != nil {
+		return nil, err
+	}
+	apps := make([]*types.Application, 0, len(statuses))
+	for _, status := range statuses {
+		apps = append(apps, status.App)
+	}
+	return apps, nil
+}
+
+// ListAppStatuses lists applications on a target node along with their
+// health and the metrics they publish
+func ListAppStatuses(ctx context.Context, host *p2p.Host, peerID string, logger types.Logger) ([]*types.AppStatus, error) {
+	var statuses []*types.AppStatus
+	_, err := StreamAppStatuses(ctx, host, peerID, ListOptions{}, func(page []*types.AppStatus) error {
+		statuses = append(statuses, page...)
+		return nil
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// StreamAppStatuses lists the applications on a target node that opts
+// selects, in its order, calling onPage with each page of them as it
+// arrives. It returns the cursor listing the apps the limit left out, if
+// any. Nodes that predate version 3 of the list protocol send every app at
+// once and with every field; the filter, order, cursor and limit are
+// applied here then.
+func StreamAppStatuses(ctx context.Context, host *p2p.Host, peerID string, opts ListOptions, onPage func(page []*types.AppStatus) error, logger types.Logger) (string, error) {
+	// Create stream to target peer. What the local daemon serves is not
+	// known up front, so the newest version is tried there and version 1 is
+	// the fallback.
+	protocolID := consts.Negotiate(consts.CapabilityList, func(id string) bool {
+		return host.IsLocal(peerID) || host.Supports(peerID, id)
+	})
+	stream, err := openStream(ctx, host, peerID, protocolID)
+	if err != nil && protocolID != consts.ListProtocolID {
+		protocolID = consts.ListProtocolID
+		stream, err = openStream(ctx, host, peerID, protocolID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	if protocolID != consts.ListProtocolV3ID {
+		logger.Info("requesting application list", "peer", peerID)
+		resp, err := readListResponse(stream)
+		if err != nil {
+			return "", err
+		}
+		if !resp.Success {
+			return "", withRequestID(fmt.Errorf("list failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), resp.RequestID)
+		}
+		statuses, next, err := pageStatuses(resp.statuses(), opts)
+		if err != nil {
+			return "", err
+		}
+		logger.Info("received application list", "request_id", resp.RequestID, "count", len(statuses))
+		return next, onPage(statuses)
+	}
+
+	// Prepare request
+	req := ListAppsRequest{
+		Cursor:    opts.Cursor,
+		Limit:     opts.Limit,
+		PageSize:  opts.PageSize,
+		Fields:    opts.Fields,
+		Sort:      opts.Sort,
+		RequestID: logging.NewRequestID(),
+		AppFilter: opts.Filter,
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return "", fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting application list", "peer", peerID, "cursor", opts.Cursor, "limit", opts.Limit, "fields", opts.Fields, "sort", opts.Sort)
+
+	count := 0
+	for {
+		resp, err := readListResponse(stream)
+		if err != nil {
+			return "", withRequestID(err, req.RequestID)
+		}
+		if !resp.Success {
+			return "", withRequestID(fmt.Errorf("list failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+		}
+		page := resp.statuses()
+		count += len(page)
+		if err := onPage(page); err != nil {
+			return "", err
+		}
+		if !resp.More {
+			logger.Info("received application list", "count", count)
+			return resp.NextCursor, nil
+		}
+	}
+}
+
+// readListResponse reads one length-prefixed list apps response or page
+func readListResponse(stream types.Stream) (*ListAppsResponse, error) {
+	var resp ListAppsResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &resp, nil
+}
+
+// pageStatuses applies the filter, order, cursor and limit of opts to the
+// apps of a node that does not, returning the cursor of the apps left out
+func pageStatuses(statuses []*types.AppStatus, opts ListOptions) ([]*types.AppStatus, string, error) {
+	if err := opts.Filter.Validate(); err != nil {
+		return nil, "", err
+	}
+	matching := statuses[:0]
+	for _, status := range statuses {
+		if opts.Filter.Matches(status.App) {
+			matching = append(matching, status)
+		}
+	}
+	if err := types.SortAppStatuses(matching, opts.Sort); err != nil {
+		return nil, "", err
+	}
+	return types.PageAppStatuses(matching, opts.Sort, opts.Cursor, opts.Limit)
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// NodeInfoRequest represents a node information request
+type NodeInfoRequest struct {
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// NodeInfoResponse represents a node information response
+type NodeInfoResponse struct {
+	Success   bool            `json:"success"`
+	Node      *types.NodeInfo `json:"node,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Code      string          `json:"code,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// FetchNodeInfo fetches the information of a target node, including the
+// quota usage of every operator
+func FetchNodeInfo(ctx context.Context, host *p2p.Host, peerID string, logger types.Logger) (*types.NodeInfo, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.NodeInfoProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	req := NodeInfoRequest{
+		RequestID: logging.NewRequestID(),
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting node info", "peer", peerID)
+
+	var resp NodeInfoResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("node info request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	if resp.Node == nil {
+		return nil, withRequestID(fmt.Errorf("%w: node info missing from response", types.ErrInvalidState), req.RequestID)
+	}
+
+	logger.Info("received node info", "quotas", len(resp.Node.Quotas))
+	return resp.Node, nil
+}
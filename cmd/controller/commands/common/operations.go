@@ -0,0 +1,281 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// operationsDir is the directory under Storage.PackagesDir holding the
+// operations that failed on some nodes
+const operationsDir = "operations"
+
+// operationFile describes the operation in its directory
+const operationFile = "operation.json"
+
+// MaxOperations is how many operations are kept for retrying; the oldest are
+// removed beyond it
+const MaxOperations = 20
+
+// Operation is a deployment to several nodes that failed on some of them,
+// kept with a copy of its package so the failed nodes can be retried with the
+// same package and parameters
+type Operation struct {
+	// ID names the operation for 'controller retry'
+	ID string `json:"id"`
+
+	// Command is the command that started the operation, e.g. deploy
+	Command string `json:"command"`
+
+	// Package is the kept copy of the package; its signature, if signed, is Package + ".sig"
+	Package string `json:"package"`
+
+	// App and Version are those of the package
+	App     string `json:"app,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	// AutoStart, ForceUnlock, WaitHealthy and HealthTimeout are the DeployOptions used
+	AutoStart     bool          `json:"auto_start"`
+	ForceUnlock   bool          `json:"force_unlock,omitempty"`
+	WaitHealthy   bool          `json:"wait_healthy,omitempty"`
+	HealthTimeout time.Duration `json:"health_timeout,omitempty"`
+
+	// Concurrency, Retries and RetryDelay are the QueueOptions used
+	Concurrency int           `json:"concurrency"`
+	Retries     int           `json:"retries"`
+	RetryDelay  time.Duration `json:"retry_delay,omitempty"`
+
+	// CreatedAt is when the operation ran first, UpdatedAt when it was last retried
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Nodes are the latest results on every node of the operation
+	Nodes []NodeReport `json:"nodes"`
+}
+
+// DeployOptions returns the options the operation deployed with
+func (o *Operation) DeployOptions() DeployOptions {
+	return DeployOptions{
+		AutoStart:     o.AutoStart,
+		ForceUnlock:   o.ForceUnlock,
+		WaitHealthy:   o.WaitHealthy,
+		HealthTimeout: o.HealthTimeout,
+	}
+}
+
+// QueueOptions returns the options the operation ran on the nodes with
+func (o *Operation) QueueOptions() QueueOptions {
+	return QueueOptions{Concurrency: o.Concurrency, Retries: o.Retries, RetryDelay: o.RetryDelay}
+}
+
+// Failed returns the nodes the operation last failed on
+func (o *Operation) Failed() []string {
+	var nodes []string
+	for _, node := range o.Nodes {
+		if !node.Success {
+			nodes = append(nodes, node.NodeID)
+		}
+	}
+	return nodes
+}
+
+// Update replaces the results of the nodes retried by those in report
+func (o *Operation) Update(report *DeployReport) {
+	for _, retried := range report.Nodes {
+		for i := range o.Nodes {
+			if o.Nodes[i].NodeID == retried.NodeID {
+				o.Nodes[i] = retried
+			}
+		}
+	}
+	o.UpdatedAt = time.Now()
+}
+
+// RecordOperation keeps a deployment that failed on some nodes for 'controller
+// retry': a copy of the package at pkgPath and its signature, the options and
+// the results in report. The ID of the operation is recorded in the report.
+// Nothing is kept when no node failed.
+func RecordOperation(report *DeployReport, pkgPath string, manifest *types.Manifest, opts DeployOptions, queue QueueOptions) (*Operation, error) {
+	op := &Operation{
+		ID:            logging.NewRequestID(),
+		Command:       report.Command,
+		App:           manifest.Name,
+		Version:       manifest.Version,
+		AutoStart:     opts.AutoStart,
+		ForceUnlock:   opts.ForceUnlock,
+		WaitHealthy:   opts.WaitHealthy,
+		HealthTimeout: opts.HealthTimeout,
+		Concurrency:   queue.Concurrency,
+		Retries:       queue.Retries,
+		RetryDelay:    queue.RetryDelay,
+		CreatedAt:     report.StartedAt,
+		UpdatedAt:     time.Now(),
+		Nodes:         report.Nodes,
+	}
+	if len(op.Failed()) == 0 {
+		return nil, nil
+	}
+
+	base, err := operationsBase()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(base, op.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, types.WrapError(err, "failed to create operation directory")
+	}
+	op.Package = filepath.Join(dir, filepath.Base(pkgPath))
+	if err := linkOrCopy(pkgPath, op.Package); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	if _, err := os.Stat(pkgPath + ".sig"); err == nil {
+		if err := linkOrCopy(pkgPath+".sig", op.Package+".sig"); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	if err := SaveOperation(op); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	report.Operation = op.ID
+
+	pruneOperations(base)
+	return op, nil
+}
+
+// LoadOperation loads the operation with the given ID
+func LoadOperation(id string) (*Operation, error) {
+	base, err := operationsBase()
+	if err != nil {
+		return nil, err
+	}
+	if id == "" || id != filepath.Base(id) || id == "." || id == ".." {
+		return nil, fmt.Errorf("%w: invalid operation ID %q", types.ErrInvalidInput, id)
+	}
+	data, err := os.ReadFile(filepath.Join(base, id, operationFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: no operation %s (see 'controller retry' for the operations kept)", types.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, types.WrapError(err, "failed to read operation")
+	}
+	var op Operation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return nil, fmt.Errorf("%w: operation %s: %w", types.ErrInvalidInput, id, err)
+	}
+	return &op, nil
+}
+
+// SaveOperation writes op, or removes it with its package once it failed on no node
+func SaveOperation(op *Operation) error {
+	base, err := operationsBase()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(base, op.ID)
+	if len(op.Failed()) == 0 {
+		if err := os.RemoveAll(dir); err != nil {
+			return types.WrapError(err, "failed to remove operation")
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(op, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation: %w", err)
+	}
+	tmp := filepath.Join(dir, operationFile+".tmp")
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return types.WrapError(err, "failed to write operation")
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, operationFile)); err != nil {
+		return types.WrapError(err, "failed to write operation")
+	}
+	return nil
+}
+
+// ListOperations returns the operations kept for retrying, most recent first
+func ListOperations() ([]*Operation, error) {
+	base, err := operationsBase()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, types.WrapError(err, "failed to read operations directory")
+	}
+
+	var ops []*Operation
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		op, err := LoadOperation(entry.Name())
+		if err != nil {
+			GlobalLogger.Warn("skipping unreadable operation", "id", entry.Name(), "error", err)
+			continue
+		}
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].UpdatedAt.After(ops[j].UpdatedAt) })
+	return ops, nil
+}
+
+// pruneOperations removes the least recently updated operations beyond MaxOperations
+func pruneOperations(base string) {
+	ops, err := ListOperations()
+	if err != nil {
+		return
+	}
+	for _, op := range ops[min(len(ops), MaxOperations):] {
+		if err := os.RemoveAll(filepath.Join(base, op.ID)); err != nil {
+			GlobalLogger.Warn("failed to remove operation", "id", op.ID, "error", err)
+		}
+	}
+}
+
+// operationsBase returns the directory holding the operations
+func operationsBase() (string, error) {
+	dir, err := packagesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, operationsDir), nil
+}
+
+// linkOrCopy makes dst a hard link to src, or a copy where linking fails,
+// e.g. across filesystems
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return types.WrapError(err, "failed to open package")
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return types.WrapError(err, "failed to keep package")
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return types.WrapError(err, "failed to keep package")
+	}
+	if err := out.Close(); err != nil {
+		return types.WrapError(err, "failed to keep package")
+	}
+	return nil
+}
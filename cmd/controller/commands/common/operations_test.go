@@ -0,0 +1,73 @@
+package common_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestRecordOperation(t *testing.T) {
+	useCache(t, 0)
+	pkgPath := filepath.Join(t.TempDir(), "web-1.0.0.tar.gz")
+	if err := os.WriteFile(pkgPath, []byte("package"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := &types.Manifest{Name: "web", Version: "1.0.0"}
+	opts := common.DeployOptions{AutoStart: true, WaitHealthy: true}
+	queue := common.QueueOptions{Concurrency: 2, Retries: 1}
+
+	// Nothing is kept when every node succeeded
+	report := common.NewDeployReport("deploy")
+	report.AddResult(common.NodeResult{NodeID: "a", Value: "web-1.0.0"}, opts)
+	if op, err := common.RecordOperation(report, pkgPath, manifest, opts, queue); op != nil || err != nil {
+		t.Fatalf("RecordOperation() = %+v, %v; want nothing kept", op, err)
+	}
+
+	report.AddResult(common.NodeResult{NodeID: "b", Err: errors.New("no addresses")}, opts)
+	op, err := common.RecordOperation(report, pkgPath, manifest, opts, queue)
+	if err != nil || op == nil {
+		t.Fatalf("RecordOperation() = %+v, %v", op, err)
+	}
+	if report.Operation != op.ID {
+		t.Errorf("report records operation %q, want %q", report.Operation, op.ID)
+	}
+
+	loaded, err := common.LoadOperation(op.ID)
+	if err != nil {
+		t.Fatalf("LoadOperation() error = %v", err)
+	}
+	if failed := loaded.Failed(); len(failed) != 1 || failed[0] != "b" {
+		t.Errorf("Failed() = %v, want [b]", failed)
+	}
+	if loaded.DeployOptions() != opts || loaded.QueueOptions() != queue {
+		t.Errorf("loaded options %+v and %+v, want %+v and %+v", loaded.DeployOptions(), loaded.QueueOptions(), opts, queue)
+	}
+	if data, err := os.ReadFile(loaded.Package); err != nil || string(data) != "package" {
+		t.Errorf("kept package = %q, %v", data, err)
+	}
+
+	// Once the failed node succeeds, the operation is removed
+	retry := common.NewDeployReport("retry")
+	retry.AddResult(common.NodeResult{NodeID: "b", Value: "web-1.0.0"}, opts)
+	loaded.Update(retry)
+	if err := common.SaveOperation(loaded); err != nil {
+		t.Fatalf("SaveOperation() error = %v", err)
+	}
+	if _, err := common.LoadOperation(op.ID); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("LoadOperation() of a completed operation error = %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(loaded.Package); !os.IsNotExist(err) {
+		t.Errorf("package of a completed operation kept: %v", err)
+	}
+}
+
+func TestLoadOperationRejectsPaths(t *testing.T) {
+	useCache(t, 0)
+	if _, err := common.LoadOperation("../cache"); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("LoadOperation() error = %v, want ErrInvalidInput", err)
+	}
+}
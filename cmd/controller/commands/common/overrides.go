@@ -0,0 +1,195 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// Overrides patch the manifest of an app before it is packed, so quick
+// experiments need no edits to manifest.yaml
+type Overrides struct {
+	// Set are key=value pairs with dotted manifest keys, e.g. env.FOO=bar or
+	// resources.memory_mb=256
+	Set []string
+
+	// Args replace the arguments of the manifest
+	Args []string
+}
+
+// AddOverrideFlags registers --set and --set-args
+func AddOverrideFlags(flags *pflag.FlagSet, o *Overrides) {
+	flags.StringArrayVar(&o.Set, "set", nil, "override a manifest value, e.g. env.FOO=bar or resources.memory_mb=256 (repeatable)")
+	flags.StringArrayVar(&o.Args, "set-args", nil, "replace the manifest args, one flag per argument (repeatable)")
+}
+
+// IsEmpty reports whether nothing is overridden
+func (o *Overrides) IsEmpty() bool {
+	return len(o.Set) == 0 && len(o.Args) == 0
+}
+
+// Stage extracts the app directory or package at path into dir and applies
+// the overrides to its manifest. It returns the patched manifest.
+func (o *Overrides) Stage(ctx context.Context, path string, dir string) (*types.Manifest, error) {
+	pkgMgr := pkgmanager.New()
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", path, err)
+	}
+
+	// An app directory is packed first, so exactly what would be packed is staged
+	pkgPath := path
+	if info.IsDir() {
+		tmpDir, err := os.MkdirTemp(filepath.Dir(dir), "src-")
+		if err != nil {
+			return nil, types.WrapError(err, "failed to create staging directory")
+		}
+		defer func() { _ = os.RemoveAll(tmpDir) }()
+		if pkgPath, err = pkgMgr.PackTo(ctx, path, tmpDir); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := pkgMgr.Unpack(ctx, pkgPath, dir); err != nil {
+		return nil, err
+	}
+
+	manifestPath := filepath.Join(dir, "manifest.yaml")
+	if err := o.Apply(manifestPath); err != nil {
+		return nil, err
+	}
+	return pkgMgr.ReadManifest(manifestPath)
+}
+
+// Apply patches the manifest file at path, keeping its comments
+func (o *Overrides) Apply(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return types.WrapError(err, "failed to read manifest")
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse manifest: %w", types.ErrInvalidManifest)
+	}
+	root := doc.Content[0]
+
+	for _, set := range o.Set {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("%w: invalid --set %q, want key=value", types.ErrInvalidInput, set)
+		}
+		node, err := overrideValue(key, value)
+		if err != nil {
+			return err
+		}
+		if err := setPath(root, strings.Split(key, "."), node); err != nil {
+			return fmt.Errorf("%w: --set %s: %v", types.ErrInvalidInput, key, err)
+		}
+	}
+	if len(o.Args) > 0 {
+		args := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, arg := range o.Args {
+			args.Content = append(args.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: arg})
+		}
+		if err := setPath(root, []string{"args"}, args); err != nil {
+			return err
+		}
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return types.WrapError(err, "failed to write manifest")
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// overrideValue parses the value of a --set. Values starting with [ or { are
+// YAML sequences or mappings; others are scalars, typed as YAML types them, so
+// resources.memory_mb=256 sets a number. The key must name a manifest field
+// the value fits.
+func overrideValue(key string, value string) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(value), &doc); err != nil || len(doc.Content) == 0 {
+			return nil, fmt.Errorf("%w: --set %s: invalid value %q", types.ErrInvalidInput, key, value)
+		}
+		node = doc.Content[0]
+	}
+
+	// Decode the override alone, strictly, to catch unknown keys and values
+	// of the wrong type
+	check := &yaml.Node{Kind: yaml.MappingNode}
+	if err := setPath(check, strings.Split(key, "."), node); err != nil {
+		return nil, fmt.Errorf("%w: --set %s: %v", types.ErrInvalidInput, key, err)
+	}
+	data, err := yaml.Marshal(check)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to encode override")
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&types.Manifest{}); err != nil {
+		return nil, fmt.Errorf("%w: --set %s: %s", types.ErrInvalidInput, key, decodeError(err))
+	}
+	return node, nil
+}
+
+// decodeError describes an error decoding an override on one line, without
+// the line numbers of the document decoded
+func decodeError(err error) string {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err.Error()
+	}
+	msgs := make([]string, 0, len(typeErr.Errors))
+	for _, msg := range typeErr.Errors {
+		if _, rest, ok := strings.Cut(msg, ": "); ok && strings.HasPrefix(msg, "line ") {
+			msg = rest
+		}
+		msgs = append(msgs, msg)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// setPath sets the value at the dotted path below the mapping node, creating
+// the mappings along it
+func setPath(mapping *yaml.Node, path []string, value *yaml.Node) error {
+	for i, key := range path {
+		if key == "" {
+			return fmt.Errorf("empty key")
+		}
+		var next *yaml.Node
+		for j := 0; j+1 < len(mapping.Content); j += 2 {
+			if mapping.Content[j].Value == key {
+				next = mapping.Content[j+1]
+				if i == len(path)-1 {
+					mapping.Content[j+1] = value
+					return nil
+				}
+				break
+			}
+		}
+		if i == len(path)-1 {
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+			return nil
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, next)
+		}
+		if next.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", strings.Join(path[:i+1], "."))
+		}
+		mapping = next
+	}
+	return nil
+}
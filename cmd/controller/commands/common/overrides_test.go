@@ -0,0 +1,88 @@
+package common_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+const overrideManifest = `# the app
+name: web
+version: "1.0.0"
+entrypoint: run.sh
+args: ["--old"]
+env:
+  KEEP: "yes"
+`
+
+// writeOverrideApp writes an app directory to override
+func writeOverrideApp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte(overrideManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestOverridesStage(t *testing.T) {
+	overrides := common.Overrides{
+		Set: []string{
+			"env.FOO=bar",
+			"env.PORT=8080",
+			"resources.memory_mb=256",
+			"labels={team: a}",
+		},
+		Args: []string{"--listen", ":8080"},
+	}
+	dst := filepath.Join(t.TempDir(), "app")
+	manifest, err := overrides.Stage(context.Background(), writeOverrideApp(t), dst)
+	if err != nil {
+		t.Fatalf("Stage() error = %v", err)
+	}
+
+	if manifest.Env["FOO"] != "bar" || manifest.Env["PORT"] != "8080" || manifest.Env["KEEP"] != "yes" {
+		t.Errorf("Env = %v, want FOO, PORT and KEEP", manifest.Env)
+	}
+	if manifest.Resources == nil || manifest.Resources.MemoryMB != 256 {
+		t.Errorf("Resources = %+v, want memory_mb 256", manifest.Resources)
+	}
+	if manifest.Labels["team"] != "a" {
+		t.Errorf("Labels = %v, want team=a", manifest.Labels)
+	}
+	if !slices.Equal(manifest.Args, []string{"--listen", ":8080"}) {
+		t.Errorf("Args = %v, want the --set-args", manifest.Args)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "run.sh")); err != nil {
+		t.Errorf("app files were not staged: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "manifest.yaml"))
+	if err != nil || !strings.Contains(string(data), "# the app") {
+		t.Errorf("patched manifest lost its comments:\n%s", data)
+	}
+}
+
+func TestOverridesInvalid(t *testing.T) {
+	for _, set := range []string{
+		"resorces.memory_mb=256",
+		"resources.memory_mb=lots",
+		"name.first=web",
+		"novalue",
+	} {
+		overrides := common.Overrides{Set: []string{set}}
+		dst := filepath.Join(t.TempDir(), "app")
+		if _, err := overrides.Stage(context.Background(), writeOverrideApp(t), dst); !errors.Is(err, types.ErrInvalidInput) {
+			t.Errorf("Stage() with --set %s error = %v, want ErrInvalidInput", set, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/ownership"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// OwnershipRequest represents an app ownership inspection request
+type OwnershipRequest struct {
+	App       string `json:"app,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// OwnershipResponse represents an app ownership inspection response
+type OwnershipResponse struct {
+	Success   bool                `json:"success"`
+	Enabled   bool                `json:"enabled"`
+	Records   []*ownership.Record `json:"records,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	Code      string              `json:"code,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+}
+
+// FetchOwnership fetches app ownership records from a target node.
+// An empty app returns the records for all apps.
+func FetchOwnership(ctx context.Context, host *p2p.Host, peerID string, app string, logger types.Logger) (*OwnershipResponse, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.OwnershipProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	req := OwnershipRequest{
+		App:       app,
+		RequestID: logging.NewRequestID(),
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting app ownership", "peer", peerID, "app", app)
+
+	var resp OwnershipResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("ownership request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	logger.Info("received app ownership", "count", len(resp.Records))
+	return &resp, nil
+}
@@ -0,0 +1,335 @@
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/filelock"
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// packageCacheDir is the directory under Storage.PackagesDir holding cached packages
+const packageCacheDir = "cache"
+
+// defaultPackageCacheMB is the package cache size limit unless configured
+const defaultPackageCacheMB = 512
+
+// cacheEntryFile describes the package in a cache entry
+const cacheEntryFile = "entry.json"
+
+// cacheLockFile is the file under Storage.PackagesDir locking the package cache
+const cacheLockFile = "cache.lock"
+
+// cacheLockTimeout bounds how long to wait for another controller using the cache
+const cacheLockTimeout = 30 * time.Second
+
+// CachedPackage is a package in the package cache
+type CachedPackage struct {
+	// Key identifies the sources and signing key the package was built from
+	Key string `json:"key"`
+
+	// Path is the package file; its signature, if signed, is Path + ".sig"
+	Path string `json:"path"`
+
+	// AppDir is the app directory the package was built from
+	AppDir string `json:"app_dir"`
+
+	// Signer is the hex public key the package is signed with, if signed
+	Signer string `json:"signer,omitempty"`
+
+	// Size is the size of the package and its signature
+	Size int64 `json:"size"`
+
+	// LastUsed is when the package was last built or reused
+	LastUsed time.Time `json:"last_used"`
+}
+
+// cacheEntry is what a cache entry records about its package
+type cacheEntry struct {
+	AppDir string `json:"app_dir"`
+	Signer string `json:"signer,omitempty"`
+}
+
+// PackageCache keeps the packages built from app directories, so that
+// deploying unchanged sources again skips packing and signing. Packages are
+// keyed by a hash of the sources and the signing key, and the least recently
+// used ones are evicted beyond the configured size. Controllers running at
+// the same time take turns changing the cache through a file lock.
+type PackageCache struct {
+	dir      string
+	lockPath string
+	maxBytes int64
+}
+
+// OpenPackageCache returns the package cache under Storage.PackagesDir
+func OpenPackageCache() (*PackageCache, error) {
+	dir, err := packagesDir()
+	if err != nil {
+		return nil, err
+	}
+	maxMB := GlobalConfig.PackageCache.MaxSizeMB
+	if maxMB <= 0 {
+		maxMB = defaultPackageCacheMB
+	}
+	return &PackageCache{
+		dir:      filepath.Join(dir, packageCacheDir),
+		lockPath: filepath.Join(dir, cacheLockFile),
+		maxBytes: int64(maxMB) << 20,
+	}, nil
+}
+
+// Build returns the package of appDir, signed with signer unless it is nil.
+// A package built before from the same sources with the same key is reused,
+// which hit reports; otherwise the package is built and added to the cache.
+func (c *PackageCache) Build(ctx context.Context, appDir string, signer *security.Signer) (pkg *CachedPackage, hit bool, err error) {
+	sourceHash, err := pkgmanager.New().SourceHash(appDir)
+	if err != nil {
+		return nil, false, err
+	}
+	var publicKey string
+	if signer != nil {
+		publicKey = hex.EncodeToString(signer.PublicKey())
+	}
+	key := cacheKey(sourceHash, publicKey)
+
+	lock, err := c.lock(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if pkg, err := c.entry(key); err == nil {
+		now := time.Now()
+		_ = os.Chtimes(filepath.Join(c.dir, key), now, now)
+		pkg.LastUsed = now
+		_ = lock.Unlock()
+		return pkg, true, nil
+	}
+	_ = lock.Unlock()
+
+	// Build in a workspace without holding the lock, then move it into the
+	// cache at once, so that the cache never holds a partially written package
+	ws, err := NewWorkspace("cache")
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = ws.Remove() }()
+
+	if _, err := BuildPackage(ctx, appDir, ws.Dir, signer); err != nil {
+		return nil, false, err
+	}
+
+	absDir, err := filepath.Abs(appDir)
+	if err != nil {
+		absDir = appDir
+	}
+	data, err := json.MarshalIndent(cacheEntry{AppDir: absDir, Signer: publicKey}, "", "  ")
+	if err != nil {
+		return nil, false, types.WrapError(err, "failed to marshal cache entry")
+	}
+	if err := os.WriteFile(filepath.Join(ws.Dir, cacheEntryFile), data, 0644); err != nil {
+		return nil, false, types.WrapError(err, "failed to write cache entry")
+	}
+
+	lock, err = c.lock(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, false, types.WrapError(err, "failed to create package cache")
+	}
+	if err := os.Rename(ws.Dir, filepath.Join(c.dir, key)); err != nil {
+		// Another controller may have cached the same package meanwhile
+		if _, statErr := os.Stat(filepath.Join(c.dir, key)); statErr != nil {
+			return nil, false, types.WrapError(err, "failed to add package to cache")
+		}
+	}
+
+	pkg, err = c.entry(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := c.prune(false, key); err != nil {
+		GlobalLogger.Warn("failed to evict cached packages", "error", err)
+	}
+	return pkg, false, nil
+}
+
+// BuildPackage packs appDir into outDir and, unless signer is nil, saves its
+// signature next to it, and returns the package path
+func BuildPackage(ctx context.Context, appDir, outDir string, signer *security.Signer) (string, error) {
+	pkgPath, err := pkgmanager.New().PackTo(ctx, appDir, outDir)
+	if err != nil {
+		return "", err
+	}
+	if signer != nil {
+		signature, err := signer.SignFile(pkgPath)
+		if err != nil {
+			return "", types.WrapError(err, "failed to sign package")
+		}
+		if err := os.WriteFile(pkgPath+".sig", signature, 0644); err != nil {
+			return "", types.WrapError(err, "failed to save signature")
+		}
+	}
+	return pkgPath, nil
+}
+
+// List returns the cached packages, most recently used first
+func (c *PackageCache) List() ([]*CachedPackage, error) {
+	keys, err := c.keys()
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]*CachedPackage, 0, len(keys))
+	for _, key := range keys {
+		if pkg, err := c.entry(key); err == nil {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].LastUsed.After(pkgs[j].LastUsed) })
+	return pkgs, nil
+}
+
+// Prune removes incomplete entries and the least recently used packages
+// beyond the size limit, or every package if all is set, and returns the
+// removed entries
+func (c *PackageCache) Prune(ctx context.Context, all bool) ([]string, error) {
+	lock, err := c.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	return c.prune(all, "")
+}
+
+// lock takes the cache lock, waiting up to cacheLockTimeout for another
+// controller to release it
+func (c *PackageCache) lock(ctx context.Context) (*filelock.Lock, error) {
+	ctx, cancel := context.WithTimeout(ctx, cacheLockTimeout)
+	defer cancel()
+
+	lock, err := filelock.Acquire(ctx, c.lockPath)
+	if err != nil {
+		return nil, fmt.Errorf("package cache is in use: %w", err)
+	}
+	return lock, nil
+}
+
+// prune implements Prune, never evicting the entry keep. Callers must hold the lock.
+func (c *PackageCache) prune(all bool, keep string) ([]string, error) {
+	keys, err := c.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	var remove []string
+	var pkgs []*CachedPackage
+	for _, key := range keys {
+		pkg, err := c.entry(key)
+		switch {
+		case err != nil || all:
+			remove = append(remove, key)
+		default:
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].LastUsed.After(pkgs[j].LastUsed) })
+	var total int64
+	for _, pkg := range pkgs {
+		total += pkg.Size
+		if total > c.maxBytes && pkg.Key != keep {
+			remove = append(remove, pkg.Key)
+			total -= pkg.Size
+		}
+	}
+
+	var removed []string
+	for _, key := range remove {
+		path := filepath.Join(c.dir, key)
+		if err := os.RemoveAll(path); err != nil {
+			return removed, types.WrapError(err, "failed to remove cached package")
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// keys returns the keys of the cache entries
+func (c *PackageCache) keys() ([]string, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, types.WrapError(err, "failed to read package cache")
+	}
+	var keys []string
+	for _, entry := range entries {
+		keys = append(keys, entry.Name())
+	}
+	return keys, nil
+}
+
+// entry reads the cache entry key, failing unless it is complete
+func (c *PackageCache) entry(key string) (*CachedPackage, error) {
+	dir := filepath.Join(c.dir, key)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, cacheEntryFile))
+	if err != nil {
+		return nil, err
+	}
+	var meta cacheEntry
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, types.WrapError(err, "failed to parse cache entry")
+	}
+
+	pkg := &CachedPackage{Key: key, AppDir: meta.AppDir, Signer: meta.Signer, LastUsed: info.ModTime()}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		fileInfo, err := file.Info()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.HasSuffix(file.Name(), ".tar.gz"):
+			pkg.Path = filepath.Join(dir, file.Name())
+			pkg.Size += fileInfo.Size()
+		case strings.HasSuffix(file.Name(), ".sig"):
+			pkg.Size += fileInfo.Size()
+		}
+	}
+	if pkg.Path == "" {
+		return nil, types.ErrNotFound
+	}
+	if pkg.Signer != "" {
+		if _, err := os.Stat(pkg.Path + ".sig"); err != nil {
+			return nil, err
+		}
+	}
+	return pkg, nil
+}
+
+// cacheKey returns the cache key of sources signed with publicKey
+func cacheKey(sourceHash, publicKey string) string {
+	sum := sha256.Sum256([]byte(sourceHash + "\x00" + publicKey))
+	return hex.EncodeToString(sum[:16])
+}
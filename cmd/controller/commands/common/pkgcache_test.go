@@ -0,0 +1,133 @@
+package common_test
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+)
+
+// useCache points the controller config at a fresh packages directory
+func useCache(t *testing.T, maxSizeMB int) *common.PackageCache {
+	t.Helper()
+	saved := common.GlobalConfig
+	common.GlobalConfig = &config.ControllerConfig{
+		Storage:      config.StorageConfig{PackagesDir: t.TempDir()},
+		PackageCache: config.PackageCacheConfig{MaxSizeMB: maxSizeMB},
+	}
+	t.Cleanup(func() { common.GlobalConfig = saved })
+
+	cache, err := common.OpenPackageCache()
+	if err != nil {
+		t.Fatalf("OpenPackageCache() error = %v", err)
+	}
+	return cache
+}
+
+// writeApp creates an app directory with a main file of the given content
+func writeApp(t *testing.T, name string, main []byte) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := "name: " + name + "\nversion: 1.0.0\nentrypoint: main\n"
+	if err := os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main"), main, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPackageCacheReuse(t *testing.T) {
+	cache := useCache(t, 0)
+	appDir := writeApp(t, "web", []byte("v1"))
+
+	first, hit, err := cache.Build(t.Context(), appDir, nil)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if hit {
+		t.Errorf("first Build() reported a cache hit")
+	}
+
+	second, hit, err := cache.Build(t.Context(), appDir, nil)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if !hit || second.Path != first.Path {
+		t.Errorf("unchanged sources: hit = %v, path = %s, want reuse of %s", hit, second.Path, first.Path)
+	}
+
+	// A different signing key is a different package
+	signer, err := security.NewSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, hit, err := cache.Build(t.Context(), appDir, signer)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if hit || signed.Key == first.Key {
+		t.Errorf("signed build reused the unsigned package")
+	}
+	if _, err := os.Stat(signed.Path + ".sig"); err != nil {
+		t.Errorf("signature missing: %v", err)
+	}
+
+	// Changed sources are packed again
+	if err := os.WriteFile(filepath.Join(appDir, "main"), []byte("v2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit, err := cache.Build(t.Context(), appDir, nil); err != nil || hit {
+		t.Errorf("changed sources: hit = %v, error = %v, want a new build", hit, err)
+	}
+
+	pkgs, err := cache.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(pkgs) != 3 {
+		t.Errorf("List() = %d packages, want 3", len(pkgs))
+	}
+
+	removed, err := cache.Prune(t.Context(), true)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(removed) != 3 {
+		t.Errorf("Prune(all) removed %d packages, want 3", len(removed))
+	}
+}
+
+func TestPackageCacheEviction(t *testing.T) {
+	cache := useCache(t, 1)
+
+	// Random content does not compress, so each package takes about 700 KiB
+	content := make([]byte, 700<<10)
+	var keys []string
+	for _, name := range []string{"first", "second"} {
+		if _, err := rand.Read(content); err != nil {
+			t.Fatal(err)
+		}
+		pkg, _, err := cache.Build(t.Context(), writeApp(t, name, content), nil)
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		keys = append(keys, pkg.Key)
+	}
+
+	pkgs, err := cache.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Key != keys[1] {
+		t.Errorf("List() = %v, want only the most recent package", pkgs)
+	}
+}
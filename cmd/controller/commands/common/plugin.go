@@ -0,0 +1,174 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PluginPrefix is prepended to a command name to find the plugin binary implementing it
+const PluginPrefix = "controller-"
+
+// Environment variables carrying controller context to plugins
+const (
+	// PluginEnvConfig is the config file given with --config (empty for the default)
+	PluginEnvConfig = "P2P_CONTROLLER_CONFIG"
+
+	// PluginEnvNode is the node selected with --node, if any
+	PluginEnvNode = "P2P_CONTROLLER_NODE"
+
+	// PluginEnvOutput is the output format: text or json
+	PluginEnvOutput = "P2P_CONTROLLER_OUTPUT"
+
+	// PluginEnvBinary is the path of the controller binary, for plugins calling back into it
+	PluginEnvBinary = "P2P_CONTROLLER_BIN"
+)
+
+// Plugin is an external binary invoked as a controller subcommand
+type Plugin struct {
+	// Name is the command name, e.g. "foo" or "foo-bar" for "controller foo bar"
+	Name string
+
+	// Path is the binary's location
+	Path string
+
+	// Args are the arguments following the command name
+	Args []string
+
+	// ConfigFile, Node and Output are the global flags given before the command name
+	ConfigFile string
+	Node       string
+	Output     string
+}
+
+// FindPlugin resolves args (without the program name) to a plugin in PATH.
+// Global flags (--config, --output, --node) may precede the command name and are
+// passed to the plugin through its environment. The longest name wins, so
+// "controller foo bar" runs controller-foo-bar if present and controller-foo
+// otherwise. Commands for which builtin returns true are never plugins.
+func FindPlugin(args []string, builtin func(name string) bool) (*Plugin, bool) {
+	p := &Plugin{Output: "text"}
+
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+
+		var target *string
+		switch name {
+		case "-c", "--config":
+			target = &p.ConfigFile
+		case "--output":
+			target = &p.Output
+		case "--node":
+			target = &p.Node
+		default:
+			// Anything else (--help, --version, ...) is for the controller itself
+			return nil, false
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, false
+			}
+			i++
+			value = args[i]
+		}
+		*target = value
+	}
+
+	if i >= len(args) || builtin(args[i]) {
+		return nil, false
+	}
+
+	var words []string
+	for _, arg := range args[i:] {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		words = append(words, arg)
+	}
+
+	for n := len(words); n > 0; n-- {
+		name := strings.Join(words[:n], "-")
+		path, err := exec.LookPath(PluginPrefix + name)
+		if err != nil {
+			continue
+		}
+		p.Name = name
+		p.Path = path
+		p.Args = args[i+n:]
+		return p, true
+	}
+
+	return nil, false
+}
+
+// Environ returns the environment the plugin runs with
+func (p *Plugin) Environ() []string {
+	env := append(os.Environ(),
+		PluginEnvConfig+"="+p.ConfigFile,
+		PluginEnvNode+"="+p.Node,
+		PluginEnvOutput+"="+p.Output,
+	)
+	if self, err := os.Executable(); err == nil {
+		env = append(env, PluginEnvBinary+"="+self)
+	}
+	return env
+}
+
+// Run executes the plugin with the controller's standard streams.
+// A non-zero plugin exit status is returned as ExitStatus.
+func (p *Plugin) Run() error {
+	cmd := exec.Command(p.Path, p.Args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = p.Environ()
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return ExitStatus(exitErr.ExitCode())
+	}
+	return err
+}
+
+// ListPlugins returns the plugins found in PATH, keyed by command name.
+// When several directories provide the same plugin, the first one in PATH wins.
+func ListPlugins() map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			file := entry.Name()
+			if entry.IsDir() || !strings.HasPrefix(file, PluginPrefix) {
+				continue
+			}
+			path := filepath.Join(dir, file)
+			if _, err := exec.LookPath(path); err != nil {
+				continue
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(file, PluginPrefix), ".exe")
+			if _, ok := plugins[name]; !ok && name != "" {
+				plugins[name] = path
+			}
+		}
+	}
+	return plugins
+}
+
+// PluginNames returns the names of plugins in sorted order
+func PluginNames(plugins map[string]string) []string {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,85 @@
+package common_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+)
+
+// installPlugins creates executable plugin stubs in a directory that becomes PATH
+func installPlugins(t *testing.T, names ...string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin stubs are shell scripts")
+	}
+
+	dir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(dir, common.PluginPrefix+name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	return dir
+}
+
+func TestFindPlugin(t *testing.T) {
+	dir := installPlugins(t, "foo", "foo-bar", "deploy")
+	builtin := func(name string) bool { return name == "deploy" }
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantOK   bool
+		wantName string
+		wantArgs []string
+		wantNode string
+		wantOut  string
+		wantCfg  string
+	}{
+		{"simple", []string{"foo", "x"}, true, "foo", []string{"x"}, "", "text", ""},
+		{"longest name", []string{"foo", "bar", "--flag"}, true, "foo-bar", []string{"--flag"}, "", "text", ""},
+		{"flags stop name", []string{"foo", "--flag", "bar"}, true, "foo", []string{"--flag", "bar"}, "", "text", ""},
+		{"global flags", []string{"-c", "c.yaml", "--node=peer", "--output", "json", "foo"}, true, "foo", []string{}, "peer", "json", "c.yaml"},
+		{"builtin wins", []string{"deploy", "pkg.tar.gz"}, false, "", nil, "", "", ""},
+		{"unknown", []string{"baz"}, false, "", nil, "", "", ""},
+		{"controller flag", []string{"--help"}, false, "", nil, "", "", ""},
+		{"no command", []string{"--node", "peer"}, false, "", nil, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := common.FindPlugin(tt.args, builtin)
+			if ok != tt.wantOK {
+				t.Fatalf("FindPlugin() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if p.Name != tt.wantName || p.Path != filepath.Join(dir, common.PluginPrefix+tt.wantName) {
+				t.Errorf("FindPlugin() = %s at %s, want %s", p.Name, p.Path, tt.wantName)
+			}
+			if !reflect.DeepEqual(p.Args, tt.wantArgs) {
+				t.Errorf("Args = %q, want %q", p.Args, tt.wantArgs)
+			}
+			if p.Node != tt.wantNode || p.Output != tt.wantOut || p.ConfigFile != tt.wantCfg {
+				t.Errorf("context = (%q, %q, %q), want (%q, %q, %q)",
+					p.ConfigFile, p.Node, p.Output, tt.wantCfg, tt.wantNode, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestListPlugins(t *testing.T) {
+	installPlugins(t, "foo", "bar")
+
+	plugins := common.ListPlugins()
+	if got := common.PluginNames(plugins); !reflect.DeepEqual(got, []string{"bar", "foo"}) {
+		t.Errorf("PluginNames() = %v, want [bar foo]", got)
+	}
+}
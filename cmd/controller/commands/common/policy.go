@@ -0,0 +1,44 @@
+package common
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/policy"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// PolicyViolations evaluates the controller's policy rules as a pre-flight check.
+// Daemons enforce their own rules; this only catches violations before any transfer.
+func PolicyViolations(manifest *types.Manifest, signed bool, signer *policy.Signer) []policy.Violation {
+	if GlobalConfig == nil || policy.IsEmpty(&GlobalConfig.Policy) {
+		return nil
+	}
+	return policy.Evaluate(&GlobalConfig.Policy, &policy.Input{
+		Manifest: manifest,
+		Signed:   signed,
+		Signer:   signer,
+	})
+}
+
+// CheckPolicy runs the pre-flight policy check and returns an error wrapping types.ErrPolicyViolation on failure
+func CheckPolicy(manifest *types.Manifest, signed bool, signer *policy.Signer) error {
+	if GlobalConfig == nil || policy.IsEmpty(&GlobalConfig.Policy) {
+		return nil
+	}
+	return policy.Check(&GlobalConfig.Policy, &policy.Input{
+		Manifest: manifest,
+		Signed:   signed,
+		Signer:   signer,
+	})
+}
+
+// SignerIdentity returns the policy identity of a signing key loaded from privKeyPath.
+// The key name is the file name without its .key extension, matching the .pub name on nodes.
+func SignerIdentity(privKeyPath string, signer *security.Signer) *policy.Signer {
+	return &policy.Signer{
+		Name:      strings.TrimSuffix(filepath.Base(privKeyPath), ".key"),
+		PublicKey: signer.PublicKey(),
+	}
+}
@@ -0,0 +1,191 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	"github.com/spf13/pflag"
+)
+
+// DefaultConcurrency is how many nodes a queue works on at once unless
+// --concurrency or deployment.concurrency say otherwise
+const DefaultConcurrency = 4
+
+// MaxRetryDelay caps the exponential backoff between attempts on a node
+const MaxRetryDelay = 2 * time.Minute
+
+// QueueOptions set how an operation runs on several nodes. Commands register
+// them with AddQueueFlags; unset options default to the deployment section
+// of the controller config.
+type QueueOptions struct {
+	// Concurrency is how many nodes are worked on at once
+	Concurrency int
+
+	// Retries is how often a failed attempt on a node is repeated, -1 for the default
+	Retries int
+
+	// RetryDelay is the delay before the first retry, doubled after each
+	RetryDelay time.Duration
+
+	// Timeout bounds each attempt, 0 for none
+	Timeout time.Duration
+}
+
+// AddQueueFlags registers --concurrency, --retries and --retry-delay
+func AddQueueFlags(flags *pflag.FlagSet, opts *QueueOptions) {
+	flags.IntVar(&opts.Concurrency, "concurrency", 0, "nodes to work on at once (default: deployment.concurrency)")
+	flags.IntVar(&opts.Retries, "retries", -1, "retries per node after a failed attempt (default: deployment.retry_attempts)")
+	flags.DurationVar(&opts.RetryDelay, "retry-delay", 0, "delay before the first retry, doubled after each (default: deployment.retry_delay)")
+}
+
+// WithDefaults fills in the options left unset from cfg
+func (o QueueOptions) WithDefaults(cfg *config.DeploymentConfig) QueueOptions {
+	if o.Concurrency <= 0 {
+		o.Concurrency = cfg.Concurrency
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultConcurrency
+	}
+	if o.Retries < 0 {
+		o.Retries = cfg.RetryAttempts
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = cfg.RetryDelay
+	}
+	if o.Timeout == 0 {
+		o.Timeout = cfg.Timeout
+	}
+	return o
+}
+
+// Parallel reports whether a queue over n nodes works on several at once,
+// so their output would interleave
+func (o QueueOptions) Parallel(n int) bool {
+	return n > 1 && o.Concurrency != 1
+}
+
+// NodeOp is an operation on one node, returning what it produced, e.g. the
+// ID of the app deployed
+type NodeOp func(ctx context.Context, nodeID string) (string, error)
+
+// NodeResult is the outcome of an operation on one node
+type NodeResult struct {
+	NodeID   string
+	Value    string
+	Attempts int
+	Duration time.Duration
+	Err      error
+}
+
+// RunQueue runs op on every node, at most opts.Concurrency at a time, and
+// returns the results in the order of nodes. Attempts failing in a way that
+// may succeed when repeated (see Retryable) are retried with exponential
+// backoff. Progress is reported as "[done/total]" lines once a node is
+// done when there are several. If host is not nil, the backoff it keeps
+// after failing to dial a node is cleared before retrying it.
+func RunQueue(ctx context.Context, host *p2p.Host, nodes []string, opts QueueOptions, op NodeOp) []NodeResult {
+	results := make([]NodeResult, len(nodes))
+	jobs := make(chan int)
+
+	var mu sync.Mutex
+	done := 0
+	report := func(res NodeResult) {
+		if len(nodes) < 2 {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if res.Err != nil {
+			Out.Statusf("  [%d/%d] ✗ %s: %v\n", done, len(nodes), res.NodeID, res.Err)
+		} else if res.Value != "" {
+			Out.Statusf("  [%d/%d] ✓ %s (%s)\n", done, len(nodes), res.NodeID, res.Value)
+		} else {
+			Out.Statusf("  [%d/%d] ✓ %s\n", done, len(nodes), res.NodeID)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range min(max(opts.Concurrency, 1), len(nodes)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runWithRetry(ctx, host, nodes[i], opts, op)
+				report(results[i])
+			}
+		}()
+	}
+	for i := range nodes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// runWithRetry runs op on a node until it succeeds, fails for good, or runs
+// out of retries
+func runWithRetry(ctx context.Context, host *p2p.Host, nodeID string, opts QueueOptions, op NodeOp) NodeResult {
+	start := time.Now()
+	res := NodeResult{NodeID: nodeID}
+	delay := max(opts.RetryDelay, time.Second)
+	for {
+		res.Attempts++
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		}
+		res.Value, res.Err = op(attemptCtx, nodeID)
+		cancel()
+
+		if res.Err == nil || res.Attempts > opts.Retries || !Retryable(res.Err) || ctx.Err() != nil {
+			res.Duration = time.Since(start)
+			return res
+		}
+
+		Out.Statusf("  ↻ %s: attempt %d failed, retrying in %s: %v\n", nodeID, res.Attempts, delay, res.Err)
+		select {
+		case <-ctx.Done():
+			res.Err = ctx.Err()
+			res.Duration = time.Since(start)
+			return res
+		case <-time.After(delay):
+		}
+		if host != nil {
+			ClearBackoff(host, nodeID)
+		}
+		delay = min(2*delay, MaxRetryDelay)
+	}
+}
+
+// Retryable reports whether a failed attempt may succeed when repeated: the
+// node could not be reached or did not answer in time, or the app was locked.
+// A node that answered with any other error rejected the operation.
+func Retryable(err error) bool {
+	if errors.Is(err, types.ErrAppLocked) || errors.Is(err, types.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var appErr *types.AppError
+	return !errors.As(err, &appErr)
+}
+
+// ClearBackoff lets the next attempt dial the node right away, instead of
+// failing on the backoff libp2p keeps after a failed dial
+func ClearBackoff(host *p2p.Host, nodeID string) {
+	id, err := peer.Decode(nodeID)
+	if err != nil {
+		return
+	}
+	if sw, ok := host.LibP2PHost().Network().(*swarm.Swarm); ok {
+		sw.Backoff().Clear(id)
+	}
+}
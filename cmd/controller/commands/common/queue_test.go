@@ -0,0 +1,94 @@
+package common_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/output"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func quietOutput(t *testing.T) {
+	t.Helper()
+	saved := common.Out
+	common.Out = output.New(output.FormatText, io.Discard, io.Discard)
+	t.Cleanup(func() { common.Out = saved })
+}
+
+func TestRunQueueConcurrency(t *testing.T) {
+	quietOutput(t)
+	nodes := make([]string, 20)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("node-%d", i)
+	}
+
+	var running, peak atomic.Int32
+	results := common.RunQueue(context.Background(), nil, nodes, common.QueueOptions{Concurrency: 3}, func(ctx context.Context, nodeID string) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return "app-" + nodeID, nil
+	})
+
+	if got := peak.Load(); got != 3 {
+		t.Errorf("at most %d nodes worked on at once, want 3", got)
+	}
+	if len(results) != len(nodes) {
+		t.Fatalf("got %d results, want %d", len(results), len(nodes))
+	}
+	for i, res := range results {
+		if res.NodeID != nodes[i] || res.Value != "app-"+nodes[i] || res.Err != nil || res.Attempts != 1 {
+			t.Errorf("results[%d] = %+v, want the success of %s", i, res, nodes[i])
+		}
+	}
+}
+
+func TestRunQueueRetries(t *testing.T) {
+	quietOutput(t)
+	var unreachable, rejected atomic.Int32
+	opts := common.QueueOptions{Concurrency: 2, Retries: 1, RetryDelay: time.Millisecond}
+	results := common.RunQueue(context.Background(), nil, []string{"flaky", "rejecting"}, opts, func(ctx context.Context, nodeID string) (string, error) {
+		if nodeID == "rejecting" {
+			rejected.Add(1)
+			return "", types.ErrorFromCode(types.CodeInvalidSignature, "signature verification failed")
+		}
+		if unreachable.Add(1) == 1 {
+			return "", errors.New("failed to create stream: no addresses")
+		}
+		return "app", nil
+	})
+
+	if res := results[0]; res.Err != nil || res.Attempts != 2 {
+		t.Errorf("flaky node: %+v, want success on the second attempt", res)
+	}
+	if res := results[1]; res.Err == nil || res.Attempts != 1 || rejected.Load() != 1 {
+		t.Errorf("rejecting node: %+v, want one failed attempt", res)
+	}
+}
+
+func TestQueueOptionsWithDefaults(t *testing.T) {
+	var opts common.QueueOptions
+	opts.Retries = -1
+	got := opts.WithDefaults(&config.DeploymentConfig{RetryAttempts: 3, RetryDelay: time.Second})
+	if got.Concurrency != common.DefaultConcurrency || got.Retries != 3 || got.RetryDelay != time.Second {
+		t.Errorf("WithDefaults() = %+v", got)
+	}
+
+	got = common.QueueOptions{Concurrency: 1, Retries: 0}.WithDefaults(&config.DeploymentConfig{Concurrency: 8, RetryAttempts: 3})
+	if got.Concurrency != 1 || got.Retries != 0 {
+		t.Errorf("WithDefaults() = %+v, want the options given kept", got)
+	}
+}
@@ -0,0 +1,109 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/registry"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// OpenRegistry returns the package registry of the configuration
+func OpenRegistry() (*registry.Bucket, error) {
+	bucket, err := registry.New(&GlobalConfig.Registry.S3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure registry.s3: %w", err)
+	}
+	if bucket == nil {
+		return nil, fmt.Errorf("%w: no registry is configured (registry.s3 in the controller config)", types.ErrUnavailable)
+	}
+	return bucket, nil
+}
+
+// PushPackage uploads a package, with its signature from <package>.sig if
+// signed, to the registry unless it is there already, and reports whether
+// it was uploaded
+func PushPackage(ctx context.Context, bucket *registry.Bucket, packagePath string) (*registry.Package, bool, error) {
+	manifest, err := pkgmanager.New().GetManifest(ctx, packagePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	digest, err := registry.FileDigest(packagePath)
+	if err != nil {
+		return nil, false, err
+	}
+	file, err := os.Open(packagePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open package: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to access package file: %w", err)
+	}
+
+	pkg := &registry.Package{
+		Digest:   digest,
+		FileName: filepath.Base(packagePath),
+		Name:     manifest.Name,
+		Version:  manifest.Version,
+		Size:     info.Size(),
+		PushedAt: time.Now().UTC(),
+	}
+	if signature, err := os.ReadFile(packagePath + ".sig"); err == nil {
+		pkg.Signature = signature
+	}
+	pushed, err := bucket.Push(ctx, pkg, file)
+	if err != nil {
+		return nil, false, err
+	}
+	return pkg, pushed, nil
+}
+
+// DeployDigest has a target node deploy the package of the registry with
+// digest, which the node fetches from the registry it is configured with
+func DeployDigest(ctx context.Context, host *p2p.Host, peerID string, digest string, opts DeployOptions, logger types.Logger) (string, error) {
+	protocolID := consts.Negotiate(consts.CapabilityDeploy, func(id string) bool {
+		return host.IsLocal(peerID) || host.Supports(peerID, id)
+	})
+	stream, err := openStream(ctx, host, peerID, protocolID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	req := DeployRequest{
+		AutoStart:     opts.AutoStart,
+		RequestID:     logging.NewRequestID(),
+		ForceUnlock:   opts.ForceUnlock,
+		Progress:      true,
+		WaitHealthy:   opts.WaitHealthy && opts.AutoStart,
+		HealthTimeout: opts.HealthTimeout,
+		Digest:        digest,
+	}
+	logger = logger.With("request_id", req.RequestID)
+	logger.Info("deploying package from the registry", "peer", peerID, "digest", digest)
+
+	if err := wire.WriteMessage(stream, &req); err != nil {
+		return "", withRequestID(err, req.RequestID)
+	}
+	resp, healthy, err := awaitDeployResponse(ctx, stream, opts.Quiet, logger)
+	if err != nil {
+		return "", withRequestID(err, req.RequestID)
+	}
+	if !resp.Success {
+		return "", withRequestID(fmt.Errorf("deployment failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), resp.RequestID)
+	}
+	if req.WaitHealthy && !healthy {
+		return "", withRequestID(fmt.Errorf("%w: node deployed %s but cannot wait for it to become healthy; upgrade the node", types.ErrNotImplemented, resp.AppID), resp.RequestID)
+	}
+	return resp.AppID, nil
+}
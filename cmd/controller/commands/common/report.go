@@ -0,0 +1,150 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Health outcomes of a node in a deploy report. The outcome is omitted when
+// the deployment did not wait for the app to become healthy.
+const (
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// DeployReport is the machine-readable summary of a deployment written with
+// --report, for CI artifacts and for tooling that acts on deployment results
+type DeployReport struct {
+	Command     string       `json:"command"`
+	Package     string       `json:"package,omitempty"`
+	PackageSize int64        `json:"package_size,omitempty"`
+	Checksum    string       `json:"checksum,omitempty"`
+	App         string       `json:"app,omitempty"`
+	Version     string       `json:"version,omitempty"`
+	DryRun      bool         `json:"dry_run,omitempty"`
+	Operation   string       `json:"operation,omitempty"` // Kept for 'controller retry' if some nodes failed
+	Success     bool         `json:"success"`
+	Error       string       `json:"error,omitempty"`
+	Code        string       `json:"code,omitempty"`
+	ExitCode    int          `json:"exit_code"`
+	StartedAt   time.Time    `json:"started_at"`
+	FinishedAt  time.Time    `json:"finished_at"`
+	DurationMS  int64        `json:"duration_ms"`
+	Nodes       []NodeReport `json:"nodes"`
+}
+
+// NodeReport is the result of a deployment on one node
+type NodeReport struct {
+	NodeID     string `json:"node_id"`
+	AppID      string `json:"app_id,omitempty"`
+	Success    bool   `json:"success"`
+	Started    bool   `json:"started"`
+	Health     string `json:"health,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Code       string `json:"code,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	Attempts   int    `json:"attempts,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// NewDeployReport starts the report of a command
+func NewDeployReport(command string) *DeployReport {
+	return &DeployReport{
+		Command:   command,
+		StartedAt: time.Now(),
+		Nodes:     []NodeReport{},
+	}
+}
+
+// SetPackage records the package being deployed
+func (r *DeployReport) SetPackage(path string, manifest *types.Manifest) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to access package file: %w", err)
+	}
+	checksum, err := pkgmanager.New().CalculateChecksum(path)
+	if err != nil {
+		return err
+	}
+
+	r.Package = path
+	r.PackageSize = info.Size()
+	r.Checksum = checksum
+	if manifest != nil {
+		r.App = manifest.Name
+		r.Version = manifest.Version
+	}
+	return nil
+}
+
+// AddNode records the result of deploying to a node, which began at start
+func (r *DeployReport) AddNode(nodeID string, appID string, opts DeployOptions, start time.Time, err error) {
+	r.addNode(nodeID, appID, opts, time.Since(start), err)
+}
+
+// AddResult records the result of deploying to a node through RunQueue
+func (r *DeployReport) AddResult(res NodeResult, opts DeployOptions) {
+	r.addNode(res.NodeID, res.Value, opts, res.Duration, res.Err)
+	r.Nodes[len(r.Nodes)-1].Attempts = res.Attempts
+}
+
+// addNode records the result of deploying to a node, which took duration
+func (r *DeployReport) addNode(nodeID string, appID string, opts DeployOptions, duration time.Duration, err error) {
+	node := NodeReport{
+		NodeID:     nodeID,
+		AppID:      appID,
+		Success:    err == nil,
+		Started:    err == nil && opts.AutoStart,
+		DurationMS: duration.Milliseconds(),
+	}
+
+	if opts.AutoStart && opts.WaitHealthy {
+		switch {
+		case err == nil:
+			node.Health = HealthHealthy
+		case errors.Is(err, types.ErrAppUnhealthy):
+			node.Health = HealthUnhealthy
+		}
+	}
+
+	if err != nil {
+		node.Error = err.Error()
+		node.Code = types.ErrorCode(err)
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) {
+			node.RequestID = reqErr.RequestID
+		}
+	}
+
+	r.Nodes = append(r.Nodes, node)
+}
+
+// Finish records the outcome of the command
+func (r *DeployReport) Finish(err error) {
+	r.FinishedAt = time.Now()
+	r.DurationMS = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+	r.Success = err == nil
+	r.ExitCode = ExitCode(err)
+	if err != nil {
+		r.Error = err.Error()
+		r.Code = types.ErrorCode(err)
+	}
+}
+
+// Write writes the report to path as indented JSON
+func (r *DeployReport) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package common_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestDeployReportAddNode(t *testing.T) {
+	waiting := common.DeployOptions{AutoStart: true, WaitHealthy: true}
+	unhealthy := &common.RequestError{
+		RequestID: "abc123",
+		Err:       fmt.Errorf("deployment failed on node: %w", types.ErrorFromCode(types.CodeAppUnhealthy, "not healthy")),
+	}
+
+	tests := []struct {
+		name        string
+		opts        common.DeployOptions
+		err         error
+		wantSuccess bool
+		wantStarted bool
+		wantHealth  string
+		wantCode    string
+		wantRequest string
+	}{
+		{"started", common.DeployOptions{AutoStart: true}, nil, true, true, "", "", ""},
+		{"not started", common.DeployOptions{}, nil, true, false, "", "", ""},
+		{"healthy", waiting, nil, true, true, common.HealthHealthy, "", ""},
+		{"unhealthy", waiting, unhealthy, false, false, common.HealthUnhealthy, types.CodeAppUnhealthy, "abc123"},
+		{"unreachable", waiting, errors.New("failed to create stream"), false, false, "", types.CodeInternal, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := common.NewDeployReport("deploy")
+			report.AddNode("node-1", "app-1.0.0", tt.opts, time.Now(), tt.err)
+
+			node := report.Nodes[0]
+			if node.Success != tt.wantSuccess || node.Started != tt.wantStarted {
+				t.Errorf("success, started = %v, %v, want %v, %v", node.Success, node.Started, tt.wantSuccess, tt.wantStarted)
+			}
+			if node.Health != tt.wantHealth {
+				t.Errorf("health = %q, want %q", node.Health, tt.wantHealth)
+			}
+			if node.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", node.Code, tt.wantCode)
+			}
+			if node.RequestID != tt.wantRequest {
+				t.Errorf("request id = %q, want %q", node.RequestID, tt.wantRequest)
+			}
+		})
+	}
+}
+
+func TestDeployReportWrite(t *testing.T) {
+	report := common.NewDeployReport("run")
+	report.AddNode("node-1", "", common.DeployOptions{AutoStart: true}, time.Now(), common.ErrNoNodes)
+	report.Finish(fmt.Errorf("deploy: %w", common.ErrNoNodes))
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := report.Write(path); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got common.DeployReport
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if got.Command != "run" || got.Success || got.ExitCode != common.ExitNoNodes {
+		t.Errorf("command, success, exit code = %q, %v, %d", got.Command, got.Success, got.ExitCode)
+	}
+	if len(got.Nodes) != 1 || got.Nodes[0].Success {
+		t.Errorf("nodes = %+v, want one failed node", got.Nodes)
+	}
+	if got.FinishedAt.Before(got.StartedAt) {
+		t.Errorf("finished at %v before started at %v", got.FinishedAt, got.StartedAt)
+	}
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/revision"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// RollbackRequest represents a request to redeploy an earlier revision of an
+// app, or with List to return its revisions
+type RollbackRequest struct {
+	App           string        `json:"app"`
+	Revision      int64         `json:"revision,omitempty"`
+	List          bool          `json:"list,omitempty"`
+	RequestID     string        `json:"request_id,omitempty"`
+	ForceUnlock   bool          `json:"force_unlock,omitempty"`
+	WaitHealthy   bool          `json:"wait_healthy,omitempty"`
+	HealthTimeout time.Duration `json:"health_timeout,omitempty"`
+}
+
+// RollbackResponse represents a rollback response
+type RollbackResponse struct {
+	Success    bool                 `json:"success"`
+	AppID      string               `json:"app_id,omitempty"`
+	Revision   int64                `json:"revision,omitempty"`
+	RollbackTo int64                `json:"rollback_to,omitempty"`
+	Revisions  []*revision.Revision `json:"revisions,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	Code       string               `json:"code,omitempty"`
+	RequestID  string               `json:"request_id,omitempty"`
+}
+
+// Rollback has a target node redeploy an earlier revision of an app, or with
+// req.List return the revisions it keeps
+func Rollback(ctx context.Context, host *p2p.Host, peerID string, req RollbackRequest, logger types.Logger) (*RollbackResponse, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.RollbackProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	if req.RequestID == "" {
+		req.RequestID = logging.NewRequestID()
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting rollback", "peer", peerID, "app", req.App, "revision", req.Revision, "list", req.List)
+
+	var resp RollbackResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("rollback request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+
+	logger.Info("rollback response received", "app_id", resp.AppID, "revision", resp.Revision, "revisions", len(resp.Revisions))
+	return &resp, nil
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// sizeUnits are the units of sizes, largest first; KB, MB and GB are powers of 1024
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as "100MB", "1.5G" or "512" (bytes). KB, MB
+// and GB, or K, M and G, are powers of 1024, in any case.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 || n*float64(multiplier) >= 1<<63 {
+		return 0, fmt.Errorf("%w: invalid size %q, e.g. 100MB", types.ErrInvalidInput, s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatSize formats bytes in the largest unit that fits, to a tenth, e.g. "1.5 GB"
+func FormatSize(bytes int64) string {
+	for _, unit := range sizeUnits[:3] {
+		if bytes >= unit.bytes {
+			rounded := math.Round(float64(bytes)/float64(unit.bytes)*10) / 10
+			return strconv.FormatFloat(rounded, 'f', -1, 64) + " " + unit.suffix
+		}
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
@@ -0,0 +1,54 @@
+package common_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"512", 512},
+		{"512B", 512},
+		{"64KB", 64 << 10},
+		{"100MB", 100 << 20},
+		{"100mb", 100 << 20},
+		{"1.5G", 3 << 29},
+		{" 2 GB ", 2 << 30},
+	}
+	for _, tt := range tests {
+		got, err := common.ParseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "MB", "-1MB", "ten", "1TB"} {
+		if _, err := common.ParseSize(in); !errors.Is(err, types.ErrInvalidInput) {
+			t.Errorf("ParseSize(%q) error = %v, want ErrInvalidInput", in, err)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{1000, "1000 B"},
+		{1 << 10, "1 KB"},
+		{100 << 20, "100 MB"},
+		{3 << 29, "1.5 GB"},
+	}
+	for _, tt := range tests {
+		if got := common.FormatSize(tt.in); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
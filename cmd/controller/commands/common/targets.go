@@ -0,0 +1,316 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/spf13/pflag"
+)
+
+// DiscoveryWait is how long discovery may look for nodes before a command
+// chooses among those found, unless the node was given by peer ID
+const DiscoveryWait = 3 * time.Second
+
+// Selection chooses the nodes a command talks to. Commands register it with
+// AddSelectionFlags and resolve it with ResolveNodes or ResolveNode.
+type Selection struct {
+	// Node is the peer ID or the announced name of a node
+	Node string
+
+	// Selector selects the nodes announcing all of these labels, "key=value,..."
+	Selector string
+
+	// All selects every node found
+	All bool
+}
+
+// AddSelectionFlags registers --node and, for commands that can act on
+// several nodes at once, --selector and --all
+func AddSelectionFlags(flags *pflag.FlagSet, sel *Selection, multi bool) {
+	flags.StringVar(&sel.Node, "node", "", "target node, by peer ID or name")
+	if multi {
+		flags.StringVar(&sel.Selector, "selector", "", "target the nodes with these labels, e.g. env=lab,zone=a")
+		flags.BoolVar(&sel.All, "all", false, "target every node found")
+	}
+}
+
+// IsEmpty reports whether no node was selected, so the command picks one
+func (s *Selection) IsEmpty() bool {
+	return s.Node == "" && s.Selector == "" && !s.All
+}
+
+// Multiple reports whether the selection may match several nodes
+func (s *Selection) Multiple() bool {
+	return s.Selector != "" || s.All
+}
+
+// candidate is a daemon found for a selection, with what it announced if it did
+type candidate struct {
+	id   string
+	node *discovery.DiscoveredNode
+}
+
+// String names the candidate for messages
+func (c candidate) String() string {
+	if c.node != nil && c.node.Name != "" {
+		return c.node.Name + " (" + c.id + ")"
+	}
+	return c.id
+}
+
+// ResolveNode returns the peer ID of the node sel selects. Without a
+// selection that is the daemon reached over the local socket, or else the
+// only daemon found; with several, the user has to choose one with --node.
+func ResolveNode(ctx context.Context, host *p2p.Host, sel *Selection) (string, error) {
+	ids, err := ResolveNodes(ctx, host, sel)
+	if err != nil {
+		return "", err
+	}
+	if len(ids) > 1 {
+		return "", fmt.Errorf("%w: %d nodes selected, choose one with --node", types.ErrInvalidInput, len(ids))
+	}
+	return ids[0], nil
+}
+
+// ResolveNodes returns the peer IDs of the daemons sel selects, the one
+// reached over the local socket first. A peer ID given with --node is used as is; names and
+// labels are matched against what the daemons announce. Peers that do not
+// serve the daemon protocols, such as bootstrap nodes, relays and other
+// controllers, are never selected, nor are daemons missing from
+// security.trusted_peers when it is set.
+func ResolveNodes(ctx context.Context, host *p2p.Host, sel *Selection) ([]string, error) {
+	if sel.Node != "" && sel.Multiple() {
+		return nil, fmt.Errorf("%w: --node cannot be combined with --selector or --all", types.ErrInvalidInput)
+	}
+	if sel.Selector != "" && sel.All {
+		return nil, fmt.Errorf("%w: --selector cannot be combined with --all", types.ErrInvalidInput)
+	}
+	labels, err := ParseSelector(sel.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if sel.Node != "" {
+		if _, err := peer.Decode(sel.Node); err == nil {
+			if err := CheckTrusted(host, sel.Node); err != nil {
+				return nil, err
+			}
+			Out.Statusf("Using specified node: %s\n", sel.Node)
+			return []string{sel.Node}, nil
+		}
+	}
+	if sel.IsEmpty() {
+		for _, p := range host.Peers() {
+			if host.IsLocal(p.ID) {
+				Out.Statusf("Using local node: %s\n", p.ID)
+				return []string{p.ID}, nil
+			}
+		}
+	}
+
+	nodes, err := Discovery(host)
+	if err != nil {
+		return nil, err
+	}
+	Out.Statusln("Discovering nodes...")
+
+	// Names and labels are only known once the daemons announced themselves
+	needAnnounced := sel.Node != "" || len(labels) > 0
+	found, err := findDaemons(ctx, host, nodes, needAnnounced, func(found []candidate) bool {
+		return sel.Node != "" && slices.ContainsFunc(found, func(c candidate) bool {
+			return c.node != nil && c.node.Name == sel.Node
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	found = slices.DeleteFunc(found, func(c candidate) bool {
+		if err := CheckTrusted(host, c.id); err != nil {
+			GlobalLogger.Debug("skipping untrusted node", "peer", c.id)
+			return true
+		}
+		return false
+	})
+	if len(found) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	var selected []candidate
+	switch {
+	case sel.Node != "":
+		for _, c := range found {
+			if c.node != nil && c.node.Name == sel.Node {
+				selected = append(selected, c)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("no node named %q, found %s: %w", sel.Node, describe(found), ErrNoNodes)
+		}
+		if len(selected) > 1 {
+			return nil, fmt.Errorf("%w: %d nodes are named %q, choose one by peer ID: %s",
+				types.ErrInvalidInput, len(selected), sel.Node, describe(selected))
+		}
+	case len(labels) > 0:
+		for _, c := range found {
+			if c.node != nil && matchLabels(c.node.Labels, labels) {
+				selected = append(selected, c)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("no node matches selector %s: %w", sel.Selector, ErrNoNodes)
+		}
+	case sel.All:
+		selected = found
+	default:
+		if len(found) > 1 {
+			return nil, fmt.Errorf("%w: %d nodes found, choose one with --node: %s",
+				types.ErrInvalidInput, len(found), describe(found))
+		}
+		selected = found
+	}
+
+	ids := make([]string, len(selected))
+	for i, c := range selected {
+		ids[i] = c.id
+		Out.Statusf("Using discovered node: %s\n", c)
+	}
+	return ids, nil
+}
+
+// LocateNodes looks for the daemons with the given peer IDs for up to
+// DiscoveryWait, so that they can be reached by peer ID alone. Nodes not
+// found are left to fail when dialed.
+func LocateNodes(ctx context.Context, host *p2p.Host, ids []string) error {
+	nodes, err := Discovery(host)
+	if err != nil {
+		return err
+	}
+	Out.Statusln("Discovering nodes...")
+
+	_, err = findDaemons(ctx, host, nodes, false, func(found []candidate) bool {
+		for _, id := range ids {
+			if !slices.ContainsFunc(found, func(c candidate) bool { return c.id == id }) {
+				return false
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// findDaemons waits DiscoveryWait for daemons to be found, and with
+// needAnnounced up to DeviceDiscoveryTimeout for all of them to announce
+// themselves. It returns early once done reports the daemons found suffice.
+func findDaemons(ctx context.Context, host *p2p.Host, nodes *discovery.Merged, needAnnounced bool, done func([]candidate) bool) ([]candidate, error) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	start := time.Now()
+	dialed := make(map[peer.ID]bool)
+
+	for {
+		found := daemons(ctx, host, nodes, dialed)
+		if done(found) {
+			return found, nil
+		}
+
+		elapsed := time.Since(start)
+		if elapsed >= DiscoveryWait {
+			announced := !slices.ContainsFunc(found, func(c candidate) bool { return c.node == nil })
+			if len(found) == 0 || !needAnnounced || announced {
+				return found, nil
+			}
+			if elapsed >= DeviceDiscoveryTimeout {
+				GlobalLogger.Warn("not all nodes announced themselves in time")
+				return found, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// daemons returns the connected peers serving the daemon protocols, those
+// reached over the local socket first. Announced nodes the host is not
+// connected to are dialed once, in the background, to find out.
+func daemons(ctx context.Context, host *p2p.Host, nodes *discovery.Merged, dialed map[peer.ID]bool) []candidate {
+	var found []candidate
+	for _, p := range host.Peers() {
+		if !host.Supports(p.ID, consts.ListProtocolID) {
+			continue
+		}
+		c := candidate{id: p.ID}
+		if id, err := peer.Decode(p.ID); err == nil {
+			if node := nodes.Node(id); node != nil && slices.Contains(node.Sources, discovery.BackendGossip) {
+				c.node = node
+			}
+		}
+		found = append(found, c)
+	}
+
+	for _, node := range nodes.Nodes() {
+		if dialed[node.PeerID] || len(host.LibP2PHost().Network().ConnsToPeer(node.PeerID)) > 0 {
+			continue
+		}
+		dialed[node.PeerID] = true
+		info := peer.AddrInfo{ID: node.PeerID}
+		for _, addr := range node.Addrs {
+			if maddr, err := multiaddr.NewMultiaddr(addr); err == nil {
+				info.Addrs = append(info.Addrs, maddr)
+			}
+		}
+		go func() {
+			dialCtx, cancel := context.WithTimeout(ctx, DiscoveryWait)
+			defer cancel()
+			_ = host.LibP2PHost().Connect(dialCtx, info)
+		}()
+	}
+	return found
+}
+
+// describe lists candidates for error messages
+func describe(found []candidate) string {
+	names := make([]string, len(found))
+	for i, c := range found {
+		names[i] = c.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseSelector parses a label selector of the form "key=value,key=value"
+func ParseSelector(selector string) (map[string]string, error) {
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(selector, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%w: invalid selector %q, want key=value,...", types.ErrInvalidInput, selector)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// matchLabels reports whether labels has every key and value of want
+func matchLabels(labels map[string]string, want map[string]string) bool {
+	for key, value := range want {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package common_test
+
+import (
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{"empty", "", nil, false},
+		{"single", "env=lab", map[string]string{"env": "lab"}, false},
+		{"several", "env=lab, zone=a", map[string]string{"env": "lab", "zone": "a"}, false},
+		{"empty value", "gpu=", map[string]string{"gpu": ""}, false},
+		{"missing value", "env", nil, true},
+		{"missing key", "=lab", nil, true},
+		{"trailing comma", "env=lab,", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := common.ParseSelector(tt.selector)
+			if tt.wantErr {
+				if !errors.Is(err, types.ErrInvalidInput) {
+					t.Fatalf("ParseSelector() error = %v, want ErrInvalidInput", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSelector() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("ParseSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveNodesRejectsConflictingFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		sel  common.Selection
+	}{
+		{"node and selector", common.Selection{Node: "a", Selector: "env=lab"}},
+		{"node and all", common.Selection{Node: "a", All: true}},
+		{"selector and all", common.Selection{Selector: "env=lab", All: true}},
+		{"bad selector", common.Selection{Selector: "env"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The flags are checked before the host is used
+			if _, err := common.ResolveNodes(t.Context(), nil, &tt.sel); !errors.Is(err, types.ErrInvalidInput) {
+				t.Errorf("ResolveNodes() error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
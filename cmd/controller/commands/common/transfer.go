@@ -0,0 +1,94 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/transfer"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// DirectUpgradeTimeout is how long a deploy waits for a direct connection to
+// a node reached through a relay before sending the package through the relay
+const DirectUpgradeTimeout = 5 * time.Second
+
+// relayedEstimateSize is the package size the relayed throughput warning
+// estimates the transfer time of, to put the rate in perspective
+const relayedEstimateSize = 100 * 1000 * 1000
+
+// reportTransfer reports how a package of size bytes was sent to a node and
+// how fast, warning when it went through a relay. Quiet, only the warning is
+// reported, in a line of its own.
+func reportTransfer(peerID string, route p2p.Route, size int64, elapsed time.Duration, quiet bool, logger types.Logger) {
+	if elapsed <= 0 {
+		elapsed = time.Millisecond
+	}
+	rate := float64(size) / elapsed.Seconds()
+
+	if route != p2p.RouteRelayed {
+		if quiet {
+			return
+		}
+		Out.Statusf("  Transfer: %s, %s\n", route, FormatRate(rate))
+		return
+	}
+
+	estimate := time.Duration(relayedEstimateSize / rate * float64(time.Second)).Round(time.Second)
+	logger.Warn("package sent through a relay", "peer", peerID, "rate", FormatRate(rate))
+	if quiet {
+		Out.Statusf("  Warning: package relayed to %s at %s, a direct connection could not be established\n", peerID, FormatRate(rate))
+		return
+	}
+	Out.Statusf("  Warning: transfer relayed at %s, a direct connection could not be established\n", FormatRate(rate))
+	Out.Statusf("  At this rate a 100 MB package takes about %s and counts against the relay's limits;\n", estimate)
+	Out.Statusf("  make the node reachable or enable hole punching on both sides for direct transfers\n")
+}
+
+// FormatRate formats a transfer rate in bytes per second
+func FormatRate(bytesPerSecond float64) string {
+	switch {
+	case bytesPerSecond >= 1000*1000:
+		return fmt.Sprintf("%.1f MB/s", bytesPerSecond/(1000*1000))
+	case bytesPerSecond >= 1000:
+		return fmt.Sprintf("%.1f KB/s", bytesPerSecond/1000)
+	}
+	return fmt.Sprintf("%.0f B/s", bytesPerSecond)
+}
+
+// TransferPackage asks the node from to send the package ref names (an app
+// instance, an app name for its latest package, or a package file name) to
+// the node to, which deploys it, starting it if start is set. The package
+// goes from node to node without passing through the controller.
+func TransferPackage(ctx context.Context, host *p2p.Host, from string, ref string, to string, start bool, logger types.Logger) (*transfer.Response, error) {
+	stream, err := openStream(ctx, host, from, consts.TransferProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	req := transfer.Request{
+		To:        to,
+		Ref:       ref,
+		AutoStart: start,
+		RequestID: logging.NewRequestID(),
+	}
+	logger = logger.With("request_id", req.RequestID)
+	logger.Info("requesting package transfer", "from", from, "ref", ref, "to", to)
+
+	if err := wire.WriteMessage(stream, &req); err != nil {
+		return nil, withRequestID(err, req.RequestID)
+	}
+	var resp transfer.Response
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+	if !resp.Success {
+		return nil, withRequestID(types.ErrorFromCode(resp.Code, resp.Error), req.RequestID)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,24 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+)
+
+func TestFormatRate(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want string
+	}{
+		{0, "0 B/s"},
+		{999, "999 B/s"},
+		{1500, "1.5 KB/s"},
+		{12_340_000, "12.3 MB/s"},
+	}
+	for _, tt := range tests {
+		if got := common.FormatRate(tt.rate); got != tt.want {
+			t.Errorf("FormatRate(%v) = %s, want %s", tt.rate, got, tt.want)
+		}
+	}
+}
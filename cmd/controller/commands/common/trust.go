@@ -0,0 +1,53 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// TrustedOnly restricts every connection of the controller to the peers in
+// security.trusted_peers, including the bootstrap peers and relays it would
+// otherwise reach regardless. Set by the --trusted-only flag.
+var TrustedOnly bool
+
+// GatedPeers returns the peer IDs the controller host may connect to, or
+// nil when connections are not restricted. With security.trusted_peers set
+// those are the trusted daemons plus the configured bootstrap peers and
+// static relays, which the controller needs to reach them; with TrustedOnly
+// only the trusted daemons.
+func GatedPeers() ([]string, error) {
+	trusted := GlobalConfig.Security.TrustedPeers
+	if len(trusted) == 0 {
+		if TrustedOnly {
+			return nil, fmt.Errorf("%w: --trusted-only requires security.trusted_peers in the controller configuration", types.ErrInvalidInput)
+		}
+		return nil, nil
+	}
+	if TrustedOnly {
+		return trusted, nil
+	}
+
+	allowed := slices.Clone(trusted)
+	for _, addr := range slices.Concat(GlobalConfig.Node.BootstrapPeers, GlobalConfig.Node.StaticRelays) {
+		if info, err := peer.AddrInfoFromString(strings.TrimSpace(addr)); err == nil {
+			allowed = append(allowed, info.ID.String())
+		}
+	}
+	return allowed, nil
+}
+
+// CheckTrusted returns an error if security.trusted_peers is set and does
+// not list the daemon with peer ID id. The daemon reached over the local
+// socket is always trusted.
+func CheckTrusted(host *p2p.Host, id string) error {
+	trusted := GlobalConfig.Security.TrustedPeers
+	if len(trusted) == 0 || slices.Contains(trusted, id) || host.IsLocal(id) {
+		return nil
+	}
+	return fmt.Errorf("%w: node %s is not in security.trusted_peers", types.ErrUnauthorized, id)
+}
@@ -0,0 +1,133 @@
+package common_test
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// newPeerID returns the ID of a fresh key
+func newPeerID(t *testing.T) string {
+	t.Helper()
+	_, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id.String()
+}
+
+// newTestHost creates a host reachable on loopback only
+func newTestHost(t *testing.T) *p2p.Host {
+	t.Helper()
+	h, err := p2p.NewHost(context.Background(), &p2p.HostConfig{
+		ListenAddrs:         []string{"/ip4/127.0.0.1/tcp/0"},
+		DisableDHT:          true,
+		DisableNATService:   true,
+		DisableAutoRelay:    true,
+		DisableHolePunching: true,
+		DisableRelayService: true,
+	}, logging.Nop())
+	if err != nil {
+		t.Fatalf("NewHost() error = %v", err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	return h
+}
+
+// setTrust configures the trusted peers, bootstrap peers and relays, and
+// --trusted-only, for the duration of the test
+func setTrust(t *testing.T, trusted, bootstrap, relays []string, trustedOnly bool) {
+	t.Helper()
+	savedConfig, savedTrustedOnly := common.GlobalConfig, common.TrustedOnly
+	t.Cleanup(func() { common.GlobalConfig, common.TrustedOnly = savedConfig, savedTrustedOnly })
+
+	common.GlobalConfig = &config.ControllerConfig{
+		Node:     config.NodeConfig{BootstrapPeers: bootstrap, StaticRelays: relays},
+		Security: config.SecurityConfig{TrustedPeers: trusted},
+	}
+	common.TrustedOnly = trustedOnly
+}
+
+func TestGatedPeers(t *testing.T) {
+	daemon, bootstrap, relay := newPeerID(t), newPeerID(t), newPeerID(t)
+	bootstrapAddr := "/ip4/192.0.2.1/tcp/9000/p2p/" + bootstrap
+	relayAddr := "/ip4/192.0.2.2/tcp/9000/p2p/" + relay
+
+	tests := []struct {
+		name        string
+		trusted     []string
+		bootstrap   []string
+		trustedOnly bool
+		want        []string
+		wantErr     error
+	}{
+		{"unrestricted", nil, []string{bootstrapAddr}, false, nil, nil},
+		{"trusted-only without trusted peers", nil, []string{bootstrapAddr}, true, nil, types.ErrInvalidInput},
+		{"bootstrap peers and relays exempt", []string{daemon}, []string{bootstrapAddr}, false, []string{daemon, bootstrap, relay}, nil},
+		{"malformed bootstrap address skipped", []string{daemon}, []string{"not an address"}, false, []string{daemon, relay}, nil},
+		{"trusted-only", []string{daemon}, []string{bootstrapAddr}, true, []string{daemon}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTrust(t, tt.trusted, tt.bootstrap, []string{relayAddr}, tt.trustedOnly)
+			got, err := common.GatedPeers()
+			if !errors.Is(err, tt.wantErr) || !slices.Equal(got, tt.want) {
+				t.Errorf("GatedPeers() = %v, %v; want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckTrusted(t *testing.T) {
+	// The controller reaches the local daemon over its socket
+	local := newTestHost(t)
+	socket := filepath.Join(t.TempDir(), p2p.LocalSocketName)
+	if err := local.ServeLocal(socket); err != nil {
+		t.Fatal(err)
+	}
+	host := newTestHost(t)
+	if _, err := host.ConnectLocal(context.Background(), socket); err != nil {
+		t.Fatal(err)
+	}
+
+	trusted, untrusted, bootstrap := newPeerID(t), newPeerID(t), newPeerID(t)
+	bootstrapAddrs := []string{"/ip4/192.0.2.1/tcp/9000/p2p/" + bootstrap}
+
+	tests := []struct {
+		name    string
+		trusted []string
+		peer    string
+		wantErr error
+	}{
+		{"no trusted peers", nil, untrusted, nil},
+		{"trusted", []string{trusted}, trusted, nil},
+		{"untrusted", []string{trusted}, untrusted, types.ErrUnauthorized},
+		{"bootstrap peer not trusted to deploy", []string{trusted}, bootstrap, types.ErrUnauthorized},
+		{"local daemon", []string{trusted}, local.ID(), nil},
+	}
+
+	for _, tt := range tests {
+		for _, trustedOnly := range []bool{false, true} {
+			setTrust(t, tt.trusted, bootstrapAddrs, nil, trustedOnly)
+			if err := common.CheckTrusted(host, tt.peer); !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: CheckTrusted() with --trusted-only=%v error = %v, want %v", tt.name, trustedOnly, err, tt.wantErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/history"
+)
+
+// sparkBars are the bars of a sparkline, from lowest to highest
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a line of bars scaled from 0 to top, or to the
+// largest value if top is not positive. With more values than width (if
+// positive), each bar shows the largest of the values it stands for, so
+// spikes stay visible.
+func Sparkline(values []float64, top float64, width int) string {
+	if width > 0 && len(values) > width {
+		buckets := make([]float64, width)
+		for i := range buckets {
+			bucket := values[i*len(values)/width : (i+1)*len(values)/width]
+			buckets[i] = bucket[0]
+			for _, v := range bucket[1:] {
+				buckets[i] = max(buckets[i], v)
+			}
+		}
+		values = buckets
+	}
+	if top <= 0 {
+		for _, v := range values {
+			top = max(top, v)
+		}
+	}
+
+	line := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if top > 0 {
+			level = int(math.Round(v / top * float64(len(sparkBars)-1)))
+		}
+		line[i] = sparkBars[min(max(level, 0), len(sparkBars)-1)]
+	}
+	return string(line)
+}
+
+// MemoryTrend is how fast the memory of an app grew over a period
+type MemoryTrend struct {
+	// MBPerMinute is the growth fitted to the samples, negative if memory shrank
+	MBPerMinute float64 `json:"mb_per_minute"`
+
+	// UntilLimit is how long until memory reaches the limit at this rate,
+	// zero if it does not grow or the app has no limit
+	UntilLimit time.Duration `json:"until_limit,omitempty"`
+}
+
+// minTrendSamples is how many samples with memory usage a trend is fitted to at least
+const minTrendSamples = 3
+
+// FitMemoryTrend fits a line to the memory usage of the samples among
+// entries, oldest first, and returns how fast it grows towards limitMB (0 for
+// none). It returns nil with too few samples to tell.
+func FitMemoryTrend(entries []*history.Entry, limitMB int64) *MemoryTrend {
+	var n, sumX, sumY, sumXY, sumXX float64
+	var start time.Time
+	var last int64
+	for _, e := range entries {
+		if e.Kind != history.KindSample || e.Usage == nil {
+			continue
+		}
+		if start.IsZero() {
+			start = e.Time
+		}
+		x := e.Time.Sub(start).Minutes()
+		y := float64(e.Usage.MemoryMB)
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+		last = e.Usage.MemoryMB
+	}
+	denom := n*sumXX - sumX*sumX
+	if n < minTrendSamples || denom <= 0 {
+		return nil
+	}
+
+	trend := &MemoryTrend{MBPerMinute: (n*sumXY - sumX*sumY) / denom}
+	if limitMB > 0 && trend.MBPerMinute > 0 {
+		minutes := float64(max(limitMB-last, 0)) / trend.MBPerMinute
+		trend.UntilLimit = time.Duration(minutes * float64(time.Minute)).Round(time.Second)
+	}
+	return trend
+}
+
+// String describes the trend, e.g. "+4.2 MB/min, limit in 12m0s"
+func (t *MemoryTrend) String() string {
+	if math.Abs(t.MBPerMinute) < 0.05 {
+		return "steady"
+	}
+	s := fmt.Sprintf("%+.1f MB/min", t.MBPerMinute)
+	if t.UntilLimit > 0 {
+		s += fmt.Sprintf(", limit in %s", t.UntilLimit)
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package common_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/history"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		top    float64
+		width  int
+		want   string
+	}{
+		{"scaled to largest", []float64{0, 1, 2, 3, 4, 5, 6, 7}, 0, 0, "▁▂▃▄▅▆▇█"},
+		{"scaled to top", []float64{0, 50, 100}, 200, 0, "▁▃▅"},
+		{"clamped", []float64{-1, 300}, 200, 0, "▁█"},
+		{"all zero", []float64{0, 0}, 0, 0, "▁▁"},
+		{"largest of each bucket", []float64{0, 7, 0, 0, 3, 1}, 7, 3, "█▁▄"},
+		{"empty", nil, 0, 10, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := common.Sparkline(tt.values, tt.top, tt.width); got != tt.want {
+				t.Errorf("Sparkline() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitMemoryTrend(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(minute int, memoryMB int64) *history.Entry {
+		return &history.Entry{
+			Time:  start.Add(time.Duration(minute) * time.Minute),
+			Kind:  history.KindSample,
+			Usage: &types.ResourceUsage{MemoryMB: memoryMB},
+		}
+	}
+
+	// Growing 10 MB a minute, 60 MB short of the limit
+	entries := []*history.Entry{
+		sample(0, 100),
+		{Time: start, Kind: history.KindEvent, Event: types.AppEventStarted},
+		sample(1, 110),
+		sample(2, 120),
+		sample(3, 130),
+		sample(4, 140),
+	}
+	trend := common.FitMemoryTrend(entries, 200)
+	if trend == nil {
+		t.Fatal("FitMemoryTrend() = nil")
+	}
+	if trend.MBPerMinute < 9.99 || trend.MBPerMinute > 10.01 || trend.UntilLimit != 6*time.Minute {
+		t.Errorf("FitMemoryTrend() = %+v, want 10 MB/min and 6m until the limit", trend)
+	}
+	if got := trend.String(); got != "+10.0 MB/min, limit in 6m0s" {
+		t.Errorf("String() = %q", got)
+	}
+
+	// Without a limit there is no time to it
+	if trend := common.FitMemoryTrend(entries, 0); trend == nil || trend.UntilLimit != 0 {
+		t.Errorf("FitMemoryTrend() without a limit = %+v", trend)
+	}
+
+	steady := []*history.Entry{sample(0, 50), sample(1, 50), sample(2, 50)}
+	if trend := common.FitMemoryTrend(steady, 200); trend == nil || trend.String() != "steady" {
+		t.Errorf("FitMemoryTrend() of steady memory = %+v", trend)
+	}
+
+	if trend := common.FitMemoryTrend(entries[:2], 200); trend != nil {
+		t.Errorf("FitMemoryTrend() of one sample = %+v, want nil", trend)
+	}
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/integrity"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// VerifyRequest represents a request to check the files of a deployed app
+type VerifyRequest struct {
+	AppID     string `json:"app_id"`
+	Files     bool   `json:"files,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// VerifyResponse represents a verify response
+type VerifyResponse struct {
+	Success   bool              `json:"success"`
+	Report    *integrity.Report `json:"report,omitempty"`
+	Files     []integrity.File  `json:"files,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Code      string            `json:"code,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// VerifyApp has a target node check the files of a deployed app against the
+// checksums it recorded when the app was deployed
+func VerifyApp(ctx context.Context, host *p2p.Host, peerID string, appID string, logger types.Logger) (*integrity.Report, error) {
+	resp, err := verifyApp(ctx, host, peerID, appID, false, logger)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Report, nil
+}
+
+// DeployedFiles returns the checksums a target node recorded for the files
+// of a deployed app when it was deployed, and the report of checking the
+// files against them. Nodes that do not report the checksums return none.
+func DeployedFiles(ctx context.Context, host *p2p.Host, peerID string, appID string, logger types.Logger) ([]integrity.File, *integrity.Report, error) {
+	resp, err := verifyApp(ctx, host, peerID, appID, true, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Files, resp.Report, nil
+}
+
+// verifyApp sends a verify request and reads the response, with the
+// recorded checksums if files is set
+func verifyApp(ctx context.Context, host *p2p.Host, peerID string, appID string, files bool, logger types.Logger) (*VerifyResponse, error) {
+	// Create stream to target peer
+	stream, err := openStream(ctx, host, peerID, consts.VerifyProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Prepare request
+	req := VerifyRequest{
+		AppID:     appID,
+		Files:     files,
+		RequestID: logging.NewRequestID(),
+	}
+	logger = logger.With("request_id", req.RequestID)
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, fmt.Errorf("failed to send header: %w", err)
+	}
+
+	logger.Info("requesting app verification", "peer", peerID, "app_id", appID)
+
+	var resp VerifyResponse
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, withRequestID(fmt.Errorf("failed to read response: %w", err), req.RequestID)
+	}
+
+	if !resp.Success {
+		return nil, withRequestID(fmt.Errorf("verify request failed on node: %w", types.ErrorFromCode(resp.Code, resp.Error)), req.RequestID)
+	}
+	if resp.Report == nil {
+		return nil, withRequestID(fmt.Errorf("%w: node sent no verification report", types.ErrInternal), req.RequestID)
+	}
+
+	logger.Info("received verification report", "checked", resp.Report.Checked, "drift", len(resp.Report.Drift))
+	return &resp, nil
+}
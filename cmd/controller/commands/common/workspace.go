@@ -0,0 +1,104 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// workspacesDir is the directory under Storage.PackagesDir holding temporary workspaces
+const workspacesDir = "tmp"
+
+// Workspace is a temporary directory for the files a command builds, such as
+// packages and their signatures. It is named after the process that created
+// it, so PruneWorkspaces can tell which ones were left behind.
+type Workspace struct {
+	// Dir is the workspace directory
+	Dir string
+}
+
+// NewWorkspace creates a workspace under Storage.PackagesDir
+func NewWorkspace(name string) (*Workspace, error) {
+	base, err := workspacesBase()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, types.WrapError(err, "failed to create workspace directory")
+	}
+	dir, err := os.MkdirTemp(base, fmt.Sprintf("%s-%d-", name, os.Getpid()))
+	if err != nil {
+		return nil, types.WrapError(err, "failed to create workspace")
+	}
+	return &Workspace{Dir: dir}, nil
+}
+
+// Remove deletes the workspace and everything in it
+func (w *Workspace) Remove() error {
+	return os.RemoveAll(w.Dir)
+}
+
+// PruneWorkspaces deletes the workspaces of processes that are no longer
+// running, e.g. interrupted before they could clean up, and returns them
+func PruneWorkspaces() ([]string, error) {
+	base, err := workspacesBase()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, types.WrapError(err, "failed to read workspace directory")
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if pid, ok := workspacePID(entry.Name()); ok && processAlive(pid) {
+			continue
+		}
+		path := filepath.Join(base, entry.Name())
+		if err := os.RemoveAll(path); err != nil {
+			return removed, types.WrapError(err, "failed to remove workspace")
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// workspacePID returns the PID in a workspace name of the form name-pid-random
+func workspacePID(name string) (int, bool) {
+	parts := strings.Split(name, "-")
+	if len(parts) < 3 {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(parts[len(parts)-2])
+	return pid, err == nil
+}
+
+// workspacesBase returns the directory holding the workspaces
+func workspacesBase() (string, error) {
+	dir, err := packagesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, workspacesDir), nil
+}
+
+// packagesDir returns Storage.PackagesDir with ~ expanded
+func packagesDir() (string, error) {
+	dir := GlobalConfig.Storage.PackagesDir
+	if strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home dir: %w", err)
+		}
+		dir = filepath.Join(home, dir[2:])
+	}
+	return dir, nil
+}
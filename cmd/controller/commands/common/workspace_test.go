@@ -0,0 +1,52 @@
+package common_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+)
+
+func TestPruneWorkspaces(t *testing.T) {
+	packagesDir := t.TempDir()
+	saved := common.GlobalConfig
+	common.GlobalConfig = &config.ControllerConfig{Storage: config.StorageConfig{PackagesDir: packagesDir}}
+	t.Cleanup(func() { common.GlobalConfig = saved })
+
+	ws, err := common.NewWorkspace("run")
+	if err != nil {
+		t.Fatalf("NewWorkspace() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(ws.Dir, "app-1.0.0.tar.gz"), []byte("pkg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Left behind by a process that is gone, and by an unknown tool
+	stale := filepath.Join(filepath.Dir(ws.Dir), "run-999999999-123")
+	other := filepath.Join(filepath.Dir(ws.Dir), "leftover")
+	for _, dir := range []string{stale, other} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := common.PruneWorkspaces()
+	if err != nil {
+		t.Fatalf("PruneWorkspaces() error = %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("PruneWorkspaces() removed %v, want the stale and unknown directories", removed)
+	}
+	if _, err := os.Stat(ws.Dir); err != nil {
+		t.Errorf("workspace of a running process was removed: %v", err)
+	}
+
+	if err := ws.Remove(); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(ws.Dir); !os.IsNotExist(err) {
+		t.Errorf("workspace still exists after Remove()")
+	}
+}
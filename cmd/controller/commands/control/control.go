@@ -0,0 +1,87 @@
+package control
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// controlResult is the structured result of a pause or resume
+type controlResult struct {
+	AppID  string              `json:"app_id"`
+	Action types.AppAction     `json:"action"`
+	Status types.AppStatusType `json:"status"`
+	PID    int                 `json:"pid,omitempty"`
+}
+
+// PauseCmd represents the pause command
+var PauseCmd = newCmd(types.AppActionPause, "Suspend a running app, keeping its process state",
+	`Suspend a running application on a node, keeping the state of its process,
+e.g. to look into resource contention. The app keeps its memory but gets no
+CPU time until it is resumed. Its health is not checked meanwhile, so it is
+not restarted for failing its health check. Stopping a paused app resumes it
+first.
+
+The exec backend stops the main process of the app with SIGSTOP; processes it
+started keep running. The systemd backend freezes the cgroup of the app,
+every process included.
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is used, or else the only node discovered.
+
+Example:
+  controller pause web-1.0.0 --node 12D3KooW...`)
+
+// ResumeCmd represents the resume command
+var ResumeCmd = newCmd(types.AppActionResume, "Continue a paused app",
+	`Continue an application paused with 'controller pause' on a node, and
+check its health again.
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is used, or else the only node discovered.
+
+Example:
+  controller resume web-1.0.0 --node 12D3KooW...`)
+
+// newCmd returns the command applying action to an app
+func newCmd(action types.AppAction, short, long string) *cobra.Command {
+	var selection common.Selection
+	cmd := &cobra.Command{
+		Use:   string(action) + " <app-id>",
+		Short: short,
+		Long:  long,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := common.Out
+			appID := args[0]
+
+			// Create P2P host using configuration
+			ctx := context.Background()
+			host, err := common.CreateP2PHost(ctx)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = host.Close() }()
+
+			targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+			if err != nil {
+				return err
+			}
+
+			app, err := common.ControlApp(ctx, host, targetPeerID, appID, action, common.GlobalLogger)
+			if err != nil {
+				return fmt.Errorf("failed to %s %s: %w", action, appID, err)
+			}
+
+			result := controlResult{AppID: app.ID, Action: action, Status: app.Status, PID: app.PID}
+			return out.Result(result, func() {
+				out.Printf("✓ %s is %s (pid %d)\n", app.ID, app.Status, app.PID)
+			})
+		},
+	}
+	common.AddSelectionFlags(cmd.Flags(), &selection, false)
+	return cmd
+}
@@ -0,0 +1,180 @@
+package coredump
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	pkgcoredump "github.com/asjdf/p2p-playground-lite/pkg/coredump"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+	filePath  string
+)
+
+// dumpsResult is the structured result of listing core dumps
+type dumpsResult struct {
+	AppID string             `json:"app_id"`
+	Dumps []pkgcoredump.Dump `json:"dumps"`
+}
+
+// fetchResult is the structured result of fetching a core dump
+type fetchResult struct {
+	AppID string           `json:"app_id"`
+	Dump  pkgcoredump.Dump `json:"dump"`
+	File  string           `json:"file"`
+}
+
+// Cmd represents the coredump command
+var Cmd = &cobra.Command{
+	Use:   "coredump",
+	Short: "List and fetch the core dumps of crashed apps",
+	Long: `List and fetch the core dumps apps left on a node when they crashed with a
+signal, for offline analysis, e.g. with gdb.
+
+An app dumps core only if its manifest sets core_dumps: true. The node lifts
+the core file size limit of its processes, and collects the dumps the kernel
+writes in the working directory of the app, keeping the 3 newest. This takes
+a core pattern (/proc/sys/kernel/core_pattern) naming a file in the working
+directory of the crashed process, e.g. "core" or "core.%p"; nodes piping core
+dumps to a program such as systemd-coredump keep them there instead. The
+exited event of a crash names the core dump it left ('controller events').
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is used, or else the only node discovered.`,
+}
+
+// listCmd lists the core dumps of an app
+var listCmd = &cobra.Command{
+	Use:   "list <app-id>",
+	Short: "List the core dumps of an app, newest first",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		appID := args[0]
+		var dumps []pkgcoredump.Dump
+		err := withNode(func(ctx context.Context, host *p2p.Host, peerID string) (err error) {
+			dumps, err = common.ListCoreDumps(ctx, host, peerID, appID, common.GlobalLogger)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list core dumps of %s: %w", appID, err)
+		}
+		if dumps == nil {
+			dumps = []pkgcoredump.Dump{}
+		}
+
+		out := common.Out
+		return out.Result(dumpsResult{AppID: appID, Dumps: dumps}, func() {
+			if len(dumps) == 0 {
+				out.Printf("%s left no core dumps\n", appID)
+				return
+			}
+			for _, dump := range dumps {
+				out.Printf("%s  %10d bytes  %s\n", dump.Time.Local().Format("2006-01-02 15:04:05"), dump.Size, dump.Name)
+			}
+		})
+	},
+}
+
+// fetchCmd fetches a core dump of an app
+var fetchCmd = &cobra.Command{
+	Use:   "fetch <app-id> [name]",
+	Short: "Fetch a core dump of an app, the newest by default",
+	Long: `Fetch a core dump of an app into a local file, the newest one unless name
+is given. The file is --file, by default <app-id>.<name> in the current
+directory.
+
+Example:
+  controller coredump fetch web-1.0.0 --node 12D3KooW...
+  controller coredump fetch web-1.0.0 core.4242 --file /tmp/web.core`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		appID := args[0]
+		var dump *pkgcoredump.Dump
+		var path string
+		err := withNode(func(ctx context.Context, host *p2p.Host, peerID string) error {
+			name := ""
+			if len(args) == 2 {
+				name = args[1]
+			} else {
+				dumps, err := common.ListCoreDumps(ctx, host, peerID, appID, common.GlobalLogger)
+				if err != nil {
+					return err
+				}
+				if len(dumps) == 0 {
+					return fmt.Errorf("%s left no core dumps", appID)
+				}
+				name = dumps[0].Name
+			}
+
+			path = filePath
+			if path == "" {
+				path = appID + "." + name
+			}
+			var err error
+			dump, err = fetch(ctx, host, peerID, appID, name, path)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch core dump of %s: %w", appID, err)
+		}
+
+		out := common.Out
+		return out.Result(fetchResult{AppID: appID, Dump: *dump, File: path}, func() {
+			out.Printf("✓ Fetched %s (%d bytes) to %s\n", dump.Name, dump.Size, path)
+		})
+	},
+}
+
+// fetch writes a core dump to path, through a temporary file so that an
+// interrupted transfer leaves no partial dump
+func fetch(ctx context.Context, host *p2p.Host, peerID, appID, name, path string) (*pkgcoredump.Dump, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	common.Out.Statusf("Fetching %s of %s...\n", name, appID)
+	dump, err := common.FetchCoreDump(ctx, host, peerID, appID, name, f, common.GlobalLogger)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write file: %w", closeErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	return dump, nil
+}
+
+// withNode runs fn with a host connected to the selected node
+func withNode(fn func(ctx context.Context, host *p2p.Host, peerID string) error) error {
+	// Create P2P host using configuration
+	ctx := context.Background()
+	host, err := common.CreateP2PHost(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = host.Close() }()
+
+	targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+	if err != nil {
+		return err
+	}
+	return fn(ctx, host, targetPeerID)
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.PersistentFlags(), &selection, false)
+	fetchCmd.Flags().StringVarP(&filePath, "file", "f", "", "write the core dump to this file (default: <app-id>.<name>)")
+
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(fetchCmd)
+}
@@ -4,28 +4,123 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
 	"github.com/spf13/cobra"
 )
 
 var (
-	nodeID    string
-	autoStart bool
+	selection   common.Selection
+	autoStart   bool
+	dryRun      bool
+	forceUnlock bool
+	waitHealthy bool
+	timeout     time.Duration
+	reportPath  string
+	privateKey  string
+	overrides   common.Overrides
+	queueOpts   common.QueueOptions
 )
 
+// deployResult is the structured result of a deployment
+type deployResult struct {
+	NodeID  string `json:"node_id"`
+	AppID   string `json:"app_id"`
+	Started bool   `json:"started"`
+	Healthy bool   `json:"healthy,omitempty"`
+	Route   string `json:"route,omitempty"` // How the package was sent, e.g. direct or relayed
+}
+
 // Cmd represents the deploy command
 var Cmd = &cobra.Command{
-	Use:   "deploy [package]",
+	Use:   "deploy [package | app-directory]",
 	Short: "Deploy an application package",
 	Long: `Deploy an application package to a target node.
 
-If --node is not specified, the package will be deployed to the first discovered node.`,
+Given an app directory, the package is built from it first, signed with
+--private-key if given. While the sources and key are unchanged, the package
+cached by an earlier run or deploy is reused (see 'controller cache ls').
+
+--node takes the peer ID or name of the target node. If it is not specified,
+the package is deployed to the local daemon, or else to the only node
+discovered; an app that needs devices is placed on a node providing them.
+--selector deploys to every node with the given labels and --all to every node
+discovered, up to --concurrency nodes at a time (default: deployment.concurrency),
+each reported as it finishes.
+
+Attempts that fail to reach a node, time out, or find the app locked are
+retried up to --retries times with exponential backoff; a node rejecting the
+package is not retried.
+
+With --dry-run, the package is validated and the target node is checked, and the
+planned actions are reported without transferring or starting anything.
+
+The node locks the app while deploying it, so a concurrent deployment of the
+same app fails with "locked by deploy ... since ...". If an operation is stuck
+holding the lock, --force-unlock breaks it (use with care).
+
+With --wait-healthy, the command succeeds only once the app passes its health
+check on the node (or, without one, keeps running for a moment). If it does not
+within --timeout, the node rolls back to the previous version and the command
+fails with the health check output and exit code 6, which makes it suitable for
+gating CI pipelines. Without it, the node only checks that the started app
+keeps running for a moment, rolling back to the previous version if it does
+not. With "on_failure: leave" in the manifest, a failed version is left in
+place for inspection instead.
+
+With --report, a JSON summary of the deployment (package checksum, per-node
+result, durations, health outcome) is written to the given file, also when the
+deployment fails, for uploading as a CI artifact.
+
+When the deployment fails on some of several nodes, it is kept with a copy of
+the package under an operation ID, also recorded in the report, and
+'controller retry <operation-id>' deploys to just the failed nodes again.
+
+--set and --set-args patch the manifest for this deployment without editing
+manifest.yaml, e.g. --set env.FOO=bar --set resources.memory_mb=256. The
+package is then built anew, and a package is re-signed only with --private-key.`,
 	Args: cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		packagePath := args[0]
-		fmt.Printf("Deploying package: %s\n", packagePath)
+		out := common.Out
+		out.Statusf("Deploying package: %s\n", packagePath)
+
+		report := common.NewDeployReport("deploy")
+		report.DryRun = dryRun
+		if reportPath != "" {
+			defer func() {
+				report.Finish(err)
+				if writeErr := report.Write(reportPath); writeErr != nil && err == nil {
+					err = writeErr
+				}
+			}()
+		}
+
+		ctx := context.Background()
+
+		// Overrides are applied to a copy of the app, packed anew. Otherwise an
+		// app directory is packed first, reusing the package cached while its
+		// sources are unchanged.
+		if !overrides.IsEmpty() {
+			var cleanup func()
+			packagePath, cleanup, err = buildPatchedPackage(ctx, packagePath)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+		} else if info, err := os.Stat(packagePath); err == nil && info.IsDir() {
+			var cleanup func()
+			packagePath, cleanup, err = buildPackage(ctx, packagePath)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+		}
 
 		// Check if file exists
 		fileInfo, err := os.Stat(packagePath)
@@ -33,55 +128,274 @@ If --node is not specified, the package will be deployed to the first discovered
 			return fmt.Errorf("failed to access package file: %w", err)
 		}
 
+		manifest, err := pkgmanager.New().GetManifest(ctx, packagePath)
+		if err != nil {
+			return fmt.Errorf("failed to read manifest: %w", err)
+		}
+		if reportPath != "" {
+			if err := report.SetPackage(packagePath, manifest); err != nil {
+				return err
+			}
+		}
+
+		// Pre-flight policy check before contacting any node (a dry run reports violations instead)
+		if !dryRun {
+			if err := common.CheckPolicy(manifest, fileExists(packagePath+".sig"), nil); err != nil {
+				return err
+			}
+		}
+
 		// Create P2P host using configuration
-		ctx := context.Background()
 		host, err := common.CreateP2PHost(ctx)
 		if err != nil {
 			return err
 		}
 		defer func() { _ = host.Close() }()
 
-		fmt.Printf("Controller ID: %s\n", host.ID())
-
-		// Wait for peer discovery
-		fmt.Println("Discovering nodes...")
-		time.Sleep(3 * time.Second)
-
-		// Get target node
-		var targetPeerID string
-		if nodeID != "" {
-			targetPeerID = nodeID
-			fmt.Printf("Using specified node: %s\n", targetPeerID)
-		} else {
-			// Use first discovered peer
-			peers := host.Peers()
-			if len(peers) == 0 {
-				return fmt.Errorf("no nodes discovered")
+		out.Statusf("Controller ID: %s\n", host.ID())
+
+		// Without a selection, place the app on a node providing the devices it needs
+		placing := selection.IsEmpty() && len(manifest.Devices) > 0
+		sel := selection
+		if placing {
+			sel.All = true
+		}
+		targets, err := common.ResolveNodes(ctx, host, &sel)
+		if err != nil {
+			return err
+		}
+		if placing {
+			capable, err := common.CapableNodes(ctx, host, targets, manifest, common.GlobalLogger)
+			if err != nil {
+				return err
 			}
-			targetPeerID = peers[0].ID
-			fmt.Printf("Using discovered node: %s\n", targetPeerID)
+			targets = capable[:1]
+			out.Statusf("Placing on node: %s\n", targets[0])
+		} else if sel.Multiple() && common.Restricted(manifest) {
+			// Skip the selected nodes that cannot run the app
+			capable, err := common.CapableNodes(ctx, host, targets, manifest, common.GlobalLogger)
+			if err != nil {
+				return err
+			}
+			if skipped := len(targets) - len(capable); skipped > 0 {
+				out.Statusf("Skipping %d node(s) that cannot run the app\n", skipped)
+			}
+			targets = capable
 		}
 
-		// Deploy package
-		fmt.Println("\nDeploying package...")
-		appID, err := common.DeployPackage(ctx, host, targetPeerID, packagePath, fileInfo.Size(), autoStart, common.GlobalLogger)
-		if err != nil {
-			return fmt.Errorf("deployment failed: %w", err)
+		if dryRun {
+			return dryRunDeploy(ctx, host, targets, packagePath, fileInfo.Size())
 		}
 
-		fmt.Printf("\n✓ Deployment successful!\n")
-		fmt.Printf("  Application ID: %s\n", appID)
-		if autoStart {
-			fmt.Printf("  Status: Started\n")
-		} else {
-			fmt.Printf("  Status: Deployed (not started)\n")
+		// Deploy package
+		out.Statusln("\nDeploying package...")
+		queue := queueOpts.WithDefaults(&common.GlobalConfig.Deployment)
+		opts := common.DeployOptions{
+			AutoStart:     autoStart,
+			ForceUnlock:   forceUnlock,
+			WaitHealthy:   waitHealthy,
+			HealthTimeout: timeout,
+			Quiet:         queue.Parallel(len(targets)),
+		}
+		nodeResults := common.RunQueue(ctx, host, targets, queue, func(ctx context.Context, nodeID string) (string, error) {
+			return common.DeployPackage(ctx, host, nodeID, packagePath, fileInfo.Size(), opts, common.GlobalLogger)
+		})
+		results := make([]deployResult, 0, len(targets))
+		var failed []error
+		for _, res := range nodeResults {
+			report.AddResult(res, opts)
+			if res.Err != nil {
+				if !selection.Multiple() {
+					return fmt.Errorf("deployment failed: %w", res.Err)
+				}
+				failed = append(failed, res.Err)
+				continue
+			}
+			targetPeerID := res.NodeID
+			result := deployResult{
+				NodeID:  targetPeerID,
+				AppID:   res.Value,
+				Started: autoStart,
+				Healthy: autoStart && waitHealthy,
+			}
+			if route := host.Route(targetPeerID); route != p2p.RouteNone {
+				result.Route = string(route)
+			}
+			results = append(results, result)
+		}
+		var op *common.Operation
+		if len(failed) > 0 {
+			op, err = common.RecordOperation(report, packagePath, manifest, opts, queue)
+			if err != nil {
+				common.GlobalLogger.Warn("failed to keep the deployment for retrying", "error", err)
+			}
 		}
 
+		var value interface{} = results
+		if !selection.Multiple() {
+			value = results[0]
+		}
+		err = out.Result(value, func() {
+			for _, result := range results {
+				if selection.Multiple() {
+					out.Printf("\n✓ Deployed to %s\n", result.NodeID)
+				} else {
+					out.Printf("\n✓ Deployment successful!\n")
+				}
+				out.Printf("  Application ID: %s\n", result.AppID)
+				if result.Healthy {
+					out.Printf("  Status: Started and healthy\n")
+				} else if autoStart {
+					out.Printf("  Status: Started\n")
+				} else {
+					out.Printf("  Status: Deployed (not started)\n")
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			if op != nil {
+				out.Statusf("\nRetry the %d failed node(s) with: controller retry %s\n", len(failed), op.ID)
+			}
+			// Keep the exit code of the first failure, e.g. an unhealthy app
+			return fmt.Errorf("deployment failed on %d of %d node(s): %w", len(failed), len(targets), failed[0])
+		}
 		return nil
 	},
 }
 
+// loadSigner loads the --private-key, or returns nil without one
+func loadSigner() (*security.Signer, error) {
+	if privateKey == "" {
+		return nil, nil
+	}
+	signer, err := security.LoadSigner(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key: %w", err)
+	}
+	return signer, nil
+}
+
+// buildPatchedPackage packs an app directory or package with the --set
+// overrides applied to its manifest, signed with --private-key if given, and
+// returns the package and a function removing it
+func buildPatchedPackage(ctx context.Context, path string) (string, func(), error) {
+	out := common.Out
+	signer, err := loadSigner()
+	if err != nil {
+		return "", nil, err
+	}
+
+	ws, err := common.NewWorkspace("deploy")
+	if err != nil {
+		return "", nil, err
+	}
+	appDir := filepath.Join(ws.Dir, "app")
+	if _, err := overrides.Stage(ctx, path, appDir); err != nil {
+		_ = ws.Remove()
+		return "", nil, fmt.Errorf("failed to apply overrides: %w", err)
+	}
+	pkgPath, err := common.BuildPackage(ctx, appDir, ws.Dir, signer)
+	if err != nil {
+		_ = ws.Remove()
+		return "", nil, fmt.Errorf("failed to build package: %w", err)
+	}
+	out.Statusf("Package created with overrides: %s\n", pkgPath)
+	if signer == nil && fileExists(path+".sig") {
+		out.Statusln("The signature of the original package does not cover the overrides; pass --private-key to sign the new one")
+	}
+	return pkgPath, func() { _ = ws.Remove() }, nil
+}
+
+// buildPackage packs an app directory, signed with --private-key if given,
+// and returns the package and a function removing it unless it is cached
+func buildPackage(ctx context.Context, appDir string) (string, func(), error) {
+	out := common.Out
+	signer, err := loadSigner()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if common.GlobalConfig.PackageCache.Disabled {
+		ws, err := common.NewWorkspace("deploy")
+		if err != nil {
+			return "", nil, err
+		}
+		pkgPath, err := common.BuildPackage(ctx, appDir, ws.Dir, signer)
+		if err != nil {
+			_ = ws.Remove()
+			return "", nil, fmt.Errorf("failed to build package: %w", err)
+		}
+		out.Statusf("Package created: %s\n", pkgPath)
+		return pkgPath, func() { _ = ws.Remove() }, nil
+	}
+
+	cache, err := common.OpenPackageCache()
+	if err != nil {
+		return "", nil, err
+	}
+	pkg, hit, err := cache.Build(ctx, appDir, signer)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to build package: %w", err)
+	}
+	if hit {
+		out.Statusf("Sources unchanged, reusing cached package: %s\n", pkg.Path)
+	} else {
+		out.Statusf("Package created: %s\n", pkg.Path)
+	}
+	return pkg.Path, func() {}, nil
+}
+
+// dryRunDeploy validates the package and reports what deploying it would do
+func dryRunDeploy(ctx context.Context, host *p2p.Host, targets []string, packagePath string, size int64) error {
+	common.Out.Statusln("\nValidating package (dry run)...")
+
+	pkgMgr := pkgmanager.New()
+	manifest, err := pkgMgr.GetManifest(ctx, packagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if err := pkgmanager.ValidateManifest(manifest); err != nil {
+		return err
+	}
+
+	checksum, err := pkgMgr.CalculateChecksum(packagePath)
+	if err != nil {
+		return err
+	}
+
+	signed := fileExists(packagePath + ".sig")
+
+	report := &common.DryRunReport{
+		Package:     packagePath,
+		PackageSize: size,
+		Checksum:    checksum,
+		Signed:      signed,
+		Manifest:    manifest,
+		AutoStart:   autoStart,
+		Violations:  common.PolicyViolations(manifest, signed, nil),
+		Nodes:       common.PlanDeployment(ctx, host, targets, manifest, common.GlobalLogger),
+	}
+	return common.PrintDryRun(report)
+}
+
+// fileExists returns true if a file exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
 func init() {
-	Cmd.Flags().StringVar(&nodeID, "node", "", "target node peer ID")
+	common.AddSelectionFlags(Cmd.Flags(), &selection, true)
 	Cmd.Flags().BoolVar(&autoStart, "start", true, "automatically start the application after deployment")
+	Cmd.Flags().StringVar(&privateKey, "private-key", "", "path to private key file for signing a package built from an app directory")
+	Cmd.Flags().BoolVar(&dryRun, "dry-run", false, "validate and report what would be deployed without transferring anything")
+	Cmd.Flags().BoolVar(&forceUnlock, "force-unlock", false, "break the node's lock on the app held by another operation (admin escape hatch)")
+	Cmd.Flags().BoolVar(&waitHealthy, "wait-healthy", false, "report success only once the app passes its health check on the node")
+	Cmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "how long --wait-healthy waits for the app to become healthy")
+	Cmd.Flags().StringVar(&reportPath, "report", "", "write a JSON summary of the deployment to this file")
+	common.AddOverrideFlags(Cmd.Flags(), &overrides)
+	common.AddQueueFlags(Cmd.Flags(), &queueOpts)
 }
@@ -0,0 +1,400 @@
+package describe
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/events"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	appSelection common.Selection
+	appLookback  time.Duration
+	logTail      int
+)
+
+// appReport is the structured result of describe app
+type appReport struct {
+	Name string `json:"name"`
+
+	// Manifest is that of the most recently started instance
+	Manifest *types.Manifest `json:"manifest,omitempty"`
+
+	Nodes    []*appNode `json:"nodes"`
+	Warnings []string   `json:"warnings,omitempty"`
+}
+
+// appNode is what a node reports about an app
+type appNode struct {
+	NodeID string `json:"node_id"`
+
+	// Status is that of the current instance: the one running, or else the
+	// most recently started
+	Status *types.AppStatus `json:"status,omitempty"`
+
+	// Deployments are the versions of the app on the node, newest first
+	Deployments []*types.Application `json:"deployments,omitempty"`
+
+	// Events are the lifecycle events of any version, oldest first
+	Events []*events.Event `json:"events,omitempty"`
+
+	// Restarts are the exits of the app process that were not requested, oldest first
+	Restarts []*events.Event `json:"restarts,omitempty"`
+
+	// Logs are the last lines logged by the current instance
+	Logs []string `json:"logs,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// appCmd describes an app across the nodes running it
+var appCmd = &cobra.Command{
+	Use:   "app <name>",
+	Short: "Show a detailed report of an app on every node running it",
+	Long: `Show everything known about an app in one place: its manifest and, for
+every node it is deployed to, the versions deployed, the current status,
+health and resource usage, the restarts and lifecycle events over the
+--history period, and the last lines it logged.
+
+The app is given by name, or by the ID of one version. Every node
+discovered is asked unless --node, --selector or --all is given.
+
+Example:
+  controller describe app web
+  controller describe app web --selector env=lab --history 24h --tail 50`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		// An app may run anywhere, so ask every node unless told otherwise
+		sel := appSelection
+		if sel.IsEmpty() {
+			sel.All = true
+		}
+		nodeIDs, err := common.ResolveNodes(ctx, host, &sel)
+		if err != nil {
+			return err
+		}
+
+		report := &appReport{Name: name, Nodes: []*appNode{}}
+		var latest time.Time
+		for _, id := range nodeIDs {
+			node, err := describeAppOn(ctx, host, id, name)
+			if err != nil {
+				report.Nodes = append(report.Nodes, &appNode{NodeID: id, Error: err.Error()})
+				report.Warnings = append(report.Warnings, fmt.Sprintf("node %s could not be asked: %v", id, err))
+				continue
+			}
+			if node == nil {
+				continue
+			}
+			report.Nodes = append(report.Nodes, node)
+			if app := node.Status.App; report.Manifest == nil || app.StartedAt.After(latest) {
+				report.Name = app.Name
+				report.Manifest = app.Manifest
+				latest = app.StartedAt
+			}
+		}
+		if report.Manifest == nil && len(report.Warnings) == 0 {
+			return fmt.Errorf("%w: application %s on %d node(s)", types.ErrNotFound, name, len(nodeIDs))
+		}
+		report.Warnings = append(report.Warnings, appWarnings(report)...)
+
+		return out.Result(report, func() {
+			printApp(report)
+		})
+	},
+}
+
+// describeAppOn gathers what node peerID reports about the app called name,
+// or returns nil if the app is not deployed there
+func describeAppOn(ctx context.Context, host *p2p.Host, peerID string, name string) (*appNode, error) {
+	statuses, err := common.ListAppStatuses(ctx, host, peerID, common.GlobalLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &appNode{NodeID: peerID}
+	ids := make(map[string]bool)
+	for _, status := range statuses {
+		if status.App.Name != name && status.App.ID != name {
+			continue
+		}
+		ids[status.App.ID] = true
+		node.Deployments = append(node.Deployments, status.App)
+		if node.Status == nil || current(status.App, node.Status.App) {
+			node.Status = status
+		}
+	}
+	if node.Status == nil {
+		return nil, nil
+	}
+	// Naming a version describes the app it is a version of
+	if node.Status.App.ID == name {
+		return describeAppOn(ctx, host, peerID, node.Status.App.Name)
+	}
+	sort.Slice(node.Deployments, func(i, j int) bool {
+		return node.Deployments[i].StartedAt.After(node.Deployments[j].StartedAt)
+	})
+
+	// The rest only adds detail to the status
+	if evts, err := common.FetchEvents(ctx, host, peerID, common.EventsRequest{Since: appLookback}, common.GlobalLogger); err == nil {
+		for _, e := range evts {
+			if ids[e.AppID] {
+				node.Events = append(node.Events, e)
+			}
+		}
+		node.Restarts = restarts(node.Events)
+	} else {
+		node.Error = fmt.Sprintf("events unavailable: %v", err)
+	}
+	if logTail > 0 {
+		logs, err := common.FetchLogs(ctx, host, peerID, node.Status.App.ID, common.LogOptions{Tail: logTail}, common.GlobalLogger)
+		if err == nil {
+			node.Logs = strings.Split(strings.TrimRight(logs, "\n"), "\n")
+			if len(node.Logs) == 1 && node.Logs[0] == "" {
+				node.Logs = nil
+			}
+		} else if node.Error == "" {
+			node.Error = fmt.Sprintf("logs unavailable: %v", err)
+		}
+	}
+	return node, nil
+}
+
+// restarts returns the exits among events, oldest first, that were not
+// requested by stopping the app
+func restarts(evts []*events.Event) []*events.Event {
+	var exits []*events.Event
+	stopping := make(map[string]bool)
+	for _, e := range evts {
+		switch e.Type {
+		case types.AppEventStopped:
+			stopping[e.AppID] = true
+		case types.AppEventStarted:
+			stopping[e.AppID] = false
+		case types.AppEventExited:
+			if !stopping[e.AppID] {
+				exits = append(exits, e)
+			}
+		}
+	}
+	return exits
+}
+
+// current reports whether a is more current than b: running, or else started later
+func current(a, b *types.Application) bool {
+	if (a.Status == types.AppStatusRunning) != (b.Status == types.AppStatusRunning) {
+		return a.Status == types.AppStatusRunning
+	}
+	return a.StartedAt.After(b.StartedAt)
+}
+
+// appWarnings lists what needs attention about the app described by report
+func appWarnings(report *appReport) []string {
+	var warns []string
+	versions := make(map[string]bool)
+	for _, node := range report.Nodes {
+		if node.Status == nil {
+			continue
+		}
+		app := node.Status.App
+		versions[app.Version] = true
+		switch {
+		case app.Status == types.AppStatusFailed:
+			warns = append(warns, fmt.Sprintf("%s failed on %s: %s", app.ID, node.NodeID, node.Status.Message))
+		case app.Status == types.AppStatusRunning && !node.Status.Healthy:
+			warns = append(warns, fmt.Sprintf("%s is unhealthy on %s: %s", app.ID, node.NodeID, node.Status.Message))
+		}
+		if len(node.Restarts) > 0 {
+			warns = append(warns, fmt.Sprintf("%s exited %d time(s) on %s in the last %s", app.Name, len(node.Restarts), node.NodeID, appLookback))
+		}
+		if usage := node.Status.ResourceUsage; app.Manifest != nil && app.Manifest.Resources != nil && usage != nil &&
+			app.Manifest.Resources.MemoryMB > 0 && usage.MemoryMB*100 >= app.Manifest.Resources.MemoryMB*nearLimitPercent {
+			limits := app.Manifest.Resources
+			warns = append(warns, fmt.Sprintf("%s uses %d of its %d MB memory limit on %s", app.ID, usage.MemoryMB, limits.MemoryMB, node.NodeID))
+		}
+		if node.Error != "" {
+			warns = append(warns, fmt.Sprintf("%s on %s: %s", app.ID, node.NodeID, node.Error))
+		}
+	}
+	if len(versions) > 1 {
+		list := make([]string, 0, len(versions))
+		for v := range versions {
+			list = append(list, v)
+		}
+		sort.Strings(list)
+		warns = append(warns, "nodes run different versions: "+strings.Join(list, ", "))
+	}
+	return warns
+}
+
+// printApp prints report in the style of "kubectl describe"
+func printApp(report *appReport) {
+	out := common.Out
+
+	out.Printf("Name:         %s\n", report.Name)
+	if m := report.Manifest; m != nil {
+		out.Printf("Version:      %s\n", m.Version)
+		if m.Description != "" {
+			out.Printf("Description:  %s\n", m.Description)
+		}
+		out.Printf("Entrypoint:   %s\n", strings.Join(append([]string{m.Entrypoint}, m.Args...), " "))
+		out.Printf("Labels:       %s\n", formatLabels(m.Labels))
+		if len(m.Env) > 0 {
+			// Values may be secrets
+			keys := make([]string, 0, len(m.Env))
+			for k := range m.Env {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			out.Printf("Environment:  %s\n", strings.Join(keys, ", "))
+		}
+		if r := m.Resources; r != nil {
+			out.Printf("Resources:    %s\n", formatLimits(r))
+		}
+		if hc := m.HealthCheck; hc != nil {
+			check := hc.Type
+			if hc.Endpoint != "" {
+				check += " " + hc.Endpoint
+			}
+			if hc.Interval > 0 {
+				check += fmt.Sprintf(" every %s", hc.Interval)
+			}
+			out.Printf("Health check: %s\n", check)
+		}
+	}
+
+	out.Printf("Nodes (%d):\n", len(report.Nodes))
+	for _, node := range report.Nodes {
+		out.Printf("\n=== Node %s ===\n", node.NodeID)
+		if node.Status == nil {
+			out.Printf("  Error: %s\n", node.Error)
+			continue
+		}
+		printAppNode(node)
+	}
+
+	out.Println("\nWarnings:")
+	if len(report.Warnings) == 0 {
+		out.Println("  <none>")
+	}
+	for _, warn := range report.Warnings {
+		out.Printf("  ! %s\n", warn)
+	}
+}
+
+// printAppNode prints what a node reports about the app
+func printAppNode(node *appNode) {
+	out := common.Out
+	status := node.Status
+	app := status.App
+
+	out.Printf("  Instance:   %s\n", app.ID)
+	if app.Revision > 0 {
+		out.Printf("  Revision:   %d\n", app.Revision)
+	}
+	line := string(app.Status)
+	if app.PID > 0 {
+		line += fmt.Sprintf(", pid %d", app.PID)
+	}
+	if !app.StartedAt.IsZero() {
+		line += fmt.Sprintf(", started %s", app.StartedAt.Local().Format("2006-01-02 15:04:05"))
+		if app.Status == types.AppStatusRunning {
+			line += fmt.Sprintf(" (up %s)", time.Since(app.StartedAt).Round(time.Second))
+		}
+	}
+	out.Printf("  Status:     %s\n", line)
+	if app.Initiator != nil {
+		out.Printf("  By:         %s\n", app.Initiator)
+	}
+	if len(app.Ports) > 0 {
+		out.Printf("  Ports:      %s\n", common.FormatPorts(app.Ports))
+	}
+
+	health := "unhealthy"
+	if status.Healthy {
+		health = "healthy"
+	}
+	if status.Message != "" {
+		health += ": " + status.Message
+	}
+	if !status.LastHealthCheck.IsZero() {
+		health += fmt.Sprintf(" (checked %s)", status.LastHealthCheck.Local().Format("15:04:05"))
+	}
+	out.Printf("  Health:     %s\n", health)
+	if r := status.Reported; r != nil {
+		out.Printf("  Reported:   %s\n", r.Status)
+	}
+	if u := status.ResourceUsage; u != nil {
+		out.Printf("  Usage:      cpu %.1f%%, memory %d MB\n", u.CPUPercent, u.MemoryMB)
+	}
+	if len(status.Metrics) > 0 {
+		out.Printf("  Metrics:    %s\n", common.FormatMetrics(status.Metrics))
+	}
+	out.Printf("  Restarts:   %d in the last %s\n", len(node.Restarts), appLookback)
+
+	out.Println("  Deployments:")
+	for _, d := range node.Deployments {
+		started := "-"
+		if !d.StartedAt.IsZero() {
+			started = d.StartedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		out.Printf("    %-30s  %-10s  %s\n", d.ID, d.Status, started)
+	}
+
+	out.Printf("  Events (last %s):\n", appLookback)
+	if len(node.Events) == 0 {
+		out.Println("    <none>")
+	}
+	for _, e := range node.Events {
+		out.Printf("    %s  %-30s  %-10s  %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.AppID, e.Type, e.Message)
+	}
+
+	if logTail > 0 {
+		out.Printf("  Logs (last %d lines):\n", logTail)
+		if len(node.Logs) == 0 {
+			out.Println("    <none>")
+		}
+		for _, l := range node.Logs {
+			out.Printf("    %s\n", l)
+		}
+	}
+}
+
+// formatLimits formats the resource limits of a manifest
+func formatLimits(r *types.ResourceLimits) string {
+	var limits []string
+	if r.CPUPercent > 0 {
+		limits = append(limits, fmt.Sprintf("cpu %.0f%%", r.CPUPercent))
+	}
+	if r.MemoryMB > 0 {
+		limits = append(limits, fmt.Sprintf("memory %d MB", r.MemoryMB))
+	}
+	if len(limits) == 0 {
+		return "<none>"
+	}
+	return strings.Join(limits, ", ")
+}
+
+func init() {
+	common.AddSelectionFlags(appCmd.Flags(), &appSelection, true)
+	appCmd.Flags().DurationVar(&appLookback, "history", 24*time.Hour, "show the events and restarts of this period")
+	appCmd.Flags().IntVar(&logTail, "tail", 20, "show this many of the last log lines of each instance (0 for none)")
+}
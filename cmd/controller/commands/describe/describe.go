@@ -0,0 +1,18 @@
+package describe
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// Cmd represents the describe command
+var Cmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Show a detailed report of a node or app",
+	Long: `Show a detailed, human-friendly report of a node or an app, assembled from
+what the nodes report about themselves and their apps over several protocols.`,
+}
+
+func init() {
+	Cmd.AddCommand(nodeCmd)
+	Cmd.AddCommand(appCmd)
+}
@@ -0,0 +1,416 @@
+package describe
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/events"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/spf13/cobra"
+)
+
+// pingTimeout bounds measuring the round trip time to the node
+const pingTimeout = 5 * time.Second
+
+// nearLimitPercent is the share of a limit in use above which a warning is given
+const nearLimitPercent = 90
+
+var (
+	eventsSince time.Duration
+	maxEvents   int
+)
+
+// reachability is how the controller reaches the node
+type reachability struct {
+	// Addrs are the remote addresses of the connections to the node
+	Addrs []string `json:"addrs,omitempty"`
+
+	// Local is set if the node is reached over the local socket
+	Local bool `json:"local,omitempty"`
+
+	// Relayed is set if the node is only reached through a relay
+	Relayed bool `json:"relayed,omitempty"`
+
+	// RTTMillis is the round trip time of a ping, 0 if the node did not answer
+	RTTMillis float64 `json:"rtt_ms,omitempty"`
+}
+
+// nodeReport is the structured result of describe node
+type nodeReport struct {
+	Node         *types.NodeInfo    `json:"node"`
+	Reachability reachability       `json:"reachability"`
+	Apps         []*types.AppStatus `json:"apps"`
+	Events       []*events.Event    `json:"events"`
+	Warnings     []string           `json:"warnings,omitempty"`
+}
+
+// nodeCmd describes a node
+var nodeCmd = &cobra.Command{
+	Use:   "node [peer-id|name]",
+	Short: "Show a detailed report of a node",
+	Long: `Show everything a node reports about itself in one place: its identity,
+addresses and how the controller reaches it, labels, capacity and usage,
+quota usage per operator, the streams handled per protocol, the apps with
+their status and health, their recent lifecycle events, and warnings about
+anything that needs attention.
+
+The node is given by peer ID or name. If it is not specified, the local
+daemon is described, or else the only node discovered.
+
+Example:
+  controller describe node edge-1
+  controller describe node edge-1 --events 24h`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var selection common.Selection
+		if len(args) == 1 {
+			selection.Node = args[0]
+		}
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		report := &nodeReport{Apps: []*types.AppStatus{}, Events: []*events.Event{}}
+		report.Node, err = common.FetchNodeInfo(ctx, host, targetPeerID, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to fetch node info: %w", err)
+		}
+		report.Reachability = reach(ctx, host, targetPeerID)
+
+		// The node answered, so the rest only adds detail
+		if statuses, err := common.ListAppStatuses(ctx, host, targetPeerID, common.GlobalLogger); err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("app statuses unavailable: %v", err))
+		} else {
+			sort.Slice(statuses, func(i, j int) bool { return statuses[i].App.ID < statuses[j].App.ID })
+			report.Apps = statuses
+		}
+		if evts, err := common.FetchEvents(ctx, host, targetPeerID, common.EventsRequest{Since: eventsSince}, common.GlobalLogger); err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("events unavailable: %v", err))
+		} else if len(evts) > 0 {
+			if maxEvents > 0 && len(evts) > maxEvents {
+				evts = evts[len(evts)-maxEvents:]
+			}
+			report.Events = evts
+		}
+		report.Warnings = append(report.Warnings, warnings(report)...)
+
+		return out.Result(report, func() {
+			printNode(report)
+		})
+	},
+}
+
+// reach reports how the controller is connected to the node, pinging it
+func reach(ctx context.Context, host *p2p.Host, peerID string) reachability {
+	var r reachability
+	for _, p := range host.Peers() {
+		if p.ID != peerID {
+			continue
+		}
+		r.Addrs = p.Addrs
+		r.Relayed = len(p.Addrs) > 0
+		for _, addr := range p.Addrs {
+			if strings.HasPrefix(addr, "/unix") {
+				r.Local = true
+			}
+			if !strings.Contains(addr, "/p2p-circuit") {
+				r.Relayed = false
+			}
+		}
+		break
+	}
+	if r.Local {
+		return r
+	}
+
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return r
+	}
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	select {
+	case res := <-ping.Ping(ctx, host.LibP2PHost(), id):
+		if res.Error == nil {
+			r.RTTMillis = float64(res.RTT.Microseconds()) / 1000
+		}
+	case <-ctx.Done():
+	}
+	return r
+}
+
+// warnings lists what needs attention on the node described by report
+func warnings(report *nodeReport) []string {
+	var warns []string
+	node := report.Node
+	for _, a := range node.Alerts {
+		warns = append(warns, "alert: "+a)
+	}
+
+	for _, status := range report.Apps {
+		switch {
+		case status.App.Status == types.AppStatusFailed:
+			warns = append(warns, fmt.Sprintf("app %s failed: %s", status.App.ID, status.Message))
+		case status.App.Status == types.AppStatusRunning && !status.Healthy:
+			warns = append(warns, fmt.Sprintf("app %s is unhealthy: %s", status.App.ID, status.Message))
+		}
+	}
+
+	if c := node.Capacity; c != nil {
+		if c.Allocatable.MemoryMB > 0 && c.Allocated.MemoryMB > c.Allocatable.MemoryMB {
+			warns = append(warns, fmt.Sprintf("running apps declare %d MB memory, more than the %d MB allocatable", c.Allocated.MemoryMB, c.Allocatable.MemoryMB))
+		}
+		if c.Allocatable.CPUPercent > 0 && c.Allocated.CPUPercent > c.Allocatable.CPUPercent {
+			warns = append(warns, fmt.Sprintf("running apps declare %.0f%% CPU, more than the %.0f%% allocatable", c.Allocated.CPUPercent, c.Allocatable.CPUPercent))
+		}
+		if c.Allocatable.DiskMB > 0 && c.Allocated.DiskMB*100 >= c.Allocatable.DiskMB*nearLimitPercent {
+			warns = append(warns, fmt.Sprintf("disk is %d%% full (%d of %d MB allocatable)", c.Allocated.DiskMB*100/c.Allocatable.DiskMB, c.Allocated.DiskMB, c.Allocatable.DiskMB))
+		}
+	}
+
+	for _, q := range node.Quotas {
+		if (q.MaxApps > 0 && q.Apps >= q.MaxApps) ||
+			(q.MaxPackageBytes > 0 && q.PackageBytes >= q.MaxPackageBytes) ||
+			(q.MaxDeploysPerHour > 0 && q.DeploysLastHour >= q.MaxDeploysPerHour) {
+			warns = append(warns, fmt.Sprintf("operator %s has reached its quota", operatorName(q)))
+		}
+	}
+
+	crashed := make([]string, 0, len(node.Crashes))
+	for name := range node.Crashes {
+		crashed = append(crashed, name)
+	}
+	sort.Strings(crashed)
+	for _, name := range crashed {
+		warns = append(warns, fmt.Sprintf("%s panicked %d time(s), see the daemon log", name, node.Crashes[name]))
+	}
+
+	for _, c := range node.Components {
+		if c.State == types.ComponentUnhealthy || c.State == types.ComponentFailed {
+			warns = append(warns, fmt.Sprintf("subsystem %s is %s: %s", c.Name, c.State, c.Error))
+		}
+	}
+
+	if a := node.Advertisements; a != nil && a.Failed > 0 && a.Advertised == 0 {
+		warns = append(warns, "node failed to advertise itself in the DHT, see the daemon log")
+	}
+
+	if report.Reachability.Relayed {
+		warns = append(warns, "node is only reached through a relay")
+	}
+	if !report.Reachability.Local && report.Reachability.RTTMillis == 0 {
+		warns = append(warns, "node did not answer a ping")
+	}
+	return warns
+}
+
+// operatorName names the operator of a quota
+func operatorName(q *types.QuotaUsage) string {
+	if q.Name != "" {
+		return q.Name
+	}
+	return q.Operator
+}
+
+// printNode prints report in the style of "kubectl describe"
+func printNode(report *nodeReport) {
+	out := common.Out
+	node := report.Node
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	field := func(name string, format string, args ...interface{}) {
+		_, _ = fmt.Fprintf(w, "%s:\t%s\n", name, fmt.Sprintf(format, args...))
+	}
+
+	name := node.Name
+	if name == "" {
+		name = "-"
+	}
+	field("Name", "%s", name)
+	field("Peer ID", "%s", node.ID)
+	field("Version", "%s", version.Info{Version: node.Version, Commit: node.Commit})
+	if !node.StartedAt.IsZero() {
+		field("Started", "%s (up %s)", node.StartedAt.Local().Format("2006-01-02 15:04:05"), time.Duration(node.UptimeSeconds)*time.Second)
+	}
+	field("Labels", "%s", formatLabels(node.Labels))
+	_ = w.Flush()
+	out.Printf("%s", b.String())
+
+	out.Println("Addresses:")
+	for _, addr := range node.Addrs {
+		out.Printf("  %s\n", addr)
+	}
+
+	out.Println("Reachability:")
+	r := report.Reachability
+	switch {
+	case r.Local:
+		out.Println("  Connected over the local socket")
+	case r.Relayed:
+		out.Println("  Connected through a relay")
+	case len(r.Addrs) > 0:
+		out.Println("  Connected directly")
+	default:
+		out.Println("  Not connected")
+	}
+	for _, addr := range r.Addrs {
+		out.Printf("  via %s\n", addr)
+	}
+	if r.RTTMillis > 0 {
+		out.Printf("  Round trip: %.1fms\n", r.RTTMillis)
+	}
+
+	if c := node.Capacity; c != nil {
+		out.Println("Capacity:")
+		b.Reset()
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "  RESOURCE\tTOTAL\tRESERVED\tALLOCATABLE\tALLOCATED")
+		_, _ = fmt.Fprintf(w, "  cpu\t%.0f%%\t%.0f%%\t%.0f%%\t%.0f%%\n", c.Total.CPUPercent, c.Reserved.CPUPercent, c.Allocatable.CPUPercent, c.Allocated.CPUPercent)
+		_, _ = fmt.Fprintf(w, "  memory\t%s\t%d MB\t%s\t%d MB\n", megabytes(c.Total.MemoryMB), c.Reserved.MemoryMB, megabytes(c.Allocatable.MemoryMB), c.Allocated.MemoryMB)
+		_, _ = fmt.Fprintf(w, "  disk\t%s\t%d MB\t%s\t%s\n", megabytes(c.Total.DiskMB), c.Reserved.DiskMB, megabytes(c.Allocatable.DiskMB), megabytes(c.Allocated.DiskMB))
+		_ = w.Flush()
+		out.Printf("%s", b.String())
+		if c.Enforced {
+			out.Println("  Deployments that do not fit are refused")
+		}
+	}
+
+	if len(node.Quotas) > 0 {
+		out.Println("Quotas:")
+		for _, q := range node.Quotas {
+			out.Printf("  %s: %s apps, %s package bytes, %s deploys in the last hour\n", operatorName(q),
+				limited(int64(q.Apps), int64(q.MaxApps)), limited(q.PackageBytes, q.MaxPackageBytes),
+				limited(int64(q.DeploysLastHour), int64(q.MaxDeploysPerHour)))
+		}
+	}
+
+	if len(node.Protocols) > 0 {
+		out.Println("Protocols:")
+		b.Reset()
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "  PROTOCOL\tSTREAMS\tREJECTED\tPANICS\tAVG")
+		for _, p := range node.Protocols {
+			_, _ = fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%.1fms\n", p.Protocol, p.Streams, p.Rejected, p.Panics, p.AvgMillis)
+		}
+		_ = w.Flush()
+		out.Printf("%s", b.String())
+	}
+
+	if len(node.Components) > 0 {
+		out.Println("Subsystems:")
+		b.Reset()
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "  NAME\tSTATE\tDETAIL")
+		for _, c := range node.Components {
+			detail := c.Detail
+			if c.Error != "" {
+				detail = c.Error
+			}
+			_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\n", c.Name, c.State, detail)
+		}
+		_ = w.Flush()
+		out.Printf("%s", b.String())
+	}
+
+	if a := node.Advertisements; a != nil {
+		out.Println("DHT advertisements:")
+		active := "none"
+		if len(a.Active) > 0 {
+			active = strings.Join(a.Active, ", ")
+		}
+		out.Printf("  Active: %s\n", active)
+		out.Printf("  Published: %d, failed: %d, withdrawn: %d, stale provider records seen: %d\n",
+			a.Advertised, a.Failed, a.Withdrawn, a.StaleProviders)
+	}
+
+	out.Printf("Apps (%d):\n", len(report.Apps))
+	if len(report.Apps) > 0 {
+		b.Reset()
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "  ID\tSTATUS\tHEALTH\tMESSAGE")
+		for _, status := range report.Apps {
+			health := "unhealthy"
+			if status.Healthy {
+				health = "healthy"
+			}
+			_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", status.App.ID, status.App.Status, health, status.Message)
+		}
+		_ = w.Flush()
+		out.Printf("%s", b.String())
+	}
+
+	out.Printf("Events (last %s):\n", eventsSince)
+	if len(report.Events) == 0 {
+		out.Println("  <none>")
+	}
+	for _, e := range report.Events {
+		out.Printf("  %s  %-30s  %-10s  %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.AppID, e.Type, e.Message)
+	}
+
+	out.Println("Warnings:")
+	if len(report.Warnings) == 0 {
+		out.Println("  <none>")
+	}
+	for _, warn := range report.Warnings {
+		out.Printf("  ! %s\n", warn)
+	}
+}
+
+// formatLabels formats labels as k=v pairs sorted by key
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "<none>"
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// megabytes formats a size in megabytes, 0 being unknown
+func megabytes(mb int64) string {
+	if mb <= 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d MB", mb)
+}
+
+// limited formats usage against a limit, 0 being unlimited
+func limited(used, limit int64) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d", used)
+	}
+	return fmt.Sprintf("%d/%d", used, limit)
+}
+
+func init() {
+	nodeCmd.Flags().DurationVar(&eventsSince, "events", time.Hour, "show the app events of this period")
+	nodeCmd.Flags().IntVar(&maxEvents, "max-events", 20, "show at most this many of the most recent events (0 for all)")
+}
@@ -0,0 +1,267 @@
+package devcluster
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// stateFile records the daemons of a running cluster inside its directory
+const stateFile = "cluster.json"
+
+// lockFile serializes the controllers starting and stopping a cluster
+const lockFile = "cluster.lock"
+
+// startTimeout bounds how long a daemon may take to report its peer ID
+const startTimeout = 30 * time.Second
+
+// stopTimeout bounds how long a daemon may take to exit before it is killed
+const stopTimeout = 10 * time.Second
+
+// node is a daemon of the dev cluster
+type node struct {
+	Name    string `json:"name"`
+	PID     int    `json:"pid"`
+	Port    int    `json:"port"`
+	PeerID  string `json:"peer_id"`
+	Addr    string `json:"addr"`
+	Dir     string `json:"dir"`
+	Running bool   `json:"running"`
+}
+
+// cluster is the state of a dev cluster
+type cluster struct {
+	Dir              string  `json:"dir"`
+	ControllerConfig string  `json:"controller_config"`
+	Nodes            []*node `json:"nodes"`
+}
+
+// nodeOptions configures the daemons of a cluster
+type nodeOptions struct {
+	mdns          bool
+	allowUnsigned bool
+}
+
+// loadCluster reads the state of the cluster in dir, returning nil if none was started
+func loadCluster(dir string) (*cluster, error) {
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster state: %w", err)
+	}
+
+	var c cluster
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster state: %w", err)
+	}
+	for _, n := range c.Nodes {
+		n.Running = alive(n.PID)
+	}
+	return &c, nil
+}
+
+// save writes the state of the cluster to its directory
+func (c *cluster) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cluster state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.Dir, stateFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write cluster state: %w", err)
+	}
+	return nil
+}
+
+// running reports whether any daemon of the cluster is still running
+func (c *cluster) running() bool {
+	for _, n := range c.Nodes {
+		if n.Running {
+			return true
+		}
+	}
+	return false
+}
+
+// stop terminates the daemons of the cluster, killing those that do not exit in time
+func (c *cluster) stop() {
+	for _, n := range c.Nodes {
+		if !n.Running {
+			continue
+		}
+		if p, err := os.FindProcess(n.PID); err == nil {
+			_ = terminate(p)
+		}
+	}
+
+	deadline := time.Now().Add(stopTimeout)
+	for _, n := range c.Nodes {
+		for n.Running && time.Now().Before(deadline) {
+			time.Sleep(100 * time.Millisecond)
+			n.Running = alive(n.PID)
+		}
+		if n.Running {
+			if p, err := os.FindProcess(n.PID); err == nil {
+				_ = p.Kill()
+			}
+			n.Running = false
+		}
+	}
+}
+
+// startNode launches the i-th daemon of the cluster, bootstrapping from the
+// given peers, and waits until it reports its peer ID
+func (c *cluster) startNode(daemonBin string, i int, bootstrap []string, opts nodeOptions) (*node, error) {
+	name := fmt.Sprintf("node-%d", i)
+	dir := filepath.Join(c.Dir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create node directory: %w", err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	configPath := filepath.Join(dir, "daemon.yaml")
+	logPath := filepath.Join(dir, "daemon.log")
+	if err := os.WriteFile(configPath, []byte(daemonConfig(name, dir, port, bootstrap, opts)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write daemon config: %w", err)
+	}
+	// A stale log would report the peer ID of a previous run
+	_ = os.Remove(logPath)
+
+	output, err := os.Create(filepath.Join(dir, "daemon.out"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create daemon output file: %w", err)
+	}
+	defer func() { _ = output.Close() }()
+
+	cmd := exec.Command(daemonBin, "daemon", "run", "-c", configPath)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start daemon: %w", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	n := &node{Name: name, PID: cmd.Process.Pid, Port: port, Dir: dir, Running: true}
+	deadline := time.Now().Add(startTimeout)
+	for n.PeerID == "" {
+		select {
+		case <-exited:
+			return nil, fmt.Errorf("%w: daemon %s exited during startup, see %s", types.ErrAppStartFailed, name, dir)
+		case <-time.After(200 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			_ = cmd.Process.Kill()
+			return nil, fmt.Errorf("%w: daemon %s did not start within %s, see %s", types.ErrTimeout, name, startTimeout, dir)
+		}
+		n.PeerID = readPeerID(logPath)
+	}
+
+	n.Addr = fmt.Sprintf("/ip4/127.0.0.1/tcp/%d/p2p/%s", port, n.PeerID)
+	return n, nil
+}
+
+// writeControllerConfig writes a controller config that bootstraps from every node
+func (c *cluster) writeControllerConfig() error {
+	c.ControllerConfig = filepath.Join(c.Dir, "controller.yaml")
+
+	config := "node:\n  listen_addrs: [/ip4/127.0.0.1/tcp/0]\n  enable_mdns: false\n  bootstrap_peers:\n"
+	for _, n := range c.Nodes {
+		config += fmt.Sprintf("    - %s\n", n.Addr)
+	}
+	config += fmt.Sprintf(`storage:
+  data_dir: %[1]s/controller
+  packages_dir: %[1]s/controller/packages
+  keys_dir: %[1]s/controller/keys
+logging:
+  level: warn
+  format: console
+  output_path: stderr
+  error_output_path: stderr
+`, c.Dir)
+
+	if err := os.WriteFile(c.ControllerConfig, []byte(config), 0644); err != nil {
+		return fmt.Errorf("failed to write controller config: %w", err)
+	}
+	return nil
+}
+
+// daemonConfig returns the config of a daemon listening on loopback only.
+// Every path is set, since defaults do not apply once a config file is given.
+func daemonConfig(name, dir string, port int, bootstrap []string, opts nodeOptions) string {
+	config := fmt.Sprintf(`node:
+  name: %s
+  listen_addrs: [/ip4/127.0.0.1/tcp/%d]
+  enable_mdns: %t
+  bootstrap_peers: [`, name, port, opts.mdns)
+	for i, addr := range bootstrap {
+		if i > 0 {
+			config += ", "
+		}
+		config += addr
+	}
+	config += fmt.Sprintf(`]
+storage:
+  data_dir: %[1]s/data
+  packages_dir: %[1]s/data/packages
+  apps_dir: %[1]s/data/apps
+  keys_dir: %[1]s/data/keys
+logging:
+  level: info
+  format: json
+  output_path: %[1]s/daemon.log
+  error_output_path: %[1]s/daemon.log
+security:
+  allow_unsigned_packages: %[2]t
+  public_keys_dir: %[1]s/data/keys/trusted
+`, dir, opts.allowUnsigned)
+	return config
+}
+
+// readPeerID returns the peer ID the daemon logged at startup, or "" if not yet logged
+func readPeerID(logPath string) string {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry struct {
+			PeerID string `json:"peer_id"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.PeerID != "" {
+			return entry.PeerID
+		}
+	}
+	return ""
+}
+
+// freePort returns a loopback TCP port that was free a moment ago
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer func() { _ = l.Close() }()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,254 @@
+package devcluster
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/filelock"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// maxNodes bounds the size of a dev cluster
+const maxNodes = 32
+
+var (
+	clusterDir    string
+	nodeCount     int
+	daemonBin     string
+	enableMDNS    bool
+	allowUnsigned bool
+	purge         bool
+)
+
+// Cmd represents the dev-cluster command
+var Cmd = &cobra.Command{
+	Use:   "dev-cluster",
+	Short: "Run a local cluster of daemons for demos and testing",
+	Long: `Run several daemons on this machine, each with its own data directory and
+loopback port, to try deployments without multiple machines.
+
+The daemons keep running in the background until "dev-cluster down". A
+controller config reaching them is written to the cluster directory.
+
+Example:
+  controller dev-cluster up --nodes 3
+  controller -c ~/.p2p-playground/dev-cluster/controller.yaml list
+  controller dev-cluster down`,
+}
+
+// upCmd starts a cluster
+var upCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Start a local cluster",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if nodeCount < 1 || nodeCount > maxNodes {
+			return fmt.Errorf("%w: --nodes must be between 1 and %d", types.ErrInvalidInput, maxNodes)
+		}
+
+		dir, err := resolveDir()
+		if err != nil {
+			return err
+		}
+		lock, err := lockCluster(dir)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = lock.Unlock() }()
+
+		existing, err := loadCluster(dir)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.running() {
+			return fmt.Errorf("%w: a dev cluster is already running in %s, stop it with \"dev-cluster down\"", types.ErrAlreadyExists, dir)
+		}
+
+		bin, err := findDaemon()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create cluster directory: %w", err)
+		}
+
+		out := common.Out
+		c := &cluster{Dir: dir}
+		opts := nodeOptions{mdns: enableMDNS, allowUnsigned: allowUnsigned}
+
+		// Later nodes bootstrap from the first, which knows about all of them
+		var bootstrap []string
+		for i := 0; i < nodeCount; i++ {
+			n, err := c.startNode(bin, i, bootstrap, opts)
+			if err != nil {
+				c.stop()
+				return err
+			}
+			c.Nodes = append(c.Nodes, n)
+			if i == 0 {
+				bootstrap = []string{n.Addr}
+			}
+			out.Statusf("Started %s (pid %d, port %d)\n", n.Name, n.PID, n.Port)
+		}
+
+		if err := c.writeControllerConfig(); err != nil {
+			c.stop()
+			return err
+		}
+		if err := c.save(); err != nil {
+			c.stop()
+			return err
+		}
+
+		return out.Result(c, func() {
+			out.Println()
+			out.Printf("✓ Dev cluster with %d node(s) running in %s\n", len(c.Nodes), dir)
+			out.Println()
+			printNodes(c)
+			out.Println()
+			out.Println("Use it with:")
+			out.Printf("  controller -c %s list\n", c.ControllerConfig)
+		})
+	},
+}
+
+// downCmd stops a cluster
+var downCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Stop the local cluster",
+	Long:  `Stop the daemons of the local cluster. Their data is kept unless --purge is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := resolveDir()
+		if err != nil {
+			return err
+		}
+		lock, err := lockCluster(dir)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = lock.Unlock() }()
+
+		c, err := loadCluster(dir)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return fmt.Errorf("%w: no dev cluster in %s", types.ErrNotFound, dir)
+		}
+
+		out := common.Out
+		out.Statusf("Stopping %d node(s)...\n", len(c.Nodes))
+		c.stop()
+
+		if purge {
+			// The lock file goes with the directory
+			_ = lock.Unlock()
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("failed to remove cluster directory: %w", err)
+			}
+		} else if err := os.Remove(filepath.Join(dir, stateFile)); err != nil {
+			return fmt.Errorf("failed to remove cluster state: %w", err)
+		}
+
+		return out.Result(c, func() {
+			out.Printf("✓ Dev cluster in %s stopped\n", dir)
+		})
+	},
+}
+
+// statusCmd shows the nodes of a cluster
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the nodes of the local cluster",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := resolveDir()
+		if err != nil {
+			return err
+		}
+		c, err := loadCluster(dir)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return fmt.Errorf("%w: no dev cluster in %s", types.ErrNotFound, dir)
+		}
+
+		out := common.Out
+		return out.Result(c, func() {
+			printNodes(c)
+			out.Println()
+			out.Printf("Controller config: %s\n", c.ControllerConfig)
+		})
+	},
+}
+
+// printNodes prints a table of the nodes of c
+func printNodes(c *cluster) {
+	out := common.Out
+	out.Printf("%-8s %-8s %-6s %-8s %s\n", "NAME", "PID", "PORT", "STATUS", "PEER ID")
+	for _, n := range c.Nodes {
+		status := "stopped"
+		if n.Running {
+			status = "running"
+		}
+		out.Printf("%-8s %-8d %-6d %-8s %s\n", n.Name, n.PID, n.Port, status, n.PeerID)
+	}
+}
+
+// resolveDir returns the cluster directory
+func resolveDir() (string, error) {
+	if clusterDir != "" {
+		return filepath.Abs(clusterDir)
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".p2p-playground", "dev-cluster"), nil
+}
+
+// lockCluster locks the cluster in dir against other controllers starting
+// or stopping it at the same time
+func lockCluster(dir string) (*filelock.Lock, error) {
+	lock, err := filelock.TryAcquire(filepath.Join(dir, lockFile))
+	if err != nil {
+		return nil, fmt.Errorf("dev cluster in %s is busy: %w", dir, err)
+	}
+	return lock, nil
+}
+
+// findDaemon returns the daemon binary to launch: --daemon, a daemon next to
+// the controller binary, or p2p-daemon from PATH
+func findDaemon() (string, error) {
+	if daemonBin != "" {
+		return daemonBin, nil
+	}
+	if exe, err := os.Executable(); err == nil {
+		for _, name := range []string{"p2p-daemon", "daemon"} {
+			path := filepath.Join(filepath.Dir(exe), name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+	}
+	if path, err := exec.LookPath("p2p-daemon"); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("%w: daemon binary not found next to the controller or in PATH, set --daemon", types.ErrNotFound)
+}
+
+func init() {
+	Cmd.PersistentFlags().StringVar(&clusterDir, "dir", "", "cluster directory (default: ~/.p2p-playground/dev-cluster)")
+	upCmd.Flags().IntVar(&nodeCount, "nodes", 3, "number of daemons to start")
+	upCmd.Flags().StringVar(&daemonBin, "daemon", "", "daemon binary (default: next to the controller, or p2p-daemon in PATH)")
+	upCmd.Flags().BoolVar(&enableMDNS, "mdns", false, "also discover nodes with mDNS")
+	upCmd.Flags().BoolVar(&allowUnsigned, "allow-unsigned", false, "let the daemons accept unsigned packages")
+	downCmd.Flags().BoolVar(&purge, "purge", false, "also remove the data of the nodes")
+
+	Cmd.AddCommand(upCmd)
+	Cmd.AddCommand(downCmd)
+	Cmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package devcluster
+
+import (
+	"os"
+	"os/exec"
+)
+
+// detach is not needed on this platform
+func detach(cmd *exec.Cmd) {}
+
+// terminate stops p; graceful shutdown signals are not available on this platform
+func terminate(p *os.Process) error {
+	return p.Kill()
+}
+
+// alive reports whether a process with the given PID exists
+func alive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
@@ -0,0 +1,231 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/platform"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// logsDir is the directory of an app that nodes leave out of the recorded
+// checksums, as the runtime writes the app's output there
+const logsDir = "logs"
+
+var (
+	selection common.Selection
+)
+
+// diffResult is the structured result of a diff
+type diffResult struct {
+	NodeID string `json:"node_id"`
+	App    string `json:"app"`
+
+	// DeployedID is the ID of the deployed version, empty if none is
+	DeployedID string `json:"deployed_id,omitempty"`
+
+	DeployedVersion string `json:"deployed_version,omitempty"`
+	NewVersion      string `json:"new_version"`
+
+	Manifest []common.Change `json:"manifest"`
+	Files    []common.Change `json:"files"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+// Cmd represents the diff command
+var Cmd = &cobra.Command{
+	Use:   "diff <package | app-directory>",
+	Short: "Preview what a redeploy would change on a node",
+	Long: `Compare a package or app directory with the version of the app currently
+deployed on a node, before deploying it.
+
+The manifests are compared field by field (args, env, resources, health
+check and the rest), named as in manifest.yaml. The files are compared by
+their SHA-256 with the checksums the node recorded when it deployed the
+app. Files changed on the node since it was deployed are reported as a
+warning; see controller verify.
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is compared, or else the only node discovered.
+
+Example:
+  controller diff ./examples/http-server --node edge-1
+  controller diff web-1.1.0.tar.gz --output json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := args[0]
+		out := common.Out
+		ctx := context.Background()
+
+		manifest, files, err := readLocal(ctx, path)
+		if err != nil {
+			return err
+		}
+
+		// Create P2P host using configuration
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		statuses, err := common.ListAppStatuses(ctx, host, targetPeerID, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to list applications: %w", err)
+		}
+		var deployed *types.Application
+		for _, status := range statuses {
+			if status.App.Name == manifest.Name && (deployed == nil || current(status.App, deployed)) {
+				deployed = status.App
+			}
+		}
+
+		result := diffResult{
+			NodeID:     targetPeerID,
+			App:        manifest.Name,
+			NewVersion: manifest.Version,
+			Manifest:   []common.Change{},
+			Files:      []common.Change{},
+		}
+		if deployed == nil {
+			return out.Result(result, func() {
+				out.Printf("\n%s is not deployed on %s: deploying %s would add it with %d files\n",
+					manifest.Name, targetPeerID, path, len(files))
+			})
+		}
+		result.DeployedID = deployed.ID
+		result.DeployedVersion = deployed.Version
+
+		if result.Manifest, err = common.DiffManifests(deployed.Manifest, manifest); err != nil {
+			return err
+		}
+
+		recorded, report, err := common.DeployedFiles(ctx, host, targetPeerID, deployed.ID, common.GlobalLogger)
+		switch {
+		case err != nil:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("files not compared: %v", err))
+		case len(recorded) == 0 && report.Checked > 0:
+			result.Warnings = append(result.Warnings, "files not compared: the node does not report the checksums of deployed files")
+		default:
+			deployedFiles := make(map[string]string, len(recorded))
+			for _, f := range recorded {
+				if f.Path != pkgmanager.ChecksumsFile {
+					deployedFiles[f.Path] = f.SHA256
+				}
+			}
+			result.Files = common.DiffFiles(deployedFiles, files)
+			if !report.OK() {
+				result.Warnings = append(result.Warnings, fmt.Sprintf(
+					"%d file(s) changed on the node since %s was deployed, see controller verify %s",
+					len(report.Drift), deployed.ID, deployed.ID))
+			}
+		}
+
+		return out.Result(result, func() {
+			printDiff(&result, path)
+		})
+	},
+}
+
+// readLocal reads the manifest and file checksums of a package or app
+// directory, leaving out the files nodes do not record
+func readLocal(ctx context.Context, path string) (*types.Manifest, map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to access %s: %w", path, err)
+	}
+
+	pkgMgr := pkgmanager.New()
+	var manifest *types.Manifest
+	if info.IsDir() {
+		manifest, err = pkgMgr.ReadManifest(filepath.Join(path, "manifest.yaml"))
+		// Packing records the platform of the entrypoint
+		if err == nil && manifest.Platform == "" {
+			manifest.Platform, _ = platform.Detect(filepath.Join(path, manifest.Entrypoint))
+		}
+	} else {
+		manifest, err = pkgMgr.GetManifest(ctx, path)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	files, err := pkgMgr.FileChecksums(ctx, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read files: %w", err)
+	}
+	for name := range files {
+		if strings.HasPrefix(name, logsDir+"/") {
+			delete(files, name)
+		}
+	}
+	return manifest, files, nil
+}
+
+// current reports whether a is more current than b: running, or else started later
+func current(a, b *types.Application) bool {
+	if (a.Status == types.AppStatusRunning) != (b.Status == types.AppStatusRunning) {
+		return a.Status == types.AppStatusRunning
+	}
+	return a.StartedAt.After(b.StartedAt)
+}
+
+// printDiff prints result with one line per change
+func printDiff(result *diffResult, path string) {
+	out := common.Out
+	out.Printf("\nComparing %s on %s with %s %s from %s\n",
+		result.DeployedID, result.NodeID, result.App, result.NewVersion, path)
+
+	if len(result.Manifest) > 0 {
+		out.Println("\nManifest:")
+		for _, c := range result.Manifest {
+			switch c.Kind {
+			case common.ChangeAdded:
+				out.Printf("  + %s: %s\n", c.Path, c.New)
+			case common.ChangeRemoved:
+				out.Printf("  - %s: %s\n", c.Path, c.Old)
+			default:
+				out.Printf("  ~ %s: %s -> %s\n", c.Path, c.Old, c.New)
+			}
+		}
+	}
+
+	if len(result.Files) > 0 {
+		out.Println("\nFiles:")
+		for _, c := range result.Files {
+			switch c.Kind {
+			case common.ChangeAdded:
+				out.Printf("  + %s\n", c.Path)
+			case common.ChangeRemoved:
+				out.Printf("  - %s\n", c.Path)
+			default:
+				out.Printf("  ~ %s (sha256 %.12s -> %.12s)\n", c.Path, c.Old, c.New)
+			}
+		}
+	}
+
+	for _, warn := range result.Warnings {
+		out.Printf("\n⚠️  %s\n", warn)
+	}
+
+	if len(result.Manifest) == 0 && len(result.Files) == 0 {
+		out.Println("\nNo differences")
+		return
+	}
+	out.Printf("\n%d manifest field(s) and %d file(s) differ\n", len(result.Manifest), len(result.Files))
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	pkgevents "github.com/asjdf/p2p-playground-lite/pkg/events"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection  common.Selection
+	since      time.Duration
+	appID      string
+	eventTypes []string
+)
+
+// eventsResult is the structured result of an events request
+type eventsResult struct {
+	NodeID string             `json:"node_id"`
+	Events []*pkgevents.Event `json:"events"`
+}
+
+// Cmd represents the events command
+var Cmd = &cobra.Command{
+	Use:   "events",
+	Short: "Show the app lifecycle events a node logged",
+	Long: `Show the lifecycle events of the apps on a node: deployed, started, exited,
+stopped, unhealthy and alert. Nodes log every event in their data directory
+as it happens, so the events can be queried afterwards by a controller that
+was not connected at the time. The most recent 10000 events are kept by
+default (events.size in the daemon config).
+
+Each event names its initiator when known: the operator whose deploy or
+rollback started or stopped the app (the key that signed the package, or
+else the controller's peer ID), the health monitor, the restart policy, or
+the app itself exiting.
+
+--node takes the peer ID or name of the node to query. If it is not
+specified, the local daemon is queried, or else the only node discovered.
+
+Example:
+  controller events --node edge-1 --since 1h
+  controller events --app web-1.0.0 --type exited,unhealthy`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+
+		req := common.EventsRequest{Since: since, AppID: appID}
+		for _, t := range eventTypes {
+			t := types.AppEvent(strings.TrimSpace(t))
+			if !slices.Contains(pkgevents.Types, t) {
+				return fmt.Errorf("%w: unknown event type %q, expected one of %s", types.ErrInvalidInput, t, typeNames())
+			}
+			req.Types = append(req.Types, t)
+		}
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		evts, err := common.FetchEvents(ctx, host, targetPeerID, req, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to fetch events: %w", err)
+		}
+		result := eventsResult{NodeID: targetPeerID, Events: evts}
+		if result.Events == nil {
+			result.Events = []*pkgevents.Event{}
+		}
+
+		return out.Result(result, func() {
+			out.Println()
+			if len(evts) == 0 {
+				out.Println("No events")
+				return
+			}
+			for _, e := range evts {
+				message := e.Message
+				if e.Initiator != nil {
+					message += "; by " + e.Initiator.String()
+				}
+				out.Printf("%s  %-30s  %-10s  %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.AppID, e.Type, message)
+			}
+		})
+	},
+}
+
+// typeNames lists the event types for messages
+func typeNames() string {
+	names := make([]string, len(pkgevents.Types))
+	for i, t := range pkgevents.Types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+	Cmd.Flags().DurationVar(&since, "since", 0, "show the events of this period, e.g. 1h (default: all logged)")
+	Cmd.Flags().StringVar(&appID, "app", "", "show the events of this app ID")
+	Cmd.Flags().StringSliceVar(&eventTypes, "type", nil, "show the events of these types: "+typeNames())
+}
@@ -1,10 +1,13 @@
 package keygen
 
 import (
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/filelock"
 	"github.com/asjdf/p2p-playground-lite/pkg/security"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +16,13 @@ var (
 	output string
 )
 
+// keygenResult is the structured result of key generation
+type keygenResult struct {
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	PublicHex  string `json:"public_key_hex"`
+}
+
 // Cmd represents the keygen command
 var Cmd = &cobra.Command{
 	Use:   "keygen",
@@ -32,23 +42,36 @@ The public key (controller.pub) should be distributed to nodes for signature ver
 			outputDir = filepath.Join(home, ".p2p-playground", "keys")
 		}
 
+		// Another keygen writing the same directory would mix up the key pair
+		lock, err := filelock.TryAcquire(filepath.Join(outputDir, "keys.lock"))
+		if err != nil {
+			return fmt.Errorf("keys in %s are being generated: %w", outputDir, err)
+		}
+		defer func() { _ = lock.Unlock() }()
+
 		// Generate keys
-		fmt.Printf("Generating Ed25519 key pair...\n")
+		out := common.Out
+		out.Statusf("Generating Ed25519 key pair...\n")
 		signer, err := security.GenerateAndSaveKeys(outputDir, "controller")
 		if err != nil {
 			return fmt.Errorf("failed to generate keys: %w", err)
 		}
 
-		fmt.Printf("\n✓ Key pair generated successfully!\n")
-		fmt.Printf("  Private key: %s/controller.key\n", outputDir)
-		fmt.Printf("  Public key:  %s/controller.pub\n", outputDir)
-		fmt.Printf("\n")
-		fmt.Printf("⚠️  Keep the private key secure and never share it.\n")
-		fmt.Printf("📤 Distribute the public key to nodes for signature verification.\n")
-		fmt.Printf("\n")
-		fmt.Printf("Public key (hex): %x\n", signer.PublicKey())
-
-		return nil
+		result := keygenResult{
+			PrivateKey: filepath.Join(outputDir, "controller.key"),
+			PublicKey:  filepath.Join(outputDir, "controller.pub"),
+			PublicHex:  hex.EncodeToString(signer.PublicKey()),
+		}
+		return out.Result(result, func() {
+			out.Printf("\n✓ Key pair generated successfully!\n")
+			out.Printf("  Private key: %s/controller.key\n", outputDir)
+			out.Printf("  Public key:  %s/controller.pub\n", outputDir)
+			out.Printf("\n")
+			out.Printf("⚠️  Keep the private key secure and never share it.\n")
+			out.Printf("📤 Distribute the public key to nodes for signature verification.\n")
+			out.Printf("\n")
+			out.Printf("Public key (hex): %x\n", signer.PublicKey())
+		})
 	},
 }
 
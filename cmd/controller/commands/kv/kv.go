@@ -0,0 +1,183 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	pkgkv "github.com/asjdf/p2p-playground-lite/pkg/kv"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+	namespace string
+	valueFile string
+)
+
+// entryResult is the structured representation of a key and its value
+type entryResult struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Value     string `json:"value,omitempty"`
+}
+
+// keysResult is the structured representation of a key listing
+type keysResult struct {
+	Namespace string   `json:"namespace"`
+	Keys      []string `json:"keys"`
+}
+
+// Cmd represents the kv command
+var Cmd = &cobra.Command{
+	Use:   "kv",
+	Short: "Read and write the cluster-wide key-value store",
+	Long: `Read and write the key-value store shared by all nodes.
+
+Every daemon keeps a replica of the store and shares writes with the other
+nodes over gossip, so any node can be asked and the data outlives the machine
+the controller runs on. Concurrent writes to a key are resolved by keeping the
+latest one. Keys are grouped into namespaces (default: "` + pkgkv.DefaultNamespace + `").
+
+--node takes the peer ID or name of the node to use. If it is not
+specified, the local daemon is used, or else the only node discovered.`,
+}
+
+// getCmd prints the value of a key
+var getCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := do(common.KVRequest{Op: pkgkv.OpGet, Namespace: namespace, Key: args[0]})
+		if err != nil {
+			return err
+		}
+
+		out := common.Out
+		return out.Result(entryResult{Namespace: namespace, Key: args[0], Value: string(resp.Value)}, func() {
+			out.Printf("%s\n", resp.Value)
+		})
+	},
+}
+
+// putCmd sets the value of a key
+var putCmd = &cobra.Command{
+	Use:   "put <key> [value]",
+	Short: "Set the value of a key",
+	Long:  `Set the value of a key, given as argument or read from --file ("-" for stdin).`,
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, err := readValue(args)
+		if err != nil {
+			return err
+		}
+
+		if _, err := do(common.KVRequest{Op: pkgkv.OpPut, Namespace: namespace, Key: args[0], Value: value}); err != nil {
+			return err
+		}
+
+		out := common.Out
+		return out.Result(entryResult{Namespace: namespace, Key: args[0], Value: string(value)}, func() {
+			out.Printf("Set %s/%s (%d bytes)\n", namespace, args[0], len(value))
+		})
+	},
+}
+
+// deleteCmd removes a key
+var deleteCmd = &cobra.Command{
+	Use:   "delete <key>",
+	Short: "Remove a key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := do(common.KVRequest{Op: pkgkv.OpDelete, Namespace: namespace, Key: args[0]}); err != nil {
+			return err
+		}
+
+		out := common.Out
+		return out.Result(entryResult{Namespace: namespace, Key: args[0]}, func() {
+			out.Printf("Deleted %s/%s\n", namespace, args[0])
+		})
+	},
+}
+
+// listCmd lists keys
+var listCmd = &cobra.Command{
+	Use:   "list [prefix]",
+	Short: "List keys, optionally only those starting with prefix",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var prefix string
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+
+		resp, err := do(common.KVRequest{Op: pkgkv.OpList, Namespace: namespace, Prefix: prefix})
+		if err != nil {
+			return err
+		}
+
+		out := common.Out
+		keys := resp.Keys
+		if keys == nil {
+			keys = []string{}
+		}
+		return out.Result(keysResult{Namespace: namespace, Keys: keys}, func() {
+			if len(keys) == 0 {
+				out.Println("(no keys)")
+				return
+			}
+			for _, key := range keys {
+				out.Println(key)
+			}
+		})
+	},
+}
+
+// do sends req to the target node
+func do(req common.KVRequest) (*common.KVResponse, error) {
+	// Create P2P host using configuration
+	ctx := context.Background()
+	host, err := common.CreateP2PHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = host.Close() }()
+
+	targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.ClusterKV(ctx, host, targetPeerID, req, common.GlobalLogger)
+}
+
+// readValue returns the value given as second argument or through --file
+func readValue(args []string) ([]byte, error) {
+	switch {
+	case len(args) == 2 && valueFile != "":
+		return nil, fmt.Errorf("%w: give the value as argument or with --file, not both", types.ErrInvalidInput)
+	case len(args) == 2:
+		return []byte(args[1]), nil
+	case valueFile == "-":
+		return io.ReadAll(os.Stdin)
+	case valueFile != "":
+		return os.ReadFile(valueFile)
+	default:
+		return nil, fmt.Errorf("%w: missing value, give it as argument or with --file", types.ErrInvalidInput)
+	}
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.PersistentFlags(), &selection, false)
+	Cmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", pkgkv.DefaultNamespace, "key namespace")
+	putCmd.Flags().StringVarP(&valueFile, "file", "f", "", `read the value from a file ("-" for stdin)`)
+
+	Cmd.AddCommand(getCmd)
+	Cmd.AddCommand(putCmd)
+	Cmd.AddCommand(deleteCmd)
+	Cmd.AddCommand(listCmd)
+}
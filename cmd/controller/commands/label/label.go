@@ -0,0 +1,96 @@
+package label
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+)
+
+// labelResult is the structured result of a label command
+type labelResult struct {
+	AppID  string            `json:"app_id"`
+	Labels map[string]string `json:"labels"`
+}
+
+// Cmd represents the label command
+var Cmd = &cobra.Command{
+	Use:   "label <app-id> [key=value ...] [key- ...]",
+	Short: "Set or remove labels of a deployed app",
+	Long: `Set and remove labels of an application deployed on a node, without
+redeploying it. key=value sets a label, overriding the one of the manifest,
+and key- removes it. Without changes, the labels of the application are shown.
+
+The application is named by its ID, or by its name or <name>-<version> for
+the latest deployment of those. The node keeps the changes: redeploying the
+same version applies them again on top of the labels of its manifest.
+'controller list --label' and the other commands listing applications see
+the new labels at once; the application itself sees them through its API
+from its next start. Labels the policy of the node requires of manifests
+cannot be removed.
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is used, or else the only node discovered.
+
+Example:
+  controller label web-1.0.0 env=canary --node 12D3KooW...
+  controller label web-1.0.0 owner-`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+		appID := args[0]
+		set, remove, err := common.ParseLabelChanges(args[1:])
+		if err != nil {
+			return err
+		}
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		labels, err := common.LabelApp(ctx, host, targetPeerID, appID, set, remove, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to label %s: %w", appID, err)
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+
+		return out.Result(labelResult{AppID: appID, Labels: labels}, func() {
+			if len(set) > 0 || len(remove) > 0 {
+				out.Printf("✓ Labels of %s updated\n", appID)
+			}
+			if len(labels) == 0 {
+				out.Printf("%s has no labels\n", appID)
+				return
+			}
+			keys := make([]string, 0, len(labels))
+			for key := range labels {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				out.Printf("  %s=%s\n", key, labels[key])
+			}
+		})
+	},
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+}
@@ -3,25 +3,69 @@ package list
 import (
 	"context"
 	"fmt"
-	"time"
+	"strings"
 
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
 	"github.com/spf13/cobra"
 )
 
 var (
-	nodeID string
+	selection common.Selection
+	listOpts  common.ListOptions
+	statuses  []string
+	labels    string
 )
 
+// listFields are the fields of the apps the text list shows, which leave out their manifests
+var listFields = []string{types.RecordFieldRuntime, types.RecordFieldHealth, types.RecordFieldMetrics}
+
 // Cmd represents the list command
 var Cmd = &cobra.Command{
 	Use:   "list",
 	Short: "List deployed applications",
 	Long: `List all deployed applications on a target node.
 
-If --node is not specified, applications from the first discovered node will be listed.`,
+--node takes the peer ID or name of the node. If it is not specified, the
+applications of the local daemon are listed, or else those of the only node
+discovered. --selector lists the nodes with the given labels and --all every
+node discovered, grouped by node.
+
+--status, --label and --name list only the applications with one of the
+given statuses, all the given labels, and a name matching the pattern (e.g.
+"web*"). Applications are listed in the order of their IDs, or by --sort:
+name, started (most recently first) or memory (most first). The node
+filters and orders them where it can; for older nodes the controller does.
+
+Applications are shown as the node sends them, a page at a time
+(--page-size). --limit lists at most that many and prints the cursor to list
+the rest with --cursor, which lists the applications after the one with that
+ID in the same order; with several nodes, they apply to each.
+
+Example:
+  controller list --status running --label env=prod --sort memory
+  controller list --name "web*" --limit 20`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println("Listing applications...")
+		out := common.Out
+		for _, status := range statuses {
+			listOpts.Filter.Statuses = append(listOpts.Filter.Statuses, types.AppStatusType(status))
+		}
+		var err error
+		if listOpts.Filter.Labels, err = common.ParseSelector(labels); err != nil {
+			return err
+		}
+		if err := listOpts.Filter.Validate(); err != nil {
+			return err
+		}
+		// Sorting nothing checks the order before connecting
+		if err := types.SortAppStatuses(nil, listOpts.Sort); err != nil {
+			return err
+		}
+		out.Statusln("Listing applications...")
+		if !out.IsJSON() {
+			listOpts.Fields = listFields
+		}
 
 		// Create P2P host using configuration
 		ctx := context.Background()
@@ -31,60 +75,202 @@ If --node is not specified, applications from the first discovered node will be
 		}
 		defer func() { _ = host.Close() }()
 
-		// Wait for peer discovery
-		fmt.Println("Discovering nodes...")
-		time.Sleep(3 * time.Second)
-
-		// Get target node
-		var targetPeerID string
-		if nodeID != "" {
-			targetPeerID = nodeID
-			fmt.Printf("Using specified node: %s\n", targetPeerID)
-		} else {
-			// Use first discovered peer
-			peers := host.Peers()
-			if len(peers) == 0 {
-				return fmt.Errorf("no nodes discovered")
-			}
-			targetPeerID = peers[0].ID
-			fmt.Printf("Using discovered node: %s\n", targetPeerID)
-		}
-
-		// List applications
-		fmt.Println("\nFetching applications...")
-		apps, err := common.ListApplications(ctx, host, targetPeerID, common.GlobalLogger)
+		nodeIDs, err := common.ResolveNodes(ctx, host, &selection)
 		if err != nil {
-			return fmt.Errorf("failed to list applications: %w", err)
+			return err
 		}
 
-		// Display results
-		fmt.Printf("\nFound %d application(s):\n\n", len(apps))
-		if len(apps) == 0 {
-			fmt.Println("  (no applications deployed)")
+		// List applications, showing them as they arrive unless the result is JSON
+		out.Statusln("\nFetching applications...")
+		if !selection.Multiple() {
+			printer := &appPrinter{}
+			apps, next, err := listApps(ctx, host, nodeIDs[0], printer.print)
+			if err != nil {
+				return err
+			}
+			if err := out.Result(apps, printer.finish); err != nil {
+				return err
+			}
+			printNextCursor(next)
 			return nil
 		}
 
-		for i, app := range apps {
-			fmt.Printf("%d. Application: %s\n", i+1, app.Name)
-			fmt.Printf("   ID: %s\n", app.ID)
-			fmt.Printf("   Version: %s\n", app.Version)
-			fmt.Printf("   Status: %s\n", app.Status)
-			if app.PID > 0 {
-				fmt.Printf("   PID: %d\n", app.PID)
-			}
-			if !app.StartedAt.IsZero() {
-				fmt.Printf("   Started: %s\n", app.StartedAt.Format("2006-01-02 15:04:05"))
+		results := make([]nodeApps, 0, len(nodeIDs))
+		failed := 0
+		for _, id := range nodeIDs {
+			result := nodeApps{NodeID: id}
+			if !out.IsJSON() {
+				out.Printf("\n=== Node %s ===\n", id)
 			}
-			if len(app.Labels) > 0 {
-				fmt.Printf("   Labels: %v\n", app.Labels)
+			printer := &appPrinter{}
+			apps, next, err := listApps(ctx, host, id, printer.print)
+			if err != nil {
+				result.Error = err.Error()
+				failed++
+				if !out.IsJSON() {
+					out.Printf("  Error: %s\n", result.Error)
+				}
+			} else {
+				result.Apps = apps
+				result.NextCursor = next
+				if !out.IsJSON() {
+					printer.finish()
+					printNextCursor(next)
+				}
 			}
-			fmt.Println()
+			results = append(results, result)
 		}
 
+		if err := out.Result(results, nil); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to list applications on %d of %d node(s)", failed, len(nodeIDs))
+		}
 		return nil
 	},
 }
 
+// nodeApps is the applications of one node, when listing several
+type nodeApps struct {
+	NodeID     string     `json:"node_id"`
+	Apps       []appEntry `json:"apps"`
+	NextCursor string     `json:"next_cursor,omitempty"` // Lists the apps --limit left out
+	Error      string     `json:"error,omitempty"`
+}
+
+// listApps fetches the applications of a node that listOpts selects,
+// calling onPage with each page of them as it arrives. It also returns the
+// cursor listing the apps the limit left out, if any.
+func listApps(ctx context.Context, host *p2p.Host, nodeID string, onPage func([]appEntry)) ([]appEntry, string, error) {
+	apps := []appEntry{}
+	next, err := common.StreamAppStatuses(ctx, host, nodeID, listOpts, func(statuses []*types.AppStatus) error {
+		page := make([]appEntry, 0, len(statuses))
+		for _, status := range statuses {
+			page = append(page, appEntry{
+				Application: status.App,
+				Healthy:     status.Healthy,
+				Message:     status.Message,
+				Reported:    status.Reported,
+				Metrics:     status.Metrics,
+				Usage:       status.ResourceUsage,
+			})
+		}
+		apps = append(apps, page...)
+		onPage(page)
+		return nil
+	}, common.GlobalLogger)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list applications: %w", err)
+	}
+	return apps, next, nil
+}
+
+// printNextCursor tells how to list the applications --limit left out
+func printNextCursor(next string) {
+	if next != "" {
+		common.Out.Statusf("\nMore applications follow; list them with --cursor %s\n", next)
+	}
+}
+
+// appPrinter prints the applications of a node as the pages of them arrive,
+// in text mode
+type appPrinter struct {
+	count int
+}
+
+// print prints a page of applications
+func (p *appPrinter) print(apps []appEntry) {
+	if common.Out.IsJSON() {
+		return
+	}
+	if p.count == 0 && len(apps) > 0 {
+		common.Out.Println()
+	}
+	for _, app := range apps {
+		p.count++
+		printApp(p.count, app)
+	}
+}
+
+// finish prints how many applications were printed
+func (p *appPrinter) finish() {
+	out := common.Out
+	if p.count == 0 {
+		out.Printf("\nFound 0 application(s):\n\n")
+		out.Println("  (no applications deployed)")
+		return
+	}
+	out.Printf("Found %d application(s)\n", p.count)
+}
+
+// printApp prints the i-th application of a node
+func printApp(i int, app appEntry) {
+	out := common.Out
+	out.Printf("%d. Application: %s\n", i, app.Name)
+	out.Printf("   ID: %s\n", app.ID)
+	out.Printf("   Version: %s\n", app.Version)
+	if app.Revision > 0 {
+		out.Printf("   Revision: %d\n", app.Revision)
+	}
+	out.Printf("   Status: %s\n", app.Status)
+	if app.Initiator != nil {
+		out.Printf("   By: %s\n", app.Initiator)
+	}
+	if app.PID > 0 {
+		out.Printf("   PID: %d\n", app.PID)
+	}
+	if !app.StartedAt.IsZero() {
+		out.Printf("   Started: %s\n", app.StartedAt.Format("2006-01-02 15:04:05"))
+	}
+	if len(app.Ports) > 0 {
+		out.Printf("   Ports: %s\n", common.FormatPorts(app.Ports))
+	}
+	if len(app.Labels) > 0 {
+		out.Printf("   Labels: %v\n", app.Labels)
+	}
+	if app.Status == types.AppStatusRunning {
+		out.Printf("   Health: %s\n", app.health())
+	}
+	if app.Usage != nil {
+		out.Printf("   Memory: %d MB\n", app.Usage.MemoryMB)
+	}
+	if len(app.Metrics) > 0 {
+		out.Printf("   Metrics: %s\n", common.FormatMetrics(app.Metrics))
+	}
+	out.Println()
+}
+
+// appEntry is a listed application with its health and published metrics
+type appEntry struct {
+	*types.Application
+	Healthy  bool                  `json:"healthy"`
+	Message  string                `json:"message,omitempty"`
+	Reported *types.ReportedHealth `json:"reported,omitempty"`
+	Metrics  map[string]float64    `json:"metrics,omitempty"`
+	Usage    *types.ResourceUsage  `json:"resource_usage,omitempty"`
+}
+
+// health summarizes the health of a running app, including what it reports about itself
+func (e appEntry) health() string {
+	switch {
+	case e.Reported != nil && strings.HasPrefix(e.Message, e.Reported.Status):
+		// The node put the app's own report in the message
+		return e.Message
+	case !e.Healthy:
+		return "unhealthy: " + e.Message
+	default:
+		return "healthy"
+	}
+}
+
 func init() {
-	Cmd.Flags().StringVar(&nodeID, "node", "", "target node peer ID")
+	common.AddSelectionFlags(Cmd.Flags(), &selection, true)
+	Cmd.Flags().IntVar(&listOpts.Limit, "limit", 0, "list at most this many applications (0 for all)")
+	Cmd.Flags().StringVar(&listOpts.Cursor, "cursor", "", "list the applications after the one with this ID")
+	Cmd.Flags().IntVar(&listOpts.PageSize, "page-size", 0, "applications per page the node sends (0 for its default)")
+	Cmd.Flags().StringSliceVar(&statuses, "status", nil, "list only the applications with these statuses, e.g. running,failed")
+	Cmd.Flags().StringVar(&labels, "label", "", "list only the applications with these labels, e.g. env=prod,tier=web")
+	Cmd.Flags().StringVar(&listOpts.Filter.Name, "name", "", `list only the applications whose name or ID matches this pattern, e.g. "web*"`)
+	Cmd.Flags().StringVar(&listOpts.Sort, "sort", "", "order the applications by id, name, started or memory")
 }
@@ -3,6 +3,9 @@ package logs
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
@@ -10,67 +13,100 @@ import (
 )
 
 var (
-	nodeID string
-	follow bool
-	tail   int
+	selection common.Selection
+	follow    bool
+	tail      int
+	since     time.Duration
 )
 
+// logsResult is the structured result of a logs request
+type logsResult struct {
+	NodeID string `json:"node_id"`
+	AppID  string `json:"app_id"`
+	Logs   string `json:"logs"`
+}
+
+// logLine is a single followed log line in JSON output mode
+type logLine struct {
+	NodeID string `json:"node_id"`
+	AppID  string `json:"app_id"`
+	Line   string `json:"line"`
+}
+
 // Cmd represents the logs command
 var Cmd = &cobra.Command{
 	Use:   "logs [app-id]",
 	Short: "View application logs",
 	Long: `View logs from a deployed application.
 
-If --node is not specified, logs will be fetched from the first discovered node.
-Use --tail to limit the number of lines shown.`,
+The application is named by its ID, or by its name or <name>-<version> for
+the latest deployment of those, as nodes may deploy apps under IDs of their
+own (runtime.app_id_format).
+
+--node takes the peer ID or name of the node. If it is not specified, logs
+are fetched from the local daemon, or else from the only node discovered.
+Use --tail to limit the number of lines shown, and --follow to keep printing
+new lines until interrupted; with --output json each followed line is a
+record of its own. --since only shows lines written within the given
+duration and requires a node reading app logs from the systemd journal
+(runtime.log_source: journal).`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		appID := args[0]
-		fmt.Printf("Fetching logs for application: %s\n", appID)
+		out := common.Out
+		out.Statusf("Fetching logs for application: %s\n", appID)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
 		// Create P2P host using configuration
-		ctx := context.Background()
 		host, err := common.CreateP2PHost(ctx)
 		if err != nil {
 			return err
 		}
 		defer func() { _ = host.Close() }()
 
-		// Wait for peer discovery
-		fmt.Println("Discovering nodes...")
-		time.Sleep(3 * time.Second)
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
 
-		// Get target node
-		var targetPeerID string
-		if nodeID != "" {
-			targetPeerID = nodeID
-			fmt.Printf("Using specified node: %s\n", targetPeerID)
-		} else {
-			// Use first discovered peer
-			peers := host.Peers()
-			if len(peers) == 0 {
-				return fmt.Errorf("no nodes discovered")
+		opts := common.LogOptions{Tail: tail, Since: since}
+		if follow {
+			out.Statusln("\nFollowing logs (Ctrl+C to stop)...")
+			err := common.FollowLogs(ctx, host, targetPeerID, appID, opts, func(line string) {
+				_ = out.Record(logLine{NodeID: targetPeerID, AppID: appID, Line: line}, func() {
+					out.Println(line)
+				})
+			}, common.GlobalLogger)
+			if err != nil {
+				return fmt.Errorf("failed to follow logs: %w", err)
 			}
-			targetPeerID = peers[0].ID
-			fmt.Printf("Using discovered node: %s\n", targetPeerID)
+			return nil
 		}
 
 		// Fetch logs
-		fmt.Println("\nFetching logs...")
-		logsContent, err := common.FetchLogs(ctx, host, targetPeerID, appID, follow, tail, common.GlobalLogger)
+		out.Statusln("\nFetching logs...")
+		logsContent, err := common.FetchLogs(ctx, host, targetPeerID, appID, opts, common.GlobalLogger)
 		if err != nil {
 			return fmt.Errorf("failed to fetch logs: %w", err)
 		}
 
 		// Display logs
-		fmt.Println(logsContent)
-
-		return nil
+		result := logsResult{
+			NodeID: targetPeerID,
+			AppID:  appID,
+			Logs:   logsContent,
+		}
+		return out.Result(result, func() {
+			out.Println(logsContent)
+		})
 	},
 }
 
 func init() {
-	Cmd.Flags().StringVar(&nodeID, "node", "", "target node peer ID")
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
 	Cmd.Flags().BoolVarP(&follow, "follow", "f", false, "follow log output")
 	Cmd.Flags().IntVar(&tail, "tail", 50, "number of lines to show from the end")
+	Cmd.Flags().DurationVar(&since, "since", 0, "only show lines written within this duration, e.g. 10m (journal logs only)")
 }
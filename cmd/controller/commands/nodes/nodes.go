@@ -3,30 +3,156 @@ package nodes
 import (
 	"context"
 	"fmt"
-	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
 	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/spf13/cobra"
 )
 
+var (
+	watchMode bool
+	once      bool
+	timeout   time.Duration
+	interval  time.Duration
+)
+
+// nodeResult is the structured representation of a discovered node
+type nodeResult struct {
+	PeerID   string            `json:"peer_id"`
+	Name     string            `json:"name"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Addrs    []string          `json:"addrs"`
+	Version  string            `json:"version,omitempty"`
+	Commit   string            `json:"commit,omitempty"`
+	Devices  []string          `json:"devices,omitempty"`
+	Platform string            `json:"platform,omitempty"`
+	Degraded bool              `json:"degraded,omitempty"`
+	Alerts   []string          `json:"alerts,omitempty"`
+	Sources  []string          `json:"sources,omitempty"`
+	LastSeen time.Time         `json:"last_seen"`
+
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	UptimeSeconds int64      `json:"uptime_seconds,omitempty"`
+}
+
+// nodeEvent is emitted when a node is discovered, announces itself or is lost
+type nodeEvent struct {
+	Event string     `json:"event"`
+	Node  nodeResult `json:"node"`
+}
+
+// toNodeResult converts a discovered node to its structured representation
+func toNodeResult(node *discovery.DiscoveredNode) nodeResult {
+	result := nodeResult{
+		PeerID:   node.PeerID.String(),
+		Name:     node.Name,
+		Labels:   node.Labels,
+		Addrs:    node.Addrs,
+		Version:  node.Version,
+		Commit:   node.Commit,
+		Devices:  node.Devices,
+		Platform: node.Platform,
+		Degraded: node.Degraded,
+		Alerts:   node.Alerts,
+		Sources:  node.Sources,
+		LastSeen: node.LastSeen,
+	}
+	if !node.StartedAt.IsZero() {
+		result.StartedAt = &node.StartedAt
+		result.UptimeSeconds = int64(node.Uptime.Seconds())
+	}
+	return result
+}
+
+// describeVersion formats a node's version for display
+func describeVersion(node *discovery.DiscoveredNode) string {
+	if node.Version == "" {
+		return "unknown"
+	}
+	return version.Info{Version: node.Version, Commit: node.Commit}.String()
+}
+
+// printAlerts prints the alerts of a degraded node
+func printAlerts(node *discovery.DiscoveredNode, indent string) {
+	if !node.Degraded {
+		return
+	}
+	common.Out.Printf("%sStatus: degraded\n", indent)
+	for _, a := range node.Alerts {
+		common.Out.Printf("%s  ! %s\n", indent, a)
+	}
+}
+
+// printNode prints a node that was discovered or announced itself, as event
+func printNode(event string, title string, node *discovery.DiscoveredNode, total int) {
+	out := common.Out
+	_ = out.Record(nodeEvent{Event: event, Node: toNodeResult(node)}, func() {
+		out.Printf("\n%s:\n", title)
+		out.Printf("  Peer ID: %s\n", node.PeerID)
+		out.Printf("  Name: %s\n", node.Name)
+		out.Printf("  Version: %s\n", describeVersion(node))
+		if node.Platform != "" {
+			out.Printf("  Platform: %s\n", node.Platform)
+		}
+		if !node.StartedAt.IsZero() {
+			out.Printf("  Uptime: %s\n", node.Uptime)
+		}
+		if len(node.Labels) > 0 {
+			out.Printf("  Labels: %v\n", node.Labels)
+		}
+		if len(node.Devices) > 0 {
+			out.Printf("  Devices: %v\n", node.Devices)
+		}
+		printAlerts(node, "  ")
+		out.Printf("  Addresses: %v\n", node.Addrs)
+		out.Printf("  Found via: %s\n", strings.Join(node.Sources, ", "))
+		out.Printf("  (Total nodes: %d)\n", total)
+	})
+}
+
 // Cmd represents the nodes command
 var Cmd = &cobra.Command{
 	Use:   "nodes",
 	Short: "Discover and list P2P Playground nodes",
-	Long: `Continuously discover P2P Playground nodes using gossip protocol.
+	Long: `Discover P2P Playground nodes with the configured discovery
+backends (node.discovery): gossip announcements, mDNS, DHT rendezvous and
+static peers. A node found by several backends is listed once.
+
+By default the command prints every node as it is found or lost, and a
+summary when interrupted (Ctrl+C). With --watch it instead shows a table of
+the nodes with their labels, running/total apps, round trip time and when
+they were last seen, refreshed until interrupted. With --once it listens for
+--timeout, prints the table once and exits.
 
-This command will keep running until interrupted (Ctrl+C).
-It discovers nodes that are running the p2p-playground daemon.`,
+With --output json, events and the summary are JSON lines, --watch prints
+one {"time", "nodes"} line per refresh and --once prints the table as an array.
+
+Example:
+  controller nodes --watch
+  controller nodes --once --timeout 10s --output json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println("Discovering P2P Playground nodes...")
+		if cmd.Flags().Changed("timeout") && !once {
+			return fmt.Errorf("%w: --timeout needs --once", types.ErrInvalidInput)
+		}
+		if timeout <= 0 || interval <= 0 {
+			return fmt.Errorf("%w: --timeout and --interval must be positive", types.ErrInvalidInput)
+		}
+
+		out := common.Out
+		out.Statusln("Discovering P2P Playground nodes...")
 
 		// Create P2P host using configuration
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
+		interrupted, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
 
 		host, err := common.CreateP2PHost(ctx)
 		if err != nil {
@@ -34,74 +160,107 @@ It discovers nodes that are running the p2p-playground daemon.`,
 		}
 		defer func() { _ = host.Close() }()
 
-		fmt.Printf("Controller ID: %s\n", host.ID())
-		fmt.Printf("Controller addresses:\n")
+		out.Statusf("Controller ID: %s\n", host.ID())
+		out.Statusf("Controller addresses:\n")
 		for _, addr := range host.Addrs() {
-			fmt.Printf("  - %s\n", addr)
+			out.Statusf("  - %s\n", addr)
 		}
-		fmt.Println()
-
-		// Initialize discovery service
-		discoverySvc, err := discovery.NewService(host.LibP2PHost(), common.GlobalLogger, &discovery.Config{
-			NodeName:   "controller",
-			NodeLabels: nil,
-			Version:    "0.1.0",
-			Routing:    host.DHT(),
-		})
+		out.Statusln()
+
+		// Merge what every discovery backend finds
+		nodes, err := common.Discovery(host)
 		if err != nil {
-			return fmt.Errorf("failed to create discovery service: %w", err)
+			return err
 		}
 
-		// Track discovered nodes
-		discoveredNodes := make(map[string]*discovery.DiscoveredNode)
-
-		discoverySvc.SetOnNodeDiscovered(func(node *discovery.DiscoveredNode) {
-			discoveredNodes[node.PeerID.String()] = node
-			fmt.Printf("\n✓ New node discovered:\n")
-			fmt.Printf("  Peer ID: %s\n", node.PeerID)
-			fmt.Printf("  Name: %s\n", node.Name)
-			if len(node.Labels) > 0 {
-				fmt.Printf("  Labels: %v\n", node.Labels)
+		switch {
+		case watchMode:
+			return watch(interrupted, host, nodes, interval)
+		case once:
+			out.Statusf("Listening for nodes for %s...\n", timeout)
+			select {
+			case <-time.After(timeout):
+			case <-interrupted.Done():
 			}
-			fmt.Printf("  Addresses: %v\n", node.Addrs)
-			fmt.Printf("  (Total nodes: %d)\n", len(discoveredNodes))
-		})
+			rows := probe(ctx, host, nodes.Nodes())
+			return out.Result(rows, func() {
+				printTable(rows, time.Now())
+			})
+		}
 
-		discoverySvc.SetOnNodeLost(func(peerID peer.ID) {
-			if node, ok := discoveredNodes[peerID.String()]; ok {
-				fmt.Printf("\n✗ Node lost: %s (%s)\n", node.Name, peerID)
-				delete(discoveredNodes, peerID.String())
-				fmt.Printf("  (Total nodes: %d)\n", len(discoveredNodes))
-			}
-		})
+		events, unsubscribe := nodes.Subscribe()
+		defer unsubscribe()
 
-		discoverySvc.Start()
-		defer discoverySvc.Stop()
+		go func() {
+			// Nodes found by backends other than gossip get their details once they announce themselves
+			announced := make(map[peer.ID]bool)
+			for ev := range events {
+				node := ev.Node
+				switch {
+				case ev.Type == discovery.EventLost:
+					delete(announced, node.PeerID)
+					total := len(nodes.Nodes())
+					_ = out.Record(nodeEvent{Event: "lost", Node: toNodeResult(node)}, func() {
+						out.Printf("\n✗ Node lost: %s (%s)\n", node.Name, node.PeerID)
+						out.Printf("  (Total nodes: %d)\n", total)
+					})
+				case ev.Type == discovery.EventFound:
+					announced[node.PeerID] = node.Name != ""
+					printNode("discovered", "✓ New node discovered", node, len(nodes.Nodes()))
+				case !announced[node.PeerID] && node.Name != "":
+					announced[node.PeerID] = true
+					printNode("announced", "✓ Node announced", node, len(nodes.Nodes()))
+				}
+			}
+		}()
 
-		fmt.Println("\nListening for P2P Playground nodes... (Press Ctrl+C to stop)")
-		fmt.Println("Nodes will announce themselves every 10 seconds.")
+		out.Statusln("\nListening for P2P Playground nodes... (Press Ctrl+C to stop)")
+		out.Statusln("Nodes will announce themselves every 10 seconds.")
 
 		// Wait for interrupt signal
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-		<-sigCh
+		<-interrupted.Done()
 
-		fmt.Println("\n\nStopping discovery...")
+		out.Statusln("\n\nStopping discovery...")
 
 		// Print final summary
-		nodes := discoverySvc.GetNodes()
-		if len(nodes) == 0 {
-			fmt.Println("\nNo P2P Playground nodes discovered.")
-		} else {
-			fmt.Printf("\nDiscovered %d P2P Playground node(s):\n", len(nodes))
-			for i, node := range nodes {
-				fmt.Printf("%d. %s (%s)\n", i+1, node.Name, node.PeerID)
-				fmt.Printf("   Labels: %v\n", node.Labels)
-				fmt.Printf("   Addresses: %v\n", node.Addrs)
-				fmt.Printf("   Last seen: %s\n", node.LastSeen.Format("15:04:05"))
-			}
+		list := nodes.Nodes()
+		summary := make([]nodeResult, 0, len(list))
+		for _, node := range list {
+			summary = append(summary, toNodeResult(node))
 		}
 
-		return nil
+		return out.Record(summary, func() {
+			if len(list) == 0 {
+				out.Println("\nNo P2P Playground nodes discovered.")
+				return
+			}
+			out.Printf("\nDiscovered %d P2P Playground node(s):\n", len(list))
+			for i, node := range list {
+				out.Printf("%d. %s (%s)\n", i+1, node.Name, node.PeerID)
+				out.Printf("   Version: %s\n", describeVersion(node))
+				if node.Platform != "" {
+					out.Printf("   Platform: %s\n", node.Platform)
+				}
+				if !node.StartedAt.IsZero() {
+					out.Printf("   Uptime: %s (started %s)\n", node.Uptime, node.StartedAt.Format(time.RFC3339))
+				}
+				out.Printf("   Labels: %v\n", node.Labels)
+				if len(node.Devices) > 0 {
+					out.Printf("   Devices: %v\n", node.Devices)
+				}
+				printAlerts(node, "   ")
+				out.Printf("   Addresses: %v\n", node.Addrs)
+				out.Printf("   Found via: %s\n", strings.Join(node.Sources, ", "))
+				out.Printf("   Last seen: %s\n", node.LastSeen.Format("15:04:05"))
+			}
+		})
 	},
 }
+
+func init() {
+	Cmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "show a table of the nodes, refreshed until interrupted")
+	Cmd.Flags().BoolVar(&once, "once", false, "listen for --timeout, print the table of the nodes and exit")
+	Cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Second, "how long --once listens for nodes")
+	Cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "how often --watch refreshes the table")
+	Cmd.MarkFlagsMutuallyExclusive("watch", "once")
+}
@@ -0,0 +1,176 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+// probeTimeout bounds the app list and ping of one node when building a table
+const probeTimeout = 5 * time.Second
+
+// appCount is how many apps a node runs
+type appCount struct {
+	Total   int `json:"total"`
+	Running int `json:"running"`
+}
+
+// nodeRow is a node in the table of --watch and --once, with what the
+// controller measured itself
+type nodeRow struct {
+	nodeResult
+
+	// Apps is nil if the node did not answer the app list
+	Apps *appCount `json:"apps,omitempty"`
+
+	// RTTMillis is the round trip time of a ping, 0 if the node did not answer
+	RTTMillis float64 `json:"rtt_ms,omitempty"`
+}
+
+// snapshot is one refresh of --watch
+type snapshot struct {
+	Time  time.Time `json:"time"`
+	Nodes []nodeRow `json:"nodes"`
+}
+
+// probe lists the apps of every node and pings it, concurrently
+func probe(ctx context.Context, host *p2p.Host, list []*discovery.DiscoveredNode) []nodeRow {
+	rows := make([]nodeRow, len(list))
+	var wg sync.WaitGroup
+	for i, node := range list {
+		rows[i].nodeResult = toNodeResult(node)
+		wg.Add(1)
+		go func(row *nodeRow) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+			defer cancel()
+
+			if statuses, err := common.ListAppStatuses(ctx, host, row.PeerID, common.GlobalLogger); err == nil {
+				count := &appCount{Total: len(statuses)}
+				for _, status := range statuses {
+					if status.App.Status == types.AppStatusRunning {
+						count.Running++
+					}
+				}
+				row.Apps = count
+			}
+
+			select {
+			case res := <-ping.Ping(ctx, host.LibP2PHost(), node.PeerID):
+				if res.Error == nil {
+					row.RTTMillis = float64(res.RTT.Microseconds()) / 1000
+				}
+			case <-ctx.Done():
+			}
+		}(&rows[i])
+	}
+	wg.Wait()
+	return rows
+}
+
+// formatLabels formats labels as k=v pairs sorted by key
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// printTable prints rows as an aligned table
+func printTable(rows []nodeRow, now time.Time) {
+	if len(rows) == 0 {
+		common.Out.Println("No P2P Playground nodes discovered.")
+		return
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tPEER ID\tLABELS\tAPPS\tRTT\tLAST SEEN")
+	for _, row := range rows {
+		name := row.Name
+		if name == "" {
+			name = "-"
+		}
+		if row.Degraded {
+			name += " (degraded)"
+		}
+		apps := "-"
+		if row.Apps != nil {
+			apps = fmt.Sprintf("%d/%d", row.Apps.Running, row.Apps.Total)
+		}
+		rtt := "-"
+		if row.RTTMillis > 0 {
+			rtt = fmt.Sprintf("%.1fms", row.RTTMillis)
+		}
+		lastSeen := now.Sub(row.LastSeen).Truncate(time.Second).String() + " ago"
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, row.PeerID, formatLabels(row.Labels), apps, rtt, lastSeen)
+	}
+	_ = w.Flush()
+	common.Out.Printf("%s", b.String())
+}
+
+// isTerminal reports whether results go to a terminal, where --watch redraws the screen
+func isTerminal() bool {
+	f, ok := common.Out.Stdout().(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// watch redraws the table every interval until ctx is done. In JSON mode
+// every refresh is a snapshot record.
+func watch(ctx context.Context, host *p2p.Host, nodes *discovery.Merged, interval time.Duration) error {
+	out := common.Out
+	redraw := isTerminal()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		rows := probe(ctx, host, nodes.Nodes())
+		if ctx.Err() != nil {
+			return nil
+		}
+		err := out.Record(snapshot{Time: now.UTC(), Nodes: rows}, func() {
+			if redraw {
+				// Move to the top left and clear the screen
+				out.Printf("\033[H\033[2J")
+			} else {
+				out.Println()
+			}
+			out.Printf("%s  %d node(s), refreshing every %s (Ctrl+C to stop)\n\n", now.Format("15:04:05"), len(rows), interval)
+			printTable(rows, now)
+		})
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
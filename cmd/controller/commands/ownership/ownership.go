@@ -0,0 +1,85 @@
+package ownership
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+)
+
+// Cmd represents the ownership command
+var Cmd = &cobra.Command{
+	Use:   "ownership [app-name]",
+	Short: "Show which signing key owns each application",
+	Long: `Show the signing key that owns each application name on a target node.
+
+The first key to deploy an application name owns it; later versions signed by
+other keys are rejected. Ownership can only be changed by an admin on the node:
+
+  p2p-daemon daemon ownership transfer <app> --key new-owner.pub
+
+--node takes the peer ID or name of the node to query. If it is not
+specified, the local daemon is queried, or else the only node discovered.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+
+		var app string
+		if len(args) > 0 {
+			app = args[0]
+		}
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		resp, err := common.FetchOwnership(ctx, host, targetPeerID, app, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to fetch ownership: %w", err)
+		}
+
+		return out.Result(resp.Records, func() {
+			if !resp.Enabled {
+				out.Println("\nApp ownership is disabled on this node (security.disable_app_ownership)")
+				return
+			}
+
+			out.Printf("\nFound %d owned application(s):\n\n", len(resp.Records))
+			if len(resp.Records) == 0 {
+				out.Println("  (no owned applications)")
+				return
+			}
+
+			for _, rec := range resp.Records {
+				out.Printf("Application: %s\n", rec.App)
+				if rec.KeyName != "" {
+					out.Printf("   Key: %s\n", rec.KeyName)
+				}
+				out.Printf("   Public key: %s\n", rec.PublicKey)
+				out.Printf("   Claimed: %s\n", rec.ClaimedAt.Format("2006-01-02 15:04:05"))
+				if !rec.TransferredAt.IsZero() {
+					out.Printf("   Transferred: %s (previous key: %s)\n", rec.TransferredAt.Format("2006-01-02 15:04:05"), rec.PreviousPublicKey)
+				}
+				out.Println()
+			}
+		})
+	},
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+}
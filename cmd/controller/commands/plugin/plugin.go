@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/spf13/cobra"
+)
+
+// pluginResult is the structured representation of an installed plugin
+type pluginResult struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Cmd represents the plugin command
+var Cmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Work with controller plugins",
+	Long: `Plugins extend the controller with external binaries.
+
+Running "controller foo" executes the first "controller-foo" binary found in PATH
+when foo is not a built-in command; "controller foo bar" prefers "controller-foo-bar".
+Remaining arguments are passed to the plugin unchanged, and the global flags given
+before the command name are passed through the environment:
+
+  ` + common.PluginEnvConfig + `  config file from --config (empty for the default)
+  ` + common.PluginEnvNode + `    node from --node
+  ` + common.PluginEnvOutput + `  output format from --output (text or json)
+  ` + common.PluginEnvBinary + `     path of the controller binary
+
+The plugin's exit code becomes the controller's exit code.`,
+}
+
+// listCmd lists the plugins found in PATH
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List plugins found in PATH",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+
+		plugins := common.ListPlugins()
+		names := common.PluginNames(plugins)
+		results := make([]pluginResult, 0, len(names))
+		for _, name := range names {
+			results = append(results, pluginResult{Name: name, Path: plugins[name]})
+		}
+
+		return out.Result(results, func() {
+			if len(results) == 0 {
+				out.Println("No plugins found in PATH.")
+				return
+			}
+			for _, p := range results {
+				out.Printf("%-20s %s\n", p.Name, p.Path)
+			}
+		})
+	},
+}
+
+func init() {
+	Cmd.AddCommand(listCmd)
+}
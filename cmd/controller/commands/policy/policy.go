@@ -0,0 +1,157 @@
+package policy
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	pkgpolicy "github.com/asjdf/p2p-playground-lite/pkg/policy"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyFile string
+	signerName string
+	signed     bool
+)
+
+// testResult is the structured result of a policy test
+type testResult struct {
+	App        string                `json:"app"`
+	Version    string                `json:"version"`
+	Passed     bool                  `json:"passed"`
+	Violations []pkgpolicy.Violation `json:"violations"`
+}
+
+// Cmd represents the policy command
+var Cmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Work with manifest policy rules",
+}
+
+// testCmd evaluates policy rules against a manifest
+var testCmd = &cobra.Command{
+	Use:   "test [manifest.yaml | app-directory | package.tar.gz]",
+	Short: "Validate policy rules against a manifest",
+	Long: `Evaluate policy rules against an application manifest without deploying it.
+
+Rules are read from --policy, or from the policy section of the controller config.
+The same rules can be set in the daemon config's policy section, where they are enforced.
+
+Signer rules are only checked when --signer is given (a key name or hex public key).`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+
+		rules, err := loadRules()
+		if err != nil {
+			return err
+		}
+
+		manifest, err := loadManifest(args[0])
+		if err != nil {
+			return err
+		}
+
+		input := &pkgpolicy.Input{
+			Manifest: manifest,
+			Signed:   signed || signerName != "",
+		}
+		if signerName != "" {
+			input.Signer = &pkgpolicy.Signer{Name: signerName}
+			if key, err := decodeHexKey(signerName); err == nil {
+				input.Signer.PublicKey = key
+			}
+		}
+
+		violations := pkgpolicy.Evaluate(rules, input)
+		result := testResult{
+			App:        manifest.Name,
+			Version:    manifest.Version,
+			Passed:     len(violations) == 0,
+			Violations: violations,
+		}
+		if result.Violations == nil {
+			result.Violations = []pkgpolicy.Violation{}
+		}
+
+		if err := out.Result(result, func() {
+			if result.Passed {
+				out.Printf("✓ %s %s passes all policy rules\n", manifest.Name, manifest.Version)
+				return
+			}
+			out.Printf("✗ %s %s violates %d policy rule(s):\n", manifest.Name, manifest.Version, len(violations))
+			for _, v := range violations {
+				out.Printf("  - %s\n", v)
+			}
+		}); err != nil {
+			return err
+		}
+
+		if !result.Passed {
+			return fmt.Errorf("%w: %d rule(s) failed", types.ErrPolicyViolation, len(violations))
+		}
+		return nil
+	},
+}
+
+// loadRules returns the rules from --policy or the controller config
+func loadRules() (*config.PolicyConfig, error) {
+	if policyFile != "" {
+		return pkgpolicy.LoadFile(policyFile)
+	}
+	if common.GlobalConfig == nil || pkgpolicy.IsEmpty(&common.GlobalConfig.Policy) {
+		return nil, fmt.Errorf("no policy rules configured: pass --policy or set the policy section in the controller config")
+	}
+	return &common.GlobalConfig.Policy, nil
+}
+
+// loadManifest reads a manifest from a manifest file, application directory or package
+func loadManifest(path string) (*types.Manifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", path, err)
+	}
+
+	pkgMgr := pkgmanager.New()
+	switch {
+	case info.IsDir():
+		return pkgMgr.ReadManifest(filepath.Join(path, "manifest.yaml"))
+	case strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz"):
+		manifest, err := pkgMgr.GetManifest(context.Background(), path)
+		if err != nil {
+			return nil, err
+		}
+		return manifest, pkgmanager.ValidateManifest(manifest)
+	default:
+		return pkgMgr.ReadManifest(path)
+	}
+}
+
+// decodeHexKey decodes a hex-encoded Ed25519 public key
+func decodeHexKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("not an Ed25519 public key")
+	}
+	return key, nil
+}
+
+func init() {
+	testCmd.Flags().StringVar(&policyFile, "policy", "", "policy rules file (default: policy section of the controller config)")
+	testCmd.Flags().StringVar(&signerName, "signer", "", "signer key name or hex public key to check signer rules against")
+	testCmd.Flags().BoolVar(&signed, "signed", false, "treat the package as signed by an unknown key")
+
+	Cmd.AddCommand(testCmd)
+}
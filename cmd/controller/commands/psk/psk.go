@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
 	"github.com/asjdf/p2p-playground-lite/pkg/security"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +14,12 @@ var (
 	outputPath string
 )
 
+// pskResult is the structured result of PSK generation
+type pskResult struct {
+	File string `json:"file"`
+	PSK  string `json:"psk"`
+}
+
 // Cmd represents the psk command
 var Cmd = &cobra.Command{
 	Use:   "psk",
@@ -26,7 +33,8 @@ Example:
   controller psk
   controller psk --output ~/.p2p-playground/psk`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println("Generating pre-shared key...")
+		out := common.Out
+		out.Statusln("Generating pre-shared key...")
 
 		// Generate PSK
 		pskBytes, err := security.GeneratePSK()
@@ -51,23 +59,27 @@ Example:
 
 		encoded := security.EncodePSK(pskBytes)
 
-		fmt.Println()
-		fmt.Println("✓ PSK generated successfully!")
-		fmt.Printf("  File: %s\n", outPath)
-		fmt.Println()
-		fmt.Println("⚠️  Keep this key secure and never share it publicly.")
-		fmt.Println("📤 Distribute this key to all nodes that should join your network.")
-		fmt.Println()
-		fmt.Printf("PSK (hex): %s\n", encoded)
-		fmt.Println()
-		fmt.Println("To use this PSK, add the following to your configuration:")
-		fmt.Println()
-		fmt.Println("  security:")
-		fmt.Println("    enable_auth: true")
-		fmt.Printf("    psk: \"%s\"\n", encoded)
-		fmt.Println()
-
-		return nil
+		result := pskResult{
+			File: outPath,
+			PSK:  encoded,
+		}
+		return out.Result(result, func() {
+			out.Println()
+			out.Println("✓ PSK generated successfully!")
+			out.Printf("  File: %s\n", outPath)
+			out.Println()
+			out.Println("⚠️  Keep this key secure and never share it publicly.")
+			out.Println("📤 Distribute this key to all nodes that should join your network.")
+			out.Println()
+			out.Printf("PSK (hex): %s\n", encoded)
+			out.Println()
+			out.Println("To use this PSK, add the following to your configuration:")
+			out.Println()
+			out.Println("  security:")
+			out.Println("    enable_auth: true")
+			out.Printf("    psk: \"%s\"\n", encoded)
+			out.Println()
+		})
 	},
 }
 
@@ -0,0 +1,88 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+)
+
+// Cmd represents the quota command
+var Cmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Show the quota usage of each operator on a node",
+	Long: `Show what each operator uses of its quota on a target node: deployed apps,
+their total package size, and deployments made in the last hour.
+
+Operators are identified by the key signing their packages; unsigned packages
+all count towards the operator "unsigned". Limits are set in the quotas section
+of the daemon config, and a deployment exceeding one fails with QUOTA_EXCEEDED.
+
+--node takes the peer ID or name of the node to query. If it is not
+specified, the local daemon is queried, or else the only node discovered.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		node, err := common.FetchNodeInfo(ctx, host, targetPeerID, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to fetch node info: %w", err)
+		}
+
+		usage := node.Quotas
+		if usage == nil {
+			usage = []*types.QuotaUsage{}
+		}
+		return out.Result(usage, func() {
+			out.Printf("\nFound %d operator(s):\n\n", len(usage))
+			if len(usage) == 0 {
+				out.Println("  (nothing deployed)")
+				return
+			}
+
+			for _, u := range usage {
+				if u.Name != "" {
+					out.Printf("Operator: %s\n", u.Name)
+					out.Printf("   Public key: %s\n", u.Operator)
+				} else {
+					out.Printf("Operator: %s\n", u.Operator)
+				}
+				out.Printf("   Apps: %s\n", limited(int64(u.Apps), int64(u.MaxApps)))
+				out.Printf("   Package bytes: %s\n", limited(u.PackageBytes, u.MaxPackageBytes))
+				out.Printf("   Deploys in the last hour: %s\n", limited(int64(u.DeploysLastHour), int64(u.MaxDeploysPerHour)))
+				out.Println()
+			}
+		})
+	},
+}
+
+// limited formats usage against a limit, 0 being unlimited
+func limited(used, limit int64) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d (unlimited)", used)
+	}
+	return fmt.Sprintf("%d of %d", used, limit)
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+}
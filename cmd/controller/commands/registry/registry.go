@@ -0,0 +1,237 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	pkgregistry "github.com/asjdf/p2p-playground-lite/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection   common.Selection
+	autoStart   bool
+	forceUnlock bool
+	waitHealthy bool
+	timeout     time.Duration
+	queueOpts   common.QueueOptions
+)
+
+// pushResult is the structured result of pushing a package
+type pushResult struct {
+	Package *pkgregistry.Package `json:"package"`
+	Pushed  bool                 `json:"pushed"` // False if the registry had the package already
+}
+
+// deployResult is the structured result of deploying a package of the registry to a node
+type deployResult struct {
+	NodeID  string `json:"node_id"`
+	AppID   string `json:"app_id"`
+	Started bool   `json:"started"`
+	Healthy bool   `json:"healthy,omitempty"`
+}
+
+// Cmd represents the registry command
+var Cmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Keep packages in an S3-compatible registry and deploy them from it",
+	Long: `Keep signed packages in a registry, an S3-compatible bucket, and have nodes
+deploy them from it by digest.
+
+A package pushed to the registry is kept by the SHA-256 digest of its file,
+with its signature, so it outlives the disks of the controller and of any
+node. Nodes configured with the same bucket (registry.s3 in the daemon
+config) fetch the package themselves when told its digest, and keep it in a
+local cache for later deployments. The controller, and its uplink, only
+sends the request. Each node verifies the package against its own trusted
+keys and policy, exactly like a package sent to it.
+
+'controller deploy' and 'controller transfer' still send packages over the
+P2P network, which suits packages under active development.
+
+The registry of the controller is registry.s3 in its config.`,
+}
+
+// pushCmd pushes a package to the registry
+var pushCmd = &cobra.Command{
+	Use:   "push <package>",
+	Short: "Upload a package, and its signature, to the registry",
+	Long: `Upload a package to the registry, with its signature from <package>.sig if
+it was signed ('controller sign'), and print its digest. A package the
+registry has already is not uploaded again.
+
+Example:
+  controller registry push web-1.0.0.tar.gz
+  controller registry deploy sha256:4f9c... --all`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bucket, err := common.OpenRegistry()
+		if err != nil {
+			return err
+		}
+
+		out := common.Out
+		out.Statusf("Pushing %s to %s...\n", args[0], bucket)
+		pkg, pushed, err := common.PushPackage(context.Background(), bucket, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to push %s: %w", args[0], err)
+		}
+
+		return out.Result(pushResult{Package: pkg, Pushed: pushed}, func() {
+			if pushed {
+				out.Printf("✓ Pushed %s %s (%d bytes)\n", pkg.Name, pkg.Version, pkg.Size)
+			} else {
+				out.Printf("✓ The registry has %s %s already\n", pkg.Name, pkg.Version)
+			}
+			out.Printf("  Digest: %s\n", pkg.Digest)
+			if len(pkg.Signature) == 0 {
+				out.Println("  Warning: the package is not signed; nodes refuse it unless they allow unsigned packages")
+			}
+		})
+	},
+}
+
+// listCmd lists the packages of the registry
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the packages in the registry, most recently pushed first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bucket, err := common.OpenRegistry()
+		if err != nil {
+			return err
+		}
+		packages, err := bucket.List(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", bucket, err)
+		}
+		if packages == nil {
+			packages = []*pkgregistry.Package{}
+		}
+
+		out := common.Out
+		return out.Result(packages, func() {
+			if len(packages) == 0 {
+				out.Println("No packages")
+				return
+			}
+			for _, pkg := range packages {
+				signed := "unsigned"
+				if len(pkg.Signature) > 0 {
+					signed = "signed"
+				}
+				out.Printf("%s  %-30s %10d bytes  %-8s  %s\n", pkg.PushedAt.Local().Format("2006-01-02 15:04:05"), pkg.Name+" "+pkg.Version, pkg.Size, signed, pkg.Digest)
+			}
+		})
+	},
+}
+
+// deployCmd deploys a package of the registry
+var deployCmd = &cobra.Command{
+	Use:   "deploy <digest>",
+	Short: "Have nodes deploy a package of the registry",
+	Long: `Have the selected nodes deploy the package of the registry with the given
+digest (as printed by 'controller registry push'). Each node fetches the
+package from its registry, unless it has it cached, and deploys it like a
+package sent to it.
+
+--node takes the peer ID or name of the target node. If it is not specified,
+the local daemon is used, or else the only node discovered. --selector
+deploys to every node with the given labels and --all to every node
+discovered, up to --concurrency nodes at a time.
+
+Example:
+  controller registry deploy sha256:4f9c... --selector env=lab --wait-healthy`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		digest, err := pkgregistry.ParseDigest(args[0])
+		if err != nil {
+			return err
+		}
+		digest = pkgregistry.DigestPrefix + digest
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targets, err := common.ResolveNodes(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		out.Statusf("Deploying %s...\n", digest)
+		queue := queueOpts.WithDefaults(&common.GlobalConfig.Deployment)
+		opts := common.DeployOptions{
+			AutoStart:     autoStart,
+			ForceUnlock:   forceUnlock,
+			WaitHealthy:   waitHealthy,
+			HealthTimeout: timeout,
+			Quiet:         queue.Parallel(len(targets)),
+		}
+		nodeResults := common.RunQueue(ctx, host, targets, queue, func(ctx context.Context, nodeID string) (string, error) {
+			return common.DeployDigest(ctx, host, nodeID, digest, opts, common.GlobalLogger)
+		})
+		results := make([]deployResult, 0, len(targets))
+		var failed []error
+		for _, res := range nodeResults {
+			if res.Err != nil {
+				if !selection.Multiple() {
+					return fmt.Errorf("deployment failed: %w", res.Err)
+				}
+				failed = append(failed, res.Err)
+				continue
+			}
+			results = append(results, deployResult{
+				NodeID:  res.NodeID,
+				AppID:   res.Value,
+				Started: autoStart,
+				Healthy: autoStart && waitHealthy,
+			})
+		}
+
+		var value interface{} = results
+		if !selection.Multiple() {
+			value = results[0]
+		}
+		err = out.Result(value, func() {
+			for _, result := range results {
+				out.Printf("\n✓ Deployed to %s\n", result.NodeID)
+				out.Printf("  Application ID: %s\n", result.AppID)
+				if result.Healthy {
+					out.Printf("  Status: Started and healthy\n")
+				} else if autoStart {
+					out.Printf("  Status: Started\n")
+				} else {
+					out.Printf("  Status: Deployed (not started)\n")
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("deployment failed on %d of %d node(s): %w", len(failed), len(targets), failed[0])
+		}
+		return nil
+	},
+}
+
+func init() {
+	common.AddSelectionFlags(deployCmd.Flags(), &selection, true)
+	deployCmd.Flags().BoolVar(&autoStart, "start", true, "automatically start the application after deployment")
+	deployCmd.Flags().BoolVar(&forceUnlock, "force-unlock", false, "break the node's lock on the app held by another operation (admin escape hatch)")
+	deployCmd.Flags().BoolVar(&waitHealthy, "wait-healthy", false, "report success only once the app passes its health check on the node")
+	deployCmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "how long --wait-healthy waits for the app to become healthy")
+	common.AddQueueFlags(deployCmd.Flags(), &queueOpts)
+
+	Cmd.AddCommand(pushCmd)
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(deployCmd)
+}
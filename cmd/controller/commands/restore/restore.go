@@ -0,0 +1,88 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	pkgbackup "github.com/asjdf/p2p-playground-lite/pkg/backup"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+	snapshot  string
+	from      string
+)
+
+// restoreResult is the structured result of a restore
+type restoreResult struct {
+	From     string           `json:"from,omitempty"`
+	Snapshot *volume.Snapshot `json:"snapshot"`
+}
+
+// Cmd represents the restore command
+var Cmd = &cobra.Command{
+	Use:   "restore <app>",
+	Short: "Restore the volumes of an app from a snapshot",
+	Long: `Replace the volumes of an app on a node with a snapshot taken by
+'controller backup', the latest one unless --snapshot names another.
+
+The snapshot is one the node keeps, or, with --from, one kept by another
+node (peer ID or name) or in the bucket the node is configured with (s3).
+This moves an app to another node with its data: back it up to the new
+node, deploy it there, then restore. The archive is checked against its
+checksum before the volumes are replaced. Running instances of the app are
+stopped meanwhile and started again; a paused app must be resumed first.
+
+--node takes the peer ID or name of the node whose volumes are restored. If
+it is not specified, the local daemon is used, or else the only node
+discovered.
+
+Example:
+  controller restore web --node lab-2 --from lab-1
+  controller restore web --snapshot web-20261015T101500.000Z --from s3`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app := args[0]
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		peerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+		source := from
+		if source != "" && source != pkgbackup.TargetS3 {
+			if source, err = common.ResolveNode(ctx, host, &common.Selection{Node: from}); err != nil {
+				return err
+			}
+		}
+
+		common.Out.Statusf("Restoring the volumes of %s...\n", app)
+		resp, err := common.RestoreVolumes(ctx, host, peerID, app, snapshot, source, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", app, err)
+		}
+
+		out := common.Out
+		snap := resp.Snapshot
+		return out.Result(restoreResult{From: source, Snapshot: snap}, func() {
+			out.Printf("✓ Restored %v of %s from snapshot %s\n", snap.Volumes, app, snap.ID)
+			out.Printf("  Taken: %s\n", snap.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		})
+	},
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+	Cmd.Flags().StringVar(&snapshot, "snapshot", "", "ID of the snapshot to restore (default: the latest)")
+	Cmd.Flags().StringVar(&from, "from", "", "node (peer ID or name) or s3 to restore the snapshot from (default: the node itself)")
+}
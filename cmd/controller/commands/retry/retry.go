@@ -0,0 +1,176 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportPath string
+	queueOpts  common.QueueOptions
+)
+
+// retryResult is the structured result of a retry
+type retryResult struct {
+	Operation string              `json:"operation"`
+	Nodes     []common.NodeReport `json:"nodes"`     // Results on the nodes retried
+	Remaining []string            `json:"remaining"` // Nodes the operation still failed on
+}
+
+// Cmd represents the retry command
+var Cmd = &cobra.Command{
+	Use:   "retry [operation-id]",
+	Short: "Deploy again to the nodes a deploy or run failed on",
+	Long: `Deploy again to just the nodes where a deploy or run to several nodes failed,
+with the same package and parameters.
+
+A deploy or run failing on some of its nodes is kept under an operation ID,
+which it prints and records in its --report, with a copy of the package (and
+its signature). Without an operation ID, the operations kept are listed.
+
+Retrying deploys the kept package to the failed nodes with the options of the
+operation (--start, --wait-healthy and its --timeout, --force-unlock), through
+the same queue, and records the new results in the operation. Once no node
+failed, the operation and its package are removed. --concurrency, --retries
+and --retry-delay override those the operation ran with. A retried run does
+not stream the logs of the app.
+
+The 20 most recently updated operations are kept.
+
+Example:
+  controller retry
+  controller retry 3f9a2c71be04 --report retry.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if len(args) == 0 {
+			return listOperations()
+		}
+		out := common.Out
+
+		op, err := common.LoadOperation(args[0])
+		if err != nil {
+			return err
+		}
+		nodes := op.Failed()
+		fileInfo, err := os.Stat(op.Package)
+		if err != nil {
+			return fmt.Errorf("failed to access package of operation %s: %w", op.ID, err)
+		}
+
+		report := common.NewDeployReport("retry")
+		report.Operation = op.ID
+		if reportPath != "" {
+			defer func() {
+				report.Finish(err)
+				if writeErr := report.Write(reportPath); writeErr != nil && err == nil {
+					err = writeErr
+				}
+			}()
+			if err := report.SetPackage(op.Package, nil); err != nil {
+				return err
+			}
+			report.App, report.Version = op.App, op.Version
+		}
+
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+		for _, nodeID := range nodes {
+			if err := common.CheckTrusted(host, nodeID); err != nil {
+				return err
+			}
+		}
+		if err := common.LocateNodes(ctx, host, nodes); err != nil {
+			return err
+		}
+
+		out.Statusf("Retrying %s of %s %s on %d failed node(s)...\n", op.Command, op.App, op.Version, len(nodes))
+		queue := op.QueueOptions()
+		if queueOpts.Concurrency > 0 {
+			queue.Concurrency = queueOpts.Concurrency
+		}
+		if queueOpts.Retries >= 0 {
+			queue.Retries = queueOpts.Retries
+		}
+		if queueOpts.RetryDelay > 0 {
+			queue.RetryDelay = queueOpts.RetryDelay
+		}
+		queue = queue.WithDefaults(&common.GlobalConfig.Deployment)
+		opts := op.DeployOptions()
+		opts.Quiet = queue.Parallel(len(nodes))
+		results := common.RunQueue(ctx, host, nodes, queue, func(ctx context.Context, nodeID string) (string, error) {
+			return common.DeployPackage(ctx, host, nodeID, op.Package, fileInfo.Size(), opts, common.GlobalLogger)
+		})
+
+		var failed []error
+		for _, res := range results {
+			report.AddResult(res, opts)
+			if res.Err != nil {
+				failed = append(failed, res.Err)
+			}
+		}
+		op.Update(report)
+		if err := common.SaveOperation(op); err != nil {
+			common.GlobalLogger.Warn("failed to record the retry in the operation", "error", err)
+		}
+
+		result := retryResult{Operation: op.ID, Nodes: report.Nodes, Remaining: op.Failed()}
+		if result.Remaining == nil {
+			result.Remaining = []string{}
+		}
+		err = out.Result(result, func() {
+			for _, node := range report.Nodes {
+				if node.Success {
+					out.Printf("\n✓ Deployed to %s\n", node.NodeID)
+					out.Printf("  Application ID: %s\n", node.AppID)
+				} else {
+					out.Printf("\n✗ %s: %s\n", node.NodeID, node.Error)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			out.Statusf("\nRetry the %d node(s) still failing with: controller retry %s\n", len(failed), op.ID)
+			// Keep the exit code of the first failure, e.g. an unhealthy app
+			return fmt.Errorf("retry failed on %d of %d node(s): %w", len(failed), len(nodes), failed[0])
+		}
+		return nil
+	},
+}
+
+// listOperations lists the operations kept for retrying
+func listOperations() error {
+	out := common.Out
+	ops, err := common.ListOperations()
+	if err != nil {
+		return err
+	}
+	if ops == nil {
+		ops = []*common.Operation{}
+	}
+
+	return out.Result(ops, func() {
+		if len(ops) == 0 {
+			out.Println("No failed operations kept")
+			return
+		}
+		for _, op := range ops {
+			out.Printf("%s  %-7s  %s %s  failed on %d of %d node(s)  %s\n",
+				op.ID, op.Command, op.App, op.Version, len(op.Failed()), len(op.Nodes), op.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+	})
+}
+
+func init() {
+	Cmd.Flags().StringVar(&reportPath, "report", "", "write a JSON summary of the retry to this file")
+	common.AddQueueFlags(Cmd.Flags(), &queueOpts)
+}
@@ -0,0 +1,137 @@
+package rollback
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection   common.Selection
+	toRevision  int64
+	list        bool
+	forceUnlock bool
+	waitHealthy bool
+	timeout     time.Duration
+)
+
+// rollbackResult is the structured result of a rollback
+type rollbackResult struct {
+	NodeID     string `json:"node_id"`
+	AppID      string `json:"app_id"`
+	Revision   int64  `json:"revision"`
+	RollbackTo int64  `json:"rollback_to,omitempty"`
+	Healthy    bool   `json:"healthy,omitempty"`
+}
+
+// Cmd represents the rollback command
+var Cmd = &cobra.Command{
+	Use:   "rollback <app>",
+	Short: "Redeploy an earlier revision of an application",
+	Long: `Redeploy an earlier revision of an application on a node.
+
+Every deployment of an application on a node is numbered with a revision,
+shown by 'controller list' and in the app events. The node keeps the last
+revisions of each application with their packages; --list shows them.
+
+The revision given by --to-revision, by default the one before the latest,
+is deployed again and started as a new revision, so rolling back can itself
+be rolled back. The package is redeployed only if it is unchanged since that
+revision was deployed. As it passed admission then, admission hooks and
+quotas are not applied again.
+
+--force-unlock, --wait-healthy and --timeout work as for 'controller deploy'.
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is used, or else the only node discovered.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+		app := args[0]
+		if toRevision < 0 {
+			return fmt.Errorf("%w: --to-revision must be positive", types.ErrInvalidInput)
+		}
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		req := common.RollbackRequest{
+			App:           app,
+			Revision:      toRevision,
+			List:          list,
+			ForceUnlock:   forceUnlock,
+			WaitHealthy:   waitHealthy,
+			HealthTimeout: timeout,
+		}
+		if !list {
+			out.Statusf("Rolling back %s on %s...\n", app, targetPeerID)
+		}
+		resp, err := common.Rollback(ctx, host, targetPeerID, req, common.GlobalLogger)
+		if err != nil {
+			if list {
+				return fmt.Errorf("failed to list revisions of %s: %w", app, err)
+			}
+			return fmt.Errorf("rollback failed: %w", err)
+		}
+
+		if list {
+			return out.Result(resp.Revisions, func() {
+				out.Printf("\n%-8s  %-30s  %-19s  %s\n", "REVISION", "APP ID", "DEPLOYED", "NOTE")
+				for _, rev := range resp.Revisions {
+					note := ""
+					if rev.RollbackTo > 0 {
+						note = fmt.Sprintf("rollback to %d", rev.RollbackTo)
+					}
+					if rev.Signer != "" {
+						if note != "" {
+							note += ", "
+						}
+						note += "signed by " + rev.Signer
+					}
+					out.Printf("%-8d  %-30s  %-19s  %s\n", rev.Number, rev.AppID, rev.DeployedAt.Local().Format("2006-01-02 15:04:05"), note)
+				}
+			})
+		}
+
+		result := rollbackResult{
+			NodeID:     targetPeerID,
+			AppID:      resp.AppID,
+			Revision:   resp.Revision,
+			RollbackTo: resp.RollbackTo,
+			Healthy:    waitHealthy,
+		}
+		return out.Result(result, func() {
+			out.Printf("\n✓ Rollback successful!\n")
+			out.Printf("  Application ID: %s\n", result.AppID)
+			out.Printf("  Revision: %d (revision %d redeployed)\n", result.Revision, result.RollbackTo)
+			if result.Healthy {
+				out.Printf("  Status: Started and healthy\n")
+			} else {
+				out.Printf("  Status: Started\n")
+			}
+		})
+	},
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+	Cmd.Flags().Int64Var(&toRevision, "to-revision", 0, "revision to redeploy (default: the one before the latest)")
+	Cmd.Flags().BoolVar(&list, "list", false, "list the revisions the node keeps instead of rolling back")
+	Cmd.Flags().BoolVar(&forceUnlock, "force-unlock", false, "break the node's lock on the app held by another operation (admin escape hatch)")
+	Cmd.Flags().BoolVar(&waitHealthy, "wait-healthy", false, "report success only once the app passes its health check on the node")
+	Cmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "how long --wait-healthy waits for the app to become healthy")
+}
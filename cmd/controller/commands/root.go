@@ -1,44 +1,130 @@
 package commands
 
 import (
+	"os"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/audit"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/backup"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/bench"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/cache"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/cideploy"
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/control"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/coredump"
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/deploy"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/describe"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/devcluster"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/diff"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/events"
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/keygen"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/kv"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/label"
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/list"
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/logs"
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/nodes"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/ownership"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/plugin"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/policy"
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/psk"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/quota"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/registry"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/restore"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/retry"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/rollback"
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/run"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/schema"
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/sign"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/status"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/token"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/top"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/transfer"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/verify"
+	versioncmd "github.com/asjdf/p2p-playground-lite/cmd/controller/commands/version"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cfgFile string
+	cfgFile      string
+	outputFormat string
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "controller",
 	Short: "P2P Playground controller",
-	Long:  `Controller for P2P Playground - deploy and manage applications across P2P nodes.`,
+	Long: `Controller for P2P Playground - deploy and manage applications across P2P nodes.
+
+Other commands run controller-<name> plugins found in PATH (see "controller plugin --help").
+
+` + common.ExitCodeHelp,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		return common.InitConfig(cfgFile)
+		return common.InitConfig(cfgFile, outputFormat)
 	},
 }
 
 func init() {
+	rootCmd.Version = version.Get().String()
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default: ~/.p2p-playground/controller.yaml)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "output format for command results: text or json")
+	rootCmd.PersistentFlags().BoolVar(&common.TrustedOnly, "trusted-only", false, "connect to the peers in security.trusted_peers only, not even to other bootstrap peers or relays")
 
 	rootCmd.AddCommand(deploy.Cmd)
+	rootCmd.AddCommand(rollback.Cmd)
+	rootCmd.AddCommand(transfer.Cmd)
+	rootCmd.AddCommand(retry.Cmd)
+	rootCmd.AddCommand(describe.Cmd)
+	rootCmd.AddCommand(diff.Cmd)
+	rootCmd.AddCommand(cideploy.Cmd)
 	rootCmd.AddCommand(list.Cmd)
 	rootCmd.AddCommand(logs.Cmd)
+	rootCmd.AddCommand(status.Cmd)
+	rootCmd.AddCommand(top.Cmd)
+	rootCmd.AddCommand(events.Cmd)
 	rootCmd.AddCommand(nodes.Cmd)
+	rootCmd.AddCommand(bench.Cmd)
 	rootCmd.AddCommand(run.Cmd)
 	rootCmd.AddCommand(keygen.Cmd)
 	rootCmd.AddCommand(sign.Cmd)
 	rootCmd.AddCommand(psk.Cmd)
+	rootCmd.AddCommand(policy.Cmd)
+	rootCmd.AddCommand(ownership.Cmd)
+	rootCmd.AddCommand(quota.Cmd)
+	rootCmd.AddCommand(audit.Cmd)
+	rootCmd.AddCommand(plugin.Cmd)
+	rootCmd.AddCommand(cache.Cmd)
+	rootCmd.AddCommand(kv.Cmd)
+	rootCmd.AddCommand(devcluster.Cmd)
+	rootCmd.AddCommand(token.Cmd)
+	rootCmd.AddCommand(verify.Cmd)
+	rootCmd.AddCommand(label.Cmd)
+	rootCmd.AddCommand(control.PauseCmd)
+	rootCmd.AddCommand(control.ResumeCmd)
+	rootCmd.AddCommand(coredump.Cmd)
+	rootCmd.AddCommand(backup.Cmd)
+	rootCmd.AddCommand(restore.Cmd)
+	rootCmd.AddCommand(registry.Cmd)
+	rootCmd.AddCommand(schema.Cmd)
+	rootCmd.AddCommand(versioncmd.Cmd)
 }
 
 func Execute() error {
+	// Unknown commands run the matching controller-<name> plugin from PATH, if any
+	if p, ok := common.FindPlugin(os.Args[1:], isBuiltin); ok {
+		return p.Run()
+	}
 	return rootCmd.Execute()
 }
+
+// isBuiltin reports whether name is a command of the controller itself
+func isBuiltin(name string) bool {
+	switch name {
+	case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	for _, cmd := range rootCmd.Commands() {
+		if cmd.Name() == name || cmd.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,9 @@
 package run
 
 import (
-	"bufio"
 	"context"
-	"encoding/binary"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -15,19 +12,26 @@ import (
 	"time"
 
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
-	"github.com/asjdf/p2p-playground-lite/pkg/consts"
 	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
 	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/policy"
 	"github.com/asjdf/p2p-playground-lite/pkg/security"
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
 	"github.com/spf13/cobra"
 )
 
 var (
-	nodeID     string
-	cleanup    bool
-	noSign     bool
-	privateKey string
+	selection   common.Selection
+	cleanup     bool
+	noCache     bool
+	noSign      bool
+	privateKey  string
+	dryRun      bool
+	waitHealthy bool
+	timeout     time.Duration
+	reportPath  string
+	overrides   common.Overrides
+	queueOpts   common.QueueOptions
 )
 
 // Cmd represents the run command
@@ -40,15 +44,68 @@ This command is similar to 'go run' but for P2P Playground applications:
 1. Discovers available nodes in the network
 2. Builds the application package from the directory
 3. Optionally signs the package
-4. Deploys to all discovered nodes (or specified node with --node)
+4. Deploys to all discovered nodes (or the nodes selected with --node or --selector)
 5. Streams logs in real-time with format: [node-id] original log
 
 By default, the application is deployed to ALL discovered nodes in the network.
-Use --node to deploy to a specific node only.`,
+Use --node to deploy to a single node, by peer ID or name, or --selector to
+deploy to the nodes with the given labels, e.g. --selector env=lab.
+
+Up to --concurrency nodes (default: deployment.concurrency) are deployed to at
+once, and each is reported as it finishes. Attempts that fail to reach a node,
+time out, or find the app locked are retried up to --retries times with
+exponential backoff; a node rejecting the package is not retried.
+
+The package is built once per version of the sources: while the app directory
+and signing key are unchanged, the package cached by an earlier run or deploy is
+reused without packing and signing again (see 'controller cache ls'). With
+--no-cache, the package is built in a temporary workspace instead.
+
+With --dry-run, nodes are discovered and the package is built and signed, then the
+planned actions are reported without transferring or starting anything.
+
+With --wait-healthy, logs are only streamed once the app passes its health check
+on every node within --timeout; nodes where it does not roll back to the previous
+version, and the command fails with exit code 6.
+
+With --report, a JSON summary of the deployment (package checksum, per-node
+result, durations, health outcome) is written to the given file once every node
+has answered, also when the deployment fails.
+
+When the deployment fails on some of several nodes, it is kept with a copy of
+the package under an operation ID, also recorded in the report, and
+'controller retry <operation-id>' deploys to just the failed nodes again
+(without streaming their logs).
+
+--set and --set-args patch the manifest for this run without editing
+manifest.yaml, e.g. --set env.FOO=bar --set resources.memory_mb=256 or
+--set-args --verbose. The package is then built in a workspace, not cached.`,
 	Args: cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		appDir := args[0]
-		ctx := context.Background()
+
+		// Interrupting cancels whatever is in progress, so the workspace is still cleaned up
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		out := common.Out
+
+		// The report covers the deployment, not the log streaming after it
+		report := common.NewDeployReport("run")
+		report.DryRun = dryRun
+		reported := false
+		writeReport := func(err error) error {
+			if reportPath == "" || reported {
+				return nil
+			}
+			reported = true
+			report.Finish(err)
+			return report.Write(reportPath)
+		}
+		defer func() {
+			if writeErr := writeReport(err); writeErr != nil && err == nil {
+				err = writeErr
+			}
+		}()
 
 		// Verify app directory exists and has manifest
 		manifestPath := filepath.Join(appDir, "manifest.yaml")
@@ -57,7 +114,7 @@ Use --node to deploy to a specific node only.`,
 		}
 
 		// Simply display the directory for now
-		fmt.Printf("Building and running application from: %s\n", appDir)
+		out.Statusf("Building and running application from: %s\n", appDir)
 
 		// Create P2P host
 		host, err := common.CreateP2PHost(ctx)
@@ -66,76 +123,84 @@ Use --node to deploy to a specific node only.`,
 		}
 		defer func() { _ = host.Close() }()
 
-		fmt.Printf("Controller ID: %s\n", host.ID())
-
-		// Discover nodes
-		fmt.Println("\nDiscovering nodes...")
-		time.Sleep(3 * time.Second)
-
-		var targetPeerIDs []string
-		if nodeID != "" {
-			targetPeerIDs = []string{nodeID}
-			fmt.Printf("Using specified node: %s\n", nodeID)
-		} else {
-			peers := host.Peers()
-			if len(peers) == 0 {
-				return fmt.Errorf("no nodes discovered")
-			}
+		out.Statusf("Controller ID: %s\n", host.ID())
 
-			// List all discovered nodes
-			fmt.Printf("\nDiscovered %d node(s):\n", len(peers))
-			for i, peer := range peers {
-				fmt.Printf("%d. Peer ID: %s\n", i+1, peer.ID)
-				fmt.Printf("   Addresses:\n")
-				for _, addr := range peer.Addrs {
-					fmt.Printf("     - %s\n", addr)
-				}
-				targetPeerIDs = append(targetPeerIDs, peer.ID)
-			}
-			fmt.Println()
-			fmt.Printf("Deploying to all %d node(s)\n", len(targetPeerIDs))
+		// Deploy to every node unless told otherwise
+		sel := selection
+		if sel.IsEmpty() {
+			sel.All = true
 		}
-
-		// Build package
-		fmt.Println("\nBuilding application package...")
-		pkgMgr := pkgmanager.New()
-		pkgPath, err := pkgMgr.Pack(ctx, appDir)
+		targetPeerIDs, err := common.ResolveNodes(ctx, host, &sel)
 		if err != nil {
-			return fmt.Errorf("failed to build package: %w", err)
-		}
-		fmt.Printf("Package created: %s\n", pkgPath)
-
-		// Cleanup package file after deployment if requested
-		if cleanup {
-			defer func() {
-				_ = os.Remove(pkgPath)
-				_ = os.Remove(pkgPath + ".sig")
-			}()
+			return err
 		}
+		out.Statusf("Deploying to %d node(s)\n", len(targetPeerIDs))
 
-		// Sign package if requested
-		var signature []byte
+		// The signing key is loaded first as cached packages are keyed by it
+		var signer *security.Signer
+		var signerID *policy.Signer
 		if !noSign && privateKey != "" {
-			fmt.Println("\nSigning package...")
-			signer, err := security.LoadSigner(privateKey)
+			signer, err = security.LoadSigner(privateKey)
 			if err != nil {
 				return fmt.Errorf("failed to load private key: %w", err)
 			}
+			signerID = common.SignerIdentity(privateKey, signer)
+		} else if !noSign {
+			common.GlobalLogger.Warn("no private key specified, deploying without signature")
+		}
 
-			signature, err = signer.SignFile(pkgPath)
+		out.Statusln("\nBuilding application package...")
+		pkgMgr := pkgmanager.New()
+		var pkgPath string
+		if noCache || common.GlobalConfig.PackageCache.Disabled || !overrides.IsEmpty() {
+			// Build the package in a workspace of its own, removed on exit
+			// unless kept with --cleanup=false (a dry run never keeps one)
+			ws, err := common.NewWorkspace("run")
 			if err != nil {
-				return fmt.Errorf("failed to sign package: %w", err)
+				return err
 			}
-
-			// Save signature
-			sigPath := pkgPath + ".sig"
-			if err := os.WriteFile(sigPath, signature, 0644); err != nil {
-				common.GlobalLogger.Warn("failed to save signature file", "error", err)
+			if cleanup || dryRun {
+				defer func() { _ = ws.Remove() }()
+			}
+			srcDir := appDir
+			if !overrides.IsEmpty() {
+				srcDir = filepath.Join(ws.Dir, "app")
+				if _, err := overrides.Stage(ctx, appDir, srcDir); err != nil {
+					return fmt.Errorf("failed to apply overrides: %w", err)
+				}
+			}
+			pkgPath, err = common.BuildPackage(ctx, srcDir, ws.Dir, signer)
+			if err != nil {
+				return fmt.Errorf("failed to build package: %w", err)
+			}
+			out.Statusf("Package created: %s\n", pkgPath)
+			if !cleanup && !dryRun {
+				out.Statusln("The package is kept until 'controller cache prune'")
+			}
+		} else {
+			cache, err := common.OpenPackageCache()
+			if err != nil {
+				return err
+			}
+			pkg, hit, err := cache.Build(ctx, appDir, signer)
+			if err != nil {
+				return fmt.Errorf("failed to build package: %w", err)
+			}
+			pkgPath = pkg.Path
+			if hit {
+				out.Statusf("Sources unchanged, reusing cached package: %s\n", pkgPath)
 			} else {
-				common.GlobalLogger.Info("package signed", "sig_path", sigPath)
+				out.Statusf("Package created: %s\n", pkgPath)
 			}
-		} else if !noSign {
-			common.GlobalLogger.Warn("no private key specified, deploying without signature")
+		}
+
+		var signature []byte
+		if signer != nil {
+			signature, err = os.ReadFile(pkgPath + ".sig")
+			if err != nil {
+				return fmt.Errorf("failed to read signature: %w", err)
+			}
+			common.GlobalLogger.Info("package signed", "sig_path", pkgPath+".sig")
 		}
 
 		// Get package info
@@ -144,63 +209,122 @@ Use --node to deploy to a specific node only.`,
 			return fmt.Errorf("failed to get package info: %w", err)
 		}
 
-		// Deploy package to all target nodes
-		fmt.Printf("\nDeploying package to %d node(s)...\n", len(targetPeerIDs))
+		manifest, err := pkgMgr.GetManifest(ctx, pkgPath)
+		if err != nil {
+			return fmt.Errorf("failed to read manifest: %w", err)
+		}
+		if reportPath != "" {
+			if err := report.SetPackage(pkgPath, manifest); err != nil {
+				return err
+			}
+		}
 
-		type deploymentResult struct {
-			peerID string
-			appID  string
-			err    error
+		// Place apps needing devices only on nodes that provide them, and
+		// restricted apps only on the selected nodes that can run them
+		if selection.Node == "" && len(manifest.Devices) > 0 {
+			out.Statusf("\nFinding nodes providing devices: %s\n", strings.Join(manifest.Devices, ", "))
+			targetPeerIDs, err = common.CapableNodes(ctx, host, targetPeerIDs, manifest, common.GlobalLogger)
+			if err != nil {
+				return err
+			}
+			out.Statusf("Deploying to %d capable node(s)\n", len(targetPeerIDs))
+		} else if selection.Multiple() && common.Restricted(manifest) {
+			out.Statusln("\nFinding nodes that can run the app")
+			targetPeerIDs, err = common.CapableNodes(ctx, host, targetPeerIDs, manifest, common.GlobalLogger)
+			if err != nil {
+				return err
+			}
+			out.Statusf("Deploying to %d capable node(s)\n", len(targetPeerIDs))
+		}
+
+		if dryRun {
+			checksum, err := pkgMgr.CalculateChecksum(pkgPath)
+			if err != nil {
+				return err
+			}
+
+			out.Statusln("\nChecking target nodes (dry run)...")
+			return common.PrintDryRun(&common.DryRunReport{
+				Package:     pkgPath,
+				PackageSize: fileInfo.Size(),
+				Checksum:    checksum,
+				Signed:      len(signature) > 0,
+				Manifest:    manifest,
+				AutoStart:   true,
+				Violations:  common.PolicyViolations(manifest, len(signature) > 0, signerID),
+				Nodes:       common.PlanDeployment(ctx, host, targetPeerIDs, manifest, common.GlobalLogger),
+			})
 		}
 
-		results := make(chan deploymentResult, len(targetPeerIDs))
+		// Pre-flight policy check before transferring anything
+		if err := common.CheckPolicy(manifest, len(signature) > 0, signerID); err != nil {
+			return err
+		}
 
-		for _, peerID := range targetPeerIDs {
-			go func(pid string) {
-				appID, err := common.DeployPackage(ctx, host, pid, pkgPath, fileInfo.Size(), true, common.GlobalLogger)
-				results <- deploymentResult{peerID: pid, appID: appID, err: err}
-			}(peerID)
+		// Deploy package to all target nodes
+		out.Statusf("\nDeploying package to %d node(s)...\n", len(targetPeerIDs))
+
+		queue := queueOpts.WithDefaults(&common.GlobalConfig.Deployment)
+		opts := common.DeployOptions{
+			AutoStart:     true,
+			WaitHealthy:   waitHealthy,
+			HealthTimeout: timeout,
+			Quiet:         queue.Parallel(len(targetPeerIDs)),
 		}
+		results := common.RunQueue(ctx, host, targetPeerIDs, queue, func(ctx context.Context, peerID string) (string, error) {
+			return common.DeployPackage(ctx, host, peerID, pkgPath, fileInfo.Size(), opts, common.GlobalLogger)
+		})
 
 		// Collect deployment results
 		deployments := make(map[string]string) // peerID -> appID
 		var deployErrors []error
 
-		for i := 0; i < len(targetPeerIDs); i++ {
-			result := <-results
-			if result.err != nil {
-				deployErrors = append(deployErrors, fmt.Errorf("node %s: %w", result.peerID, result.err))
+		for _, result := range results {
+			report.AddResult(result, opts)
+			if result.Err != nil {
+				deployErrors = append(deployErrors, fmt.Errorf("node %s: %w", result.NodeID, result.Err))
 			} else {
-				deployments[result.peerID] = result.appID
-				fmt.Printf("  ✓ Deployed to node: %s (app: %s)\n", result.peerID, result.appID)
+				deployments[result.NodeID] = result.Value
+				if len(results) == 1 {
+					out.Statusf("  ✓ Deployed to node: %s (app: %s)\n", result.NodeID, result.Value)
+				}
 			}
 		}
 
 		if len(deployErrors) > 0 {
-			fmt.Println("\nDeployment errors:")
+			out.Statusln("\nDeployment errors:")
 			for _, err := range deployErrors {
-				fmt.Printf("  ✗ %v\n", err)
+				out.Statusf("  ✗ %v\n", err)
+			}
+			if len(targetPeerIDs) > 1 {
+				if op, err := common.RecordOperation(report, pkgPath, manifest, opts, queue); err != nil {
+					common.GlobalLogger.Warn("failed to keep the deployment for retrying", "error", err)
+				} else if op != nil {
+					out.Statusf("\nRetry the %d failed node(s) with: controller retry %s\n", len(deployErrors), op.ID)
+				}
 			}
 		}
 
 		if len(deployments) == 0 {
-			return fmt.Errorf("failed to deploy to any nodes")
+			return fmt.Errorf("failed to deploy to any nodes: %w", errors.Join(deployErrors...))
+		}
+		if waitHealthy && len(deployErrors) > 0 {
+			return fmt.Errorf("deployment failed on %d of %d node(s): %w", len(deployErrors), len(targetPeerIDs), errors.Join(deployErrors...))
 		}
 
-		fmt.Printf("\n✓ Application deployed and started on %d node(s)!\n\n", len(deployments))
+		out.Statusf("\n✓ Application deployed and started on %d node(s)!\n\n", len(deployments))
+		if err := writeReport(nil); err != nil {
+			return err
+		}
 
 		// Stream logs from all deployed nodes
-		fmt.Println("Streaming logs from all nodes (Ctrl+C to stop):")
-		fmt.Println("─────────────────────────────────────────────────────────────")
+		out.Statusln("Streaming logs from all nodes (Ctrl+C to stop):")
+		out.Statusln("─────────────────────────────────────────────────────────────")
 
 		// Create a context that we can cancel
 		logsCtx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
-		// Set up signal handling for graceful shutdown
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
 		// Start log streaming for each node in separate goroutines
 		for peerID, appID := range deployments {
 			go func(pid, aid string) {
@@ -214,8 +338,9 @@ Use --node to deploy to a specific node only.`,
 		}
 
 		// Wait for interrupt signal
-		<-sigChan
-		fmt.Println("\n\nReceived interrupt signal, stopping...")
+		<-ctx.Done()
+		stop()
+		out.Statusln("\n\nReceived interrupt signal, stopping...")
 
 		return nil
 	},
@@ -223,99 +348,41 @@ Use --node to deploy to a specific node only.`,
 
 // streamLogs streams logs from the application with [node-id] prefix
 func streamLogs(ctx context.Context, host *p2p.Host, peerID string, appID string, logger types.Logger) error {
-	// Create stream to target peer
-	stream, err := host.NewStream(ctx, peerID, consts.LogsProtocolID)
-	if err != nil {
-		return fmt.Errorf("failed to create stream: %w", err)
-	}
-	defer func() { _ = stream.Close() }()
-
-	// Prepare logs request (follow mode)
-	req := common.LogsRequest{
-		AppID:  appID,
-		Follow: true,
-		Tail:   0, // Get all logs
-	}
-
-	reqBytes, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Send request header size
-	reqSize := uint32(len(reqBytes))
-	if err := binary.Write(stream, binary.BigEndian, reqSize); err != nil {
-		return fmt.Errorf("failed to send header size: %w", err)
-	}
-
-	// Send request header
-	if _, err := stream.Write(reqBytes); err != nil {
-		return fmt.Errorf("failed to send header: %w", err)
-	}
-
-	logger.Info("requesting logs", "app_id", appID, "follow", true)
-
-	// Read response header size
-	var respSize uint32
-	if err := binary.Read(stream, binary.BigEndian, &respSize); err != nil {
-		return fmt.Errorf("failed to read response size: %w", err)
-	}
-
-	// Read response
-	respBytes := make([]byte, respSize)
-	if _, err := io.ReadFull(stream, respBytes); err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
-	}
-
-	var resp common.LogsResponse
-	if err := json.Unmarshal(respBytes, &resp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	if !resp.Success {
-		return fmt.Errorf("logs request failed on node: %s", resp.Error)
-	}
-
 	// Shorten peer ID for display (first 8 characters)
 	shortPeerID := peerID
 	if len(peerID) > 8 {
 		shortPeerID = peerID[:8]
 	}
 
-	// Output initial logs with prefix
-	if resp.Logs != "" {
-		lines := strings.Split(strings.TrimSpace(resp.Logs), "\n")
-		for _, line := range lines {
-			if line != "" {
-				fmt.Printf("[%s] %s\n", shortPeerID, line)
-			}
-		}
-	}
-
-	// For follow mode, keep reading from stream
-	// Note: Current implementation returns all logs at once
-	// In a real streaming implementation, we would keep reading chunks
-	scanner := bufio.NewScanner(stream)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line != "" {
-			fmt.Printf("[%s] %s\n", shortPeerID, line)
-		}
-	}
+	return common.FollowLogs(ctx, host, peerID, appID, common.LogOptions{}, func(line string) {
+		printLogLine(peerID, shortPeerID, appID, line)
+	}, logger)
+}
 
-	if err := scanner.Err(); err != nil {
-		// Don't treat EOF as an error
-		if err != io.EOF {
-			return fmt.Errorf("error reading log stream: %w", err)
-		}
-	}
+// logLine is a single streamed log line in JSON output mode
+type logLine struct {
+	NodeID string `json:"node_id"`
+	AppID  string `json:"app_id"`
+	Line   string `json:"line"`
+}
 
-	return nil
+// printLogLine writes a log line to stdout with a [node-id] prefix
+func printLogLine(peerID, shortPeerID, appID, line string) {
+	_ = common.Out.Record(logLine{NodeID: peerID, AppID: appID, Line: line}, func() {
+		common.Out.Printf("[%s] %s\n", shortPeerID, line)
+	})
 }
 
 func init() {
-	Cmd.Flags().StringVar(&nodeID, "node", "", "target node peer ID")
-	Cmd.Flags().BoolVar(&cleanup, "cleanup", true, "remove package file after deployment")
+	common.AddSelectionFlags(Cmd.Flags(), &selection, true)
+	Cmd.Flags().BoolVar(&noCache, "no-cache", false, "build the package even if the sources are unchanged, without caching it")
+	Cmd.Flags().BoolVar(&cleanup, "cleanup", true, "with --no-cache, remove the package built in the workspace on exit")
 	Cmd.Flags().BoolVar(&noSign, "no-sign", false, "skip package signing")
 	Cmd.Flags().StringVar(&privateKey, "private-key", "", "path to private key file for signing")
+	Cmd.Flags().BoolVar(&dryRun, "dry-run", false, "build, sign and report what would be deployed without transferring anything")
+	Cmd.Flags().BoolVar(&waitHealthy, "wait-healthy", false, "stream logs only once the app passes its health check on every node")
+	Cmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "how long --wait-healthy waits for the app to become healthy")
+	Cmd.Flags().StringVar(&reportPath, "report", "", "write a JSON summary of the deployment to this file")
+	common.AddOverrideFlags(Cmd.Flags(), &overrides)
+	common.AddQueueFlags(Cmd.Flags(), &queueOpts)
 }
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/schema"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// documents are the files a schema can be exported for
+var documents = map[string]func() *schema.Schema{
+	"manifest": func() *schema.Schema {
+		return schema.Generate(&types.Manifest{}, schema.Options{Title: "P2P Playground app manifest", RequireFields: true})
+	},
+	"daemon-config": func() *schema.Schema {
+		return schema.Generate(&config.DaemonConfig{}, schema.Options{Title: "P2P Playground daemon configuration"})
+	},
+	"controller-config": func() *schema.Schema {
+		return schema.Generate(&config.ControllerConfig{}, schema.Options{Title: "P2P Playground controller configuration"})
+	},
+}
+
+// Cmd represents the schema command
+var Cmd = &cobra.Command{
+	Use:   "schema manifest|daemon-config|controller-config",
+	Short: "Print the JSON Schema of manifests or configuration files",
+	Long: `Print the JSON Schema of manifest.yaml, the daemon configuration or the
+controller configuration. The schema is generated from the types this
+version reads the files into, so it always matches them.
+
+Point an editor at the schema to complete and validate the YAML as it is
+written. With the YAML language server, for example:
+
+  # yaml-language-server: $schema=./manifest.schema.json
+
+Example:
+  controller schema manifest > manifest.schema.json
+  controller schema daemon-config > daemon-config.schema.json`,
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"manifest", "daemon-config", "controller-config"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		generate, ok := documents[args[0]]
+		if !ok {
+			return fmt.Errorf("%w: unknown document %q", types.ErrInvalidInput, args[0])
+		}
+
+		// The schema is the result in either output format
+		encoder := json.NewEncoder(common.Out.Stdout())
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(generate()); err != nil {
+			return fmt.Errorf("failed to encode schema: %w", err)
+		}
+		return nil
+	},
+}
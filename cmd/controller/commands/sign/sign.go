@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
 	"github.com/asjdf/p2p-playground-lite/pkg/security"
 	"github.com/spf13/cobra"
 )
@@ -14,6 +15,13 @@ var (
 	keyPath string
 )
 
+// signResult is the structured result of package signing
+type signResult struct {
+	Package      string `json:"package"`
+	Signature    string `json:"signature"`
+	SignatureHex string `json:"signature_hex"`
+}
+
 // Cmd represents the sign command
 var Cmd = &cobra.Command{
 	Use:   "sign [package]",
@@ -47,7 +55,8 @@ The signature will be embedded in the deployment request and verified by nodes.`
 		}
 
 		// Sign package
-		fmt.Printf("Signing package: %s\n", packagePath)
+		out := common.Out
+		out.Statusf("Signing package: %s\n", packagePath)
 		signature, err := signer.SignFile(packagePath)
 		if err != nil {
 			return fmt.Errorf("failed to sign package: %w", err)
@@ -59,13 +68,18 @@ The signature will be embedded in the deployment request and verified by nodes.`
 			return fmt.Errorf("failed to save signature: %w", err)
 		}
 
-		fmt.Printf("\n✓ Package signed successfully!\n")
-		fmt.Printf("  Signature: %s\n", sigPath)
-		fmt.Printf("  Signature (hex): %s\n", hex.EncodeToString(signature))
-		fmt.Printf("\n")
-		fmt.Printf("You can now deploy this package with signature verification.\n")
-
-		return nil
+		result := signResult{
+			Package:      packagePath,
+			Signature:    sigPath,
+			SignatureHex: hex.EncodeToString(signature),
+		}
+		return out.Result(result, func() {
+			out.Printf("\n✓ Package signed successfully!\n")
+			out.Printf("  Signature: %s\n", sigPath)
+			out.Printf("  Signature (hex): %s\n", result.SignatureHex)
+			out.Printf("\n")
+			out.Printf("You can now deploy this package with signature verification.\n")
+		})
 	},
 }
 
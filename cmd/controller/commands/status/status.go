@@ -0,0 +1,140 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/history"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+	lookback  time.Duration
+)
+
+// statusResult is the structured result of a status request
+type statusResult struct {
+	NodeID   string             `json:"node_id"`
+	Apps     []*types.AppStatus `json:"apps,omitempty"`
+	History  []*history.Entry   `json:"history,omitempty"`
+	Lookback string             `json:"lookback,omitempty"`
+}
+
+// Cmd represents the status command
+var Cmd = &cobra.Command{
+	Use:   "status [app-id]",
+	Short: "Show application status and its recent history",
+	Long: `Show the current status of the applications on a node, or of one application.
+An application is named by its ID, its name or <name>-<version>.
+
+With --history, show the status samples and lifecycle events (deployed, started,
+exited, stopped, unhealthy) the node recorded over that period instead, to see
+what happened just before a crash. Nodes sample every app every 10 seconds and
+keep 3 hours of history per app by default (history in the daemon config).
+
+--node takes the peer ID or name of the node to query. If it is not
+specified, the local daemon is queried, or else the only node discovered.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var appID string
+		if len(args) == 1 {
+			appID = args[0]
+		}
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		result := statusResult{NodeID: targetPeerID}
+
+		if lookback > 0 {
+			entries, err := common.FetchHistory(ctx, host, targetPeerID, appID, lookback, common.GlobalLogger)
+			if err != nil {
+				return fmt.Errorf("failed to fetch history: %w", err)
+			}
+			result.History = entries
+			result.Lookback = lookback.String()
+
+			return out.Result(result, func() {
+				out.Printf("\nHistory of the last %s (%d entries):\n\n", lookback, len(entries))
+				for _, e := range entries {
+					out.Println(formatEntry(e, appID == ""))
+				}
+			})
+		}
+
+		statuses, err := common.ListAppStatuses(ctx, host, targetPeerID, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to fetch status: %w", err)
+		}
+		// An app name or <name>-<version> shows all its deployments
+		for _, status := range statuses {
+			app := status.App
+			if appID == "" || app.ID == appID || app.Name == appID || types.LegacyAppID(app.Name, app.Version) == appID {
+				result.Apps = append(result.Apps, status)
+			}
+		}
+		if appID != "" && len(result.Apps) == 0 {
+			return fmt.Errorf("%w: application %s on %s", types.ErrNotFound, appID, targetPeerID)
+		}
+
+		return out.Result(result, func() {
+			out.Println()
+			for _, status := range result.Apps {
+				health := "unhealthy"
+				if status.Healthy {
+					health = "healthy"
+				}
+				out.Printf("%-30s  %-10s  %-9s  %s\n", status.App.ID, status.App.Status, health, status.Message)
+				if len(status.Metrics) > 0 {
+					out.Printf("%-30s  %s\n", "", common.FormatMetrics(status.Metrics))
+				}
+			}
+		})
+	},
+}
+
+// formatEntry formats a history entry as one line, naming the app if withApp is set
+func formatEntry(e *history.Entry, withApp bool) string {
+	line := e.Time.Local().Format("2006-01-02 15:04:05")
+	if withApp {
+		line += fmt.Sprintf("  %-30s", e.AppID)
+	}
+
+	if e.Kind == history.KindEvent {
+		line += fmt.Sprintf("  %-10s  %s", e.Event, e.Message)
+		return line
+	}
+
+	health := "unhealthy"
+	if e.Healthy {
+		health = "healthy"
+	}
+	line += fmt.Sprintf("  %-10s  %-9s  %s", e.Status, health, e.Message)
+	if e.Usage != nil {
+		line += fmt.Sprintf("  cpu=%.1f%% memory=%dMB", e.Usage.CPUPercent, e.Usage.MemoryMB)
+	}
+	if len(e.Metrics) > 0 {
+		line += "  " + common.FormatMetrics(e.Metrics)
+	}
+	return line
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+	Cmd.Flags().DurationVar(&lookback, "history", 0, "show the status history of this period, e.g. 1h")
+}
@@ -0,0 +1,131 @@
+package token
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ttl       time.Duration
+	cluster   string
+	bootstrap []string
+	keyPath   string
+)
+
+// tokenResult is the structured result of token creation
+type tokenResult struct {
+	Token          string    `json:"token"`
+	Cluster        string    `json:"cluster"`
+	BootstrapPeers []string  `json:"bootstrap_peers"`
+	PSKFingerprint string    `json:"psk_fingerprint,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// Cmd represents the token command
+var Cmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage cluster join tokens",
+	Long:  `Manage join tokens that let fresh nodes configure themselves with "p2p-daemon daemon join".`,
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a signed join token",
+	Long: `Create a join token for onboarding new nodes.
+
+The token carries the cluster name, the bootstrap peers, a fingerprint of the
+cluster PSK and the public key it is signed with, which the joining node
+trusts for package signatures. The PSK itself is not included: it is passed
+to "daemon join" separately and checked against the fingerprint.
+
+The token is signed with the package signing key. Anyone holding it can
+configure a node, so share it only with the nodes' operators.
+
+Example:
+  controller token create --cluster lab --ttl 24h
+  p2p-daemon daemon join <token> --psk-file ~/.p2p-playground/psk`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if ttl <= 0 {
+			return fmt.Errorf("%w: --ttl must be positive", types.ErrInvalidInput)
+		}
+
+		peers := bootstrap
+		if len(peers) == 0 {
+			peers = common.GlobalConfig.Node.BootstrapPeers
+		}
+		if len(peers) == 0 {
+			return fmt.Errorf("%w: no bootstrap peers: pass --bootstrap or set node.bootstrap_peers", types.ErrInvalidInput)
+		}
+		for _, addr := range peers {
+			if _, err := peer.AddrInfoFromString(addr); err != nil {
+				return fmt.Errorf("%w: invalid bootstrap peer %q: %w", types.ErrInvalidInput, addr, err)
+			}
+		}
+
+		kp := keyPath
+		if kp == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to get home directory: %w", err)
+			}
+			kp = filepath.Join(home, ".p2p-playground", "keys", "controller.key")
+		}
+		signer, err := security.LoadSigner(kp)
+		if err != nil {
+			return fmt.Errorf("failed to load private key: %w", err)
+		}
+
+		token := &security.JoinToken{
+			Cluster:        cluster,
+			BootstrapPeers: peers,
+			ExpiresAt:      time.Now().Add(ttl).UTC().Truncate(time.Second),
+		}
+		if psk := common.GlobalConfig.Security.PSK; psk != "" {
+			token.PSKFingerprint, err = security.PSKFingerprint(psk)
+			if err != nil {
+				return fmt.Errorf("invalid PSK in config: %w", err)
+			}
+		}
+
+		encoded, err := security.CreateJoinToken(signer, token)
+		if err != nil {
+			return err
+		}
+
+		out := common.Out
+		result := tokenResult{
+			Token:          encoded,
+			Cluster:        token.Cluster,
+			BootstrapPeers: token.BootstrapPeers,
+			PSKFingerprint: token.PSKFingerprint,
+			ExpiresAt:      token.ExpiresAt,
+		}
+		return out.Result(result, func() {
+			out.Printf("Join token for cluster %q (expires %s):\n\n", token.Cluster, token.ExpiresAt.Format(time.RFC3339))
+			out.Printf("%s\n\n", encoded)
+			out.Println("On the new node, run:")
+			if token.PSKFingerprint != "" {
+				out.Println("  p2p-daemon daemon join <token> --psk-file <path to the cluster PSK>")
+			} else {
+				out.Println("  p2p-daemon daemon join <token>")
+			}
+		})
+	},
+}
+
+func init() {
+	createCmd.Flags().DurationVar(&ttl, "ttl", 24*time.Hour, "how long the token is valid")
+	createCmd.Flags().StringVar(&cluster, "cluster", "default", "cluster name, added to joining nodes as the label cluster=<name>")
+	createCmd.Flags().StringArrayVar(&bootstrap, "bootstrap", nil, "bootstrap peer multiaddr with /p2p/ ID (repeatable, default: node.bootstrap_peers)")
+	createCmd.Flags().StringVarP(&keyPath, "key", "k", "", "path to private key file (default: ~/.p2p-playground/keys/controller.key)")
+
+	Cmd.AddCommand(createCmd)
+}
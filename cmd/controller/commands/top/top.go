@@ -0,0 +1,237 @@
+package top
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/history"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+	window    time.Duration
+	interval  time.Duration
+	once      bool
+)
+
+// sparkWidth is how many bars a sparkline has at most
+const sparkWidth = 30
+
+// appRow is an app in the table, with its usage over the window
+type appRow struct {
+	AppID  string              `json:"app_id"`
+	Status types.AppStatusType `json:"status"`
+
+	// Usage is the current usage, nil if the node did not measure it
+	Usage *types.ResourceUsage `json:"usage,omitempty"`
+
+	// MemoryLimitMB is the memory limit of the manifest, 0 for none
+	MemoryLimitMB int64 `json:"memory_limit_mb,omitempty"`
+
+	// CPU and Memory are the samples of the window, oldest first
+	CPU    []float64 `json:"cpu,omitempty"`
+	Memory []float64 `json:"memory_mb,omitempty"`
+
+	// MemoryTrend is how fast memory grew over the window, if it can be told
+	MemoryTrend *common.MemoryTrend `json:"memory_trend,omitempty"`
+}
+
+// snapshot is one refresh of the table
+type snapshot struct {
+	Time   time.Time `json:"time"`
+	NodeID string    `json:"node_id"`
+	Apps   []appRow  `json:"apps"`
+}
+
+// Cmd represents the top command
+var Cmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show the CPU and memory usage of applications over time",
+	Long: `Show the CPU and memory usage of the applications on a node, with sparklines
+of the usage the node sampled over the last --window and how fast memory
+grew over it. Memory is scaled to the limit of the app, if it has one, and
+the time until it reaches the limit at that rate is shown, so an app leaking
+memory stands out before it is killed for it.
+
+Nodes sample every app every 10 seconds (history in the daemon config); with
+history disabled only the current usage is shown. The table is refreshed
+every --interval until interrupted (Ctrl+C), or printed once with --once.
+
+--node takes the peer ID or name of the node. If it is not specified, the
+local daemon is shown, or else the only node discovered.
+
+With --output json, every refresh is one {"time", "node_id", "apps"} line.
+
+Example:
+  controller top
+  controller top --node lab-1 --window 30m`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if window <= 0 || interval <= 0 {
+			return fmt.Errorf("%w: --window and --interval must be positive", types.ErrInvalidInput)
+		}
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		interrupted, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		nodeID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		redraw := !once && isTerminal()
+		withHistory := true
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			now := time.Now()
+			rows, err := fetchRows(interrupted, host, nodeID, &withHistory)
+			if interrupted.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			err = out.Record(snapshot{Time: now.UTC(), NodeID: nodeID, Apps: rows}, func() {
+				if redraw {
+					// Move to the top left and clear the screen
+					out.Printf("\033[H\033[2J")
+				} else {
+					out.Println()
+				}
+				if once {
+					out.Printf("%s  %s\n\n", now.Format("15:04:05"), nodeID)
+				} else {
+					out.Printf("%s  %s, refreshing every %s (Ctrl+C to stop)\n\n", now.Format("15:04:05"), nodeID, interval)
+				}
+				printTable(rows)
+			})
+			if err != nil || once {
+				return err
+			}
+
+			select {
+			case <-interrupted.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+// fetchRows fetches the apps of the node and, while *withHistory, their
+// usage over the window. A node with history disabled clears *withHistory.
+func fetchRows(ctx context.Context, host *p2p.Host, nodeID string, withHistory *bool) ([]appRow, error) {
+	statuses, err := common.ListAppStatuses(ctx, host, nodeID, common.GlobalLogger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch status: %w", err)
+	}
+
+	samples := make(map[string][]*history.Entry)
+	if *withHistory {
+		entries, err := common.FetchHistory(ctx, host, nodeID, "", window, common.GlobalLogger)
+		switch {
+		case errors.Is(err, types.ErrUnavailable):
+			common.Out.Statusf("The node keeps no history, showing the current usage only: %v\n", err)
+			*withHistory = false
+		case err != nil:
+			return nil, fmt.Errorf("failed to fetch history: %w", err)
+		}
+		for _, e := range entries {
+			if e.Kind == history.KindSample && e.Usage != nil {
+				samples[e.AppID] = append(samples[e.AppID], e)
+			}
+		}
+	}
+
+	rows := make([]appRow, 0, len(statuses))
+	for _, status := range statuses {
+		app := status.App
+		row := appRow{AppID: app.ID, Status: app.Status, Usage: status.ResourceUsage}
+		if app.Manifest != nil && app.Manifest.Resources != nil {
+			row.MemoryLimitMB = app.Manifest.Resources.MemoryMB
+		}
+		for _, e := range samples[app.ID] {
+			row.CPU = append(row.CPU, e.Usage.CPUPercent)
+			row.Memory = append(row.Memory, float64(e.Usage.MemoryMB))
+		}
+		row.MemoryTrend = common.FitMemoryTrend(samples[app.ID], row.MemoryLimitMB)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// printTable prints rows as an aligned table
+func printTable(rows []appRow) {
+	if len(rows) == 0 {
+		common.Out.Println("No applications deployed.")
+		return
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "APP\tSTATUS\tCPU\tCPU HISTORY\tMEMORY\tMEMORY HISTORY\tMEMORY TREND")
+	for _, row := range rows {
+		cpu, memory := "-", "-"
+		if row.Usage != nil {
+			cpu = fmt.Sprintf("%.1f%%", row.Usage.CPUPercent)
+			memory = fmt.Sprintf("%d MB", row.Usage.MemoryMB)
+		}
+		if row.MemoryLimitMB > 0 {
+			memory += fmt.Sprintf(" / %d MB", row.MemoryLimitMB)
+		}
+		trend := "-"
+		if row.MemoryTrend != nil {
+			trend = row.MemoryTrend.String()
+		}
+		// CPU is scaled to one CPU, or to more if the app used more
+		cpuTop := 100.0
+		for _, v := range row.CPU {
+			cpuTop = max(cpuTop, v)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", row.AppID, row.Status, cpu,
+			common.Sparkline(row.CPU, cpuTop, sparkWidth), memory,
+			common.Sparkline(row.Memory, float64(row.MemoryLimitMB), sparkWidth), trend)
+	}
+	_ = w.Flush()
+	common.Out.Printf("%s", b.String())
+}
+
+// isTerminal reports whether results go to a terminal, where the table is redrawn in place
+func isTerminal() bool {
+	f, ok := common.Out.Stdout().(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+	Cmd.Flags().DurationVar(&window, "window", 5*time.Minute, "how far back the sparklines go")
+	Cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "how often to refresh the table")
+	Cmd.Flags().BoolVar(&once, "once", false, "print the table once and exit")
+}
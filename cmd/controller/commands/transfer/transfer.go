@@ -0,0 +1,107 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var start bool
+
+// transferResult is the structured result of a transfer
+type transferResult struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Package  string `json:"package"`
+	Bytes    int64  `json:"bytes"`
+	AppID    string `json:"app_id"`
+	Started  bool   `json:"started"`
+	Duration string `json:"duration"`
+}
+
+// Cmd represents the transfer command
+var Cmd = &cobra.Command{
+	Use:   "transfer <src-node>:<package> <dst-node>",
+	Short: "Deploy a package kept by one node to another, node to node",
+	Long: `Have the node src-node send one of its packages straight to the node
+dst-node, which deploys it, without the package passing through the
+controller. This saves sending large packages over a slow link to the
+controller machine when the nodes are close to each other.
+
+package names a package the source node keeps for rollbacks: the package
+of a deployed app instance (e.g. web-1.0.0), the latest package of an app
+name (e.g. web), or a package file name (e.g. web-1.0.0.tar.gz).
+
+The destination deploys the package exactly as if the controller had sent
+it. It verifies the signature the source received the package with, and
+applies its own policy, admission hooks and quotas. The nodes are named by
+peer ID or name.
+
+Example:
+  controller transfer lab-1:web lab-2 --start
+  controller transfer 12D3KooW...:web-1.0.0.tar.gz 12D3KooX...`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srcNode, ref, ok := strings.Cut(args[0], ":")
+		if !ok || srcNode == "" || ref == "" {
+			return fmt.Errorf("%w: source %q is not <src-node>:<package>", types.ErrInvalidInput, args[0])
+		}
+		out := common.Out
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		from, err := common.ResolveNode(ctx, host, &common.Selection{Node: srcNode})
+		if err != nil {
+			return err
+		}
+		to, err := common.ResolveNode(ctx, host, &common.Selection{Node: args[1]})
+		if err != nil {
+			return err
+		}
+		if from == to {
+			return fmt.Errorf("%w: the source and destination are the same node %s", types.ErrInvalidInput, from)
+		}
+
+		out.Statusf("Transferring %s from %s to %s...\n", ref, from, to)
+		resp, err := common.TransferPackage(ctx, host, from, ref, to, start, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("transfer failed: %w", err)
+		}
+
+		result := transferResult{
+			From:     from,
+			To:       to,
+			Package:  resp.FileName,
+			Bytes:    resp.Bytes,
+			AppID:    resp.AppID,
+			Started:  start,
+			Duration: resp.Elapsed.String(),
+		}
+		return out.Result(result, func() {
+			out.Printf("\n✓ Transferred %s (%d bytes) in %s", resp.FileName, resp.Bytes, resp.Elapsed.Round(time.Microsecond))
+			if resp.Elapsed > 0 {
+				out.Printf(", %s", common.FormatRate(float64(resp.Bytes)/resp.Elapsed.Seconds()))
+			}
+			out.Println()
+			out.Printf("  Application ID: %s\n", resp.AppID)
+			if start {
+				out.Println("  Status: Started")
+			}
+		})
+	},
+}
+
+func init() {
+	Cmd.Flags().BoolVar(&start, "start", false, "start the app once deployed")
+}
@@ -0,0 +1,88 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/integrity"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selection common.Selection
+)
+
+// Cmd represents the verify command
+var Cmd = &cobra.Command{
+	Use:   "verify <app-id>",
+	Short: "Check the files of a deployed app against its package",
+	Long: `Have a node re-hash the files of a deployed application and compare them with
+the checksums it recorded when the application was deployed. The application
+is named by its ID, or by its name or <name>-<version> for the latest
+deployment of those.
+
+Modified and missing files are reported, which reveals tampering and disk
+corruption. Files the application created itself and its logs are not
+checked. Applications deployed before checksums were recorded have to be
+deployed again first.
+
+The command fails if any file differs.
+
+--node takes the peer ID or name of the node to query. If it is not
+specified, the local daemon is queried, or else the only node discovered.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+		appID := args[0]
+
+		// Create P2P host using configuration
+		ctx := context.Background()
+		host, err := common.CreateP2PHost(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = host.Close() }()
+
+		targetPeerID, err := common.ResolveNode(ctx, host, &selection)
+		if err != nil {
+			return err
+		}
+
+		report, err := common.VerifyApp(ctx, host, targetPeerID, appID, common.GlobalLogger)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", appID, err)
+		}
+
+		if err := out.Result(report, func() {
+			out.Printf("\nDeployed: %s\n", report.RecordedAt.Local().Format("2006-01-02 15:04:05"))
+			for _, drift := range report.Drift {
+				switch drift.Kind {
+				case integrity.DriftModified:
+					out.Printf("  modified  %s\n", drift.Path)
+					out.Printf("            sha256 %s, deployed %s\n", drift.Actual, drift.Expected)
+				default:
+					out.Printf("  %-8s  %s\n", drift.Kind, drift.Path)
+				}
+			}
+
+			if !report.OK() {
+				out.Printf("\n✗ %d of %d files of %s differ from the deployed package\n", len(report.Drift), report.Checked, appID)
+				return
+			}
+			out.Printf("✓ All %d files of %s match the deployed package\n", report.Checked, appID)
+		}); err != nil {
+			return err
+		}
+
+		if !report.OK() {
+			return fmt.Errorf("%w: %d files of %s differ from the deployed package", types.ErrInvalidChecksum, len(report.Drift), appID)
+		}
+		return nil
+	},
+}
+
+func init() {
+	common.AddSelectionFlags(Cmd.Flags(), &selection, false)
+}
@@ -0,0 +1,32 @@
+package version
+
+import (
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+// Cmd represents the version command
+var Cmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the controller version",
+	Long: `Print the version, commit and build date of the controller.
+
+The versions of the daemons are shown by "controller nodes".`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+		info := version.Get()
+		return out.Result(info, func() {
+			out.Printf("controller %s\n", info.Version)
+			if info.Commit != "" {
+				out.Printf("  Commit:     %s\n", info.Commit)
+			}
+			if info.Date != "" {
+				out.Printf("  Built:      %s\n", info.Date)
+			}
+			out.Printf("  Go version: %s\n", info.GoVersion)
+			out.Printf("  Platform:   %s\n", info.Platform)
+		})
+	},
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands"
+	"github.com/asjdf/p2p-playground-lite/cmd/controller/commands/common"
 )
 
 func main() {
 	if err := commands.Execute(); err != nil {
+		var status common.ExitStatus
+		if errors.As(err, &status) {
+			os.Exit(int(status))
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(common.ExitCode(err))
 	}
 }
@@ -0,0 +1,33 @@
+// Package common holds the state shared by the daemon CLI commands
+package common
+
+import (
+	"os"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/output"
+)
+
+// Out renders command results to stdout and status messages to stderr
+var Out = output.NewDefault()
+
+// InitOutput sets up Out for the format given with --output
+func InitOutput(outputFormat string) error {
+	format, err := output.ParseFormat(outputFormat)
+	if err != nil {
+		return err
+	}
+	Out = output.New(format, os.Stdout, os.Stderr)
+	return nil
+}
+
+// serviceResult is the result of a command managing the system service
+type serviceResult struct {
+	Status string `json:"status"`
+}
+
+// ServiceStatus reports the status a service command left the service in
+func ServiceStatus(status string) error {
+	return Out.Result(serviceResult{Status: status}, func() {
+		Out.Println(status)
+	})
+}
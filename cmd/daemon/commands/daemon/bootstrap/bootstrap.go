@@ -0,0 +1,106 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/daemon"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/spf13/cobra"
+)
+
+var (
+	announceIP string
+)
+
+// nodeInfo is printed to stdout once the daemon is up
+type nodeInfo struct {
+	PeerID     string   `json:"peer_id"`
+	Name       string   `json:"name"`
+	Version    string   `json:"version"`
+	Addrs      []string `json:"addrs"`
+	Multiaddrs []string `json:"multiaddrs"`
+}
+
+// Cmd represents the bootstrap command
+var Cmd = &cobra.Command{
+	Use:   "bootstrap",
+	Short: "Run the daemon configured from environment variables",
+	Long: `Run the daemon in foreground, configured from environment variables instead
+of a config file, for containers and orchestration scripts.
+
+Each config key is read from P2P_<SECTION>_<KEY>, e.g. P2P_NODE_NAME for
+node.name or P2P_NODE_BOOTSTRAP_PEERS for node.bootstrap_peers. Lists are
+comma-separated and maps are comma-separated key=value pairs. All data,
+including the node identity, is kept under P2P_STORAGE_DATA_DIR, so mounting
+a volume there keeps the peer ID across restarts.
+
+Logs go to stderr. Once started, the node's peer ID and addresses are printed
+to stdout as a single line of JSON.
+
+Example:
+  P2P_STORAGE_DATA_DIR=/data P2P_NODE_BOOTSTRAP_PEERS=/dns4/seed/tcp/9000/p2p/12D3... \
+    p2p-daemon daemon bootstrap --announce-ip 203.0.113.10`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDaemonConfigFromEnv()
+		if err != nil {
+			return err
+		}
+
+		// stdout is reserved for the node info
+		if cfg.Logging.OutputPath == "" || cfg.Logging.OutputPath == "stdout" {
+			cfg.Logging.OutputPath = "stderr"
+		}
+		if cfg.Node.IdentityFile == "" {
+			cfg.Node.IdentityFile = filepath.Join(cfg.Storage.KeysDir, "identity.key")
+		}
+
+		if announceIP == "" {
+			announceIP = os.Getenv(config.EnvPrefix + "_ANNOUNCE_IP")
+		}
+		if announceIP != "" && len(cfg.Node.AnnounceAddrs) == 0 {
+			cfg.Node.AnnounceAddrs, err = p2p.AnnounceAddrsForIP(cfg.Node.ListenAddrs, announceIP)
+			if err != nil {
+				return err
+			}
+		}
+
+		d, err := daemon.New(cfg)
+		if err != nil {
+			return err
+		}
+		if err := d.Start(); err != nil {
+			return err
+		}
+
+		node := d.GetNodeInfo(cmd.Context())
+		info := nodeInfo{PeerID: node.ID, Name: cfg.Node.Name, Version: node.Version, Addrs: node.Addrs}
+		for _, addr := range node.Addrs {
+			info.Multiaddrs = append(info.Multiaddrs, fmt.Sprintf("%s/p2p/%s", addr, node.ID))
+		}
+		// The line is machine-readable whatever the output format
+		if err := json.NewEncoder(common.Out.Stdout()).Encode(&info); err != nil {
+			_ = d.Stop()
+			return err
+		}
+
+		// Wait for signal
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+		<-sigChan
+
+		// Stop daemon
+		return d.Stop()
+	},
+}
+
+func init() {
+	Cmd.Flags().StringVar(&announceIP, "announce-ip", "", "IP address advertised to peers instead of the listen addresses, e.g. the host IP behind container NAT (env P2P_ANNOUNCE_IP)")
+}
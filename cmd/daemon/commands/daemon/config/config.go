@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ignoreUnknown bool
+	dryRun        bool
+)
+
+// Cmd is the parent command for config file operations
+var Cmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the daemon config file",
+}
+
+// migrateResult describes a migrated config file
+type migrateResult struct {
+	Path    string   `json:"path"`
+	Changes []string `json:"changes"`
+	Unknown []string `json:"unknown,omitempty"`
+
+	// Backup is the copy of the original file, if it was rewritten
+	Backup string `json:"backup,omitempty"`
+
+	// Config is the migrated file, with --dry-run
+	Config string `json:"config,omitempty"`
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [file]",
+	Short: "Upgrade a daemon config file to the current schema",
+	Long: `Upgrade a daemon config file written for an earlier release.
+
+Deprecated keys are renamed to their replacements (inverting enable_* switches
+that became disable_*) or dropped, with a warning for each. Comments and the
+order of keys are kept. The original file is saved as <file>.bak.
+
+Keys the current schema does not know are usually typos that silently fall
+back to defaults, so the migration refuses to run while there are any, unless
+--ignore-unknown is passed (they are then kept as they are).
+
+The file is taken from the argument or the -c flag.
+
+Example:
+  p2p-daemon daemon config migrate /etc/p2p-playground/daemon.yaml
+  p2p-daemon daemon config migrate -c daemon.yaml --dry-run`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("config")
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if path == "" {
+			return fmt.Errorf("%w: pass the config file as argument or with -c", types.ErrInvalidInput)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read config: %w", err)
+		}
+		m, err := config.MigrateDaemonConfig(data)
+		if err != nil {
+			return err
+		}
+
+		out := common.Out
+		for _, change := range m.Changes {
+			out.Statusf("warning: %s\n", change)
+		}
+		if len(m.Unknown) > 0 {
+			if !ignoreUnknown {
+				return fmt.Errorf("%w: unknown keys in %s: %s (fix them or pass --ignore-unknown)",
+					types.ErrInvalidInput, path, strings.Join(m.Unknown, ", "))
+			}
+			out.Statusf("warning: keeping unknown keys: %s\n", strings.Join(m.Unknown, ", "))
+		}
+
+		result := migrateResult{Path: path, Changes: m.Changes, Unknown: m.Unknown}
+		if result.Changes == nil {
+			result.Changes = []string{}
+		}
+		if dryRun {
+			result.Config = string(m.Data)
+			return out.Result(result, func() { out.Printf("%s", result.Config) })
+		}
+		if len(m.Changes) == 0 {
+			return out.Result(result, func() { out.Printf("%s is up to date\n", path) })
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat config: %w", err)
+		}
+		// The config may hold the PSK, so keep the backup as private as the original
+		if err := os.WriteFile(path+".bak", data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to back up config: %w", err)
+		}
+		if err := os.WriteFile(path, m.Data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write config: %w", err)
+		}
+
+		result.Backup = path + ".bak"
+		return out.Result(result, func() {
+			out.Printf("✓ Migrated %s (%d change(s), original saved as %s)\n", path, len(m.Changes), result.Backup)
+		})
+	},
+}
+
+func init() {
+	migrateCmd.Flags().BoolVar(&ignoreUnknown, "ignore-unknown", false, "migrate even if the file has keys the current schema does not know")
+	migrateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the migrated config instead of writing it")
+
+	Cmd.AddCommand(migrateCmd)
+}
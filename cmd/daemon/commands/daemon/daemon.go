@@ -1,7 +1,12 @@
 package daemon
 
 import (
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/bootstrap"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/config"
 	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/install"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/join"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/ownership"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/relay"
 	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/restart"
 	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/run"
 	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/start"
@@ -20,10 +25,15 @@ var Cmd = &cobra.Command{
 
 func init() {
 	Cmd.AddCommand(run.Cmd)
+	Cmd.AddCommand(bootstrap.Cmd)
+	Cmd.AddCommand(relay.Cmd)
+	Cmd.AddCommand(join.Cmd)
 	Cmd.AddCommand(install.Cmd)
 	Cmd.AddCommand(uninstall.Cmd)
 	Cmd.AddCommand(start.Cmd)
 	Cmd.AddCommand(stop.Cmd)
 	Cmd.AddCommand(restart.Cmd)
 	Cmd.AddCommand(status.Cmd)
+	Cmd.AddCommand(ownership.Cmd)
+	Cmd.AddCommand(config.Cmd)
 }
@@ -2,67 +2,90 @@ package install
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 
-	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/service"
 	"github.com/spf13/cobra"
-	sysdaemon "github.com/takama/daemon"
 )
 
-// systemdTemplate is a custom systemd service template with HOME environment variable set
-// This is needed because systemd services don't have HOME defined by default
-const systemdTemplate = `[Unit]
-Description={{.Description}}
-Requires={{.Dependencies}}
-After={{.Dependencies}}
-
-[Service]
-PIDFile=/var/run/{{.Name}}.pid
-ExecStartPre=/bin/rm -f /var/run/{{.Name}}.pid
-ExecStart={{.Path}} {{.Args}}
-Environment="HOME=/root"
-WorkingDirectory=/root
-Restart=on-failure
-RestartSec=5
+var (
+	opts      service.Options
+	printOnly bool
+)
 
-[Install]
-WantedBy=multi-user.target
-`
+// installDefinition is the result of install --print
+type installDefinition struct {
+	Definition string `json:"definition"`
+}
 
 // Cmd represents the install command
 var Cmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install the daemon as a system service",
-	Long:  `Install the P2P Playground daemon as a system service (systemd on Linux, launchd on macOS).`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		srv, err := sysdaemon.New(consts.DaemonServiceName, consts.DaemonServiceDescription, sysdaemon.SystemDaemon)
-		if err != nil {
-			return fmt.Errorf("failed to create daemon: %w", err)
-		}
+	Long: `Install the P2P Playground daemon as a system service (systemd on Linux,
+launchd on macOS, a Windows service on Windows).
 
-		// Set custom template for Linux systemd to include HOME environment variable
-		if runtime.GOOS == "linux" {
-			if err := srv.SetTemplate(systemdTemplate); err != nil {
-				return fmt.Errorf("failed to set service template: %w", err)
-			}
-		}
+The service runs as root unless --run-as names another account, in the home
+directory of that account unless --workdir is given, with HOME set to it.
+--env adds environment variables and may be repeated.
 
-		// Get config file from root command
-		cfgFile, _ := cmd.Flags().GetString("config")
+With --user the daemon is installed for the current user only, as a systemd
+user unit on Linux or a launchd agent on macOS, without root privileges.
+Pass --user to start, stop, restart, status and uninstall as well.
 
-		// Build arguments for the service: daemon run + optional config
-		// Note: takama/daemon automatically uses the current executable path
+--print writes the unit, property list or PowerShell script that would be
+installed to stdout instead, e.g. to review it or install it by other means.
+
+Examples:
+  p2p-daemon daemon install -c /etc/p2p-playground/daemon.yaml --run-as p2p
+  p2p-daemon daemon install --user -c ~/.p2p-playground/daemon.yaml
+  p2p-daemon daemon install --print --env HTTPS_PROXY=http://proxy:3128`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Build arguments for the service: daemon run + optional config.
+		// The service does not start in the current directory.
 		serviceArgs := []string{"daemon", "run"}
-		if cfgFile != "" {
-			serviceArgs = append(serviceArgs, "-c", cfgFile)
+		if cfgFile, _ := cmd.Flags().GetString("config"); cfgFile != "" {
+			abs, err := filepath.Abs(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to resolve config path: %w", err)
+			}
+			serviceArgs = append(serviceArgs, "-c", abs)
+		}
+
+		if printOnly {
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to get executable path: %w", err)
+			}
+			definition, err := service.Render(&opts, exe, serviceArgs)
+			if err != nil {
+				return err
+			}
+			return common.Out.Result(installDefinition{Definition: definition}, func() {
+				common.Out.Printf("%s", definition)
+			})
 		}
 
-		status, err := srv.Install(serviceArgs...)
+		status, err := service.Install(&opts, serviceArgs)
 		if err != nil {
-			return fmt.Errorf("failed to install service: %w", err)
+			return err
 		}
 
-		fmt.Println(status)
+		if err := common.ServiceStatus(status); err != nil {
+			return err
+		}
+		if opts.User && runtime.GOOS == "linux" {
+			common.Out.Statusln("To keep the daemon running while you are logged out, enable lingering:")
+			common.Out.Statusln("  loginctl enable-linger $USER")
+		}
 		return nil
 	},
 }
+
+func init() {
+	service.AddInstallFlags(Cmd.Flags(), &opts)
+	Cmd.Flags().BoolVar(&printOnly, "print", false, "print the service definition instead of installing it")
+}
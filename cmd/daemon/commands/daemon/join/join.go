@@ -0,0 +1,186 @@
+package join
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	psk     string
+	pskFile string
+	name    string
+	dataDir string
+	force   bool
+)
+
+// joinResult describes the config written for the node
+type joinResult struct {
+	Node           string   `json:"node"`
+	Cluster        string   `json:"cluster"`
+	Config         string   `json:"config"`
+	TrustedKey     string   `json:"trusted_key"`
+	BootstrapPeers []string `json:"bootstrap_peers"`
+}
+
+// Cmd represents the join command
+var Cmd = &cobra.Command{
+	Use:   "join <token>",
+	Short: "Configure this node to join a cluster from a join token",
+	Long: `Configure a fresh node from a join token created with "controller token create".
+
+A daemon config is written with the token's bootstrap peers, the cluster PSK
+and the label cluster=<name>, and the key that signed the token is installed
+as a trusted package signing key. The PSK is not part of the token: pass it
+with --psk or --psk-file; it is checked against the fingerprint in the token.
+
+The config is written to the file given with -c (default:
+~/.p2p-playground/daemon.yaml). An existing config is only replaced with --force.
+
+Example:
+  p2p-daemon daemon join p2pjoin1.eyJj... --psk-file ./psk
+  p2p-daemon daemon run -c ~/.p2p-playground/daemon.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token, err := security.ParseJoinToken(args[0], time.Now())
+		if err != nil {
+			return err
+		}
+
+		encodedPSK, err := clusterPSK(token)
+		if err != nil {
+			return err
+		}
+
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+		cfgFile, _ := cmd.Flags().GetString("config")
+		if cfgFile == "" {
+			cfgFile = filepath.Join(home, ".p2p-playground", "daemon.yaml")
+		}
+		if _, err := os.Stat(cfgFile); err == nil && !force {
+			return fmt.Errorf("%w: %s exists, pass --force to replace it", types.ErrAlreadyExists, cfgFile)
+		}
+
+		dir := dataDir
+		if dir == "" {
+			dir = filepath.Join(home, ".p2p-playground")
+		}
+		dir, err = filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve data directory: %w", err)
+		}
+
+		// Start from the defaults, since they do not apply once a config file exists
+		cfg, err := config.LoadDaemonConfig("")
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			cfg.Node.Name = name
+		}
+		cfg.Node.BootstrapPeers = token.BootstrapPeers
+		cfg.Node.Labels = map[string]string{"cluster": token.Cluster}
+		cfg.Storage.DataDir = dir
+		cfg.Storage.PackagesDir = filepath.Join(dir, "packages")
+		cfg.Storage.AppsDir = filepath.Join(dir, "apps")
+		cfg.Storage.KeysDir = filepath.Join(dir, "keys")
+		cfg.Node.IdentityFile = filepath.Join(cfg.Storage.KeysDir, "identity.key")
+		cfg.Security.PublicKeysDir = filepath.Join(cfg.Storage.KeysDir, "trusted")
+		if encodedPSK != "" {
+			cfg.Security.EnableAuth = true
+			cfg.Security.PSK = encodedPSK
+		}
+
+		// Trust packages signed by the token's issuer
+		issuerKey := filepath.Join(cfg.Security.PublicKeysDir, token.Cluster+".pub")
+		if err := os.MkdirAll(cfg.Security.PublicKeysDir, 0700); err != nil {
+			return fmt.Errorf("failed to create trusted keys directory: %w", err)
+		}
+		if err := os.WriteFile(issuerKey, token.IssuerKey, 0644); err != nil {
+			return fmt.Errorf("failed to write trusted key: %w", err)
+		}
+
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to marshal config: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(cfgFile), 0755); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		// The config holds the PSK
+		if err := os.WriteFile(cfgFile, data, 0600); err != nil {
+			return fmt.Errorf("failed to write config: %w", err)
+		}
+
+		result := joinResult{
+			Node:           cfg.Node.Name,
+			Cluster:        token.Cluster,
+			Config:         cfgFile,
+			TrustedKey:     issuerKey,
+			BootstrapPeers: token.BootstrapPeers,
+		}
+		out := common.Out
+		return out.Result(result, func() {
+			out.Printf("✓ Node %q configured to join cluster %q\n", result.Node, result.Cluster)
+			out.Printf("  Config:      %s\n", result.Config)
+			out.Printf("  Trusted key: %s\n", result.TrustedKey)
+			out.Printf("  Bootstrap:   %d peer(s)\n", len(result.BootstrapPeers))
+			out.Println()
+			out.Println("Start the daemon with:")
+			out.Printf("  p2p-daemon daemon run -c %s\n", result.Config)
+			out.Println("or install it as a service with:")
+			out.Printf("  p2p-daemon daemon install -c %s\n", result.Config)
+		})
+	},
+}
+
+// clusterPSK returns the PSK given on the command line, checked against the
+// token's fingerprint, or "" if the cluster has none
+func clusterPSK(token *security.JoinToken) (string, error) {
+	encoded := psk
+	if pskFile != "" {
+		key, err := security.LoadPSK(pskFile)
+		if err != nil {
+			return "", err
+		}
+		encoded = security.EncodePSK(key)
+	}
+
+	if token.PSKFingerprint == "" {
+		if encoded != "" {
+			return "", fmt.Errorf("%w: cluster %q does not use a PSK", types.ErrInvalidInput, token.Cluster)
+		}
+		return "", nil
+	}
+	if encoded == "" {
+		return "", fmt.Errorf("%w: cluster %q requires its PSK, pass --psk or --psk-file", types.ErrInvalidInput, token.Cluster)
+	}
+	fingerprint, err := security.PSKFingerprint(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", types.ErrInvalidInput, err)
+	}
+	if fingerprint != token.PSKFingerprint {
+		return "", fmt.Errorf("%w: PSK does not match cluster %q (fingerprint %s, want %s)", types.ErrUnauthorized, token.Cluster, fingerprint, token.PSKFingerprint)
+	}
+	return encoded, nil
+}
+
+func init() {
+	Cmd.Flags().StringVar(&psk, "psk", "", "cluster PSK (hex)")
+	Cmd.Flags().StringVar(&pskFile, "psk-file", "", "file containing the cluster PSK")
+	Cmd.Flags().StringVar(&name, "name", "", "node name (default: daemon-<hostname>)")
+	Cmd.Flags().StringVar(&dataDir, "data-dir", "", "directory for node data (default: ~/.p2p-playground)")
+	Cmd.Flags().BoolVar(&force, "force", false, "replace an existing config file")
+	Cmd.MarkFlagsMutuallyExclusive("psk", "psk-file")
+}
@@ -0,0 +1,145 @@
+package ownership
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	pkgownership "github.com/asjdf/p2p-playground-lite/pkg/ownership"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keyFile string
+)
+
+// ownerResult is the owner an application was given or released from
+type ownerResult struct {
+	App   string `json:"app"`
+	Owner string `json:"owner,omitempty"`
+}
+
+// Cmd represents the ownership command
+var Cmd = &cobra.Command{
+	Use:   "ownership",
+	Short: "Manage application ownership",
+	Long: `Manage which signing key owns each application name on this node.
+
+The first key to deploy an application name owns it, and later versions signed
+by other keys are rejected. These commands edit the ownership records in the
+daemon's data directory directly; a running daemon picks up changes immediately.`,
+}
+
+// listCmd lists ownership records
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List application owners",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+
+		records, err := store.List(context.Background())
+		if err != nil {
+			return err
+		}
+
+		if records == nil {
+			records = []*pkgownership.Record{}
+		}
+		out := common.Out
+		return out.Result(records, func() {
+			if len(records) == 0 {
+				out.Println("No owned applications")
+				return
+			}
+			for _, rec := range records {
+				out.Printf("%s\t%s\t%s\n", rec.App, rec.PublicKey, rec.KeyName)
+			}
+		})
+	},
+}
+
+// transferCmd assigns an application to a new key
+var transferCmd = &cobra.Command{
+	Use:   "transfer <app-name>",
+	Short: "Transfer an application to another signing key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pubKey, err := security.LoadPublicKey(keyFile)
+		if err != nil {
+			return err
+		}
+
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+
+		keyName := strings.TrimSuffix(filepath.Base(keyFile), ".pub")
+		if err := store.Transfer(context.Background(), args[0], keyName, pubKey); err != nil {
+			return err
+		}
+
+		result := ownerResult{App: args[0], Owner: keyName}
+		return common.Out.Result(result, func() {
+			common.Out.Printf("✓ %s is now owned by %s\n", result.App, result.Owner)
+		})
+	},
+}
+
+// releaseCmd removes the owner of an application
+var releaseCmd = &cobra.Command{
+	Use:   "release <app-name>",
+	Short: "Remove the owner of an application",
+	Long:  `Remove the owner of an application. The next signed deployment claims it.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+
+		if err := store.Release(context.Background(), args[0]); err != nil {
+			return err
+		}
+
+		result := ownerResult{App: args[0]}
+		return common.Out.Result(result, func() {
+			common.Out.Printf("✓ %s no longer has an owner\n", result.App)
+		})
+	},
+}
+
+// openStore opens the ownership store in the configured data directory
+func openStore(cmd *cobra.Command) (*pkgownership.Store, error) {
+	// Get config file from root command
+	cfgFile, _ := cmd.Flags().GetString("config")
+
+	cfg, err := config.LoadDaemonConfig(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := storage.NewFileStorage(cfg.Storage.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+
+	return pkgownership.NewStore(st), nil
+}
+
+func init() {
+	transferCmd.Flags().StringVar(&keyFile, "key", "", "public key file (.pub) of the new owner")
+	_ = transferCmd.MarkFlagRequired("key")
+
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(transferCmd)
+	Cmd.AddCommand(releaseCmd)
+}
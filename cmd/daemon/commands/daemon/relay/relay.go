@@ -0,0 +1,127 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/spf13/cobra"
+)
+
+var (
+	announceIP string
+)
+
+// relayResult is printed once the relay is up
+type relayResult struct {
+	PeerID string   `json:"peer_id"`
+	Addrs  []string `json:"addrs"`
+}
+
+// Cmd represents the relay command
+var Cmd = &cobra.Command{
+	Use:   "relay",
+	Short: "Run a dedicated relay for nodes behind NAT",
+	Long: `Run a minimal host that only relays connections (circuit relay v2) and
+serves the DHT as a rendezvous point, with limits sized for a dedicated relay.
+No apps are run. Intended for a small publicly reachable server bridging
+nodes behind NAT.
+
+The node, storage, logging and security (PSK) sections of the daemon config
+are used. The relay keeps its peer ID across restarts, and its addresses are
+printed at startup for use as static_relays and bootstrap_peers of other nodes.
+
+Example:
+  p2p-daemon daemon relay -c /etc/p2p-playground/relay.yaml --announce-ip 203.0.113.10`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgFile, _ := cmd.Flags().GetString("config")
+		cfg, err := config.LoadDaemonConfig(cfgFile)
+		if err != nil {
+			return err
+		}
+
+		logger, err := logging.New(&cfg.Logging)
+		if err != nil {
+			return fmt.Errorf("failed to create logger: %w", err)
+		}
+
+		identityFile := cfg.Node.IdentityFile
+		if identityFile == "" {
+			identityFile = filepath.Join(cfg.Storage.KeysDir, "identity.key")
+		}
+		identity, err := p2p.LoadOrGenerateIdentity(identityFile)
+		if err != nil {
+			return fmt.Errorf("failed to load identity: %w", err)
+		}
+
+		announceAddrs := cfg.Node.AnnounceAddrs
+		if announceIP != "" {
+			announceAddrs, err = p2p.AnnounceAddrsForIP(cfg.Node.ListenAddrs, announceIP)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// A relay is reachable by definition and needs no relay or hole punching itself
+		host, err := p2p.NewHost(ctx, &p2p.HostConfig{
+			ListenAddrs:             cfg.Node.ListenAddrs,
+			AddressFamily:           cfg.Node.AddressFamily,
+			PSK:                     cfg.Security.PSK,
+			EnableAuth:              cfg.Security.EnableAuth,
+			TrustedPeers:            cfg.Security.TrustedPeers,
+			BootstrapPeers:          cfg.Node.BootstrapPeers,
+			DisableDHT:              cfg.Node.DisableDHT,
+			DHTMode:                 "server",
+			DisableNATService:       cfg.Node.DisableNATService,
+			DisableAutoRelay:        true,
+			DisableHolePunching:     true,
+			Identity:                identity,
+			AnnounceAddrs:           announceAddrs,
+			RelayResources:          p2p.DedicatedRelayResources(),
+			ForceReachabilityPublic: true,
+		}, logger)
+		if err != nil {
+			return err
+		}
+
+		result := relayResult{PeerID: host.ID()}
+		for _, addr := range host.Addrs() {
+			result.Addrs = append(result.Addrs, fmt.Sprintf("%s/p2p/%s", addr, host.ID()))
+		}
+		out := common.Out
+		err = out.Result(result, func() {
+			out.Printf("Relay running with peer ID %s\n\n", result.PeerID)
+			out.Println("Add these addresses to static_relays (and bootstrap_peers) of nodes behind NAT:")
+			for _, addr := range result.Addrs {
+				out.Printf("  %s\n", addr)
+			}
+		})
+		if err != nil {
+			_ = host.Close()
+			return err
+		}
+
+		// Wait for signal
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+		<-sigChan
+
+		logger.Info("stopping relay")
+		return host.Close()
+	},
+}
+
+func init() {
+	Cmd.Flags().StringVar(&announceIP, "announce-ip", "", "public IP address advertised instead of the listen addresses")
+}
@@ -5,6 +5,7 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
 	"github.com/asjdf/p2p-playground-lite/pkg/config"
 	"github.com/asjdf/p2p-playground-lite/pkg/daemon"
 	"github.com/spf13/cobra"
@@ -14,7 +15,11 @@ import (
 var Cmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run the daemon in foreground",
-	Long:  `Run the P2P Playground daemon in foreground mode. Use Ctrl+C to stop.`,
+	Long: `Run the P2P Playground daemon in foreground mode. Use Ctrl+C to stop.
+
+Once started, the daemon prints a connection card with its peer ID, its most
+routable addresses and snippets for connecting controllers and other nodes to
+it. The card is also written to connection.txt in the data directory.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Get config file from root command
 		cfgFile, _ := cmd.Flags().GetString("config")
@@ -35,6 +40,11 @@ var Cmd = &cobra.Command{
 		if err := d.Start(); err != nil {
 			return err
 		}
+		card := d.ConnectionCard()
+		if err := common.Out.Result(card, func() { common.Out.Printf("%s", card) }); err != nil {
+			_ = d.Stop()
+			return err
+		}
 
 		// Wait for signal
 		sigChan := make(chan os.Signal, 1)
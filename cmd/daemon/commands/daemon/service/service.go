@@ -0,0 +1,183 @@
+// Package service installs and controls the daemon as an operating system
+// service: a systemd unit on Linux, a launchd job on macOS or a Windows
+// service. On Linux and macOS the service can also be installed for the
+// current user only, as a systemd user unit or a launchd agent.
+package service
+
+import (
+	"fmt"
+	"os/user"
+	"runtime"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/pflag"
+	sysdaemon "github.com/takama/daemon"
+)
+
+// Options selects and configures the daemon service
+type Options struct {
+	// User selects the service of the current user instead of the system service
+	User bool
+
+	// RunAs is the account the system service runs as, "" for root
+	// (LocalSystem on Windows)
+	RunAs string
+
+	// WorkDir is the working directory of the service, "" for the home
+	// directory of the account it runs as
+	WorkDir string
+
+	// Env holds extra KEY=VALUE environment variables of the service
+	Env []string
+}
+
+// AddFlags adds the flags selecting the service to flags
+func AddFlags(flags *pflag.FlagSet, opts *Options) {
+	flags.BoolVar(&opts.User, "user", false, "the service of the current user (systemd user unit, launchd agent) instead of the system service")
+}
+
+// AddInstallFlags adds the flags selecting and configuring the installed service to flags
+func AddInstallFlags(flags *pflag.FlagSet, opts *Options) {
+	AddFlags(flags, opts)
+	flags.StringVar(&opts.RunAs, "run-as", "", "account the system service runs as (default: root)")
+	flags.StringVar(&opts.WorkDir, "workdir", "", "working directory of the service (default: home directory of the account it runs as)")
+	flags.StringArrayVar(&opts.Env, "env", nil, "extra environment variable of the service as KEY=VALUE (repeatable)")
+}
+
+// New returns the daemon service selected by opts
+func New(opts *Options) (sysdaemon.Daemon, error) {
+	name, description := consts.DaemonServiceName, consts.DaemonServiceDescription
+	switch {
+	case runtime.GOOS == "linux" && opts.User:
+		return newUserUnit(name, description)
+	case runtime.GOOS == "darwin" && opts.User:
+		return sysdaemon.New(name, description, sysdaemon.UserAgent)
+	case runtime.GOOS == "darwin":
+		return sysdaemon.New(name, description, sysdaemon.GlobalDaemon)
+	case opts.User:
+		return nil, fmt.Errorf("%w: per-user services are not supported on %s", types.ErrInvalidInput, runtime.GOOS)
+	default:
+		return sysdaemon.New(name, description, sysdaemon.SystemDaemon)
+	}
+}
+
+// Install installs the service selected by opts, running the daemon with args
+func Install(opts *Options, args []string) (string, error) {
+	def, err := resolve(opts)
+	if err != nil {
+		return "", err
+	}
+	srv, err := New(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to create daemon: %w", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		// The Windows service is created from its configuration, not a template
+		status, err := srv.Install(args...)
+		if err != nil {
+			return "", fmt.Errorf("failed to install service: %w", err)
+		}
+		if err := configureWindowsService(consts.DaemonServiceName, opts.RunAs, def.Env); err != nil {
+			return "", fmt.Errorf("failed to configure service: %w", err)
+		}
+		return status, nil
+	}
+
+	tmpl, err := serviceTemplate(runtime.GOOS, def)
+	if err != nil {
+		return "", err
+	}
+	if err := srv.SetTemplate(tmpl); err != nil {
+		return "", fmt.Errorf("failed to set service template: %w", err)
+	}
+	status, err := srv.Install(args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to install service: %w", err)
+	}
+	return status, nil
+}
+
+// definition is the service configuration with every default resolved
+type definition struct {
+	Name        string
+	Description string
+
+	// User is the account the service runs as, "" for the default
+	User string
+
+	WorkDir string
+	Env     []string
+
+	// UserService marks a service of the current user
+	UserService bool
+
+	// LogDir is where launchd writes the output of the service
+	LogDir string
+}
+
+// resolve checks opts and fills in the defaults for this host
+func resolve(opts *Options) (*definition, error) {
+	def := &definition{
+		Name:        consts.DaemonServiceName,
+		Description: consts.DaemonServiceDescription,
+		User:        opts.RunAs,
+		WorkDir:     opts.WorkDir,
+		UserService: opts.User,
+	}
+
+	if runtime.GOOS == "windows" && (opts.User || opts.WorkDir != "") {
+		return nil, fmt.Errorf("%w: Windows services are system services without a working directory setting", types.ErrInvalidInput)
+	}
+
+	var account *user.User
+	var err error
+	switch {
+	case opts.User && opts.RunAs != "":
+		return nil, fmt.Errorf("%w: --run-as only applies to the system service", types.ErrInvalidInput)
+	case opts.User:
+		account, err = user.Current()
+	case runtime.GOOS == "windows":
+		// Windows services are not given a home directory
+	case opts.RunAs != "":
+		account, err = user.Lookup(opts.RunAs)
+	default:
+		account, err = user.Lookup("root")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to look up service account: %w", types.ErrInvalidInput, err)
+	}
+
+	if account != nil {
+		if def.WorkDir == "" {
+			def.WorkDir = account.HomeDir
+		}
+		// The system service manager sets no HOME for root
+		if !opts.User {
+			def.Env = append(def.Env, "HOME="+account.HomeDir)
+		}
+	}
+	for _, env := range opts.Env {
+		if key, _, ok := strings.Cut(env, "="); !ok || key == "" {
+			return nil, fmt.Errorf("%w: environment variable %q is not KEY=VALUE", types.ErrInvalidInput, env)
+		}
+		def.Env = append(def.Env, env)
+	}
+
+	// Values are placed into a template of the service library
+	for _, value := range append([]string{def.User, def.WorkDir}, def.Env...) {
+		if strings.Contains(value, "{{") || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%w: %q cannot be written to a service definition", types.ErrInvalidInput, value)
+		}
+	}
+
+	if runtime.GOOS == "darwin" {
+		def.LogDir = "/Library/Logs"
+		if opts.User {
+			def.LogDir = account.HomeDir + "/Library/Logs"
+		}
+	}
+	return def, nil
+}
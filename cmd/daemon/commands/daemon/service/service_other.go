@@ -0,0 +1,8 @@
+//go:build !windows
+
+package service
+
+// configureWindowsService is only called on Windows
+func configureWindowsService(name, account string, env []string) error {
+	return nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// configureWindowsService sets the account and the environment of the
+// installed service, which the service library leaves at their defaults
+func configureWindowsService(name, account string, env []string) error {
+	if account != "" {
+		m, err := mgr.Connect()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = m.Disconnect() }()
+
+		s, err := m.OpenService(name)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = s.Close() }()
+
+		cfg, err := s.Config()
+		if err != nil {
+			return err
+		}
+		// Only accounts without a password, such as NT AUTHORITY\LocalService
+		cfg.ServiceStartName = account
+		if err := s.UpdateConfig(cfg); err != nil {
+			return fmt.Errorf("failed to set service account: %w", err)
+		}
+	}
+
+	if len(env) > 0 {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+name, registry.SET_VALUE)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = key.Close() }()
+		if err := key.SetStringsValue("Environment", env); err != nil {
+			return fmt.Errorf("failed to set service environment: %w", err)
+		}
+	}
+	return nil
+}
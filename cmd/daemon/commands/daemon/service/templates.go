@@ -0,0 +1,197 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// The templates below are rendered in two passes. The first fills in the
+// service configuration between [[ ]]; the result is a template of the
+// service library, which fills in the executable and its arguments between
+// {{ }} when installing.
+
+// systemdTemplate is the systemd unit of the system or user service
+const systemdTemplate = `[Unit]
+Description={{.Description}}
+[[- if not .UserService]]
+Requires={{.Dependencies}}
+After={{.Dependencies}}
+[[- end]]
+
+[Service]
+ExecStart={{.Path}} {{.Args}}
+[[- if .User]]
+User=[[systemd .User]]
+[[- end]]
+[[- range .Env]]
+Environment="[[systemdQuoted .]]"
+[[- end]]
+WorkingDirectory=[[systemd .WorkDir]]
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=[[if .UserService]]default.target[[else]]multi-user.target[[end]]
+`
+
+// launchdTemplate is the property list of the launchd daemon or agent
+const launchdTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Name}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{.Path}}</string>
+		{{range .Args}}<string>{{.}}</string>
+		{{end}}
+	</array>
+[[- if .User]]
+	<key>UserName</key>
+	<string>[[xml .User]]</string>
+[[- end]]
+	<key>WorkingDirectory</key>
+	<string>[[xml .WorkDir]]</string>
+[[- if .Env]]
+	<key>EnvironmentVariables</key>
+	<dict>
+[[- range .Env]]
+		<key>[[xml (envKey .)]]</key>
+		<string>[[xml (envValue .)]]</string>
+[[- end]]
+	</dict>
+[[- end]]
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>[[xml .LogDir]]/{{.Name}}.log</string>
+	<key>StandardErrorPath</key>
+	<string>[[xml .LogDir]]/{{.Name}}.err</string>
+</dict>
+</plist>
+`
+
+// windowsScript is a PowerShell script creating the Windows service. It is
+// only printed; installing creates the service through the service manager.
+const windowsScript = `# Creates the Windows service {{.Name}}: {{.Description}}
+New-Service -Name '{{.Name}}' -DisplayName '{{.Name}}' -Description '{{powershell .Description}}' ` +
+	"`" + `
+    -BinaryPathName '{{powershell .Command}}' -StartupType Automatic
+{{- if .User}}
+sc.exe config '{{.Name}}' obj= '{{powershell .User}}'
+{{- end}}
+{{- if .Env}}
+Set-ItemProperty -Path 'HKLM:\SYSTEM\CurrentControlSet\Services\{{.Name}}' -Name Environment -Type MultiString ` +
+	"`" + `
+    -Value @({{range $i, $env := .Env}}{{if $i}}, {{end}}'{{powershell $env}}'{{end}})
+{{- end}}
+`
+
+// templateFuncs escape values for the service definitions
+var templateFuncs = template.FuncMap{
+	"systemd": func(s string) string {
+		return strings.ReplaceAll(s, "%", "%%")
+	},
+	"systemdQuoted": func(s string) string {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		s = strings.ReplaceAll(s, `"`, `\"`)
+		return strings.ReplaceAll(s, "%", "%%")
+	},
+	"xml": func(s string) (string, error) {
+		var buf bytes.Buffer
+		if err := xml.EscapeText(&buf, []byte(s)); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	},
+	"envKey": func(env string) string {
+		key, _, _ := strings.Cut(env, "=")
+		return key
+	},
+	"envValue": func(env string) string {
+		_, value, _ := strings.Cut(env, "=")
+		return value
+	},
+	"powershell": func(s string) string {
+		return strings.ReplaceAll(s, "'", "''")
+	},
+}
+
+// serviceTemplate returns the service library template defining the service on goos
+func serviceTemplate(goos string, def *definition) (string, error) {
+	var text string
+	switch goos {
+	case "linux":
+		text = systemdTemplate
+	case "darwin":
+		text = launchdTemplate
+	default:
+		return "", fmt.Errorf("no service template for %s", goos)
+	}
+
+	tmpl, err := template.New(goos).Delims("[[", "]]").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, def); err != nil {
+		return "", fmt.Errorf("failed to render service template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Render returns the definition of the service selected by opts on this
+// platform, running path with args, as it would be installed. On Windows it is
+// a PowerShell script creating the service.
+func Render(opts *Options, path string, args []string) (string, error) {
+	def, err := resolve(opts)
+	if err != nil {
+		return "", err
+	}
+
+	if runtime.GOOS == "windows" {
+		tmpl, err := template.New("windows").Funcs(templateFuncs).Parse(windowsScript)
+		if err != nil {
+			return "", err
+		}
+		command := append([]string{`"` + path + `"`}, args...)
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, struct {
+			*definition
+			Command string
+		}{def, strings.Join(command, " ")})
+		return buf.String(), err
+	}
+
+	text, err := serviceTemplate(runtime.GOOS, def)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("service").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	// The same data the service library renders its templates with
+	var data interface{} = struct {
+		Name, Description, Dependencies, Path, Args string
+	}{def.Name, def.Description, "", path, strings.Join(args, " ")}
+	if runtime.GOOS == "darwin" {
+		data = struct {
+			Name, Path string
+			Args       []string
+		}{def.Name, path, args}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	sysdaemon "github.com/takama/daemon"
+)
+
+// userUnit is the daemon installed as a systemd user unit, managed by the
+// service manager of the current user. The service library only installs
+// system units.
+type userUnit struct {
+	name        string
+	description string
+	template    string
+	path        string
+}
+
+// newUserUnit returns the user unit of the daemon
+func newUserUnit(name, description string) (*userUnit, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return &userUnit{
+		name:        name,
+		description: description,
+		path:        filepath.Join(dir, "systemd", "user", name+".service"),
+	}, nil
+}
+
+// GetTemplate implements sysdaemon.Daemon
+func (u *userUnit) GetTemplate() string {
+	return u.template
+}
+
+// SetTemplate implements sysdaemon.Daemon
+func (u *userUnit) SetTemplate(tmpl string) error {
+	u.template = tmpl
+	return nil
+}
+
+// Install implements sysdaemon.Daemon
+func (u *userUnit) Install(args ...string) (string, error) {
+	if _, err := os.Stat(u.path); err == nil {
+		return "", sysdaemon.ErrAlreadyInstalled
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	tmpl, err := template.New("unit").Parse(u.template)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct {
+		Name, Description, Dependencies, Path, Args string
+	}{u.name, u.description, "", exe, strings.Join(args, " ")}); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(u.path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create unit directory: %w", err)
+	}
+	if err := os.WriteFile(u.path, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write unit: %w", err)
+	}
+	if err := systemctlUser("daemon-reload"); err != nil {
+		_ = os.Remove(u.path)
+		return "", err
+	}
+	if err := systemctlUser("enable", u.name+".service"); err != nil {
+		_ = os.Remove(u.path)
+		return "", err
+	}
+	return fmt.Sprintf("Installed %s as user unit %s", u.description, u.path), nil
+}
+
+// Remove implements sysdaemon.Daemon
+func (u *userUnit) Remove() (string, error) {
+	if _, err := os.Stat(u.path); err != nil {
+		return "", sysdaemon.ErrNotInstalled
+	}
+	if err := systemctlUser("disable", "--now", u.name+".service"); err != nil {
+		return "", err
+	}
+	if err := os.Remove(u.path); err != nil {
+		return "", fmt.Errorf("failed to remove unit: %w", err)
+	}
+	if err := systemctlUser("daemon-reload"); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Removed user unit %s", u.path), nil
+}
+
+// Start implements sysdaemon.Daemon
+func (u *userUnit) Start() (string, error) {
+	if err := systemctlUser("start", u.name+".service"); err != nil {
+		return "", err
+	}
+	return "Started " + u.description, nil
+}
+
+// Stop implements sysdaemon.Daemon
+func (u *userUnit) Stop() (string, error) {
+	if err := systemctlUser("stop", u.name+".service"); err != nil {
+		return "", err
+	}
+	return "Stopped " + u.description, nil
+}
+
+// Status implements sysdaemon.Daemon
+func (u *userUnit) Status() (string, error) {
+	if _, err := os.Stat(u.path); err != nil {
+		return "", sysdaemon.ErrNotInstalled
+	}
+	out, _ := exec.Command("systemctl", "--user", "is-active", u.name+".service").Output()
+	if strings.TrimSpace(string(out)) == "active" {
+		return "Service is running...", nil
+	}
+	return "Service is stopped", nil
+}
+
+// Run implements sysdaemon.Daemon. The daemon runs through "daemon run".
+func (u *userUnit) Run(sysdaemon.Executable) (string, error) {
+	return "", errors.New("user units are run by the service manager")
+}
+
+// systemctlUser runs systemctl on the service manager of the current user
+func systemctlUser(args ...string) error {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl --user %s: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
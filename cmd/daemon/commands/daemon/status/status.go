@@ -1,31 +1,116 @@
 package status
 
 import (
+	"context"
 	"fmt"
+	"text/tabwriter"
+	"time"
 
-	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/service"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/daemon"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
 	"github.com/spf13/cobra"
-	sysdaemon "github.com/takama/daemon"
 )
 
+var opts service.Options
+
 // Cmd represents the status command
 var Cmd = &cobra.Command{
 	Use:   "status",
-	Short: "Show daemon service status",
-	Long:  `Display the current status of the P2P Playground daemon system service.`,
+	Short: "Show daemon service status and the state of its subsystems",
+	Long: `Display the current status of the P2P Playground daemon system service.
+
+If a daemon is running, its subsystems are listed too, asked over the local
+socket of the daemon configured with --config: whether storage is open, the
+host listening, discovery joined, the handlers registered and so on, so that
+a daemon that is up but not discoverable shows why.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		srv, err := sysdaemon.New(consts.DaemonServiceName, consts.DaemonServiceDescription, sysdaemon.SystemDaemon)
+		srv, err := service.New(&opts)
 		if err != nil {
 			return fmt.Errorf("failed to create daemon: %w", err)
 		}
 
-		status, err := srv.Status()
+		var result statusResult
+		if result.Service, err = srv.Status(); err != nil {
+			result.Service, result.ServiceError = "", err.Error()
+		}
+
+		cfgFile, _ := cmd.Flags().GetString("config")
+		cfg, err := config.LoadDaemonConfig(cfgFile)
 		if err != nil {
-			fmt.Printf("Service status: %s\n", err)
-			return nil
+			return err
+		}
+		if cfg.Node.DisableLocalSocket {
+			result.DaemonError = "the local socket is disabled (node.disable_local_socket)"
+		} else {
+			result.Socket = daemon.LocalSocket(cfg)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if result.Daemon, err = daemon.LocalNodeInfo(ctx, result.Socket); err != nil {
+				result.DaemonError = err.Error()
+			}
 		}
 
-		fmt.Println(status)
-		return nil
+		return common.Out.Result(result, func() { printStatus(&result) })
 	},
 }
+
+// statusResult is the state of the service and of the daemon it runs
+type statusResult struct {
+	Service      string `json:"service,omitempty"`
+	ServiceError string `json:"service_error,omitempty"`
+
+	// Socket is the local socket the daemon was asked on
+	Socket string `json:"socket,omitempty"`
+
+	// Daemon is what the daemon reports of itself, if it could be asked
+	Daemon      *types.NodeInfo `json:"daemon,omitempty"`
+	DaemonError string          `json:"daemon_error,omitempty"`
+}
+
+// printStatus prints the status as text
+func printStatus(result *statusResult) {
+	out := common.Out
+	if result.ServiceError != "" {
+		out.Printf("Service status: %s\n", result.ServiceError)
+	} else {
+		out.Println(result.Service)
+	}
+
+	switch {
+	case result.Socket == "":
+		out.Printf("Subsystems: unknown, %s\n", result.DaemonError)
+	case result.Daemon == nil:
+		out.Printf("Daemon: not reachable on %s: %s\n", result.Socket, result.DaemonError)
+	default:
+		node := result.Daemon
+		out.Printf("Daemon: %s (%s), up %s\n", node.Name, node.ID, time.Duration(node.UptimeSeconds)*time.Second)
+		printComponents(node.Components)
+	}
+}
+
+// printComponents prints the state of each subsystem
+func printComponents(components []*types.ComponentStatus) {
+	out := common.Out
+	if len(components) == 0 {
+		out.Println("Subsystems: not reported; upgrade the daemon")
+		return
+	}
+	out.Println("Subsystems:")
+	w := tabwriter.NewWriter(out.Stdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "  NAME\tSTATE\tDETAIL")
+	for _, c := range components {
+		detail := c.Detail
+		if c.Error != "" {
+			detail = c.Error
+		}
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\n", c.Name, c.State, detail)
+	}
+	_ = w.Flush()
+}
+
+func init() {
+	service.AddFlags(Cmd.Flags(), &opts)
+}
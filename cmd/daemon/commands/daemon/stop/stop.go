@@ -3,18 +3,20 @@ package stop
 import (
 	"fmt"
 
-	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon/service"
 	"github.com/spf13/cobra"
-	sysdaemon "github.com/takama/daemon"
 )
 
+var opts service.Options
+
 // Cmd represents the stop command
 var Cmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop the daemon system service",
 	Long:  `Stop the P2P Playground daemon system service.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		srv, err := sysdaemon.New(consts.DaemonServiceName, consts.DaemonServiceDescription, sysdaemon.SystemDaemon)
+		srv, err := service.New(&opts)
 		if err != nil {
 			return fmt.Errorf("failed to create daemon: %w", err)
 		}
@@ -24,7 +26,10 @@ var Cmd = &cobra.Command{
 			return fmt.Errorf("failed to stop service: %w", err)
 		}
 
-		fmt.Println(status)
-		return nil
+		return common.ServiceStatus(status)
 	},
 }
+
+func init() {
+	service.AddFlags(Cmd.Flags(), &opts)
+}
@@ -1,12 +1,16 @@
 package commands
 
 import (
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
 	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/daemon"
+	versioncmd "github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/version"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cfgFile string
+	cfgFile      string
+	outputFormat string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -14,6 +18,9 @@ var rootCmd = &cobra.Command{
 	Use:   "p2p-daemon",
 	Short: "P2P Playground daemon CLI",
 	Long:  `P2P Playground daemon CLI - manage the P2P Playground daemon service.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return common.InitOutput(outputFormat)
+	},
 }
 
 // GetCfgFile returns the config file path
@@ -22,10 +29,13 @@ func GetCfgFile() string {
 }
 
 func init() {
+	rootCmd.Version = version.Get().String()
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default: ~/.p2p-playground/daemon.yaml)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "output format for command results: text or json")
 
 	// Add daemon command
 	rootCmd.AddCommand(daemon.Cmd)
+	rootCmd.AddCommand(versioncmd.Cmd)
 }
 
 func Execute() error {
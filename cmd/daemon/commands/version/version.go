@@ -0,0 +1,42 @@
+package version
+
+import (
+	"github.com/asjdf/p2p-playground-lite/cmd/daemon/commands/common"
+	"github.com/asjdf/p2p-playground-lite/pkg/output"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jsonOutput bool
+)
+
+// Cmd represents the version command
+var Cmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the daemon version",
+	Long:  `Print the version, commit and build date of the daemon.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := common.Out
+		if jsonOutput {
+			out = output.New(output.FormatJSON, out.Stdout(), out.Stderr())
+		}
+		info := version.Get()
+		return out.Result(info, func() {
+			out.Printf("p2p-daemon %s\n", info.Version)
+			if info.Commit != "" {
+				out.Printf("  Commit:     %s\n", info.Commit)
+			}
+			if info.Date != "" {
+				out.Printf("  Built:      %s\n", info.Date)
+			}
+			out.Printf("  Go version: %s\n", info.GoVersion)
+			out.Printf("  Platform:   %s\n", info.Platform)
+		})
+	},
+}
+
+func init() {
+	Cmd.Flags().BoolVar(&jsonOutput, "json", false, "print the version as JSON (same as --output json)")
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+)
+
+func main() {
+	steps, err := strconv.Atoi(os.Getenv("BATCH_STEPS"))
+	if err != nil {
+		steps = 10
+	}
+
+	// Stop between steps when the daemon announces a shutdown
+	var shutdown <-chan appsdk.ShutdownNotice
+	if client, err := appsdk.New(); err == nil {
+		shutdown, _ = client.WatchShutdown(context.Background())
+	}
+
+	for step := 1; step <= steps; step++ {
+		select {
+		case notice := <-shutdown:
+			log.Printf("stopping after %d of %d steps: %s", step-1, steps, notice.Reason)
+			return
+		case <-time.After(time.Second):
+		}
+		log.Printf("step %d of %d done", step, steps)
+	}
+	log.Printf("batch complete")
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	after, err := time.ParseDuration(os.Getenv("CRASH_AFTER"))
+	if err != nil {
+		after = 5 * time.Second
+	}
+
+	log.Printf("running, crashing in %s", after)
+	time.Sleep(after)
+	log.Printf("crashing with exit code 3")
+	os.Exit(3)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:18080", "listen address")
+	flag.Parse()
+
+	var requests atomic.Int64
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		hostname, _ := os.Hostname()
+		_, _ = fmt.Fprintf(w, "Hello from %s on %s\n", os.Getenv(appsdk.EnvAppID), hostname)
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "ok")
+	})
+
+	// Report health and request count to the daemon when running under one
+	if client, err := appsdk.New(); err == nil {
+		go func() {
+			ctx := context.Background()
+			_ = client.SetHealth(ctx, appsdk.HealthHealthy, "")
+			for range time.Tick(10 * time.Second) {
+				_ = client.PublishMetrics(ctx, map[string]float64{"requests_total": float64(requests.Load())})
+			}
+		}()
+	}
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
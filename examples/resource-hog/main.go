@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+func main() {
+	megabytes, err := strconv.Atoi(os.Getenv("HOG_MEMORY_MB"))
+	if err != nil {
+		megabytes = 32
+	}
+
+	// Touch every page so the memory is resident
+	hog := make([]byte, megabytes<<20)
+	for i := 0; i < len(hog); i += 4096 {
+		hog[i] = 1
+	}
+	log.Printf("holding %d MB", megabytes)
+
+	// Keep one core busy
+	go func() {
+		x := 0
+		for {
+			x++
+		}
+	}()
+
+	for range time.Tick(10 * time.Second) {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		log.Printf("heap in use: %d MB", stats.HeapInuse>>20)
+		runtime.KeepAlive(hog)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:18081", "listen address")
+	flag.Parse()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("echoing on %s", listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			defer func() { _ = conn.Close() }()
+			n, _ := io.Copy(conn, conn)
+			log.Printf("echoed %d bytes to %s", n, conn.RemoteAddr())
+		}()
+	}
+}
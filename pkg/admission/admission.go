@@ -0,0 +1,238 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DefaultTimeout bounds a single admission hook invocation
+const DefaultTimeout = 10 * time.Second
+
+// maxReasonLength bounds the rejection reason returned to the controller
+const maxReasonLength = 1024
+
+// Request is the JSON document sent to admission hooks
+type Request struct {
+	// RequestID correlates the admission decision with daemon logs
+	RequestID string `json:"request_id,omitempty"`
+
+	// AppID is <name>-<version> of the package. The ID it is deployed as may
+	// differ, depending on the app ID format of the node.
+	AppID string `json:"app_id"`
+
+	// Manifest is the package manifest
+	Manifest *types.Manifest `json:"manifest"`
+
+	// FileName is the package file name
+	FileName string `json:"file_name"`
+
+	// FileSize is the package size in bytes
+	FileSize int64 `json:"file_size"`
+
+	// Signed reports whether the package signature was verified
+	Signed bool `json:"signed"`
+
+	// AutoStart reports whether the application would be started after deployment
+	AutoStart bool `json:"auto_start"`
+
+	// Node describes the node receiving the deployment
+	Node NodeInfo `json:"node"`
+}
+
+// NodeInfo describes the node evaluating the admission request
+type NodeInfo struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Decision is an admission hook's verdict
+type Decision struct {
+	// Allowed admits the deployment when true
+	Allowed bool `json:"allowed"`
+
+	// Reason explains a rejection and is returned to the controller
+	Reason string `json:"reason,omitempty"`
+}
+
+// Hook reviews deployments before they are unpacked
+type Hook interface {
+	// Review returns a decision, or an error if the hook could not be evaluated
+	Review(ctx context.Context, req *Request) (*Decision, error)
+}
+
+// Admitter runs the configured hooks in order; the first rejection wins
+type Admitter struct {
+	hooks    []Hook
+	failOpen bool
+	logger   types.Logger
+}
+
+// New creates an admitter from configuration. It returns nil if no hooks are configured.
+func New(cfg *config.AdmissionConfig, logger types.Logger) *Admitter {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	var hooks []Hook
+	if cfg.Script != "" {
+		hooks = append(hooks, &ScriptHook{Path: cfg.Script, Timeout: timeout})
+	}
+	if cfg.Webhook != "" {
+		hooks = append(hooks, &WebhookHook{URL: cfg.Webhook, Timeout: timeout})
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	return NewWithHooks(hooks, cfg.FailOpen, logger)
+}
+
+// NewWithHooks creates an admitter running the given hooks
+func NewWithHooks(hooks []Hook, failOpen bool, logger types.Logger) *Admitter {
+	return &Admitter{
+		hooks:    hooks,
+		failOpen: failOpen,
+		logger:   logger,
+	}
+}
+
+// Admit runs all hooks and returns an error wrapping types.ErrAdmissionDenied if any rejects.
+// Hook failures reject the deployment unless the admitter is configured to fail open.
+func (a *Admitter) Admit(ctx context.Context, req *Request) error {
+	if a == nil {
+		return nil
+	}
+
+	for _, hook := range a.hooks {
+		decision, err := hook.Review(ctx, req)
+		if err != nil {
+			if a.failOpen {
+				a.logger.Warn("admission hook failed, admitting deployment (fail_open)", "error", err)
+				continue
+			}
+			return fmt.Errorf("%w: admission hook failed: %v", types.ErrAdmissionDenied, err)
+		}
+
+		if !decision.Allowed {
+			reason := strings.TrimSpace(decision.Reason)
+			if reason == "" {
+				reason = "rejected by admission hook"
+			}
+			if len(reason) > maxReasonLength {
+				reason = reason[:maxReasonLength]
+			}
+			return fmt.Errorf("%w: %s", types.ErrAdmissionDenied, reason)
+		}
+	}
+
+	return nil
+}
+
+// ScriptHook runs an executable with the request JSON on stdin.
+// Exit status 0 admits the deployment; any other status rejects it, with the
+// script's output as the reason. A script may also print a Decision as JSON.
+type ScriptHook struct {
+	Path    string
+	Timeout time.Duration
+}
+
+// Review implements Hook
+func (h *ScriptHook) Review(ctx context.Context, req *Request) (*Decision, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal admission request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Path)
+	cmd.Stdin = bytes.NewReader(payload)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("admission script timed out after %s", h.Timeout)
+	}
+
+	// A JSON decision on the output takes precedence over the exit status
+	var decision Decision
+	if err := json.Unmarshal(bytes.TrimSpace(output.Bytes()), &decision); err == nil {
+		return &decision, nil
+	}
+
+	if runErr != nil {
+		if _, ok := runErr.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("failed to run admission script: %w", runErr)
+		}
+		return &Decision{Allowed: false, Reason: output.String()}, nil
+	}
+
+	return &Decision{Allowed: true}, nil
+}
+
+// WebhookHook POSTs the request JSON to a URL and expects a Decision in the response body
+type WebhookHook struct {
+	URL     string
+	Timeout time.Duration
+
+	// Client is the HTTP client to use (default: http.DefaultClient)
+	Client *http.Client
+}
+
+// Review implements Hook
+func (h *WebhookHook) Review(ctx context.Context, req *Request) (*Decision, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal admission request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admission request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("admission webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admission webhook response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("admission webhook returned status %d", resp.StatusCode)
+	}
+
+	var decision Decision
+	if err := json.Unmarshal(body, &decision); err != nil {
+		return nil, fmt.Errorf("invalid admission webhook response: %w", err)
+	}
+
+	return &decision, nil
+}
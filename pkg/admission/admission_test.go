@@ -0,0 +1,123 @@
+package admission_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/admission"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func testRequest() *admission.Request {
+	return &admission.Request{
+		AppID: "demo-1.0.0",
+		Manifest: &types.Manifest{
+			Name:       "demo",
+			Version:    "1.0.0",
+			Entrypoint: "demo",
+			Labels:     map[string]string{"team": "infra"},
+		},
+	}
+}
+
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "admit.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	return path
+}
+
+func TestNewWithoutHooks(t *testing.T) {
+	a := admission.New(&config.AdmissionConfig{}, logging.Nop())
+	if a != nil {
+		t.Fatal("expected nil admitter without hooks")
+	}
+	if err := a.Admit(context.Background(), testRequest()); err != nil {
+		t.Errorf("nil admitter must admit, got: %v", err)
+	}
+}
+
+func TestScriptHook(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantDenied bool
+		wantReason string
+	}{
+		{"exit zero admits", "cat >/dev/null\nexit 0\n", false, ""},
+		{"exit non-zero rejects", "cat >/dev/null\necho 'label team is required'\nexit 1\n", true, "label team is required"},
+		{"json decision", "grep -q '\"team\":\"infra\"' && echo '{\"allowed\": false, \"reason\": \"infra apps are frozen\"}'\n", true, "infra apps are frozen"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := admission.New(&config.AdmissionConfig{Script: writeScript(t, tt.body)}, logging.Nop())
+			err := a.Admit(context.Background(), testRequest())
+
+			if denied := errors.Is(err, types.ErrAdmissionDenied); denied != tt.wantDenied {
+				t.Fatalf("denied = %v, want %v (err: %v)", denied, tt.wantDenied, err)
+			}
+			if tt.wantReason != "" && !strings.Contains(err.Error(), tt.wantReason) {
+				t.Errorf("error %q does not contain reason %q", err, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestWebhookHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req admission.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		decision := admission.Decision{Allowed: req.Manifest.Entrypoint != "forbidden"}
+		if !decision.Allowed {
+			decision.Reason = "entrypoint is forbidden"
+		}
+		_ = json.NewEncoder(w).Encode(decision)
+	}))
+	defer server.Close()
+
+	a := admission.New(&config.AdmissionConfig{Webhook: server.URL}, logging.Nop())
+
+	if err := a.Admit(context.Background(), testRequest()); err != nil {
+		t.Fatalf("expected admission, got: %v", err)
+	}
+
+	req := testRequest()
+	req.Manifest.Entrypoint = "forbidden"
+	err := a.Admit(context.Background(), req)
+	if !errors.Is(err, types.ErrAdmissionDenied) || !strings.Contains(err.Error(), "entrypoint is forbidden") {
+		t.Errorf("expected rejection with reason, got: %v", err)
+	}
+}
+
+func TestFailOpen(t *testing.T) {
+	cfg := &config.AdmissionConfig{Webhook: "http://127.0.0.1:1", Timeout: time.Second}
+
+	if err := admission.New(cfg, logging.Nop()).Admit(context.Background(), testRequest()); !errors.Is(err, types.ErrAdmissionDenied) {
+		t.Errorf("unreachable hook must reject by default, got: %v", err)
+	}
+
+	cfg.FailOpen = true
+	if err := admission.New(cfg, logging.Nop()).Admit(context.Background(), testRequest()); err != nil {
+		t.Errorf("unreachable hook must admit with fail_open, got: %v", err)
+	}
+}
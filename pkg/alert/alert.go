@@ -0,0 +1,270 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DefaultInterval is how often rules are evaluated when not configured
+const DefaultInterval = 30 * time.Second
+
+// DefaultTimeout bounds a webhook request when not configured
+const DefaultTimeout = 10 * time.Second
+
+// restartWindow is the period over which app starts are counted
+const restartWindow = time.Hour
+
+// Alert rules
+const (
+	// RuleAppDown fires when an app stays down for longer than alerts.app_down_after
+	RuleAppDown = "app_down"
+
+	// RuleRestarts fires when an app starts more than alerts.max_restarts_per_hour times an hour
+	RuleRestarts = "restarts"
+
+	// RuleDisk fires when the data directory's filesystem is fuller than alerts.max_disk_percent
+	RuleDisk = "disk"
+
+	// RuleDiscovery fires while the node lost all peers on the discovery
+	// topic, so its node list is stale. It is raised by the daemon, not Evaluate.
+	RuleDiscovery = "discovery"
+)
+
+// Notification states
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Alert is a rule that currently fires
+type Alert struct {
+	// Rule is the rule that fired
+	Rule string `json:"rule"`
+
+	// AppID is the app the alert is about, empty for node alerts
+	AppID string `json:"app_id,omitempty"`
+
+	// Message describes the condition, e.g. "started more than 10 times in the last hour"
+	Message string `json:"message"`
+
+	// Since is when the alert started firing
+	Since time.Time `json:"since"`
+}
+
+// String returns a one-line summary of the alert
+func (a *Alert) String() string {
+	if a.AppID == "" {
+		return a.Rule + ": " + a.Message
+	}
+	return a.Rule + " " + a.AppID + ": " + a.Message
+}
+
+// key identifies an alert across evaluations
+func (a *Alert) key() string {
+	return a.Rule + "/" + a.AppID
+}
+
+// Change is an alert that started or stopped firing
+type Change struct {
+	// State is StateFiring or StateResolved
+	State string `json:"state"`
+
+	// Alert is the alert as it was last evaluated
+	Alert *Alert `json:"alert"`
+}
+
+// Evaluator checks the configured rules and tracks which alerts fire
+type Evaluator struct {
+	cfg config.AlertsConfig
+
+	mu        sync.Mutex
+	starts    map[string][]time.Time
+	downSince map[string]time.Time
+	active    map[string]*Alert
+}
+
+// New creates an evaluator from configuration. It returns nil if no rule is enabled.
+func New(cfg *config.AlertsConfig) *Evaluator {
+	if cfg.AppDownAfter <= 0 && cfg.MaxRestartsPerHour <= 0 && cfg.MaxDiskPercent <= 0 {
+		return nil
+	}
+	return &Evaluator{
+		cfg:       *cfg,
+		starts:    make(map[string][]time.Time),
+		downSince: make(map[string]time.Time),
+		active:    make(map[string]*Alert),
+	}
+}
+
+// Started records that app was started at the given time
+func (e *Evaluator) Started(appID string, at time.Time) {
+	if e.cfg.MaxRestartsPerHour <= 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.starts[appID] = append(e.starts[appID], at)
+}
+
+// Evaluate checks the rules against the current apps and the usage of the
+// data directory's filesystem in percent (negative if unknown), and returns
+// the alerts that started or stopped firing since the last evaluation
+func (e *Evaluator) Evaluate(now time.Time, apps []*types.Application, diskPercent float64) []Change {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	firing := make(map[string]*Alert)
+	fire := func(a *Alert) {
+		firing[a.key()] = a
+	}
+
+	if e.cfg.AppDownAfter > 0 {
+		down := make(map[string]time.Time, len(e.downSince))
+		for _, app := range apps {
+			if app.Status != types.AppStatusFailed && app.Status != types.AppStatusRestarting {
+				continue
+			}
+			since, ok := e.downSince[app.ID]
+			if !ok {
+				since = now
+			}
+			down[app.ID] = since
+			if now.Sub(since) > e.cfg.AppDownAfter {
+				fire(&Alert{Rule: RuleAppDown, AppID: app.ID, Since: since.Add(e.cfg.AppDownAfter),
+					Message: fmt.Sprintf("down for more than %s", e.cfg.AppDownAfter)})
+			}
+		}
+		e.downSince = down
+	}
+
+	if e.cfg.MaxRestartsPerHour > 0 {
+		cutoff := now.Add(-restartWindow)
+		for appID, starts := range e.starts {
+			recent := starts[:0]
+			for _, t := range starts {
+				if t.After(cutoff) {
+					recent = append(recent, t)
+				}
+			}
+			if len(recent) == 0 {
+				delete(e.starts, appID)
+				continue
+			}
+			e.starts[appID] = recent
+			if len(recent) > e.cfg.MaxRestartsPerHour {
+				fire(&Alert{Rule: RuleRestarts, AppID: appID, Since: now,
+					Message: fmt.Sprintf("started more than %d times in the last hour", e.cfg.MaxRestartsPerHour)})
+			}
+		}
+	}
+
+	if e.cfg.MaxDiskPercent > 0 && diskPercent > e.cfg.MaxDiskPercent {
+		fire(&Alert{Rule: RuleDisk, Since: now,
+			Message: fmt.Sprintf("data directory disk more than %g%% full", e.cfg.MaxDiskPercent)})
+	}
+
+	var changes []Change
+	for key, a := range firing {
+		if prev, ok := e.active[key]; ok {
+			// Still firing since it first fired
+			a.Since = prev.Since
+			continue
+		}
+		changes = append(changes, Change{State: StateFiring, Alert: a})
+	}
+	for key, a := range e.active {
+		if _, ok := firing[key]; !ok {
+			changes = append(changes, Change{State: StateResolved, Alert: a})
+		}
+	}
+	e.active = firing
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Alert.key() < changes[j].Alert.key()
+	})
+	return changes
+}
+
+// Active returns the alerts firing as of the last evaluation, sorted by rule and app
+func (e *Evaluator) Active() []*Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]*Alert, 0, len(e.active))
+	for _, a := range e.active {
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].key() < alerts[j].key()
+	})
+	return alerts
+}
+
+// NodeInfo describes the node raising an alert
+type NodeInfo struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Notification is the JSON document POSTed to the alert webhook
+type Notification struct {
+	Change
+	Node NodeInfo  `json:"node"`
+	Time time.Time `json:"time"`
+}
+
+// Webhook POSTs notifications to a URL
+type Webhook struct {
+	URL     string
+	Timeout time.Duration
+
+	// Client is the HTTP client to use (default: http.DefaultClient)
+	Client *http.Client
+}
+
+// Notify sends n to the webhook. Any 2xx status is accepted.
+func (w *Webhook) Notify(ctx context.Context, n *Notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert notification: %w", err)
+	}
+
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create alert webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alert webhook request failed: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
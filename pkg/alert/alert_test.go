@@ -0,0 +1,119 @@
+package alert_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/alert"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestNewWithoutRules(t *testing.T) {
+	if e := alert.New(&config.AlertsConfig{Webhook: "http://example.com"}); e != nil {
+		t.Errorf("New() = %v, want nil without rules", e)
+	}
+}
+
+func TestAppDown(t *testing.T) {
+	e := alert.New(&config.AlertsConfig{AppDownAfter: time.Minute})
+	app := &types.Application{
+		AppSpec:          types.AppSpec{ID: "app-1.0.0"},
+		AppRuntimeStatus: types.AppRuntimeStatus{Status: types.AppStatusFailed},
+	}
+	apps := []*types.Application{app}
+	start := time.Now()
+
+	if changes := e.Evaluate(start, apps, -1); len(changes) != 0 {
+		t.Fatalf("changes right after failing = %v, want none", changes)
+	}
+
+	changes := e.Evaluate(start.Add(2*time.Minute), apps, -1)
+	if len(changes) != 1 || changes[0].State != alert.StateFiring || changes[0].Alert.Rule != alert.RuleAppDown {
+		t.Fatalf("changes after 2m = %+v, want app_down firing", changes)
+	}
+	if changes := e.Evaluate(start.Add(3*time.Minute), apps, -1); len(changes) != 0 {
+		t.Errorf("changes while still down = %+v, want none", changes)
+	}
+	if len(e.Active()) != 1 {
+		t.Errorf("Active() = %v, want one alert", e.Active())
+	}
+
+	app.Status = types.AppStatusRunning
+	changes = e.Evaluate(start.Add(4*time.Minute), apps, -1)
+	if len(changes) != 1 || changes[0].State != alert.StateResolved {
+		t.Fatalf("changes after recovery = %+v, want resolved", changes)
+	}
+	if len(e.Active()) != 0 {
+		t.Errorf("Active() = %v, want none", e.Active())
+	}
+}
+
+func TestRestarts(t *testing.T) {
+	e := alert.New(&config.AlertsConfig{MaxRestartsPerHour: 2})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		e.Started("app-1.0.0", start.Add(time.Duration(i)*time.Minute))
+	}
+
+	changes := e.Evaluate(start.Add(5*time.Minute), nil, -1)
+	if len(changes) != 1 || changes[0].Alert.Rule != alert.RuleRestarts || changes[0].Alert.AppID != "app-1.0.0" {
+		t.Fatalf("changes = %+v, want restarts firing", changes)
+	}
+
+	// The starts age out of the window
+	changes = e.Evaluate(start.Add(2*time.Hour), nil, -1)
+	if len(changes) != 1 || changes[0].State != alert.StateResolved {
+		t.Errorf("changes an hour later = %+v, want resolved", changes)
+	}
+}
+
+func TestDisk(t *testing.T) {
+	e := alert.New(&config.AlertsConfig{MaxDiskPercent: 90})
+	now := time.Now()
+
+	if changes := e.Evaluate(now, nil, -1); len(changes) != 0 {
+		t.Errorf("changes with unknown usage = %+v, want none", changes)
+	}
+	if changes := e.Evaluate(now, nil, 50); len(changes) != 0 {
+		t.Errorf("changes at 50%% = %+v, want none", changes)
+	}
+	changes := e.Evaluate(now, nil, 95)
+	if len(changes) != 1 || changes[0].Alert.Rule != alert.RuleDisk || changes[0].Alert.AppID != "" {
+		t.Errorf("changes at 95%% = %+v, want disk firing", changes)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got alert.Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	n := &alert.Notification{
+		Change: alert.Change{State: alert.StateFiring, Alert: &alert.Alert{Rule: alert.RuleDisk, Message: "full"}},
+		Node:   alert.NodeInfo{ID: "peer-1", Name: "node-1"},
+		Time:   time.Now(),
+	}
+	if err := (&alert.Webhook{URL: server.URL}).Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if got.State != alert.StateFiring || got.Alert == nil || got.Alert.Rule != alert.RuleDisk || got.Node.Name != "node-1" {
+		t.Errorf("webhook received %+v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := (&alert.Webhook{URL: failing.URL}).Notify(context.Background(), n); err == nil {
+		t.Error("Notify() to a failing webhook succeeded")
+	}
+}
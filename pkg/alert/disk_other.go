@@ -0,0 +1,14 @@
+//go:build !unix
+
+package alert
+
+import (
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DiskUsage is not supported on this platform
+func DiskUsage(path string) (float64, error) {
+	return 0, fmt.Errorf("%w: disk usage on this platform", types.ErrNotImplemented)
+}
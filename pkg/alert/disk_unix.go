@@ -0,0 +1,24 @@
+//go:build unix
+
+package alert
+
+import (
+	"syscall"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DiskUsage returns how full the filesystem holding path is, in percent
+func DiskUsage(path string) (float64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, types.WrapError(err, "failed to stat filesystem")
+	}
+	total := float64(st.Blocks) * float64(st.Bsize)
+	if total == 0 {
+		return 0, nil
+	}
+	// Space reserved for root counts as used, since apps cannot use it
+	free := float64(st.Bavail) * float64(st.Bsize)
+	return 100 * (total - free) / total, nil
+}
@@ -0,0 +1,427 @@
+package appapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// SocketName is the name of the API socket in the app's working directory
+const SocketName = ".p2p-app.sock"
+
+// MaxMetrics is the number of distinct metrics an app may publish
+const MaxMetrics = 100
+
+// maxSocketPath keeps socket paths within the sun_path limit of every platform
+const maxSocketPath = 100
+
+// Node identifies the daemon's node to apps
+type Node struct {
+	ID     string
+	Name   string
+	Labels map[string]string
+}
+
+// HandlerFunc serves one API call. ctx is canceled when the app hangs up or
+// its session is closed.
+type HandlerFunc func(ctx context.Context, call *Call) error
+
+// Call is an API request from an app.
+// A handler replies once for plain calls; streaming handlers reply to accept
+// the call and then once per event. Returning an error before the first reply
+// sends it to the app.
+type Call struct {
+	// App is the calling application
+	App *types.Application
+
+	// Method is the requested method
+	Method string
+
+	params  json.RawMessage
+	conn    net.Conn
+	sess    *session
+	replied bool
+}
+
+// Decode parses the call parameters into v
+func (c *Call) Decode(v interface{}) error {
+	if len(c.params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(c.params, v); err != nil {
+		return fmt.Errorf("%w: invalid %s params: %v", types.ErrInvalidInput, c.Method, err)
+	}
+	return nil
+}
+
+// Reply sends a successful response carrying result, which may be nil
+func (c *Call) Reply(result interface{}) error {
+	resp := appsdk.Response{Success: true}
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s result: %w", c.Method, err)
+		}
+		resp.Result = data
+	}
+	c.replied = true
+	return wire.WriteMessage(c.conn, &resp)
+}
+
+// Server serves the app API on one Unix socket per running app.
+// The socket an app connects through identifies it, so apps cannot act for each other.
+type Server struct {
+	node     Node
+	logger   types.Logger
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+	apps     map[string]*session
+}
+
+// session is the API state of one running app
+type session struct {
+	app      *types.Application
+	path     string
+	listener net.Listener
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	health   *appsdk.Health
+	metrics  map[string]float64
+	shutdown chan struct{}
+	notice   *appsdk.ShutdownNotice
+}
+
+// New creates a server with the built-in methods registered
+func New(node Node, logger types.Logger) *Server {
+	s := &Server{
+		node:     node,
+		logger:   logger,
+		handlers: make(map[string]HandlerFunc),
+		apps:     make(map[string]*session),
+	}
+
+	s.Handle(appsdk.MethodInfo, s.handleInfo)
+	s.Handle(appsdk.MethodSetHealth, s.handleSetHealth)
+	s.Handle(appsdk.MethodPublishMetrics, s.handlePublishMetrics)
+	s.Handle(appsdk.MethodWatchShutdown, s.handleWatchShutdown)
+
+	return s
+}
+
+// Handle registers fn for method, replacing any previous handler
+func (s *Server) Handle(method string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = fn
+}
+
+// Open starts serving app and returns the environment variables telling the
+// process where to find its socket. A previous session of the app ID, left by
+// an earlier instance of the app, is closed.
+func (s *Server) Open(ctx context.Context, app *types.Application) ([]string, error) {
+	s.mu.Lock()
+	previous := s.apps[app.ID]
+	delete(s.apps, app.ID)
+	s.mu.Unlock()
+	if previous != nil {
+		previous.close()
+	}
+
+	path := socketPath(app)
+	_ = os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to listen on app socket")
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = listener.Close()
+		return nil, types.WrapError(err, "failed to restrict app socket")
+	}
+
+	sessCtx, cancel := context.WithCancel(context.Background())
+	sess := &session{
+		app:      app,
+		path:     path,
+		listener: listener,
+		ctx:      sessCtx,
+		cancel:   cancel,
+		metrics:  make(map[string]float64),
+		shutdown: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.apps[app.ID] = sess
+	s.mu.Unlock()
+
+	crash.Loop(sess.ctx.Done(), s.logger, "appapi.serve", func() { s.serve(sess) })
+
+	return []string{
+		appsdk.EnvSocket + "=" + path,
+		appsdk.EnvAppID + "=" + app.ID,
+		appsdk.EnvAppName + "=" + app.Name,
+		appsdk.EnvAppVersion + "=" + app.Version,
+		appsdk.EnvNodeID + "=" + s.node.ID,
+		appsdk.EnvNodeName + "=" + s.node.Name,
+	}, nil
+}
+
+// Stopping notifies app's shutdown watchers that it will be stopped within grace
+func (s *Server) Stopping(app *types.Application, reason string, grace time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess := s.apps[app.ID]
+	if sess == nil || sess.notice != nil {
+		return
+	}
+	sess.notice = &appsdk.ShutdownNotice{Reason: reason, Deadline: time.Now().Add(grace)}
+	close(sess.shutdown)
+}
+
+// Close stops serving app and removes its socket. app must be the
+// application Open was called with, not a copy: a session opened since for a
+// later instance of the same app ID is left alone.
+func (s *Server) Close(app *types.Application) {
+	s.mu.Lock()
+	sess := s.apps[app.ID]
+	if sess != nil && sess.app == app {
+		delete(s.apps, app.ID)
+	} else {
+		sess = nil
+	}
+	s.mu.Unlock()
+
+	if sess != nil {
+		sess.close()
+	}
+}
+
+// close stops accepting calls and removes the socket
+func (sess *session) close() {
+	sess.cancel()
+	_ = sess.listener.Close()
+	_ = os.Remove(sess.path)
+}
+
+// Health returns the health app last reported, or nil if it reported none
+func (s *Server) Health(appID string) *appsdk.Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess := s.apps[appID]; sess != nil && sess.health != nil {
+		health := *sess.health
+		return &health
+	}
+	return nil
+}
+
+// Metrics returns the metrics app published
+func (s *Server) Metrics(appID string) map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess := s.apps[appID]
+	if sess == nil || len(sess.metrics) == 0 {
+		return nil
+	}
+	metrics := make(map[string]float64, len(sess.metrics))
+	for name, value := range sess.metrics {
+		metrics[name] = value
+	}
+	return metrics
+}
+
+// serve accepts connections until the session is closed
+func (s *Server) serve(sess *session) {
+	for {
+		conn, err := sess.listener.Accept()
+		if err != nil {
+			if sess.ctx.Err() == nil {
+				s.logger.Warn("app socket failed", "app_id", sess.app.ID, "error", err)
+			}
+			return
+		}
+		crash.Go(s.logger, "appapi.call", func() { s.serveConn(sess, conn) })
+	}
+}
+
+// serveConn handles a single call
+func (s *Server) serveConn(sess *session, conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	stop := context.AfterFunc(sess.ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	var req appsdk.Request
+	if err := wire.ReadMessage(conn, &req); err != nil {
+		s.logger.Debug("invalid app API request", "app_id", sess.app.ID, "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	handler := s.handlers[req.Method]
+	s.mu.Unlock()
+
+	// Apps send nothing after the request, so a read returns only once they hang up
+	ctx, cancel := context.WithCancel(sess.ctx)
+	defer cancel()
+	go func() {
+		_, _ = conn.Read(make([]byte, 1))
+		cancel()
+	}()
+
+	call := &Call{App: sess.app, Method: req.Method, params: req.Params, conn: conn, sess: sess}
+	var err error
+	if handler == nil {
+		err = fmt.Errorf("%w: unknown method %q", types.ErrInvalidInput, req.Method)
+	} else {
+		err = handler(ctx, call)
+	}
+
+	if err != nil && !call.replied {
+		_ = wire.WriteMessage(conn, &appsdk.Response{
+			Success: false,
+			Error:   err.Error(),
+			Code:    types.ErrorCode(err),
+		})
+	}
+}
+
+// session returns the session call came through, or nil once it was closed
+// or replaced by the session of a later instance of the app
+func (s *Server) session(call *Call) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if call.sess != nil && s.apps[call.App.ID] == call.sess {
+		return call.sess
+	}
+	return nil
+}
+
+// handleInfo returns the app's identity and metadata
+func (s *Server) handleInfo(ctx context.Context, call *Call) error {
+	app := call.App
+	return call.Reply(&appsdk.Info{
+		AppID:      app.ID,
+		Name:       app.Name,
+		Version:    app.Version,
+		Labels:     app.Labels,
+		NodeID:     s.node.ID,
+		NodeName:   s.node.Name,
+		NodeLabels: s.node.Labels,
+		StartedAt:  app.StartedAt,
+	})
+}
+
+// handleSetHealth records the health the app reports
+func (s *Server) handleSetHealth(ctx context.Context, call *Call) error {
+	var health appsdk.Health
+	if err := call.Decode(&health); err != nil {
+		return err
+	}
+	switch health.Status {
+	case appsdk.HealthHealthy, appsdk.HealthDegraded, appsdk.HealthUnhealthy:
+	default:
+		return fmt.Errorf("%w: health status must be %s, %s or %s", types.ErrInvalidInput,
+			appsdk.HealthHealthy, appsdk.HealthDegraded, appsdk.HealthUnhealthy)
+	}
+	health.UpdatedAt = time.Now()
+
+	sess := s.session(call)
+	if sess == nil {
+		return types.ErrAppNotRunning
+	}
+
+	s.mu.Lock()
+	previous := sess.health
+	sess.health = &health
+	s.mu.Unlock()
+
+	if previous == nil || previous.Status != health.Status {
+		s.logger.Info("application reported health",
+			"app_id", call.App.ID,
+			"status", health.Status,
+			"message", health.Message,
+		)
+	}
+	return call.Reply(nil)
+}
+
+// handlePublishMetrics merges the published gauge values
+func (s *Server) handlePublishMetrics(ctx context.Context, call *Call) error {
+	var params appsdk.MetricsParams
+	if err := call.Decode(&params); err != nil {
+		return err
+	}
+
+	sess := s.session(call)
+	if sess == nil {
+		return types.ErrAppNotRunning
+	}
+
+	s.mu.Lock()
+	for name, value := range params.Metrics {
+		if _, exists := sess.metrics[name]; !exists && len(sess.metrics) >= MaxMetrics {
+			s.mu.Unlock()
+			return fmt.Errorf("%w: at most %d metrics may be published", types.ErrInvalidInput, MaxMetrics)
+		}
+		sess.metrics[name] = value
+	}
+	s.mu.Unlock()
+
+	return call.Reply(nil)
+}
+
+// handleWatchShutdown accepts the call and sends the shutdown notice when the app is stopped
+func (s *Server) handleWatchShutdown(ctx context.Context, call *Call) error {
+	sess := s.session(call)
+	if sess == nil {
+		return types.ErrAppNotRunning
+	}
+
+	if err := call.Reply(nil); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-sess.shutdown:
+	}
+
+	s.mu.Lock()
+	notice := *sess.notice
+	s.mu.Unlock()
+
+	if err := call.Reply(&notice); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.logger.Debug("failed to deliver shutdown notice", "app_id", call.App.ID, "error", err)
+	}
+	return nil
+}
+
+// socketPath returns where app's socket lives: its working directory, or the
+// temporary directory if that path would be too long for a Unix socket
+func socketPath(app *types.Application) string {
+	path := filepath.Join(app.WorkDir, SocketName)
+	if len(path) <= maxSocketPath {
+		return path
+	}
+
+	sum := sha256.Sum256([]byte(app.WorkDir))
+	return filepath.Join(os.TempDir(), "p2p-app-"+hex.EncodeToString(sum[:8])+".sock")
+}
@@ -0,0 +1,135 @@
+package appapi_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appapi"
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// openApp starts serving a test app and returns a client for it
+func openApp(t *testing.T, s *appapi.Server) (*types.Application, *appsdk.Client) {
+	t.Helper()
+
+	app := types.NewApplication(types.AppSpec{
+		ID:      "hello-1.0.0",
+		Name:    "hello",
+		Version: "1.0.0",
+		Labels:  map[string]string{"team": "demo"},
+		WorkDir: t.TempDir(),
+	})
+
+	env, err := s.Open(context.Background(), app)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close(app) })
+
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, appsdk.EnvSocket+"="); ok {
+			return app, appsdk.NewClient(value)
+		}
+	}
+	t.Fatalf("Open() env %v does not name the socket", env)
+	return nil, nil
+}
+
+func newServer() *appapi.Server {
+	return appapi.New(appapi.Node{ID: "peer-1", Name: "node-1"}, logging.Nop())
+}
+
+func TestInfo(t *testing.T) {
+	s := newServer()
+	_, client := openApp(t, s)
+
+	info, err := client.Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info() error = %v", err)
+	}
+	if info.AppID != "hello-1.0.0" || info.NodeID != "peer-1" || info.NodeName != "node-1" || info.Labels["team"] != "demo" {
+		t.Errorf("Info() = %+v", info)
+	}
+}
+
+func TestHealthAndMetrics(t *testing.T) {
+	s := newServer()
+	app, client := openApp(t, s)
+	ctx := context.Background()
+
+	if err := client.SetHealth(ctx, "sleepy", ""); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("SetHealth(invalid) error = %v, want ErrInvalidInput", err)
+	}
+	if err := client.SetHealth(ctx, appsdk.HealthDegraded, "backlog"); err != nil {
+		t.Fatalf("SetHealth() error = %v", err)
+	}
+	health := s.Health(app.ID)
+	if health == nil || health.Status != appsdk.HealthDegraded || health.Message != "backlog" {
+		t.Errorf("Health() = %+v", health)
+	}
+
+	if err := client.PublishMetrics(ctx, map[string]float64{"a": 1, "b": 2}); err != nil {
+		t.Fatalf("PublishMetrics() error = %v", err)
+	}
+	if err := client.PublishMetrics(ctx, map[string]float64{"a": 3}); err != nil {
+		t.Fatalf("PublishMetrics() error = %v", err)
+	}
+	metrics := s.Metrics(app.ID)
+	if metrics["a"] != 3 || metrics["b"] != 2 {
+		t.Errorf("Metrics() = %v, want a=3 b=2", metrics)
+	}
+}
+
+func TestWatchShutdown(t *testing.T) {
+	s := newServer()
+	app, client := openApp(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	notices, err := client.WatchShutdown(ctx)
+	if err != nil {
+		t.Fatalf("WatchShutdown() error = %v", err)
+	}
+
+	s.Stopping(app, "stop requested", 10*time.Second)
+
+	select {
+	case notice, ok := <-notices:
+		if !ok || notice.Reason != "stop requested" || time.Until(notice.Deadline) <= 0 {
+			t.Errorf("notice = %+v (ok=%v)", notice, ok)
+		}
+	case <-ctx.Done():
+		t.Fatal("no shutdown notice received")
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	s := newServer()
+	_, client := openApp(t, s)
+
+	err := client.Call(context.Background(), "nope", nil, nil)
+	if !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("Call(nope) error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestCloseRemovesSocket(t *testing.T) {
+	s := newServer()
+	app, client := openApp(t, s)
+
+	s.Close(app)
+
+	if _, err := os.Stat(app.WorkDir + "/" + appapi.SocketName); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after Close: %v", err)
+	}
+	if _, err := client.Info(context.Background()); err == nil {
+		t.Error("Info() after Close succeeded")
+	}
+}
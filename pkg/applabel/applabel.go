@@ -0,0 +1,183 @@
+// Package applabel persists the labels operators set on and remove from
+// deployed applications, so that they outlive redeploying the same version
+// and carry over the labels of its manifest. Overrides are keyed by
+// <name>-<version>, whatever the ID the version is deployed as.
+package applabel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// StorageDir is the storage directory holding the label overrides of each application instance
+const StorageDir = "labels"
+
+// schema versions the stored overrides
+var schema = storage.Schema{
+	Kind: "label overrides",
+	Migrations: []storage.Migration{
+		nil, // 1: the schema version was added
+	},
+}
+
+// Overrides are the label changes made to an application instance after it
+// was deployed
+type Overrides struct {
+	// Set are the labels set, overriding those of the manifest
+	Set map[string]string `json:"set,omitempty"`
+
+	// Removed are the keys of the manifest labels removed
+	Removed []string `json:"removed,omitempty"`
+
+	// UpdatedAt is when they last changed
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Apply returns labels, e.g. those of the manifest, with the overrides
+// applied. labels is not modified.
+func (o *Overrides) Apply(labels map[string]string) map[string]string {
+	if o == nil || (len(o.Set) == 0 && len(o.Removed) == 0) {
+		return labels
+	}
+	applied := make(map[string]string, len(labels)+len(o.Set))
+	for key, value := range labels {
+		applied[key] = value
+	}
+	for _, key := range o.Removed {
+		delete(applied, key)
+	}
+	for key, value := range o.Set {
+		applied[key] = value
+	}
+	return applied
+}
+
+// Validate checks that labels set can be told apart in a selector
+// ("key=value,key=value") and that the keys removed are not empty
+func Validate(set map[string]string, remove []string) error {
+	for key, value := range set {
+		if err := validateKey(key); err != nil {
+			return err
+		}
+		if strings.Contains(value, ",") {
+			return fmt.Errorf("%w: label value %q contains a comma", types.ErrInvalidInput, value)
+		}
+	}
+	for _, key := range remove {
+		if err := validateKey(key); err != nil {
+			return err
+		}
+		if _, ok := set[key]; ok {
+			return fmt.Errorf("%w: label %q is both set and removed", types.ErrInvalidInput, key)
+		}
+	}
+	return nil
+}
+
+// validateKey checks a label key
+func validateKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "=,") || strings.TrimSpace(key) != key {
+		return fmt.Errorf("%w: invalid label key %q", types.ErrInvalidInput, key)
+	}
+	return nil
+}
+
+// Store persists the label overrides of each application instance
+type Store struct {
+	storage types.Storage
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+// NewStore creates a label store on top of storage
+func NewStore(storage types.Storage) *Store {
+	return &Store{storage: storage, now: time.Now}
+}
+
+// key returns the storage key of the overrides of the app instance appID
+func key(appID string) string {
+	return StorageDir + "/" + appID + ".json"
+}
+
+// Get returns the overrides of the app instance appID, nil if there are none
+func (s *Store) Get(ctx context.Context, appID string) (*Overrides, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(ctx, appID)
+}
+
+// Update sets and removes labels of the app instance appID, on top of its
+// earlier overrides, and returns the new overrides. Validate checks set and
+// remove first.
+func (s *Store) Update(ctx context.Context, appID string, set map[string]string, remove []string) (*Overrides, error) {
+	if err := Validate(set, remove); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, err := s.load(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		o = &Overrides{}
+	}
+
+	removed := make(map[string]bool, len(o.Removed)+len(remove))
+	for _, key := range o.Removed {
+		removed[key] = true
+	}
+	for _, key := range remove {
+		delete(o.Set, key)
+		removed[key] = true
+	}
+	for key, value := range set {
+		if o.Set == nil {
+			o.Set = make(map[string]string)
+		}
+		o.Set[key] = value
+		delete(removed, key)
+	}
+	o.Removed = o.Removed[:0]
+	for key := range removed {
+		o.Removed = append(o.Removed, key)
+	}
+	sort.Strings(o.Removed)
+	o.UpdatedAt = s.now()
+
+	data, err := schema.Marshal(o)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to marshal labels")
+	}
+	if err := s.storage.Save(ctx, key(appID), data); err != nil {
+		return nil, types.WrapError(err, "failed to save labels")
+	}
+	return o, nil
+}
+
+// load reads the overrides of appID, nil if none were recorded. s.mu must be held.
+func (s *Store) load(ctx context.Context, appID string) (*Overrides, error) {
+	data, err := s.storage.Load(ctx, key(appID))
+	if errors.Is(err, types.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, types.WrapError(err, "failed to load labels")
+	}
+
+	var o Overrides
+	if err := schema.Unmarshal(data, &o); err != nil {
+		return nil, types.WrapError(err, "failed to parse labels")
+	}
+	return &o, nil
+}
@@ -0,0 +1,82 @@
+package applabel_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/applabel"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func newStore(t *testing.T) *applabel.Store {
+	t.Helper()
+	st, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	return applabel.NewStore(st)
+}
+
+func TestUpdateAndApply(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	manifest := map[string]string{"env": "prod", "tier": "web"}
+
+	if o, err := store.Get(ctx, "web-1.0.0"); o != nil || err != nil {
+		t.Fatalf("Get() before any update = %+v, %v; want none", o, err)
+	}
+	if got := (*applabel.Overrides)(nil).Apply(manifest); !reflect.DeepEqual(got, manifest) {
+		t.Errorf("Apply() of no overrides = %v, want the labels unchanged", got)
+	}
+
+	if _, err := store.Update(ctx, "web-1.0.0", map[string]string{"env": "canary", "owner": "ops"}, []string{"tier"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	// Setting a removed label again and removing a set one
+	o, err := store.Update(ctx, "web-1.0.0", map[string]string{"tier": "api"}, []string{"owner"})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	loaded, err := store.Get(ctx, "web-1.0.0")
+	if err != nil || !reflect.DeepEqual(loaded.Set, o.Set) {
+		t.Fatalf("Get() = %+v, %v; want %+v", loaded, err, o)
+	}
+	want := map[string]string{"env": "canary", "tier": "api"}
+	if got := loaded.Apply(manifest); !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() = %v, want %v", got, want)
+	}
+	if manifest["env"] != "prod" {
+		t.Errorf("Apply() modified the labels it was given")
+	}
+	if got := loaded.Apply(map[string]string{"owner": "dev"}); got["owner"] != "" {
+		t.Errorf("Apply() kept the removed label owner: %v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		set    map[string]string
+		remove []string
+	}{
+		{"empty key", map[string]string{"": "x"}, nil},
+		{"key with equals", map[string]string{"a=b": "x"}, nil},
+		{"value with comma", map[string]string{"env": "a,b"}, nil},
+		{"removed key with comma", nil, []string{"a,b"}},
+		{"set and removed", map[string]string{"env": "prod"}, []string{"env"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := applabel.Validate(tt.set, tt.remove); !errors.Is(err, types.ErrInvalidInput) {
+				t.Errorf("Validate() error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+	if err := applabel.Validate(map[string]string{"env": "prod", "empty": ""}, []string{"tier"}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Package applock provides per-app advisory locks that serialize operations
+// changing an application on a daemon, such as deploy, remove or rollback.
+package applock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Lock describes the operation holding an app
+type Lock struct {
+	App       string    `json:"app"`
+	Operation string    `json:"operation"`
+	RequestID string    `json:"request_id,omitempty"`
+	Holder    string    `json:"holder,omitempty"` // Peer ID of the requester
+	Since     time.Time `json:"since"`
+
+	token uint64
+}
+
+// Manager holds the locks of a daemon. Locks live in memory only: a
+// restarted daemon runs no operations, so none of them can still be held.
+type Manager struct {
+	mu    sync.Mutex
+	locks map[string]*Lock
+	next  uint64
+}
+
+// NewManager creates a lock manager
+func NewManager() *Manager {
+	return &Manager{locks: make(map[string]*Lock)}
+}
+
+// Acquire locks app for an operation. It fails with ErrAppLocked, naming the
+// holder, if another operation holds app. The returned release function
+// unlocks app; it does nothing once the lock has been broken with ForceUnlock.
+func (m *Manager) Acquire(app string, lock Lock) (release func(), err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if held, ok := m.locks[app]; ok {
+		return nil, fmt.Errorf("%w: %s", types.ErrAppLocked, held)
+	}
+
+	m.next++
+	lock.App = app
+	lock.Since = time.Now()
+	lock.token = m.next
+	m.locks[app] = &lock
+
+	token := lock.token
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if held, ok := m.locks[app]; ok && held.token == token {
+			delete(m.locks, app)
+		}
+	}, nil
+}
+
+// ForceUnlock breaks the lock on app and returns it, or nil if app was not locked.
+// The operation that held it is not interrupted.
+func (m *Manager) ForceUnlock(app string) *Lock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	held, ok := m.locks[app]
+	if !ok {
+		return nil
+	}
+	delete(m.locks, app)
+	result := *held
+	return &result
+}
+
+// Get returns the lock on app, or nil if app is not locked
+func (m *Manager) Get(app string) *Lock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	held, ok := m.locks[app]
+	if !ok {
+		return nil
+	}
+	result := *held
+	return &result
+}
+
+// String describes who holds the lock, e.g. "app web is locked by deploy (request r1 from 12D3...) since 2024-01-02T15:04:05Z"
+func (l *Lock) String() string {
+	s := fmt.Sprintf("app %s is locked by %s", l.App, l.Operation)
+	switch {
+	case l.RequestID != "" && l.Holder != "":
+		s += fmt.Sprintf(" (request %s from %s)", l.RequestID, l.Holder)
+	case l.RequestID != "":
+		s += fmt.Sprintf(" (request %s)", l.RequestID)
+	case l.Holder != "":
+		s += fmt.Sprintf(" (from %s)", l.Holder)
+	}
+	return s + " since " + l.Since.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,81 @@
+package applock_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/applock"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestAcquireConflict(t *testing.T) {
+	m := applock.NewManager()
+
+	release, err := m.Acquire("web", applock.Lock{Operation: "deploy", RequestID: "r1"})
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	_, err = m.Acquire("web", applock.Lock{Operation: "deploy", RequestID: "r2"})
+	if !errors.Is(err, types.ErrAppLocked) {
+		t.Fatalf("second Acquire() error = %v, want ErrAppLocked", err)
+	}
+	if !strings.Contains(err.Error(), "locked by deploy (request r1)") {
+		t.Errorf("error %q does not name the holder", err)
+	}
+
+	// Other apps are independent
+	releaseOther, err := m.Acquire("api", applock.Lock{Operation: "deploy"})
+	if err != nil {
+		t.Fatalf("Acquire() of another app error = %v", err)
+	}
+	releaseOther()
+
+	release()
+	if _, err := m.Acquire("web", applock.Lock{Operation: "deploy"}); err != nil {
+		t.Errorf("Acquire() after release error = %v", err)
+	}
+}
+
+func TestReleaseAfterForceUnlock(t *testing.T) {
+	m := applock.NewManager()
+
+	stale, err := m.Acquire("web", applock.Lock{Operation: "deploy", RequestID: "stuck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ForceUnlock("web")
+	release, err := m.Acquire("web", applock.Lock{Operation: "deploy", RequestID: "new"})
+	if err != nil {
+		t.Fatalf("Acquire() after ForceUnlock() error = %v", err)
+	}
+
+	// The broken lock's release must not unlock the new holder
+	stale()
+	if l := m.Get("web"); l == nil || l.RequestID != "new" {
+		t.Errorf("Get() = %+v, want the forced lock", l)
+	}
+	release()
+	if l := m.Get("web"); l != nil {
+		t.Errorf("Get() = %+v after release, want nil", l)
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	m := applock.NewManager()
+	if l := m.ForceUnlock("web"); l != nil {
+		t.Errorf("ForceUnlock() of unlocked app = %+v, want nil", l)
+	}
+
+	if _, err := m.Acquire("web", applock.Lock{Operation: "deploy", Holder: "peer"}); err != nil {
+		t.Fatal(err)
+	}
+	l := m.ForceUnlock("web")
+	if l == nil || l.Operation != "deploy" || l.Holder != "peer" {
+		t.Errorf("ForceUnlock() = %+v, want the deploy lock", l)
+	}
+	if _, err := m.Acquire("web", applock.Lock{Operation: "deploy"}); err != nil {
+		t.Errorf("Acquire() after ForceUnlock() error = %v", err)
+	}
+}
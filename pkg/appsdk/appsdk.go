@@ -0,0 +1,184 @@
+// Package appsdk lets applications deployed by a p2p-playground daemon talk to it.
+//
+// The daemon serves a Unix socket for each app and passes its path in the
+// P2P_APP_SOCKET environment variable:
+//
+//	client, err := appsdk.New()
+//	if err != nil {
+//		// not running under a daemon
+//	}
+//	info, err := client.Info(ctx)
+//	_ = client.SetHealth(ctx, appsdk.HealthDegraded, "queue backlog 10k")
+//	_ = client.PublishMetrics(ctx, map[string]float64{"queue_length": 10000})
+//
+//	notices, err := client.WatchShutdown(ctx)
+//	notice := <-notices // drain work before notice.Deadline
+//
+//	messages, err := client.Receive(ctx)
+//	_ = client.Send(ctx, peerID, "other-app", []byte("hello"))
+package appsdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// ErrNotManaged indicates the process was not started by a p2p-playground daemon
+var ErrNotManaged = errors.New("not running under a p2p-playground daemon")
+
+// Client calls the daemon API of the current app
+type Client struct {
+	socket string
+}
+
+// New creates a client for the socket named in the environment
+func New() (*Client, error) {
+	socket := os.Getenv(EnvSocket)
+	if socket == "" {
+		return nil, fmt.Errorf("%w: %s is not set", ErrNotManaged, EnvSocket)
+	}
+	return NewClient(socket), nil
+}
+
+// NewClient creates a client for the API socket at path
+func NewClient(path string) *Client {
+	return &Client{socket: path}
+}
+
+// Info returns the app's identity and metadata
+func (c *Client) Info(ctx context.Context) (*Info, error) {
+	var info Info
+	if err := c.Call(ctx, MethodInfo, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// SetHealth reports the app's own view of its health.
+// status is HealthHealthy, HealthDegraded or HealthUnhealthy.
+func (c *Client) SetHealth(ctx context.Context, status, message string) error {
+	return c.Call(ctx, MethodSetHealth, &Health{Status: status, Message: message}, nil)
+}
+
+// PublishMetrics sets gauge values. Metrics not included keep their previous value.
+func (c *Client) PublishMetrics(ctx context.Context, metrics map[string]float64) error {
+	return c.Call(ctx, MethodPublishMetrics, &MetricsParams{Metrics: metrics}, nil)
+}
+
+// WatchShutdown returns a channel receiving a notice shortly before the daemon
+// stops the app. The channel is closed after the notice, when ctx is done, or
+// when the daemon goes away.
+func (c *Client) WatchShutdown(ctx context.Context) (<-chan ShutdownNotice, error) {
+	stream, err := c.Stream(ctx, MethodWatchShutdown, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	notices := make(chan ShutdownNotice, 1)
+	go func() {
+		defer close(notices)
+		defer func() { _ = stream.Close() }()
+
+		stop := context.AfterFunc(ctx, func() { _ = stream.Close() })
+		defer stop()
+
+		var notice ShutdownNotice
+		if err := stream.Next(&notice); err == nil {
+			notices <- notice
+		}
+	}()
+
+	return notices, nil
+}
+
+// Call invokes method with params and decodes the result into result, if not nil
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	stream, err := c.Stream(ctx, method, params)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stream.Close() }()
+
+	if result == nil || len(stream.first.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(stream.first.Result, result); err != nil {
+		return fmt.Errorf("failed to parse %s result: %w", method, err)
+	}
+	return nil
+}
+
+// Stream invokes a streaming method. The returned stream has consumed the
+// response accepting the call; Next reads the events that follow.
+func (c *Client) Stream(ctx context.Context, method string, params interface{}) (*Stream, error) {
+	req := Request{Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s params: %w", method, err)
+		}
+		req.Params = data
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", c.socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+
+	// Bound the request itself by ctx; streams clear the deadline afterwards
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	s := &Stream{conn: conn}
+	if err := wire.WriteMessage(conn, &req); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := wire.ReadMessage(conn, &s.first); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !s.first.Success {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%s failed: %w", method, types.ErrorFromCode(s.first.Code, s.first.Error))
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return s, nil
+}
+
+// Stream is an open call that delivers events
+type Stream struct {
+	conn  net.Conn
+	first Response
+}
+
+// Next decodes the next event into v
+func (s *Stream) Next(v interface{}) error {
+	var resp Response
+	if err := wire.ReadMessage(s.conn, &resp); err != nil {
+		return err
+	}
+	if !resp.Success {
+		return types.ErrorFromCode(resp.Code, resp.Error)
+	}
+	if err := json.Unmarshal(resp.Result, v); err != nil {
+		return fmt.Errorf("failed to parse event: %w", err)
+	}
+	return nil
+}
+
+// Close ends the call
+func (s *Stream) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,45 @@
+package appsdk
+
+import (
+	"context"
+)
+
+// KV is the app's key-value store, namespaced by app name and kept by the daemon.
+// Daemons may replicate it across the cluster, in which case concurrent writes
+// to the same key are resolved last-writer-wins.
+type KV struct {
+	client *Client
+}
+
+// KV returns the app's key-value store
+func (c *Client) KV() *KV {
+	return &KV{client: c}
+}
+
+// Get returns the value of key. A missing key returns an error wrapping types.ErrNotFound.
+func (kv *KV) Get(ctx context.Context, key string) ([]byte, error) {
+	var result KVValue
+	if err := kv.client.Call(ctx, MethodKVGet, &KVParams{Key: key}, &result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// Put sets key to value
+func (kv *KV) Put(ctx context.Context, key string, value []byte) error {
+	return kv.client.Call(ctx, MethodKVPut, &KVParams{Key: key, Value: value}, nil)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (kv *KV) Delete(ctx context.Context, key string) error {
+	return kv.client.Call(ctx, MethodKVDelete, &KVParams{Key: key}, nil)
+}
+
+// Keys returns the keys starting with prefix, sorted
+func (kv *KV) Keys(ctx context.Context, prefix string) ([]string, error) {
+	var result KVKeys
+	if err := kv.client.Call(ctx, MethodKVKeys, &KVParams{Prefix: prefix}, &result); err != nil {
+		return nil, err
+	}
+	return result.Keys, nil
+}
@@ -0,0 +1,46 @@
+package appsdk
+
+import (
+	"context"
+)
+
+// Send delivers data to the app named app on node, identified by peer ID.
+// An empty node sends to an app on the local node. Send returns once the
+// receiving daemon has handed the message to the app, and fails with an error
+// wrapping types.ErrNotFound if no such app is receiving.
+func (c *Client) Send(ctx context.Context, node, app string, data []byte) error {
+	return c.Call(ctx, MethodSend, &SendParams{Node: node, App: app, Data: data}, nil)
+}
+
+// Receive returns a channel of messages sent to this app by name. The channel
+// is closed when ctx is done or the daemon goes away. Messages arriving while
+// no receiver is open are rejected, so open it before announcing the app.
+func (c *Client) Receive(ctx context.Context) (<-chan *Message, error) {
+	stream, err := c.Stream(ctx, MethodReceive, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(chan *Message)
+	go func() {
+		defer close(messages)
+		defer func() { _ = stream.Close() }()
+
+		stop := context.AfterFunc(ctx, func() { _ = stream.Close() })
+		defer stop()
+
+		for {
+			var msg Message
+			if err := stream.Next(&msg); err != nil {
+				return
+			}
+			select {
+			case messages <- &msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return messages, nil
+}
@@ -0,0 +1,168 @@
+package appsdk
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Environment variables the daemon sets for every app process
+const (
+	// EnvSocket is the path of the app's API socket
+	EnvSocket = "P2P_APP_SOCKET"
+
+	// EnvAppID is the application ID, e.g. "hello-world-1.0.0"
+	EnvAppID = "P2P_APP_ID"
+
+	// EnvAppName is the application name from the manifest
+	EnvAppName = "P2P_APP_NAME"
+
+	// EnvAppVersion is the application version from the manifest
+	EnvAppVersion = "P2P_APP_VERSION"
+
+	// EnvNodeID is the peer ID of the node running the app
+	EnvNodeID = "P2P_NODE_ID"
+
+	// EnvNodeName is the configured name of the node running the app
+	EnvNodeName = "P2P_NODE_NAME"
+)
+
+// API methods
+const (
+	MethodInfo           = "info"
+	MethodSetHealth      = "health.set"
+	MethodPublishMetrics = "metrics.publish"
+	MethodWatchShutdown  = "shutdown.watch"
+	MethodKVGet          = "kv.get"
+	MethodKVPut          = "kv.put"
+	MethodKVDelete       = "kv.delete"
+	MethodKVKeys         = "kv.keys"
+	MethodSend           = "msg.send"
+	MethodReceive        = "msg.receive"
+	MethodPublish        = "topic.publish"
+	MethodSubscribe      = "topic.subscribe"
+)
+
+// Health states an app can report
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
+// Request is a call from an app to its daemon
+type Request struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is the daemon's answer to a request. Streaming methods send one
+// response to accept the call followed by further responses carrying events.
+type Response struct {
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Code    string          `json:"code,omitempty"`
+}
+
+// Info describes the app and the node it runs on
+type Info struct {
+	AppID      string            `json:"app_id"`
+	Name       string            `json:"name"`
+	Version    string            `json:"version"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	NodeID     string            `json:"node_id"`
+	NodeName   string            `json:"node_name,omitempty"`
+	NodeLabels map[string]string `json:"node_labels,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+}
+
+// Health is the health an app reports for itself
+type Health struct {
+	// Status is HealthHealthy, HealthDegraded or HealthUnhealthy
+	Status string `json:"status"`
+
+	// Message explains the status, e.g. "queue backlog 10k"
+	Message string `json:"message,omitempty"`
+
+	// UpdatedAt is when the daemon received the report
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MetricsParams are gauge values published by an app
+type MetricsParams struct {
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+// ShutdownNotice tells an app that the daemon is about to stop it
+type ShutdownNotice struct {
+	// Reason is why the app is stopped
+	Reason string `json:"reason"`
+
+	// Deadline is when the process is killed if it has not exited
+	Deadline time.Time `json:"deadline"`
+}
+
+// KVParams are the parameters of the key-value methods
+type KVParams struct {
+	Key    string `json:"key,omitempty"`
+	Value  []byte `json:"value,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// KVValue is the result of MethodKVGet
+type KVValue struct {
+	Value []byte `json:"value"`
+}
+
+// KVKeys is the result of MethodKVKeys
+type KVKeys struct {
+	Keys []string `json:"keys"`
+}
+
+// SendParams address a message to an app on a node
+type SendParams struct {
+	// Node is the peer ID of the receiving node; empty sends to the local node
+	Node string `json:"node,omitempty"`
+
+	// App is the name of the receiving app
+	App string `json:"app"`
+
+	// Data is the message payload
+	Data []byte `json:"data"`
+}
+
+// Message is a message received from another app
+type Message struct {
+	// FromNode is the peer ID of the sending node
+	FromNode string `json:"from_node"`
+
+	// FromApp is the name of the sending app
+	FromApp string `json:"from_app"`
+
+	// Data is the message payload
+	Data []byte `json:"data"`
+}
+
+// TopicParams are the parameters of the topic methods
+type TopicParams struct {
+	// Topic is the topic name, e.g. "sensors/temperature"
+	Topic string `json:"topic"`
+
+	// Data is the payload to publish
+	Data []byte `json:"data,omitempty"`
+}
+
+// TopicMessage is a message received on a subscribed topic
+type TopicMessage struct {
+	// Topic is the topic the message was published to
+	Topic string `json:"topic"`
+
+	// FromNode is the peer ID of the publishing node
+	FromNode string `json:"from_node"`
+
+	// FromApp is the name of the publishing app
+	FromApp string `json:"from_app"`
+
+	// Data is the message payload
+	Data []byte `json:"data"`
+}
@@ -0,0 +1,45 @@
+package appsdk
+
+import (
+	"context"
+)
+
+// Publish sends data to every subscriber of topic across the cluster. The
+// app's manifest must allow publishing to the topic (topics.publish).
+func (c *Client) Publish(ctx context.Context, topic string, data []byte) error {
+	return c.Call(ctx, MethodPublish, &TopicParams{Topic: topic, Data: data}, nil)
+}
+
+// Subscribe returns a channel of messages published to topic, including the
+// app's own. The app's manifest must allow subscribing to the topic
+// (topics.subscribe). The channel is closed when ctx is done or the daemon
+// goes away.
+func (c *Client) Subscribe(ctx context.Context, topic string) (<-chan *TopicMessage, error) {
+	stream, err := c.Stream(ctx, MethodSubscribe, &TopicParams{Topic: topic})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(chan *TopicMessage)
+	go func() {
+		defer close(messages)
+		defer func() { _ = stream.Close() }()
+
+		stop := context.AfterFunc(ctx, func() { _ = stream.Close() })
+		defer stop()
+
+		for {
+			var msg TopicMessage
+			if err := stream.Next(&msg); err != nil {
+				return
+			}
+			select {
+			case messages <- &msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return messages, nil
+}
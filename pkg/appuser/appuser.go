@@ -0,0 +1,219 @@
+package appuser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"sync"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// User modes
+const (
+	ModeDaemon = ""
+	ModeShared = "shared"
+	ModePerApp = "per_app"
+)
+
+// Defaults for unset configuration
+const (
+	DefaultUser     = "p2p-apps"
+	DefaultUIDBase  = 200000
+	DefaultUIDCount = 10000
+)
+
+// StorageKey is the storage key holding per-app ID assignments
+const StorageKey = "app-users.json"
+
+// assignments is the stored document of per-app ID assignments
+type assignments struct {
+	Apps map[string]int `json:"apps"`
+}
+
+// schema versions the stored assignments
+var schema = storage.Schema{
+	Kind: "app users",
+	Migrations: []storage.Migration{
+		storage.NestUnder("apps"), // 1: the assignments moved under apps, next to the schema version
+	},
+}
+
+// Credential is the user and group an app process runs as
+type Credential struct {
+	UID uint32
+	GID uint32
+}
+
+// Allocator assigns users to applications
+type Allocator struct {
+	cfg     config.AppUsersConfig
+	storage types.Storage
+	mu      sync.Mutex
+	shared  *Credential
+}
+
+// New creates an allocator, or returns nil if apps run as the daemon user
+func New(cfg *config.AppUsersConfig, storage types.Storage) (*Allocator, error) {
+	a := &Allocator{cfg: *cfg, storage: storage}
+
+	switch a.cfg.Mode {
+	case ModeDaemon:
+		return nil, nil
+	case ModeShared:
+		if a.cfg.User == "" {
+			a.cfg.User = DefaultUser
+		}
+	case ModePerApp:
+		if a.cfg.UIDBase == 0 {
+			a.cfg.UIDBase = DefaultUIDBase
+		}
+		if a.cfg.UIDCount == 0 {
+			a.cfg.UIDCount = DefaultUIDCount
+		}
+		if a.cfg.UIDBase < 1000 || a.cfg.UIDCount < 1 {
+			return nil, fmt.Errorf("%w: app_users uid_base must be at least 1000 and uid_count positive", types.ErrInvalidInput)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown app_users mode %q (expected shared or per_app)", types.ErrInvalidInput, a.cfg.Mode)
+	}
+
+	return a, nil
+}
+
+// RequirePrivileges returns an error unless the process can switch users
+func RequirePrivileges() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("%w: running apps as other users requires the daemon to run as root", types.ErrUnauthorized)
+	}
+	return nil
+}
+
+// Credential returns the user and group app runs as
+func (a *Allocator) Credential(ctx context.Context, app *types.Application) (*Credential, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cfg.Mode == ModeShared {
+		return a.sharedCredential()
+	}
+	return a.perAppCredential(ctx, app.Name)
+}
+
+// sharedCredential looks up, or creates, the shared user. Callers must hold a.mu.
+func (a *Allocator) sharedCredential() (*Credential, error) {
+	if a.shared != nil {
+		return a.shared, nil
+	}
+
+	u, err := user.Lookup(a.cfg.User)
+	var unknown user.UnknownUserError
+	if errors.As(err, &unknown) {
+		if err := createSystemUser(a.cfg.User); err != nil {
+			return nil, err
+		}
+		u, err = user.Lookup(a.cfg.User)
+	}
+	if err != nil {
+		return nil, types.WrapError(err, "failed to look up app user")
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("app user %s has non-numeric uid %q", a.cfg.User, u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("app user %s has non-numeric gid %q", a.cfg.User, u.Gid)
+	}
+
+	a.shared = &Credential{UID: uint32(uid), GID: uint32(gid)}
+	return a.shared, nil
+}
+
+// perAppCredential returns the ID assigned to name, assigning the next free one
+// on first use. Assignments are persisted so an app keeps its ID across
+// restarts and owns its files. Callers must hold a.mu.
+func (a *Allocator) perAppCredential(ctx context.Context, name string) (*Credential, error) {
+	assigned, err := a.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := assigned[name]
+	if !ok {
+		used := make(map[int]bool, len(assigned))
+		for _, id := range assigned {
+			used[id] = true
+		}
+		for candidate := a.cfg.UIDBase; candidate < a.cfg.UIDBase+a.cfg.UIDCount; candidate++ {
+			if !used[candidate] {
+				id = candidate
+				break
+			}
+		}
+		if id == 0 {
+			return nil, fmt.Errorf("%w: all %d app user IDs are in use", types.ErrUnavailable, a.cfg.UIDCount)
+		}
+
+		assigned[name] = id
+		if err := a.save(ctx, assigned); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Credential{UID: uint32(id), GID: uint32(id)}, nil
+}
+
+// load reads the per-app ID assignments
+func (a *Allocator) load(ctx context.Context) (map[string]int, error) {
+	data, err := a.storage.Load(ctx, StorageKey)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return make(map[string]int), nil
+		}
+		return nil, types.WrapError(err, "failed to load app users")
+	}
+
+	var doc assignments
+	if err := schema.Unmarshal(data, &doc); err != nil {
+		return nil, types.WrapError(err, "failed to parse app users")
+	}
+	if doc.Apps == nil {
+		doc.Apps = make(map[string]int)
+	}
+	return doc.Apps, nil
+}
+
+// save writes the per-app ID assignments
+func (a *Allocator) save(ctx context.Context, assigned map[string]int) error {
+	data, err := schema.MarshalIndent(&assignments{Apps: assigned}, "", "  ")
+	if err != nil {
+		return types.WrapError(err, "failed to marshal app users")
+	}
+	if err := a.storage.Save(ctx, StorageKey, data); err != nil {
+		return types.WrapError(err, "failed to save app users")
+	}
+	return nil
+}
+
+// createSystemUser creates a login-less system account
+func createSystemUser(name string) error {
+	out, err := exec.Command("useradd",
+		"--system",
+		"--user-group",
+		"--no-create-home",
+		"--shell", "/usr/sbin/nologin",
+		name,
+	).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to create app user %s: %w: %s", name, err, out)
+	}
+	return nil
+}
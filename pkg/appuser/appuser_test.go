@@ -0,0 +1,76 @@
+package appuser_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appuser"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestNewModes(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantNil bool
+		wantErr bool
+	}{
+		{mode: appuser.ModeDaemon, wantNil: true},
+		{mode: appuser.ModeShared},
+		{mode: appuser.ModePerApp},
+		{mode: "root", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			a, err := appuser.New(&config.AppUsersConfig{Mode: tt.mode}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (a == nil) != tt.wantNil {
+				t.Errorf("New() = %v, wantNil %v", a, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestPerAppCredentials(t *testing.T) {
+	ctx := context.Background()
+	st, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.AppUsersConfig{Mode: appuser.ModePerApp, UIDBase: 30000, UIDCount: 2}
+	a, err := appuser.New(cfg, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web, err := a.Credential(ctx, &types.Application{AppSpec: types.AppSpec{Name: "web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := a.Credential(ctx, &types.Application{AppSpec: types.AppSpec{Name: "db"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if web.UID == db.UID {
+		t.Errorf("apps share uid %d", web.UID)
+	}
+	if web.UID != 30000 || web.GID != web.UID {
+		t.Errorf("unexpected credential for web: %+v", web)
+	}
+
+	// Assignments survive a new allocator over the same storage
+	again, _ := appuser.New(cfg, st)
+	if cred, _ := again.Credential(ctx, &types.Application{AppSpec: types.AppSpec{Name: "web"}}); cred.UID != web.UID {
+		t.Errorf("web uid changed from %d to %d", web.UID, cred.UID)
+	}
+
+	if _, err := a.Credential(ctx, &types.Application{AppSpec: types.AppSpec{Name: "cache"}}); !errors.Is(err, types.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable when IDs run out, got: %v", err)
+	}
+}
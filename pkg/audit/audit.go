@@ -0,0 +1,288 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// FileName is the name of the transparency log in the daemon data directory
+const FileName = "audit.log"
+
+// schema versions the entries of the log, each line a document. The version
+// is not part of the entry, so it is not covered by the hash and entries
+// chained before it was added still verify.
+var schema = storage.Schema{
+	Kind: "audit entry",
+	Migrations: []storage.Migration{
+		nil, // 1: the schema version was added
+	},
+}
+
+// KindSession marks entries recording a session rather than a deployment
+const KindSession = "session"
+
+// KindLifecycle marks entries recording an app being started, stopped or
+// exiting, with who or what initiated it
+const KindLifecycle = "lifecycle"
+
+// SessionLogs is the session recorded for a logs request
+const SessionLogs = "logs"
+
+// SessionCoreDump is the session recorded for fetching a core dump
+const SessionCoreDump = "coredump"
+
+// Entry records a single deployed artifact, with Kind KindSession a session
+// a peer had with an app, or with Kind KindLifecycle a lifecycle transition.
+// Each entry commits to the previous one through PrevHash, so rewriting or
+// removing a historical entry breaks every hash after it.
+type Entry struct {
+	// Seq is the position of the entry in the log, starting at 1
+	Seq uint64 `json:"seq"`
+
+	// Time is when the package was deployed or the session started
+	Time time.Time `json:"time"`
+
+	// AppID is the deployed application ID, or the app of the session
+	AppID string `json:"app_id"`
+
+	// Kind is KindSession for sessions, KindLifecycle for transitions, empty
+	// for deployments. The session and transition fields are omitted from
+	// deployments so that entries written before they were recorded keep
+	// their hash.
+	Kind string `json:"kind,omitempty"`
+
+	// Event is the lifecycle event of a transition
+	Event types.AppEvent `json:"event,omitempty"`
+
+	// Initiator is who or what initiated a transition, if known
+	Initiator *types.Initiator `json:"initiator,omitempty"`
+
+	// Session is what the peer did, e.g. SessionLogs
+	Session string `json:"session,omitempty"`
+
+	// Peer is the peer ID that opened the session
+	Peer string `json:"peer,omitempty"`
+
+	// DurationMillis is how long the session lasted
+	DurationMillis int64 `json:"duration_ms,omitempty"`
+
+	// TranscriptHash is the SHA-256 of everything sent to the peer (hex)
+	TranscriptHash string `json:"transcript_hash,omitempty"`
+
+	// TranscriptSize is the size of the transcript in bytes
+	TranscriptSize int64 `json:"transcript_size,omitempty"`
+
+	// PackageChecksum is the SHA-256 of the package file (hex)
+	PackageChecksum string `json:"package_checksum"`
+
+	// PackageSize is the package size in bytes
+	PackageSize int64 `json:"package_size"`
+
+	// ManifestDigest is the SHA-256 of the JSON-encoded manifest (hex)
+	ManifestDigest string `json:"manifest_digest"`
+
+	// Signer is the name of the signing key, empty for unsigned packages
+	Signer string `json:"signer,omitempty"`
+
+	// SignerKey is the hex-encoded public key of the signer
+	SignerKey string `json:"signer_key,omitempty"`
+
+	// RequestID is the request that deployed the package
+	RequestID string `json:"request_id,omitempty"`
+
+	// PrevHash is the hash of the previous entry, empty for the first entry
+	PrevHash string `json:"prev_hash"`
+
+	// Hash is the SHA-256 over PrevHash and the entry contents (hex)
+	Hash string `json:"hash"`
+}
+
+// ComputeHash returns the hash the entry should carry
+func (e *Entry) ComputeHash() string {
+	unsigned := *e
+	unsigned.Hash = ""
+
+	// Marshaling a struct of plain fields cannot fail
+	data, _ := json.Marshal(&unsigned)
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ManifestDigest returns the digest recorded for a manifest
+func ManifestDigest(manifest *types.Manifest) (string, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", types.WrapError(err, "failed to marshal manifest")
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// TranscriptHash returns the hash recorded for a session transcript
+func TranscriptHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log is an append-only, hash-chained log of deployed artifacts stored as JSON lines
+type Log struct {
+	path string
+	mu   sync.Mutex
+	head *Entry
+}
+
+// Open opens the log at path, creating it on first append.
+// The chain is not verified here; new entries link to the last entry as found.
+func Open(path string) (*Log, error) {
+	l := &Log{path: path}
+
+	entries, err := l.Entries()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		l.head = entries[len(entries)-1]
+	}
+
+	return l, nil
+}
+
+// Append chains entry to the log and writes it.
+// Seq, PrevHash and Hash are set by the log.
+func (l *Log) Append(entry *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Seq = 1
+	entry.PrevHash = ""
+	if l.head != nil {
+		entry.Seq = l.head.Seq + 1
+		entry.PrevHash = l.head.Hash
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	entry.Hash = entry.ComputeHash()
+
+	data, err := schema.Marshal(entry)
+	if err != nil {
+		return types.WrapError(err, "failed to marshal audit entry")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return types.WrapError(err, "failed to create audit log directory")
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return types.WrapError(err, "failed to open audit log")
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w: %w", types.ErrStorageWrite, err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w: %w", types.ErrStorageWrite, err)
+	}
+
+	l.head = entry
+	return nil
+}
+
+// Entries reads all entries from the log
+func (l *Log) Entries() ([]*Entry, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, types.WrapError(err, "failed to open audit log")
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := schema.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			if errors.Is(err, types.ErrUnsupportedSchema) {
+				return nil, fmt.Errorf("audit log line %d: %w", line, err)
+			}
+			return nil, fmt.Errorf("%w: audit log line %d is corrupt: %v", types.ErrInvalidChecksum, line, err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, types.WrapError(err, "failed to read audit log")
+	}
+
+	return entries, nil
+}
+
+// Head returns the latest entry, or nil if the log is empty
+func (l *Log) Head() *Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+// Verify checks that entries form an unbroken hash chain starting at the first entry.
+// The returned error wraps types.ErrInvalidChecksum and names the first bad entry.
+func Verify(entries []*Entry) error {
+	prevHash := ""
+	for i, entry := range entries {
+		want := uint64(i + 1)
+		if entry.Seq != want {
+			return fmt.Errorf("%w: entry %d has sequence %d (entries removed or reordered)", types.ErrInvalidChecksum, want, entry.Seq)
+		}
+		if entry.PrevHash != prevHash {
+			return fmt.Errorf("%w: entry %d does not link to the previous entry", types.ErrInvalidChecksum, entry.Seq)
+		}
+		if entry.Hash != entry.ComputeHash() {
+			return fmt.Errorf("%w: entry %d (%s) was modified", types.ErrInvalidChecksum, entry.Seq, entry.AppID)
+		}
+		prevHash = entry.Hash
+	}
+	return nil
+}
+
+// Contains reports whether an entry with the given hash is part of entries.
+// Checking a previously recorded head detects a log that was rewritten from scratch.
+func Contains(entries []*Entry, hash string) bool {
+	for _, entry := range entries {
+		if entry.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// Sessions returns the session entries of entries
+func Sessions(entries []*Entry) []*Entry {
+	var sessions []*Entry
+	for _, entry := range entries {
+		if entry.Kind == KindSession {
+			sessions = append(sessions, entry)
+		}
+	}
+	return sessions
+}
@@ -0,0 +1,171 @@
+package audit_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/audit"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func appendEntries(t *testing.T, path string, appIDs ...string) []*audit.Entry {
+	t.Helper()
+
+	log, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	for _, id := range appIDs {
+		if err := log.Append(&audit.Entry{AppID: id, PackageChecksum: "abc"}); err != nil {
+			t.Fatalf("failed to append: %v", err)
+		}
+	}
+
+	entries, err := log.Entries()
+	if err != nil {
+		t.Fatalf("failed to read entries: %v", err)
+	}
+	return entries
+}
+
+func TestAppendAndVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), audit.FileName)
+	appendEntries(t, path, "a-1.0.0", "b-1.0.0")
+
+	// Reopening continues the existing chain
+	entries := appendEntries(t, path, "a-1.1.0")
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if entries[2].Seq != 3 || entries[2].PrevHash != entries[1].Hash {
+		t.Errorf("entry 3 not chained: %+v", entries[2])
+	}
+	if err := audit.Verify(entries); err != nil {
+		t.Errorf("valid chain rejected: %v", err)
+	}
+	if !audit.Contains(entries, entries[0].Hash) {
+		t.Error("Contains() should find the first entry")
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]*audit.Entry) []*audit.Entry
+	}{
+		{
+			name: "modified entry",
+			tamper: func(e []*audit.Entry) []*audit.Entry {
+				e[1].PackageChecksum = "evil"
+				return e
+			},
+		},
+		{
+			name: "modified and rehashed entry",
+			tamper: func(e []*audit.Entry) []*audit.Entry {
+				e[0].AppID = "evil"
+				e[0].Hash = e[0].ComputeHash()
+				return e
+			},
+		},
+		{
+			name: "removed entry",
+			tamper: func(e []*audit.Entry) []*audit.Entry {
+				return append(e[:1], e[2:]...)
+			},
+		},
+		{
+			name: "reordered entries",
+			tamper: func(e []*audit.Entry) []*audit.Entry {
+				e[0], e[1] = e[1], e[0]
+				return e
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), audit.FileName)
+			entries := appendEntries(t, path, "a", "b", "c")
+
+			err := audit.Verify(tt.tamper(entries))
+			if !errors.Is(err, types.ErrInvalidChecksum) {
+				t.Errorf("expected ErrInvalidChecksum, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestSessionsShareTheChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), audit.FileName)
+	appendEntries(t, path, "a-1.0.0")
+
+	log, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	transcript := []byte("hello\n")
+	session := &audit.Entry{
+		AppID:          "a-1.0.0",
+		Kind:           audit.KindSession,
+		Session:        audit.SessionLogs,
+		Peer:           "12D3KooWPeer",
+		TranscriptHash: audit.TranscriptHash(transcript),
+		TranscriptSize: int64(len(transcript)),
+	}
+	if err := log.Append(session); err != nil {
+		t.Fatalf("failed to append session: %v", err)
+	}
+
+	entries := appendEntries(t, path, "a-1.1.0")
+	if err := audit.Verify(entries); err != nil {
+		t.Fatalf("chain with a session rejected: %v", err)
+	}
+	sessions := audit.Sessions(entries)
+	if len(sessions) != 1 || sessions[0].Seq != 2 || sessions[0].Peer != "12D3KooWPeer" {
+		t.Errorf("Sessions() = %+v, want the session at seq 2", sessions)
+	}
+
+	// Tampering with a transcript hash breaks the chain like any other field
+	entries[1].TranscriptHash = audit.TranscriptHash([]byte("edited\n"))
+	if err := audit.Verify(entries); !errors.Is(err, types.ErrInvalidChecksum) {
+		t.Errorf("expected ErrInvalidChecksum, got: %v", err)
+	}
+}
+
+func TestDeploymentHashUnchangedBySessionFields(t *testing.T) {
+	// The entry as written before sessions were recorded
+	legacy := struct {
+		Seq             uint64    `json:"seq"`
+		Time            time.Time `json:"time"`
+		AppID           string    `json:"app_id"`
+		PackageChecksum string    `json:"package_checksum"`
+		PackageSize     int64     `json:"package_size"`
+		ManifestDigest  string    `json:"manifest_digest"`
+		Signer          string    `json:"signer,omitempty"`
+		SignerKey       string    `json:"signer_key,omitempty"`
+		RequestID       string    `json:"request_id,omitempty"`
+		PrevHash        string    `json:"prev_hash"`
+		Hash            string    `json:"hash"`
+	}{Seq: 1, Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), AppID: "a-1.0.0", PackageChecksum: "abc", PackageSize: 42, ManifestDigest: "def"}
+
+	data, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	want := hex.EncodeToString(sum[:])
+
+	var entry audit.Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if got := entry.ComputeHash(); got != want {
+		t.Errorf("ComputeHash() = %s, want the legacy hash %s", got, want)
+	}
+}
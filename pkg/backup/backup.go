@@ -0,0 +1,147 @@
+// Package backup carries snapshots of app volumes (see pkg/volume) between
+// nodes over the backup protocol, and keeps them in an S3-compatible bucket.
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// Actions of backup requests
+const (
+	// ActionCreate snapshots the volumes of an app and sends the snapshot to
+	// the targets of the request, or else those the node is configured with
+	ActionCreate = "create"
+
+	// ActionList lists the snapshots the node keeps, or those in its bucket
+	ActionList = "list"
+
+	// ActionStore keeps the snapshot whose archive follows the request,
+	// sent by another node backing up to this one
+	ActionStore = "store"
+
+	// ActionFetch sends the archive of a snapshot after the response
+	ActionFetch = "fetch"
+
+	// ActionRestore replaces the volumes of an app with a snapshot
+	ActionRestore = "restore"
+)
+
+// TargetS3 names the bucket the node is configured with, as a target of
+// ActionCreate or the source of ActionList and ActionRestore
+const TargetS3 = "s3"
+
+// Request is sent on a backup stream
+type Request struct {
+	Action string `json:"action"`
+	App    string `json:"app,omitempty"`
+
+	// Snapshot is the ID of the snapshot to fetch or restore, the latest
+	// snapshot of App if empty
+	Snapshot string `json:"snapshot,omitempty"`
+
+	// Targets are the peer IDs, or TargetS3, a created snapshot is sent to
+	// instead of the configured ones
+	Targets []string `json:"targets,omitempty"`
+
+	// From is where snapshots are listed or restored from: the node itself
+	// if empty, TargetS3, or for restores the peer ID of another node
+	From string `json:"from,omitempty"`
+
+	// Meta describes the snapshot following an ActionStore request
+	Meta *volume.Snapshot `json:"meta,omitempty"`
+
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// TargetResult is the outcome of sending a snapshot to one target
+type TargetResult struct {
+	Target string `json:"target"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Response answers a Request. For ActionFetch, the archive of Snapshot
+// follows it.
+type Response struct {
+	Success   bool               `json:"success"`
+	Snapshot  *volume.Snapshot   `json:"snapshot,omitempty"`  // Snapshot created, fetched or restored
+	Snapshots []*volume.Snapshot `json:"snapshots,omitempty"` // Snapshots listed, newest first
+	Targets   []TargetResult     `json:"targets,omitempty"`   // Where a created snapshot was sent
+	Error     string             `json:"error,omitempty"`
+	Code      string             `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string             `json:"request_id,omitempty"`
+}
+
+// Do sends req to the node peerID and returns its response. For
+// ActionFetch, fn reads the archive from the stream; otherwise it may be nil.
+func Do(ctx context.Context, host types.Host, peerID string, req *Request, fn func(resp *Response, r io.Reader) error) (*Response, error) {
+	stream, err := host.NewStream(ctx, peerID, consts.BackupProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return nil, err
+	}
+	resp, err := readResponse(stream)
+	if err != nil {
+		return nil, err
+	}
+	if fn != nil {
+		if err := fn(resp, stream); err != nil {
+			_ = stream.Reset()
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// Send sends snap, whose archive is read from r, to the node peerID to keep
+func Send(ctx context.Context, host types.Host, peerID string, snap *volume.Snapshot, r io.Reader, requestID string) error {
+	stream, err := host.NewStream(ctx, peerID, consts.BackupProtocolID)
+	if err != nil {
+		return fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	if err := wire.WriteMessage(stream, &Request{Action: ActionStore, App: snap.App, Meta: snap, RequestID: requestID}); err != nil {
+		return err
+	}
+	if n, err := io.CopyN(stream, r, snap.Size); err != nil {
+		_ = stream.Reset()
+		return fmt.Errorf("failed to send snapshot after %d of %d bytes: %w", n, snap.Size, err)
+	}
+	_, err = readResponse(stream)
+	return err
+}
+
+// Fetch fetches the snapshot id of app, or its latest if id is empty, from
+// the node peerID, and hands it to fn with a reader of its archive
+func Fetch(ctx context.Context, host types.Host, peerID string, app, id string, requestID string, fn func(snap *volume.Snapshot, r io.Reader) error) error {
+	_, err := Do(ctx, host, peerID, &Request{Action: ActionFetch, App: app, Snapshot: id, RequestID: requestID}, func(resp *Response, r io.Reader) error {
+		if resp.Snapshot == nil {
+			return fmt.Errorf("node %s sent no snapshot", peerID)
+		}
+		return fn(resp.Snapshot, io.LimitReader(r, resp.Snapshot.Size))
+	})
+	return err
+}
+
+// readResponse reads a response and returns the error it reports, if any
+func readResponse(r io.Reader) (*Response, error) {
+	var resp Response
+	if err := wire.ReadMessage(r, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.Success {
+		return nil, types.ErrorFromCode(resp.Code, resp.Error)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,123 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/backup"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// objectServer keeps the objects of a bucket in memory, path-style
+type objectServer struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *objectServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == http.MethodPut:
+		s.objects[key], _ = io.ReadAll(r.Body)
+	case r.Method == http.MethodGet && key == "":
+		type content struct{ Key string }
+		var result struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []content
+		}
+		for k := range s.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				result.Contents = append(result.Contents, content{k})
+			}
+		}
+		_ = xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	if b, err := backup.NewBucket(&config.S3Config{}); b != nil || err != nil {
+		t.Fatalf("NewBucket() without an endpoint = %v, %v, want nil", b, err)
+	}
+
+	server := httptest.NewServer(&objectServer{objects: make(map[string][]byte)})
+	defer server.Close()
+	bucket, err := backup.NewBucket(&config.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "bucket",
+		Prefix:          "backups/",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	created := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"web-1", "web-2"} {
+		archive := []byte("archive of " + id)
+		snap := &volume.Snapshot{ID: id, App: "web", Volumes: []string{"data"}, Size: int64(len(archive)), SHA256: "00", CreatedAt: created.Add(time.Duration(i) * time.Hour)}
+		if err := bucket.Put(ctx, snap, bytes.NewReader(archive)); err != nil {
+			t.Fatalf("Put(%s) error = %v", id, err)
+		}
+	}
+
+	snapshots, err := bucket.List(ctx, "web")
+	if err != nil || len(snapshots) != 2 || snapshots[0].ID != "web-2" {
+		t.Fatalf("List() = %v, %v, want web-2 and web-1", snapshots, err)
+	}
+
+	snap, body, err := bucket.Open(ctx, "web", "")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	_ = body.Close()
+	if snap.ID != "web-2" || string(data) != "archive of web-2" {
+		t.Errorf("Open() of the latest = %s, %q", snap.ID, data)
+	}
+
+	if _, _, err := bucket.Open(ctx, "web", "web-3"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Open() of a missing snapshot error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := bucket.Open(ctx, "db", ""); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Open() of an app without snapshots error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReadMessageLimit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := wire.WriteMessage(&buf, &backup.Request{Action: backup.ActionList}); err != nil {
+		t.Fatal(err)
+	}
+	var req backup.Request
+	if err := wire.ReadMessage(&buf, &req); err != nil || req.Action != backup.ActionList {
+		t.Errorf("ReadMessage() = %+v, %v", req, err)
+	}
+
+	huge := []byte{0xff, 0xff, 0xff, 0xff}
+	if err := wire.ReadMessage(bytes.NewReader(huge), &req); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("ReadMessage() of an oversized message error = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/s3"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
+)
+
+// maxMetaSize bounds the metadata of a snapshot read from a bucket
+const maxMetaSize = 1 << 20
+
+// Bucket keeps snapshots in an S3-compatible bucket, each as its archive
+// <prefix><app>/<id>.tar.gz next to its metadata <prefix><app>/<id>.json.
+// Old snapshots are not deleted; the lifecycle rules of the bucket can
+// expire them.
+type Bucket struct {
+	client *s3.Client
+	prefix string
+}
+
+// NewBucket returns the bucket cfg configures, or nil if it configures none
+func NewBucket(cfg *config.S3Config) (*Bucket, error) {
+	if cfg.Endpoint == "" {
+		return nil, nil
+	}
+	client, err := s3.New(s3.Config{
+		Endpoint:        cfg.Endpoint,
+		Region:          cfg.Region,
+		Bucket:          cfg.Bucket,
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Bucket{client: client, prefix: cfg.Prefix}, nil
+}
+
+// String returns the location of the bucket, for logs and messages
+func (b *Bucket) String() string {
+	return b.client.String() + "/" + b.prefix
+}
+
+// key returns the key of the object of snapshot id of app with suffix ext
+func (b *Bucket) key(app, id, ext string) string {
+	return b.prefix + app + "/" + id + ext
+}
+
+// Put uploads snap, whose archive is read from r
+func (b *Bucket) Put(ctx context.Context, snap *volume.Snapshot, r io.Reader) error {
+	meta, err := json.Marshal(snap)
+	if err != nil {
+		return types.WrapError(err, "failed to marshal snapshot")
+	}
+	if err := b.client.Put(ctx, b.key(snap.App, snap.ID, ".tar.gz"), r, snap.Size); err != nil {
+		return err
+	}
+	// The metadata is uploaded last, so only complete snapshots are listed
+	return b.client.Put(ctx, b.key(snap.App, snap.ID, ".json"), strings.NewReader(string(meta)), int64(len(meta)))
+}
+
+// List returns the snapshots of app in the bucket, or of all apps if app is
+// empty, newest first
+func (b *Bucket) List(ctx context.Context, app string) ([]*volume.Snapshot, error) {
+	prefix := b.prefix
+	if app != "" {
+		prefix += app + "/"
+	}
+	objects, err := b.client.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*volume.Snapshot
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, ".json") {
+			continue
+		}
+		snap, err := b.meta(ctx, object.Key)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snap)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Open returns the snapshot id of app, or its latest if id is empty, and a
+// reader of its archive, which the caller closes
+func (b *Bucket) Open(ctx context.Context, app, id string) (*volume.Snapshot, io.ReadCloser, error) {
+	var snap *volume.Snapshot
+	if id == "" {
+		snapshots, err := b.List(ctx, app)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(snapshots) == 0 {
+			return nil, nil, fmt.Errorf("%w: no snapshot of %s in %s", types.ErrNotFound, app, b)
+		}
+		snap = snapshots[0]
+	} else {
+		var err error
+		if snap, err = b.meta(ctx, b.key(app, id, ".json")); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := snap.Validate(); err != nil || snap.App != app {
+		return nil, nil, fmt.Errorf("%w: invalid snapshot %s in %s", types.ErrInvalidInput, snap.ID, b)
+	}
+
+	body, _, err := b.client.Get(ctx, b.key(app, snap.ID, ".tar.gz"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return snap, body, nil
+}
+
+// meta reads the snapshot metadata at key
+func (b *Bucket) meta(ctx context.Context, key string) (*volume.Snapshot, error) {
+	body, _, err := b.client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	var snap volume.Snapshot
+	if err := json.NewDecoder(io.LimitReader(body, maxMetaSize)).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", key, err)
+	}
+	return &snap, nil
+}
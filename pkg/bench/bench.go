@@ -0,0 +1,368 @@
+// Package bench measures the throughput and latency of the connection
+// between two nodes, over the bench protocol, to validate a network before
+// large deployments.
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+// Defaults of the options left zero
+const (
+	DefaultSize    = 100 << 20
+	DefaultStreams = 4
+	DefaultPings   = 10
+)
+
+// MaxSize bounds the bytes a benchmark sends in one direction
+const MaxSize = 10 << 30
+
+// MaxStreams bounds the concurrent streams of a benchmark
+const MaxStreams = 64
+
+// MaxPings bounds the pings latency is measured with
+const MaxPings = 1000
+
+// pingTimeout bounds a single ping
+const pingTimeout = 5 * time.Second
+
+// bufferSize is the size of the writes and reads of the data
+const bufferSize = 64 * 1024
+
+// Directions of the data of a benchmark stream, seen from the side opening it
+const (
+	// DirectionUpload sends the data to the node
+	DirectionUpload = "upload"
+
+	// DirectionDownload receives the data from the node
+	DirectionDownload = "download"
+)
+
+// Options configure a benchmark
+type Options struct {
+	// Size is how many bytes to send in each direction, DefaultSize if 0
+	Size int64 `json:"size,omitempty"`
+
+	// Streams is how many streams share the bytes, DefaultStreams if 0
+	Streams int `json:"streams,omitempty"`
+
+	// Pings is how many pings measure latency, DefaultPings if 0 and none
+	// if negative
+	Pings int `json:"pings,omitempty"`
+
+	// Direction limits the benchmark to DirectionUpload or
+	// DirectionDownload; both are measured if empty
+	Direction string `json:"direction,omitempty"`
+}
+
+// WithDefaults returns o with the defaults of the options left zero
+func (o Options) WithDefaults() Options {
+	if o.Size == 0 {
+		o.Size = DefaultSize
+	}
+	if o.Streams == 0 {
+		o.Streams = DefaultStreams
+	}
+	if o.Pings == 0 {
+		o.Pings = DefaultPings
+	}
+	return o
+}
+
+// Validate checks the options, with their defaults applied
+func (o Options) Validate() error {
+	if o.Size <= 0 || o.Size > MaxSize {
+		return fmt.Errorf("%w: size must be between 1 and %d bytes", types.ErrInvalidInput, int64(MaxSize))
+	}
+	if o.Streams <= 0 || o.Streams > MaxStreams {
+		return fmt.Errorf("%w: streams must be between 1 and %d", types.ErrInvalidInput, MaxStreams)
+	}
+	if o.Pings > MaxPings {
+		return fmt.Errorf("%w: pings must be at most %d", types.ErrInvalidInput, MaxPings)
+	}
+	switch o.Direction {
+	case "", DirectionUpload, DirectionDownload:
+	default:
+		return fmt.Errorf("%w: unknown direction %q, want upload or download", types.ErrInvalidInput, o.Direction)
+	}
+	return nil
+}
+
+// Request is sent on a bench stream. Either it carries data in Direction,
+// or it asks the node to benchmark its own connection to Peer.
+type Request struct {
+	// Direction of the data, seen from the side sending the request
+	Direction string `json:"direction,omitempty"`
+
+	// Size is how many bytes the stream carries
+	Size int64 `json:"size,omitempty"`
+
+	// Peer is the node the receiving node benchmarks with Options
+	Peer    string   `json:"peer,omitempty"`
+	Options *Options `json:"options,omitempty"`
+
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// Response answers a Request: after an upload has been received, before a
+// download is sent, and with the report of a benchmark run for a Peer
+type Response struct {
+	Success   bool    `json:"success"`
+	Bytes     int64   `json:"bytes,omitempty"`  // Bytes of an upload received
+	Report    *Report `json:"report,omitempty"` // Report of a benchmark run for a Peer
+	Error     string  `json:"error,omitempty"`
+	Code      string  `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string  `json:"request_id,omitempty"`
+}
+
+// Latency summarizes the round trip times of pings
+type Latency struct {
+	Sent     int           `json:"sent"`
+	Received int           `json:"received"`
+	Min      time.Duration `json:"min"`
+	Avg      time.Duration `json:"avg"`
+	Max      time.Duration `json:"max"`
+}
+
+// Throughput is the rate data was sent at in one direction
+type Throughput struct {
+	Bytes      int64         `json:"bytes"`
+	Elapsed    time.Duration `json:"elapsed"`
+	MbitPerSec float64       `json:"mbit_per_sec"`
+}
+
+// Report is the result of a benchmark
+type Report struct {
+	// From and To are the peer IDs of the node measuring and the one measured
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Local is set when the nodes talked over the local socket rather than
+	// the network, which has no pings
+	Local bool `json:"local,omitempty"`
+
+	Time    time.Time `json:"time"`
+	Size    int64     `json:"size"`
+	Streams int       `json:"streams"`
+
+	// Latency is nil without pings
+	Latency *Latency `json:"latency,omitempty"`
+
+	// Upload and Download are nil when not measured
+	Upload   *Throughput `json:"upload,omitempty"`
+	Download *Throughput `json:"download,omitempty"`
+}
+
+// Run benchmarks the connection of host to the node peerID: it measures
+// latency with libp2p pings, then throughput by sending opts.Size bytes
+// to it and back, split over opts.Streams concurrent bench streams.
+func Run(ctx context.Context, host *p2p.Host, peerID string, opts Options, requestID string) (*Report, error) {
+	opts = opts.WithDefaults()
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		From:    host.ID(),
+		To:      peerID,
+		Local:   host.IsLocal(peerID),
+		Time:    time.Now().UTC(),
+		Size:    opts.Size,
+		Streams: opts.Streams,
+	}
+	if opts.Pings > 0 && !report.Local {
+		latency, err := measureLatency(ctx, host, peerID, opts.Pings)
+		if err != nil {
+			return nil, err
+		}
+		report.Latency = latency
+	}
+
+	var err error
+	if opts.Direction != DirectionDownload {
+		if report.Upload, err = measureThroughput(ctx, host, peerID, DirectionUpload, opts, requestID); err != nil {
+			return nil, fmt.Errorf("upload: %w", err)
+		}
+	}
+	if opts.Direction != DirectionUpload {
+		if report.Download, err = measureThroughput(ctx, host, peerID, DirectionDownload, opts, requestID); err != nil {
+			return nil, fmt.Errorf("download: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// measureLatency pings peerID count times. Pings lost to a timeout count as
+// sent but not received; it fails only if none come back.
+func measureLatency(ctx context.Context, host *p2p.Host, peerID string, count int) (*Latency, error) {
+	pid, err := peer.Decode(peerID)
+	if err != nil {
+		return nil, types.WrapError(err, "invalid peer ID")
+	}
+
+	latency := &Latency{}
+	var total time.Duration
+	for latency.Sent < count {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		res := <-ping.Ping(pingCtx, host.LibP2PHost(), pid)
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		latency.Sent++
+		if res.Error != nil {
+			continue
+		}
+		latency.Received++
+		total += res.RTT
+		if latency.Min == 0 || res.RTT < latency.Min {
+			latency.Min = res.RTT
+		}
+		latency.Max = max(latency.Max, res.RTT)
+	}
+	if latency.Received == 0 {
+		return nil, fmt.Errorf("%w: no pings to %s answered", types.ErrTimeout, peerID)
+	}
+	latency.Avg = total / time.Duration(latency.Received)
+	return latency, nil
+}
+
+// measureThroughput sends opts.Size bytes in direction over opts.Streams
+// concurrent streams and returns the rate of all of them together
+func measureThroughput(ctx context.Context, host *p2p.Host, peerID string, direction string, opts Options, requestID string) (*Throughput, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	errs := make([]error, opts.Streams)
+	var wg sync.WaitGroup
+	for i := range opts.Streams {
+		// The first streams carry the remainder
+		size := opts.Size / int64(opts.Streams)
+		if int64(i) < opts.Size%int64(opts.Streams) {
+			size++
+		}
+		if size == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := &Request{Direction: direction, Size: size, RequestID: requestID}
+			if errs[i] = runStream(ctx, host, peerID, req); errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return &Throughput{
+		Bytes:      opts.Size,
+		Elapsed:    elapsed,
+		MbitPerSec: float64(opts.Size) * 8 / elapsed.Seconds() / 1e6,
+	}, nil
+}
+
+// runStream opens a bench stream and sends or receives the data of req on it
+func runStream(ctx context.Context, host *p2p.Host, peerID string, req *Request) error {
+	stream, err := host.NewStream(ctx, peerID, consts.BenchProtocolID)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Unblock reads and writes once the benchmark is canceled
+	stop := context.AfterFunc(ctx, func() { _ = stream.Reset() })
+	defer stop()
+
+	if err := wire.WriteMessage(stream, req); err != nil {
+		return err
+	}
+
+	switch req.Direction {
+	case DirectionUpload:
+		if _, err := WriteData(stream, req.Size); err != nil {
+			return fmt.Errorf("failed to send data: %w", err)
+		}
+		resp, err := readResponse(stream)
+		if err != nil {
+			return err
+		}
+		if resp.Bytes != req.Size {
+			return fmt.Errorf("node received %d of %d bytes", resp.Bytes, req.Size)
+		}
+	case DirectionDownload:
+		if _, err := readResponse(stream); err != nil {
+			return err
+		}
+		n, err := io.CopyN(io.Discard, stream, req.Size)
+		if err != nil {
+			return fmt.Errorf("failed to receive data after %d of %d bytes: %w", n, req.Size, err)
+		}
+	}
+	return nil
+}
+
+// RunRemote asks the node from to benchmark its connection to the node to,
+// and returns its report
+func RunRemote(ctx context.Context, host *p2p.Host, from string, to string, opts Options, requestID string) (*Report, error) {
+	stream, err := host.NewStream(ctx, from, consts.BenchProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = stream.Close() }()
+
+	if err := wire.WriteMessage(stream, &Request{Peer: to, Options: &opts, RequestID: requestID}); err != nil {
+		return nil, err
+	}
+	resp, err := readResponse(stream)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Report == nil {
+		return nil, fmt.Errorf("node %s sent no report", from)
+	}
+	return resp.Report, nil
+}
+
+// readResponse reads a response and returns the error it reports, if any
+func readResponse(r io.Reader) (*Response, error) {
+	var resp Response
+	if err := wire.ReadMessage(r, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.Success {
+		return nil, types.ErrorFromCode(resp.Code, resp.Error)
+	}
+	return &resp, nil
+}
+
+// WriteData writes size bytes of benchmark data to w
+func WriteData(w io.Writer, size int64) (int64, error) {
+	buf := make([]byte, bufferSize)
+	var written int64
+	for written < size {
+		n, err := w.Write(buf[:min(int64(len(buf)), size-written)])
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
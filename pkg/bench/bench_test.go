@@ -0,0 +1,65 @@
+package bench_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/bench"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+func TestOptions(t *testing.T) {
+	opts := bench.Options{}.WithDefaults()
+	if opts.Size != bench.DefaultSize || opts.Streams != bench.DefaultStreams || opts.Pings != bench.DefaultPings {
+		t.Errorf("WithDefaults() = %+v", opts)
+	}
+	if err := opts.Validate(); err != nil {
+		t.Errorf("Validate() of the defaults error = %v", err)
+	}
+	if err := (bench.Options{Size: 1, Streams: 1, Pings: -1, Direction: bench.DirectionUpload}).Validate(); err != nil {
+		t.Errorf("Validate() without pings error = %v", err)
+	}
+
+	invalid := []bench.Options{
+		{Size: bench.MaxSize + 1, Streams: 1},
+		{Size: 1, Streams: bench.MaxStreams + 1},
+		{Size: 1, Streams: 1, Pings: bench.MaxPings + 1},
+		{Size: 1, Streams: 1, Direction: "sideways"},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); !errors.Is(err, types.ErrInvalidInput) {
+			t.Errorf("Validate(%+v) error = %v, want ErrInvalidInput", opts, err)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	var buf bytes.Buffer
+	req := &bench.Request{Peer: "12D3KooW", Options: &bench.Options{Size: 1 << 20, Streams: 2}, RequestID: "r1"}
+	if err := wire.WriteMessage(&buf, req); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	n, err := bench.WriteData(&buf, 200_000)
+	if err != nil || n != 200_000 {
+		t.Fatalf("WriteData() = %d, %v", n, err)
+	}
+
+	var got bench.Request
+	if err := wire.ReadMessage(&buf, &got); err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if got.Peer != req.Peer || got.Options == nil || *got.Options != *req.Options || got.RequestID != req.RequestID {
+		t.Errorf("ReadMessage() = %+v, want %+v", got, req)
+	}
+	if buf.Len() != 200_000 {
+		t.Errorf("%d bytes of data follow the message, want 200000", buf.Len())
+	}
+
+	// A length beyond the limit is not allocated
+	oversized := bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff})
+	if err := wire.ReadMessage(oversized, &got); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("ReadMessage() of an oversized message error = %v, want ErrInvalidInput", err)
+	}
+}
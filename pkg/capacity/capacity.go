@@ -0,0 +1,82 @@
+// Package capacity measures what a node offers to applications: its CPU,
+// memory and disk, less what is reserved for the daemon and the operating
+// system.
+package capacity
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// bytesPerMB converts the byte counts of the platform to megabytes
+const bytesPerMB = 1024 * 1024
+
+// Measure returns the capacity of the node, where appsDir is the directory
+// apps are deployed to and apps are the deployed apps, of which those
+// holding resources count as allocated. Resources the platform cannot
+// measure have a total of zero.
+func Measure(appsDir string, reserved config.ReservedConfig, apps []*types.Application) *types.NodeCapacity {
+	c := &types.NodeCapacity{
+		Total: types.NodeResources{CPUPercent: float64(runtime.NumCPU() * 100)},
+		Reserved: types.NodeResources{
+			CPUPercent: reserved.CPUPercent,
+			MemoryMB:   reserved.MemoryMB,
+			DiskMB:     reserved.DiskMB,
+		},
+		Enforced: !reserved.IsZero(),
+	}
+	if memory, err := totalMemory(); err == nil {
+		c.Total.MemoryMB = int64(memory / bytesPerMB)
+	}
+	if total, free, err := diskSpace(appsDir); err == nil {
+		c.Total.DiskMB = total / bytesPerMB
+		c.Allocated.DiskMB = (total - free) / bytesPerMB
+	}
+
+	c.Allocatable = types.NodeResources{
+		CPUPercent: max(c.Total.CPUPercent-c.Reserved.CPUPercent, 0),
+		MemoryMB:   max(c.Total.MemoryMB-c.Reserved.MemoryMB, 0),
+		DiskMB:     max(c.Total.DiskMB-c.Reserved.DiskMB, 0),
+	}
+	for _, app := range apps {
+		if !Allocates(app) || app.Manifest == nil || app.Manifest.Resources == nil {
+			continue
+		}
+		c.Allocated.CPUPercent += app.Manifest.Resources.CPUPercent
+		c.Allocated.MemoryMB += app.Manifest.Resources.MemoryMB
+	}
+	return c
+}
+
+// Allocates reports whether app holds its resources: it runs, is about to or
+// is paused. Stopped and failed apps, including versions replaced by a newer
+// one, do not.
+func Allocates(app *types.Application) bool {
+	switch app.Status {
+	case types.AppStatusRunning, types.AppStatusStarting, types.AppStatusRestarting, types.AppStatusPaused:
+		return true
+	}
+	return false
+}
+
+// Check returns an error wrapping types.ErrUnavailable if an app declaring
+// limits does not fit in the free capacity of c. Resources with an unknown
+// total are not checked.
+func Check(c *types.NodeCapacity, limits *types.ResourceLimits) error {
+	if limits == nil {
+		return nil
+	}
+	free := c.Free()
+	if c.Total.MemoryMB > 0 && limits.MemoryMB > free.MemoryMB {
+		return fmt.Errorf("%w: app declares %d MB memory, node has %d MB free of %d MB allocatable",
+			types.ErrUnavailable, limits.MemoryMB, free.MemoryMB, c.Allocatable.MemoryMB)
+	}
+	if c.Total.CPUPercent > 0 && limits.CPUPercent > free.CPUPercent {
+		return fmt.Errorf("%w: app declares %.0f%% CPU, node has %.0f%% free of %.0f%% allocatable",
+			types.ErrUnavailable, limits.CPUPercent, free.CPUPercent, c.Allocatable.CPUPercent)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package capacity_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/capacity"
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// app returns a deployed app declaring limits
+func app(name string, cpu float64, memoryMB int64) *types.Application {
+	return &types.Application{
+		AppSpec: types.AppSpec{
+			ID:   name + "-1.0.0",
+			Name: name,
+			Manifest: &types.Manifest{
+				Name:      name,
+				Resources: &types.ResourceLimits{CPUPercent: cpu, MemoryMB: memoryMB},
+			},
+		},
+		AppRuntimeStatus: types.AppRuntimeStatus{Status: types.AppStatusRunning},
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	reserved := config.ReservedConfig{CPUPercent: 50, MemoryMB: 256}
+	stopped := app("batch", 100, 1024)
+	stopped.Status = types.AppStatusStopped
+	apps := []*types.Application{app("web", 20, 128), app("worker", 30, 64), stopped, {AppSpec: types.AppSpec{ID: "plain-1.0.0"}}}
+
+	c := capacity.Measure(t.TempDir(), reserved, apps)
+	if c.Total.CPUPercent < 100 {
+		t.Fatalf("Total.CPUPercent = %v, want at least one core", c.Total.CPUPercent)
+	}
+	if c.Reserved.MemoryMB != 256 || !c.Enforced {
+		t.Errorf("Reserved = %+v, Enforced = %v, want 256 MB enforced", c.Reserved, c.Enforced)
+	}
+	if c.Allocatable.CPUPercent != c.Total.CPUPercent-50 {
+		t.Errorf("Allocatable.CPUPercent = %v, want total less 50", c.Allocatable.CPUPercent)
+	}
+	if c.Total.MemoryMB > 0 && c.Allocatable.MemoryMB != max(c.Total.MemoryMB-256, 0) {
+		t.Errorf("Allocatable.MemoryMB = %v, want total less 256", c.Allocatable.MemoryMB)
+	}
+	if c.Allocated.CPUPercent != 50 || c.Allocated.MemoryMB != 192 {
+		t.Errorf("Allocated = %+v, want 50%% CPU and 192 MB", c.Allocated)
+	}
+
+	if capacity.Measure(t.TempDir(), config.ReservedConfig{}, nil).Enforced {
+		t.Error("Enforced without a reservation")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := &types.NodeCapacity{
+		Total:       types.NodeResources{CPUPercent: 400, MemoryMB: 2048},
+		Allocatable: types.NodeResources{CPUPercent: 300, MemoryMB: 1536},
+		Allocated:   types.NodeResources{CPUPercent: 100, MemoryMB: 1024},
+	}
+
+	tests := []struct {
+		name    string
+		limits  *types.ResourceLimits
+		wantErr bool
+	}{
+		{"no limits", nil, false},
+		{"fits", &types.ResourceLimits{CPUPercent: 200, MemoryMB: 512}, false},
+		{"too much memory", &types.ResourceLimits{MemoryMB: 513}, true},
+		{"too much CPU", &types.ResourceLimits{CPUPercent: 201}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := capacity.Check(c, tt.limits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, types.ErrUnavailable) {
+				t.Errorf("Check() error = %v, want ErrUnavailable", err)
+			}
+		})
+	}
+
+	// Memory is not checked on platforms that cannot measure it
+	unknown := &types.NodeCapacity{Total: types.NodeResources{CPUPercent: 100}, Allocatable: types.NodeResources{CPUPercent: 100}}
+	if err := capacity.Check(unknown, &types.ResourceLimits{MemoryMB: 512}); err != nil {
+		t.Errorf("Check() with unknown memory error = %v", err)
+	}
+}
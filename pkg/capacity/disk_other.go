@@ -0,0 +1,14 @@
+//go:build !unix
+
+package capacity
+
+import (
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// diskSpace is not supported on this platform
+func diskSpace(dir string) (int64, int64, error) {
+	return 0, 0, fmt.Errorf("%w: disk space on this platform", types.ErrNotImplemented)
+}
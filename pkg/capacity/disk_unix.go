@@ -0,0 +1,15 @@
+//go:build unix
+
+package capacity
+
+import "syscall"
+
+// diskSpace returns the size of the filesystem holding dir and the bytes
+// free on it for unprivileged users, in bytes
+func diskSpace(dir string) (int64, int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	return int64(st.Blocks) * int64(st.Bsize), int64(st.Bavail) * int64(st.Bsize), nil
+}
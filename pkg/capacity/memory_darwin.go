@@ -0,0 +1,8 @@
+package capacity
+
+import "golang.org/x/sys/unix"
+
+// totalMemory returns the physical memory of the node in bytes
+func totalMemory() (uint64, error) {
+	return unix.SysctlUint64("hw.memsize")
+}
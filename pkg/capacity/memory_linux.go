@@ -0,0 +1,12 @@
+package capacity
+
+import "golang.org/x/sys/unix"
+
+// totalMemory returns the physical memory of the node in bytes
+func totalMemory() (uint64, error) {
+	var info unix.Sysinfo_t
+	if err := unix.Sysinfo(&info); err != nil {
+		return 0, err
+	}
+	return uint64(info.Totalram) * uint64(info.Unit), nil
+}
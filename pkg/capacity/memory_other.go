@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package capacity
+
+import (
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// totalMemory is not supported on this platform
+func totalMemory() (uint64, error) {
+	return 0, fmt.Errorf("%w: total memory on this platform", types.ErrNotImplemented)
+}
@@ -1,11 +1,17 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 // getHostname returns the hostname or empty string if unavailable
@@ -116,6 +122,33 @@ type DaemonConfig struct {
 
 	// Security contains security configuration
 	Security SecurityConfig `yaml:"security" mapstructure:"security"`
+
+	// Admission contains pre-deploy admission hook configuration
+	Admission AdmissionConfig `yaml:"admission" mapstructure:"admission"`
+
+	// Policy contains manifest policy rules enforced on every deployment
+	Policy PolicyConfig `yaml:"policy" mapstructure:"policy"`
+
+	// KV contains the per-app key-value store configuration
+	KV KVConfig `yaml:"kv" mapstructure:"kv"`
+
+	// History contains the retention of recent app status samples and events
+	History HistoryConfig `yaml:"history" mapstructure:"history"`
+
+	// Events contains the retention of the app lifecycle event log
+	Events EventsConfig `yaml:"events" mapstructure:"events"`
+
+	// Alerts contains the alert rules evaluated by the daemon
+	Alerts AlertsConfig `yaml:"alerts" mapstructure:"alerts"`
+
+	// Quotas limits what each operator may deploy to the node
+	Quotas QuotaConfig `yaml:"quotas" mapstructure:"quotas"`
+
+	// Backup contains the backups of app volumes
+	Backup BackupConfig `yaml:"backup" mapstructure:"backup"`
+
+	// Registry is the package registry the node fetches packages from by digest
+	Registry RegistryConfig `yaml:"registry" mapstructure:"registry"`
 }
 
 // NodeConfig contains P2P node configuration
@@ -123,21 +156,41 @@ type NodeConfig struct {
 	// Name is the human-readable node name for discovery
 	Name string `yaml:"name" mapstructure:"name"`
 
-	// ListenAddrs are the addresses to listen on
+	// ListenAddrs are the addresses to listen on (default: TCP and QUIC on
+	// all IPv4 and IPv6 interfaces, port 9000 for daemons and 9001 for controllers)
 	ListenAddrs []string `yaml:"listen_addrs" mapstructure:"listen_addrs"`
 
+	// AddressFamily is the IP address family to listen on and advertise:
+	// "v4", "v6" or "dual" (default: "dual"). Addresses of the other family
+	// in listen_addrs are skipped, so the defaults suit every setting.
+	AddressFamily string `yaml:"address_family" mapstructure:"address_family"`
+
 	// BootstrapPeers are initial peers to connect to
 	BootstrapPeers []string `yaml:"bootstrap_peers" mapstructure:"bootstrap_peers"`
 
 	// EnableMDNS enables mDNS discovery (default: true)
 	EnableMDNS bool `yaml:"enable_mdns" mapstructure:"enable_mdns"`
 
+	// Discovery lists the discovery backends to use: gossip, mdns, dht and
+	// static (default: gossip, plus mdns with enable_mdns, dht unless
+	// disable_dht, and static with static_peers)
+	Discovery []string `yaml:"discovery" mapstructure:"discovery"`
+
+	// StaticPeers are multiaddrs ending in /p2p/<peer ID> of nodes the static
+	// discovery backend keeps connecting to, re-dialing with backoff when
+	// their connections drop
+	StaticPeers []string `yaml:"static_peers" mapstructure:"static_peers"`
+
 	// DisableDHT disables DHT for peer discovery (default: false, DHT is enabled by default)
 	DisableDHT bool `yaml:"disable_dht" mapstructure:"disable_dht"`
 
 	// DHTMode is the DHT mode: "client" or "server" (default: "server")
 	DHTMode string `yaml:"dht_mode" mapstructure:"dht_mode"`
 
+	// DisablePublicBootstrap stops the DHT from falling back to the public
+	// IPFS bootstrap nodes when no bootstrap_peers are configured (default: false)
+	DisablePublicBootstrap bool `yaml:"disable_public_bootstrap" mapstructure:"disable_public_bootstrap"`
+
 	// DisableNATService disables NAT traversal service (default: false, NAT service is enabled by default)
 	DisableNATService bool `yaml:"disable_nat_service" mapstructure:"disable_nat_service"`
 
@@ -159,8 +212,58 @@ type NodeConfig struct {
 	// Labels are node labels for organization
 	Labels map[string]string `yaml:"labels" mapstructure:"labels"`
 
+	// Devices are glob patterns of host devices apps may request and that are
+	// advertised in discovery (default: GPU devices under /dev/dri, /dev/nvidia* and /dev/kfd)
+	Devices []string `yaml:"devices" mapstructure:"devices"`
+
+	// DisableDevices offers no devices to apps (default: false)
+	DisableDevices bool `yaml:"disable_devices" mapstructure:"disable_devices"`
+
+	// Interpreters are the script interpreters advertised as
+	// interpreter.<name>=true labels when found in PATH
+	// (default: sh, bash, python3, node, perl and ruby)
+	Interpreters []string `yaml:"interpreters" mapstructure:"interpreters"`
+
+	// IdentityFile holds the node's libp2p private key, generated on first start,
+	// so its peer ID survives restarts (default: a new peer ID on every start)
+	IdentityFile string `yaml:"identity_file" mapstructure:"identity_file"`
+
+	// DisablePeerExchange stops exchanging known cluster peers with connected
+	// daemons, which lets a node find the whole cluster from one bootstrap peer (default: false)
+	DisablePeerExchange bool `yaml:"disable_peer_exchange" mapstructure:"disable_peer_exchange"`
+
+	// AnnounceAddrs are advertised to peers instead of the listen addresses,
+	// e.g. the host address of a node running behind container NAT
+	AnnounceAddrs []string `yaml:"announce_addrs" mapstructure:"announce_addrs"`
+
+	// LocalSocket is the Unix socket a daemon serves its API on for controllers
+	// on the same machine, and the socket controllers try first to reach such a
+	// daemon without the network (default: daemon.sock in the daemon data
+	// directory, ~/.p2p-playground/daemon.sock for controllers)
+	LocalSocket string `yaml:"local_socket" mapstructure:"local_socket"`
+
+	// DisableLocalSocket neither serves nor uses the local socket (default: false)
+	DisableLocalSocket bool `yaml:"disable_local_socket" mapstructure:"disable_local_socket"`
+
 	// ID is the node ID (optional, auto-generated if not provided)
 	ID string `yaml:"id" mapstructure:"id"`
+
+	// Profile tunes the defaults of other settings at once: "" (none) or
+	// "edge" for small devices (daemon only, see NodeProfileDefaults)
+	Profile string `yaml:"profile" mapstructure:"profile"`
+
+	// MaxConnections is the number of connections above which the least
+	// useful ones are closed (default: 0, the libp2p default)
+	MaxConnections int `yaml:"max_connections" mapstructure:"max_connections"`
+
+	// AnnounceInterval is how often the node announces itself to the
+	// cluster (default: 10s). Peers wait for three missed announcements
+	// before they consider it offline.
+	AnnounceInterval time.Duration `yaml:"announce_interval" mapstructure:"announce_interval"`
+
+	// TransferChunkSize is the size in bytes of the chunks packages are
+	// received in (default: 65536)
+	TransferChunkSize int `yaml:"transfer_chunk_size" mapstructure:"transfer_chunk_size"`
 }
 
 // StorageConfig contains storage configuration
@@ -176,6 +279,14 @@ type StorageConfig struct {
 
 	// KeysDir is where cryptographic keys are stored
 	KeysDir string `yaml:"keys_dir" mapstructure:"keys_dir"`
+
+	// EncryptAtRest encrypts stored packages and the sensitive files declared
+	// in manifests; they are decrypted only while an application runs (daemon only)
+	EncryptAtRest bool `yaml:"encrypt_at_rest" mapstructure:"encrypt_at_rest"`
+
+	// EncryptionKey selects the at-rest key: "node" (default) uses a random key
+	// in KeysDir, "psk" derives it from the security PSK
+	EncryptionKey string `yaml:"encryption_key" mapstructure:"encryption_key"`
 }
 
 // RuntimeConfig contains runtime configuration
@@ -192,8 +303,72 @@ type RuntimeConfig struct {
 	// LogMaxFiles is the maximum number of log files to keep
 	LogMaxFiles int `yaml:"log_max_files" mapstructure:"log_max_files"`
 
+	// MaxPackageSize is the size in bytes of the largest package the daemon
+	// accepts (default: 4 GiB, -1 for no limit)
+	MaxPackageSize int64 `yaml:"max_package_size" mapstructure:"max_package_size"`
+
+	// AppIDFormat is the ID deployed apps get, made of the placeholders
+	// {name}, {version}, {hash} (of the package) and {instance} (the revision
+	// of the deployment), e.g. "{name}-{version}-{instance}" for an ID per
+	// deployment (default: "{name}-{version}"). Apps are addressed by name,
+	// <name>-<version> or ID whatever the format.
+	AppIDFormat string `yaml:"app_id_format" mapstructure:"app_id_format"`
+
 	// EnableResourceLimits enables resource limiting
 	EnableResourceLimits bool `yaml:"enable_resource_limits" mapstructure:"enable_resource_limits"`
+
+	// Backend starts app processes: "exec" as children of the daemon, or
+	// "systemd" as transient systemd services (default: exec)
+	Backend string `yaml:"backend" mapstructure:"backend"`
+
+	// SystemdSlice is the slice the systemd backend places app services in
+	SystemdSlice string `yaml:"systemd_slice" mapstructure:"systemd_slice"`
+
+	// LogSource is where app logs are read from: "file" for the log files in
+	// the app working directories, or "journal" for the systemd journal
+	// (default: file)
+	LogSource string `yaml:"log_source" mapstructure:"log_source"`
+
+	// ScratchDir, if set, holds the logs and temporary files of apps in a
+	// directory per app instead of their working directories, e.g. on a
+	// tmpfs for devices whose apps directory is on a slow or worn SD card
+	ScratchDir string `yaml:"scratch_dir" mapstructure:"scratch_dir"`
+
+	// NetworkSubnet is the IPv4 range for the namespaces of apps with a
+	// network policy (default: 10.213.0.0/16)
+	NetworkSubnet string `yaml:"network_subnet" mapstructure:"network_subnet"`
+
+	// DisableAppAPI stops serving the local socket API used by pkg/appsdk (default: false)
+	DisableAppAPI bool `yaml:"disable_app_api" mapstructure:"disable_app_api"`
+
+	// DisableMessaging stops routing messages between apps on different nodes (default: false)
+	DisableMessaging bool `yaml:"disable_messaging" mapstructure:"disable_messaging"`
+
+	// DisableTopics stops bridging app pub/sub topics onto gossipsub (default: false)
+	DisableTopics bool `yaml:"disable_topics" mapstructure:"disable_topics"`
+
+	// Reserved is kept for the daemon and the operating system, and not
+	// advertised to controllers as capacity for apps
+	Reserved ReservedConfig `yaml:"reserved" mapstructure:"reserved"`
+}
+
+// ReservedConfig is the headroom a node keeps for itself. Once any of it is
+// set, deployments whose declared resource limits do not fit in what is left
+// after the apps already deployed are refused.
+type ReservedConfig struct {
+	// CPUPercent is the CPU reserved, 100 per core
+	CPUPercent float64 `yaml:"cpu_percent" mapstructure:"cpu_percent"`
+
+	// MemoryMB is the memory reserved in megabytes
+	MemoryMB int64 `yaml:"memory_mb" mapstructure:"memory_mb"`
+
+	// DiskMB is the disk space in megabytes that deployments must leave free
+	DiskMB int64 `yaml:"disk_mb" mapstructure:"disk_mb"`
+}
+
+// IsZero reports whether nothing is reserved
+func (r ReservedConfig) IsZero() bool {
+	return r.CPUPercent == 0 && r.MemoryMB == 0 && r.DiskMB == 0
 }
 
 // LoggingConfig contains logging configuration
@@ -209,10 +384,54 @@ type LoggingConfig struct {
 
 	// ErrorOutputPath is where to write error logs
 	ErrorOutputPath string `yaml:"error_output_path" mapstructure:"error_output_path"`
+
+	// Rotation controls rotation of OutputPath when it is a file
+	Rotation LogRotationConfig `yaml:"rotation" mapstructure:"rotation"`
+
+	// Diagnostics periodically logs network status and transfer and deploy
+	// counters (daemon only)
+	Diagnostics DiagnosticsConfig `yaml:"diagnostics" mapstructure:"diagnostics"`
+
+	// RepeatWindow is how long a warning or error with the same message and
+	// fields is logged only once; the repeats are then summarized as
+	// "repeated N times in <window>" (daemon default: 1m, 0 logs every repeat)
+	RepeatWindow time.Duration `yaml:"repeat_window" mapstructure:"repeat_window"`
+
+	// CrashDir is where the daemon writes a report for each panic it recovers
+	// from (empty disables the reports; panics are logged either way)
+	CrashDir string `yaml:"crash_dir" mapstructure:"crash_dir"`
+}
+
+// DiagnosticsConfig controls the periodic diagnostic log line of the daemon
+type DiagnosticsConfig struct {
+	// Enabled logs diagnostics every Interval (default: true)
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+
+	// Interval is how often diagnostics are logged (default: 30s)
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
+}
+
+// LogRotationConfig contains rotation settings for file-based log output
+type LogRotationConfig struct {
+	// MaxSizeMB is the size in megabytes at which the log file is rotated (0 disables rotation)
+	MaxSizeMB int `yaml:"max_size_mb" mapstructure:"max_size_mb"`
+
+	// MaxAgeDays is how many days to retain rotated files (0 keeps them regardless of age)
+	MaxAgeDays int `yaml:"max_age_days" mapstructure:"max_age_days"`
+
+	// MaxBackups is how many rotated files to retain (0 keeps all)
+	MaxBackups int `yaml:"max_backups" mapstructure:"max_backups"`
+
+	// Compress gzips rotated files
+	Compress bool `yaml:"compress" mapstructure:"compress"`
 }
 
 // SecurityConfig contains security configuration
 type SecurityConfig struct {
+	// Profile applies a set of security defaults at once: "" (none) or
+	// "hardened" (daemon only, see ApplySecurityProfile)
+	Profile string `yaml:"profile" mapstructure:"profile"`
+
 	// EnableAuth enables authentication
 	EnableAuth bool `yaml:"enable_auth" mapstructure:"enable_auth"`
 
@@ -230,6 +449,244 @@ type SecurityConfig struct {
 
 	// PublicKeysDir is where public keys for verification are stored
 	PublicKeysDir string `yaml:"public_keys_dir" mapstructure:"public_keys_dir"`
+
+	// AppArmorProfile is the default AppArmor profile for app processes (overridable in manifests)
+	AppArmorProfile string `yaml:"apparmor_profile" mapstructure:"apparmor_profile"`
+
+	// SELinuxContext is the default SELinux context for app processes (overridable in manifests)
+	SELinuxContext string `yaml:"selinux_context" mapstructure:"selinux_context"`
+
+	// RequireMAC refuses to start an app unless an AppArmor profile or SELinux
+	// context is configured for it and can be applied (fail closed)
+	RequireMAC bool `yaml:"require_mac" mapstructure:"require_mac"`
+
+	// AppUsers selects the unprivileged users app processes run as
+	AppUsers AppUsersConfig `yaml:"app_users" mapstructure:"app_users"`
+
+	// DisableAppOwnership disables binding app names to the key that first signed them
+	// (default: false, a different key cannot take over an existing app name)
+	DisableAppOwnership bool `yaml:"disable_app_ownership" mapstructure:"disable_app_ownership"`
+
+	// RecordSessions records who read the logs of which app, and a hash of
+	// what they were sent, in the audit log (default: false)
+	RecordSessions bool `yaml:"record_sessions" mapstructure:"record_sessions"`
+
+	// RateLimit limits the requests each peer may make to the daemon
+	RateLimit RateLimitConfig `yaml:"rate_limit" mapstructure:"rate_limit"`
+}
+
+// RateLimitConfig limits the protocol streams each remote peer may open,
+// across all protocols. Controllers on the local socket are not limited.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the average rate of requests a peer may make (0 disables)
+	RequestsPerSecond float64 `yaml:"requests_per_second" mapstructure:"requests_per_second"`
+
+	// Burst is the number of requests a peer may make at once (default: 20)
+	Burst int `yaml:"burst" mapstructure:"burst"`
+}
+
+// AppUsersConfig selects the user app processes run as.
+// Running apps as other users requires the daemon to run as root.
+type AppUsersConfig struct {
+	// Mode is "" (run as the daemon user), "shared" (one user for all apps)
+	// or "per_app" (a dedicated UID/GID per app name)
+	Mode string `yaml:"mode" mapstructure:"mode"`
+
+	// User is the account for shared mode (default: p2p-apps); it is created
+	// with useradd if it does not exist
+	User string `yaml:"user" mapstructure:"user"`
+
+	// UIDBase is the first UID/GID allocated in per_app mode (default: 200000).
+	// Per-app IDs need no account and must not overlap real users.
+	UIDBase int `yaml:"uid_base" mapstructure:"uid_base"`
+
+	// UIDCount is the number of IDs available in per_app mode (default: 10000)
+	UIDCount int `yaml:"uid_count" mapstructure:"uid_count"`
+}
+
+// AdmissionConfig contains pre-deploy admission hook configuration.
+// Hooks receive the manifest and deployment metadata as JSON and can reject a deployment.
+type AdmissionConfig struct {
+	// Script is an executable that reads the admission request on stdin (non-zero exit rejects)
+	Script string `yaml:"script" mapstructure:"script"`
+
+	// Webhook is a URL that receives the admission request as a POST and replies with {"allowed": bool, "reason": string}
+	Webhook string `yaml:"webhook" mapstructure:"webhook"`
+
+	// Timeout bounds each hook invocation (default: 10s)
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
+
+	// FailOpen admits deployments when a hook cannot be evaluated (default: false, reject)
+	FailOpen bool `yaml:"fail_open" mapstructure:"fail_open"`
+}
+
+// PolicyConfig contains built-in manifest policy rules.
+// The same rules are checked by the controller before a deployment and enforced by the daemon.
+type PolicyConfig struct {
+	// MaxMemoryMB is the maximum memory limit an application may declare (0 disables the rule)
+	MaxMemoryMB int64 `yaml:"max_memory_mb" mapstructure:"max_memory_mb"`
+
+	// MaxCPUPercent is the maximum CPU limit an application may declare (0 disables the rule)
+	MaxCPUPercent float64 `yaml:"max_cpu_percent" mapstructure:"max_cpu_percent"`
+
+	// RequiredLabels are label keys every manifest must set
+	RequiredLabels []string `yaml:"required_labels" mapstructure:"required_labels"`
+
+	// ForbiddenEnvKeys are environment variable names (glob patterns) manifests may not set
+	ForbiddenEnvKeys []string `yaml:"forbidden_env_keys" mapstructure:"forbidden_env_keys"`
+
+	// AllowedSigners restricts which signing keys may deploy which applications
+	AllowedSigners []SignerRule `yaml:"allowed_signers" mapstructure:"allowed_signers"`
+}
+
+// SignerRule lists the signers allowed to deploy applications matching App
+type SignerRule struct {
+	// App is an application name glob pattern (e.g. "payments-*")
+	App string `yaml:"app" mapstructure:"app"`
+
+	// Signers are key names (public key file name without .pub) or hex-encoded public keys
+	Signers []string `yaml:"signers" mapstructure:"signers"`
+}
+
+// KVConfig contains the key-value stores: the per-app store apps reach through
+// pkg/appsdk and the cluster-wide store operators reach through `controller kv`
+type KVConfig struct {
+	// Disable turns the per-app key-value API off (default: false)
+	Disable bool `yaml:"disable" mapstructure:"disable"`
+
+	// Replicate shares per-app writes with the other nodes over gossipsub, so
+	// an app sees the same data on every node (default: false, node-local)
+	Replicate bool `yaml:"replicate" mapstructure:"replicate"`
+
+	// DisableCluster turns the cluster-wide store off (default: false)
+	DisableCluster bool `yaml:"disable_cluster" mapstructure:"disable_cluster"`
+}
+
+// HistoryConfig contains the retention of recent app status samples and
+// events, queried with `controller status --history`
+type HistoryConfig struct {
+	// Disable stops recording app history (default: false)
+	Disable bool `yaml:"disable" mapstructure:"disable"`
+
+	// Interval is how often the status of each app is sampled (default: 10s)
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
+
+	// Size is how many samples and events are kept per app (default: 1080, 3h at 10s)
+	Size int `yaml:"size" mapstructure:"size"`
+
+	// Persist keeps history in the data directory across daemon restarts (default: false, memory only)
+	Persist bool `yaml:"persist" mapstructure:"persist"`
+}
+
+// EventsConfig contains the retention of the log of app lifecycle events
+// kept in the data directory, queried with `controller events`
+type EventsConfig struct {
+	// Disable stops logging app events (default: false)
+	Disable bool `yaml:"disable" mapstructure:"disable"`
+
+	// Size is how many events of all apps are kept (default: 10000)
+	Size int `yaml:"size" mapstructure:"size"`
+}
+
+// QuotaConfig limits what each operator, identified by the key signing its
+// packages, may deploy to the node, so one user cannot monopolize a shared
+// playground. Unsigned packages all count towards the operator "unsigned".
+type QuotaConfig struct {
+	// Default applies to operators without an entry in Operators
+	Default QuotaLimits `yaml:"default" mapstructure:"default"`
+
+	// Operators overrides the limits of single operators, by signing key
+	// name, hex public key or "unsigned"
+	Operators map[string]QuotaLimits `yaml:"operators" mapstructure:"operators"`
+}
+
+// QuotaLimits are the limits of an operator. Zero limits are unlimited.
+type QuotaLimits struct {
+	// MaxApps is how many apps the operator may have deployed at once
+	MaxApps int `yaml:"max_apps" mapstructure:"max_apps"`
+
+	// MaxPackageBytes is the total package size of those apps
+	MaxPackageBytes int64 `yaml:"max_package_bytes" mapstructure:"max_package_bytes"`
+
+	// MaxDeploysPerHour is how many deployments the operator may make within an hour
+	MaxDeploysPerHour int `yaml:"max_deploys_per_hour" mapstructure:"max_deploys_per_hour"`
+}
+
+// AlertsConfig contains simple alert rules evaluated by the daemon. While any
+// alert fires, the node announces itself as degraded.
+type AlertsConfig struct {
+	// AppDownAfter fires when an app has been down (exited and not restarted)
+	// for longer than this (0 disables the rule)
+	AppDownAfter time.Duration `yaml:"app_down_after" mapstructure:"app_down_after"`
+
+	// MaxRestartsPerHour fires when an app is started more often than this
+	// within an hour (0 disables the rule)
+	MaxRestartsPerHour int `yaml:"max_restarts_per_hour" mapstructure:"max_restarts_per_hour"`
+
+	// MaxDiskPercent fires when the filesystem of the data directory is fuller
+	// than this percentage (0 disables the rule)
+	MaxDiskPercent float64 `yaml:"max_disk_percent" mapstructure:"max_disk_percent"`
+
+	// Interval is how often the rules are evaluated (default: 30s)
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
+
+	// Webhook is a URL that receives a JSON POST whenever an alert fires or resolves
+	Webhook string `yaml:"webhook" mapstructure:"webhook"`
+
+	// Timeout bounds each webhook request (default: 10s)
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
+}
+
+// BackupConfig contains the backups of the volumes of apps. Each backup is
+// a snapshot of the volumes of an app, kept on the node and sent to the
+// configured peers and S3 bucket, from which `controller restore` restores it.
+type BackupConfig struct {
+	// Interval is how often the volumes of every deployed app that has
+	// volumes are backed up (0 disables scheduled backups)
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
+
+	// Keep is how many snapshots of each app are kept on the node, its own
+	// and those other nodes back up to it (default: 5)
+	Keep int `yaml:"keep" mapstructure:"keep"`
+
+	// Peers are the peer IDs of the daemons backups are sent to
+	Peers []string `yaml:"peers" mapstructure:"peers"`
+
+	// S3 is the S3-compatible bucket backups are sent to, if any
+	S3 S3Config `yaml:"s3" mapstructure:"s3"`
+}
+
+// S3Config locates an S3-compatible bucket, e.g. on AWS or MinIO
+type S3Config struct {
+	// Endpoint is the URL of the service, e.g. https://s3.eu-west-1.amazonaws.com
+	// or http://minio:9000 (empty disables the bucket)
+	Endpoint string `yaml:"endpoint" mapstructure:"endpoint"`
+
+	// Region is the region of the bucket (default: us-east-1)
+	Region string `yaml:"region" mapstructure:"region"`
+
+	// Bucket is the name of the bucket
+	Bucket string `yaml:"bucket" mapstructure:"bucket"`
+
+	// Prefix is prepended to the keys of the objects, e.g. "backups/"
+	Prefix string `yaml:"prefix" mapstructure:"prefix"`
+
+	// AccessKeyID and SecretAccessKey are the credentials (default: the
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables)
+	AccessKeyID     string `yaml:"access_key_id" mapstructure:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" mapstructure:"secret_access_key"`
+}
+
+// RegistryConfig locates the package registry, an S3-compatible bucket
+// keeping signed packages by digest. Controllers push packages to it, and
+// daemons deploy packages from it.
+type RegistryConfig struct {
+	// S3 is the bucket of the registry
+	S3 S3Config `yaml:"s3" mapstructure:"s3"`
+
+	// CacheMB bounds the packages a daemon keeps fetched from the registry,
+	// least recently used evicted first (default: 1024)
+	CacheMB int `yaml:"cache_mb" mapstructure:"cache_mb"`
 }
 
 // ControllerConfig contains controller-specific configuration
@@ -248,6 +705,27 @@ type ControllerConfig struct {
 
 	// Deployment contains deployment defaults
 	Deployment DeploymentConfig `yaml:"deployment" mapstructure:"deployment"`
+
+	// Policy contains manifest policy rules checked before deploying
+	Policy PolicyConfig `yaml:"policy" mapstructure:"policy"`
+
+	// PackageCache contains configuration of the cache of built packages
+	PackageCache PackageCacheConfig `yaml:"package_cache" mapstructure:"package_cache"`
+
+	// Registry is the package registry 'controller registry push' uploads to
+	Registry RegistryConfig `yaml:"registry" mapstructure:"registry"`
+}
+
+// PackageCacheConfig contains configuration of the controller package cache,
+// which keeps packages built from app directories for reuse while their
+// sources are unchanged
+type PackageCacheConfig struct {
+	// Disabled builds a package on every run
+	Disabled bool `yaml:"disabled" mapstructure:"disabled"`
+
+	// MaxSizeMB is the size the least recently used packages are evicted
+	// beyond (default: 512)
+	MaxSizeMB int `yaml:"max_size_mb" mapstructure:"max_size_mb"`
 }
 
 // DeploymentConfig contains deployment configuration
@@ -263,12 +741,23 @@ type DeploymentConfig struct {
 
 	// RetryDelay is the delay between retries
 	RetryDelay time.Duration `yaml:"retry_delay" mapstructure:"retry_delay"`
+
+	// Concurrency is how many nodes a deployment to several nodes works on at once
+	Concurrency int `yaml:"concurrency" mapstructure:"concurrency"`
 }
 
 // LoadDaemonConfig loads daemon configuration from a file
 func LoadDaemonConfig(path string) (*DaemonConfig, error) {
 	cfg := New()
 
+	// Diagnostics are on unless the file turns them off
+	cfg.SetDefault("logging.diagnostics.enabled", true)
+	cfg.SetDefault("logging.repeat_window", "1m")
+	cfg.SetDefault("logging.rotation.max_size_mb", 100)
+	cfg.SetDefault("logging.rotation.max_age_days", 30)
+	cfg.SetDefault("logging.rotation.max_backups", 5)
+	cfg.SetDefault("logging.rotation.compress", true)
+
 	// Load from file first if provided
 	if path != "" {
 		if err := cfg.LoadFromFile(path); err != nil {
@@ -276,6 +765,11 @@ func LoadDaemonConfig(path string) (*DaemonConfig, error) {
 		}
 	}
 
+	// The node profile changes defaults, so the file still overrides it
+	if err := applyNodeProfile(cfg.GetViper(), cfg.GetString("node.profile")); err != nil {
+		return nil, err
+	}
+
 	var daemonCfg DaemonConfig
 	if err := cfg.GetViper().Unmarshal(&daemonCfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
@@ -289,6 +783,83 @@ func LoadDaemonConfig(path string) (*DaemonConfig, error) {
 	return &daemonCfg, nil
 }
 
+// EnvPrefix prefixes the environment variables read by LoadDaemonConfigFromEnv
+const EnvPrefix = "P2P"
+
+// LoadDaemonConfigFromEnv loads daemon configuration from environment
+// variables on top of the defaults, for containers configured without files.
+// Each key is read from P2P_<SECTION>_<KEY>, e.g. P2P_NODE_NAME for node.name;
+// lists are comma-separated and maps are comma-separated key=value pairs.
+// The storage directories default to subdirectories of P2P_STORAGE_DATA_DIR.
+func LoadDaemonConfigFromEnv() (*DaemonConfig, error) {
+	var defaults DaemonConfig
+	applyDaemonDefaults(&defaults)
+	if dataDir := os.Getenv(EnvPrefix + "_STORAGE_DATA_DIR"); dataDir != "" {
+		defaults.Storage.DataDir = dataDir
+		defaults.Storage.PackagesDir = filepath.Join(dataDir, "packages")
+		defaults.Storage.AppsDir = filepath.Join(dataDir, "apps")
+		defaults.Storage.KeysDir = filepath.Join(dataDir, "keys")
+	}
+
+	// Viper only reads environment variables for keys it knows about
+	data, err := yaml.Marshal(&defaults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal default config: %w", err)
+	}
+	v := New().GetViper()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to read default config: %w", err)
+	}
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	// The node profile replaces the defaults, but not what the environment sets
+	profile, err := NodeProfileDefaults(v.GetString("node.profile"))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range profile {
+		if _, set := os.LookupEnv(EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))); !set {
+			v.Set(key, value)
+		}
+	}
+	// Empty maps are not keys to viper, so bind them explicitly
+	if err := v.BindEnv("node.labels"); err != nil {
+		return nil, fmt.Errorf("failed to bind environment: %w", err)
+	}
+
+	var daemonCfg DaemonConfig
+	if err := v.Unmarshal(&daemonCfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		stringToMapHook,
+	))); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config from environment: %w", err)
+	}
+	return &daemonCfg, nil
+}
+
+// stringToMapHook decodes "k1=v1,k2=v2" into a map[string]string
+func stringToMapHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(map[string]string{}) {
+		return data, nil
+	}
+	result := make(map[string]string)
+	for _, pair := range strings.Split(data.(string), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid key=value pair %q", pair)
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return result, nil
+}
+
 // LoadControllerConfig loads controller configuration from a file
 func LoadControllerConfig(path string) (*ControllerConfig, error) {
 	cfg := New()
@@ -313,6 +884,16 @@ func LoadControllerConfig(path string) (*ControllerConfig, error) {
 	return &controllerCfg, nil
 }
 
+// defaultListenAddrs returns TCP and QUIC addresses on port for all IPv4 and IPv6 interfaces
+func defaultListenAddrs(port int) []string {
+	return []string{
+		fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port),
+		fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic", port),
+		fmt.Sprintf("/ip6/::/tcp/%d", port),
+		fmt.Sprintf("/ip6/::/udp/%d/quic", port),
+	}
+}
+
 // applyDaemonDefaults applies default values to daemon config after unmarshaling
 func applyDaemonDefaults(cfg *DaemonConfig) {
 	if cfg.Node.Name == "" {
@@ -324,7 +905,7 @@ func applyDaemonDefaults(cfg *DaemonConfig) {
 		}
 	}
 	if len(cfg.Node.ListenAddrs) == 0 {
-		cfg.Node.ListenAddrs = []string{"/ip4/0.0.0.0/tcp/9000", "/ip4/0.0.0.0/udp/9000/quic"}
+		cfg.Node.ListenAddrs = defaultListenAddrs(9000)
 	}
 	// Always set EnableMDNS to true when applying defaults
 	cfg.Node.EnableMDNS = true
@@ -359,6 +940,9 @@ func applyDaemonDefaults(cfg *DaemonConfig) {
 	if cfg.Runtime.LogMaxFiles == 0 {
 		cfg.Runtime.LogMaxFiles = 5
 	}
+	if cfg.Runtime.MaxPackageSize == 0 {
+		cfg.Runtime.MaxPackageSize = 4 << 30
+	}
 	// Always set EnableResourceLimits to true when applying defaults
 	cfg.Runtime.EnableResourceLimits = true
 
@@ -374,6 +958,24 @@ func applyDaemonDefaults(cfg *DaemonConfig) {
 	if cfg.Logging.ErrorOutputPath == "" {
 		cfg.Logging.ErrorOutputPath = "stderr"
 	}
+	if cfg.Logging.Rotation.MaxSizeMB == 0 {
+		cfg.Logging.Rotation.MaxSizeMB = 100
+	}
+	if cfg.Logging.Rotation.MaxAgeDays == 0 {
+		cfg.Logging.Rotation.MaxAgeDays = 30
+	}
+	if cfg.Logging.Rotation.MaxBackups == 0 {
+		cfg.Logging.Rotation.MaxBackups = 5
+	}
+	// Rotated daemon logs are compressed unless configured otherwise
+	cfg.Logging.Rotation.Compress = true
+	cfg.Logging.Diagnostics.Enabled = true
+	if cfg.Logging.Diagnostics.Interval == 0 {
+		cfg.Logging.Diagnostics.Interval = 30 * time.Second
+	}
+	if cfg.Logging.RepeatWindow == 0 {
+		cfg.Logging.RepeatWindow = time.Minute
+	}
 
 	if cfg.Security.AuthMethod == "" {
 		cfg.Security.AuthMethod = "psk"
@@ -391,7 +993,7 @@ func applyControllerDefaults(cfg *ControllerConfig) {
 		}
 	}
 	if len(cfg.Node.ListenAddrs) == 0 {
-		cfg.Node.ListenAddrs = []string{"/ip4/0.0.0.0/tcp/9001", "/ip4/0.0.0.0/udp/9001/quic"}
+		cfg.Node.ListenAddrs = defaultListenAddrs(9001)
 	}
 	// Always set EnableMDNS to true when applying defaults
 	cfg.Node.EnableMDNS = true
@@ -418,7 +1020,7 @@ func applyControllerDefaults(cfg *ControllerConfig) {
 		cfg.Logging.Format = "console"
 	}
 	if cfg.Logging.OutputPath == "" {
-		cfg.Logging.OutputPath = "stdout"
+		cfg.Logging.OutputPath = "stderr"
 	}
 	if cfg.Logging.ErrorOutputPath == "" {
 		cfg.Logging.ErrorOutputPath = "stderr"
@@ -440,4 +1042,7 @@ func applyControllerDefaults(cfg *ControllerConfig) {
 	if cfg.Deployment.RetryDelay == 0 {
 		cfg.Deployment.RetryDelay = 10 * time.Second
 	}
+	if cfg.Deployment.Concurrency == 0 {
+		cfg.Deployment.Concurrency = 4
+	}
 }
@@ -3,7 +3,9 @@ package config_test
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/asjdf/p2p-playground-lite/pkg/config"
 )
@@ -134,6 +136,64 @@ security:
 	if cfg.Security.PSK != "test-secret-key" {
 		t.Errorf("got psk=%v, want 'test-secret-key'", cfg.Security.PSK)
 	}
+
+	// Keys the file leaves out keep their defaults
+	if !cfg.Logging.Diagnostics.Enabled {
+		t.Error("expected diagnostics to be enabled by default")
+	}
+	if cfg.Logging.RepeatWindow != time.Minute {
+		t.Errorf("got repeat window=%v, want 1m by default", cfg.Logging.RepeatWindow)
+	}
+	want := config.LogRotationConfig{MaxSizeMB: 100, MaxAgeDays: 30, MaxBackups: 5, Compress: true}
+	if cfg.Logging.Rotation != want {
+		t.Errorf("got rotation=%+v, want %+v by default", cfg.Logging.Rotation, want)
+	}
+}
+
+func TestLoadDaemonConfigRotation(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "daemon.yaml")
+	configContent := `
+logging:
+  rotation:
+    max_backups: 2
+    compress: false
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := config.LoadDaemonConfig(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	want := config.LogRotationConfig{MaxSizeMB: 100, MaxAgeDays: 30, MaxBackups: 2}
+	if cfg.Logging.Rotation != want {
+		t.Errorf("got rotation=%+v, want %+v", cfg.Logging.Rotation, want)
+	}
+}
+
+func TestLoadDaemonConfigDiagnostics(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "daemon.yaml")
+	configContent := `
+logging:
+  diagnostics:
+    enabled: false
+    interval: 5m
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := config.LoadDaemonConfig(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Logging.Diagnostics.Enabled {
+		t.Error("expected diagnostics to be disabled")
+	}
+	if cfg.Logging.Diagnostics.Interval != 5*time.Minute {
+		t.Errorf("got diagnostics interval=%v, want 5m", cfg.Logging.Diagnostics.Interval)
+	}
 }
 
 func TestLoadControllerConfig(t *testing.T) {
@@ -188,6 +248,9 @@ func TestLoadConfigWithDefaults(t *testing.T) {
 	if len(cfg.Node.ListenAddrs) == 0 {
 		t.Error("expected default listen_addrs to be set")
 	}
+	if !slices.Contains(cfg.Node.ListenAddrs, "/ip6/::/tcp/9000") {
+		t.Errorf("got listen_addrs=%v, want IPv6 addresses by default", cfg.Node.ListenAddrs)
+	}
 
 	if !cfg.Node.EnableMDNS {
 		t.Error("expected default enable_mdns to be true")
@@ -196,8 +259,53 @@ func TestLoadConfigWithDefaults(t *testing.T) {
 	if cfg.Runtime.MaxApps != 10 {
 		t.Errorf("got max_apps=%v, want default 10", cfg.Runtime.MaxApps)
 	}
+	if cfg.Runtime.MaxPackageSize != 4<<30 {
+		t.Errorf("got max_package_size=%v, want default 4 GiB", cfg.Runtime.MaxPackageSize)
+	}
 
 	if cfg.Logging.Level != "info" {
 		t.Errorf("got level=%v, want default 'info'", cfg.Logging.Level)
 	}
 }
+
+func TestLoadDaemonConfigFromEnv(t *testing.T) {
+	t.Setenv("P2P_NODE_NAME", "edge-1")
+	t.Setenv("P2P_NODE_LISTEN_ADDRS", "/ip4/0.0.0.0/tcp/4001,/ip4/0.0.0.0/udp/4001/quic")
+	t.Setenv("P2P_NODE_ENABLE_MDNS", "false")
+	t.Setenv("P2P_NODE_LABELS", "env=test, region=eu")
+	t.Setenv("P2P_NODE_STATIC_PEERS", "/ip4/10.0.0.1/tcp/9000/p2p/a,/ip4/10.0.0.2/tcp/9000/p2p/b")
+	t.Setenv("P2P_STORAGE_DATA_DIR", "/data")
+	t.Setenv("P2P_SECURITY_PSK", "secret")
+
+	cfg, err := config.LoadDaemonConfigFromEnv()
+	if err != nil {
+		t.Fatalf("LoadDaemonConfigFromEnv() error = %v", err)
+	}
+
+	if cfg.Node.Name != "edge-1" {
+		t.Errorf("got name=%v, want 'edge-1'", cfg.Node.Name)
+	}
+	if len(cfg.Node.ListenAddrs) != 2 || cfg.Node.ListenAddrs[1] != "/ip4/0.0.0.0/udp/4001/quic" {
+		t.Errorf("got listen_addrs=%v", cfg.Node.ListenAddrs)
+	}
+	if cfg.Node.EnableMDNS {
+		t.Error("expected enable_mdns to be false")
+	}
+	if cfg.Node.Labels["env"] != "test" || cfg.Node.Labels["region"] != "eu" {
+		t.Errorf("got labels=%v", cfg.Node.Labels)
+	}
+	if len(cfg.Node.StaticPeers) != 2 || cfg.Node.StaticPeers[1] != "/ip4/10.0.0.2/tcp/9000/p2p/b" {
+		t.Errorf("got static_peers=%v", cfg.Node.StaticPeers)
+	}
+	if cfg.Storage.KeysDir != filepath.Join("/data", "keys") {
+		t.Errorf("got keys_dir=%v, want '/data/keys'", cfg.Storage.KeysDir)
+	}
+	if cfg.Security.PSK != "secret" {
+		t.Errorf("got psk=%v, want 'secret'", cfg.Security.PSK)
+	}
+
+	// Unset keys keep their defaults
+	if cfg.Runtime.MaxApps != 10 {
+		t.Errorf("got max_apps=%v, want default 10", cfg.Runtime.MaxApps)
+	}
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Deprecation describes a daemon config key that was renamed or removed
+type Deprecation struct {
+	// Key is the dotted path of the deprecated key
+	Key string
+
+	// NewKey is the dotted path of its replacement, or "" if it was removed
+	NewKey string
+
+	// Invert means the replacement is the boolean negation (enable_x became disable_x)
+	Invert bool
+
+	// Reason explains a removal
+	Reason string
+}
+
+// DaemonDeprecations lists the daemon config keys of earlier releases
+var DaemonDeprecations = []Deprecation{
+	{Key: "runtime.app_dir", NewKey: "storage.apps_dir"},
+	{Key: "runtime.log_dir", Reason: "app logs are kept in each app's directory"},
+	{Key: "runtime.default_limits", Reason: "resource limits are set per app in its manifest"},
+	{Key: "node.enable_dht", NewKey: "node.disable_dht", Invert: true},
+	{Key: "node.enable_nat_service", NewKey: "node.disable_nat_service", Invert: true},
+	{Key: "node.enable_auto_relay", NewKey: "node.disable_auto_relay", Invert: true},
+	{Key: "node.enable_hole_punching", NewKey: "node.disable_hole_punching", Invert: true},
+	{Key: "node.enable_relay_service", NewKey: "node.disable_relay_service", Invert: true},
+}
+
+// Migration is the result of migrating a config file
+type Migration struct {
+	// Data is the migrated YAML
+	Data []byte
+
+	// Changes describe each deprecated key that was rewritten or dropped
+	Changes []string
+
+	// Unknown are the dotted paths of keys the current schema does not know
+	Unknown []string
+}
+
+// MigrateDaemonConfig rewrites a daemon config to the current schema,
+// keeping comments and the order of keys. Unknown keys are reported, not removed.
+func MigrateDaemonConfig(data []byte) (*Migration, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse config: %w", types.ErrInvalidInput, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%w: config is empty", types.ErrInvalidInput)
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: config is not a mapping", types.ErrInvalidInput)
+	}
+
+	m := &Migration{}
+	for _, dep := range DaemonDeprecations {
+		change, err := migrateKey(root, dep)
+		if err != nil {
+			return nil, err
+		}
+		if change != "" {
+			m.Changes = append(m.Changes, change)
+		}
+	}
+
+	m.Unknown = unknownKeys(root, reflect.TypeOf(DaemonConfig{}), "")
+	sort.Strings(m.Unknown)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	m.Data = buf.Bytes()
+	return m, nil
+}
+
+// migrateKey applies one deprecation to root and describes the change, or returns "" if the key is absent
+func migrateKey(root *yaml.Node, dep Deprecation) (string, error) {
+	parent, name := lookupParent(root, dep.Key, false)
+	if parent == nil {
+		return "", nil
+	}
+	value := removeKey(parent, name)
+	if value == nil {
+		return "", nil
+	}
+
+	if dep.NewKey == "" {
+		return fmt.Sprintf("%s was removed (%s)", dep.Key, dep.Reason), nil
+	}
+
+	newParent, newName := lookupParent(root, dep.NewKey, true)
+	if newParent == nil {
+		return "", fmt.Errorf("%w: cannot move %s to %s: a parent key is not a mapping", types.ErrInvalidInput, dep.Key, dep.NewKey)
+	}
+	if findKey(newParent, newName) != nil {
+		return fmt.Sprintf("%s was dropped, %s is already set", dep.Key, dep.NewKey), nil
+	}
+
+	if dep.Invert {
+		enabled, err := strconv.ParseBool(value.Value)
+		if value.Kind != yaml.ScalarNode || err != nil {
+			return "", fmt.Errorf("%w: %s must be true or false", types.ErrInvalidInput, dep.Key)
+		}
+		value = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(!enabled)}
+	}
+	newParent.Content = append(newParent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: newName}, value)
+
+	if dep.Invert {
+		return fmt.Sprintf("%s was renamed to %s (inverted)", dep.Key, dep.NewKey), nil
+	}
+	return fmt.Sprintf("%s was renamed to %s", dep.Key, dep.NewKey), nil
+}
+
+// lookupParent returns the mapping holding the last element of a dotted path and that
+// element's name. With create, missing mappings along the path are added.
+func lookupParent(root *yaml.Node, path string, create bool) (*yaml.Node, string) {
+	parts := strings.Split(path, ".")
+	node := root
+	for _, part := range parts[:len(parts)-1] {
+		next := findKey(node, part)
+		if next == nil {
+			if !create {
+				return nil, ""
+			}
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, next)
+		}
+		if next.Kind != yaml.MappingNode {
+			// An empty section ("runtime:") parses as a null scalar
+			if !create || next.Tag != "!!null" {
+				return nil, ""
+			}
+			next.Kind, next.Tag, next.Value = yaml.MappingNode, "!!map", ""
+		}
+		node = next
+	}
+	return node, parts[len(parts)-1]
+}
+
+// findKey returns the value of key in a mapping node, or nil
+func findKey(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// removeKey removes key from a mapping node and returns its value, or nil if absent
+func removeKey(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value := mapping.Content[i+1]
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return value
+		}
+	}
+	return nil
+}
+
+// unknownKeys returns the dotted paths of keys in node that t has no field for
+func unknownKeys(node *yaml.Node, t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			field, ok := fields[key]
+			if !ok {
+				unknown = append(unknown, path)
+				continue
+			}
+			unknown = append(unknown, unknownKeys(node.Content[i+1], field, path)...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			unknown = append(unknown, unknownKeys(node.Content[i+1], t.Elem(), prefix+"."+node.Content[i].Value)...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			unknown = append(unknown, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", prefix, i))...)
+		}
+	}
+	return unknown
+}
+
+// yamlFields maps the yaml keys of a struct to the types of its fields
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
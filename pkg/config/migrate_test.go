@@ -0,0 +1,119 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestMigrateDaemonConfig(t *testing.T) {
+	old := `# test node
+node:
+  name: old-node
+  enable_dht: false # no DHT here
+  enable_relay_service: true
+runtime:
+  app_dir: /srv/apps
+  log_dir: /var/log/p2p
+  max_apps: 3
+storage:
+  data_dir: /srv
+`
+
+	m, err := config.MigrateDaemonConfig([]byte(old))
+	if err != nil {
+		t.Fatalf("MigrateDaemonConfig() error = %v", err)
+	}
+	if len(m.Changes) != 4 {
+		t.Errorf("Changes = %v, want 4", m.Changes)
+	}
+	if len(m.Unknown) != 0 {
+		t.Errorf("Unknown = %v, want none", m.Unknown)
+	}
+	if !strings.Contains(string(m.Data), "# test node") {
+		t.Errorf("comments were lost:\n%s", m.Data)
+	}
+
+	// The migrated file loads with the renamed settings
+	path := filepath.Join(t.TempDir(), "daemon.yaml")
+	if err := os.WriteFile(path, m.Data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadDaemonConfig(path)
+	if err != nil {
+		t.Fatalf("LoadDaemonConfig() error = %v", err)
+	}
+	if !cfg.Node.DisableDHT || cfg.Node.DisableRelayService {
+		t.Errorf("DisableDHT = %v, DisableRelayService = %v; want true, false", cfg.Node.DisableDHT, cfg.Node.DisableRelayService)
+	}
+	if cfg.Storage.AppsDir != "/srv/apps" || cfg.Storage.DataDir != "/srv" || cfg.Runtime.MaxApps != 3 {
+		t.Errorf("Storage = %+v, MaxApps = %d", cfg.Storage, cfg.Runtime.MaxApps)
+	}
+
+	// Migrating again changes nothing
+	again, err := config.MigrateDaemonConfig(m.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Changes) != 0 || string(again.Data) != string(m.Data) {
+		t.Errorf("second migration changed the config: %v", again.Changes)
+	}
+}
+
+func TestMigrateDaemonConfigKeepsNewKey(t *testing.T) {
+	m, err := config.MigrateDaemonConfig([]byte("runtime:\n  app_dir: /old\nstorage:\n  apps_dir: /new\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(m.Data), "/old") || !strings.Contains(string(m.Data), "/new") {
+		t.Errorf("migrated config =\n%s\nwant only the current key", m.Data)
+	}
+}
+
+func TestMigrateDaemonConfigUnknownKeys(t *testing.T) {
+	data := `node:
+  name: n
+  colour: blue
+  labels:
+    anything: goes
+policy:
+  allowed_signers:
+    - app: payments-*
+      signers: [ci]
+      bogus: 1
+typo_section: {}
+`
+	m, err := config.MigrateDaemonConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"node.colour", "policy.allowed_signers[0].bogus", "typo_section"}
+	if !reflect.DeepEqual(m.Unknown, want) {
+		t.Errorf("Unknown = %v, want %v", m.Unknown, want)
+	}
+}
+
+func TestMigrateDaemonConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"not a mapping", "- a\n- b\n"},
+		{"not a bool", "node:\n  enable_dht: sometimes\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := config.MigrateDaemonConfig([]byte(tt.data)); !errors.Is(err, types.ErrInvalidInput) {
+				t.Errorf("MigrateDaemonConfig() error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
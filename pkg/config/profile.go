@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/spf13/viper"
+)
+
+// ProfileHardened is the security profile for nodes on untrusted networks
+const ProfileHardened = "hardened"
+
+// ProfileEdge is the node profile for small devices such as ARM boards
+const ProfileEdge = "edge"
+
+// NodeProfileDefaults returns the defaults node.profile sets, by config key.
+// The edge profile keeps the footprint of the daemon small: the DHT in
+// client mode, fewer connections, announcements every minute instead of
+// every 10 seconds, 16 KiB transfer chunks, warn level logs, and no app
+// status sampling.
+func NodeProfileDefaults(profile string) (map[string]interface{}, error) {
+	switch profile {
+	case "":
+		return nil, nil
+	case ProfileEdge:
+		return map[string]interface{}{
+			"node.dht_mode":            "client",
+			"node.max_connections":     64,
+			"node.announce_interval":   time.Minute,
+			"node.transfer_chunk_size": 16 * 1024,
+			"logging.level":            "warn",
+			"history.disable":          true,
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: node.profile must be empty or %s, got %q", types.ErrInvalidInput, ProfileEdge, profile)
+	}
+}
+
+// applyNodeProfile makes the defaults of profile the defaults of v, below
+// what its config file sets
+func applyNodeProfile(v *viper.Viper, profile string) error {
+	defaults, err := NodeProfileDefaults(profile)
+	if err != nil {
+		return err
+	}
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	return nil
+}
+
+// Enforced is a setting a security profile enforced
+type Enforced struct {
+	// Key is the config key, e.g. "security.allow_unsigned_packages"
+	Key string `json:"key"`
+
+	// Value is the enforced value
+	Value interface{} `json:"value"`
+
+	// Changed is true if the config file set it otherwise
+	Changed bool `json:"changed"`
+}
+
+// ApplySecurityProfile applies security.profile to the daemon config and
+// returns what it enforced. The hardened profile requires signed packages, a
+// PSK and trusted-peer gating, and disables the relay service and the
+// fallback to the public IPFS bootstrap nodes. Settings it cannot choose a
+// value for, the PSK and the trusted peers, must be configured already.
+func (c *DaemonConfig) ApplySecurityProfile() ([]Enforced, error) {
+	switch c.Security.Profile {
+	case "":
+		return nil, nil
+	case ProfileHardened:
+	default:
+		return nil, fmt.Errorf("%w: security.profile must be empty or %s, got %q", types.ErrInvalidInput, ProfileHardened, c.Security.Profile)
+	}
+
+	if c.Security.PSK == "" {
+		return nil, fmt.Errorf("%w: the %s security profile requires security.psk", types.ErrInvalidInput, ProfileHardened)
+	}
+	if len(c.Security.TrustedPeers) == 0 {
+		return nil, fmt.Errorf("%w: the %s security profile requires security.trusted_peers", types.ErrInvalidInput, ProfileHardened)
+	}
+
+	var enforced []Enforced
+	enforce := func(key string, setting *bool, value bool) {
+		enforced = append(enforced, Enforced{Key: key, Value: value, Changed: *setting != value})
+		*setting = value
+	}
+	enforce("security.allow_unsigned_packages", &c.Security.AllowUnsignedPackages, false)
+	enforce("security.enable_auth", &c.Security.EnableAuth, true)
+	enforce("node.disable_public_bootstrap", &c.Node.DisablePublicBootstrap, true)
+	enforce("node.disable_relay_service", &c.Node.DisableRelayService, true)
+	enforced = append(enforced, Enforced{Key: "security.trusted_peers", Value: len(c.Security.TrustedPeers)})
+	return enforced, nil
+}
@@ -0,0 +1,129 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestApplySecurityProfileHardened(t *testing.T) {
+	cfg := &config.DaemonConfig{}
+	cfg.Security.Profile = config.ProfileHardened
+	cfg.Security.PSK = "a1b2c3"
+	cfg.Security.TrustedPeers = []string{"12D3KooWExample"}
+	cfg.Security.AllowUnsignedPackages = true
+	cfg.Node.DisableRelayService = true
+
+	enforced, err := cfg.ApplySecurityProfile()
+	if err != nil {
+		t.Fatalf("ApplySecurityProfile() error = %v", err)
+	}
+	if cfg.Security.AllowUnsignedPackages || !cfg.Security.EnableAuth || !cfg.Node.DisablePublicBootstrap || !cfg.Node.DisableRelayService {
+		t.Errorf("ApplySecurityProfile() left %+v %+v", cfg.Security, cfg.Node)
+	}
+
+	changed := make(map[string]bool)
+	for _, e := range enforced {
+		changed[e.Key] = e.Changed
+	}
+	want := map[string]bool{
+		"security.allow_unsigned_packages": true,
+		"security.enable_auth":             true,
+		"node.disable_public_bootstrap":    true,
+		"node.disable_relay_service":       false,
+		"security.trusted_peers":           false,
+	}
+	if len(changed) != len(want) {
+		t.Errorf("enforced %v, want %v", changed, want)
+	}
+	for key, c := range want {
+		if got, ok := changed[key]; !ok || got != c {
+			t.Errorf("enforced %s changed = %v (reported %v), want %v", key, got, ok, c)
+		}
+	}
+}
+
+func TestApplySecurityProfileRequirements(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.SecurityConfig
+	}{
+		{"unknown profile", config.SecurityConfig{Profile: "paranoid"}},
+		{"no psk", config.SecurityConfig{Profile: config.ProfileHardened, TrustedPeers: []string{"12D3KooWExample"}}},
+		{"no trusted peers", config.SecurityConfig{Profile: config.ProfileHardened, PSK: "a1b2c3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.DaemonConfig{Security: tt.cfg}
+			if _, err := cfg.ApplySecurityProfile(); !errors.Is(err, types.ErrInvalidInput) {
+				t.Errorf("ApplySecurityProfile() error = %v, want %v", err, types.ErrInvalidInput)
+			}
+		})
+	}
+
+	cfg := &config.DaemonConfig{}
+	cfg.Security.AllowUnsignedPackages = true
+	if enforced, err := cfg.ApplySecurityProfile(); err != nil || enforced != nil || !cfg.Security.AllowUnsignedPackages {
+		t.Errorf("ApplySecurityProfile() without a profile = %v, %v, want no change", enforced, err)
+	}
+}
+
+func TestEdgeProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.yaml")
+	content := `
+node:
+  profile: edge
+  max_connections: 32
+logging:
+  format: json
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.LoadDaemonConfig(path)
+	if err != nil {
+		t.Fatalf("LoadDaemonConfig() error = %v", err)
+	}
+	if cfg.Node.DHTMode != "client" || cfg.Node.AnnounceInterval != time.Minute || cfg.Node.TransferChunkSize != 16*1024 {
+		t.Errorf("edge profile left node %+v", cfg.Node)
+	}
+	if cfg.Logging.Level != "warn" || !cfg.History.Disable {
+		t.Errorf("edge profile left logging level %q and history disable %v", cfg.Logging.Level, cfg.History.Disable)
+	}
+	// What the file sets wins over the profile
+	if cfg.Node.MaxConnections != 32 {
+		t.Errorf("max_connections = %d, want 32 from the file", cfg.Node.MaxConnections)
+	}
+}
+
+func TestEdgeProfileFromEnv(t *testing.T) {
+	t.Setenv("P2P_NODE_PROFILE", "edge")
+	t.Setenv("P2P_LOGGING_LEVEL", "debug")
+
+	cfg, err := config.LoadDaemonConfigFromEnv()
+	if err != nil {
+		t.Fatalf("LoadDaemonConfigFromEnv() error = %v", err)
+	}
+	if cfg.Node.DHTMode != "client" || cfg.Node.MaxConnections != 64 || !cfg.History.Disable {
+		t.Errorf("edge profile left node %+v, history disable %v", cfg.Node, cfg.History.Disable)
+	}
+	if cfg.Logging.Level != "debug" {
+		t.Errorf("logging level = %q, want debug from the environment", cfg.Logging.Level)
+	}
+}
+
+func TestUnknownNodeProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.yaml")
+	if err := os.WriteFile(path, []byte("node:\n  profile: tiny\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.LoadDaemonConfig(path); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("LoadDaemonConfig() error = %v, want %v", err, types.ErrInvalidInput)
+	}
+}
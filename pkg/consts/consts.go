@@ -1,15 +1,108 @@
 package consts
 
+import "time"
+
 // P2P protocol IDs
 const (
 	// DeployProtocolID is the protocol ID for application deployment
 	DeployProtocolID = "/p2p-playground/deploy/1.0.0"
 
+	// DeployProtocolV2ID is version 2 of the deploy protocol, sending the
+	// package in CRC32-checked frames (transfer.FrameWriter)
+	DeployProtocolV2ID = "/p2p-playground/deploy/2.0.0"
+
 	// ListProtocolID is the protocol ID for listing applications
 	ListProtocolID = "/p2p-playground/list/1.0.0"
 
+	// ListProtocolV2ID is version 2 of the list protocol, reporting the spec
+	// and runtime status of each application apart (types.AppRecord)
+	ListProtocolV2ID = "/p2p-playground/list/2.0.0"
+
+	// ListProtocolV3ID is version 3 of the list protocol, taking a request
+	// that pages the records by a cursor and selects their fields, and
+	// streaming the pages in frames of their own
+	ListProtocolV3ID = "/p2p-playground/list/3.0.0"
+
 	// LogsProtocolID is the protocol ID for fetching application logs
 	LogsProtocolID = "/p2p-playground/logs/1.0.0"
+
+	// OwnershipProtocolID is the protocol ID for inspecting application ownership
+	OwnershipProtocolID = "/p2p-playground/ownership/1.0.0"
+
+	// AuditProtocolID is the protocol ID for fetching the deployment transparency log
+	AuditProtocolID = "/p2p-playground/audit/1.0.0"
+
+	// HistoryProtocolID is the protocol ID for fetching recent app status samples and events
+	HistoryProtocolID = "/p2p-playground/history/1.0.0"
+
+	// KVProtocolID is the protocol ID for the cluster-wide key-value store
+	KVProtocolID = "/p2p-playground/kv/1.0.0"
+
+	// MessagingProtocolID is the protocol ID for app-to-app messages routed by daemons
+	MessagingProtocolID = "/p2p-playground/msg/1.0.0"
+
+	// PeerExchangeProtocolID is the protocol ID for exchanging known cluster peers
+	PeerExchangeProtocolID = "/p2p-playground/pex/1.0.0"
+
+	// NodeInfoProtocolID is the protocol ID for fetching node information and quota usage
+	NodeInfoProtocolID = "/p2p-playground/node-info/1.0.0"
+
+	// VerifyProtocolID is the protocol ID for checking deployed application files against their checksums
+	VerifyProtocolID = "/p2p-playground/verify/1.0.0"
+
+	// EventsProtocolID is the protocol ID for querying the app lifecycle events logged by a node
+	EventsProtocolID = "/p2p-playground/events/1.0.0"
+
+	// RollbackProtocolID is the protocol ID for listing the revisions of an application and redeploying one
+	RollbackProtocolID = "/p2p-playground/rollback/1.0.0"
+
+	// TransferProtocolID is the protocol ID for sending packages between nodes in chunks
+	TransferProtocolID = "/p2p-playground/transfer/1.0.0"
+
+	// LabelProtocolID is the protocol ID for setting and removing the labels of a deployed application
+	LabelProtocolID = "/p2p-playground/label/1.0.0"
+
+	// ControlProtocolID is the protocol ID for pausing and resuming deployed applications
+	ControlProtocolID = "/p2p-playground/control/1.0.0"
+
+	// CoreDumpProtocolID is the protocol ID for listing and fetching the core dumps of crashed applications
+	CoreDumpProtocolID = "/p2p-playground/coredump/1.0.0"
+
+	// BenchProtocolID is the protocol ID for measuring the throughput of the connection between nodes
+	BenchProtocolID = "/p2p-playground/bench/1.0.0"
+
+	// BackupProtocolID is the protocol ID for backing up, listing and restoring snapshots of app volumes
+	BackupProtocolID = "/p2p-playground/backup/1.0.0"
+)
+
+// Pubsub topics and service tags
+const (
+	// DiscoveryTopic is the pubsub topic nodes announce themselves on, and the
+	// name they advertise under in the DHT
+	DiscoveryTopic = "p2p-playground/discovery"
+
+	// AppKVTopic is the pubsub topic carrying app key-value store updates
+	AppKVTopic = "p2p-playground/kv"
+
+	// ClusterKVTopic is the pubsub topic carrying cluster key-value store updates
+	ClusterKVTopic = "p2p-playground/cluster-kv"
+
+	// AppTopicPrefix keeps the topics of apps apart from the topics daemons use themselves
+	AppTopicPrefix = "p2p-playground/app/"
+
+	// MDNSServiceName is the service nodes advertise and look for with multicast DNS
+	MDNSServiceName = "p2p-playground"
+)
+
+// Protocol timing
+const (
+	// DeployHeartbeatInterval is how often a node reports that a deployment is
+	// still in progress when it has nothing else to report
+	DeployHeartbeatInterval = 5 * time.Second
+
+	// DeployHeartbeatTimeout is how long the controller waits for the next status
+	// of a deployment before it considers the node dead
+	DeployHeartbeatTimeout = 3 * DeployHeartbeatInterval
 )
 
 // System service constants
@@ -0,0 +1,83 @@
+package consts
+
+// Capability is a feature nodes serve over a protocol, in one or more versions
+type Capability string
+
+// Capabilities of daemons
+const (
+	CapabilityDeploy       Capability = "deploy"
+	CapabilityList         Capability = "list"
+	CapabilityLogs         Capability = "logs"
+	CapabilityOwnership    Capability = "ownership"
+	CapabilityAudit        Capability = "audit"
+	CapabilityHistory      Capability = "history"
+	CapabilityKV           Capability = "kv"
+	CapabilityMessaging    Capability = "msg"
+	CapabilityPeerExchange Capability = "pex"
+	CapabilityNodeInfo     Capability = "node-info"
+	CapabilityVerify       Capability = "verify"
+	CapabilityEvents       Capability = "events"
+	CapabilityRollback     Capability = "rollback"
+	CapabilityTransfer     Capability = "transfer"
+	CapabilityLabel        Capability = "label"
+	CapabilityControl      Capability = "control"
+	CapabilityCoreDump     Capability = "coredump"
+	CapabilityBench        Capability = "bench"
+	CapabilityBackup       Capability = "backup"
+)
+
+// protocols maps every capability to the IDs of its protocol versions, newest first
+var protocols = map[Capability][]string{
+	CapabilityDeploy:       {DeployProtocolV2ID, DeployProtocolID},
+	CapabilityList:         {ListProtocolV3ID, ListProtocolV2ID, ListProtocolID},
+	CapabilityLogs:         {LogsProtocolID},
+	CapabilityOwnership:    {OwnershipProtocolID},
+	CapabilityAudit:        {AuditProtocolID},
+	CapabilityHistory:      {HistoryProtocolID},
+	CapabilityKV:           {KVProtocolID},
+	CapabilityMessaging:    {MessagingProtocolID},
+	CapabilityPeerExchange: {PeerExchangeProtocolID},
+	CapabilityNodeInfo:     {NodeInfoProtocolID},
+	CapabilityVerify:       {VerifyProtocolID},
+	CapabilityEvents:       {EventsProtocolID},
+	CapabilityRollback:     {RollbackProtocolID},
+	CapabilityTransfer:     {TransferProtocolID},
+	CapabilityLabel:        {LabelProtocolID},
+	CapabilityControl:      {ControlProtocolID},
+	CapabilityCoreDump:     {CoreDumpProtocolID},
+	CapabilityBench:        {BenchProtocolID},
+	CapabilityBackup:       {BackupProtocolID},
+}
+
+// Versions returns the protocol IDs of c, newest first, or nil for an unknown capability
+func Versions(c Capability) []string {
+	return append([]string(nil), protocols[c]...)
+}
+
+// Negotiate returns the newest protocol ID of c that supports reports the
+// remote node serves. When it reports none, the oldest version is returned,
+// as the one nodes that predate the others serve.
+func Negotiate(c Capability, supports func(protocolID string) bool) string {
+	versions := protocols[c]
+	if len(versions) == 0 {
+		return ""
+	}
+	for _, id := range versions {
+		if supports(id) {
+			return id
+		}
+	}
+	return versions[len(versions)-1]
+}
+
+// CapabilityOf returns the capability served over protocolID
+func CapabilityOf(protocolID string) (Capability, bool) {
+	for c, versions := range protocols {
+		for _, id := range versions {
+			if id == protocolID {
+				return c, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,112 @@
+package consts_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+)
+
+// protocolConst matches the names of protocol ID constants
+var protocolConst = regexp.MustCompile(`ProtocolV?[0-9]*ID$`)
+
+// capabilityConst matches the names of capability constants
+var capabilityConst = regexp.MustCompile(`^Capability[A-Z]`)
+
+// declaredConsts returns the string constants declared in the package
+// source, by name
+func declaredConsts(t *testing.T) map[string]string {
+	t.Helper()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	if err != nil {
+		t.Fatalf("parsing package: %v", err)
+	}
+
+	result := make(map[string]string)
+	for _, file := range pkgs["consts"].Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.ValueSpec)
+			if !ok {
+				return true
+			}
+			for i, name := range spec.Names {
+				if i >= len(spec.Values) {
+					continue
+				}
+				if lit, ok := spec.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					value, _ := strconv.Unquote(lit.Value)
+					result[name.Name] = value
+				}
+			}
+			return true
+		})
+	}
+	return result
+}
+
+func TestRegistryIsExhaustive(t *testing.T) {
+	declared := declaredConsts(t)
+
+	found := 0
+	for name, value := range declared {
+		switch {
+		case protocolConst.MatchString(name):
+			found++
+			if _, ok := consts.CapabilityOf(value); !ok {
+				t.Errorf("%s (%s) is not registered with a capability", name, value)
+			}
+		case capabilityConst.MatchString(name):
+			if len(consts.Versions(consts.Capability(value))) == 0 {
+				t.Errorf("%s (%s) has no protocol versions", name, value)
+			}
+		}
+	}
+	if found == 0 {
+		t.Fatal("no protocol ID constants found")
+	}
+}
+
+func TestRegistryIDsAreUnique(t *testing.T) {
+	seen := make(map[string]string)
+	for name, value := range declaredConsts(t) {
+		if !protocolConst.MatchString(name) {
+			continue
+		}
+		if other, ok := seen[value]; ok {
+			t.Errorf("%s and %s are both %s", name, other, value)
+		}
+		seen[value] = name
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	none := func(string) bool { return false }
+	all := func(string) bool { return true }
+	onlyV1 := func(id string) bool { return id == consts.ListProtocolID }
+
+	tests := []struct {
+		name     string
+		supports func(string) bool
+		want     string
+	}{
+		{"newest supported", all, consts.ListProtocolV3ID},
+		{"older supported", onlyV1, consts.ListProtocolID},
+		{"nothing known", none, consts.ListProtocolID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := consts.Negotiate(consts.CapabilityList, tt.supports); got != tt.want {
+				t.Errorf("Negotiate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if got := consts.Negotiate("unknown", all); got != "" {
+		t.Errorf("Negotiate() of an unknown capability = %q, want empty", got)
+	}
+}
@@ -0,0 +1,156 @@
+// Package coredump finds the core dumps crashed app processes leave in their
+// working directory. Where the kernel writes a core dump is set by its
+// core_pattern; only patterns naming a file in the working directory of the
+// crashed process can be collected.
+package coredump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// PatternPath is where Linux exposes the core pattern
+const PatternPath = "/proc/sys/kernel/core_pattern"
+
+// Dump is a core dump in the working directory of an application
+type Dump struct {
+	// Name is the file name of the dump in the working directory
+	Name string `json:"name"`
+
+	// Size is the size of the dump in bytes
+	Size int64 `json:"size"`
+
+	// Time is when the dump was written
+	Time time.Time `json:"time"`
+}
+
+// Pattern returns the core pattern of the kernel
+func Pattern() (string, error) {
+	data, err := os.ReadFile(PatternPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read the core pattern: %w", types.ErrUnavailable, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Glob returns the glob matching, in the working directory of a crashed
+// process, the core dumps the kernel names after pattern. It fails with
+// ErrUnavailable if pattern hands dumps to a program, e.g. systemd-coredump,
+// or writes them to another directory.
+func Glob(pattern string) (string, error) {
+	switch {
+	case pattern == "":
+		return "", fmt.Errorf("%w: the core pattern is empty, no core dumps are written", types.ErrUnavailable)
+	case strings.HasPrefix(pattern, "|"):
+		return "", fmt.Errorf("%w: the core pattern %q hands core dumps to a program", types.ErrUnavailable, pattern)
+	case strings.Contains(pattern, "/"):
+		return "", fmt.Errorf("%w: the core pattern %q writes core dumps outside the working directory", types.ErrUnavailable, pattern)
+	}
+
+	var glob strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '%' && i+1 < len(pattern) && pattern[i+1] == '%':
+			glob.WriteByte('%')
+			i++
+		case c == '%':
+			// A specifier, expanded by the kernel
+			glob.WriteByte('*')
+			i++
+		case c == '*' || c == '?' || c == '[' || c == '\\':
+			glob.WriteByte('\\')
+			glob.WriteByte(c)
+		default:
+			glob.WriteByte(c)
+		}
+	}
+	// With core_uses_pid, the kernel appends ".<pid>" to patterns without %p
+	glob.WriteByte('*')
+	return glob.String(), nil
+}
+
+// List returns the core dumps in dir matching glob, newest first. Files
+// matching glob that are no ELF core files, e.g. "core.js", are left out.
+func List(dir, glob string) ([]Dump, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, glob))
+	if err != nil {
+		return nil, fmt.Errorf("%w: core dump glob %q: %w", types.ErrInvalidInput, glob, err)
+	}
+
+	var dumps []Dump
+	for _, path := range matches {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || !isCore(path) {
+			continue
+		}
+		dumps = append(dumps, Dump{Name: info.Name(), Size: info.Size(), Time: info.ModTime()})
+	}
+	sort.Slice(dumps, func(i, j int) bool { return dumps[i].Time.After(dumps[j].Time) })
+	return dumps, nil
+}
+
+// Prune removes the core dumps in dir matching glob but the keep newest
+func Prune(dir, glob string, keep int) error {
+	dumps, err := List(dir, glob)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(dumps); i++ {
+		if err := os.Remove(filepath.Join(dir, dumps[i].Name)); err != nil && !os.IsNotExist(err) {
+			return types.WrapError(err, "failed to remove core dump")
+		}
+	}
+	return nil
+}
+
+// Open opens the core dump called name in dir, which must match glob
+func Open(dir, glob, name string) (*os.File, Dump, error) {
+	dumps, err := List(dir, glob)
+	if err != nil {
+		return nil, Dump{}, err
+	}
+	for _, dump := range dumps {
+		if dump.Name != name {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, Dump{}, types.WrapError(err, "failed to open core dump")
+		}
+		return f, dump, nil
+	}
+	return nil, Dump{}, fmt.Errorf("%w: no core dump %q", types.ErrNotFound, name)
+}
+
+// elfCore is the ELF file type of core files
+const elfCore = 4
+
+// isCore reports whether the file at path is an ELF core file
+func isCore(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+
+	// e_ident (16 bytes), then e_type in the byte order of e_ident[EI_DATA]
+	var header [18]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil || !bytes.Equal(header[:4], []byte("\x7fELF")) {
+		return false
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if header[5] == 2 {
+		order = binary.BigEndian
+	}
+	return order.Uint16(header[16:]) == elfCore
+}
@@ -0,0 +1,108 @@
+package coredump_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/coredump"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{"core", "core*", false},
+		{"core.%p", "core.**", false},
+		{"core-%e-%s-%%", "core-*-*-%*", false},
+		{"crash[1]", "crash\\[1]*", false},
+		{"|/usr/lib/systemd/systemd-coredump %P %u", "", true},
+		{"/var/crash/core.%p", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := coredump.Glob(tt.pattern)
+			if tt.wantErr {
+				if !errors.Is(err, types.ErrUnavailable) {
+					t.Fatalf("Glob() error = %v, want ErrUnavailable", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Glob() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// writeFile writes data to name in dir, last modified at mtime
+func writeFile(t *testing.T, dir, name string, data []byte, mtime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// elfCore returns the start of a little-endian ELF core file
+func elfCore() []byte {
+	header := make([]byte, 64)
+	copy(header, "\x7fELF\x02\x01\x01")
+	header[16] = 4 // ET_CORE
+	return header
+}
+
+func TestListAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeFile(t, dir, "core.100", elfCore(), now.Add(-2*time.Minute))
+	writeFile(t, dir, "core.200", elfCore(), now.Add(-time.Minute))
+	writeFile(t, dir, "core.300", elfCore(), now)
+	writeFile(t, dir, "core.js", []byte("module.exports = {}"), now)
+	exe := elfCore()
+	exe[16] = 2 // ET_EXEC
+	writeFile(t, dir, "core-server", exe, now)
+
+	dumps, err := coredump.List(dir, "core*")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var names []string
+	for _, dump := range dumps {
+		names = append(names, dump.Name)
+	}
+	if len(names) != 3 || names[0] != "core.300" || names[2] != "core.100" {
+		t.Errorf("List() = %v, want the core files newest first", names)
+	}
+
+	if err := coredump.Prune(dir, "core*", 1); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if dumps, _ := coredump.List(dir, "core*"); len(dumps) != 1 || dumps[0].Name != "core.300" {
+		t.Errorf("List() after Prune() = %+v, want core.300 only", dumps)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "core.js")); err != nil {
+		t.Errorf("Prune() removed a file that is no core dump: %v", err)
+	}
+
+	f, dump, err := coredump.Open(dir, "core*", "core.300")
+	if err != nil || dump.Size != 64 {
+		t.Fatalf("Open() = %+v, %v", dump, err)
+	}
+	_ = f.Close()
+	for _, name := range []string{"core.100", "core.js", "../core.300"} {
+		if _, _, err := coredump.Open(dir, "core*", name); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("Open(%q) error = %v, want ErrNotFound", name, err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package crash keeps a panic in one goroutine of the daemon from taking the
+// whole process down. Panics are logged with their stack, counted by
+// goroutine and optionally written to a crash report file.
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
+)
+
+// MaxReports is the number of crash report files kept; older ones are removed
+const MaxReports = 20
+
+// restartDelay is how long Loop waits before running a loop that panicked again
+var restartDelay = time.Second
+
+var (
+	mu        sync.Mutex
+	counts    = make(map[string]uint64)
+	reportDir string
+)
+
+// SetReportDir makes every crash write a report file into dir, where a
+// leading ~/ is the home directory. An empty dir disables the files.
+func SetReportDir(dir string) {
+	if strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[2:])
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	reportDir = dir
+}
+
+// Recover stops a panic, logging and counting it under name. It must be
+// deferred directly, at the top of the goroutine:
+//
+//	defer crash.Recover(logger, "discovery.announce")
+func Recover(logger types.Logger, name string) {
+	if r := recover(); r != nil {
+		Report(logger, name, r, debug.Stack())
+	}
+}
+
+// Go runs fn in a new goroutine that recovers from a panic in it
+func Go(logger types.Logger, name string, fn func()) {
+	go func() {
+		defer Recover(logger, name)
+		fn()
+	}()
+}
+
+// Loop runs fn in a new goroutine, and runs it again after a short delay if it
+// panics, until done is closed. fn is a loop that returns once done is closed;
+// with a nil done, fn runs again after every panic.
+func Loop(done <-chan struct{}, logger types.Logger, name string, fn func()) {
+	go func() {
+		for {
+			if !runRecovered(logger, name, fn) {
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(restartDelay):
+				logger.Warn("restarting goroutine after a panic", "goroutine", name)
+			}
+		}
+	}()
+}
+
+// runRecovered runs fn, reporting whether it panicked
+func runRecovered(logger types.Logger, name string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			Report(logger, name, r, debug.Stack())
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// Report logs and counts a recovered panic of the goroutine name, writing a
+// crash report file if a report directory is set
+func Report(logger types.Logger, name string, value interface{}, stack []byte) {
+	mu.Lock()
+	counts[name]++
+	dir := reportDir
+	mu.Unlock()
+
+	fields := []interface{}{
+		"goroutine", name,
+		"panic", fmt.Sprint(value),
+		"stack", string(stack),
+	}
+	if dir != "" {
+		path, err := writeReport(dir, name, value, stack)
+		if err != nil {
+			logger.Warn("failed to write crash report", "error", err)
+		} else {
+			fields = append(fields, "report", path)
+		}
+	}
+	logger.Error("goroutine panicked", fields...)
+}
+
+// Counts returns the number of panics recovered by goroutine name
+func Counts() map[string]uint64 {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+	result := make(map[string]uint64, len(counts))
+	for name, n := range counts {
+		result[name] = n
+	}
+	return result
+}
+
+// writeReport writes a crash report into dir and removes the oldest reports
+// beyond MaxReports. It returns the path of the report.
+func writeReport(dir string, name string, value interface{}, stack []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	file := fmt.Sprintf("crash-%s-%s.txt", now.Format("20060102T150405.000000000Z"), sanitize(name))
+	path := filepath.Join(dir, file)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "goroutine: %s\n", name)
+	fmt.Fprintf(&b, "time: %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "version: %s\n", version.Get())
+	fmt.Fprintf(&b, "panic: %v\n\n", value)
+	b.Write(stack)
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return "", err
+	}
+
+	// Report names start with the time, so they sort oldest first
+	reports, err := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+	if err == nil && len(reports) > MaxReports {
+		sort.Strings(reports)
+		for _, old := range reports[:len(reports)-MaxReports] {
+			_ = os.Remove(old)
+		}
+	}
+	return path, nil
+}
+
+// sanitize makes a goroutine name safe to use in a file name
+func sanitize(name string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.':
+			return c
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,91 @@
+package crash_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+)
+
+func TestGoRecovers(t *testing.T) {
+	dir := t.TempDir()
+	crash.SetReportDir(dir)
+	t.Cleanup(func() { crash.SetReportDir("") })
+
+	done := make(chan struct{})
+	crash.Go(logging.NewNopLogger(), "test.go", func() {
+		defer close(done)
+		panic("boom")
+	})
+	<-done
+
+	// The report is written after fn's deferred calls, so wait for the count
+	deadline := time.Now().Add(5 * time.Second)
+	for crash.Counts()["test.go"] == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := crash.Counts()["test.go"]; got != 1 {
+		t.Fatalf("Counts()[test.go] = %d, want 1", got)
+	}
+
+	reports, err := filepath.Glob(filepath.Join(dir, "crash-*-test.go.txt"))
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("reports = %v (%v), want one", reports, err)
+	}
+	data, err := os.ReadFile(reports[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"goroutine: test.go", "panic: boom", "crash_test.go"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("report does not contain %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestLoopRestarts(t *testing.T) {
+	runs := make(chan int, 2)
+	n := 0
+	crash.Loop(nil, logging.NewNopLogger(), "test.loop", func() {
+		n++
+		runs <- n
+		if n == 1 {
+			panic("first run")
+		}
+	})
+
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-runs:
+			if got != want {
+				t.Fatalf("run %d, want %d", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("loop did not run a %d. time", want)
+		}
+	}
+	if got := crash.Counts()["test.loop"]; got != 1 {
+		t.Errorf("Counts()[test.loop] = %d, want 1", got)
+	}
+}
+
+func TestLoopStopsWhenDone(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+	runs := make(chan struct{}, 2)
+	crash.Loop(done, logging.NewNopLogger(), "test.done", func() {
+		runs <- struct{}{}
+		panic("stopped")
+	})
+
+	<-runs
+	select {
+	case <-runs:
+		t.Error("loop ran again after done was closed")
+	case <-time.After(1500 * time.Millisecond):
+	}
+}
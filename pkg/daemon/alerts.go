@@ -0,0 +1,124 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/alert"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// appEvent handles an application lifecycle event reported by the runtime
+func (d *Daemon) appEvent(app *types.Application, event types.AppEvent, message string) {
+	d.recordEvent(app, event, message, app.Initiator)
+	d.recordTransition(app, event)
+	if d.alerts != nil && event == types.AppEventStarted {
+		d.alerts.Started(app.ID, time.Now())
+	}
+}
+
+// evaluateAlerts checks the alert rules at the configured interval until the daemon stops
+func (d *Daemon) evaluateAlerts() {
+	interval := d.config.Alerts.Interval
+	if interval <= 0 {
+		interval = alert.DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		apps, err := d.runtime.List(d.ctx)
+		if err != nil {
+			continue
+		}
+		diskPercent := -1.0
+		if d.config.Alerts.MaxDiskPercent > 0 {
+			if diskPercent, err = alert.DiskUsage(d.config.Storage.DataDir); err != nil {
+				d.logger.Warn("failed to check disk usage", "error", err)
+				diskPercent = -1
+			}
+		}
+
+		changes := d.alerts.Evaluate(time.Now(), apps, diskPercent)
+		if len(changes) == 0 {
+			continue
+		}
+		for _, change := range changes {
+			d.notifyAlert(change)
+		}
+
+		if d.discovery != nil {
+			d.discovery.SetAlerts(d.activeAlerts())
+		}
+	}
+}
+
+// activeAlerts summarizes the alerts firing on the node
+func (d *Daemon) activeAlerts() []string {
+	if d.alerts == nil {
+		return nil
+	}
+	active := d.alerts.Active()
+	summaries := make([]string, 0, len(active))
+	for _, a := range active {
+		summaries = append(summaries, a.String())
+	}
+	return summaries
+}
+
+// discoveryHealthChanged raises a node alert while discovery is degraded
+func (d *Daemon) discoveryHealthChanged(health discovery.Health) {
+	change := alert.Change{
+		State: alert.StateResolved,
+		Alert: &alert.Alert{
+			Rule:    alert.RuleDiscovery,
+			Message: "no peers on the discovery topic, the node list is stale",
+			Since:   health.Since,
+		},
+	}
+	if health.Degraded {
+		change.State = alert.StateFiring
+	}
+	d.notifyAlert(change)
+}
+
+// notifyAlert logs an alert that fired or resolved, records it in the app's
+// history and sends it to the webhook, if configured
+func (d *Daemon) notifyAlert(change alert.Change) {
+	a := change.Alert
+	if change.State == alert.StateFiring {
+		d.logger.Warn("alert firing", "rule", a.Rule, "app_id", a.AppID, "message", a.Message)
+	} else {
+		d.logger.Info("alert resolved", "rule", a.Rule, "app_id", a.AppID, "message", a.Message)
+	}
+
+	if a.AppID != "" {
+		d.recordEvent(&types.Application{AppSpec: types.AppSpec{ID: a.AppID}}, types.AppEventAlert, change.State+": "+a.Rule+": "+a.Message, nil)
+	}
+
+	if d.config.Alerts.Webhook == "" {
+		return
+	}
+	webhook := &alert.Webhook{URL: d.config.Alerts.Webhook, Timeout: d.config.Alerts.Timeout}
+	notification := &alert.Notification{
+		Change: change,
+		Node: alert.NodeInfo{
+			ID:     d.host.ID(),
+			Name:   d.config.Node.Name,
+			Labels: d.config.Node.Labels,
+		},
+		Time: time.Now().UTC(),
+	}
+	crash.Go(d.logger, "daemon.alert-webhook", func() {
+		if err := webhook.Notify(d.ctx, notification); err != nil {
+			d.logger.Warn("failed to send alert to webhook", "rule", a.Rule, "app_id", a.AppID, "error", err)
+		}
+	})
+}
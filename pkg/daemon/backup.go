@@ -0,0 +1,433 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/applock"
+	"github.com/asjdf/p2p-playground-lite/pkg/backup"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/preflight"
+	"github.com/asjdf/p2p-playground-lite/pkg/runtime"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// initBackup opens the snapshot store and the configured backup bucket
+func (d *Daemon) initBackup() error {
+	d.volumes = d.storage.GetPath(volume.DirName)
+	d.snapshots = volume.NewStore(d.storage.GetPath(volume.SnapshotsDirName))
+
+	bucket, err := backup.NewBucket(&d.config.Backup.S3)
+	if err != nil {
+		return fmt.Errorf("failed to configure backup.s3: %w", err)
+	}
+	d.bucket = bucket
+	if d.config.Backup.Interval > 0 {
+		d.logger.Info("app volumes are backed up", "interval", d.config.Backup.Interval, "peers", d.config.Backup.Peers, "s3", bucket != nil)
+	}
+	return nil
+}
+
+// handleBackupRequest takes, lists, keeps, sends and restores snapshots of
+// the volumes of apps
+func (d *Daemon) handleBackupRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req backup.Request
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("backup", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received backup request", "action", req.Action, "app", req.App, "snapshot", req.Snapshot, "from", req.From)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendBackupResponse(ctx, stream, backup.Response{}, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	// Snapshots sent and restored are read from the stream and written to
+	// volumes, so only the other actions end with the stream
+	switch req.Action {
+	case backup.ActionCreate, backup.ActionList, backup.ActionFetch:
+		watchStream(stream, cancel)
+	}
+
+	var resp backup.Response
+	var err error
+	switch req.Action {
+	case backup.ActionCreate:
+		resp.Snapshot, resp.Targets, err = d.backupApp(ctx, req.App, req.Targets, p2p.RemotePeer(stream))
+	case backup.ActionList:
+		resp.Snapshots, err = d.listSnapshots(ctx, req.App, req.From)
+	case backup.ActionStore:
+		resp.Snapshot, err = d.storeSnapshot(ctx, stream, req.Meta)
+	case backup.ActionFetch:
+		d.sendSnapshot(ctx, stream, &req)
+		return
+	case backup.ActionRestore:
+		resp.Snapshot, err = d.restoreVolumes(ctx, &req, p2p.RemotePeer(stream))
+	default:
+		err = fmt.Errorf("%w: unknown action %q", types.ErrInvalidInput, req.Action)
+	}
+	if err != nil {
+		log.Error("backup request failed", "action", req.Action, "app", req.App, "error", err)
+	}
+	d.sendBackupResponse(ctx, stream, resp, err)
+}
+
+// appVolumes returns the volumes the deployed instances of app declare
+func (d *Daemon) appVolumes(ctx context.Context, app string) ([]string, error) {
+	apps, err := d.runtime.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, a := range apps {
+		if a.Name != app || a.Manifest == nil {
+			continue
+		}
+		for _, name := range a.Manifest.Volumes {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no deployed app %s has volumes", types.ErrNotFound, app)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// backupApp snapshots the volumes of app and sends the snapshot to targets,
+// or to the configured peers and bucket if there are none. A target that
+// cannot be reached is reported in its result; the snapshot is kept anyway.
+func (d *Daemon) backupApp(ctx context.Context, app string, targets []string, holder string) (*volume.Snapshot, []backup.TargetResult, error) {
+	log := logging.FromContext(ctx)
+
+	names, err := d.appVolumes(ctx, app)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Do not archive volumes a restore is replacing
+	release, err := d.locks.Acquire(app, applock.Lock{
+		Operation: "backup",
+		RequestID: logging.RequestIDFromContext(ctx),
+		Holder:    holder,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	snap, err := d.snapshots.Create(d.volumes, app, names, d.host.ID())
+	release()
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Info("volumes snapshotted", "app", app, "snapshot", snap.ID, "volumes", names, "size", snap.Size)
+
+	if len(targets) == 0 {
+		targets = append(targets, d.config.Backup.Peers...)
+		if d.bucket != nil {
+			targets = append(targets, backup.TargetS3)
+		}
+	}
+	results := make([]backup.TargetResult, 0, len(targets))
+	for _, target := range targets {
+		result := backup.TargetResult{Target: target}
+		if err := d.sendSnapshotTo(ctx, snap, target); err != nil {
+			log.Warn("failed to back up snapshot", "snapshot", snap.ID, "target", target, "error", err)
+			result.Error = err.Error()
+		} else {
+			log.Info("snapshot backed up", "snapshot", snap.ID, "target", target)
+		}
+		results = append(results, result)
+	}
+
+	d.pruneSnapshots(ctx, app)
+	return snap, results, nil
+}
+
+// sendSnapshotTo sends snap to the node target, or the bucket if target is backup.TargetS3
+func (d *Daemon) sendSnapshotTo(ctx context.Context, snap *volume.Snapshot, target string) error {
+	if target == d.host.ID() {
+		return fmt.Errorf("%w: the snapshot is already kept on this node", types.ErrInvalidInput)
+	}
+	if target == backup.TargetS3 && d.bucket == nil {
+		return fmt.Errorf("%w: no bucket is configured (backup.s3)", types.ErrUnavailable)
+	}
+
+	f, err := d.snapshots.Open(snap)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if target == backup.TargetS3 {
+		return d.bucket.Put(ctx, snap, f)
+	}
+	return backup.Send(ctx, d.host, target, snap, f, logging.RequestIDFromContext(ctx))
+}
+
+// pruneSnapshots drops the snapshots of app beyond those the node keeps
+func (d *Daemon) pruneSnapshots(ctx context.Context, app string) {
+	keep := d.config.Backup.Keep
+	if keep <= 0 {
+		keep = volume.DefaultKeep
+	}
+	pruned, err := d.snapshots.Prune(app, keep)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to prune snapshots", "app", app, "error", err)
+	}
+	for _, snap := range pruned {
+		logging.FromContext(ctx).Info("snapshot pruned", "snapshot", snap.ID)
+	}
+}
+
+// listSnapshots lists the snapshots of app, or of all apps if app is empty,
+// kept on the node or, with from set to backup.TargetS3, in the bucket
+func (d *Daemon) listSnapshots(ctx context.Context, app, from string) ([]*volume.Snapshot, error) {
+	switch from {
+	case "":
+		return d.snapshots.List(app)
+	case backup.TargetS3:
+		if d.bucket == nil {
+			return nil, fmt.Errorf("%w: no bucket is configured (backup.s3)", types.ErrUnavailable)
+		}
+		return d.bucket.List(ctx, app)
+	default:
+		return nil, fmt.Errorf("%w: snapshots are listed from the node or %s, ask node %s directly", types.ErrInvalidInput, backup.TargetS3, from)
+	}
+}
+
+// storeSnapshot keeps the snapshot described by meta, whose archive follows
+// on stream, sent by a node backing up to this one
+func (d *Daemon) storeSnapshot(ctx context.Context, stream types.Stream, meta *volume.Snapshot) (*volume.Snapshot, error) {
+	if meta == nil {
+		return nil, fmt.Errorf("%w: no snapshot to store", types.ErrInvalidInput)
+	}
+	if err := meta.Validate(); err != nil {
+		return nil, err
+	}
+	err := preflight.CheckSpace(preflight.SpaceNeed{Dir: d.storage.GetPath(volume.SnapshotsDirName), Bytes: meta.Size})
+	if err != nil && !errors.Is(err, types.ErrNotImplemented) {
+		return nil, err
+	}
+
+	if err := d.snapshots.Add(meta, stream); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("snapshot stored", "snapshot", meta.ID, "node", meta.Node, "size", meta.Size)
+	d.pruneSnapshots(ctx, meta.App)
+	return meta, nil
+}
+
+// sendSnapshot sends the snapshot a fetch request names after the response
+func (d *Daemon) sendSnapshot(ctx context.Context, stream types.Stream, req *backup.Request) {
+	log := logging.FromContext(ctx)
+
+	snap, err := d.snapshots.Get(req.App, req.Snapshot)
+	if err != nil {
+		d.sendBackupResponse(ctx, stream, backup.Response{}, err)
+		return
+	}
+	f, err := d.snapshots.Open(snap)
+	if err != nil {
+		d.sendBackupResponse(ctx, stream, backup.Response{}, err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	if !d.sendBackupResponse(ctx, stream, backup.Response{Snapshot: snap}, nil) {
+		return
+	}
+	if n, err := io.CopyN(stream, f, snap.Size); err != nil {
+		log.Error("failed to send snapshot", "snapshot", snap.ID, "sent", n, "error", err)
+		return
+	}
+	log.Info("snapshot sent", "snapshot", snap.ID, "size", snap.Size)
+}
+
+// restoreVolumes replaces the volumes of the app of req with the snapshot it
+// names, kept on the node, in the bucket, or on the node req.From. Running
+// instances of the app are stopped while their volumes are replaced and
+// started again afterwards.
+func (d *Daemon) restoreVolumes(ctx context.Context, req *backup.Request, holder string) (*volume.Snapshot, error) {
+	log := logging.FromContext(ctx)
+
+	if req.App == "" {
+		return nil, fmt.Errorf("%w: no app to restore", types.ErrInvalidInput)
+	}
+
+	release, err := d.locks.Acquire(req.App, applock.Lock{
+		Operation: "restore",
+		RequestID: logging.RequestIDFromContext(ctx),
+		Holder:    holder,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx = runtime.WithInitiator(ctx, operatorInitiator(ctx, "restore", holder, nil))
+	var stopped []*types.Application
+	defer func() {
+		for _, app := range stopped {
+			if err := d.runtime.Start(ctx, app); err != nil {
+				log.Error("failed to restart application after restore", "app_id", app.ID, "error", err)
+				continue
+			}
+			log.Info("application restarted after restore", "app_id", app.ID)
+		}
+	}()
+
+	var restored *volume.Snapshot
+	restore := func(snap *volume.Snapshot, r io.Reader) error {
+		if snap.App != req.App {
+			return fmt.Errorf("%w: snapshot %s is of %s, not %s", types.ErrInvalidInput, snap.ID, snap.App, req.App)
+		}
+		err := preflight.CheckSpace(preflight.SpaceNeed{Dir: d.volumes, Bytes: snap.Size * preflight.UnpackFactor})
+		if err != nil && !errors.Is(err, types.ErrNotImplemented) {
+			return err
+		}
+		if stopped, err = d.stopInstances(ctx, req.App); err != nil {
+			return err
+		}
+		restored = snap
+		return volume.Restore(d.volumes, snap, r)
+	}
+
+	switch req.From {
+	case "":
+		err = d.withSnapshot(req.App, req.Snapshot, restore)
+	case backup.TargetS3:
+		if d.bucket == nil {
+			return nil, fmt.Errorf("%w: no bucket is configured (backup.s3)", types.ErrUnavailable)
+		}
+		var snap *volume.Snapshot
+		var body io.ReadCloser
+		if snap, body, err = d.bucket.Open(ctx, req.App, req.Snapshot); err != nil {
+			return nil, err
+		}
+		err = restore(snap, body)
+		_ = body.Close()
+	default:
+		err = backup.Fetch(ctx, d.host, req.From, req.App, req.Snapshot, logging.RequestIDFromContext(ctx), restore)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("volumes restored", "app", req.App, "snapshot", restored.ID, "from", req.From, "taken_on", restored.Node)
+	return restored, nil
+}
+
+// withSnapshot hands the snapshot id of app the node keeps, or its latest if
+// id is empty, to fn with a reader of its archive
+func (d *Daemon) withSnapshot(app, id string, fn func(snap *volume.Snapshot, r io.Reader) error) error {
+	snap, err := d.snapshots.Get(app, id)
+	if err != nil {
+		return err
+	}
+	f, err := d.snapshots.Open(snap)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return fn(snap, f)
+}
+
+// stopInstances stops the running instances of app and returns them. A
+// paused instance keeps its volumes open, so it must be resumed first.
+func (d *Daemon) stopInstances(ctx context.Context, app string) ([]*types.Application, error) {
+	apps, err := d.runtime.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var running []*types.Application
+	for _, a := range apps {
+		if a.Name != app {
+			continue
+		}
+		switch a.Status {
+		case types.AppStatusPaused:
+			return nil, fmt.Errorf("%w: %s is paused, resume it before restoring its volumes", types.ErrInvalidState, a.ID)
+		case types.AppStatusRunning, types.AppStatusStarting, types.AppStatusRestarting:
+			running = append(running, a)
+		}
+	}
+
+	var stopped []*types.Application
+	for _, a := range running {
+		if err := d.runtime.Stop(ctx, a.ID); err != nil && !errors.Is(err, types.ErrAppNotRunning) {
+			return stopped, fmt.Errorf("failed to stop %s: %w", a.ID, err)
+		}
+		logging.FromContext(ctx).Info("application stopped for restore", "app_id", a.ID)
+		stopped = append(stopped, a)
+	}
+	return stopped, nil
+}
+
+// scheduleBackups backs up the volumes of every deployed app that has
+// volumes at the configured interval until the daemon stops
+func (d *Daemon) scheduleBackups() {
+	ticker := time.NewTicker(d.config.Backup.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		apps, err := d.runtime.List(d.ctx)
+		if err != nil {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, app := range apps {
+			if app.Manifest == nil || len(app.Manifest.Volumes) == 0 || seen[app.Name] {
+				continue
+			}
+			seen[app.Name] = true
+
+			ctx, cancel := d.newRequestContext("backup", "")
+			if _, _, err := d.backupApp(ctx, app.Name, nil, ""); err != nil {
+				logging.FromContext(ctx).Warn("scheduled backup failed", "app", app.Name, "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// sendBackupResponse sends resp, with the outcome of respErr, and reports
+// whether it was sent
+func (d *Daemon) sendBackupResponse(ctx context.Context, stream types.Stream, resp backup.Response, respErr error) bool {
+	log := logging.FromContext(ctx)
+
+	resp.Success = respErr == nil
+	resp.Error = errorMessage(respErr)
+	resp.Code = types.ErrorCode(respErr)
+	resp.RequestID = logging.RequestIDFromContext(ctx)
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return false
+	}
+
+	log.Info("backup response sent", "success", respErr == nil)
+	return true
+}
@@ -0,0 +1,85 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/bench"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// handleBenchRequest receives or sends the data of a benchmark stream, or
+// benchmarks the connection of this node to the peer the request names
+func (d *Daemon) handleBenchRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req bench.Request
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("bench", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received bench request", "direction", req.Direction, "size", req.Size, "peer", req.Peer)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendBenchResponse(ctx, stream, bench.Response{}, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	if req.Peer != "" {
+		watchStream(stream, cancel)
+		var opts bench.Options
+		if req.Options != nil {
+			opts = *req.Options
+		}
+		report, err := bench.Run(ctx, d.host, req.Peer, opts, logging.RequestIDFromContext(ctx))
+		if err != nil {
+			log.Error("failed to benchmark peer", "peer", req.Peer, "error", err)
+		}
+		d.sendBenchResponse(ctx, stream, bench.Response{Report: report}, err)
+		return
+	}
+
+	if req.Size <= 0 || req.Size > bench.MaxSize {
+		d.sendBenchResponse(ctx, stream, bench.Response{}, fmt.Errorf("%w: size must be between 1 and %d bytes", types.ErrInvalidInput, int64(bench.MaxSize)))
+		return
+	}
+	switch req.Direction {
+	case bench.DirectionUpload:
+		n, err := io.CopyN(io.Discard, stream, req.Size)
+		if err != nil {
+			log.Error("failed to receive bench data", "received", n, "error", err)
+			err = fmt.Errorf("failed to receive data after %d of %d bytes: %w", n, req.Size, err)
+		}
+		d.sendBenchResponse(ctx, stream, bench.Response{Bytes: n}, err)
+	case bench.DirectionDownload:
+		d.sendBenchResponse(ctx, stream, bench.Response{}, nil)
+		if n, err := bench.WriteData(stream, req.Size); err != nil {
+			log.Error("failed to send bench data", "sent", n, "error", err)
+		}
+	default:
+		d.sendBenchResponse(ctx, stream, bench.Response{}, fmt.Errorf("%w: unknown direction %q, want upload or download", types.ErrInvalidInput, req.Direction))
+	}
+}
+
+// sendBenchResponse sends resp with the outcome of a bench request
+func (d *Daemon) sendBenchResponse(ctx context.Context, stream types.Stream, resp bench.Response, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp.Success = respErr == nil
+	resp.Error = errorMessage(respErr)
+	resp.Code = types.ErrorCode(respErr)
+	resp.RequestID = logging.RequestIDFromContext(ctx)
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("bench response sent", "success", respErr == nil)
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// ConnectionCardFile is the file in the data directory the connection card
+// is written to when the daemon starts
+const ConnectionCardFile = "connection.txt"
+
+// maxCardAddrs is how many addresses the connection card lists
+const maxCardAddrs = 4
+
+// ConnectionCard is what a user needs to reach a daemon from a controller
+// or another node
+type ConnectionCard struct {
+	Name    string `json:"name,omitempty"`
+	PeerID  string `json:"peer_id"`
+	Cluster string `json:"cluster,omitempty"`
+
+	// Addrs are the most routable addresses of the daemon, with its peer ID
+	Addrs []string `json:"addrs"`
+
+	// PSK reports whether peers need the cluster PSK to connect
+	PSK bool `json:"psk"`
+}
+
+// ConnectionCard returns the connection card of the started daemon
+func (d *Daemon) ConnectionCard() *ConnectionCard {
+	card := &ConnectionCard{
+		Name:    d.config.Node.Name,
+		PeerID:  d.host.ID(),
+		Cluster: d.config.Node.Labels["cluster"],
+		PSK:     d.config.Security.EnableAuth && d.config.Security.PSK != "",
+	}
+
+	// Loopback addresses only help on this machine, where the local socket is used
+	var loopback []string
+	for _, addr := range d.host.Addrs() {
+		full := addr + "/p2p/" + card.PeerID
+		if maddr, err := multiaddr.NewMultiaddr(addr); err == nil && manet.IsIPLoopback(maddr) {
+			loopback = append(loopback, full)
+			continue
+		}
+		card.Addrs = append(card.Addrs, full)
+	}
+	if len(card.Addrs) == 0 {
+		card.Addrs = loopback
+	}
+	if len(card.Addrs) > maxCardAddrs {
+		card.Addrs = card.Addrs[:maxCardAddrs]
+	}
+	return card
+}
+
+// String formats the card to be printed, with snippets ready to paste
+func (c *ConnectionCard) String() string {
+	var b strings.Builder
+	b.WriteString("P2P Playground daemon ready\n")
+	if c.Name != "" {
+		fmt.Fprintf(&b, "  Name:     %s\n", c.Name)
+	}
+	fmt.Fprintf(&b, "  Peer ID:  %s\n", c.PeerID)
+	if c.Cluster != "" {
+		fmt.Fprintf(&b, "  Cluster:  %s\n", c.Cluster)
+	}
+	b.WriteString("  Addresses:\n")
+	for _, addr := range c.Addrs {
+		fmt.Fprintf(&b, "    %s\n", addr)
+	}
+
+	b.WriteString("\nTarget this node from a controller:\n")
+	fmt.Fprintf(&b, "  controller status --node %s\n", c.PeerID)
+
+	if len(c.Addrs) > 0 {
+		b.WriteString("\nReach it from other networks, in the config of controllers and daemons:\n")
+		b.WriteString("  node:\n    bootstrap_peers:\n")
+		for _, addr := range c.Addrs {
+			fmt.Fprintf(&b, "      - %s\n", addr)
+		}
+	}
+	if c.PSK {
+		b.WriteString("\nThe cluster PSK is required to connect (security.psk).\n")
+	}
+	return b.String()
+}
+
+// writeConnectionCard writes the connection card to the data directory, for
+// daemons running as a service where nobody sees the output
+func (d *Daemon) writeConnectionCard(card *ConnectionCard) error {
+	path := filepath.Join(d.config.Storage.DataDir, ConnectionCardFile)
+	if err := os.WriteFile(path, []byte(card.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write connection card: %w", err)
+	}
+	return nil
+}
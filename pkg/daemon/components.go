@@ -0,0 +1,606 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/admission"
+	"github.com/asjdf/p2p-playground-lite/pkg/alert"
+	"github.com/asjdf/p2p-playground-lite/pkg/appapi"
+	"github.com/asjdf/p2p-playground-lite/pkg/applabel"
+	"github.com/asjdf/p2p-playground-lite/pkg/appuser"
+	"github.com/asjdf/p2p-playground-lite/pkg/audit"
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/integrity"
+	"github.com/asjdf/p2p-playground-lite/pkg/lifecycle"
+	"github.com/asjdf/p2p-playground-lite/pkg/messaging"
+	"github.com/asjdf/p2p-playground-lite/pkg/middleware"
+	"github.com/asjdf/p2p-playground-lite/pkg/netpolicy"
+	"github.com/asjdf/p2p-playground-lite/pkg/ownership"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/pex"
+	"github.com/asjdf/p2p-playground-lite/pkg/quota"
+	"github.com/asjdf/p2p-playground-lite/pkg/revision"
+	"github.com/asjdf/p2p-playground-lite/pkg/runtime"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/topics"
+	"github.com/asjdf/p2p-playground-lite/pkg/transfer"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// components returns the subsystems of the daemon in the order they start,
+// each after the ones it depends on
+func (d *Daemon) components() []lifecycle.Component {
+	return []lifecycle.Component{
+		{Name: "storage", Start: d.startStorage, Detail: d.storageDetail},
+		{Name: "keys", Start: d.startKeys},
+		{Name: "host", Start: d.startHost, Stop: d.stopHost, Health: d.hostHealth, Detail: d.hostDetail},
+		{Name: "peer exchange", Start: d.startPeerExchange, Stop: d.stopPeerExchange, Detail: d.peerExchangeDetail},
+		{Name: "discovery", Start: d.startDiscovery, Stop: d.stopDiscovery, Health: d.discoveryHealth, Detail: d.discoveryDetail},
+		{Name: "app api", Start: d.startAppAPI, Stop: d.stopAppAPI, Detail: d.appAPIDetail},
+		{Name: "history", Start: d.startHistory},
+		{Name: "backup", Start: d.initBackup, Detail: d.backupDetail},
+		{Name: "registry", Start: d.initRegistry, Detail: d.registryDetail},
+		{Name: "runtime", Start: d.startRuntime, Detail: d.runtimeDetail},
+		{Name: "transfer", Start: d.startTransfer},
+		{Name: "encryption", Start: d.startEncryption, Detail: d.encryptionDetail},
+		{Name: "deployment", Start: d.startDeployment},
+		{Name: "handlers", Start: d.startHandlers, Stop: d.stopHandlers, Detail: d.handlersDetail},
+		{Name: "local socket", Start: d.startLocalSocket, Health: d.localSocketHealth, Detail: d.localSocketDetail},
+	}
+}
+
+// startStorage opens the data directory
+func (d *Daemon) startStorage() error {
+	storage, err := storage.NewFileStorage(d.config.Storage.DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+	d.storage = storage
+	d.logger.Info("storage initialized", "path", d.config.Storage.DataDir)
+
+	// Drop whatever deployments interrupted by a crash left behind
+	d.cleanStaging()
+	return nil
+}
+
+// storageDetail names the data directory
+func (d *Daemon) storageDetail() string {
+	return d.config.Storage.DataDir
+}
+
+// startKeys loads or generates the keys of the node
+func (d *Daemon) startKeys() error {
+	signer, err := security.LoadOrGenerateKeys(d.config.Storage.KeysDir, "node")
+	if err != nil {
+		return fmt.Errorf("failed to load keys: %w", err)
+	}
+	d.signer = signer
+	d.logger.Info("keys loaded")
+	return nil
+}
+
+// startHost creates the P2P host
+func (d *Daemon) startHost() error {
+	// Keep the peer ID across restarts if configured
+	var identity crypto.PrivKey
+	if d.config.Node.IdentityFile != "" {
+		var err error
+		identity, err = p2p.LoadOrGenerateIdentity(d.config.Node.IdentityFile)
+		if err != nil {
+			return fmt.Errorf("failed to load identity: %w", err)
+		}
+	}
+
+	hostConfig := &p2p.HostConfig{
+		ListenAddrs:            d.config.Node.ListenAddrs,
+		AddressFamily:          d.config.Node.AddressFamily,
+		PSK:                    d.config.Security.PSK,
+		EnableAuth:             d.config.Security.EnableAuth,
+		TrustedPeers:           d.config.Security.TrustedPeers,
+		BootstrapPeers:         d.config.Node.BootstrapPeers,
+		DisableDHT:             d.config.Node.DisableDHT,
+		DHTMode:                d.config.Node.DHTMode,
+		DisablePublicBootstrap: d.config.Node.DisablePublicBootstrap,
+		DisableNATService:      d.config.Node.DisableNATService,
+		DisableAutoRelay:       d.config.Node.DisableAutoRelay,
+		DisableHolePunching:    d.config.Node.DisableHolePunching,
+		DisableRelayService:    d.config.Node.DisableRelayService,
+		StaticRelays:           d.config.Node.StaticRelays,
+		Identity:               identity,
+		AnnounceAddrs:          d.config.Node.AnnounceAddrs,
+		MaxConnections:         d.config.Node.MaxConnections,
+	}
+	host, err := p2p.NewHost(d.ctx, hostConfig, d.logger)
+	if err != nil {
+		return fmt.Errorf("failed to create P2P host: %w", err)
+	}
+	d.host = host
+	host.OnPeerConnected(func(peerID string) {
+		d.logger.Debug("peer connected", "peer", peerID)
+	})
+	host.OnPeerDisconnected(func(peerID string) {
+		d.logger.Debug("peer disconnected", "peer", peerID)
+	})
+
+	// Log network status and counters periodically
+	d.startDiagnostics(host)
+	return nil
+}
+
+// stopHost closes the P2P host, and the local socket with it
+func (d *Daemon) stopHost() error {
+	return d.host.Close()
+}
+
+// hostHealth reports a host without addresses to be reached at
+func (d *Daemon) hostHealth() error {
+	if len(d.host.Addrs()) == 0 {
+		return fmt.Errorf("%w: host has no addresses", types.ErrUnavailable)
+	}
+	return nil
+}
+
+// hostDetail counts the addresses the host listens on and its peers
+func (d *Daemon) hostDetail() string {
+	return fmt.Sprintf("listening on %d address(es), %d peer(s) connected", len(d.host.Addrs()), len(d.host.Peers()))
+}
+
+// startPeerExchange shares known cluster peers with the daemons we connect
+// to, unless disabled. Failing to is not fatal.
+func (d *Daemon) startPeerExchange() error {
+	if d.config.Node.DisablePeerExchange {
+		return nil
+	}
+	d.pex = pex.New(d.host.LibP2PHost(), d.config.Node.Labels, d.logger)
+	if err := d.pex.Start(); err != nil {
+		d.logger.Warn("failed to start peer exchange", "error", err)
+		d.pex = nil
+	}
+	return nil
+}
+
+// stopPeerExchange stops sharing peers
+func (d *Daemon) stopPeerExchange() error {
+	if d.pex != nil {
+		d.pex.Stop()
+	}
+	return nil
+}
+
+// peerExchangeDetail tells whether peers are shared
+func (d *Daemon) peerExchangeDetail() string {
+	if d.pex == nil {
+		return "disabled"
+	}
+	return "sharing peers"
+}
+
+// startDiscovery finds the other nodes with the configured discovery backends
+func (d *Daemon) startDiscovery() error {
+	build := version.Get()
+	nodes, err := discovery.New(d.host.LibP2PHost(), d.logger, &discovery.Options{
+		Backends: discovery.Backends(&d.config.Node),
+		Gossip: &discovery.Config{
+			NodeName:   d.config.Node.Name,
+			NodeLabels: d.config.Node.Labels,
+			Version:    build.Version,
+			Commit:     build.Commit,
+			StartedAt:  d.startedAt,
+			Devices:    d.devices,
+			Platform:   build.Platform,
+
+			AnnounceInterval: d.config.Node.AnnounceInterval,
+			Rejoin:           d.host.Bootstrap,
+			OnHealthChange:   d.discoveryHealthChanged,
+		},
+		Routing:     d.host.Routing(),
+		StaticPeers: d.config.Node.StaticPeers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create discovery: %w", err)
+	}
+	d.nodes = nodes
+	if err := d.nodes.Start(); err != nil {
+		return fmt.Errorf("failed to start discovery: %w", err)
+	}
+	// The gossip backend also carries app topics, KV replication and alerts
+	d.discovery = nodes.Gossip()
+	d.logger.Info("discovery started", "backends", discovery.Backends(&d.config.Node))
+	return nil
+}
+
+// stopDiscovery stops every discovery backend
+func (d *Daemon) stopDiscovery() error {
+	d.nodes.Stop()
+	return nil
+}
+
+// discoveryHealth reports the gossip backend losing the cluster
+func (d *Daemon) discoveryHealth() error {
+	if d.discovery == nil {
+		return nil
+	}
+	if health := d.discovery.Health(); health.Degraded {
+		return fmt.Errorf("%w: no peers on the discovery topic since %s", types.ErrUnavailable, health.Since.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// discoveryDetail names the discovery backends, counts the nodes they found
+// and, with the gossip backend, the peers on the discovery topic
+func (d *Daemon) discoveryDetail() string {
+	detail := fmt.Sprintf("%s; %d node(s) found", strings.Join(discovery.Backends(&d.config.Node), ", "), len(d.nodes.Nodes()))
+	if d.discovery != nil {
+		detail += fmt.Sprintf(", %d peer(s) on the discovery topic", d.discovery.Health().Peers)
+	}
+	return detail
+}
+
+// startAppAPI serves the local API used by pkg/appsdk, with messaging and
+// topics, and the key-value stores
+func (d *Daemon) startAppAPI() error {
+	if !d.config.Runtime.DisableAppAPI {
+		d.appAPI = appapi.New(appapi.Node{
+			ID:     d.host.ID(),
+			Name:   d.config.Node.Name,
+			Labels: d.config.Node.Labels,
+		}, d.logger)
+
+		// Route messages between apps, locally and through other daemons
+		if !d.config.Runtime.DisableMessaging {
+			d.messaging = messaging.New(d.host, d.logger)
+			d.messaging.Register(d.appAPI)
+		}
+
+		// Bridge app topics onto the discovery gossipsub instance
+		if !d.config.Runtime.DisableTopics && d.discovery != nil {
+			topics.New(d.discovery.PubSub(), d.logger).Register(d.appAPI)
+		}
+	}
+
+	// Initialize the per-app and cluster-wide key-value stores
+	d.initKV(d.host)
+	return nil
+}
+
+// stopAppAPI stops replicating the key-value stores
+func (d *Daemon) stopAppAPI() error {
+	for _, replicator := range d.kvSync {
+		replicator.Stop()
+	}
+	d.kvSync = nil
+	return nil
+}
+
+// appAPIDetail tells whether apps are served the local API
+func (d *Daemon) appAPIDetail() string {
+	if d.appAPI == nil {
+		return "disabled"
+	}
+	return "serving apps"
+}
+
+// startHistory keeps recent status samples and lifecycle events of each app,
+// logs the events, and evaluates alert rules
+func (d *Daemon) startHistory() error {
+	if err := d.initHistory(); err != nil {
+		return err
+	}
+	if err := d.initEvents(); err != nil {
+		return err
+	}
+	d.alerts = alert.New(&d.config.Alerts)
+	return nil
+}
+
+// startRuntime creates the runtime running apps, and the loops sampling it
+func (d *Daemon) startRuntime() error {
+	d.pkgMgr = pkgmanager.New()
+
+	runtimeOpts := []runtime.Option{
+		runtime.WithMAC(runtime.MACConfig{
+			AppArmorProfile: d.config.Security.AppArmorProfile,
+			SELinuxContext:  d.config.Security.SELinuxContext,
+			Require:         d.config.Security.RequireMAC,
+		}),
+		runtime.WithDevices(d.devices),
+		runtime.WithResourceLimits(d.config.Runtime.EnableResourceLimits),
+	}
+	if d.config.Runtime.ScratchDir != "" {
+		runtimeOpts = append(runtimeOpts, runtime.WithScratchDir(d.config.Runtime.ScratchDir))
+		d.logger.Info("app logs and temporary files are kept in the scratch directory", "path", d.config.Runtime.ScratchDir)
+	}
+
+	// Start app processes with the configured backend
+	switch d.config.Runtime.Backend {
+	case "", "exec":
+	case "systemd":
+		backend, err := runtime.NewSystemdBackend(runtime.SystemdConfig{
+			Slice: d.config.Runtime.SystemdSlice,
+			User:  os.Geteuid() != 0,
+		})
+		if err != nil {
+			return err
+		}
+		runtimeOpts = append(runtimeOpts, runtime.WithBackend(backend))
+		d.logger.Info("apps run as transient systemd services", "slice", d.config.Runtime.SystemdSlice)
+	default:
+		return fmt.Errorf("%w: runtime.backend must be exec or systemd, got %q", types.ErrInvalidInput, d.config.Runtime.Backend)
+	}
+
+	// Read app logs from the configured source
+	switch d.config.Runtime.LogSource {
+	case "", "file":
+	case "journal":
+		if err := runtime.CheckJournal(); err != nil {
+			return err
+		}
+		runtimeOpts = append(runtimeOpts, runtime.WithJournalLogs())
+		d.logger.Info("app logs are read from the systemd journal")
+	default:
+		return fmt.Errorf("%w: runtime.log_source must be file or journal, got %q", types.ErrInvalidInput, d.config.Runtime.LogSource)
+	}
+
+	// Run apps as unprivileged users if configured
+	appUsers, err := appuser.New(&d.config.Security.AppUsers, d.storage)
+	if err != nil {
+		return err
+	}
+	if appUsers != nil {
+		if err := appuser.RequirePrivileges(); err != nil {
+			return err
+		}
+		runtimeOpts = append(runtimeOpts, runtime.WithUser(appUsers.Credential))
+		d.logger.Info("apps run as unprivileged users", "mode", d.config.Security.AppUsers.Mode)
+	}
+
+	// Enforce manifest network policies with network namespaces
+	netPolicy, err := netpolicy.New(d.config.Runtime.NetworkSubnet, d.logger)
+	if err != nil {
+		return err
+	}
+	runtimeOpts = append(runtimeOpts, runtime.WithNetwork(netPolicy))
+
+	if d.appAPI != nil {
+		runtimeOpts = append(runtimeOpts, runtime.WithAppAPI(d.appAPI))
+	}
+	runtimeOpts = append(runtimeOpts, runtime.WithEvents(d.appEvent))
+
+	// Collect the core dumps of apps asking for them, if the core pattern
+	// puts them in the working directory
+	if d.coreDumps, err = coreDumpGlob(); err != nil {
+		d.logger.Info("core dumps of apps are not collected", "reason", err)
+	} else {
+		runtimeOpts = append(runtimeOpts, runtime.WithCoreDumps(d.coreDumps))
+	}
+	runtimeOpts = append(runtimeOpts, runtime.WithVolumes(d.volumes))
+
+	d.runtime = runtime.New(d.logger, runtimeOpts...)
+	if d.history != nil {
+		crash.Loop(d.ctx.Done(), d.logger, "daemon.history", d.sampleHistory)
+	}
+	if d.alerts != nil {
+		crash.Loop(d.ctx.Done(), d.logger, "daemon.alerts", d.evaluateAlerts)
+	}
+	if d.config.Backup.Interval > 0 {
+		crash.Loop(d.ctx.Done(), d.logger, "daemon.backup", d.scheduleBackups)
+	}
+	return nil
+}
+
+// runtimeDetail counts the apps deployed
+func (d *Daemon) runtimeDetail() string {
+	apps, err := d.runtime.List(d.ctx)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d app(s) deployed", len(apps))
+}
+
+// backupDetail names the bucket snapshots are backed up to
+func (d *Daemon) backupDetail() string {
+	if d.bucket == nil {
+		return "no bucket configured"
+	}
+	return "bucket " + d.bucket.String()
+}
+
+// registryDetail names the package registry
+func (d *Daemon) registryDetail() string {
+	if d.registry == nil {
+		return "not configured"
+	}
+	return d.registry.String()
+}
+
+// startTransfer creates the manager receiving packages
+func (d *Daemon) startTransfer() error {
+	d.transfer = transfer.New(d.host, d.logger, d.config.Runtime.MaxPackageSize, transfer.WithChunkSize(d.config.Node.TransferChunkSize))
+	return nil
+}
+
+// startEncryption initializes encryption at rest (no-op unless
+// storage.encrypt_at_rest is set)
+func (d *Daemon) startEncryption() error {
+	if err := d.initEncryption(); err != nil {
+		return fmt.Errorf("failed to initialize encryption at rest: %w", err)
+	}
+	return nil
+}
+
+// encryptionDetail tells whether sensitive files are encrypted at rest
+func (d *Daemon) encryptionDetail() string {
+	if d.dataKey == nil {
+		return "disabled"
+	}
+	return "enabled"
+}
+
+// startDeployment opens what deployments are checked against and recorded
+// in: admission hooks, ownership, checksums, revisions, labels, quotas and
+// the audit log
+func (d *Daemon) startDeployment() error {
+	if err := types.ValidateAppIDFormat(d.config.Runtime.AppIDFormat); err != nil {
+		return fmt.Errorf("%w (runtime.app_id_format)", err)
+	}
+	if d.config.Runtime.AppIDFormat != "" {
+		d.logger.Info("apps are deployed under configured IDs", "format", d.config.Runtime.AppIDFormat)
+	}
+
+	// Initialize admission hooks (nil when none are configured)
+	d.admitter = admission.New(&d.config.Admission, d.logger)
+	if d.admitter != nil {
+		d.logger.Info("admission hooks enabled",
+			"script", d.config.Admission.Script,
+			"webhook", d.config.Admission.Webhook,
+		)
+	}
+
+	// Initialize app ownership records (nil when disabled)
+	if !d.config.Security.DisableAppOwnership {
+		d.ownership = ownership.NewStore(d.storage)
+	}
+
+	// Record the files of deployed apps, to verify them later
+	d.checksums = integrity.NewStore(d.storage)
+
+	// Number the deployments of each app, keeping their packages for rollbacks
+	d.revisions = revision.NewStore(d.storage, revision.DefaultLimit)
+
+	// Keep the labels operators change on deployed apps
+	d.labels = applabel.NewStore(d.storage)
+
+	// Account deployments to operators, enforcing the configured quotas
+	d.quotas = quota.New(&d.config.Quotas, d.storage)
+
+	// Open the deployment transparency log
+	auditLog, err := audit.Open(filepath.Join(d.config.Storage.DataDir, audit.FileName))
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	d.auditLog = auditLog
+	if entries, err := auditLog.Entries(); err == nil {
+		if err := audit.Verify(entries); err != nil {
+			d.logger.Error("audit log failed verification", "error", err)
+		}
+	}
+	return nil
+}
+
+// protocols returns the handler of each protocol the daemon serves
+func (d *Daemon) protocols() map[string]types.StreamHandler {
+	handlers := map[string]types.StreamHandler{
+		consts.DeployProtocolID:    d.handleDeployRequest,
+		consts.DeployProtocolV2ID:  d.handleDeployRequestV2,
+		consts.ListProtocolID:      d.handleListRequest,
+		consts.ListProtocolV2ID:    d.handleListRequestV2,
+		consts.ListProtocolV3ID:    d.handleListRequestV3,
+		consts.LogsProtocolID:      d.handleLogsRequest,
+		consts.OwnershipProtocolID: d.handleOwnershipRequest,
+		consts.AuditProtocolID:     d.handleAuditRequest,
+		consts.KVProtocolID:        d.handleKVRequest,
+		consts.HistoryProtocolID:   d.handleHistoryRequest,
+		consts.NodeInfoProtocolID:  d.handleNodeInfoRequest,
+		consts.VerifyProtocolID:    d.handleVerifyRequest,
+		consts.EventsProtocolID:    d.handleEventsRequest,
+		consts.RollbackProtocolID:  d.handleRollbackRequest,
+		consts.LabelProtocolID:     d.handleLabelRequest,
+		consts.ControlProtocolID:   d.handleControlRequest,
+		consts.CoreDumpProtocolID:  d.handleCoreDumpRequest,
+		consts.BenchProtocolID:     d.handleBenchRequest,
+		consts.TransferProtocolID:  d.handleTransferRequest,
+		consts.BackupProtocolID:    d.handleBackupRequest,
+	}
+	if d.pex != nil {
+		handlers[consts.PeerExchangeProtocolID] = d.handlePeerExchange
+	}
+	if d.messaging != nil {
+		handlers[consts.MessagingProtocolID] = d.messaging.HandleStream
+	}
+	return handlers
+}
+
+// handlePeerExchange answers an exchange started by another daemon. It
+// needs the key of the remote peer, so it is only served over libp2p.
+func (d *Daemon) handlePeerExchange(stream types.Stream) {
+	s, ok := p2p.LibP2PStream(stream)
+	if !ok {
+		_ = stream.Reset()
+		return
+	}
+	d.pex.HandleStream(s)
+}
+
+// startHandlers registers the protocol handlers behind the middleware they
+// all share
+func (d *Daemon) startHandlers() error {
+	d.metrics = middleware.NewMetrics()
+	chain := []middleware.Middleware{
+		middleware.Recover(d.logger, d.metrics),
+		d.metrics.Middleware(),
+		middleware.Log(d.logger),
+	}
+	if limit := d.config.Security.RateLimit; limit.RequestsPerSecond > 0 {
+		limiter := middleware.NewRateLimiter(limit.RequestsPerSecond, limit.Burst)
+		chain = append(chain, middleware.Guard(d.logger, limiter.Check, d.metrics))
+	}
+	wrap := middleware.Chain(chain...)
+	for protocol, handler := range d.protocols() {
+		if _, ok := consts.CapabilityOf(protocol); !ok {
+			d.logger.Warn("serving a protocol missing from the capability registry", "protocol", protocol)
+		}
+		d.host.SetStreamHandler(protocol, wrap(protocol, handler))
+	}
+	return nil
+}
+
+// stopHandlers stops accepting requests, before the subsystems serving them stop
+func (d *Daemon) stopHandlers() error {
+	for protocol := range d.protocols() {
+		d.host.RemoveStreamHandler(protocol)
+	}
+	return nil
+}
+
+// handlersDetail counts the protocols served
+func (d *Daemon) handlersDetail() string {
+	return fmt.Sprintf("%d protocol(s) registered", len(d.protocols()))
+}
+
+// startLocalSocket lets controllers on this machine skip the network, unless
+// disabled. Failing to is not fatal, but leaves the component unhealthy.
+func (d *Daemon) startLocalSocket() error {
+	if d.config.Node.DisableLocalSocket {
+		return nil
+	}
+	socket := LocalSocket(d.config)
+	if err := d.host.ServeLocal(socket); err != nil {
+		d.logger.Warn("failed to serve local socket", "error", err)
+		d.localSocketErr = err
+		return nil
+	}
+	d.localSocket = socket
+	return nil
+}
+
+// localSocketHealth reports the local socket failing to be served
+func (d *Daemon) localSocketHealth() error {
+	return d.localSocketErr
+}
+
+// localSocketDetail names the local socket served
+func (d *Daemon) localSocketDetail() string {
+	if d.config.Node.DisableLocalSocket {
+		return "disabled"
+	}
+	return d.localSocket
+}
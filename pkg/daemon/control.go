@@ -0,0 +1,123 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/applock"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/runtime"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// ControlRequest represents a request to pause or resume a deployed app
+type ControlRequest struct {
+	AppID     string          `json:"app_id"`
+	Action    types.AppAction `json:"action"`
+	RequestID string          `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// ControlResponse represents a control response
+type ControlResponse struct {
+	Success   bool               `json:"success"`
+	App       *types.Application `json:"app,omitempty"` // The app after the action
+	Error     string             `json:"error,omitempty"`
+	Code      string             `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string             `json:"request_id,omitempty"`
+}
+
+// handleControlRequest pauses or resumes a deployed app. A paused app keeps
+// its process state and resources until it is resumed or stopped.
+func (d *Daemon) handleControlRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req ControlRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("control", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received control request", "app_id", req.AppID, "action", req.Action)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendControlResponse(ctx, stream, nil, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	req.AppID = d.resolveAppID(ctx, req.AppID)
+
+	app, err := d.control(ctx, &req, p2p.RemotePeer(stream))
+	if err != nil {
+		log.Error("failed to control app", "app_id", req.AppID, "action", req.Action, "error", err)
+	}
+	d.sendControlResponse(ctx, stream, app, err)
+}
+
+// control applies the action of a control request and returns the app after it
+func (d *Daemon) control(ctx context.Context, req *ControlRequest, holder string) (*types.Application, error) {
+	var act func(ctx context.Context, appID string) error
+	switch req.Action {
+	case types.AppActionPause:
+		act = d.runtime.Pause
+	case types.AppActionResume:
+		act = d.runtime.Resume
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q, want pause or resume", types.ErrInvalidInput, req.Action)
+	}
+
+	status, err := d.runtime.Status(ctx, req.AppID)
+	if errors.Is(err, types.ErrNotFound) {
+		return nil, fmt.Errorf("%w: no application %s", types.ErrNotFound, req.AppID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Do not race a deployment replacing the app
+	release, err := d.locks.Acquire(status.App.Name, applock.Lock{
+		Operation: string(req.Action),
+		RequestID: logging.RequestIDFromContext(ctx),
+		Holder:    holder,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx = runtime.WithInitiator(ctx, operatorInitiator(ctx, string(req.Action), holder, nil))
+	if err := act(ctx, req.AppID); err != nil {
+		return nil, err
+	}
+
+	status, err = d.runtime.Status(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("app controlled", "app_id", req.AppID, "action", req.Action, "status", status.App.Status)
+	return status.App, nil
+}
+
+// sendControlResponse sends a control response
+func (d *Daemon) sendControlResponse(ctx context.Context, stream types.Stream, app *types.Application, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := ControlResponse{
+		Success:   respErr == nil,
+		App:       app,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
+	}
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("control response sent", "success", respErr == nil)
+}
@@ -0,0 +1,132 @@
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/audit"
+	"github.com/asjdf/p2p-playground-lite/pkg/coredump"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// CoreDumpRequest represents a request to list the core dumps of an app or
+// to fetch one
+type CoreDumpRequest struct {
+	AppID     string `json:"app_id"`
+	Name      string `json:"name,omitempty"`       // Core dump to fetch, empty to list them
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// CoreDumpResponse represents a core dump response. When a core dump is
+// fetched, its Size bytes follow the response.
+type CoreDumpResponse struct {
+	Success   bool            `json:"success"`
+	Dumps     []coredump.Dump `json:"dumps,omitempty"` // Core dumps of the app, newest first
+	Dump      *coredump.Dump  `json:"dump,omitempty"`  // Core dump fetched
+	Error     string          `json:"error,omitempty"`
+	Code      string          `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// coreDumpGlob returns the glob of the core dumps the kernel leaves in the
+// working directory of crashed processes
+func coreDumpGlob() (string, error) {
+	pattern, err := coredump.Pattern()
+	if err != nil {
+		return "", err
+	}
+	return coredump.Glob(pattern)
+}
+
+// handleCoreDumpRequest lists the core dumps crashed processes of an app left
+// in its working directory, or sends one
+func (d *Daemon) handleCoreDumpRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req CoreDumpRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("coredump", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received core dump request", "app_id", req.AppID, "name", req.Name)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendCoreDumpResponse(ctx, stream, CoreDumpResponse{}, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	req.AppID = d.resolveAppID(ctx, req.AppID)
+
+	watchStream(stream, cancel)
+
+	if d.coreDumps == "" {
+		d.sendCoreDumpResponse(ctx, stream, CoreDumpResponse{}, fmt.Errorf("%w: the core pattern of the node does not put core dumps in the working directory of apps", types.ErrUnavailable))
+		return
+	}
+	status, err := d.runtime.Status(ctx, req.AppID)
+	if errors.Is(err, types.ErrNotFound) {
+		err = fmt.Errorf("%w: no application %s", types.ErrNotFound, req.AppID)
+	}
+	if err != nil {
+		d.sendCoreDumpResponse(ctx, stream, CoreDumpResponse{}, err)
+		return
+	}
+	workDir := status.App.WorkDir
+
+	if req.Name == "" {
+		dumps, err := coredump.List(workDir, d.coreDumps)
+		d.sendCoreDumpResponse(ctx, stream, CoreDumpResponse{Dumps: dumps}, err)
+		return
+	}
+
+	f, dump, err := coredump.Open(workDir, d.coreDumps, req.Name)
+	if err != nil {
+		log.Error("failed to open core dump", "error", err)
+		d.sendCoreDumpResponse(ctx, stream, CoreDumpResponse{}, err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	start := time.Now()
+	if !d.sendCoreDumpResponse(ctx, stream, CoreDumpResponse{Dump: &dump}, nil) {
+		return
+	}
+	transcript := sha256.New()
+	size, err := io.Copy(io.MultiWriter(stream, transcript), io.LimitReader(f, dump.Size))
+	if err != nil {
+		log.Error("failed to send core dump", "error", err)
+		return
+	}
+	log.Info("core dump sent", "name", dump.Name, "size", size)
+	d.recordSession(ctx, audit.SessionCoreDump, p2p.RemotePeer(stream), req.AppID, hex.EncodeToString(transcript.Sum(nil)), size, start)
+}
+
+// sendCoreDumpResponse sends resp, with the outcome of respErr, and reports
+// whether it was sent
+func (d *Daemon) sendCoreDumpResponse(ctx context.Context, stream types.Stream, resp CoreDumpResponse, respErr error) bool {
+	log := logging.FromContext(ctx)
+
+	resp.Success = respErr == nil
+	resp.Error = errorMessage(respErr)
+	resp.Code = types.ErrorCode(respErr)
+	resp.RequestID = logging.RequestIDFromContext(ctx)
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return false
+	}
+
+	log.Info("core dump response sent", "success", respErr == nil)
+	return true
+}
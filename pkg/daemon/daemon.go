@@ -2,55 +2,157 @@ package daemon
 
 import (
 	"context"
-	"encoding/binary"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/asjdf/p2p-playground-lite/pkg/admission"
+	"github.com/asjdf/p2p-playground-lite/pkg/alert"
+	"github.com/asjdf/p2p-playground-lite/pkg/appapi"
+	"github.com/asjdf/p2p-playground-lite/pkg/applabel"
+	"github.com/asjdf/p2p-playground-lite/pkg/applock"
+	"github.com/asjdf/p2p-playground-lite/pkg/audit"
+	"github.com/asjdf/p2p-playground-lite/pkg/backup"
+	"github.com/asjdf/p2p-playground-lite/pkg/capacity"
 	"github.com/asjdf/p2p-playground-lite/pkg/config"
-	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/device"
 	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/events"
+	"github.com/asjdf/p2p-playground-lite/pkg/history"
+	"github.com/asjdf/p2p-playground-lite/pkg/integrity"
+	"github.com/asjdf/p2p-playground-lite/pkg/interpreter"
+	"github.com/asjdf/p2p-playground-lite/pkg/kv"
+	"github.com/asjdf/p2p-playground-lite/pkg/lifecycle"
 	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/messaging"
+	"github.com/asjdf/p2p-playground-lite/pkg/middleware"
+	"github.com/asjdf/p2p-playground-lite/pkg/ownership"
 	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
 	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/pex"
+	"github.com/asjdf/p2p-playground-lite/pkg/platform"
+	"github.com/asjdf/p2p-playground-lite/pkg/policy"
+	"github.com/asjdf/p2p-playground-lite/pkg/preflight"
+	"github.com/asjdf/p2p-playground-lite/pkg/quota"
+	"github.com/asjdf/p2p-playground-lite/pkg/registry"
+	"github.com/asjdf/p2p-playground-lite/pkg/revision"
 	"github.com/asjdf/p2p-playground-lite/pkg/runtime"
 	"github.com/asjdf/p2p-playground-lite/pkg/security"
 	"github.com/asjdf/p2p-playground-lite/pkg/storage"
 	"github.com/asjdf/p2p-playground-lite/pkg/transfer"
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
 )
 
 // Daemon coordinates all daemon components
 type Daemon struct {
-	config     *config.DaemonConfig
-	logger     types.Logger
-	host       *p2p.Host
-	discovery  *discovery.Service
-	storage    *storage.FileStorage
-	pkgMgr     *pkgmanager.Manager
-	runtime    *runtime.Runtime
-	transfer   *transfer.Manager
-	signer     *security.Signer
-	ctx        context.Context
-	cancelFunc context.CancelFunc
+	config         *config.DaemonConfig
+	logger         types.Logger
+	host           *p2p.Host
+	discovery      *discovery.Service // gossip backend of nodes, nil if not used
+	nodes          *discovery.Merged
+	pex            *pex.Service
+	messaging      *messaging.Router // routes messages between apps, nil if disabled
+	storage        *storage.FileStorage
+	pkgMgr         *pkgmanager.Manager
+	runtime        *runtime.Runtime
+	transfer       *transfer.Manager
+	signer         *security.Signer
+	admitter       *admission.Admitter
+	ownership      *ownership.Store
+	checksums      *integrity.Store
+	revisions      *revision.Store
+	labels         *applabel.Store
+	quotas         *quota.Tracker
+	auditLog       *audit.Log
+	dataKey        []byte
+	devices        []string
+	coreDumps      string // glob of the core dumps of apps in their working directory, "" if not collected
+	volumes        string // directory of the volumes of apps
+	snapshots      *volume.Store
+	bucket         *backup.Bucket   // bucket snapshots are backed up to, nil if not configured
+	registry       *registry.Bucket // package registry, nil if not configured
+	registryCache  *registry.Cache
+	appAPI         *appapi.Server
+	clusterKV      *kv.Store
+	kvSync         []*kv.Replicator
+	locks          *applock.Manager
+	history        *history.Store
+	events         *events.Store
+	alerts         *alert.Evaluator
+	metrics        *middleware.Metrics
+	subsystems     *lifecycle.Group
+	localSocket    string // path of the local socket served, "" if not
+	localSocketErr error  // why the local socket is not served, if it failed to be
+	counters       counters
+	startedAt      time.Time
+	ctx            context.Context
+	cancelFunc     context.CancelFunc
+}
+
+// Option configures optional daemon behavior
+type Option func(*options)
+
+// options holds optional daemon settings
+type options struct {
+	logger types.Logger
+}
+
+// WithLogger makes the daemon use the given logger instead of building a zap logger from config
+func WithLogger(l types.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithLoggerProvider makes the daemon use the logger supplied by p
+func WithLoggerProvider(p types.LoggerProvider) Option {
+	return func(o *options) {
+		o.logger = p.Logger()
+	}
+}
+
+// WithSlogHandler makes the daemon log through an slog.Handler
+func WithSlogHandler(h slog.Handler) Option {
+	return func(o *options) {
+		o.logger = logging.NewSlog(h)
+	}
 }
 
 // New creates a new daemon
-func New(cfg *config.DaemonConfig) (*Daemon, error) {
-	// Initialize logger
-	logger, err := logging.New(&cfg.Logging)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create logger: %w", err)
+func New(cfg *config.DaemonConfig, opts ...Option) (*Daemon, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Initialize logger (zap from config unless one was supplied)
+	logger := o.logger
+	if logger == nil {
+		var err error
+		logger, err = logging.New(&cfg.Logging)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create logger: %w", err)
+		}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(logging.NewContext(context.Background(), logger))
 
 	d := &Daemon{
 		config:     cfg,
 		logger:     logger,
+		locks:      applock.NewManager(),
 		ctx:        ctx,
 		cancelFunc: cancel,
 	}
@@ -58,90 +160,94 @@ func New(cfg *config.DaemonConfig) (*Daemon, error) {
 	return d, nil
 }
 
+// Logger returns the daemon's logger, implementing types.LoggerProvider
+func (d *Daemon) Logger() types.Logger {
+	return d.logger
+}
+
 // Start starts the daemon
 func (d *Daemon) Start() error {
-	d.logger.Info("starting P2P Playground daemon")
+	build := version.Get()
+	d.startedAt = time.Now()
+	d.logger.Info("starting P2P Playground daemon", "version", build.Version, "commit", build.Commit)
 
-	// Initialize storage
-	storage, err := storage.NewFileStorage(d.config.Storage.DataDir)
-	if err != nil {
-		return fmt.Errorf("failed to create storage: %w", err)
-	}
-	d.storage = storage
-	d.logger.Info("storage initialized", "path", d.config.Storage.DataDir)
+	// Keep a report of every panic recovered from, if configured
+	crash.SetReportDir(d.config.Logging.CrashDir)
 
-	// Load or generate keys
-	signer, err := security.LoadOrGenerateKeys(d.config.Storage.KeysDir, "node")
+	// Apply the security profile before anything reads the settings it enforces
+	enforced, err := d.config.ApplySecurityProfile()
 	if err != nil {
-		return fmt.Errorf("failed to load keys: %w", err)
-	}
-	d.signer = signer
-	d.logger.Info("keys loaded")
-
-	// Initialize P2P host
-	hostConfig := &p2p.HostConfig{
-		ListenAddrs:          d.config.Node.ListenAddrs,
-		PSK:                  d.config.Security.PSK,
-		EnableAuth:           d.config.Security.EnableAuth,
-		TrustedPeers:         d.config.Security.TrustedPeers,
-		BootstrapPeers:       d.config.Node.BootstrapPeers,
-		DisableDHT:           d.config.Node.DisableDHT,
-		DHTMode:              d.config.Node.DHTMode,
-		DisableNATService:    d.config.Node.DisableNATService,
-		DisableAutoRelay:     d.config.Node.DisableAutoRelay,
-		DisableHolePunching:  d.config.Node.DisableHolePunching,
-		DisableRelayService:  d.config.Node.DisableRelayService,
-		StaticRelays:         d.config.Node.StaticRelays,
-	}
-	host, err := p2p.NewHost(d.ctx, hostConfig, d.logger)
-	if err != nil {
-		return fmt.Errorf("failed to create P2P host: %w", err)
+		return err
+	}
+	for _, e := range enforced {
+		d.logger.Info("security profile enforced", "profile", d.config.Security.Profile, "setting", e.Key, "value", e.Value, "changed", e.Changed)
 	}
-	d.host = host
-
-	// Start diagnostic logging every 30 seconds
-	host.StartDiagnosticLogging(d.ctx, 30*time.Second)
 
-	// Enable mDNS if configured
-	if d.config.Node.EnableMDNS {
-		if err := host.EnableMDNS(d.ctx); err != nil {
-			d.logger.Warn("failed to enable mDNS", "error", err)
+	// Check the host before touching it, so that a broken one fails early
+	checks := preflight.Run(d.config)
+	for _, result := range checks.Results {
+		switch result.Status {
+		case preflight.StatusFail:
+			d.logger.Error("preflight check failed", "check", result.Check, "message", result.Message)
+		case preflight.StatusWarn:
+			d.logger.Warn("preflight check warning", "check", result.Check, "message", result.Message)
 		}
 	}
-
-	// Initialize discovery service for gossip-based node discovery
-	discoverySvc, err := discovery.NewService(host.LibP2PHost(), d.logger, &discovery.Config{
-		NodeName:   d.config.Node.Name,
-		NodeLabels: d.config.Node.Labels,
-		Version:    "0.1.0", // TODO: get from build info
-		Routing:    host.DHT(),
-	})
-	if err != nil {
-		d.logger.Warn("failed to create discovery service", "error", err)
-	} else {
-		d.discovery = discoverySvc
-		d.discovery.Start()
-		d.logger.Info("discovery service started")
+	if err := checks.Err(); err != nil {
+		return err
 	}
+	d.logger.Info("preflight checks passed",
+		"ok", checks.Count(preflight.StatusOK),
+		"warn", checks.Count(preflight.StatusWarn),
+		"skipped", checks.Count(preflight.StatusSkipped),
+		"results", checks.Results,
+	)
 
-	// Initialize package manager
-	d.pkgMgr = pkgmanager.New()
-
-	// Initialize runtime
-	d.runtime = runtime.New(d.logger)
+	// Advertise the interpreters of script apps found on this node as labels,
+	// leaving labels set in the config alone
+	names := d.config.Node.Interpreters
+	if len(names) == 0 {
+		names = interpreter.DefaultNames
+	}
+	if found := interpreter.Detect(names); len(found) > 0 {
+		if d.config.Node.Labels == nil {
+			d.config.Node.Labels = make(map[string]string)
+		}
+		for key, value := range found {
+			if _, ok := d.config.Node.Labels[key]; !ok {
+				d.config.Node.Labels[key] = value
+			}
+		}
+	}
 
-	// Initialize transfer manager
-	d.transfer = transfer.New(d.host, d.logger)
+	// Detect the devices apps may request
+	if !d.config.Node.DisableDevices {
+		patterns := d.config.Node.Devices
+		if len(patterns) == 0 {
+			patterns = device.DefaultPatterns
+		}
+		d.devices = device.Detect(patterns)
+		if len(d.devices) > 0 {
+			d.logger.Info("devices available to apps", "devices", d.devices)
+		}
+	}
 
-	// Register protocol handlers
-	d.host.SetStreamHandler(consts.DeployProtocolID, d.handleDeployRequest)
-	d.host.SetStreamHandler(consts.ListProtocolID, d.handleListRequest)
-	d.host.SetStreamHandler(consts.LogsProtocolID, d.handleLogsRequest)
+	// Start the subsystems in dependency order. If one fails, the ones
+	// started before it are stopped again in reverse order.
+	d.subsystems = lifecycle.New(d.logger, d.components()...)
+	if err := d.subsystems.Start(); err != nil {
+		d.cancelFunc()
+		return err
+	}
 
 	d.logger.Info("daemon started",
-		"peer_id", host.ID(),
-		"addrs", host.Addrs(),
+		"peer_id", d.host.ID(),
+		"addrs", d.host.Addrs(),
+		"data_dir", d.config.Storage.DataDir,
 	)
+	if err := d.writeConnectionCard(d.ConnectionCard()); err != nil {
+		d.logger.Warn("failed to write connection card", "error", err)
+	}
 
 	return nil
 }
@@ -150,57 +256,39 @@ func (d *Daemon) Start() error {
 func (d *Daemon) Stop() error {
 	d.logger.Info("stopping daemon")
 
-	if d.discovery != nil {
-		d.discovery.Stop()
+	// Stop the subsystems in the reverse order they started
+	var err error
+	if d.subsystems != nil {
+		err = d.subsystems.Stop()
 	}
 
 	if d.cancelFunc != nil {
 		d.cancelFunc()
 	}
 
-	if d.host != nil {
-		_ = d.host.Close()
-	}
-
 	d.logger.Info("daemon stopped")
-	return nil
-}
 
-// DeployPackage deploys a package
-func (d *Daemon) DeployPackage(ctx context.Context, pkgPath string) (*types.Application, error) {
-	d.logger.Info("deploying package", "path", pkgPath)
-
-	// Get manifest
-	manifest, err := d.pkgMgr.GetManifest(ctx, pkgPath)
-	if err != nil {
-		return nil, types.WrapError(err, "failed to get manifest")
+	// Log the summaries of repeated warnings, which would be lost otherwise
+	if s, ok := d.logger.(interface{ Sync() error }); ok {
+		_ = s.Sync()
 	}
+	return err
+}
 
-	// Create application directory
-	appID := fmt.Sprintf("%s-%s", manifest.Name, manifest.Version)
-	appDir := filepath.Join(d.config.Storage.AppsDir, appID)
-
-	// Unpack package
-	_, err = d.pkgMgr.Unpack(ctx, pkgPath, appDir)
-	if err != nil {
-		return nil, types.WrapError(err, "failed to unpack package")
+// DeployPackage deploys the package at pkgPath, which is moved into the packages
+// directory, replacing the deployed version of its application. With start the
+// application is started, and the deployment is rolled back if it fails to start.
+func (d *Daemon) DeployPackage(ctx context.Context, pkgPath string, start bool) (*types.Application, error) {
+	log := logging.FromContextOr(ctx, d.logger)
+	log.Info("deploying package", "path", pkgPath)
+	ctx = logging.NewContext(ctx, log)
+	if logging.RequestIDFromContext(ctx) == "" {
+		ctx = logging.WithRequestID(ctx, logging.NewRequestID())
 	}
-
-	// Create application
-	app := &types.Application{
-		ID:          appID,
-		Name:        manifest.Name,
-		Version:     manifest.Version,
-		PackagePath: pkgPath,
-		Manifest:    manifest,
-		Status:      types.AppStatusStopped,
-		WorkDir:     appDir,
-		Labels:      manifest.Labels,
+	if err := d.checkDeployFilesystems(ctx); err != nil {
+		return nil, err
 	}
-
-	d.logger.Info("package deployed", "app_id", appID)
-
-	return app, nil
+	return d.deploy(ctx, pkgPath, filepath.Base(pkgPath), deployOptions{start: start}, nil)
 }
 
 // StartApp starts an application
@@ -220,16 +308,87 @@ func (d *Daemon) ListApps(ctx context.Context) ([]*types.Application, error) {
 	return d.runtime.List(ctx)
 }
 
+// resolveAppID returns the ID of the deployed app ref names: its ID, its name
+// or <name>-<version> (see types.ResolveApp). If ref names no deployed app, it
+// is returned as is, so that what is recorded of apps no longer deployed is
+// still found by their ID.
+func (d *Daemon) resolveAppID(ctx context.Context, ref string) string {
+	apps, err := d.runtime.List(ctx)
+	if err != nil {
+		return ref
+	}
+	app, err := types.ResolveApp(apps, ref)
+	if err != nil {
+		return ref
+	}
+	if app.ID != ref {
+		logging.FromContext(ctx).Debug("app resolved", "ref", ref, "app_id", app.ID)
+	}
+	return app.ID
+}
+
 // GetNodeInfo returns node information
-func (d *Daemon) GetNodeInfo() *types.NodeInfo {
-	apps, _ := d.runtime.List(d.ctx)
+func (d *Daemon) GetNodeInfo(ctx context.Context) *types.NodeInfo {
+	apps, _ := d.runtime.List(ctx)
+	build := version.Get()
+	now := time.Now()
 
 	return &types.NodeInfo{
-		ID:     d.host.ID(),
-		Addrs:  d.host.Addrs(),
-		Labels: d.config.Node.Labels,
-		Apps:   apps,
+		ID:            d.host.ID(),
+		Name:          d.config.Node.Name,
+		Addrs:         d.host.Addrs(),
+		Labels:        d.config.Node.Labels,
+		Apps:          apps,
+		LastSeen:      now,
+		Version:       build.Version,
+		Commit:        build.Commit,
+		StartedAt:     d.startedAt,
+		UptimeSeconds: int64(now.Sub(d.startedAt).Seconds()),
+		Quotas:        d.quotaUsage(ctx),
+		Capacity:      capacity.Measure(d.config.Storage.AppsDir, d.config.Runtime.Reserved, apps),
+		Alerts:        d.activeAlerts(),
+		Protocols:     d.metrics.Snapshot(),
+		Crashes:       crash.Counts(),
+
+		Advertisements: d.nodes.Advertisements(),
+		Components:     d.subsystems.Status(),
+	}
+}
+
+// newRequestContext derives a context for a single protocol request.
+// The context carries a request ID and a logger tagged with it, so every log
+// line for the request can be correlated with the ID returned to the controller.
+// A caller-supplied ID is reused when valid; otherwise a fresh one is generated.
+// The context ends when the daemon stops or the request is cancelled, see
+// watchStream; the caller cancels it once the request is handled.
+func (d *Daemon) newRequestContext(protocol string, requestID string) (context.Context, context.CancelFunc) {
+	if !logging.ValidRequestID(requestID) {
+		requestID = logging.NewRequestID()
 	}
+	ctx, cancel := context.WithCancel(d.ctx)
+	ctx = logging.NewContext(ctx, logging.FromContext(d.ctx).With("protocol", protocol))
+	return logging.WithRequestID(ctx, requestID), cancel
+}
+
+// watchStream cancels the request of stream when the peer closes or resets
+// it, so that a controller that gave up or timed out does not leave the
+// daemon working for nobody. It reads what is left of the stream, so it is
+// called once the request has been read. Requests replacing deployed apps are
+// not watched: they complete, rather than leave an app half replaced.
+func watchStream(stream types.Stream, cancel context.CancelFunc) {
+	go func() {
+		_, _ = io.Copy(io.Discard, stream)
+		cancel()
+	}()
+}
+
+// readRequestHeader reads a length-prefixed JSON request header into v
+func readRequestHeader(stream types.Stream, v interface{}) error {
+	if err := wire.ReadMessage(stream, v); err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+
+	return nil
 }
 
 // DeployRequest represents a deployment request
@@ -237,110 +396,546 @@ type DeployRequest struct {
 	FileName  string `json:"file_name"`
 	FileSize  int64  `json:"file_size"`
 	AutoStart bool   `json:"auto_start"`
-	Signature []byte `json:"signature,omitempty"` // Ed25519 signature of the package file
+	Signature []byte `json:"signature,omitempty"`  // Ed25519 signature of the package file
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+
+	// ForceUnlock breaks the lock of another operation on the app instead of failing
+	ForceUnlock bool `json:"force_unlock,omitempty"`
+
+	// Progress asks for status frames, and heartbeats, ahead of the response
+	Progress bool `json:"progress,omitempty"`
+
+	// WaitHealthy succeeds only once the started app is healthy, rolling back otherwise
+	WaitHealthy bool `json:"wait_healthy,omitempty"`
+
+	// HealthTimeout bounds the wait for the app to become healthy (default 60s)
+	HealthTimeout time.Duration `json:"health_timeout,omitempty"`
+
+	// Digest names a package of the registry (sha256:<hex>) for the node to
+	// fetch instead of receiving it. The file name and size come from the
+	// registry, and so does the signature unless the request carries one.
+	Digest string `json:"digest,omitempty"`
 }
 
-// DeployResponse represents a deployment response
+// DeployResponse represents a deployment response. A status frame sent ahead
+// of it has Stage set; the final response never does.
 type DeployResponse struct {
-	Success bool   `json:"success"`
-	AppID   string `json:"app_id,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success   bool              `json:"success"`
+	AppID     string            `json:"app_id,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Code      string            `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string            `json:"request_id,omitempty"`
+	Stage     types.DeployStage `json:"stage,omitempty"`
+	Message   string            `json:"message,omitempty"`
+
+	// RolledBack is set when the new version failed to start or become
+	// healthy and the previous version was restored
+	RolledBack bool `json:"rolled_back,omitempty"`
+
+	// Restarted lists the previous versions the rollback restarted
+	Restarted []string `json:"restarted,omitempty"`
 }
 
 // handleDeployRequest handles incoming deploy requests
 func (d *Daemon) handleDeployRequest(stream types.Stream) {
-	defer func() { _ = stream.Close() }()
+	d.handleDeploy(stream, false)
+}
+
+// handleDeployRequestV2 handles deploy requests sending the package in CRC
+// frames, so a corrupted chunk aborts the transfer as soon as it arrives
+func (d *Daemon) handleDeployRequestV2(stream types.Stream) {
+	d.handleDeploy(stream, true)
+}
 
-	d.logger.Info("received deploy request")
+// handleDeploy handles a deploy request, whose package is sent in CRC frames
+// if framed is set
+func (d *Daemon) handleDeploy(stream types.Stream, framed bool) {
+	defer func() { _ = stream.Close() }()
 
 	// Read request header (JSON)
-	var headerSize uint32
-	if err := binary.Read(stream, binary.BigEndian, &headerSize); err != nil {
-		d.logger.Error("failed to read header size", "error", err)
-		d.sendDeployResponse(stream, false, "", err.Error())
-		return
-	}
+	var req DeployRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("deploy", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
 
-	headerBytes := make([]byte, headerSize)
-	if _, err := io.ReadFull(stream, headerBytes); err != nil {
-		d.logger.Error("failed to read header", "error", err)
-		d.sendDeployResponse(stream, false, "", err.Error())
+	log.Info("received deploy request")
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendDeployResponse(ctx, stream, "", fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
 		return
 	}
 
-	var req DeployRequest
-	if err := json.Unmarshal(headerBytes, &req); err != nil {
-		d.logger.Error("failed to parse request", "error", err)
-		d.sendDeployResponse(stream, false, "", err.Error())
-		return
+	// A package named by digest comes from the registry instead of the stream
+	if req.Digest != "" {
+		if err := d.resolveRegistered(ctx, &req); err != nil {
+			log.Warn("package refused", "digest", req.Digest, "error", err)
+			d.sendDeployResponse(ctx, stream, "", err)
+			return
+		}
 	}
 
-	d.logger.Info("deploy request details",
+	log.Info("deploy request details",
 		"file_name", req.FileName,
 		"file_size", req.FileSize,
 		"auto_start", req.AutoStart,
+		"wait_healthy", req.WaitHealthy,
 	)
 
-	// Save package to packages directory
-	pkgPath := filepath.Join(d.config.Storage.PackagesDir, req.FileName)
-	if err := d.receiveFile(stream, pkgPath, req.FileSize); err != nil {
-		d.logger.Error("failed to receive file", "error", err)
-		d.sendDeployResponse(stream, false, "", err.Error())
+	// Refuse oversized packages before accepting any of them
+	if err := transfer.CheckSize(req.FileSize, d.config.Runtime.MaxPackageSize); err != nil {
+		log.Warn("package refused", "error", err)
+		d.sendDeployResponse(ctx, stream, "", fmt.Errorf("%w (runtime.max_package_size)", err))
+		return
+	}
+	if err := d.checkDeployFilesystems(ctx); err != nil {
+		log.Warn("package refused", "error", err)
+		d.sendDeployResponse(ctx, stream, "", err)
+		return
+	}
+	if err := d.checkDeploySpace(req.FileSize); err != nil {
+		log.Warn("package refused", "error", err)
+		d.sendDeployResponse(ctx, stream, "", err)
+		return
+	}
+
+	// Receive into the staging area, so neither a failed transfer nor a
+	// concurrent deployment can clobber a deployed package
+	fileName := req.FileName
+	if !validFileName(fileName) {
+		d.sendDeployResponse(ctx, stream, "", fmt.Errorf("%w: invalid file name %q", types.ErrInvalidInput, req.FileName))
+		return
+	}
+	staging, err := newStagingDir(d.config.Storage.PackagesDir)
+	if err != nil {
+		d.sendDeployResponse(ctx, stream, "", err)
+		return
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+	pkgPath := filepath.Join(staging, fileName)
+	if req.Digest != "" {
+		err = d.stageRegistered(ctx, req.Digest, pkgPath)
+	} else {
+		err = d.receiveFile(ctx, stream, pkgPath, req.FileSize, framed)
+	}
+	if err != nil {
+		log.Error("failed to receive file", "error", err)
+		d.sendDeployResponse(ctx, stream, "", err)
+		return
+	}
+
+	// Report progress from here on if the controller asked for it
+	progress := newDeployProgress(ctx, stream, req.Progress)
+	defer progress.stop()
+	respond := func(appID string, err error) {
+		progress.stop()
+		d.sendDeployResponse(ctx, stream, appID, err)
+	}
+	progress.report(types.DeployStageReceived, fmt.Sprintf("Package received (%d bytes)", req.FileSize))
+
+	app, err := d.deployReceived(ctx, pkgPath, fileName, &req, "deploy", p2p.RemotePeer(stream), progress)
+	if err != nil {
+		respond("", err)
 		return
 	}
+	respond(app.ID, nil)
+}
+
+// deployReceived verifies, admits and deploys the package received for req
+// at pkgPath, for an operation a peer, holder, requested
+func (d *Daemon) deployReceived(ctx context.Context, pkgPath, fileName string, req *DeployRequest, operation, holder string, progress *deployProgress) (*types.Application, error) {
+	log := logging.FromContext(ctx)
 
 	// Verify signature if provided
+	var signer *policy.Signer
 	if len(req.Signature) > 0 {
-		d.logger.Info("verifying package signature")
-		if err := d.verifyPackageSignature(pkgPath, req.Signature); err != nil {
-			d.logger.Error("signature verification failed", "error", err)
-			d.sendDeployResponse(stream, false, "", fmt.Sprintf("signature verification failed: %v", err))
-			return
+		log.Info("verifying package signature")
+		verified, err := d.verifyPackageSignature(ctx, pkgPath, req.Signature)
+		if err != nil {
+			log.Error("signature verification failed", "error", err)
+			return nil, fmt.Errorf("signature verification failed: %w", err)
 		}
-		d.logger.Info("package signature verified successfully")
+		log.Info("package signature verified successfully", "signer", verified.Name)
+		signer = verified
 	} else if !d.config.Security.AllowUnsignedPackages {
 		// No signature provided and unsigned packages not allowed
-		d.logger.Error("unsigned package rejected", "allow_unsigned_packages", d.config.Security.AllowUnsignedPackages)
-		d.sendDeployResponse(stream, false, "", "package signature required: unsigned packages are not allowed (set allow_unsigned_packages: true to permit)")
-		return
+		log.Error("unsigned package rejected", "allow_unsigned_packages", d.config.Security.AllowUnsignedPackages)
+		return nil, fmt.Errorf("%w: unsigned packages are not allowed (set allow_unsigned_packages: true to permit)", types.ErrPackageNotSigned)
 	} else {
-		d.logger.Warn("package deployed without signature verification", "allow_unsigned_packages", true)
+		log.Warn("package deployed without signature verification", "allow_unsigned_packages", true)
+	}
+	ctx = runtime.WithInitiator(ctx, operatorInitiator(ctx, operation, holder, signer))
+
+	// Serialize operations on the app
+	release, err := d.lockApp(ctx, pkgPath, operation, holder, req.ForceUnlock)
+	if err != nil {
+		log.Warn("deployment refused", "error", err)
+		return nil, err
+	}
+	defer release()
+
+	// Enforce policy and run admission hooks before unpacking anything
+	if err := d.admit(ctx, pkgPath, req, signer); err != nil {
+		log.Warn("deployment rejected", "error", err)
+		return nil, err
+	}
+
+	// Hold the operator's quota until the deployment is done
+	reservation, err := d.reserveQuota(ctx, pkgPath, req.FileSize, signer)
+	if err != nil {
+		log.Warn("deployment rejected", "error", err)
+		return nil, err
+	}
+	defer reservation.Cancel()
+
+	progress.report(types.DeployStageVerified, "Package verified")
+
+	// Record the artifact in the transparency log while the package is still plaintext
+	checksum, err := d.pkgMgr.CalculateChecksum(pkgPath)
+	if err != nil {
+		log.Warn("failed to checksum package", "error", err)
 	}
 
-	// Deploy package
-	app, err := d.DeployPackage(d.ctx, pkgPath)
+	// Replace the deployed version, rolling back on any failure
+	opts := deployOptions{start: req.AutoStart}
+	opts.revision.SHA256 = checksum
+	opts.revision.Size = req.FileSize
+	if signer != nil {
+		opts.revision.Signer = signer.Name
+		opts.revision.Signature = req.Signature
+	}
+	if req.AutoStart && req.WaitHealthy {
+		opts.healthTimeout = req.HealthTimeout
+		if opts.healthTimeout <= 0 {
+			opts.healthTimeout = defaultHealthTimeout
+		}
+	}
+	app, err := d.deploy(ctx, pkgPath, fileName, opts, progress)
 	if err != nil {
-		d.logger.Error("failed to deploy package", "error", err)
-		d.sendDeployResponse(stream, false, "", err.Error())
+		log.Error("failed to deploy package", "error", err)
+		return nil, err
+	}
+
+	// The first key to deploy an app name owns it
+	d.claimOwnership(ctx, app.Name, signer)
+
+	if err := reservation.Commit(ctx); err != nil {
+		log.Warn("failed to record quota usage", "error", err)
+	}
+
+	d.recordDeployment(ctx, app, checksum, req.FileSize, signer)
+	return app, nil
+}
+
+// operatorInitiator returns the initiator of an operation a controller
+// requested: the operator is the key that signed the package, or else the
+// peer ID of the controller
+func operatorInitiator(ctx context.Context, operation string, peerID string, signer *policy.Signer) types.Initiator {
+	id := peerID
+	if signer != nil {
+		id = signer.Name
+	}
+	return types.Initiator{
+		Kind:   types.InitiatorOperator,
+		ID:     id,
+		Reason: fmt.Sprintf("%s, request %s", operation, logging.RequestIDFromContext(ctx)),
+	}
+}
+
+// lockApp locks the app of a received package for an operation, first breaking
+// the lock of any other operation on it if force is set
+func (d *Daemon) lockApp(ctx context.Context, pkgPath string, operation string, holder string, force bool) (func(), error) {
+	log := logging.FromContext(ctx)
+
+	manifest, err := d.pkgMgr.GetManifest(ctx, pkgPath)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to get manifest")
+	}
+
+	if force {
+		if broken := d.locks.ForceUnlock(manifest.Name); broken != nil {
+			log.Warn("lock forcibly broken", "lock", broken.String(), "holder", holder)
+		}
+	}
+
+	release, err := d.locks.Acquire(manifest.Name, applock.Lock{
+		Operation: operation,
+		RequestID: logging.RequestIDFromContext(ctx),
+		Holder:    holder,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// admit checks that the node can run the app and provides the devices it
+// needs, enforces app ownership and the manifest policy, and runs the
+// configured admission hooks against a received package
+func (d *Daemon) admit(ctx context.Context, pkgPath string, req *DeployRequest, signer *policy.Signer) error {
+	manifest, err := d.pkgMgr.GetManifest(ctx, pkgPath)
+	if err != nil {
+		return types.WrapError(err, "failed to get manifest")
+	}
+
+	// Reject apps built for another platform
+	if err := platform.Check(manifest.Platform); err != nil {
+		return err
+	}
+
+	// Reject scripts whose interpreter this node does not have
+	if manifest.Interpreter != "" {
+		if _, err := interpreter.Lookup(manifest.Interpreter); err != nil {
+			return err
+		}
+	}
+
+	// Reject apps needing devices this node does not have
+	if _, err := device.Resolve(manifest.Devices, d.devices); err != nil {
+		return err
+	}
+
+	// Reject apps that do not fit next to the deployed ones once the node
+	// reserves headroom for itself
+	if !d.config.Runtime.Reserved.IsZero() {
+		if err := d.checkCapacity(ctx, manifest); err != nil {
+			return err
+		}
+	}
+
+	if d.admitter == nil && d.ownership == nil && policy.IsEmpty(&d.config.Policy) {
+		return nil
+	}
+
+	if d.ownership != nil {
+		var publicKey []byte
+		if signer != nil {
+			publicKey = signer.PublicKey
+		}
+		if err := d.ownership.Verify(ctx, manifest.Name, publicKey); err != nil {
+			return err
+		}
+	}
+
+	if err := policy.Check(&d.config.Policy, &policy.Input{
+		Manifest: manifest,
+		Signed:   signer != nil,
+		Signer:   signer,
+	}); err != nil {
+		return err
+	}
+
+	return d.admitter.Admit(ctx, &admission.Request{
+		RequestID: logging.RequestIDFromContext(ctx),
+		AppID:     types.LegacyAppID(manifest.Name, manifest.Version),
+		Manifest:  manifest,
+		FileName:  req.FileName,
+		FileSize:  req.FileSize,
+		Signed:    len(req.Signature) > 0,
+		AutoStart: req.AutoStart,
+		Node: admission.NodeInfo{
+			Name:   d.config.Node.Name,
+			Labels: d.config.Node.Labels,
+		},
+	})
+}
+
+// checkCapacity checks that the resource limits of manifest fit in the free
+// capacity of the node. The versions of the app it replaces do not count.
+func (d *Daemon) checkCapacity(ctx context.Context, manifest *types.Manifest) error {
+	apps, err := d.runtime.List(ctx)
+	if err != nil {
+		return types.WrapError(err, "failed to list apps")
+	}
+	others := make([]*types.Application, 0, len(apps))
+	for _, app := range apps {
+		if app.Name != manifest.Name {
+			others = append(others, app)
+		}
+	}
+	return capacity.Check(capacity.Measure(d.config.Storage.AppsDir, d.config.Runtime.Reserved, others), manifest.Resources)
+}
+
+// claimOwnership records signer as the owner of app if it has none yet
+func (d *Daemon) claimOwnership(ctx context.Context, app string, signer *policy.Signer) {
+	if d.ownership == nil || signer == nil {
 		return
 	}
 
-	// Auto-start if requested
-	if req.AutoStart {
-		if err := d.runtime.Start(d.ctx, app); err != nil {
-			d.logger.Warn("failed to auto-start application", "error", err)
-			// Don't fail the deployment, just log the warning
-		} else {
-			d.logger.Info("application started", "app_id", app.ID)
+	log := logging.FromContext(ctx)
+	claimed, err := d.ownership.Claim(ctx, app, signer.Name, signer.PublicKey)
+	if err != nil {
+		// The deployment already succeeded; the next signed deploy will claim it
+		log.Warn("failed to record app ownership", "app", app, "error", err)
+		return
+	}
+	if claimed {
+		log.Info("app ownership claimed", "app", app, "signer", signer.Name)
+	}
+}
+
+// recordDeployment appends a deployed package to the transparency log
+func (d *Daemon) recordDeployment(ctx context.Context, app *types.Application, checksum string, size int64, signer *policy.Signer) {
+	log := logging.FromContext(ctx)
+
+	if checksum == "" {
+		log.Warn("failed to record deployment in audit log", "error", "package checksum unavailable")
+		return
+	}
+	manifestDigest, err := audit.ManifestDigest(app.Manifest)
+	if err != nil {
+		log.Warn("failed to record deployment in audit log", "error", err)
+		return
+	}
+
+	entry := &audit.Entry{
+		AppID:           app.ID,
+		PackageChecksum: checksum,
+		PackageSize:     size,
+		ManifestDigest:  manifestDigest,
+		RequestID:       logging.RequestIDFromContext(ctx),
+	}
+	if signer != nil {
+		entry.Signer = signer.Name
+		entry.SignerKey = hex.EncodeToString(signer.PublicKey)
+	}
+
+	if err := d.auditLog.Append(entry); err != nil {
+		log.Warn("failed to record deployment in audit log", "error", err)
+		return
+	}
+	log.Info("deployment recorded in audit log", "seq", entry.Seq, "hash", entry.Hash)
+}
+
+// recordSession appends a session to the audit log if security.record_sessions is enabled
+func (d *Daemon) recordSession(ctx context.Context, session, peerID, appID, transcriptHash string, transcriptSize int64, start time.Time) {
+	if !d.config.Security.RecordSessions {
+		return
+	}
+	log := logging.FromContext(ctx)
+
+	entry := &audit.Entry{
+		Time:           start,
+		AppID:          appID,
+		Kind:           audit.KindSession,
+		Session:        session,
+		Peer:           peerID,
+		DurationMillis: time.Since(start).Milliseconds(),
+		TranscriptHash: transcriptHash,
+		TranscriptSize: transcriptSize,
+		RequestID:      logging.RequestIDFromContext(ctx),
+	}
+	if err := d.auditLog.Append(entry); err != nil {
+		log.Warn("failed to record session in audit log", "error", err)
+		return
+	}
+	log.Info("session recorded in audit log", "session", session, "peer", peerID, "seq", entry.Seq)
+}
+
+// recordTransition appends an app being started, stopped, paused, resumed or
+// exiting to the audit log, with who or what initiated it
+func (d *Daemon) recordTransition(app *types.Application, event types.AppEvent) {
+	switch event {
+	case types.AppEventStarted, types.AppEventStopped, types.AppEventExited, types.AppEventPaused, types.AppEventResumed:
+	default:
+		return
+	}
+	if d.auditLog == nil {
+		return
+	}
+
+	entry := &audit.Entry{
+		AppID:     app.ID,
+		Kind:      audit.KindLifecycle,
+		Event:     event,
+		Initiator: app.Initiator,
+	}
+	if err := d.auditLog.Append(entry); err != nil {
+		d.logger.Warn("failed to record transition in audit log", "app_id", app.ID, "event", event, "error", err)
+	}
+}
+
+// checkDeployFilesystems checks that apps can be deployed and write their
+// logs, so a node whose apps directory was remounted read-only refuses a
+// deployment with a clear error instead of failing to unpack it. Network
+// filesystems are allowed, with a warning.
+func (d *Daemon) checkDeployFilesystems(ctx context.Context) error {
+	log := logging.FromContext(ctx)
+	dirs := []struct{ key, dir string }{
+		{"storage.apps_dir", d.config.Storage.AppsDir},
+		{"runtime.scratch_dir", d.config.Runtime.ScratchDir},
+	}
+	for _, dir := range dirs {
+		if dir.dir == "" {
+			continue
+		}
+		fs, err := preflight.CheckFilesystem(dir.key, dir.dir)
+		if errors.Is(err, types.ErrNotImplemented) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fs.Network {
+			log.Warn("deploying to a network filesystem", "setting", dir.key, "path", dir.dir, "type", fs.Type)
 		}
 	}
+	return nil
+}
 
-	d.sendDeployResponse(stream, true, app.ID, "")
+// checkDeploySpace checks that a package of size bytes and its unpacked
+// files fit on disk, leaving the reserved disk space free, so a node short of space refuses the deployment before
+// receiving it instead of failing midway
+func (d *Daemon) checkDeploySpace(size int64) error {
+	// No disk holds an exabyte, and capping keeps the sums below from overflowing
+	size = min(size, 1<<60)
+
+	// An encrypted package is written next to the plaintext one
+	packageBytes := size
+	if d.config.Storage.EncryptAtRest {
+		packageBytes *= 2
+	}
+	err := preflight.CheckSpace(
+		preflight.SpaceNeed{Dir: d.config.Storage.PackagesDir, Bytes: packageBytes},
+		preflight.SpaceNeed{Dir: d.config.Storage.AppsDir, Bytes: size*preflight.UnpackFactor + d.config.Runtime.Reserved.DiskMB<<20},
+	)
+	if errors.Is(err, types.ErrNotImplemented) {
+		return nil
+	}
+	return err
 }
 
-// receiveFile receives file content from stream
-func (d *Daemon) receiveFile(stream types.Stream, destPath string, expectedSize int64) error {
+// receiveFile receives file content from stream, sent in CRC frames if framed is set
+func (d *Daemon) receiveFile(ctx context.Context, stream types.Stream, destPath string, expectedSize int64, framed bool) (err error) {
+	defer func() {
+		if err != nil {
+			d.counters.transfersFailed.Add(1)
+		} else {
+			d.counters.transfersReceived.Add(1)
+		}
+	}()
+
 	file, err := d.storage.CreateFile(destPath)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
 	defer func() { _ = file.Close() }()
 
-	buf := make([]byte, 64*1024) // 64KB chunks
+	chunkSize := d.config.Node.TransferChunkSize
+	if chunkSize <= 0 {
+		chunkSize = transfer.DefaultChunkSize
+	}
+	buf := make([]byte, chunkSize)
 	var received int64
 
+	var src io.Reader = stream
+	if framed {
+		src = transfer.NewFrameReader(stream)
+	}
 	for received < expectedSize {
-		n, err := stream.Read(buf)
+		n, err := src.Read(buf)
 		if err != nil && err != io.EOF {
 			return fmt.Errorf("failed to read chunk: %w", err)
 		}
@@ -350,243 +945,536 @@ func (d *Daemon) receiveFile(stream types.Stream, destPath string, expectedSize
 		}
 
 		if _, err := file.Write(buf[:n]); err != nil {
-			return fmt.Errorf("failed to write chunk: %w", err)
+			if errors.Is(err, syscall.ENOSPC) {
+				return fmt.Errorf("failed to write chunk: %w", types.ErrInsufficientStorage)
+			}
+			return fmt.Errorf("failed to write chunk: %w: %w", types.ErrStorageWrite, err)
 		}
 
 		received += int64(n)
+		d.counters.bytesReceived.Add(uint64(n))
 	}
 
 	if received != expectedSize {
-		return fmt.Errorf("incomplete transfer: received %d of %d bytes", received, expectedSize)
+		return fmt.Errorf("%w: incomplete transfer: received %d of %d bytes", types.ErrStreamClosed, received, expectedSize)
 	}
 
-	d.logger.Info("file received", "path", destPath, "size", received)
+	logging.FromContext(ctx).Info("file received", "path", destPath, "size", received)
 	return nil
 }
 
 // sendDeployResponse sends deployment response
-func (d *Daemon) sendDeployResponse(stream types.Stream, success bool, appID string, errMsg string) {
-	resp := DeployResponse{
-		Success: success,
-		AppID:   appID,
-		Error:   errMsg,
-	}
+func (d *Daemon) sendDeployResponse(ctx context.Context, stream types.Stream, appID string, respErr error) {
+	log := logging.FromContext(ctx)
 
-	respBytes, err := json.Marshal(resp)
-	if err != nil {
-		d.logger.Error("failed to marshal response", "error", err)
-		return
+	if respErr != nil {
+		d.counters.deploysFailed.Add(1)
+	} else {
+		d.counters.deploysSucceeded.Add(1)
 	}
 
-	// Send response size
-	respSize := uint32(len(respBytes))
-	if err := binary.Write(stream, binary.BigEndian, respSize); err != nil {
-		d.logger.Error("failed to send response size", "error", err)
-		return
+	resp := DeployResponse{
+		Success:   respErr == nil,
+		AppID:     appID,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
+	}
+	var rolledBack *rolledBackError
+	if errors.As(respErr, &rolledBack) {
+		resp.RolledBack = true
+		resp.Restarted = rolledBack.restarted
 	}
 
-	// Send response
-	if _, err := stream.Write(respBytes); err != nil {
-		d.logger.Error("failed to send response", "error", err)
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
 		return
 	}
 
-	d.logger.Info("deploy response sent", "success", success, "app_id", appID)
+	log.Info("deploy response sent", "success", respErr == nil, "app_id", appID)
+}
+
+// defaultListPageSize is how many records a page of version 3 of the list
+// protocol carries when the request does not say, and maxListPageSize the
+// most it can ask for
+const (
+	defaultListPageSize = 50
+	maxListPageSize     = 500
+)
+
+// ListAppsRequest is the request of version 3 of the list protocol; earlier
+// versions take none
+type ListAppsRequest struct {
+	Cursor    string   `json:"cursor,omitempty"`     // List the apps after the one with this ID, in the order listed
+	Limit     int      `json:"limit,omitempty"`      // Most apps to list, 0 for all
+	PageSize  int      `json:"page_size,omitempty"`  // Records per page (default 50, at most 500)
+	Fields    []string `json:"fields,omitempty"`     // Record fields to send (types.RecordField*), empty for all
+	Sort      string   `json:"sort,omitempty"`       // Order of the apps (types.AppOrder*), by ID if empty
+	RequestID string   `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+
+	// AppFilter selects the apps listed, all if empty
+	types.AppFilter
 }
 
-// ListAppsResponse represents the response for list apps request
+// ListAppsResponse represents the response for list apps request, or a page
+// of it in version 3 of the protocol
 type ListAppsResponse struct {
-	Success bool                 `json:"success"`
-	Apps    []*types.Application `json:"apps,omitempty"`
-	Error   string               `json:"error,omitempty"`
+	Success   bool                 `json:"success"`
+	Apps      []*types.Application `json:"apps,omitempty"`
+	Statuses  []*types.AppStatus   `json:"statuses,omitempty"` // Status of each app, in the order of Apps
+	Error     string               `json:"error,omitempty"`
+	Code      string               `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string               `json:"request_id,omitempty"`
+
+	// Records replace Apps and Statuses in version 2 of the protocol
+	Records []*types.AppRecord `json:"records,omitempty"`
+
+	// More says another page follows this one on the stream (version 3)
+	More bool `json:"more,omitempty"`
+
+	// NextCursor is, in the last page, the cursor listing the apps the
+	// limit left out, if any (version 3)
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // handleListRequest handles incoming list apps requests
 func (d *Daemon) handleListRequest(stream types.Stream) {
+	d.handleList(stream, false)
+}
+
+// handleListRequestV2 handles incoming list apps requests of version 2,
+// which report the spec and runtime status of each app apart
+func (d *Daemon) handleListRequestV2(stream types.Stream) {
+	d.handleList(stream, true)
+}
+
+// handleList answers a list apps request, with records if v2
+func (d *Daemon) handleList(stream types.Stream, v2 bool) {
 	defer func() { _ = stream.Close() }()
 
-	d.logger.Info("received list apps request")
+	ctx, cancel := d.newRequestContext("list", "")
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received list apps request", "v2", v2)
+	watchStream(stream, cancel)
 
 	// Get all applications
-	apps, err := d.runtime.List(d.ctx)
+	apps, err := d.runtime.List(ctx)
 	if err != nil {
-		d.logger.Error("failed to list apps", "error", err)
-		d.sendListResponse(stream, false, nil, err.Error())
+		log.Error("failed to list apps", "error", err)
+		d.sendListResponse(ctx, stream, nil, nil, v2, err)
 		return
 	}
 
-	d.sendListResponse(stream, true, apps, "")
+	statuses := make([]*types.AppStatus, 0, len(apps))
+	for _, app := range apps {
+		statuses = append(statuses, d.listedStatus(ctx, app))
+	}
+
+	d.sendListResponse(ctx, stream, apps, statuses, v2, nil)
 }
 
-// sendListResponse sends list apps response
-func (d *Daemon) sendListResponse(stream types.Stream, success bool, apps []*types.Application, errMsg string) {
-	resp := ListAppsResponse{
-		Success: success,
-		Apps:    apps,
-		Error:   errMsg,
+// handleListRequestV3 handles incoming list apps requests of version 3,
+// sending the records the request selects in pages of their own, so that
+// nodes with many apps need no single large response
+func (d *Daemon) handleListRequestV3(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req ListAppsRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("list", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received list apps request", "v3", true, "cursor", req.Cursor, "limit", req.Limit, "page_size", req.PageSize,
+		"fields", req.Fields, "sort", req.Sort, "statuses", req.Statuses, "labels", req.Labels, "name", req.Name)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendListResponse(ctx, stream, nil, nil, true, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
 	}
 
-	respBytes, err := json.Marshal(resp)
+	watchStream(stream, cancel)
+	if req.Limit < 0 || req.PageSize < 0 {
+		d.sendListResponse(ctx, stream, nil, nil, true, fmt.Errorf("%w: negative limit or page size", types.ErrInvalidInput))
+		return
+	}
+	if err := req.AppFilter.Validate(); err != nil {
+		d.sendListResponse(ctx, stream, nil, nil, true, err)
+		return
+	}
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = defaultListPageSize
+	}
+	pageSize = min(pageSize, maxListPageSize)
+
+	apps, err := d.runtime.List(ctx)
 	if err != nil {
-		d.logger.Error("failed to marshal response", "error", err)
+		log.Error("failed to list apps", "error", err)
+		d.sendListResponse(ctx, stream, nil, nil, true, err)
 		return
 	}
 
-	// Send response size
-	respSize := uint32(len(respBytes))
-	if err := binary.Write(stream, binary.BigEndian, respSize); err != nil {
-		d.logger.Error("failed to send response size", "error", err)
+	// Filter and order the apps here, sparing the controller all the others
+	statuses := make([]*types.AppStatus, 0, len(apps))
+	for _, app := range apps {
+		if req.Matches(app) {
+			statuses = append(statuses, d.listedStatus(ctx, app))
+		}
+	}
+	if err := types.SortAppStatuses(statuses, req.Sort); err != nil {
+		d.sendListResponse(ctx, stream, nil, nil, true, err)
+		return
+	}
+	statuses, next, err := types.PageAppStatuses(statuses, req.Sort, req.Cursor, req.Limit)
+	if err != nil {
+		d.sendListResponse(ctx, stream, nil, nil, true, err)
 		return
 	}
 
-	// Send response
-	if _, err := stream.Write(respBytes); err != nil {
-		d.logger.Error("failed to send response", "error", err)
+	pages := 0
+	for start := 0; ; start += pageSize {
+		end := min(start+pageSize, len(statuses))
+		page := &ListAppsResponse{
+			Success:   true,
+			RequestID: logging.RequestIDFromContext(ctx),
+			More:      end < len(statuses),
+		}
+		if !page.More {
+			page.NextCursor = next
+		}
+		for _, status := range statuses[start:end] {
+			page.Records = append(page.Records, status.Record().Select(req.Fields))
+		}
+		if err := writeListResponse(stream, page); err != nil {
+			log.Error("failed to send list page", "error", err)
+			return
+		}
+		pages++
+		if !page.More {
+			break
+		}
+	}
+
+	log.Info("list response sent", "app_count", len(statuses), "pages", pages)
+}
+
+// listedStatus returns the status of a listed app
+func (d *Daemon) listedStatus(ctx context.Context, app *types.Application) *types.AppStatus {
+	status, err := d.runtime.Status(ctx, app.ID)
+	if err != nil {
+		// Removed since it was listed
+		status = &types.AppStatus{App: app, AppHealth: types.AppHealth{Message: string(app.Status)}}
+	}
+	return status
+}
+
+// sendListResponse sends list apps response, as records if v2
+func (d *Daemon) sendListResponse(ctx context.Context, stream types.Stream, apps []*types.Application, statuses []*types.AppStatus, v2 bool, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := ListAppsResponse{
+		Success:   respErr == nil,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
+	}
+	if v2 {
+		for _, status := range statuses {
+			resp.Records = append(resp.Records, status.Record())
+		}
+	} else {
+		resp.Apps = apps
+		resp.Statuses = statuses
+	}
+
+	if err := writeListResponse(stream, &resp); err != nil {
+		log.Error("failed to send response", "error", err)
 		return
 	}
 
-	d.logger.Info("list response sent", "app_count", len(apps))
+	log.Info("list response sent", "app_count", len(apps))
+}
+
+// writeListResponse writes a length-prefixed list apps response or page
+func writeListResponse(stream types.Stream, resp *ListAppsResponse) error {
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		return fmt.Errorf("failed to send response: %w", err)
+	}
+	return nil
 }
 
 // LogsRequest represents a logs request
 type LogsRequest struct {
-	AppID  string `json:"app_id"`
-	Follow bool   `json:"follow"`
-	Tail   int    `json:"tail"` // Number of lines from end, 0 for all
+	AppID     string        `json:"app_id"`
+	Follow    bool          `json:"follow"`
+	Tail      int           `json:"tail"`                 // Number of lines from end, 0 for all
+	Since     time.Duration `json:"since,omitempty"`      // Only lines written within this long, 0 for all
+	RequestID string        `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
 }
 
 // LogsResponse represents a logs response
 type LogsResponse struct {
-	Success bool   `json:"success"`
-	Logs    string `json:"logs,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success   bool   `json:"success"`
+	Logs      string `json:"logs,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // handleLogsRequest handles incoming logs requests
 func (d *Daemon) handleLogsRequest(stream types.Stream) {
 	defer func() { _ = stream.Close() }()
 
-	d.logger.Info("received logs request")
-
 	// Read request header
-	var headerSize uint32
-	if err := binary.Read(stream, binary.BigEndian, &headerSize); err != nil {
-		d.logger.Error("failed to read header size", "error", err)
-		d.sendLogsResponse(stream, false, "", err.Error())
-		return
-	}
+	var req LogsRequest
+	headerErr := readRequestHeader(stream, &req)
 
-	headerBytes := make([]byte, headerSize)
-	if _, err := io.ReadFull(stream, headerBytes); err != nil {
-		d.logger.Error("failed to read header", "error", err)
-		d.sendLogsResponse(stream, false, "", err.Error())
-		return
-	}
+	ctx, cancel := d.newRequestContext("logs", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
 
-	var req LogsRequest
-	if err := json.Unmarshal(headerBytes, &req); err != nil {
-		d.logger.Error("failed to parse request", "error", err)
-		d.sendLogsResponse(stream, false, "", err.Error())
+	log.Info("received logs request")
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendLogsResponse(ctx, stream, "", fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
 		return
 	}
 
-	d.logger.Info("logs request details", "app_id", req.AppID, "follow", req.Follow, "tail", req.Tail)
+	req.AppID = d.resolveAppID(ctx, req.AppID)
+
+	watchStream(stream, cancel)
 
-	// Get logs
-	logsReader, err := d.runtime.Logs(d.ctx, req.AppID, req.Follow)
+	log.Info("logs request details", "app_id", req.AppID, "follow", req.Follow, "tail", req.Tail, "since", req.Since)
+	start := time.Now()
+
+	opts := types.LogOptions{Follow: req.Follow, Tail: req.Tail}
+	if req.Since > 0 {
+		opts.Since = start.Add(-req.Since)
+	}
+	logsReader, err := d.runtime.Logs(ctx, req.AppID, opts)
 	if err != nil {
-		d.logger.Error("failed to get logs", "error", err)
-		d.sendLogsResponse(stream, false, "", err.Error())
+		log.Error("failed to get logs", "error", err)
+		d.sendLogsResponse(ctx, stream, "", err)
 		return
 	}
 	defer func() { _ = logsReader.Close() }()
 
-	// Read all logs
+	if req.Follow {
+		// Lines are streamed after the response until either side closes the
+		// stream, which ends the request
+		d.sendLogsResponse(ctx, stream, "", nil)
+		stop := context.AfterFunc(ctx, func() { _ = logsReader.Close() })
+		defer stop()
+
+		transcript := sha256.New()
+		size, err := io.Copy(io.MultiWriter(stream, transcript), logsReader)
+		if errors.Is(err, io.ErrClosedPipe) {
+			// The controller left
+			err = nil
+		}
+		log.Info("log stream ended", "size", size, "error", err)
+		d.recordSession(ctx, audit.SessionLogs, p2p.RemotePeer(stream), req.AppID, hex.EncodeToString(transcript.Sum(nil)), size, start)
+		return
+	}
+
 	logsBytes, err := io.ReadAll(logsReader)
 	if err != nil {
-		d.logger.Error("failed to read logs", "error", err)
-		d.sendLogsResponse(stream, false, "", err.Error())
+		log.Error("failed to read logs", "error", err)
+		d.sendLogsResponse(ctx, stream, "", err)
+		return
+	}
+
+	d.sendLogsResponse(ctx, stream, string(logsBytes), nil)
+	d.recordSession(ctx, audit.SessionLogs, p2p.RemotePeer(stream), req.AppID, audit.TranscriptHash(logsBytes), int64(len(logsBytes)), start)
+}
+
+// sendLogsResponse sends logs response
+func (d *Daemon) sendLogsResponse(ctx context.Context, stream types.Stream, logs string, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := LogsResponse{
+		Success:   respErr == nil,
+		Logs:      logs,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
+	}
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("logs response sent", "log_size", len(logs))
+}
+
+// OwnershipRequest represents an app ownership inspection request
+type OwnershipRequest struct {
+	App       string `json:"app,omitempty"`        // Optional app name, empty for all apps
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// OwnershipResponse represents an app ownership inspection response
+type OwnershipResponse struct {
+	Success   bool                `json:"success"`
+	Enabled   bool                `json:"enabled"`
+	Records   []*ownership.Record `json:"records,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	Code      string              `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string              `json:"request_id,omitempty"`
+}
+
+// handleOwnershipRequest handles incoming app ownership inspection requests.
+// Ownership is read-only over the network; transfers are done locally by an admin.
+func (d *Daemon) handleOwnershipRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req OwnershipRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("ownership", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received ownership request", "app", req.App)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendOwnershipResponse(ctx, stream, nil, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	if d.ownership == nil {
+		d.sendOwnershipResponse(ctx, stream, nil, nil)
 		return
 	}
 
-	logs := string(logsBytes)
+	records, err := d.ownership.List(ctx)
+	if err != nil {
+		log.Error("failed to list ownership records", "error", err)
+		d.sendOwnershipResponse(ctx, stream, nil, err)
+		return
+	}
 
-	// Apply tail if requested
-	if req.Tail > 0 {
-		lines := make([]string, 0)
-		for _, line := range splitLines(logs) {
-			if line != "" {
-				lines = append(lines, line)
+	if req.App != "" {
+		filtered := records[:0]
+		for _, rec := range records {
+			if rec.App == req.App {
+				filtered = append(filtered, rec)
 			}
 		}
-		if len(lines) > req.Tail {
-			lines = lines[len(lines)-req.Tail:]
-		}
-		logs = joinLines(lines)
+		records = filtered
 	}
 
-	d.sendLogsResponse(stream, true, logs, "")
+	d.sendOwnershipResponse(ctx, stream, records, nil)
 }
 
-// sendLogsResponse sends logs response
-func (d *Daemon) sendLogsResponse(stream types.Stream, success bool, logs string, errMsg string) {
-	resp := LogsResponse{
-		Success: success,
-		Logs:    logs,
-		Error:   errMsg,
+// sendOwnershipResponse sends an ownership response
+func (d *Daemon) sendOwnershipResponse(ctx context.Context, stream types.Stream, records []*ownership.Record, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := OwnershipResponse{
+		Success:   respErr == nil,
+		Enabled:   d.ownership != nil,
+		Records:   records,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
 	}
 
-	respBytes, err := json.Marshal(resp)
-	if err != nil {
-		d.logger.Error("failed to marshal response", "error", err)
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
 		return
 	}
 
-	// Send response size
-	respSize := uint32(len(respBytes))
-	if err := binary.Write(stream, binary.BigEndian, respSize); err != nil {
-		d.logger.Error("failed to send response size", "error", err)
+	log.Info("ownership response sent", "record_count", len(records))
+}
+
+// AuditRequest represents a transparency log request
+type AuditRequest struct {
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// AuditResponse represents a transparency log response.
+// The full chain is returned so the caller can verify it independently.
+type AuditResponse struct {
+	Success   bool           `json:"success"`
+	Entries   []*audit.Entry `json:"entries,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Code      string         `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string         `json:"request_id,omitempty"`
+}
+
+// handleAuditRequest handles incoming transparency log requests
+func (d *Daemon) handleAuditRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req AuditRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("audit", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received audit request")
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendAuditResponse(ctx, stream, nil, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
 		return
 	}
 
-	// Send response
-	if _, err := stream.Write(respBytes); err != nil {
-		d.logger.Error("failed to send response", "error", err)
+	watchStream(stream, cancel)
+
+	entries, err := d.auditLog.Entries()
+	if err != nil {
+		log.Error("failed to read audit log", "error", err)
+		d.sendAuditResponse(ctx, stream, nil, err)
 		return
 	}
 
-	d.logger.Info("logs response sent", "log_size", len(logs))
+	d.sendAuditResponse(ctx, stream, entries, nil)
 }
 
-// Helper functions
-func splitLines(s string) []string {
-	lines := make([]string, 0)
-	start := 0
-	for i, c := range s {
-		if c == '\n' {
-			lines = append(lines, s[start:i])
-			start = i + 1
-		}
+// sendAuditResponse sends a transparency log response
+func (d *Daemon) sendAuditResponse(ctx context.Context, stream types.Stream, entries []*audit.Entry, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := AuditResponse{
+		Success:   respErr == nil,
+		Entries:   entries,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
 	}
-	if start < len(s) {
-		lines = append(lines, s[start:])
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
 	}
-	return lines
+
+	log.Info("audit response sent", "entry_count", len(entries))
 }
 
-func joinLines(lines []string) string {
-	result := ""
-	for i, line := range lines {
-		result += line
-		if i < len(lines)-1 {
-			result += "\n"
-		}
+// errorMessage returns err's message, or "" for a nil error
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
 	}
-	return result
+	return err.Error()
 }
 
 // verifyPackageSignature verifies the package signature against trusted public keys
-func (d *Daemon) verifyPackageSignature(packagePath string, signature []byte) error {
+// and returns the identity of the key that signed it
+func (d *Daemon) verifyPackageSignature(ctx context.Context, packagePath string, signature []byte) (*policy.Signer, error) {
+	log := logging.FromContext(ctx)
+
 	// Get public keys directory
 	pubKeysDir := d.config.Security.PublicKeysDir
 	if pubKeysDir == "" {
@@ -595,17 +1483,17 @@ func (d *Daemon) verifyPackageSignature(packagePath string, signature []byte) er
 
 	// Check if directory exists
 	if _, err := os.Stat(pubKeysDir); os.IsNotExist(err) {
-		return fmt.Errorf("trusted public keys directory not found: %s", pubKeysDir)
+		return nil, fmt.Errorf("%w: trusted public keys directory not found: %s", types.ErrInvalidSignature, pubKeysDir)
 	}
 
 	// Try to verify with each public key in the directory
 	entries, err := os.ReadDir(pubKeysDir)
 	if err != nil {
-		return types.WrapError(err, "failed to read public keys directory")
+		return nil, types.WrapError(err, "failed to read public keys directory")
 	}
 
 	if len(entries) == 0 {
-		return fmt.Errorf("no trusted public keys found in %s", pubKeysDir)
+		return nil, fmt.Errorf("%w: no trusted public keys found in %s", types.ErrInvalidSignature, pubKeysDir)
 	}
 
 	// Try each public key file
@@ -617,16 +1505,19 @@ func (d *Daemon) verifyPackageSignature(packagePath string, signature []byte) er
 		pubKeyPath := filepath.Join(pubKeysDir, entry.Name())
 		pubKey, err := security.LoadPublicKey(pubKeyPath)
 		if err != nil {
-			d.logger.Warn("failed to load public key", "file", entry.Name(), "error", err)
+			log.Warn("failed to load public key", "file", entry.Name(), "error", err)
 			continue
 		}
 
 		// Try to verify with this public key
 		if err := security.VerifyFile(packagePath, signature, pubKey); err == nil {
-			d.logger.Info("signature verified", "public_key", entry.Name())
-			return nil
+			log.Info("signature verified", "public_key", entry.Name())
+			return &policy.Signer{
+				Name:      strings.TrimSuffix(entry.Name(), ".pub"),
+				PublicKey: pubKey,
+			}, nil
 		}
 	}
 
-	return types.ErrInvalidSignature
+	return nil, types.ErrInvalidSignature
 }
@@ -0,0 +1,687 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/integrity"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/revision"
+	"github.com/asjdf/p2p-playground-lite/pkg/runtime"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// stagingDirName is the directory, inside the packages and apps directories,
+// where deployments are prepared before they replace anything
+const stagingDirName = ".staging"
+
+// newStagingDir creates a directory of its own in the staging area of dir,
+// for the caller to remove. Its name is chosen here rather than derived from
+// the request, so operations neither share a directory nor leave the area.
+func newStagingDir(dir string) (string, error) {
+	staging := filepath.Join(dir, stagingDirName)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging area: %w", err)
+	}
+	path, err := os.MkdirTemp(staging, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return path, nil
+}
+
+// validFileName reports whether name, as sent by a peer, names a file
+// directly inside a directory
+func validFileName(name string) bool {
+	return name != "" && name == filepath.Base(name) && name != "." && name != ".."
+}
+
+// cleanStaging removes what interrupted deployments left in the staging areas
+func (d *Daemon) cleanStaging() {
+	for _, dir := range []string{d.config.Storage.PackagesDir, d.config.Storage.AppsDir} {
+		staging := filepath.Join(dir, stagingDirName)
+		if err := os.RemoveAll(staging); err != nil {
+			d.logger.Warn("failed to clean staging area", "path", staging, "error", err)
+		}
+	}
+}
+
+// deployment replaces an application with a new version as a transaction.
+// Every step that changes the node is recorded with the step undoing it, and
+// the steps are undone in reverse order unless the deployment is committed.
+type deployment struct {
+	d     *Daemon
+	ctx   context.Context
+	undo  []func() error
+	done  []func()
+	apps  []*types.Application // stopped old versions, restarted on rollback
+	title string
+}
+
+// rollback undoes every recorded step, most recent first, and returns the
+// IDs of the previous versions restarted. Rolling back again does nothing.
+// The apps stopped and started are initiated by the rollback of whatever
+// initiated the deployment.
+func (t *deployment) rollback() []string {
+	log := logging.FromContext(t.ctx)
+	if initiator := runtime.InitiatorFromContext(t.ctx); initiator != nil && (len(t.undo) > 0 || len(t.apps) > 0) {
+		rollback := *initiator
+		rollback.Reason = "rollback of " + rollback.Reason
+		t.ctx = runtime.WithInitiator(t.ctx, rollback)
+	}
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		if err := t.undo[i](); err != nil {
+			log.Error("failed to roll back deployment step", "app_id", t.title, "error", err)
+		}
+	}
+	var restarted []string
+	for _, app := range t.apps {
+		if err := t.d.runtime.Start(t.ctx, app); err != nil {
+			log.Error("failed to restart previous version", "app_id", app.ID, "error", err)
+			continue
+		}
+		log.Info("previous version restarted", "app_id", app.ID)
+		restarted = append(restarted, app.ID)
+	}
+	if len(t.undo) > 0 || len(t.apps) > 0 {
+		log.Warn("deployment rolled back", "app_id", t.title)
+	}
+	t.undo, t.apps = nil, nil
+	return restarted
+}
+
+// start starts the new version of the app and waits up to window for it to
+// become healthy or, unless healthy is set, only to keep running for a
+// moment. Stopping and removing it is recorded as a step to undo.
+func (t *deployment) start(app *types.Application, window time.Duration, healthy bool) error {
+	log := logging.FromContext(t.ctx)
+	if err := t.d.runtime.Start(t.ctx, app); err != nil {
+		return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
+	}
+	log.Info("application started", "app_id", app.ID)
+	t.undo = append(t.undo, func() error {
+		if err := t.d.runtime.Stop(t.ctx, app.ID); err != nil && !errors.Is(err, types.ErrAppNotRunning) {
+			return err
+		}
+		if err := t.d.runtime.Remove(t.ctx, app.ID); err != nil && !errors.Is(err, types.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(t.ctx, window)
+	defer cancel()
+	if !healthy {
+		return t.d.runtime.WaitStarted(ctx, app.ID)
+	}
+	if err := t.d.runtime.WaitHealthy(ctx, app.ID); err != nil {
+		return err
+	}
+	log.Info("application healthy", "app_id", app.ID)
+	return nil
+}
+
+// commit discards what the deployment replaced
+func (t *deployment) commit() {
+	for _, done := range t.done {
+		done()
+	}
+	t.undo, t.apps = nil, nil
+}
+
+// replace moves staged to target, setting aside whatever target held until
+// the deployment is committed or rolled back
+func (t *deployment) replace(staged string, target string) error {
+	backup := target + ".previous"
+	hadTarget := false
+	if _, err := os.Lstat(target); err == nil {
+		if err := os.RemoveAll(backup); err != nil {
+			return types.WrapError(err, "failed to remove stale backup")
+		}
+		if err := os.Rename(target, backup); err != nil {
+			return types.WrapError(err, "failed to set aside previous version")
+		}
+		hadTarget = true
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return types.WrapError(err, "failed to create directory")
+	}
+	if err := os.Rename(staged, target); err != nil {
+		if hadTarget {
+			_ = os.Rename(backup, target)
+		}
+		return types.WrapError(err, "failed to move new version into place")
+	}
+
+	t.undo = append(t.undo, func() error {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		if hadTarget {
+			return os.Rename(backup, target)
+		}
+		return nil
+	})
+	if hadTarget {
+		t.done = append(t.done, func() { _ = os.RemoveAll(backup) })
+	}
+	return nil
+}
+
+// stopPrevious stops the running versions of an app that the new one replaces:
+// the same app ID, whose directory is about to be replaced, and with start
+// also the other versions of the app
+func (t *deployment) stopPrevious(app *types.Application, start bool) error {
+	log := logging.FromContext(t.ctx)
+	running, err := t.d.runtime.List(t.ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, old := range running {
+		if old.Status != types.AppStatusRunning && old.Status != types.AppStatusPaused {
+			continue
+		}
+		sameVersion := old.ID == app.ID || (old.Name == app.Name && old.Version == app.Version)
+		if !sameVersion && (!start || old.Name != app.Name) {
+			continue
+		}
+		if err := t.d.runtime.Stop(t.ctx, old.ID); err != nil && !errors.Is(err, types.ErrAppNotRunning) {
+			return fmt.Errorf("failed to stop previous version %s: %w", old.ID, err)
+		}
+		log.Info("previous version stopped", "app_id", old.ID)
+		t.apps = append(t.apps, old)
+	}
+	return nil
+}
+
+// removeReplaced removes, once the deployment is committed, the deployments
+// of the version of app under other IDs, which formats with {instance} or
+// {hash} give every deployment. They are found in the runtime and, for those
+// deployed before the daemon restarted, in the revision history. If app takes
+// no deployment's directory, it gets the logs of the latest of them.
+func (t *deployment) removeReplaced(app *types.Application, stagedDir string) error {
+	running, err := t.d.runtime.List(t.ctx)
+	if err != nil {
+		return err
+	}
+	history, err := t.d.revisions.List(t.ctx, app.Name)
+	if err != nil {
+		return err
+	}
+
+	replaced := make(map[string]*types.Application)
+	for _, old := range running {
+		if old.ID != app.ID && old.Name == app.Name && old.Version == app.Version {
+			replaced[old.ID] = old
+		}
+	}
+	for _, rev := range history {
+		if _, ok := replaced[rev.AppID]; ok || rev.AppID == app.ID || rev.Version != app.Version {
+			continue
+		}
+		replaced[rev.AppID] = &types.Application{AppSpec: types.AppSpec{
+			ID:       rev.AppID,
+			Name:     app.Name,
+			Version:  rev.Version,
+			WorkDir:  filepath.Join(t.d.config.Storage.AppsDir, rev.AppID),
+			Revision: rev.Number,
+		}}
+	}
+
+	var latest *types.Application
+	for _, old := range replaced {
+		if latest == nil || old.Revision > latest.Revision {
+			latest = old
+		}
+		t.done = append(t.done, func() { t.d.removeDeployment(t.ctx, old) })
+	}
+	if latest == nil {
+		return nil
+	}
+	if _, err := os.Stat(app.WorkDir); err == nil {
+		return nil
+	}
+
+	logs := filepath.Join(stagedDir, "logs")
+	if t.d.config.Runtime.ScratchDir != "" {
+		logs = t.d.runtime.LogDir(app)
+		scratch := filepath.Dir(logs)
+		t.undo = append(t.undo, func() error { return os.RemoveAll(scratch) })
+	}
+	if _, err := os.Stat(t.d.runtime.LogDir(latest)); err == nil {
+		if err := copyDir(t.d.runtime.LogDir(latest), logs); err != nil {
+			logging.FromContext(t.ctx).Warn("failed to keep logs of previous deployment", "app_id", latest.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// removeDeployment removes a stopped deployment that another one replaced:
+// its runtime record, its directories and its recorded checksums. What
+// cannot be removed is logged and left behind.
+func (d *Daemon) removeDeployment(ctx context.Context, app *types.Application) {
+	log := logging.FromContext(ctx)
+	if err := d.runtime.Remove(ctx, app.ID); err != nil && !errors.Is(err, types.ErrNotFound) {
+		log.Warn("failed to remove replaced deployment", "app_id", app.ID, "error", err)
+		return
+	}
+	dirs := []string{app.WorkDir}
+	if d.config.Runtime.ScratchDir != "" {
+		dirs = append(dirs, filepath.Dir(d.runtime.LogDir(app)))
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warn("failed to remove directory of replaced deployment", "app_id", app.ID, "path", dir, "error", err)
+		}
+	}
+	if err := d.checksums.Delete(ctx, app.ID); err != nil && !errors.Is(err, types.ErrNotFound) {
+		log.Warn("failed to remove checksums of replaced deployment", "app_id", app.ID, "error", err)
+	}
+	log.Info("replaced deployment removed", "app_id", app.ID)
+}
+
+// recordChecksums records the checksums of the deployed files of app,
+// restoring the record it replaces on rollback. Logs are written at runtime
+// and left out.
+func (t *deployment) recordChecksums(app *types.Application) error {
+	files, err := integrity.Scan(app.WorkDir, "logs")
+	if err != nil {
+		return err
+	}
+
+	previous, err := t.d.checksums.Load(t.ctx, app.ID)
+	if err != nil && !errors.Is(err, types.ErrNotFound) {
+		return err
+	}
+	rec := &integrity.Record{AppID: app.ID, RecordedAt: time.Now().UTC(), Files: files}
+	if err := t.d.checksums.Save(t.ctx, rec); err != nil {
+		return err
+	}
+
+	t.undo = append(t.undo, func() error {
+		if previous != nil {
+			return t.d.checksums.Save(t.ctx, previous)
+		}
+		return t.d.checksums.Delete(t.ctx, app.ID)
+	})
+	return nil
+}
+
+// defaultHealthTimeout is how long a deployment waits for the app to become
+// healthy when the controller asks to wait without a timeout
+const defaultHealthTimeout = 60 * time.Second
+
+// startWindow is how long a deployment waits for a started app to keep
+// running for a moment when the controller does not wait for it to become healthy
+const startWindow = 10 * time.Second
+
+// rolledBackError reports a deployment whose new version failed to start or
+// become healthy and was rolled back
+type rolledBackError struct {
+	err       error
+	restarted []string // Previous versions restarted
+}
+
+// Error implements the error interface
+func (e *rolledBackError) Error() string {
+	if len(e.restarted) == 0 {
+		return fmt.Sprintf("rolled back: %v", e.err)
+	}
+	return fmt.Sprintf("rolled back to %s: %v", strings.Join(e.restarted, ", "), e.err)
+}
+
+// Unwrap returns the failure of the new version
+func (e *rolledBackError) Unwrap() error {
+	return e.err
+}
+
+// deployOptions controls what a deployment does once the app is replaced
+type deployOptions struct {
+	// start starts the new version
+	start bool
+
+	// healthTimeout, if set, is how long the started version has to become
+	// healthy before the deployment fails. Otherwise it only has to keep
+	// running for a moment.
+	healthTimeout time.Duration
+
+	// revision describes the package for the revision history; the
+	// deployment fills in the rest
+	revision revision.Revision
+}
+
+// deploy unpacks a staged package and replaces the deployed version of its
+// application with it. The previous version keeps running until the new one
+// is unpacked and verified, and is restored if anything fails after that.
+// When the new version fails to start or become healthy, it is rolled back
+// too, unless the manifest says to leave it in place. The staged package is
+// consumed on success. Progress, if not nil, is told about each step.
+func (d *Daemon) deploy(ctx context.Context, stagedPkg string, fileName string, opts deployOptions, progress *deployProgress) (app *types.Application, err error) {
+	log := logging.FromContext(ctx)
+	if !validFileName(fileName) {
+		return nil, fmt.Errorf("%w: invalid file name %q", types.ErrInvalidInput, fileName)
+	}
+
+	// Unpack and verify the new version next to the deployed one
+	staging, err := newStagingDir(d.config.Storage.AppsDir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+	stagedDir := filepath.Join(staging, "app")
+
+	progress.report(types.DeployStageUnpacking, "Unpacking package")
+	manifest, err := d.pkgMgr.Unpack(ctx, stagedPkg, stagedDir)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to unpack package")
+	}
+	if err := verifyUnpacked(manifest, stagedDir); err != nil {
+		return nil, err
+	}
+
+	number, err := d.revisions.Next(ctx, manifest.Name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.revision.SHA256 == "" && strings.Contains(d.config.Runtime.AppIDFormat, types.AppIDHash) {
+		if opts.revision.SHA256, err = d.pkgMgr.CalculateChecksum(stagedPkg); err != nil {
+			log.Warn("failed to checksum package for its app ID", "error", err)
+		}
+	}
+	appID := types.FormatAppID(d.config.Runtime.AppIDFormat, types.AppIDParts{
+		Name:     manifest.Name,
+		Version:  manifest.Version,
+		SHA256:   opts.revision.SHA256,
+		Instance: number,
+	})
+
+	// Labels set on a version stay with it, whatever the ID of its deployments
+	overrides, err := d.labels.Get(ctx, types.LegacyAppID(manifest.Name, manifest.Version))
+	if err != nil {
+		return nil, err
+	}
+	app = types.NewApplication(types.AppSpec{
+		ID:          appID,
+		Name:        manifest.Name,
+		Version:     manifest.Version,
+		PackagePath: filepath.Join(d.config.Storage.PackagesDir, fileName),
+		Manifest:    manifest,
+		WorkDir:     filepath.Join(d.config.Storage.AppsDir, appID),
+		Labels:      overrides.Apply(manifest.Labels),
+	})
+	app.Revision = number
+
+	t := &deployment{d: d, ctx: ctx, title: appID}
+	defer func() {
+		if err != nil {
+			t.rollback()
+		}
+	}()
+
+	// Only now that the new version is ready, make way for it
+	if err := t.stopPrevious(app, opts.start); err != nil {
+		return nil, err
+	}
+
+	// Keep the logs of the version being replaced, unless they are kept
+	// in the scratch directory where replacing it leaves them
+	previousLogs := filepath.Join(app.WorkDir, "logs")
+	if _, statErr := os.Stat(previousLogs); statErr == nil && d.config.Runtime.ScratchDir == "" {
+		if err := copyDir(previousLogs, filepath.Join(stagedDir, "logs")); err != nil {
+			log.Warn("failed to keep logs of previous version", "error", err)
+		}
+	}
+	if err := t.removeReplaced(app, stagedDir); err != nil {
+		return nil, err
+	}
+
+	if err := t.replace(stagedDir, app.WorkDir); err != nil {
+		return nil, err
+	}
+
+	// Encrypt before moving the package, so a plaintext copy never lands in the packages directory
+	app.PackagePath = stagedPkg
+	if err := d.encryptAtRest(ctx, app); err != nil {
+		return nil, err
+	}
+	finalPkg := filepath.Join(d.config.Storage.PackagesDir, fileName)
+	if app.PackagePath != stagedPkg {
+		finalPkg += security.EncryptedSuffix
+	}
+	if err := t.replace(app.PackagePath, finalPkg); err != nil {
+		return nil, err
+	}
+	app.PackagePath = finalPkg
+
+	// Record the files as deployed, for verify requests to detect changes
+	if err := t.recordChecksums(app); err != nil {
+		return nil, err
+	}
+
+	if opts.start {
+		progress.report(types.DeployStageStarting, "Starting "+app.ID)
+		window, healthy := opts.healthTimeout, opts.healthTimeout > 0
+		if !healthy {
+			window = startWindow
+		}
+		if err := t.start(app, window, healthy); err != nil {
+			if manifest.OnFailure == types.OnFailureLeave {
+				return nil, d.leaveFailed(t, app, opts.revision, err)
+			}
+			return nil, d.rollBackFailed(t, app, err)
+		}
+		if healthy {
+			progress.report(types.DeployStageHealthy, app.ID+" is healthy")
+		}
+	}
+
+	rev, err := d.recordRevision(ctx, app, opts.revision)
+	if err != nil {
+		return nil, err
+	}
+
+	t.commit()
+	log.Info("package deployed", "app_id", appID, "revision", app.Revision)
+	requestID := logging.RequestIDFromContext(ctx)
+	message := fmt.Sprintf("revision %d, request %s", app.Revision, requestID)
+	if rev.RollbackTo > 0 {
+		message = fmt.Sprintf("revision %d, rollback to revision %d, request %s", app.Revision, rev.RollbackTo, requestID)
+	}
+	d.recordEvent(app, types.AppEventDeployed, message, runtime.InitiatorFromContext(ctx))
+	return app, nil
+}
+
+// recordRevision records the deployment of app in its revision history. As
+// nothing can fail after the revision is recorded, it needs no undo.
+func (d *Daemon) recordRevision(ctx context.Context, app *types.Application, rev revision.Revision) (*revision.Revision, error) {
+	rev.Number = app.Revision
+	rev.AppID = app.ID
+	rev.Version = app.Version
+	rev.Package = filepath.Base(app.PackagePath)
+	rev.RequestID = logging.RequestIDFromContext(ctx)
+	rev.DeployedAt = time.Now().UTC()
+	if err := d.revisions.Record(ctx, app.Name, &rev); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// rollBackFailed rolls back a deployment whose new version failed to start or
+// become healthy, recording the rollback as an event of the new version
+func (d *Daemon) rollBackFailed(t *deployment, app *types.Application, cause error) error {
+	restarted := t.rollback()
+	message := fmt.Sprintf("revision %d failed, rolled back, request %s: %v", app.Revision, logging.RequestIDFromContext(t.ctx), cause)
+	if len(restarted) > 0 {
+		message = fmt.Sprintf("revision %d failed, rolled back to %s, request %s: %v", app.Revision, strings.Join(restarted, ", "), logging.RequestIDFromContext(t.ctx), cause)
+	}
+	d.recordEvent(app, types.AppEventRolledBack, message, runtime.InitiatorFromContext(t.ctx))
+	return &rolledBackError{err: cause, restarted: restarted}
+}
+
+// leaveFailed keeps a new version that failed to start or become healthy in
+// place, as the manifest asks, recording it as deployed
+func (d *Daemon) leaveFailed(t *deployment, app *types.Application, rev revision.Revision, cause error) error {
+	if _, err := d.recordRevision(t.ctx, app, rev); err != nil {
+		return err
+	}
+	t.commit()
+	logging.FromContext(t.ctx).Warn("deployed version failed, left in place", "app_id", app.ID, "revision", app.Revision, "error", cause)
+	d.recordEvent(app, types.AppEventDeployed, fmt.Sprintf("revision %d failed and was left in place, request %s: %v", app.Revision, logging.RequestIDFromContext(t.ctx), cause), runtime.InitiatorFromContext(t.ctx))
+	return fmt.Errorf("left in place (on_failure: %s): %w", types.OnFailureLeave, cause)
+}
+
+// deployProgress sends status frames of a deployment to the controller, and a
+// heartbeat whenever there has been nothing to report for a while. A nil
+// deployProgress reports nothing.
+type deployProgress struct {
+	ctx    context.Context
+	stream types.Stream
+
+	mu       sync.Mutex
+	last     time.Time
+	stopOnce sync.Once
+	stopped  chan struct{}
+	done     chan struct{}
+}
+
+// newDeployProgress starts reporting the progress of a deployment, or returns
+// nil if the controller did not ask for it
+func newDeployProgress(ctx context.Context, stream types.Stream, enabled bool) *deployProgress {
+	if !enabled {
+		return nil
+	}
+	p := &deployProgress{
+		ctx:     ctx,
+		stream:  stream,
+		last:    time.Now(),
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.heartbeat()
+	return p
+}
+
+// heartbeat reports that the deployment is still in progress until stopped
+func (p *deployProgress) heartbeat() {
+	defer close(p.done)
+	ticker := time.NewTicker(consts.DeployHeartbeatInterval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopped:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			idle := time.Since(p.last) >= consts.DeployHeartbeatInterval
+			p.mu.Unlock()
+			if idle {
+				p.report(types.DeployStageHeartbeat, "")
+			}
+		}
+	}
+}
+
+// report sends a status frame
+func (p *deployProgress) report(stage types.DeployStage, message string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.stopped:
+		return
+	default:
+	}
+	p.last = time.Now()
+
+	err := wire.WriteMessage(p.stream, DeployResponse{
+		Stage:     stage,
+		Message:   message,
+		RequestID: logging.RequestIDFromContext(p.ctx),
+	})
+	if err != nil {
+		logging.FromContext(p.ctx).Debug("failed to send deploy status", "stage", stage, "error", err)
+	}
+}
+
+// stop stops reporting, so the final response can be sent
+func (p *deployProgress) stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		close(p.stopped)
+		p.mu.Unlock()
+		<-p.done
+	})
+}
+
+// verifyUnpacked checks that an unpacked package can run
+func verifyUnpacked(manifest *types.Manifest, dir string) error {
+	if manifest == nil {
+		return fmt.Errorf("%w: package has no manifest", types.ErrInvalidPackage)
+	}
+	if err := pkgmanager.ValidateManifest(manifest); err != nil {
+		return err
+	}
+
+	entrypoint := filepath.Join(dir, manifest.Entrypoint)
+	info, err := os.Stat(entrypoint)
+	if err != nil {
+		return fmt.Errorf("%w: entrypoint %s not found in package", types.ErrInvalidPackage, manifest.Entrypoint)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: entrypoint %s is not a file", types.ErrInvalidPackage, manifest.Entrypoint)
+	}
+	return nil
+}
+
+// copyDir copies the regular files under src to dst
+func copyDir(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = in.Close() }()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			_ = out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,243 @@
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/daemon"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// startDaemon starts a daemon keeping its data in a temporary directory
+func startDaemon(t *testing.T) *config.DaemonConfig {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	cfg, err := config.LoadDaemonConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Node.Name = "test"
+	cfg.Node.ListenAddrs = []string{"/ip4/127.0.0.1/tcp/0"}
+	cfg.Node.EnableMDNS = false
+	cfg.Node.DisableDHT = true
+	cfg.Storage.DataDir = filepath.Join(dir, "data")
+	cfg.Storage.PackagesDir = filepath.Join(dir, "data", "packages")
+	cfg.Storage.AppsDir = filepath.Join(dir, "data", "apps")
+	cfg.Storage.KeysDir = filepath.Join(dir, "data", "keys")
+	cfg.Node.IdentityFile = filepath.Join(cfg.Storage.KeysDir, "identity.key")
+	cfg.Security.PublicKeysDir = filepath.Join(cfg.Storage.KeysDir, "trusted")
+	cfg.Security.AllowUnsignedPackages = true
+
+	d, err := daemon.New(cfg, daemon.WithLogger(logging.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = d.Stop() })
+	return cfg
+}
+
+// buildPackage packs an app whose entrypoint runs script
+func buildPackage(t *testing.T, name, version, script string) string {
+	t.Helper()
+
+	appDir := filepath.Join(t.TempDir(), name)
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := "name: " + name + "\nversion: \"" + version + "\"\nentrypoint: run.sh\n"
+	if err := os.WriteFile(filepath.Join(appDir, "manifest.yaml"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "run.sh"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pkgPath, err := pkgmanager.New().Pack(context.Background(), appDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkgPath
+}
+
+// deploy sends a package over the local socket of the daemon configured
+// with cfg and returns the final response
+func deploy(t *testing.T, cfg *config.DaemonConfig, req daemon.DeployRequest, data []byte) daemon.DeployResponse {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stream, err := p2p.DialLocal(ctx, daemon.LocalSocket(cfg), consts.DeployProtocolID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	req.FileSize = int64(len(data))
+	if err := wire.WriteMessage(stream, &req); err != nil {
+		t.Fatal(err)
+	}
+	var resp daemon.DeployResponse
+	if _, err := stream.Write(data); err != nil {
+		// The daemon may refuse the request before reading the package
+		t.Logf("sending package: %v", err)
+	}
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	return resp
+}
+
+// deployFile deploys the package at pkgPath
+func deployFile(t *testing.T, cfg *config.DaemonConfig, pkgPath string, req daemon.DeployRequest) daemon.DeployResponse {
+	t.Helper()
+
+	data, err := os.ReadFile(pkgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.FileName = filepath.Base(pkgPath)
+	return deploy(t, cfg, req, data)
+}
+
+// checkStagingEmpty checks that no deployment left anything staged
+func checkStagingEmpty(t *testing.T, cfg *config.DaemonConfig) {
+	t.Helper()
+
+	for _, dir := range []string{cfg.Storage.PackagesDir, cfg.Storage.AppsDir} {
+		entries, err := os.ReadDir(filepath.Join(dir, ".staging"))
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			t.Errorf("%s left in the staging area of %s", entry.Name(), dir)
+		}
+	}
+}
+
+func TestDeployRefusesFileNames(t *testing.T) {
+	cfg := startDaemon(t)
+	pkgPath := buildPackage(t, "app", "1.0.0", "sleep 60")
+	data, err := os.ReadFile(pkgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	marker := filepath.Join(cfg.Storage.DataDir, "marker")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"", ".", "..", "../app.tar.gz", "sub/app.tar.gz", "/app.tar.gz"} {
+		resp := deploy(t, cfg, daemon.DeployRequest{FileName: name}, data)
+		if resp.Success || resp.Code != types.ErrorCode(types.ErrInvalidInput) {
+			t.Errorf("deploy of file name %q = %+v, want %s", name, resp, types.ErrorCode(types.ErrInvalidInput))
+		}
+	}
+
+	// The data directory, which ".." resolves to, was not replaced
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("data directory replaced: %v", err)
+	}
+	checkStagingEmpty(t, cfg)
+}
+
+func TestDeployStagesEachRequestApart(t *testing.T) {
+	cfg := startDaemon(t)
+	first := buildPackage(t, "first", "1.0.0", "sleep 60")
+	second := buildPackage(t, "second", "1.0.0", "sleep 60")
+
+	// Request IDs are the caller's; neither these nor a dot-only one may
+	// name the staging directory
+	var wg sync.WaitGroup
+	responses := make([]daemon.DeployResponse, 3)
+	for i, pkgPath := range []string{first, second, first} {
+		requestID := "same"
+		if i == 2 {
+			requestID = ".."
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = deployFile(t, cfg, pkgPath, daemon.DeployRequest{RequestID: requestID})
+		}()
+	}
+	wg.Wait()
+
+	for i, resp := range responses {
+		if !resp.Success {
+			t.Errorf("deploy %d failed: %s", i, resp.Error)
+		}
+	}
+	if responses[2].RequestID == ".." {
+		t.Error("request ID .. was used")
+	}
+	for _, pkgPath := range []string{first, second} {
+		if _, err := os.Stat(filepath.Join(cfg.Storage.PackagesDir, filepath.Base(pkgPath))); err != nil {
+			t.Errorf("package not deployed: %v", err)
+		}
+	}
+	if _, err := os.Stat(cfg.Storage.AppsDir); err != nil {
+		t.Errorf("apps directory: %v", err)
+	}
+	checkStagingEmpty(t, cfg)
+}
+
+func TestDeployUndoesFailedDeployment(t *testing.T) {
+	cfg := startDaemon(t)
+	good := buildPackage(t, "app", "1.0.0", "sleep 60")
+	resp := deployFile(t, cfg, good, daemon.DeployRequest{})
+	if !resp.Success {
+		t.Fatalf("deploy failed: %s", resp.Error)
+	}
+	deployed, err := os.ReadFile(filepath.Join(cfg.Storage.AppsDir, resp.AppID, "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A version failing to start is rolled back
+	bad := buildPackage(t, "app", "2.0.0", "exit 1")
+	resp = deployFile(t, cfg, bad, daemon.DeployRequest{AutoStart: true})
+	if resp.Success || !resp.RolledBack {
+		t.Fatalf("deploy of a failing version = %+v, want it rolled back", resp)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Storage.PackagesDir, filepath.Base(bad))); !os.IsNotExist(err) {
+		t.Errorf("package of the failed version kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Storage.PackagesDir, filepath.Base(good))); err != nil {
+		t.Errorf("package of the previous version: %v", err)
+	}
+
+	// So is a package that cannot be unpacked, before anything is replaced
+	resp = deploy(t, cfg, daemon.DeployRequest{FileName: "app-3.0.0.tar.gz"}, []byte("not a package"))
+	if resp.Success {
+		t.Fatal("deploy of a corrupt package succeeded")
+	}
+
+	matches, err := filepath.Glob(filepath.Join(cfg.Storage.AppsDir, "app*", "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("deployed versions = %v, want the first one only", matches)
+	}
+	restored, err := os.ReadFile(matches[0])
+	if err != nil || !bytes.Equal(restored, deployed) {
+		t.Errorf("deployed version = %q, %v; want %q", restored, err, deployed)
+	}
+	checkStagingEmpty(t, cfg)
+}
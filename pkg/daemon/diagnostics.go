@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"sync/atomic"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+)
+
+// counters count the package transfers and deployments since the daemon started
+type counters struct {
+	transfersReceived atomic.Uint64
+	transfersFailed   atomic.Uint64
+	bytesReceived     atomic.Uint64
+	deploysSucceeded  atomic.Uint64
+	deploysFailed     atomic.Uint64
+}
+
+// fields returns the counters as key-value pairs for a log line
+func (c *counters) fields() []interface{} {
+	return []interface{}{
+		"transfers_received", c.transfersReceived.Load(),
+		"transfers_failed", c.transfersFailed.Load(),
+		"bytes_received", c.bytesReceived.Load(),
+		"deploys_succeeded", c.deploysSucceeded.Load(),
+		"deploys_failed", c.deploysFailed.Load(),
+	}
+}
+
+// startDiagnostics periodically logs network status along with the counters,
+// unless diagnostics are disabled
+func (d *Daemon) startDiagnostics(host *p2p.Host) {
+	diag := d.config.Logging.Diagnostics
+	if !diag.Enabled {
+		return
+	}
+	interval := diag.Interval
+	if interval <= 0 {
+		interval = p2p.DefaultDiagnosticsInterval
+	}
+	host.StartDiagnosticLogging(d.ctx, interval, d.counters.fields)
+	d.logger.Info("diagnostic logging enabled", "interval", interval)
+}
@@ -0,0 +1,140 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/runtime"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// initEncryption loads the at-rest key and installs the runtime hooks that
+// decrypt sensitive files while an application runs
+func (d *Daemon) initEncryption() error {
+	if !d.config.Storage.EncryptAtRest {
+		return nil
+	}
+
+	var key []byte
+	var err error
+	switch d.config.Storage.EncryptionKey {
+	case "", "node":
+		key, err = security.LoadOrGenerateDataKey(d.config.Storage.KeysDir)
+	case "psk":
+		if d.config.Security.PSK == "" {
+			return fmt.Errorf("%w: storage.encryption_key is psk but security.psk is not set", types.ErrInvalidInput)
+		}
+		key, err = security.DeriveDataKey(d.config.Security.PSK)
+	default:
+		return fmt.Errorf("%w: unknown storage.encryption_key %q (expected node or psk)", types.ErrInvalidInput, d.config.Storage.EncryptionKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	d.dataKey = key
+	d.runtime.SetHooks(runtime.Hooks{
+		BeforeStart: d.decryptSensitiveFiles,
+		AfterExit:   d.removeSensitivePlaintext,
+	})
+
+	d.logger.Info("encryption at rest enabled", "key", d.config.Storage.EncryptionKey)
+	return nil
+}
+
+// encryptAtRest encrypts a deployed package and the application's sensitive files
+func (d *Daemon) encryptAtRest(ctx context.Context, app *types.Application) error {
+	if d.dataKey == nil {
+		return nil
+	}
+	log := logging.FromContext(ctx)
+
+	encPath, err := security.EncryptInPlace(app.PackagePath, d.dataKey)
+	if err != nil {
+		return types.WrapError(err, "failed to encrypt package")
+	}
+	app.PackagePath = encPath
+
+	files, err := sensitiveFiles(app, "")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if _, err := security.EncryptInPlace(file, d.dataKey); err != nil {
+			return types.WrapError(err, "failed to encrypt sensitive file")
+		}
+	}
+
+	log.Info("package encrypted at rest", "sensitive_files", len(files))
+	return nil
+}
+
+// decryptSensitiveFiles restores the plaintext of sensitive files before a start
+func (d *Daemon) decryptSensitiveFiles(ctx context.Context, app *types.Application) error {
+	files, err := sensitiveFiles(app, security.EncryptedSuffix)
+	if err != nil {
+		return err
+	}
+
+	for _, encPath := range files {
+		info, err := os.Stat(encPath)
+		if err != nil {
+			return types.WrapError(err, "failed to stat encrypted file")
+		}
+		plainPath := strings.TrimSuffix(encPath, security.EncryptedSuffix)
+		if err := security.DecryptFile(encPath, plainPath, d.dataKey, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeSensitivePlaintext removes decrypted sensitive files once an application has exited
+func (d *Daemon) removeSensitivePlaintext(app *types.Application) {
+	files, err := sensitiveFiles(app, security.EncryptedSuffix)
+	if err != nil {
+		d.logger.Warn("failed to find sensitive files", "app_id", app.ID, "error", err)
+		return
+	}
+
+	for _, encPath := range files {
+		plainPath := strings.TrimSuffix(encPath, security.EncryptedSuffix)
+		if err := os.Remove(plainPath); err != nil && !os.IsNotExist(err) {
+			d.logger.Warn("failed to remove decrypted file", "app_id", app.ID, "file", plainPath, "error", err)
+		}
+	}
+}
+
+// sensitiveFiles returns the files in the application directory matching the
+// manifest's sensitive_files patterns with suffix appended
+func sensitiveFiles(app *types.Application, suffix string) ([]string, error) {
+	if app.Manifest == nil {
+		return nil, nil
+	}
+
+	root := filepath.Clean(app.WorkDir) + string(filepath.Separator)
+	var files []string
+	for _, pattern := range app.Manifest.SensitiveFiles {
+		matches, err := filepath.Glob(filepath.Join(app.WorkDir, pattern) + suffix)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid sensitive_files pattern %q", types.ErrInvalidManifest, pattern)
+		}
+		for _, match := range matches {
+			// Patterns must not reach outside the application directory
+			if !strings.HasPrefix(match, root) {
+				continue
+			}
+			if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+				files = append(files, match)
+			}
+		}
+	}
+
+	return files, nil
+}
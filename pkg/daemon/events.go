@@ -0,0 +1,123 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/events"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// initEvents opens the app event log, unless it is disabled
+func (d *Daemon) initEvents() error {
+	if d.config.Events.Disable {
+		return nil
+	}
+
+	store, err := events.Open(filepath.Join(d.config.Storage.DataDir, events.FileName), d.config.Events.Size)
+	if err != nil {
+		return fmt.Errorf("failed to open app event log: %w", err)
+	}
+	d.events = store
+	return nil
+}
+
+// logEvent adds a lifecycle event of app to the event log
+func (d *Daemon) logEvent(app *types.Application, event types.AppEvent, message string, initiator *types.Initiator) {
+	if d.events == nil {
+		return
+	}
+	e := &events.Event{
+		Type:      event,
+		AppID:     app.ID,
+		Status:    app.Status,
+		Revision:  app.Revision,
+		Message:   message,
+		Initiator: initiator,
+	}
+	if event == types.AppEventExited {
+		e.CoreDump = app.CoreDump
+	}
+	err := d.events.Record(e)
+	if err != nil {
+		d.logger.Warn("failed to log app event", "app_id", app.ID, "event", event, "error", err)
+	}
+}
+
+// EventsRequest represents a request for the logged app events
+type EventsRequest struct {
+	Since     time.Duration    `json:"since,omitempty"`      // How far back to look, 0 for all
+	AppID     string           `json:"app_id,omitempty"`     // Empty for all apps
+	Types     []types.AppEvent `json:"types,omitempty"`      // Empty for all types
+	RequestID string           `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// EventsResponse represents an events response
+type EventsResponse struct {
+	Success   bool            `json:"success"`
+	Events    []*events.Event `json:"events,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Code      string          `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// handleEventsRequest returns the logged app events passing the request filters
+func (d *Daemon) handleEventsRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req EventsRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("events", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received events request", "app_id", req.AppID, "since", req.Since, "types", req.Types)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendEventsResponse(ctx, stream, nil, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	if req.AppID != "" {
+		req.AppID = d.resolveAppID(ctx, req.AppID)
+	}
+
+	watchStream(stream, cancel)
+
+	if d.events == nil {
+		d.sendEventsResponse(ctx, stream, nil, fmt.Errorf("%w: the app event log is disabled (events.disable)", types.ErrUnavailable))
+		return
+	}
+
+	filter := events.Filter{AppID: req.AppID, Types: req.Types}
+	if req.Since > 0 {
+		filter.Since = time.Now().Add(-req.Since)
+	}
+	d.sendEventsResponse(ctx, stream, d.events.Query(filter), nil)
+}
+
+// sendEventsResponse sends an events response
+func (d *Daemon) sendEventsResponse(ctx context.Context, stream types.Stream, evts []*events.Event, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := EventsResponse{
+		Success:   respErr == nil,
+		Events:    evts,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
+	}
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("events response sent", "event_count", len(evts))
+}
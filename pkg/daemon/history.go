@@ -0,0 +1,163 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/history"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// initHistory opens the app history store, unless history is disabled
+func (d *Daemon) initHistory() error {
+	if d.config.History.Disable {
+		return nil
+	}
+
+	var dir string
+	if d.config.History.Persist {
+		dir = filepath.Join(d.config.Storage.DataDir, history.DirName)
+	}
+	store, err := history.New(d.config.History.Size, dir)
+	if err != nil {
+		return fmt.Errorf("failed to open app history: %w", err)
+	}
+	d.history = store
+	return nil
+}
+
+// recordEvent adds a lifecycle event of app, caused by initiator if known,
+// to its history and the event log
+func (d *Daemon) recordEvent(app *types.Application, event types.AppEvent, message string, initiator *types.Initiator) {
+	if d.history != nil {
+		err := d.history.Record(&history.Entry{
+			AppID:   app.ID,
+			Kind:    history.KindEvent,
+			Event:   event,
+			Status:  app.Status,
+			Message: message,
+		})
+		if err != nil {
+			d.logger.Warn("failed to record app event", "app_id", app.ID, "event", event, "error", err)
+		}
+	}
+	d.logEvent(app, event, message, initiator)
+}
+
+// sampleHistory records the status of every app at the configured interval until the daemon stops
+func (d *Daemon) sampleHistory() {
+	interval := d.config.History.Interval
+	if interval <= 0 {
+		interval = history.DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		apps, err := d.runtime.List(d.ctx)
+		if err != nil {
+			continue
+		}
+		for _, app := range apps {
+			status, err := d.runtime.Status(d.ctx, app.ID)
+			if err != nil {
+				continue
+			}
+			err = d.history.Record(&history.Entry{
+				AppID:   app.ID,
+				Kind:    history.KindSample,
+				Status:  status.App.Status,
+				Healthy: status.Healthy,
+				Message: status.Message,
+				Metrics: status.Metrics,
+				Usage:   status.ResourceUsage,
+			})
+			if err != nil {
+				d.logger.Warn("failed to record app status", "app_id", app.ID, "error", err)
+			}
+		}
+	}
+}
+
+// HistoryRequest represents an app history request
+type HistoryRequest struct {
+	AppID     string        `json:"app_id,omitempty"`     // Empty for all apps
+	Since     time.Duration `json:"since"`                // How far back to look
+	RequestID string        `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// HistoryResponse represents an app history response
+type HistoryResponse struct {
+	Success   bool             `json:"success"`
+	Entries   []*history.Entry `json:"entries,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Code      string           `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string           `json:"request_id,omitempty"`
+}
+
+// handleHistoryRequest returns the recent status samples and events of apps
+func (d *Daemon) handleHistoryRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req HistoryRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("history", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received history request", "app_id", req.AppID, "since", req.Since)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendHistoryResponse(ctx, stream, nil, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	if req.AppID != "" {
+		req.AppID = d.resolveAppID(ctx, req.AppID)
+	}
+
+	watchStream(stream, cancel)
+
+	if d.history == nil {
+		d.sendHistoryResponse(ctx, stream, nil, fmt.Errorf("%w: app history is disabled (history.disable)", types.ErrUnavailable))
+		return
+	}
+
+	var since time.Time
+	if req.Since > 0 {
+		since = time.Now().Add(-req.Since)
+	}
+	d.sendHistoryResponse(ctx, stream, d.history.Since(req.AppID, since), nil)
+}
+
+// sendHistoryResponse sends an app history response
+func (d *Daemon) sendHistoryResponse(ctx context.Context, stream types.Stream, entries []*history.Entry, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := HistoryResponse{
+		Success:   respErr == nil,
+		Entries:   entries,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
+	}
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("history response sent", "entry_count", len(entries))
+}
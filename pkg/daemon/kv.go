@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/kv"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// initKV creates the per-app store served on the app API and the cluster-wide
+// store served to controllers, and starts replicating them
+func (d *Daemon) initKV(host *p2p.Host) {
+	if d.appAPI != nil && !d.config.KV.Disable {
+		store := kv.NewStore(d.storage, kv.AppDir, host.ID())
+		kv.Register(d.appAPI, store)
+		if d.config.KV.Replicate {
+			d.replicateKV(host, kv.AppTopic, store)
+		}
+	}
+
+	if !d.config.KV.DisableCluster {
+		d.clusterKV = kv.NewStore(d.storage, kv.ClusterDir, host.ID())
+		d.replicateKV(host, kv.ClusterTopic, d.clusterKV)
+	}
+}
+
+// replicateKV shares store with the other nodes on topic
+func (d *Daemon) replicateKV(host *p2p.Host, topic string, store *kv.Store) {
+	if d.discovery == nil {
+		d.logger.Warn("key-value replication needs the discovery service, keeping data node-local", "topic", topic)
+		return
+	}
+
+	replicator, err := kv.NewReplicator(d.discovery.PubSub(), topic, host.LibP2PHost().ID(), store, d.logger)
+	if err != nil {
+		d.logger.Warn("failed to start key-value replication", "topic", topic, "error", err)
+		return
+	}
+	replicator.Start()
+	d.kvSync = append(d.kvSync, replicator)
+	d.logger.Info("key-value replication enabled", "topic", topic)
+}
+
+// KVRequest represents a cluster key-value store request
+type KVRequest struct {
+	Op        string `json:"op"` // kv.OpGet, kv.OpPut, kv.OpDelete or kv.OpList
+	Namespace string `json:"namespace,omitempty"`
+	Key       string `json:"key,omitempty"`
+	Value     []byte `json:"value,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// KVResponse represents a cluster key-value store response
+type KVResponse struct {
+	Success   bool     `json:"success"`
+	Value     []byte   `json:"value,omitempty"`
+	Keys      []string `json:"keys,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Code      string   `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string   `json:"request_id,omitempty"`
+}
+
+// handleKVRequest serves the cluster-wide key-value store
+func (d *Daemon) handleKVRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req KVRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("kv", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	if req.Namespace == "" {
+		req.Namespace = kv.DefaultNamespace
+	}
+	log.Info("received kv request", "op", req.Op, "namespace", req.Namespace, "key", req.Key)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendKVResponse(ctx, stream, &KVResponse{}, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	if d.clusterKV == nil {
+		d.sendKVResponse(ctx, stream, &KVResponse{}, fmt.Errorf("%w: cluster key-value store is disabled (kv.disable_cluster)", types.ErrUnavailable))
+		return
+	}
+
+	resp := &KVResponse{}
+	var err error
+	switch req.Op {
+	case kv.OpGet:
+		resp.Value, err = d.clusterKV.Get(ctx, req.Namespace, req.Key)
+	case kv.OpPut:
+		err = d.clusterKV.Put(ctx, req.Namespace, req.Key, req.Value)
+	case kv.OpDelete:
+		err = d.clusterKV.Delete(ctx, req.Namespace, req.Key)
+	case kv.OpList:
+		resp.Keys, err = d.clusterKV.Keys(ctx, req.Namespace, req.Prefix)
+	default:
+		err = fmt.Errorf("%w: unknown kv operation %q", types.ErrInvalidInput, req.Op)
+	}
+	if err != nil {
+		log.Warn("kv request failed", "op", req.Op, "error", err)
+	}
+
+	d.sendKVResponse(ctx, stream, resp, err)
+}
+
+// sendKVResponse sends a cluster key-value store response
+func (d *Daemon) sendKVResponse(ctx context.Context, stream types.Stream, resp *KVResponse, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp.Success = respErr == nil
+	resp.Error = errorMessage(respErr)
+	resp.Code = types.ErrorCode(respErr)
+	resp.RequestID = logging.RequestIDFromContext(ctx)
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+}
@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/applock"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// LabelRequest represents a request to set and remove labels of a deployed
+// app; with neither, its labels are only returned
+type LabelRequest struct {
+	AppID     string            `json:"app_id"`
+	Set       map[string]string `json:"set,omitempty"`        // Labels to set, overriding those of the manifest
+	Remove    []string          `json:"remove,omitempty"`     // Keys of the labels to remove
+	RequestID string            `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// LabelResponse represents a label response
+type LabelResponse struct {
+	Success   bool              `json:"success"`
+	Labels    map[string]string `json:"labels,omitempty"` // Labels of the app after the change
+	Error     string            `json:"error,omitempty"`
+	Code      string            `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// handleLabelRequest sets and removes labels of a deployed app. The change
+// is kept, so redeploying the same app instance applies it again on top of
+// the labels of its manifest.
+func (d *Daemon) handleLabelRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req LabelRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("label", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received label request", "app_id", req.AppID, "set", req.Set, "remove", req.Remove)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendLabelResponse(ctx, stream, nil, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	req.AppID = d.resolveAppID(ctx, req.AppID)
+
+	// The app ID names a file in the labels directory
+	if req.AppID == "" || req.AppID != filepath.Base(req.AppID) || req.AppID == "." || req.AppID == ".." {
+		d.sendLabelResponse(ctx, stream, nil, fmt.Errorf("%w: invalid app ID %q", types.ErrInvalidInput, req.AppID))
+		return
+	}
+
+	labels, err := d.label(ctx, &req, p2p.RemotePeer(stream))
+	if err != nil {
+		log.Error("failed to label app", "app_id", req.AppID, "error", err)
+	}
+	d.sendLabelResponse(ctx, stream, labels, err)
+}
+
+// label applies a label request and returns the labels of the app after it
+func (d *Daemon) label(ctx context.Context, req *LabelRequest, holder string) (map[string]string, error) {
+	status, err := d.runtime.Status(ctx, req.AppID)
+	if errors.Is(err, types.ErrNotFound) {
+		return nil, fmt.Errorf("%w: no application %s", types.ErrNotFound, req.AppID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	app := status.App
+	if len(req.Set) == 0 && len(req.Remove) == 0 {
+		return app.Labels, nil
+	}
+
+	// Labels the policy requires of manifests stay
+	for _, key := range req.Remove {
+		if slices.Contains(d.config.Policy.RequiredLabels, key) {
+			return nil, fmt.Errorf("%w: label %q is required by the policy of the node", types.ErrPolicyViolation, key)
+		}
+	}
+
+	// Do not race a deployment replacing the app
+	release, err := d.locks.Acquire(app.Name, applock.Lock{
+		Operation: "label",
+		RequestID: logging.RequestIDFromContext(ctx),
+		Holder:    holder,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Overrides are kept per version, so that they survive redeploying it
+	overrides, err := d.labels.Update(ctx, types.LegacyAppID(app.Name, app.Version), req.Set, req.Remove)
+	if err != nil {
+		return nil, err
+	}
+	var manifestLabels map[string]string
+	if app.Manifest != nil {
+		manifestLabels = app.Manifest.Labels
+	}
+	updated, err := d.runtime.SetLabels(app.ID, overrides.Apply(manifestLabels))
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("app labels changed", "app_id", app.ID, "labels", updated.Labels)
+	return updated.Labels, nil
+}
+
+// sendLabelResponse sends a label response
+func (d *Daemon) sendLabelResponse(ctx context.Context, stream types.Stream, labels map[string]string, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := LabelResponse{
+		Success:   respErr == nil,
+		Labels:    labels,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
+	}
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("label response sent", "success", respErr == nil)
+}
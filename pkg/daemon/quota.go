@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/policy"
+	"github.com/asjdf/p2p-playground-lite/pkg/quota"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// operatorOf returns the operator a package signed by signer counts towards
+func operatorOf(signer *policy.Signer) quota.Operator {
+	if signer == nil {
+		return quota.Operator{ID: quota.Unsigned}
+	}
+	return quota.Operator{ID: hex.EncodeToString(signer.PublicKey), Name: signer.Name}
+}
+
+// deployedApps returns the names of the apps on the node
+func (d *Daemon) deployedApps(ctx context.Context) ([]string, error) {
+	apps, err := d.runtime.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(apps))
+	for _, app := range apps {
+		names = append(names, app.Name)
+	}
+	return names, nil
+}
+
+// reserveQuota holds the quota of the operator deploying a received package
+func (d *Daemon) reserveQuota(ctx context.Context, pkgPath string, size int64, signer *policy.Signer) (*quota.Reservation, error) {
+	manifest, err := d.pkgMgr.GetManifest(ctx, pkgPath)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to get manifest")
+	}
+	deployed, err := d.deployedApps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return d.quotas.Reserve(ctx, operatorOf(signer), manifest.Name, size, deployed)
+}
+
+// quotaUsage returns the quota usage of every operator, nil if it cannot be read
+func (d *Daemon) quotaUsage(ctx context.Context) []*types.QuotaUsage {
+	deployed, err := d.deployedApps(ctx)
+	if err != nil {
+		return nil
+	}
+	usage, err := d.quotas.Usage(ctx, deployed)
+	if err != nil {
+		d.logger.Warn("failed to read quota usage", "error", err)
+		return nil
+	}
+	return usage
+}
+
+// NodeInfoRequest represents a node information request
+type NodeInfoRequest struct {
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// NodeInfoResponse represents a node information response
+type NodeInfoResponse struct {
+	Success   bool            `json:"success"`
+	Node      *types.NodeInfo `json:"node,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Code      string          `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// handleNodeInfoRequest returns the node information, including quota usage
+func (d *Daemon) handleNodeInfoRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req NodeInfoRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("node-info", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received node info request")
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendNodeInfoResponse(ctx, stream, nil, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	watchStream(stream, cancel)
+
+	d.sendNodeInfoResponse(ctx, stream, d.GetNodeInfo(ctx), nil)
+}
+
+// sendNodeInfoResponse sends a node information response
+func (d *Daemon) sendNodeInfoResponse(ctx context.Context, stream types.Stream, node *types.NodeInfo, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := NodeInfoResponse{
+		Success:   respErr == nil,
+		Node:      node,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
+	}
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("node info response sent")
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/registry"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// initRegistry configures the package registry and the cache of the
+// packages fetched from it
+func (d *Daemon) initRegistry() error {
+	bucket, err := registry.New(&d.config.Registry.S3)
+	if err != nil {
+		return fmt.Errorf("failed to configure registry.s3: %w", err)
+	}
+	d.registry = bucket
+
+	cacheMB := d.config.Registry.CacheMB
+	if cacheMB <= 0 {
+		cacheMB = registry.DefaultCacheMB
+	}
+	d.registryCache = registry.NewCache(d.storage.GetPath("registry"), int64(cacheMB)<<20)
+	if bucket != nil {
+		d.logger.Info("packages are deployed from the registry by digest", "registry", bucket.String(), "cache_mb", cacheMB)
+	}
+	return nil
+}
+
+// resolveRegistered fills in the file name, size and, unless the request
+// carries one, signature of the package a deploy request names by digest
+func (d *Daemon) resolveRegistered(ctx context.Context, req *DeployRequest) error {
+	pkg, err := d.registryCache.Stat(req.Digest)
+	if errors.Is(err, types.ErrNotFound) {
+		if d.registry == nil {
+			return fmt.Errorf("%w: package %s is not cached and no registry is configured (registry.s3)", types.ErrUnavailable, req.Digest)
+		}
+		pkg, err = d.registry.Stat(ctx, req.Digest)
+	}
+	if err != nil {
+		return err
+	}
+
+	req.FileName = pkg.FileName
+	req.FileSize = pkg.Size
+	if len(req.Signature) == 0 {
+		req.Signature = pkg.Signature
+	}
+	logging.FromContext(ctx).Info("deploying package from the registry", "digest", pkg.Digest, "file_name", pkg.FileName, "size", pkg.Size)
+	return nil
+}
+
+// stageRegistered writes the package with digest to path, from the cache or
+// else downloaded from the registry
+func (d *Daemon) stageRegistered(ctx context.Context, digest string, path string) error {
+	file, err := d.storage.CreateFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	_, err = d.registryCache.Fetch(ctx, d.registry, digest, file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write file: %w", closeErr)
+	}
+	return err
+}
@@ -0,0 +1,218 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/revision"
+	"github.com/asjdf/p2p-playground-lite/pkg/runtime"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// RollbackRequest represents a request to redeploy an earlier revision of an
+// app, or with List to return its revisions
+type RollbackRequest struct {
+	App       string `json:"app"`                  // App name
+	Revision  int64  `json:"revision,omitempty"`   // Revision to redeploy, 0 for the one before the latest
+	List      bool   `json:"list,omitempty"`       // Only return the kept revisions
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+
+	// ForceUnlock breaks the lock of another operation on the app instead of failing
+	ForceUnlock bool `json:"force_unlock,omitempty"`
+
+	// WaitHealthy succeeds only once the started app is healthy, rolling back otherwise
+	WaitHealthy bool `json:"wait_healthy,omitempty"`
+
+	// HealthTimeout bounds the wait for the app to become healthy (default 60s)
+	HealthTimeout time.Duration `json:"health_timeout,omitempty"`
+}
+
+// RollbackResponse represents a rollback response
+type RollbackResponse struct {
+	Success    bool                 `json:"success"`
+	AppID      string               `json:"app_id,omitempty"`      // App instance redeployed
+	Revision   int64                `json:"revision,omitempty"`    // Revision the rollback was deployed as
+	RollbackTo int64                `json:"rollback_to,omitempty"` // Revision redeployed
+	Revisions  []*revision.Revision `json:"revisions,omitempty"`   // Kept revisions, oldest first, for List
+	Error      string               `json:"error,omitempty"`
+	Code       string               `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID  string               `json:"request_id,omitempty"`
+}
+
+// handleRollbackRequest redeploys the package of an earlier revision of an app,
+// as a new revision. The package passed admission when it was first deployed,
+// so admission hooks and quotas are not applied again.
+func (d *Daemon) handleRollbackRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req RollbackRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("rollback", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received rollback request", "app", req.App, "revision", req.Revision, "list", req.List)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendRollbackResponse(ctx, stream, &RollbackResponse{}, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	// The app name names a file in the revisions directory
+	if !validFileName(req.App) {
+		d.sendRollbackResponse(ctx, stream, &RollbackResponse{}, fmt.Errorf("%w: invalid app name %q", types.ErrInvalidInput, req.App))
+		return
+	}
+
+	if req.List {
+		revisions, err := d.revisions.List(ctx, req.App)
+		if err == nil && len(revisions) == 0 {
+			err = fmt.Errorf("%w: %s has no recorded revisions", types.ErrNotFound, req.App)
+		}
+		d.sendRollbackResponse(ctx, stream, &RollbackResponse{Revisions: revisions}, err)
+		return
+	}
+
+	ctx = runtime.WithInitiator(ctx, operatorInitiator(ctx, "rollback", p2p.RemotePeer(stream), nil))
+	app, target, err := d.rollback(ctx, &req, p2p.RemotePeer(stream))
+	if err != nil {
+		log.Error("failed to roll back", "app", req.App, "error", err)
+		d.sendRollbackResponse(ctx, stream, &RollbackResponse{}, err)
+		return
+	}
+	d.sendRollbackResponse(ctx, stream, &RollbackResponse{AppID: app.ID, Revision: app.Revision, RollbackTo: target}, nil)
+}
+
+// rollback redeploys the package of the requested revision, started, and
+// returns the app and the number of the revision redeployed
+func (d *Daemon) rollback(ctx context.Context, req *RollbackRequest, holder string) (*types.Application, int64, error) {
+	log := logging.FromContext(ctx)
+
+	target, err := d.revisions.Get(ctx, req.App, req.Revision)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := d.checkDeployFilesystems(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	// Deploying consumes the package, so deploy a plaintext copy of the kept one
+	fileName := strings.TrimSuffix(target.Package, security.EncryptedSuffix)
+	if !validFileName(fileName) {
+		return nil, 0, fmt.Errorf("%w: invalid package %q of revision %d", types.ErrInvalidState, target.Package, target.Number)
+	}
+	staging, err := newStagingDir(d.config.Storage.PackagesDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+	pkgPath := filepath.Join(staging, fileName)
+	if err := d.stageRevision(target, pkgPath); err != nil {
+		return nil, 0, err
+	}
+
+	// A later deployment may have replaced the package under the same name
+	checksum, err := d.pkgMgr.CalculateChecksum(pkgPath)
+	if err != nil {
+		return nil, 0, types.WrapError(err, "failed to checksum package")
+	}
+	if target.SHA256 != "" && checksum != target.SHA256 {
+		return nil, 0, fmt.Errorf("%w: package %s of revision %d has been replaced since it was deployed", types.ErrInvalidState, fileName, target.Number)
+	}
+
+	release, err := d.lockApp(ctx, pkgPath, "rollback", holder, req.ForceUnlock)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+
+	log.Info("rolling back", "app", req.App, "revision", target.Number, "app_id", target.AppID)
+
+	opts := deployOptions{start: true}
+	if req.WaitHealthy {
+		opts.healthTimeout = req.HealthTimeout
+		if opts.healthTimeout <= 0 {
+			opts.healthTimeout = defaultHealthTimeout
+		}
+	}
+	opts.revision = revision.Revision{
+		SHA256:     checksum,
+		Size:       target.Size,
+		Signer:     target.Signer,
+		Signature:  target.Signature,
+		RollbackTo: target.Number,
+	}
+	app, err := d.deploy(ctx, pkgPath, fileName, opts, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	d.recordDeployment(ctx, app, checksum, target.Size, nil)
+	return app, target.Number, nil
+}
+
+// stageRevision copies the kept package of rev to dst, decrypting it if it
+// is encrypted at rest
+func (d *Daemon) stageRevision(rev *revision.Revision, dst string) error {
+	src := filepath.Join(d.config.Storage.PackagesDir, rev.Package)
+	info, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: package %s of revision %d is no longer kept", types.ErrNotFound, rev.Package, rev.Number)
+	}
+	if err != nil {
+		return types.WrapError(err, "failed to stat package")
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return types.WrapError(err, "failed to create staging directory")
+	}
+
+	if strings.HasSuffix(rev.Package, security.EncryptedSuffix) {
+		if d.dataKey == nil {
+			return fmt.Errorf("%w: package %s is encrypted but encryption at rest is disabled", types.ErrInvalidState, rev.Package)
+		}
+		return security.DecryptFile(src, dst, d.dataKey, info.Mode().Perm())
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return types.WrapError(err, "failed to open package")
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return types.WrapError(err, "failed to create staged package")
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return types.WrapError(err, "failed to copy package")
+	}
+	return out.Close()
+}
+
+// sendRollbackResponse sends a rollback response
+func (d *Daemon) sendRollbackResponse(ctx context.Context, stream types.Stream, resp *RollbackResponse, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp.Success = respErr == nil
+	resp.Error = errorMessage(respErr)
+	resp.Code = types.ErrorCode(respErr)
+	resp.RequestID = logging.RequestIDFromContext(ctx)
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("rollback response sent", "success", respErr == nil, "app_id", resp.AppID, "revision", resp.Revision)
+}
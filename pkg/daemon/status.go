@@ -0,0 +1,48 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// LocalSocket returns the path of the local socket of the daemon configured
+// by cfg
+func LocalSocket(cfg *config.DaemonConfig) string {
+	if cfg.Node.LocalSocket != "" {
+		return cfg.Node.LocalSocket
+	}
+	return filepath.Join(cfg.Storage.DataDir, p2p.LocalSocketName)
+}
+
+// LocalNodeInfo asks the daemon serving the local socket at path for its
+// node information, the state of its subsystems included
+func LocalNodeInfo(ctx context.Context, path string) (*types.NodeInfo, error) {
+	stream, err := p2p.DialLocal(ctx, path, consts.NodeInfoProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = stream.Close() }()
+
+	if err := wire.WriteMessage(stream, &NodeInfoRequest{RequestID: logging.NewRequestID()}); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	var resp NodeInfoResponse
+	if err := readRequestHeader(stream, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("node info request failed: %w", types.ErrorFromCode(resp.Code, resp.Error))
+	}
+	if resp.Node == nil {
+		return nil, fmt.Errorf("%w: node info missing from response", types.ErrInvalidState)
+	}
+	return resp.Node, nil
+}
@@ -0,0 +1,222 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/revision"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/transfer"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// handleTransferRequest deploys a package another node sends, or pushes a
+// package kept by this node to another one, so that operators can copy
+// packages between nodes without sending them through the controller
+func (d *Daemon) handleTransferRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req transfer.Request
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("transfer", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received transfer request", "to", req.To, "ref", req.Ref, "file_name", req.FileName, "file_size", req.FileSize)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendTransferResponse(ctx, stream, nil, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	var resp *transfer.Response
+	var err error
+	if req.To != "" {
+		resp, err = d.pushPackage(ctx, &req)
+	} else {
+		resp, err = d.receivePackage(ctx, stream, &req)
+	}
+	if err != nil {
+		log.Error("transfer failed", "error", err)
+	}
+	d.sendTransferResponse(ctx, stream, resp, err)
+}
+
+// receivePackage receives the package another node sends and deploys it
+func (d *Daemon) receivePackage(ctx context.Context, stream types.Stream, req *transfer.Request) (*transfer.Response, error) {
+	// Refuse oversized packages before accepting any of them
+	if err := transfer.CheckSize(req.FileSize, d.config.Runtime.MaxPackageSize); err != nil {
+		return nil, fmt.Errorf("%w (runtime.max_package_size)", err)
+	}
+	if err := d.checkDeployFilesystems(ctx); err != nil {
+		return nil, err
+	}
+	if err := d.checkDeploySpace(req.FileSize); err != nil {
+		return nil, err
+	}
+
+	fileName := req.FileName
+	if !validFileName(fileName) {
+		return nil, fmt.Errorf("%w: invalid file name %q", types.ErrInvalidInput, req.FileName)
+	}
+	staging, err := newStagingDir(d.config.Storage.PackagesDir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+	pkgPath := filepath.Join(staging, fileName)
+	if err := d.receiveFile(ctx, stream, pkgPath, req.FileSize, true); err != nil {
+		return nil, err
+	}
+
+	checksum, err := d.pkgMgr.CalculateChecksum(pkgPath)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to checksum package")
+	}
+	if req.SHA256 != "" && checksum != req.SHA256 {
+		return nil, fmt.Errorf("%w: package %s does not match the checksum it was sent with", types.ErrInvalidChecksum, fileName)
+	}
+
+	deployReq := &DeployRequest{
+		FileName:  fileName,
+		FileSize:  req.FileSize,
+		AutoStart: req.AutoStart,
+		Signature: req.Signature,
+		RequestID: req.RequestID,
+	}
+	app, err := d.deployReceived(ctx, pkgPath, fileName, deployReq, "transfer", p2p.RemotePeer(stream), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer.Response{AppID: app.ID}, nil
+}
+
+// pushPackage sends the package req.Ref names to the node req.To, which
+// deploys it, and returns its response
+func (d *Daemon) pushPackage(ctx context.Context, req *transfer.Request) (*transfer.Response, error) {
+	if req.To == d.host.ID() {
+		return nil, fmt.Errorf("%w: cannot transfer a package to the node it is on", types.ErrInvalidInput)
+	}
+	rev, err := d.findPackage(ctx, req.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send a plaintext copy, which a later deployment cannot replace midway
+	fileName := strings.TrimSuffix(rev.Package, security.EncryptedSuffix)
+	if !validFileName(fileName) {
+		return nil, fmt.Errorf("%w: invalid package %q of revision %d", types.ErrInvalidState, rev.Package, rev.Number)
+	}
+	staging, err := newStagingDir(d.config.Storage.PackagesDir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+	pkgPath := filepath.Join(staging, fileName)
+	if err := d.stageRevision(rev, pkgPath); err != nil {
+		return nil, err
+	}
+	checksum, err := d.pkgMgr.CalculateChecksum(pkgPath)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to checksum package")
+	}
+	if rev.SHA256 != "" && checksum != rev.SHA256 {
+		return nil, fmt.Errorf("%w: package %s of revision %d has been replaced since it was deployed", types.ErrInvalidState, fileName, rev.Number)
+	}
+
+	logging.FromContext(ctx).Info("pushing package", "file_name", fileName, "to", req.To, "signed", len(rev.Signature) > 0)
+	return d.transfer.Send(ctx, req.To, pkgPath, transfer.Request{
+		FileName:  fileName,
+		SHA256:    checksum,
+		Signature: rev.Signature,
+		AutoStart: req.AutoStart,
+		RequestID: logging.RequestIDFromContext(ctx),
+	})
+}
+
+// findPackage returns the revision of the package ref names: the package of
+// a deployed app instance, the latest package of an app name, or a package
+// kept under that file name. A package no revision records comes without
+// its signature.
+func (d *Daemon) findPackage(ctx context.Context, ref string) (*revision.Revision, error) {
+	// The reference names a file in the packages directory at most
+	if !validFileName(ref) {
+		return nil, fmt.Errorf("%w: invalid package %q", types.ErrInvalidInput, ref)
+	}
+
+	status, err := d.runtime.Status(ctx, ref)
+	if err != nil && !errors.Is(err, types.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		revisions, err := d.revisions.List(ctx, status.App.Name)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(revisions) - 1; i >= 0; i-- {
+			if revisions[i].AppID == ref {
+				return revisions[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: no package of %s is kept", types.ErrNotFound, ref)
+	}
+
+	revisions, err := d.revisions.List(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) > 0 {
+		return revisions[len(revisions)-1], nil
+	}
+
+	apps, err := d.runtime.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		revisions, err := d.revisions.List(ctx, app.Name)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(revisions) - 1; i >= 0; i-- {
+			if strings.TrimSuffix(revisions[i].Package, security.EncryptedSuffix) == ref {
+				return revisions[i], nil
+			}
+		}
+	}
+	for _, name := range []string{ref, ref + security.EncryptedSuffix} {
+		if _, err := os.Stat(filepath.Join(d.config.Storage.PackagesDir, name)); err == nil {
+			return &revision.Revision{Package: name}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no app instance, app or package %s", types.ErrNotFound, ref)
+}
+
+// sendTransferResponse sends a transfer response
+func (d *Daemon) sendTransferResponse(ctx context.Context, stream types.Stream, resp *transfer.Response, respErr error) {
+	log := logging.FromContext(ctx)
+
+	if resp == nil {
+		resp = &transfer.Response{}
+	}
+	resp.Success = respErr == nil
+	resp.Error = errorMessage(respErr)
+	resp.Code = types.ErrorCode(respErr)
+	resp.RequestID = logging.RequestIDFromContext(ctx)
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("transfer response sent", "success", respErr == nil)
+}
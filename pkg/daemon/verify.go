@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/integrity"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// VerifyRequest represents a request to check the files of a deployed app
+type VerifyRequest struct {
+	AppID     string `json:"app_id"`
+	Files     bool   `json:"files,omitempty"`      // Also return the recorded checksums
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
+
+// VerifyResponse represents a verify response
+type VerifyResponse struct {
+	Success   bool              `json:"success"`
+	Report    *integrity.Report `json:"report,omitempty"`
+	Files     []integrity.File  `json:"files,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Code      string            `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// handleVerifyRequest re-hashes the files of a deployed app and compares them
+// with the checksums recorded when it was deployed
+func (d *Daemon) handleVerifyRequest(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var req VerifyRequest
+	headerErr := readRequestHeader(stream, &req)
+
+	ctx, cancel := d.newRequestContext("verify", req.RequestID)
+	defer cancel()
+	log := logging.FromContext(ctx)
+
+	log.Info("received verify request", "app_id", req.AppID)
+
+	if headerErr != nil {
+		log.Error("failed to read request", "error", headerErr)
+		d.sendVerifyResponse(ctx, stream, nil, nil, fmt.Errorf("%w: %w", types.ErrInvalidInput, headerErr))
+		return
+	}
+
+	req.AppID = d.resolveAppID(ctx, req.AppID)
+
+	watchStream(stream, cancel)
+
+	// The app ID names a directory in the apps directory
+	if req.AppID == "" || req.AppID != filepath.Base(req.AppID) || req.AppID == "." || req.AppID == ".." {
+		d.sendVerifyResponse(ctx, stream, nil, nil, fmt.Errorf("%w: invalid app ID %q", types.ErrInvalidInput, req.AppID))
+		return
+	}
+
+	rec, err := d.checksums.Load(ctx, req.AppID)
+	if err != nil {
+		d.sendVerifyResponse(ctx, stream, nil, nil, err)
+		return
+	}
+	report, err := integrity.Check(ctx, filepath.Join(d.config.Storage.AppsDir, req.AppID), rec)
+	if err != nil {
+		log.Error("failed to verify app files", "app_id", req.AppID, "error", err)
+		d.sendVerifyResponse(ctx, stream, nil, nil, err)
+		return
+	}
+
+	if !report.OK() {
+		log.Warn("app files differ from deployment", "app_id", req.AppID, "drift", len(report.Drift))
+	}
+	var files []integrity.File
+	if req.Files {
+		files = rec.Files
+	}
+	d.sendVerifyResponse(ctx, stream, report, files, nil)
+}
+
+// sendVerifyResponse sends a verify response
+func (d *Daemon) sendVerifyResponse(ctx context.Context, stream types.Stream, report *integrity.Report, files []integrity.File, respErr error) {
+	log := logging.FromContext(ctx)
+
+	resp := VerifyResponse{
+		Success:   respErr == nil,
+		Report:    report,
+		Files:     files,
+		Error:     errorMessage(respErr),
+		Code:      types.ErrorCode(respErr),
+		RequestID: logging.RequestIDFromContext(ctx),
+	}
+
+	if err := wire.WriteMessage(stream, resp); err != nil {
+		log.Error("failed to send response", "error", err)
+		return
+	}
+
+	log.Info("verify response sent")
+}
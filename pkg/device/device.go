@@ -0,0 +1,89 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DefaultPatterns are the device nodes a daemon offers to apps when none are configured:
+// DRM/render nodes, NVIDIA GPUs and the AMD compute interface
+var DefaultPatterns = []string{
+	"/dev/dri/*",
+	"/dev/nvidia*",
+	"/dev/kfd",
+}
+
+// Detect returns the device nodes matching patterns, sorted and without duplicates.
+// Paths that do not exist or are not device nodes are skipped.
+func Detect(patterns []string) []string {
+	seen := make(map[string]bool)
+	var devices []string
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil || info.Mode()&os.ModeDevice == 0 || seen[path] {
+				continue
+			}
+			seen[path] = true
+			devices = append(devices, path)
+		}
+	}
+
+	sort.Strings(devices)
+	return devices
+}
+
+// Match returns the devices in available that satisfy request. A request matches a
+// device with the same path, a device below it (for directories such as /dev/dri)
+// or, if it is a glob pattern, a device matching the pattern.
+func Match(request string, available []string) []string {
+	var matched []string
+	for _, path := range available {
+		if path == request || strings.HasPrefix(path, request+"/") {
+			matched = append(matched, path)
+			continue
+		}
+		if ok, _ := filepath.Match(request, path); ok {
+			matched = append(matched, path)
+		}
+	}
+	return matched
+}
+
+// Resolve returns every device in available that satisfies one of requests.
+// It fails with types.ErrUnavailable naming the first request nothing satisfies.
+func Resolve(requests, available []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var devices []string
+
+	for _, request := range requests {
+		matched := Match(request, available)
+		if len(matched) == 0 {
+			return nil, fmt.Errorf("%w: device %s is not available on this node", types.ErrUnavailable, request)
+		}
+		for _, path := range matched {
+			if !seen[path] {
+				seen[path] = true
+				devices = append(devices, path)
+			}
+		}
+	}
+
+	return devices, nil
+}
+
+// Satisfies reports whether available provides a device for each of requests
+func Satisfies(requests, available []string) bool {
+	_, err := Resolve(requests, available)
+	return err == nil
+}
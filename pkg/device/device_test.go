@@ -0,0 +1,79 @@
+package device_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/device"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+var available = []string{
+	"/dev/dri/card0",
+	"/dev/dri/renderD128",
+	"/dev/nvidia0",
+	"/dev/nvidia1",
+	"/dev/nvidiactl",
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		want    []string
+	}{
+		{"exact path", "/dev/nvidia0", []string{"/dev/nvidia0"}},
+		{"directory", "/dev/dri", []string{"/dev/dri/card0", "/dev/dri/renderD128"}},
+		{"glob", "/dev/nvidia[0-9]*", []string{"/dev/nvidia0", "/dev/nvidia1"}},
+		{"glob in directory", "/dev/dri/render*", []string{"/dev/dri/renderD128"}},
+		{"prefix is not a directory", "/dev/nvidia", nil},
+		{"missing", "/dev/kfd", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := device.Match(tt.request, available)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Match(%q) = %v, want %v", tt.request, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	got, err := device.Resolve([]string{"/dev/nvidia0", "/dev/nvidia*"}, available)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	want := []string{"/dev/nvidia0", "/dev/nvidia1", "/dev/nvidiactl"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() = %v, want %v", got, want)
+	}
+
+	_, err = device.Resolve([]string{"/dev/dri", "/dev/kfd"}, available)
+	if !errors.Is(err, types.ErrUnavailable) {
+		t.Errorf("Resolve() with missing device error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	if !device.Satisfies(nil, nil) {
+		t.Error("Satisfies() with no requests = false, want true")
+	}
+	if !device.Satisfies([]string{"/dev/dri"}, available) {
+		t.Error("Satisfies(/dev/dri) = false, want true")
+	}
+	if device.Satisfies([]string{"/dev/dri"}, nil) {
+		t.Error("Satisfies(/dev/dri) on node without devices = true, want false")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	// /dev/null is a character device on every unix host; directories and
+	// regular files are skipped
+	got := device.Detect([]string{"/dev/null", "/dev/null", "/dev", "/etc/hostname"})
+	if !reflect.DeepEqual(got, []string{"/dev/null"}) {
+		t.Errorf("Detect() = %v, want [/dev/null]", got)
+	}
+}
@@ -0,0 +1,138 @@
+package discovery
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/discovery"
+)
+
+// AdvertiseRetryInterval is how long a namespace that failed to be advertised
+// waits before it is advertised again
+const AdvertiseRetryInterval = 2 * time.Minute
+
+// Advertiser keeps this node advertised as a provider of namespaces in the
+// DHT. It advertises each namespace again before its record expires, and no
+// longer renews a withdrawn namespace, so that its record expires instead of
+// being kept alive for content the node dropped. Provider records cannot be
+// deleted from the DHT; they can only be left to expire.
+type Advertiser struct {
+	discovery discovery.Advertiser
+	logger    types.Logger
+
+	mu      sync.Mutex
+	adverts map[string]context.CancelFunc
+	stats   types.AdvertisementStats
+}
+
+// NewAdvertiser creates an advertiser publishing through d
+func NewAdvertiser(d discovery.Advertiser, logger types.Logger) *Advertiser {
+	return &Advertiser{
+		discovery: d,
+		logger:    logger,
+		adverts:   make(map[string]context.CancelFunc),
+	}
+}
+
+// Advertise starts advertising ns, which is renewed until it is withdrawn.
+// Advertising a namespace advertised already does nothing.
+func (a *Advertiser) Advertise(ns string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.adverts[ns]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.adverts[ns] = cancel
+	crash.Loop(ctx.Done(), a.logger, "discovery.advertise", func() { a.loop(ctx, ns) })
+	a.logger.Info("advertising namespace via DHT", "namespace", ns)
+}
+
+// Withdraw stops advertising ns, whose record then expires in the DHT
+func (a *Advertiser) Withdraw(ns string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.withdraw(ns)
+}
+
+// withdraw stops advertising ns. The caller holds a.mu.
+func (a *Advertiser) withdraw(ns string) {
+	cancel, ok := a.adverts[ns]
+	if !ok {
+		return
+	}
+	cancel()
+	delete(a.adverts, ns)
+	a.stats.Withdrawn++
+	a.logger.Info("withdrew namespace from DHT", "namespace", ns)
+}
+
+// Stop withdraws every namespace
+func (a *Advertiser) Stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for ns := range a.adverts {
+		a.withdraw(ns)
+	}
+}
+
+// Renew advertises ns right away if it is advertised, e.g. after the node
+// rejoined the network
+func (a *Advertiser) Renew(ctx context.Context, ns string) {
+	a.mu.Lock()
+	_, ok := a.adverts[ns]
+	a.mu.Unlock()
+	if ok {
+		a.advertise(ctx, ns)
+	}
+}
+
+// Stats returns the counters of the advertisements
+func (a *Advertiser) Stats() *types.AdvertisementStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := a.stats
+	stats.Active = make([]string, 0, len(a.adverts))
+	for ns := range a.adverts {
+		stats.Active = append(stats.Active, ns)
+	}
+	sort.Strings(stats.Active)
+	return &stats
+}
+
+// loop advertises ns until ctx is done, again at 7/8 of the lifetime of its
+// record, or after AdvertiseRetryInterval if it failed
+func (a *Advertiser) loop(ctx context.Context, ns string) {
+	for {
+		wait := AdvertiseRetryInterval
+		if ttl, ok := a.advertise(ctx, ns); ok && ttl > 0 {
+			wait = 7 * ttl / 8
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// advertise publishes the record of ns once, counting the outcome
+func (a *Advertiser) advertise(ctx context.Context, ns string) (time.Duration, bool) {
+	ttl, err := a.discovery.Advertise(ctx, ns)
+	if ctx.Err() != nil {
+		return 0, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.stats.Failed++
+		a.logger.Warn("failed to advertise namespace via DHT", "namespace", ns, "error", err)
+		return 0, false
+	}
+	a.stats.Advertised++
+	return ttl, true
+}
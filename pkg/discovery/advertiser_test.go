@@ -0,0 +1,96 @@
+package discovery_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	coredisc "github.com/libp2p/go-libp2p/core/discovery"
+)
+
+// fakeAdvertiser counts the advertisements of each namespace, whose records
+// live for ttl
+type fakeAdvertiser struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	err    error
+	counts map[string]int
+}
+
+func (f *fakeAdvertiser) Advertise(_ context.Context, ns string, _ ...coredisc.Option) (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts[ns]++
+	return f.ttl, f.err
+}
+
+func (f *fakeAdvertiser) count(ns string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts[ns]
+}
+
+func TestAdvertiserRenewsUntilWithdrawn(t *testing.T) {
+	fake := &fakeAdvertiser{ttl: 40 * time.Millisecond, counts: make(map[string]int)}
+	a := discovery.NewAdvertiser(fake, logging.Nop())
+	t.Cleanup(a.Stop)
+
+	a.Advertise("topic")
+	a.Advertise("topic")
+	a.Advertise("package")
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.count("topic") < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("topic advertised %d times, want it renewed", fake.count("topic"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	a.Withdraw("package")
+	time.Sleep(20 * time.Millisecond)
+	withdrawn := fake.count("package")
+	time.Sleep(150 * time.Millisecond)
+	if got := fake.count("package"); got != withdrawn {
+		t.Errorf("withdrawn namespace advertised %d more times", got-withdrawn)
+	}
+
+	stats := a.Stats()
+	if len(stats.Active) != 1 || stats.Active[0] != "topic" {
+		t.Errorf("Active = %v, want [topic]", stats.Active)
+	}
+	if stats.Withdrawn != 1 || stats.Advertised < 4 || stats.Failed != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	a.Stop()
+	if stats := a.Stats(); len(stats.Active) != 0 || stats.Withdrawn != 2 {
+		t.Errorf("Stats() after Stop = %+v, want every namespace withdrawn", stats)
+	}
+}
+
+func TestAdvertiserCountsFailures(t *testing.T) {
+	fake := &fakeAdvertiser{err: errors.New("no peers in routing table"), counts: make(map[string]int)}
+	a := discovery.NewAdvertiser(fake, logging.Nop())
+	t.Cleanup(a.Stop)
+
+	a.Advertise("topic")
+	a.Renew(context.Background(), "topic")
+	a.Renew(context.Background(), "other")
+	deadline := time.Now().Add(5 * time.Second)
+	for a.Stats().Failed < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats() = %+v, want 2 failures", a.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fake.count("other") != 0 {
+		t.Error("Renew() advertised a namespace that is not advertised")
+	}
+	if stats := a.Stats(); stats.Advertised != 0 {
+		t.Errorf("Advertised = %d, want 0", stats.Advertised)
+	}
+}
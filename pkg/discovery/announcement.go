@@ -0,0 +1,79 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// AnnouncementVersion is the version of the announcement format this node
+// writes and reads. Announcements without a version are version 1.
+//
+// Version 2 added Extensions and MinCompatible. New fields go into an
+// extension, so older nodes ignore them; the version is only raised when
+// the meaning of an existing field changes.
+const AnnouncementVersion = 2
+
+// Extension is an optional, versioned part of an announcement. Nodes skip
+// extensions they do not know, or whose version they cannot read.
+type Extension struct {
+	// Version is raised whenever Data changes in a way older readers of the
+	// extension would misread
+	Version int             `json:"v"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Extensions are the extensions of an announcement by name
+type Extensions map[string]Extension
+
+// Set stores value, encoded as JSON, as version of the extension name
+func (e Extensions) Set(name string, version int, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode extension %s: %w", name, err)
+	}
+	e[name] = Extension{Version: version, Data: data}
+	return nil
+}
+
+// Get decodes the extension name into value if the announcing node sent
+// it in version. It reports whether value was filled in, and fails only
+// if an extension of that version cannot be decoded.
+func (e Extensions) Get(name string, version int, value interface{}) (bool, error) {
+	ext, ok := e[name]
+	if !ok || ext.Version != version {
+		return false, nil
+	}
+	if err := json.Unmarshal(ext.Data, value); err != nil {
+		return false, fmt.Errorf("failed to decode extension %s v%d: %w", name, version, err)
+	}
+	return true, nil
+}
+
+// clone returns a copy of e that can be changed without changing e
+func (e Extensions) clone() Extensions {
+	if len(e) == 0 {
+		return nil
+	}
+	result := make(Extensions, len(e))
+	for name, ext := range e {
+		result[name] = ext
+	}
+	return result
+}
+
+// ParseAnnouncement decodes an announcement. Fields it does not know are
+// ignored; an announcement that needs a newer reader than this node, as
+// its MinCompatible says, fails with types.ErrProtocolNotSupported.
+func ParseAnnouncement(data []byte) (*NodeAnnouncement, error) {
+	var announcement NodeAnnouncement
+	if err := json.Unmarshal(data, &announcement); err != nil {
+		return nil, fmt.Errorf("%w: %v", types.ErrInvalidInput, err)
+	}
+	if announcement.MinCompatible > AnnouncementVersion {
+		return nil, fmt.Errorf("%w: announcement of version %d needs version %d, this node reads up to %d",
+			types.ErrProtocolNotSupported, announcement.Schema, announcement.MinCompatible, AnnouncementVersion)
+	}
+	return &announcement, nil
+}
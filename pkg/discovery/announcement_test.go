@@ -0,0 +1,129 @@
+package discovery_test
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// v1Announcement is the announcement as nodes before AnnouncementVersion 2
+// decode it
+type v1Announcement struct {
+	PeerID    string            `json:"peer_id"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Addrs     []string          `json:"addrs"`
+	Version   string            `json:"version,omitempty"`
+	Degraded  bool              `json:"degraded,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// capacity is an example extension
+type capacity struct {
+	Slots int `json:"slots"`
+}
+
+func TestParseV1Announcement(t *testing.T) {
+	data := []byte(`{"peer_id":"peer-a","name":"node-a","addrs":["/ip4/10.0.0.1/tcp/9000"],"version":"0.9.0","timestamp":100}`)
+
+	a, err := discovery.ParseAnnouncement(data)
+	if err != nil {
+		t.Fatalf("ParseAnnouncement() error = %v", err)
+	}
+	if a.Name != "node-a" || a.Version != "0.9.0" || a.Timestamp != 100 {
+		t.Errorf("ParseAnnouncement() = %+v, want the fields of node-a", a)
+	}
+	if a.Schema != 0 || a.Extensions != nil {
+		t.Errorf("Schema = %d, Extensions = %v, want a version 1 announcement without extensions", a.Schema, a.Extensions)
+	}
+}
+
+func TestV1NodeReadsNewAnnouncement(t *testing.T) {
+	a := discovery.NodeAnnouncement{
+		PeerID:     "peer-a",
+		Name:       "node-a",
+		Addrs:      []string{"/ip4/10.0.0.1/tcp/9000"},
+		Degraded:   true,
+		Timestamp:  100,
+		Schema:     discovery.AnnouncementVersion,
+		Extensions: make(discovery.Extensions),
+	}
+	if err := a.Extensions.Set("capacity", 1, capacity{Slots: 4}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var old v1Announcement
+	if err := json.Unmarshal(data, &old); err != nil {
+		t.Fatalf("old node failed to decode the announcement: %v", err)
+	}
+	if old.PeerID != "peer-a" || old.Name != "node-a" || !old.Degraded || old.Timestamp != 100 ||
+		!slices.Equal(old.Addrs, a.Addrs) {
+		t.Errorf("old node decoded %+v, want the fields of node-a", old)
+	}
+}
+
+func TestParseAnnouncementNeedsNewerReader(t *testing.T) {
+	data, err := json.Marshal(discovery.NodeAnnouncement{
+		PeerID:        "peer-a",
+		Schema:        discovery.AnnouncementVersion + 1,
+		MinCompatible: discovery.AnnouncementVersion + 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := discovery.ParseAnnouncement(data); !errors.Is(err, types.ErrProtocolNotSupported) {
+		t.Errorf("ParseAnnouncement() error = %v, want ErrProtocolNotSupported", err)
+	}
+
+	// Newer announcements that older readers understand are read
+	data, err = json.Marshal(discovery.NodeAnnouncement{
+		PeerID:        "peer-a",
+		Schema:        discovery.AnnouncementVersion + 1,
+		MinCompatible: discovery.AnnouncementVersion,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := discovery.ParseAnnouncement(data); err != nil {
+		t.Errorf("ParseAnnouncement() error = %v, want nil", err)
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	// An announcement of a newer node, with an extension this node does not
+	// know and one in a version it cannot read
+	data := []byte(`{"peer_id":"peer-a","timestamp":1,"schema":3,"extensions":{` +
+		`"capacity":{"v":1,"data":{"slots":4}},` +
+		`"cordon":{"v":1,"data":{"reason":"maintenance"}},` +
+		`"apps":{"v":7,"data":["a","b"]}}}`)
+	a, err := discovery.ParseAnnouncement(data)
+	if err != nil {
+		t.Fatalf("ParseAnnouncement() error = %v", err)
+	}
+
+	var c capacity
+	if ok, err := a.Extensions.Get("capacity", 1, &c); !ok || err != nil || c.Slots != 4 {
+		t.Errorf("Get(capacity) = %v, %v, %+v, want 4 slots", ok, err, c)
+	}
+	var apps []string
+	if ok, err := a.Extensions.Get("apps", 1, &apps); ok || err != nil {
+		t.Errorf("Get(apps v1) = %v, %v, want skipped", ok, err)
+	}
+	if ok, err := a.Extensions.Get("missing", 1, &apps); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v, want skipped", ok, err)
+	}
+
+	// Extensions of a known version that fail to decode are reported
+	var wrong []int
+	if _, err := a.Extensions.Get("capacity", 1, &wrong); err == nil {
+		t.Error("Get(capacity) into the wrong type succeeded, want error")
+	}
+}
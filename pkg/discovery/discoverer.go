@@ -0,0 +1,548 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// Discovery backends
+const (
+	// BackendGossip learns nodes from the announcements on DiscoveryTopic
+	BackendGossip = "gossip"
+
+	// BackendMDNS finds nodes on the local network with multicast DNS
+	BackendMDNS = "mdns"
+
+	// BackendDHT finds nodes advertising DiscoveryTopic in the DHT
+	BackendDHT = "dht"
+
+	// BackendStatic connects to a fixed list of node addresses
+	BackendStatic = "static"
+)
+
+// Discoverer is a source of cluster nodes. Backends that only learn peer IDs
+// and addresses report nodes without name, labels or version; Merged combines
+// them with the announcements of the gossip backend.
+type Discoverer interface {
+	// Name identifies the backend, e.g. BackendMDNS
+	Name() string
+
+	// Start begins discovering nodes
+	Start() error
+
+	// Stop stops discovering nodes
+	Stop()
+
+	// Nodes returns the nodes currently known
+	Nodes() []*DiscoveredNode
+
+	// Subscribe returns a channel receiving the changes to Nodes and a
+	// function that cancels the subscription and closes the channel
+	Subscribe() (<-chan Event, func())
+}
+
+// EventType is the kind of change an Event reports
+type EventType string
+
+// Event types
+const (
+	EventFound   EventType = "found"
+	EventUpdated EventType = "updated"
+	EventLost    EventType = "lost"
+)
+
+// Event reports a node that was found, changed or lost
+type Event struct {
+	Type EventType
+	Node *DiscoveredNode
+}
+
+// subscriptionBuffer is how many events a subscriber may fall behind before
+// events are dropped for it; Nodes always has the current view
+const subscriptionBuffer = 256
+
+// nodeSet holds the nodes known to a backend and notifies subscribers of changes
+type nodeSet struct {
+	mu    sync.RWMutex
+	nodes map[peer.ID]*DiscoveredNode
+	subs  map[chan Event]struct{}
+}
+
+func newNodeSet() *nodeSet {
+	return &nodeSet{
+		nodes: make(map[peer.ID]*DiscoveredNode),
+		subs:  make(map[chan Event]struct{}),
+	}
+}
+
+// put adds or replaces node and reports whether it was new
+func (s *nodeSet) put(node *DiscoveredNode) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.nodes[node.PeerID]
+	s.nodes[node.PeerID] = node
+	if exists {
+		s.publish(Event{Type: EventUpdated, Node: node})
+	} else {
+		s.publish(Event{Type: EventFound, Node: node})
+	}
+	return !exists
+}
+
+// remove drops the node with id, returning it if it was known
+func (s *nodeSet) remove(id peer.ID) *DiscoveredNode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	node := s.nodes[id]
+	if node == nil {
+		return nil
+	}
+	delete(s.nodes, id)
+	s.publish(Event{Type: EventLost, Node: node})
+	return node
+}
+
+// expire drops the nodes last seen more than timeout ago, or than
+// missedAnnouncements of their announce interval if that is longer, except
+// those keep returns true for, and returns the dropped nodes
+func (s *nodeSet) expire(timeout time.Duration, keep func(peer.ID) bool) []*DiscoveredNode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lost []*DiscoveredNode
+	now := time.Now()
+	for id, node := range s.nodes {
+		limit := max(timeout, missedAnnouncements*node.AnnounceInterval)
+		if now.Sub(node.LastSeen) <= limit || (keep != nil && keep(id)) {
+			continue
+		}
+		delete(s.nodes, id)
+		s.publish(Event{Type: EventLost, Node: node})
+		lost = append(lost, node)
+	}
+	return lost
+}
+
+// get returns the node with id, or nil
+func (s *nodeSet) get(id peer.ID) *DiscoveredNode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nodes[id]
+}
+
+// list returns the known nodes sorted by peer ID
+func (s *nodeSet) list() []*DiscoveredNode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nodes := make([]*DiscoveredNode, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].PeerID < nodes[j].PeerID
+	})
+	return nodes
+}
+
+// subscribe implements Discoverer.Subscribe
+func (s *nodeSet) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriptionBuffer)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, ch)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish sends ev to every subscriber that keeps up. s.mu must be held.
+func (s *nodeSet) publish(ev Event) {
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// peerSource tracks the nodes of a backend that only learns peer IDs and
+// addresses. A node is kept while the host stays connected to it, and lost
+// NodeTimeout after it was last found otherwise.
+type peerSource struct {
+	name   string
+	host   host.Host
+	logger types.Logger
+	nodes  *nodeSet
+}
+
+func newPeerSource(name string, h host.Host, logger types.Logger) *peerSource {
+	return &peerSource{name: name, host: h, logger: logger, nodes: newNodeSet()}
+}
+
+// found connects to a peer a backend found and records it as a node. It
+// returns the error if the peer could not be reached.
+func (p *peerSource) found(ctx context.Context, info peer.AddrInfo) error {
+	if info.ID == p.host.ID() {
+		return nil
+	}
+	if p.host.Network().Connectedness(info.ID) != network.Connected {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := p.host.Connect(ctx, info); err != nil {
+			p.logger.Debug("failed to connect to discovered peer", "backend", p.name, "peer", info.ID, "error", err)
+			return err
+		}
+	}
+
+	addrs := make([]string, 0, len(info.Addrs))
+	for _, addr := range info.Addrs {
+		addrs = append(addrs, addr.String())
+	}
+	isNew := p.nodes.put(&DiscoveredNode{
+		PeerID:   info.ID,
+		Addrs:    p2p.SortAddrStrings(addrs),
+		LastSeen: time.Now(),
+		Sources:  []string{p.name},
+	})
+	if isNew {
+		p.logger.Info("discovered peer", "backend", p.name, "peer", info.ID)
+	}
+	return nil
+}
+
+// expireLoop drops disconnected nodes until ctx is done
+func (p *peerSource) expireLoop(ctx context.Context) {
+	ticker := time.NewTicker(NodeTimeout / 2)
+	defer ticker.Stop()
+
+	connected := func(id peer.ID) bool {
+		return p.host.Network().Connectedness(id) == network.Connected
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, node := range p.nodes.expire(NodeTimeout, connected) {
+				p.logger.Info("peer lost", "backend", p.name, "peer", node.PeerID)
+			}
+		}
+	}
+}
+
+// rejoiner is implemented by backends that can look for nodes right away,
+// instead of waiting for their next round
+type rejoiner interface {
+	Rejoin(ctx context.Context)
+}
+
+// Merged combines the nodes of several backends into one view. A node found
+// by several backends appears once, with the announced details of the gossip
+// backend, the addresses of all and the names of the backends in Sources.
+type Merged struct {
+	logger types.Logger
+	nodes  *nodeSet
+
+	mu       sync.Mutex
+	started  bool
+	backends []Discoverer
+	cancels  []func()
+	seen     map[peer.ID]map[string]*DiscoveredNode
+}
+
+// Merge creates a merged view of backends. Starting it starts the backends.
+func Merge(logger types.Logger, backends ...Discoverer) *Merged {
+	return &Merged{
+		logger:   logger,
+		nodes:    newNodeSet(),
+		backends: backends,
+		seen:     make(map[peer.ID]map[string]*DiscoveredNode),
+	}
+}
+
+// Name implements Discoverer
+func (m *Merged) Name() string {
+	return "merged"
+}
+
+// Start starts every backend. Backends failing to start are logged and left out.
+func (m *Merged) Start() error {
+	m.mu.Lock()
+	backends := m.backends
+	m.backends = nil
+	m.started = true
+	m.mu.Unlock()
+
+	for _, d := range backends {
+		if err := m.Add(d); err != nil {
+			m.logger.Warn("failed to start discovery backend", "backend", d.Name(), "error", err)
+		}
+	}
+	return nil
+}
+
+// Add adds a backend, starting it if the view was started
+func (m *Merged) Add(d Discoverer) error {
+	m.mu.Lock()
+	if !m.started {
+		m.backends = append(m.backends, d)
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+
+	if err := d.Start(); err != nil {
+		return err
+	}
+	events, cancel := d.Subscribe()
+
+	m.mu.Lock()
+	m.backends = append(m.backends, d)
+	m.cancels = append(m.cancels, cancel)
+	m.mu.Unlock()
+
+	for _, node := range d.Nodes() {
+		m.update(d.Name(), Event{Type: EventFound, Node: node})
+	}
+	crash.Loop(nil, m.logger, "discovery.merge", func() {
+		for ev := range events {
+			m.update(d.Name(), ev)
+		}
+	})
+	return nil
+}
+
+// Stop stops every backend
+func (m *Merged) Stop() {
+	m.mu.Lock()
+	backends, cancels := m.backends, m.cancels
+	m.backends, m.cancels = nil, nil
+	m.started = false
+	m.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	for _, d := range backends {
+		d.Stop()
+	}
+}
+
+// Rejoin asks every backend that can to look for nodes right away
+func (m *Merged) Rejoin(ctx context.Context) {
+	m.mu.Lock()
+	backends := append([]Discoverer(nil), m.backends...)
+	m.mu.Unlock()
+
+	for _, d := range backends {
+		if r, ok := d.(rejoiner); ok {
+			r.Rejoin(ctx)
+		}
+	}
+}
+
+// Nodes implements Discoverer
+func (m *Merged) Nodes() []*DiscoveredNode {
+	return m.nodes.list()
+}
+
+// Node returns the node with id, or nil if no backend knows it
+func (m *Merged) Node(id peer.ID) *DiscoveredNode {
+	return m.nodes.get(id)
+}
+
+// Subscribe implements Discoverer
+func (m *Merged) Subscribe() (<-chan Event, func()) {
+	return m.nodes.subscribe()
+}
+
+// update applies an event of backend to the merged view
+func (m *Merged) update(backend string, ev Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := ev.Node.PeerID
+	views := m.seen[id]
+	if ev.Type == EventLost {
+		delete(views, backend)
+		if len(views) == 0 {
+			delete(m.seen, id)
+			m.nodes.remove(id)
+			return
+		}
+	} else {
+		if views == nil {
+			views = make(map[string]*DiscoveredNode)
+			m.seen[id] = views
+		}
+		views[backend] = ev.Node
+	}
+	m.nodes.put(combine(views))
+}
+
+// combine merges the views of several backends of one node
+func combine(views map[string]*DiscoveredNode) *DiscoveredNode {
+	backends := make([]string, 0, len(views))
+	for backend := range views {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+
+	// Start from the most detailed view, the one that carries a name
+	var base *DiscoveredNode
+	for _, backend := range backends {
+		if view := views[backend]; base == nil || (base.Name == "" && view.Name != "") {
+			base = view
+		}
+	}
+	node := *base
+	node.Sources = backends
+
+	var addrs []string
+	for _, backend := range backends {
+		view := views[backend]
+		if view.LastSeen.After(node.LastSeen) {
+			node.LastSeen = view.LastSeen
+		}
+		for _, addr := range view.Addrs {
+			if !slices.Contains(addrs, addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	node.Addrs = p2p.SortAddrStrings(addrs)
+	return &node
+}
+
+// Backends returns the discovery backends cfg selects
+func Backends(cfg *config.NodeConfig) []string {
+	if len(cfg.Discovery) > 0 {
+		return cfg.Discovery
+	}
+	backends := []string{BackendGossip}
+	if cfg.EnableMDNS {
+		backends = append(backends, BackendMDNS)
+	}
+	if !cfg.DisableDHT {
+		backends = append(backends, BackendDHT)
+	}
+	if len(cfg.StaticPeers) > 0 {
+		backends = append(backends, BackendStatic)
+	}
+	return backends
+}
+
+// Options configures the backends New composes
+type Options struct {
+	// Backends are the backends to use, see Backends
+	Backends []string
+
+	// Gossip describes this node in its announcements. Without it the gossip
+	// backend is left out; it can be added to the view later. When the gossip
+	// backend is degraded, the other backends are asked to Rejoin too.
+	Gossip *Config
+
+	// Routing is the DHT the dht backend uses; without it the backend is left out
+	Routing routing.ContentRouting
+
+	// StaticPeers are the nodes of the static backend
+	StaticPeers []string
+}
+
+// New creates a merged view of the backends opts selects, which still needs to be started
+func New(h host.Host, logger types.Logger, opts *Options) (*Merged, error) {
+	merged := Merge(logger)
+	for _, backend := range opts.Backends {
+		var d Discoverer
+		switch backend {
+		case BackendGossip:
+			if opts.Gossip == nil {
+				continue
+			}
+			cfg := *opts.Gossip
+			cfg.Rejoin = func(ctx context.Context) {
+				if opts.Gossip.Rejoin != nil {
+					opts.Gossip.Rejoin(ctx)
+				}
+				merged.Rejoin(ctx)
+			}
+			svc, err := NewService(h, logger, &cfg)
+			if err != nil {
+				merged.Stop()
+				return nil, fmt.Errorf("failed to create gossip discovery: %w", err)
+			}
+			d = svc
+		case BackendMDNS:
+			d = NewMDNS(h, logger)
+		case BackendDHT:
+			if opts.Routing == nil {
+				logger.Warn("dht discovery needs the DHT, which is disabled")
+				continue
+			}
+			d = NewRendezvous(h, opts.Routing, logger)
+		case BackendStatic:
+			static, err := NewStatic(h, opts.StaticPeers, logger)
+			if err != nil {
+				merged.Stop()
+				return nil, err
+			}
+			d = static
+		default:
+			merged.Stop()
+			return nil, fmt.Errorf("%w: unknown discovery backend %q (want %s, %s, %s or %s)",
+				types.ErrInvalidInput, backend, BackendGossip, BackendMDNS, BackendDHT, BackendStatic)
+		}
+		if err := merged.Add(d); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+// Gossip returns the gossip backend of the view, or nil if it has none
+func (m *Merged) Gossip() *Service {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.backends {
+		if svc, ok := d.(*Service); ok {
+			return svc
+		}
+	}
+	return nil
+}
+
+// Advertisements returns the counters of the DHT advertisements of the dht
+// backend of the view, or nil if it has none
+func (m *Merged) Advertisements() *types.AdvertisementStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.backends {
+		if r, ok := d.(*Rendezvous); ok {
+			return r.Advertisements()
+		}
+	}
+	return nil
+}
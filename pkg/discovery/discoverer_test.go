@@ -0,0 +1,174 @@
+package discovery_test
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// fakeBackend is a Discoverer whose events are sent by the test
+type fakeBackend struct {
+	name   string
+	mu     sync.Mutex
+	nodes  []*discovery.DiscoveredNode
+	events chan discovery.Event
+}
+
+func newFakeBackend(name string, nodes ...*discovery.DiscoveredNode) *fakeBackend {
+	return &fakeBackend{name: name, nodes: nodes, events: make(chan discovery.Event, 16)}
+}
+
+func (f *fakeBackend) Name() string { return f.name }
+func (f *fakeBackend) Start() error { return nil }
+func (f *fakeBackend) Stop()        {}
+
+func (f *fakeBackend) Nodes() []*discovery.DiscoveredNode {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nodes
+}
+
+func (f *fakeBackend) Subscribe() (<-chan discovery.Event, func()) {
+	var once sync.Once
+	return f.events, func() { once.Do(func() { close(f.events) }) }
+}
+
+// waitFor polls the merged view until cond holds
+func waitFor(t *testing.T, m *discovery.Merged, cond func([]*discovery.DiscoveredNode) bool) []*discovery.DiscoveredNode {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		nodes := m.Nodes()
+		if cond(nodes) {
+			return nodes
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("merged view did not reach the expected state, got %d node(s)", len(nodes))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMergedCombinesBackends(t *testing.T) {
+	id := peer.ID("node-a")
+	seen := time.Now()
+
+	gossip := newFakeBackend(discovery.BackendGossip, &discovery.DiscoveredNode{
+		PeerID:   id,
+		Name:     "node-a",
+		Labels:   map[string]string{"env": "lab"},
+		Addrs:    []string{"/ip4/127.0.0.1/tcp/9000"},
+		LastSeen: seen.Add(-time.Minute),
+	})
+	mdns := newFakeBackend(discovery.BackendMDNS, &discovery.DiscoveredNode{
+		PeerID:   id,
+		Addrs:    []string{"/ip4/192.168.1.10/tcp/9000", "/ip4/127.0.0.1/tcp/9000"},
+		LastSeen: seen,
+	})
+
+	m := discovery.Merge(logging.Nop(), mdns, gossip)
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(m.Stop)
+
+	nodes := m.Nodes()
+	if len(nodes) != 1 {
+		t.Fatalf("Nodes() = %d node(s), want 1", len(nodes))
+	}
+	node := nodes[0]
+	if node.Name != "node-a" || node.Labels["env"] != "lab" {
+		t.Errorf("node = %q %v, want the details announced over gossip", node.Name, node.Labels)
+	}
+	if want := []string{discovery.BackendGossip, discovery.BackendMDNS}; !slices.Equal(node.Sources, want) {
+		t.Errorf("Sources = %v, want %v", node.Sources, want)
+	}
+	if want := []string{"/ip4/192.168.1.10/tcp/9000", "/ip4/127.0.0.1/tcp/9000"}; !slices.Equal(node.Addrs, want) {
+		t.Errorf("Addrs = %v, want %v", node.Addrs, want)
+	}
+	if !node.LastSeen.Equal(seen) {
+		t.Errorf("LastSeen = %v, want the latest of the backends %v", node.LastSeen, seen)
+	}
+}
+
+func TestMergedLosesNodeWhenAllBackendsLoseIt(t *testing.T) {
+	id := peer.ID("node-a")
+	gossip := newFakeBackend(discovery.BackendGossip)
+	static := newFakeBackend(discovery.BackendStatic)
+
+	m := discovery.Merge(logging.Nop(), gossip, static)
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(m.Stop)
+
+	events, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+
+	gossip.events <- discovery.Event{Type: discovery.EventFound, Node: &discovery.DiscoveredNode{PeerID: id, Name: "node-a"}}
+	static.events <- discovery.Event{Type: discovery.EventFound, Node: &discovery.DiscoveredNode{PeerID: id}}
+	waitFor(t, m, func(nodes []*discovery.DiscoveredNode) bool {
+		return len(nodes) == 1 && len(nodes[0].Sources) == 2
+	})
+
+	gossip.events <- discovery.Event{Type: discovery.EventLost, Node: &discovery.DiscoveredNode{PeerID: id}}
+	nodes := waitFor(t, m, func(nodes []*discovery.DiscoveredNode) bool {
+		return len(nodes) == 1 && len(nodes[0].Sources) == 1
+	})
+	if nodes[0].Sources[0] != discovery.BackendStatic {
+		t.Errorf("Sources = %v, want only %s", nodes[0].Sources, discovery.BackendStatic)
+	}
+
+	static.events <- discovery.Event{Type: discovery.EventLost, Node: &discovery.DiscoveredNode{PeerID: id}}
+	waitFor(t, m, func(nodes []*discovery.DiscoveredNode) bool { return len(nodes) == 0 })
+
+	var got []discovery.EventType
+	for len(events) > 0 {
+		got = append(got, (<-events).Type)
+	}
+	if len(got) == 0 || got[0] != discovery.EventFound || got[len(got)-1] != discovery.EventLost {
+		t.Errorf("events = %v, want found first and lost last", got)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.NodeConfig
+		want []string
+	}{
+		{
+			name: "defaults",
+			want: []string{discovery.BackendGossip, discovery.BackendDHT},
+		},
+		{
+			name: "legacy switches",
+			cfg:  config.NodeConfig{EnableMDNS: true, DisableDHT: true, StaticPeers: []string{"/ip4/10.0.0.1/tcp/9000/p2p/x"}},
+			want: []string{discovery.BackendGossip, discovery.BackendMDNS, discovery.BackendStatic},
+		},
+		{
+			name: "explicit list",
+			cfg:  config.NodeConfig{Discovery: []string{discovery.BackendStatic}, EnableMDNS: true},
+			want: []string{discovery.BackendStatic},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := discovery.Backends(&tt.cfg); !slices.Equal(got, tt.want) {
+				t.Errorf("Backends() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRejectsUnknownBackend(t *testing.T) {
+	if _, err := discovery.New(nil, logging.Nop(), &discovery.Options{Backends: []string{"carrier-pigeon"}}); err == nil {
+		t.Fatal("New() error = nil, want an error for an unknown backend")
+	}
+}
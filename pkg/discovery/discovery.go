@@ -3,27 +3,39 @@ package discovery
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"sync"
 	"time"
 
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
-	"github.com/libp2p/go-libp2p/core/routing"
-	drouting "github.com/libp2p/go-libp2p/p2p/discovery/routing"
-	dutil "github.com/libp2p/go-libp2p/p2p/discovery/util"
 )
 
 const (
 	// DiscoveryTopic is the pubsub topic for node discovery
-	DiscoveryTopic = "p2p-playground/discovery"
+	DiscoveryTopic = consts.DiscoveryTopic
 
-	// AnnounceInterval is how often nodes announce themselves
+	// AnnounceInterval is how often nodes announce themselves by default
 	AnnounceInterval = 10 * time.Second
 
-	// NodeTimeout is how long before a node is considered offline
+	// NodeTimeout is how long before a node is considered offline, or
+	// missedAnnouncements of its announce interval if that is longer
 	NodeTimeout = 30 * time.Second
+
+	// HealthInterval is how often the peers on DiscoveryTopic are counted
+	HealthInterval = 5 * time.Second
+
+	// DegradedAfter is how long a node that had peers on DiscoveryTopic may
+	// have none before discovery is degraded and it tries to rejoin the cluster
+	DegradedAfter = 15 * time.Second
+
+	// MaxRejoinInterval caps the backoff between attempts to rejoin the cluster
+	MaxRejoinInterval = 5 * time.Minute
 )
 
 // NodeAnnouncement is broadcast by nodes to announce their presence
@@ -33,9 +45,30 @@ type NodeAnnouncement struct {
 	Labels    map[string]string `json:"labels,omitempty"`
 	Addrs     []string          `json:"addrs"`
 	Version   string            `json:"version,omitempty"`
+	Commit    string            `json:"commit,omitempty"`
+	StartedAt int64             `json:"started_at,omitempty"`
+	Devices   []string          `json:"devices,omitempty"`
+	Platform  string            `json:"platform,omitempty"` // GOOS/GOARCH of the node
+	Degraded  bool              `json:"degraded,omitempty"`
+	Alerts    []string          `json:"alerts,omitempty"`
+	Interval  int64             `json:"interval,omitempty"` // Seconds until the next announcement
 	Timestamp int64             `json:"timestamp"`
+
+	// Schema is the AnnouncementVersion of the announcing node, 0 for version 1
+	Schema int `json:"schema,omitempty"`
+
+	// MinCompatible is the oldest AnnouncementVersion able to read the
+	// announcement, 0 for any. Nodes older than that ignore it.
+	MinCompatible int `json:"min_compatible,omitempty"`
+
+	// Extensions carry optional fields older nodes skip
+	Extensions Extensions `json:"extensions,omitempty"`
 }
 
+// missedAnnouncements is how many announcements of a node announcing less
+// often than the default may be missed before it is considered offline
+const missedAnnouncements = 3
+
 // DiscoveredNode represents a discovered p2p-playground node
 type DiscoveredNode struct {
 	PeerID   peer.ID
@@ -43,32 +76,92 @@ type DiscoveredNode struct {
 	Labels   map[string]string
 	Addrs    []string
 	Version  string
+	Commit   string
+	Devices  []string
+	Platform string // GOOS/GOARCH, or empty if the node did not announce it
 	LastSeen time.Time
+
+	// StartedAt is when the node started, or zero if it did not announce it
+	StartedAt time.Time
+
+	// Uptime is how long the node had been running when it last announced itself
+	Uptime time.Duration
+
+	// Degraded is set while alerts fire on the node
+	Degraded bool
+
+	// Alerts summarizes the alerts firing on the node
+	Alerts []string
+
+	// AnnounceInterval is how often the node announces itself, if it said
+	AnnounceInterval time.Duration
+
+	// Sources are the discovery backends that found the node, e.g. BackendMDNS
+	Sources []string
+
+	// Extensions are the announcement extensions of the node, if it sent any
+	Extensions Extensions
 }
 
-// Service handles node discovery via pubsub
+// Health describes whether the gossip backend reaches the cluster
+type Health struct {
+	// Degraded is set while a node that had peers on DiscoveryTopic has none,
+	// so its node list goes stale. A node that never had any, such as the only
+	// node of a cluster, is not degraded.
+	Degraded bool
+
+	// Since is when the topic lost its last peer before discovery last
+	// became degraded, or zero if it never did
+	Since time.Time
+
+	// Peers is the number of peers subscribed to DiscoveryTopic
+	Peers int
+
+	// Rejoins counts the attempts to rejoin the cluster
+	Rejoins int
+}
+
+// Service discovers nodes from the announcements nodes publish on
+// DiscoveryTopic, and announces this node there. It is the gossip backend.
 type Service struct {
 	host   host.Host
 	pubsub *pubsub.PubSub
 	topic  *pubsub.Topic
-	sub    *pubsub.Subscription
 	logger types.Logger
 
-	// DHT-based peer discovery
-	routingDiscovery *drouting.RoutingDiscovery
+	// sub is replaced when resubscribing
+	sub   *pubsub.Subscription
+	subMu sync.Mutex
 
 	// Node info for announcements
 	nodeName   string
 	nodeLabels map[string]string
 	version    string
+	commit     string
+	startedAt  time.Time
+	devices    []string
+	platform   string
+	interval   time.Duration
+
+	// Alerts firing on this node, announced as degraded
+	alerts   []string
+	alertsMu sync.Mutex
+
+	// Extensions announced by this node
+	extensions   Extensions
+	extensionsMu sync.Mutex
+
+	// Nodes whose announcements this node is too old to read, warned about once
+	incompatible map[peer.ID]bool
 
 	// Discovered nodes
-	nodes   map[peer.ID]*DiscoveredNode
-	nodesMu sync.RWMutex
+	nodes *nodeSet
 
-	// Callbacks
-	onNodeDiscovered func(*DiscoveredNode)
-	onNodeLost       func(peer.ID)
+	// Health of the mesh, checked by healthLoop
+	health         Health
+	healthMu       sync.Mutex
+	rejoin         func(ctx context.Context)
+	onHealthChange func(Health)
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -79,7 +172,20 @@ type Config struct {
 	NodeName   string
 	NodeLabels map[string]string
 	Version    string
-	Routing    routing.ContentRouting // Optional: DHT routing for peer discovery
+	Commit     string    // VCS revision of the build
+	StartedAt  time.Time // When the node started, to announce its uptime
+	Devices    []string  // Devices apps may request on this node
+	Platform   string    // GOOS/GOARCH of the node
+
+	// AnnounceInterval is how often the node announces itself (default: AnnounceInterval)
+	AnnounceInterval time.Duration
+
+	// Rejoin looks for the cluster again while discovery is degraded, e.g. by
+	// dialing the bootstrap peers. The backend also resubscribes and announces.
+	Rejoin func(ctx context.Context)
+
+	// OnHealthChange is called when discovery becomes degraded or recovers
+	OnHealthChange func(Health)
 }
 
 // NewService creates a new discovery service
@@ -107,6 +213,11 @@ func NewService(h host.Host, logger types.Logger, cfg *Config) (*Service, error)
 		return nil, err
 	}
 
+	interval := cfg.AnnounceInterval
+	if interval <= 0 {
+		interval = AnnounceInterval
+	}
+
 	s := &Service{
 		host:       h,
 		pubsub:     ps,
@@ -116,81 +227,110 @@ func NewService(h host.Host, logger types.Logger, cfg *Config) (*Service, error)
 		nodeName:   cfg.NodeName,
 		nodeLabels: cfg.NodeLabels,
 		version:    cfg.Version,
-		nodes:      make(map[peer.ID]*DiscoveredNode),
+		commit:     cfg.Commit,
+		startedAt:  cfg.StartedAt,
+		devices:    cfg.Devices,
+		platform:   cfg.Platform,
+		interval:   interval,
+		nodes:      newNodeSet(),
+		extensions: make(Extensions),
 		ctx:        ctx,
 		cancel:     cancel,
-	}
 
-	// Set up DHT-based routing discovery if routing is provided
-	if cfg.Routing != nil {
-		s.routingDiscovery = drouting.NewRoutingDiscovery(cfg.Routing)
-		logger.Info("DHT-based peer discovery enabled for topic", "topic", DiscoveryTopic)
+		incompatible:   make(map[peer.ID]bool),
+		rejoin:         cfg.Rejoin,
+		onHealthChange: cfg.OnHealthChange,
 	}
 
 	return s, nil
 }
 
-// SetOnNodeDiscovered sets the callback for when a new node is discovered
-func (s *Service) SetOnNodeDiscovered(cb func(*DiscoveredNode)) {
-	s.onNodeDiscovered = cb
-}
-
-// SetOnNodeLost sets the callback for when a node is lost
-func (s *Service) SetOnNodeLost(cb func(peer.ID)) {
-	s.onNodeLost = cb
+// Name implements Discoverer
+func (s *Service) Name() string {
+	return BackendGossip
 }
 
 // Start begins the discovery service
-func (s *Service) Start() {
+func (s *Service) Start() error {
 	// Start listening for announcements
-	go s.listenLoop()
+	crash.Loop(s.ctx.Done(), s.logger, "discovery.gossip.listen", s.listenLoop)
 
 	// Start announcing ourselves
-	go s.announceLoop()
+	crash.Loop(s.ctx.Done(), s.logger, "discovery.gossip.announce", s.announceLoop)
 
 	// Start cleanup loop for stale nodes
-	go s.cleanupLoop()
+	crash.Loop(s.ctx.Done(), s.logger, "discovery.gossip.cleanup", s.cleanupLoop)
 
-	// Start DHT-based peer discovery if routing is available
-	if s.routingDiscovery != nil {
-		go s.dhtPeerDiscoveryLoop()
-	}
+	// Watch for the mesh losing all peers
+	crash.Loop(s.ctx.Done(), s.logger, "discovery.gossip.health", s.healthLoop)
 
 	s.logger.Info("discovery service started", "topic", DiscoveryTopic)
+	return nil
 }
 
 // Stop stops the discovery service
 func (s *Service) Stop() {
-	s.cancel()
-	s.sub.Cancel()
+	// The topic is closed through the pubsub loop, which stops with s.ctx
+	s.subscription().Cancel()
 	if err := s.topic.Close(); err != nil {
 		s.logger.Warn("failed to close topic", "error", err)
 	}
+	s.cancel()
 	s.logger.Info("discovery service stopped")
 }
 
-// GetNodes returns all discovered nodes
-func (s *Service) GetNodes() []*DiscoveredNode {
-	s.nodesMu.RLock()
-	defer s.nodesMu.RUnlock()
+// PubSub returns the gossipsub instance, for other subsystems to join their own topics
+func (s *Service) PubSub() *pubsub.PubSub {
+	return s.pubsub
+}
+
+// Nodes implements Discoverer
+func (s *Service) Nodes() []*DiscoveredNode {
+	return s.nodes.list()
+}
+
+// Node returns a specific node by peer ID, or nil
+func (s *Service) Node(peerID peer.ID) *DiscoveredNode {
+	return s.nodes.get(peerID)
+}
+
+// Subscribe implements Discoverer
+func (s *Service) Subscribe() (<-chan Event, func()) {
+	return s.nodes.subscribe()
+}
 
-	nodes := make([]*DiscoveredNode, 0, len(s.nodes))
-	for _, node := range s.nodes {
-		nodes = append(nodes, node)
+// SetAlerts sets the alerts firing on this node and announces the change.
+// The node is announced as degraded while there are any.
+func (s *Service) SetAlerts(alerts []string) {
+	s.alertsMu.Lock()
+	s.alerts = append([]string(nil), alerts...)
+	s.alertsMu.Unlock()
+
+	if err := s.Announce(); err != nil {
+		s.logger.Warn("failed to announce", "error", err)
 	}
-	return nodes
 }
 
-// GetNode returns a specific node by peer ID
-func (s *Service) GetNode(peerID peer.ID) *DiscoveredNode {
-	s.nodesMu.RLock()
-	defer s.nodesMu.RUnlock()
-	return s.nodes[peerID]
+// SetExtension announces value as version of the extension name from now
+// on, replacing what was announced under that name
+func (s *Service) SetExtension(name string, version int, value interface{}) error {
+	s.extensionsMu.Lock()
+	err := s.extensions.Set(name, version, value)
+	s.extensionsMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := s.Announce(); err != nil {
+		s.logger.Warn("failed to announce", "error", err)
+	}
+	return nil
 }
 
 // Announce broadcasts our presence to the network
 func (s *Service) Announce() error {
-	addrs := s.host.Addrs()
+	// Most routable first, so peers try those before LAN and loopback addresses
+	addrs := p2p.SortAddrs(s.host.Addrs())
 	addrStrs := make([]string, len(addrs))
 	for i, addr := range addrs {
 		addrStrs[i] = addr.String()
@@ -202,8 +342,27 @@ func (s *Service) Announce() error {
 		Labels:    s.nodeLabels,
 		Addrs:     addrStrs,
 		Version:   s.version,
+		Commit:    s.commit,
+		Devices:   s.devices,
+		Platform:  s.platform,
 		Timestamp: time.Now().Unix(),
+		Schema:    AnnouncementVersion,
 	}
+	// Nodes announcing at the default interval need not say so, which keeps
+	// their announcements as they were
+	if s.interval != AnnounceInterval {
+		announcement.Interval = int64(s.interval / time.Second)
+	}
+	if !s.startedAt.IsZero() {
+		announcement.StartedAt = s.startedAt.Unix()
+	}
+	s.alertsMu.Lock()
+	announcement.Alerts = s.alerts
+	announcement.Degraded = len(s.alerts) > 0
+	s.alertsMu.Unlock()
+	s.extensionsMu.Lock()
+	announcement.Extensions = s.extensions.clone()
+	s.extensionsMu.Unlock()
 
 	data, err := json.Marshal(announcement)
 	if err != nil {
@@ -213,14 +372,42 @@ func (s *Service) Announce() error {
 	return s.topic.Publish(s.ctx, data)
 }
 
+// subscription returns the current subscription to DiscoveryTopic
+func (s *Service) subscription() *pubsub.Subscription {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	return s.sub
+}
+
+// resubscribe replaces the subscription to DiscoveryTopic
+func (s *Service) resubscribe() error {
+	sub, err := s.topic.Subscribe()
+	if err != nil {
+		return err
+	}
+	s.subMu.Lock()
+	old := s.sub
+	s.sub = sub
+	s.subMu.Unlock()
+	old.Cancel()
+	return nil
+}
+
 // listenLoop listens for node announcements
 func (s *Service) listenLoop() {
 	for {
-		msg, err := s.sub.Next(s.ctx)
+		sub := s.subscription()
+		msg, err := sub.Next(s.ctx)
 		if err != nil {
 			if s.ctx.Err() != nil {
 				return // Context cancelled
 			}
+			if errors.Is(err, pubsub.ErrSubscriptionCancelled) {
+				if s.subscription() != sub {
+					continue // Resubscribed
+				}
+				return // Stopping
+			}
 			s.logger.Warn("error receiving message", "error", err)
 			continue
 		}
@@ -230,8 +417,15 @@ func (s *Service) listenLoop() {
 			continue
 		}
 
-		var announcement NodeAnnouncement
-		if err := json.Unmarshal(msg.Data, &announcement); err != nil {
+		announcement, err := ParseAnnouncement(msg.Data)
+		if errors.Is(err, types.ErrProtocolNotSupported) {
+			if from := msg.GetFrom(); !s.incompatible[from] {
+				s.incompatible[from] = true
+				s.logger.Warn("ignoring announcements of a newer node, upgrade this node to see it", "peer", from, "error", err)
+			}
+			continue
+		}
+		if err != nil {
 			s.logger.Warn("invalid announcement", "error", err, "from", msg.ReceivedFrom)
 			continue
 		}
@@ -242,37 +436,44 @@ func (s *Service) listenLoop() {
 			continue
 		}
 
-		s.handleAnnouncement(peerID, &announcement)
+		s.handleAnnouncement(peerID, announcement)
 	}
 }
 
 // handleAnnouncement processes a node announcement
 func (s *Service) handleAnnouncement(peerID peer.ID, announcement *NodeAnnouncement) {
-	s.nodesMu.Lock()
-	defer s.nodesMu.Unlock()
-
-	existing := s.nodes[peerID]
-	isNew := existing == nil
-
 	node := &DiscoveredNode{
 		PeerID:   peerID,
 		Name:     announcement.Name,
 		Labels:   announcement.Labels,
-		Addrs:    announcement.Addrs,
+		Addrs:    p2p.SortAddrStrings(announcement.Addrs),
 		Version:  announcement.Version,
+		Commit:   announcement.Commit,
+		Devices:  announcement.Devices,
+		Platform: announcement.Platform,
+		Degraded: announcement.Degraded,
+		Alerts:   announcement.Alerts,
 		LastSeen: time.Now(),
+		Sources:  []string{BackendGossip},
+
+		Extensions: announcement.Extensions,
+
+		AnnounceInterval: time.Duration(announcement.Interval) * time.Second,
+	}
+	if announcement.StartedAt > 0 {
+		node.StartedAt = time.Unix(announcement.StartedAt, 0)
+		// Measured on the announcing node's clock, so skew does not distort it
+		if announcement.Timestamp >= announcement.StartedAt {
+			node.Uptime = time.Duration(announcement.Timestamp-announcement.StartedAt) * time.Second
+		}
 	}
-	s.nodes[peerID] = node
 
-	if isNew {
+	if s.nodes.put(node) {
 		s.logger.Info("discovered new node",
 			"peer_id", peerID,
 			"name", announcement.Name,
 			"addrs", announcement.Addrs,
 		)
-		if s.onNodeDiscovered != nil {
-			go s.onNodeDiscovered(node)
-		}
 	}
 }
 
@@ -283,7 +484,7 @@ func (s *Service) announceLoop() {
 		s.logger.Warn("failed to announce", "error", err)
 	}
 
-	ticker := time.NewTicker(AnnounceInterval)
+	ticker := time.NewTicker(s.interval)
 	defer ticker.Stop()
 
 	for {
@@ -298,46 +499,103 @@ func (s *Service) announceLoop() {
 	}
 }
 
-// cleanupLoop removes stale nodes
-func (s *Service) cleanupLoop() {
-	ticker := time.NewTicker(NodeTimeout / 2)
+// Health returns the health of the mesh as of the last check
+func (s *Service) Health() Health {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	return s.health
+}
+
+// healthLoop counts the peers on DiscoveryTopic. Once a node that had peers
+// has had none for DegradedAfter, discovery is degraded and the node tries to
+// rejoin the cluster, backing off up to MaxRejoinInterval until peers return.
+func (s *Service) healthLoop() {
+	ticker := time.NewTicker(HealthInterval)
 	defer ticker.Stop()
 
+	hadPeers := false
+	var emptySince, nextRejoin time.Time
+	backoff := DegradedAfter
+
 	for {
 		select {
 		case <-s.ctx.Done():
 			return
-		case <-ticker.C:
-			s.cleanupStaleNodes()
+		case now := <-ticker.C:
+			peers := len(s.topic.ListPeers())
+
+			s.healthMu.Lock()
+			s.health.Peers = peers
+			health := s.health
+			s.healthMu.Unlock()
+
+			switch {
+			case peers > 0:
+				hadPeers = true
+				emptySince = time.Time{}
+				backoff = DegradedAfter
+				if health.Degraded {
+					s.logger.Info("discovery recovered", "peers", peers, "rejoins", health.Rejoins)
+					s.setDegraded(false, health.Since)
+					// Let the cluster see this node again without waiting for the next announcement
+					if err := s.Announce(); err != nil {
+						s.logger.Warn("failed to announce", "error", err)
+					}
+				}
+			case !hadPeers:
+				// Nothing to lose yet, e.g. the first node of a cluster
+			case emptySince.IsZero():
+				emptySince = now
+			case !health.Degraded && now.Sub(emptySince) >= DegradedAfter:
+				s.logger.Warn("discovery degraded: no peers on the discovery topic", "since", emptySince)
+				s.setDegraded(true, emptySince)
+				s.rejoinCluster()
+				nextRejoin = now.Add(backoff)
+			case health.Degraded && !now.Before(nextRejoin):
+				backoff = min(2*backoff, MaxRejoinInterval)
+				s.rejoinCluster()
+				nextRejoin = now.Add(backoff)
+			}
 		}
 	}
 }
 
-// cleanupStaleNodes removes nodes that haven't announced recently
-func (s *Service) cleanupStaleNodes() {
-	s.nodesMu.Lock()
-	defer s.nodesMu.Unlock()
-
-	now := time.Now()
-	for peerID, node := range s.nodes {
-		if now.Sub(node.LastSeen) > NodeTimeout {
-			delete(s.nodes, peerID)
-			s.logger.Info("node lost", "peer_id", peerID, "name", node.Name)
-			if s.onNodeLost != nil {
-				go s.onNodeLost(peerID)
-			}
-		}
+// setDegraded updates the health and reports the change
+func (s *Service) setDegraded(degraded bool, since time.Time) {
+	s.healthMu.Lock()
+	s.health.Degraded = degraded
+	s.health.Since = since
+	health := s.health
+	s.healthMu.Unlock()
+
+	if s.onHealthChange != nil {
+		s.onHealthChange(health)
 	}
 }
 
-// dhtPeerDiscoveryLoop uses DHT to discover peers subscribed to the same topic
-func (s *Service) dhtPeerDiscoveryLoop() {
-	// Advertise ourselves as a provider for this topic
-	dutil.Advertise(s.ctx, s.routingDiscovery, DiscoveryTopic)
-	s.logger.Info("advertising topic via DHT", "topic", DiscoveryTopic)
+// rejoinCluster looks for the cluster again, resubscribes to DiscoveryTopic
+// and announces this node
+func (s *Service) rejoinCluster() {
+	s.healthMu.Lock()
+	s.health.Rejoins++
+	s.healthMu.Unlock()
 
-	// Periodically find peers
-	ticker := time.NewTicker(10 * time.Second)
+	if s.rejoin != nil {
+		ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+		s.rejoin(ctx)
+		cancel()
+	}
+	if err := s.resubscribe(); err != nil {
+		s.logger.Warn("failed to resubscribe to discovery topic", "error", err)
+	}
+	if err := s.Announce(); err != nil {
+		s.logger.Warn("failed to announce", "error", err)
+	}
+}
+
+// cleanupLoop removes stale nodes
+func (s *Service) cleanupLoop() {
+	ticker := time.NewTicker(NodeTimeout / 2)
 	defer ticker.Stop()
 
 	for {
@@ -345,47 +603,14 @@ func (s *Service) dhtPeerDiscoveryLoop() {
 		case <-s.ctx.Done():
 			return
 		case <-ticker.C:
-			s.findDHTPeers()
+			s.cleanupStaleNodes()
 		}
 	}
 }
 
-// findDHTPeers finds and connects to peers via DHT
-func (s *Service) findDHTPeers() {
-	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
-	defer cancel()
-
-	peerChan, err := s.routingDiscovery.FindPeers(ctx, DiscoveryTopic)
-	if err != nil {
-		s.logger.Warn("failed to find peers via DHT", "error", err)
-		return
-	}
-
-	foundCount := 0
-	for p := range peerChan {
-		if p.ID == s.host.ID() {
-			continue // Skip ourselves
-		}
-		if len(p.Addrs) == 0 {
-			continue // Skip peers without addresses
-		}
-
-		// Check if already connected
-		if s.host.Network().Connectedness(p.ID) == 1 { // Connected
-			continue
-		}
-
-		// Try to connect
-		if err := s.host.Connect(ctx, p); err != nil {
-			s.logger.Debug("failed to connect to DHT peer", "peer", p.ID, "error", err)
-			continue
-		}
-
-		foundCount++
-		s.logger.Info("connected to peer via DHT discovery", "peer", p.ID, "addrs", p.Addrs)
-	}
-
-	if foundCount > 0 {
-		s.logger.Info("DHT peer discovery completed", "new_connections", foundCount)
+// cleanupStaleNodes removes nodes that haven't announced recently
+func (s *Service) cleanupStaleNodes() {
+	for _, node := range s.nodes.expire(NodeTimeout, nil) {
+		s.logger.Info("node lost", "peer_id", node.PeerID, "name", node.Name)
 	}
 }
@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"context"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+)
+
+// MDNSServiceName is the service nodes advertise and look for with multicast DNS
+const MDNSServiceName = consts.MDNSServiceName
+
+// MDNS finds nodes on the local network with multicast DNS and connects to them
+type MDNS struct {
+	*peerSource
+	service mdns.Service
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewMDNS creates an mDNS discovery backend
+func NewMDNS(h host.Host, logger types.Logger) *MDNS {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &MDNS{
+		peerSource: newPeerSource(BackendMDNS, h, logger),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	m.service = mdns.NewMdnsService(h, MDNSServiceName, m)
+	return m
+}
+
+// Name implements Discoverer
+func (m *MDNS) Name() string {
+	return BackendMDNS
+}
+
+// Start implements Discoverer
+func (m *MDNS) Start() error {
+	if err := m.service.Start(); err != nil {
+		return types.WrapError(err, "failed to start mDNS")
+	}
+	crash.Loop(m.ctx.Done(), m.logger, "discovery.mdns.expire", func() { m.expireLoop(m.ctx) })
+
+	m.logger.Info("mDNS discovery enabled")
+	return nil
+}
+
+// Stop implements Discoverer
+func (m *MDNS) Stop() {
+	m.cancel()
+	if err := m.service.Close(); err != nil {
+		m.logger.Warn("failed to stop mDNS", "error", err)
+	}
+}
+
+// Nodes implements Discoverer
+func (m *MDNS) Nodes() []*DiscoveredNode {
+	return m.nodes.list()
+}
+
+// Subscribe implements Discoverer
+func (m *MDNS) Subscribe() (<-chan Event, func()) {
+	return m.nodes.subscribe()
+}
+
+// HandlePeerFound implements mdns.Notifee
+func (m *MDNS) HandlePeerFound(info peer.AddrInfo) {
+	_ = m.found(m.ctx, info)
+}
@@ -0,0 +1,32 @@
+package discovery_test
+
+import (
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/libp2p/go-libp2p"
+	"go.uber.org/goleak"
+)
+
+func TestMDNSStopStopsGoroutines(t *testing.T) {
+	// Goroutines libp2p starts once per process are not the service's to stop
+	warm, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("libp2p.New() error = %v", err)
+	}
+	_ = warm.Close()
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("libp2p.New() error = %v", err)
+	}
+	defer func() { _ = h.Close() }()
+
+	m := discovery.NewMDNS(h, logging.NewNopLogger())
+	if err := m.Start(); err != nil {
+		t.Skipf("mDNS unavailable: %v", err)
+	}
+	m.Stop()
+}
@@ -0,0 +1,125 @@
+package discovery
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/routing"
+	drouting "github.com/libp2p/go-libp2p/p2p/discovery/routing"
+)
+
+// RendezvousInterval is how often the DHT is searched for nodes
+const RendezvousInterval = 10 * time.Second
+
+// Rendezvous advertises this node under DiscoveryTopic in the DHT and
+// connects to the other nodes advertising it
+type Rendezvous struct {
+	*peerSource
+	discovery *drouting.RoutingDiscovery
+	adverts   *Advertiser
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	// stale counts the provider records found for unreachable peers
+	stale atomic.Uint64
+}
+
+// NewRendezvous creates a DHT rendezvous discovery backend
+func NewRendezvous(h host.Host, router routing.ContentRouting, logger types.Logger) *Rendezvous {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := drouting.NewRoutingDiscovery(router)
+	return &Rendezvous{
+		peerSource: newPeerSource(BackendDHT, h, logger),
+		discovery:  d,
+		adverts:    NewAdvertiser(d, logger),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Name implements Discoverer
+func (r *Rendezvous) Name() string {
+	return BackendDHT
+}
+
+// Start implements Discoverer
+func (r *Rendezvous) Start() error {
+	crash.Loop(r.ctx.Done(), r.logger, "discovery.dht", r.loop)
+	crash.Loop(r.ctx.Done(), r.logger, "discovery.dht.expire", func() { r.expireLoop(r.ctx) })
+	return nil
+}
+
+// Stop implements Discoverer. The records of the node are no longer renewed
+// and expire in the DHT.
+func (r *Rendezvous) Stop() {
+	r.adverts.Stop()
+	r.cancel()
+}
+
+// Advertisements returns the counters of the DHT advertisements of the node
+func (r *Rendezvous) Advertisements() *types.AdvertisementStats {
+	stats := r.adverts.Stats()
+	stats.StaleProviders = r.stale.Load()
+	return stats
+}
+
+// Nodes implements Discoverer
+func (r *Rendezvous) Nodes() []*DiscoveredNode {
+	return r.nodes.list()
+}
+
+// Subscribe implements Discoverer
+func (r *Rendezvous) Subscribe() (<-chan Event, func()) {
+	return r.nodes.subscribe()
+}
+
+// loop advertises this node and periodically looks for others
+func (r *Rendezvous) loop() {
+	// Advertise ourselves as a provider for this topic
+	r.adverts.Advertise(DiscoveryTopic)
+
+	ticker := time.NewTicker(RendezvousInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.findPeers()
+		}
+	}
+}
+
+// Rejoin advertises this node in the DHT again and looks for the others
+func (r *Rendezvous) Rejoin(ctx context.Context) {
+	r.adverts.Renew(ctx, DiscoveryTopic)
+	r.findPeers()
+}
+
+// findPeers finds and connects to the peers advertising DiscoveryTopic. The
+// records of peers without addresses or that cannot be reached are counted as
+// stale; they linger in the DHT until they expire.
+func (r *Rendezvous) findPeers() {
+	ctx, cancel := context.WithTimeout(r.ctx, 30*time.Second)
+	defer cancel()
+
+	peerChan, err := r.discovery.FindPeers(ctx, DiscoveryTopic)
+	if err != nil {
+		r.logger.Warn("failed to find peers via DHT", "error", err)
+		return
+	}
+
+	for p := range peerChan {
+		if p.ID == r.host.ID() {
+			continue
+		}
+		if len(p.Addrs) == 0 || r.found(ctx, p) != nil {
+			r.stale.Add(1)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// StaticInterval is how often a connected static peer is checked
+const StaticInterval = 10 * time.Second
+
+// Backoff between dials of a static peer that cannot be reached. It doubles
+// after every failed dial and resets once the peer is connected.
+const (
+	StaticMinBackoff = time.Second
+	StaticMaxBackoff = 5 * time.Minute
+)
+
+// staticTag protects the connections to static peers from the connection manager
+const staticTag = "static-peer"
+
+// Static keeps connections to a fixed list of nodes, for small clusters
+// where neither mDNS nor the DHT can find them. Every peer is dialed at start
+// and dialed again with backoff whenever its connections drop.
+type Static struct {
+	*peerSource
+	peers    []peer.AddrInfo
+	wake     map[peer.ID]chan struct{}
+	notifiee *network.NotifyBundle
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewStatic creates a static discovery backend from multiaddrs that end in /p2p/<peer ID>
+func NewStatic(h host.Host, addrs []string, logger types.Logger) (*Static, error) {
+	var maddrs []multiaddr.Multiaddr
+	for _, addr := range addrs {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid static peer %s: %v", types.ErrInvalidInput, addr, err)
+		}
+		maddrs = append(maddrs, maddr)
+	}
+	// Addresses of the same peer are grouped
+	peers, err := peer.AddrInfosFromP2pAddrs(maddrs...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: static peers need a /p2p/ peer ID: %v", types.ErrInvalidInput, err)
+	}
+
+	wake := make(map[peer.ID]chan struct{}, len(peers))
+	for _, info := range peers {
+		wake[info.ID] = make(chan struct{}, 1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Static{
+		peerSource: newPeerSource(BackendStatic, h, logger),
+		peers:      peers,
+		wake:       wake,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	s.notifiee = &network.NotifyBundle{DisconnectedF: s.disconnected}
+	return s, nil
+}
+
+// Name implements Discoverer
+func (s *Static) Name() string {
+	return BackendStatic
+}
+
+// Start implements Discoverer
+func (s *Static) Start() error {
+	s.host.Network().Notify(s.notifiee)
+	for _, info := range s.peers {
+		s.host.ConnManager().Protect(info.ID, staticTag)
+		crash.Loop(s.ctx.Done(), s.logger, "discovery.static.keep", func() { s.keep(info) })
+	}
+	crash.Loop(s.ctx.Done(), s.logger, "discovery.static.expire", func() { s.expireLoop(s.ctx) })
+	return nil
+}
+
+// Stop implements Discoverer
+func (s *Static) Stop() {
+	s.cancel()
+	s.host.Network().StopNotify(s.notifiee)
+	for _, info := range s.peers {
+		s.host.ConnManager().Unprotect(info.ID, staticTag)
+	}
+}
+
+// Nodes implements Discoverer
+func (s *Static) Nodes() []*DiscoveredNode {
+	return s.nodes.list()
+}
+
+// Subscribe implements Discoverer
+func (s *Static) Subscribe() (<-chan Event, func()) {
+	return s.nodes.subscribe()
+}
+
+// keep connects to a static peer until the backend stops, dialing it again
+// right away when its last connection closes and with backoff while it
+// cannot be reached
+func (s *Static) keep(info peer.AddrInfo) {
+	backoff := StaticMinBackoff
+	for {
+		// Our backoff replaces the one libp2p keeps after a failed dial
+		if sw, ok := s.host.Network().(*swarm.Swarm); ok {
+			sw.Backoff().Clear(info.ID)
+		}
+
+		wait := StaticInterval
+		if err := s.found(s.ctx, info); err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			// Only the first failure is logged, until the peer is back
+			if backoff == StaticMinBackoff {
+				s.logger.Warn("static peer unreachable, retrying with backoff", "peer", info.ID, "error", err)
+			}
+			wait = backoff
+			backoff = min(2*backoff, StaticMaxBackoff)
+		} else {
+			if backoff > StaticMinBackoff {
+				s.logger.Info("reconnected to static peer", "peer", info.ID)
+			}
+			backoff = StaticMinBackoff
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.wake[info.ID]:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Rejoin dials the static peers that are not connected right away
+func (s *Static) Rejoin(_ context.Context) {
+	for _, info := range s.peers {
+		if s.host.Network().Connectedness(info.ID) == network.Connected {
+			continue
+		}
+		select {
+		case s.wake[info.ID] <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// disconnected wakes the loop of a static peer when its last connection closes
+func (s *Static) disconnected(n network.Network, conn network.Conn) {
+	ch, ok := s.wake[conn.RemotePeer()]
+	if !ok || s.ctx.Err() != nil || n.Connectedness(conn.RemotePeer()) == network.Connected {
+		return
+	}
+	s.logger.Info("static peer disconnected, reconnecting", "peer", conn.RemotePeer())
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,79 @@
+package discovery_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/discovery"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+func newHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	return h
+}
+
+func waitConnected(t *testing.T, from, to host.Host) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for from.Network().Connectedness(to.ID()) != network.Connected {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not connect to %s", from.ID(), to.ID())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestStaticReconnects(t *testing.T) {
+	h, remote := newHost(t), newHost(t)
+	addr := remote.Addrs()[0].String() + "/p2p/" + remote.ID().String()
+
+	static, err := discovery.NewStatic(h, []string{addr}, logging.Nop())
+	if err != nil {
+		t.Fatalf("NewStatic() error = %v", err)
+	}
+	if err := static.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(static.Stop)
+
+	waitConnected(t, h, remote)
+	if nodes := static.Nodes(); len(nodes) != 1 || nodes[0].PeerID != remote.ID() {
+		t.Fatalf("Nodes() = %v, want %s", nodes, remote.ID())
+	}
+	if !h.ConnManager().IsProtected(remote.ID(), "") {
+		t.Error("connection to static peer is not protected")
+	}
+
+	reconnected := make(chan struct{}, 1)
+	remote.Network().Notify(&network.NotifyBundle{ConnectedF: func(network.Network, network.Conn) {
+		select {
+		case reconnected <- struct{}{}:
+		default:
+		}
+	}})
+
+	// Dropping the connection must not wait for the next check
+	if err := remote.Network().ClosePeer(h.ID()); err != nil {
+		t.Fatalf("ClosePeer() error = %v", err)
+	}
+	select {
+	case <-reconnected:
+	case <-time.After(discovery.StaticInterval / 2):
+		t.Fatal("static peer was not dialed again after its connection dropped")
+	}
+}
+
+func TestNewStaticRejectsAddressWithoutPeerID(t *testing.T) {
+	if _, err := discovery.NewStatic(nil, []string{"/ip4/127.0.0.1/tcp/9000"}, logging.Nop()); err == nil {
+		t.Fatal("NewStatic() error = nil, want an error for an address without /p2p/")
+	}
+}
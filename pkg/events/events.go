@@ -0,0 +1,252 @@
+// Package events keeps the lifecycle events of a node's applications in a
+// bounded log on disk, so they can be queried after the fact by controllers
+// that were not connected when the events happened.
+package events
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// FileName is the name of the event log in the daemon data directory
+const FileName = "events.jsonl"
+
+// schema versions the events of the log, each line a document
+var schema = storage.Schema{
+	Kind: "event",
+	Migrations: []storage.Migration{
+		nil, // 1: the schema version was added
+	},
+}
+
+// DefaultSize is how many events are kept when not configured
+const DefaultSize = 10000
+
+// Types are the types of events logged
+var Types = []types.AppEvent{
+	types.AppEventDeployed,
+	types.AppEventStarted,
+	types.AppEventExited,
+	types.AppEventStopped,
+	types.AppEventUnhealthy,
+	types.AppEventAlert,
+	types.AppEventRolledBack,
+	types.AppEventPaused,
+	types.AppEventResumed,
+}
+
+// Event is a lifecycle event of an application
+type Event struct {
+	// Time is when the event happened
+	Time time.Time `json:"time"`
+
+	// Type names the event
+	Type types.AppEvent `json:"type"`
+
+	// AppID is the application the event is about
+	AppID string `json:"app_id"`
+
+	// Status is the process state at the time
+	Status types.AppStatusType `json:"status,omitempty"`
+
+	// Revision is the deployment of the application the event is about, if known
+	Revision int64 `json:"revision,omitempty"`
+
+	// Message is the detail of the event
+	Message string `json:"message,omitempty"`
+
+	// Initiator is who or what caused the event, if known
+	Initiator *types.Initiator `json:"initiator,omitempty"`
+
+	// CoreDump is the core dump a crash left in the working directory of
+	// the application, for exited events
+	CoreDump string `json:"core_dump,omitempty"`
+}
+
+// Filter selects events. Zero fields match every event.
+type Filter struct {
+	// Since skips events that happened before it
+	Since time.Time
+
+	// AppID matches the events of one application
+	AppID string
+
+	// Types matches the events of any of these types
+	Types []types.AppEvent
+}
+
+// Match reports whether e passes the filter
+func (f *Filter) Match(e *Event) bool {
+	if e.Time.Before(f.Since) {
+		return false
+	}
+	if f.AppID != "" && e.AppID != f.AppID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if e.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Store keeps the most recent events in memory and appends each to a JSON
+// lines file. The file is compacted to the kept events when it grows to
+// twice their number, so it stays bounded.
+type Store struct {
+	mu     sync.Mutex
+	size   int
+	path   string
+	events []*Event
+
+	// lines is how many events the file holds
+	lines int
+}
+
+// Open opens the event log at path keeping size events, loading the events
+// already logged. An empty path keeps events in memory only.
+func Open(path string, size int) (*Store, error) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	s := &Store{size: size, path: path}
+	if path == "" {
+		return s, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, types.WrapError(err, "failed to open event log")
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	damaged := false
+	for scanner.Scan() {
+		var e Event
+		// A partly written last line is expected after a crash
+		if err := schema.Unmarshal(scanner.Bytes(), &e); err != nil {
+			if errors.Is(err, types.ErrUnsupportedSchema) {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			damaged = true
+			continue
+		}
+		s.push(&e)
+		s.lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, types.WrapError(err, "failed to read event log")
+	}
+
+	// Rewrite the log so the next event does not continue a partial line
+	if damaged {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// push adds e, dropping the oldest event when the store is full. s.mu must be held.
+func (s *Store) push(e *Event) {
+	if len(s.events) == s.size {
+		copy(s.events, s.events[1:])
+		s.events = s.events[:s.size-1]
+	}
+	s.events = append(s.events, e)
+}
+
+// Record adds an event to the log. The event must not be modified afterwards.
+func (s *Store) Record(e *Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.push(e)
+	if s.path == "" {
+		return nil
+	}
+	if s.lines >= 2*s.size {
+		return s.compact()
+	}
+	return s.append(e)
+}
+
+// append writes e to the end of the file. s.mu must be held.
+func (s *Store) append(e *Event) error {
+	data, err := schema.Marshal(e)
+	if err != nil {
+		return types.WrapError(err, "failed to marshal event")
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return types.WrapError(err, "failed to open event log")
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write event: %w: %w", types.ErrStorageWrite, err)
+	}
+	s.lines++
+	return nil
+}
+
+// compact rewrites the file with the kept events. s.mu must be held.
+func (s *Store) compact() error {
+	var b strings.Builder
+	for _, e := range s.events {
+		data, err := schema.Marshal(e)
+		if err != nil {
+			return types.WrapError(err, "failed to marshal event")
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write event log: %w: %w", types.ErrStorageWrite, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace event log: %w: %w", types.ErrStorageWrite, err)
+	}
+	s.lines = len(s.events)
+	return nil
+}
+
+// Query returns the events passing filter, oldest first
+func (s *Store) Query(filter Filter) []*Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []*Event
+	for _, e := range s.events {
+		if filter.Match(e) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
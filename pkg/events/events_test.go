@@ -0,0 +1,121 @@
+package events_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/events"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// record adds an event of appID that happened at t
+func record(t *testing.T, s *events.Store, appID string, typ types.AppEvent, at time.Time, message string) {
+	t.Helper()
+	if err := s.Record(&events.Event{Time: at, Type: typ, AppID: appID, Message: message}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+}
+
+func messages(evts []*events.Event) []string {
+	msgs := make([]string, 0, len(evts))
+	for _, e := range evts {
+		msgs = append(msgs, e.Message)
+	}
+	return msgs
+}
+
+func TestQueryFilters(t *testing.T) {
+	s, err := events.Open("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	record(t, s, "app-1.0.0", types.AppEventStarted, start, "old")
+	record(t, s, "other-1.0.0", types.AppEventExited, start.Add(50*time.Minute), "other exited")
+	record(t, s, "app-1.0.0", types.AppEventExited, start.Add(55*time.Minute), "exited")
+	record(t, s, "app-1.0.0", types.AppEventStarted, start.Add(56*time.Minute), "restarted")
+
+	since := start.Add(30 * time.Minute)
+	tests := []struct {
+		name   string
+		filter events.Filter
+		want   []string
+	}{
+		{"all", events.Filter{}, []string{"old", "other exited", "exited", "restarted"}},
+		{"since", events.Filter{Since: since}, []string{"other exited", "exited", "restarted"}},
+		{"app", events.Filter{Since: since, AppID: "app-1.0.0"}, []string{"exited", "restarted"}},
+		{"type", events.Filter{Types: []types.AppEvent{types.AppEventExited}}, []string{"other exited", "exited"}},
+		{"app and type", events.Filter{AppID: "app-1.0.0", Types: []types.AppEvent{types.AppEventStarted, types.AppEventStopped}}, []string{"old", "restarted"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messages(s.Query(tt.filter)); !slices.Equal(got, tt.want) {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPersistenceIsBounded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), events.FileName)
+	s, err := events.Open(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough events to compact the file at least once
+	start := time.Now()
+	for i, msg := range []string{"a", "b", "c", "d", "e", "f"} {
+		record(t, s, "app-1.0.0", types.AppEventStarted, start.Add(time.Duration(i)*time.Second), msg)
+	}
+	if got, want := messages(s.Query(events.Filter{})), []string{"e", "f"}; !slices.Equal(got, want) {
+		t.Errorf("Query() = %v, want %v", got, want)
+	}
+
+	reopened, err := events.Open(path, 2)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if got, want := messages(reopened.Query(events.Filter{})), []string{"e", "f"}; !slices.Equal(got, want) {
+		t.Errorf("Query() after reopening = %v, want %v", got, want)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines > 4 {
+		t.Errorf("event log holds %d lines, want at most 4", lines)
+	}
+}
+
+func TestOpenSkipsPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), events.FileName)
+	content := `{"time":"2026-01-01T00:00:00Z","type":"started","app_id":"app-1.0.0","message":"a"}` + "\n" + `{"time":"2026-01-01T00:00:01Z","ty`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := events.Open(path, 10)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if got, want := messages(s.Query(events.Filter{})), []string{"a"}; !slices.Equal(got, want) {
+		t.Errorf("Query() = %v, want %v", got, want)
+	}
+
+	// The next event is not appended to the partial line
+	record(t, s, "app-1.0.0", types.AppEventExited, time.Now(), "b")
+	reopened, err := events.Open(path, 10)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if got, want := messages(reopened.Query(events.Filter{})), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("Query() after reopening = %v, want %v", got, want)
+	}
+}
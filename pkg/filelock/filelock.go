@@ -0,0 +1,122 @@
+// Package filelock provides advisory locks on files, which serialize
+// processes changing shared state such as the controller package cache.
+// Locks are released by the operating system when the holding process exits,
+// so a crashed process never leaves a stale lock behind.
+package filelock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// pollInterval is how often Lock retries a lock held by another process
+const pollInterval = 100 * time.Millisecond
+
+// Holder describes the process holding a lock. It is written to the lock
+// file, so that processes failing to take the lock can tell who holds it.
+type Holder struct {
+	PID     int       `json:"pid"`
+	Command string    `json:"command"`
+	Since   time.Time `json:"since"`
+}
+
+// String describes the holder for error messages
+func (h *Holder) String() string {
+	return fmt.Sprintf("pid %d (%s) since %s", h.PID, h.Command, h.Since.Format(time.RFC3339))
+}
+
+// Lock is an exclusive lock on a file held by this process
+type Lock struct {
+	file *os.File
+}
+
+// TryAcquire takes an exclusive lock on the file at path, creating it and its
+// directory if needed. If another process holds the lock, it fails at once
+// with an error wrapping types.ErrLocked that names the holder.
+func TryAcquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, types.WrapError(err, "failed to create lock directory")
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to open lock file")
+	}
+
+	locked, err := tryLock(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, types.WrapError(err, "failed to lock file")
+	}
+	if !locked {
+		_ = file.Close()
+		if holder := readHolder(path); holder != nil {
+			return nil, fmt.Errorf("%w: %s is held by %s", types.ErrLocked, path, holder)
+		}
+		return nil, fmt.Errorf("%w: %s is held by another process", types.ErrLocked, path)
+	}
+
+	holder := Holder{PID: os.Getpid(), Command: command(), Since: time.Now().UTC()}
+	if data, err := json.Marshal(holder); err == nil {
+		_ = file.Truncate(0)
+		_, _ = file.WriteAt(append(data, '\n'), 0)
+	}
+	return &Lock{file: file}, nil
+}
+
+// Acquire takes an exclusive lock on the file at path like TryAcquire, but waits
+// for another process to release it until ctx is done
+func Acquire(ctx context.Context, path string) (*Lock, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		lock, err := TryAcquire(path)
+		if !errors.Is(err, types.ErrLocked) {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-ticker.C:
+		}
+	}
+}
+
+// Unlock releases the lock; releasing it again does nothing. The lock file is
+// left in place, since removing it could let two processes lock different
+// files under the same path.
+func (l *Lock) Unlock() error {
+	if l.file == nil {
+		return nil
+	}
+	_ = l.file.Truncate(0)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// readHolder returns the holder recorded in a lock file, or nil if unknown
+func readHolder(path string) *Holder {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var holder Holder
+	if err := json.Unmarshal(data, &holder); err != nil || holder.PID == 0 {
+		return nil
+	}
+	return &holder
+}
+
+// command describes the command line of this process
+func command() string {
+	args := append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...)
+	return strings.Join(args, " ")
+}
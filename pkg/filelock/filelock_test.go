@@ -0,0 +1,67 @@
+package filelock_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/filelock"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestTryAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.lock")
+
+	lock, err := filelock.TryAcquire(path)
+	if err != nil {
+		t.Fatalf("TryAcquire() error = %v", err)
+	}
+
+	// Locks conflict between open files, even within one process
+	_, err = filelock.TryAcquire(path)
+	if !errors.Is(err, types.ErrLocked) {
+		t.Fatalf("second TryAcquire() error = %v, want ErrLocked", err)
+	}
+	if want := fmt.Sprintf("pid %d", os.Getpid()); !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not name the holder (%s)", err, want)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	lock, err = filelock.TryAcquire(path)
+	if err != nil {
+		t.Fatalf("TryAcquire() after Unlock() error = %v", err)
+	}
+	_ = lock.Unlock()
+}
+
+func TestAcquireWaits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+
+	lock, err := filelock.TryAcquire(path)
+	if err != nil {
+		t.Fatalf("TryAcquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := filelock.Acquire(ctx, path); !errors.Is(err, types.ErrLocked) {
+		t.Fatalf("Acquire() while held error = %v, want ErrLocked", err)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = lock.Unlock()
+	}()
+	waited, err := filelock.Acquire(t.Context(), path)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	_ = waited.Unlock()
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sync"
 	"syscall"
 	"time"
 
@@ -70,6 +71,7 @@ type Checker struct {
 	pid    int
 
 	// State
+	mu               sync.Mutex
 	lastResult       *Result
 	consecutiveFails int
 }
@@ -90,28 +92,14 @@ func New(config *Config, pid int, logger types.Logger) *Checker {
 
 // Check performs a health check
 func (c *Checker) Check(ctx context.Context) (*Result, error) {
-	var err error
-	var healthy bool
-	var message string
-
-	checkCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
-	defer cancel()
-
-	switch c.config.Type {
-	case CheckTypeProcess:
-		healthy, message, err = c.checkProcess()
-	case CheckTypeHTTP:
-		healthy, message, err = c.checkHTTP(checkCtx)
-	case CheckTypeTCP:
-		healthy, message, err = c.checkTCP(checkCtx)
-	default:
-		return nil, fmt.Errorf("unsupported health check type: %s", c.config.Type)
-	}
-
+	healthy, message, err := c.probe(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	// Update consecutive failure count
 	if !healthy {
 		c.consecutiveFails++
@@ -130,6 +118,33 @@ func (c *Checker) Check(ctx context.Context) (*Result, error) {
 	return result, nil
 }
 
+// Probe performs a health check without counting it towards the retries, as
+// when waiting for a freshly started app to come up
+func (c *Checker) Probe(ctx context.Context) (*Result, error) {
+	healthy, message, err := c.probe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Healthy: healthy, Message: message, Timestamp: time.Now()}, nil
+}
+
+// probe runs the configured check
+func (c *Checker) probe(ctx context.Context) (bool, string, error) {
+	checkCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	switch c.config.Type {
+	case CheckTypeProcess:
+		return c.checkProcess()
+	case CheckTypeHTTP:
+		return c.checkHTTP(checkCtx)
+	case CheckTypeTCP:
+		return c.checkTCP(checkCtx)
+	default:
+		return false, "", fmt.Errorf("unsupported health check type: %s", c.config.Type)
+	}
+}
+
 // checkProcess checks if the process is running
 func (c *Checker) checkProcess() (bool, string, error) {
 	// Try to send signal 0 to check if process exists
@@ -202,12 +217,15 @@ func (c *Checker) checkTCP(ctx context.Context) (bool, string, error) {
 
 // LastResult returns the last health check result
 func (c *Checker) LastResult() *Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.lastResult
 }
 
 // IsHealthy returns true if the application is healthy
 func (c *Checker) IsHealthy() bool {
-	return c.lastResult != nil && c.lastResult.Healthy
+	result := c.LastResult()
+	return result != nil && result.Healthy
 }
 
 // StartMonitoring starts continuous health monitoring
@@ -0,0 +1,277 @@
+package history
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DirName is the directory in the daemon data directory holding persisted history
+const DirName = "history"
+
+// schema versions the entries of history files, each line a document
+var schema = storage.Schema{
+	Kind: "history entry",
+	Migrations: []storage.Migration{
+		nil, // 1: the schema version was added
+	},
+}
+
+// DefaultInterval is how often app status is sampled when not configured
+const DefaultInterval = 10 * time.Second
+
+// DefaultSize is how many entries are kept per app when not configured,
+// three hours of samples at the default interval
+const DefaultSize = 1080
+
+// Kinds of history entries
+const (
+	// KindSample is a periodic status sample
+	KindSample = "sample"
+
+	// KindEvent is a lifecycle event
+	KindEvent = "event"
+)
+
+// Entry is a status sample or lifecycle event of an app
+type Entry struct {
+	// Time is when the sample was taken or the event happened
+	Time time.Time `json:"time"`
+
+	// AppID is the application the entry is about
+	AppID string `json:"app_id"`
+
+	// Kind is KindSample or KindEvent
+	Kind string `json:"kind"`
+
+	// Event names the lifecycle event of a KindEvent entry
+	Event types.AppEvent `json:"event,omitempty"`
+
+	// Status is the process state at the time
+	Status types.AppStatusType `json:"status,omitempty"`
+
+	// Healthy is whether the app passed its checks at the time of a sample
+	Healthy bool `json:"healthy"`
+
+	// Message is the status message of a sample or the detail of an event
+	Message string `json:"message,omitempty"`
+
+	// Metrics are the gauge values the app had published at the time of a sample
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+
+	// Usage is the CPU and memory the app used at the time of a sample, if measured
+	Usage *types.ResourceUsage `json:"usage,omitempty"`
+}
+
+// ring holds the most recent entries of one app
+type ring struct {
+	entries []*Entry
+	next    int
+
+	// lines is how many entries the app's file holds
+	lines int
+}
+
+// push adds entry, overwriting the oldest one when the ring is full
+func (r *ring) push(entry *Entry) {
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, entry)
+		return
+	}
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+}
+
+// ordered returns the entries from oldest to newest
+func (r *ring) ordered() []*Entry {
+	return append(append([]*Entry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// Store keeps the recent status samples and events of each app in a bounded
+// ring buffer. With a directory, entries are also appended to a JSON lines
+// file per app so history survives daemon restarts; a file is compacted to
+// the ring contents when it grows to twice the ring size.
+type Store struct {
+	mu    sync.Mutex
+	size  int
+	dir   string
+	rings map[string]*ring
+}
+
+// New creates a store keeping size entries per app, persisted in dir unless it is empty
+func New(size int, dir string) (*Store, error) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	s := &Store{
+		size:  size,
+		dir:   dir,
+		rings: make(map[string]*ring),
+	}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, types.WrapError(err, "failed to create history directory")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, types.WrapError(err, "failed to list history files")
+	}
+	for _, path := range files {
+		if err := s.load(path); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// load reads a persisted history file into its app's ring
+func (s *Store) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return types.WrapError(err, "failed to open history file")
+	}
+	defer func() { _ = file.Close() }()
+
+	var r *ring
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		// A partly written last line is expected after a crash
+		if err := schema.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			if errors.Is(err, types.ErrUnsupportedSchema) {
+				return fmt.Errorf("%s: %w", filepath.Base(path), err)
+			}
+			continue
+		}
+		if r == nil {
+			r = s.ring(entry.AppID)
+		}
+		r.push(&entry)
+		r.lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return types.WrapError(err, "failed to read history file")
+	}
+	return nil
+}
+
+// ring returns the ring of appID, creating it if needed. s.mu must be held.
+func (s *Store) ring(appID string) *ring {
+	r := s.rings[appID]
+	if r == nil {
+		r = &ring{entries: make([]*Entry, 0, s.size)}
+		s.rings[appID] = r
+	}
+	return r
+}
+
+// Record adds an entry to its app's history. The entry must not be modified afterwards.
+func (s *Store) Record(entry *Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.ring(entry.AppID)
+	r.push(entry)
+
+	if s.dir == "" {
+		return nil
+	}
+	if r.lines >= 2*s.size {
+		return s.compact(entry.AppID, r)
+	}
+	return s.append(entry, r)
+}
+
+// append writes entry to the end of its app's file. s.mu must be held.
+func (s *Store) append(entry *Entry, r *ring) error {
+	data, err := schema.Marshal(entry)
+	if err != nil {
+		return types.WrapError(err, "failed to marshal history entry")
+	}
+
+	file, err := os.OpenFile(s.path(entry.AppID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return types.WrapError(err, "failed to open history file")
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history entry: %w: %w", types.ErrStorageWrite, err)
+	}
+	r.lines++
+	return nil
+}
+
+// compact rewrites the file of appID with the entries in its ring. s.mu must be held.
+func (s *Store) compact(appID string, r *ring) error {
+	var b strings.Builder
+	entries := r.ordered()
+	for _, entry := range entries {
+		data, err := schema.Marshal(entry)
+		if err != nil {
+			return types.WrapError(err, "failed to marshal history entry")
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+
+	path := s.path(appID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write history file: %w: %w", types.ErrStorageWrite, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace history file: %w: %w", types.ErrStorageWrite, err)
+	}
+	r.lines = len(entries)
+	return nil
+}
+
+// path returns the file persisting the history of appID
+func (s *Store) path(appID string) string {
+	return filepath.Join(s.dir, url.PathEscape(appID)+".jsonl")
+}
+
+// Since returns the entries recorded at or after since, oldest first.
+// An empty appID returns the entries of all apps.
+func (s *Store) Since(appID string, since time.Time) []*Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []*Entry
+	for id, r := range s.rings {
+		if appID != "" && id != appID {
+			continue
+		}
+		for _, entry := range r.ordered() {
+			if !entry.Time.Before(since) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries
+}
@@ -0,0 +1,100 @@
+package history_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/history"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// record adds a sample of appID taken at t
+func record(t *testing.T, s *history.Store, appID string, at time.Time, message string) {
+	t.Helper()
+	if err := s.Record(&history.Entry{Time: at, AppID: appID, Kind: history.KindSample, Message: message}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+}
+
+func messages(entries []*history.Entry) []string {
+	msgs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		msgs = append(msgs, e.Message)
+	}
+	return msgs
+}
+
+func TestRingKeepsMostRecent(t *testing.T) {
+	s, err := history.New(3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i, msg := range []string{"a", "b", "c", "d", "e"} {
+		record(t, s, "app-1.0.0", start.Add(time.Duration(i)*time.Second), msg)
+	}
+
+	got := messages(s.Since("app-1.0.0", time.Time{}))
+	if want := []string{"c", "d", "e"}; !slices.Equal(got, want) {
+		t.Errorf("Since() = %v, want %v", got, want)
+	}
+}
+
+func TestSinceFiltersByTimeAndApp(t *testing.T) {
+	s, err := history.New(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	record(t, s, "app-1.0.0", start, "old")
+	record(t, s, "other-1.0.0", start.Add(50*time.Minute), "other")
+	record(t, s, "app-1.0.0", start.Add(55*time.Minute), "recent")
+
+	since := start.Add(30 * time.Minute)
+	if got, want := messages(s.Since("app-1.0.0", since)), []string{"recent"}; !slices.Equal(got, want) {
+		t.Errorf("Since(app) = %v, want %v", got, want)
+	}
+	if got, want := messages(s.Since("", since)), []string{"other", "recent"}; !slices.Equal(got, want) {
+		t.Errorf("Since(all) = %v, want %v", got, want)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	dir := t.TempDir()
+	s, err := history.New(2, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough entries to compact the file at least once
+	start := time.Now()
+	for i, msg := range []string{"a", "b", "c", "d", "e", "f"} {
+		record(t, s, "app-1.0.0", start.Add(time.Duration(i)*time.Second), msg)
+	}
+
+	reopened, err := history.New(2, dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	got := messages(reopened.Since("app-1.0.0", time.Time{}))
+	if want := []string{"e", "f"}; !slices.Equal(got, want) {
+		t.Errorf("Since() after reopening = %v, want %v", got, want)
+	}
+}
+
+func TestNewerSchemaRefused(t *testing.T) {
+	dir := t.TempDir()
+	line := `{"schema_version":99,"time":"2026-01-01T00:00:00Z","app_id":"app-1.0.0","kind":"sample"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "app-1.0.0.jsonl"), []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := history.New(2, dir); !errors.Is(err, types.ErrUnsupportedSchema) {
+		t.Errorf("New() error = %v, want ErrUnsupportedSchema", err)
+	}
+}
@@ -0,0 +1,243 @@
+// Package integrity records the checksums of the files of a deployed
+// application and checks the files against them later, to detect tampering
+// or disk corruption.
+package integrity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// StorageDir is the storage directory holding one record per application
+const StorageDir = "integrity"
+
+// schema versions the stored records
+var schema = storage.Schema{
+	Kind: "integrity record",
+	Migrations: []storage.Migration{
+		nil, // 1: the schema version was added
+	},
+}
+
+// File is the checksum of a file of an application
+type File struct {
+	// Path is relative to the application directory, with forward slashes
+	Path string `json:"path"`
+
+	// SHA256 is the hex-encoded SHA-256 of the content
+	SHA256 string `json:"sha256"`
+
+	// Size is the size in bytes
+	Size int64 `json:"size"`
+}
+
+// Record holds the checksums of the files of an application as deployed
+type Record struct {
+	AppID      string    `json:"app_id"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Files      []File    `json:"files"`
+}
+
+// DriftKind tells how a file differs from its record
+type DriftKind string
+
+const (
+	// DriftModified marks a file whose content changed
+	DriftModified DriftKind = "modified"
+
+	// DriftMissing marks a file that was removed or is no longer a regular file
+	DriftMissing DriftKind = "missing"
+)
+
+// Drift is a file that differs from its record
+type Drift struct {
+	Path string    `json:"path"`
+	Kind DriftKind `json:"kind"`
+
+	// Expected and Actual are the recorded and current SHA-256 of a modified file
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// Report is the result of checking an application against its record
+type Report struct {
+	AppID      string    `json:"app_id"`
+	RecordedAt time.Time `json:"recorded_at"`
+	CheckedAt  time.Time `json:"checked_at"`
+
+	// Checked is the number of recorded files
+	Checked int `json:"checked"`
+
+	// Drift lists the files that differ, sorted by path
+	Drift []Drift `json:"drift,omitempty"`
+}
+
+// OK reports whether every recorded file is unchanged
+func (r *Report) OK() bool {
+	return len(r.Drift) == 0
+}
+
+// Scan returns the checksums of the regular files under dir, sorted by
+// path. Top-level directories named in exclude, which hold what the
+// application writes at runtime, are skipped.
+func Scan(dir string, exclude ...string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			for _, name := range exclude {
+				if rel == name {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		sum, size, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: rel, SHA256: sum, Size: size})
+		return nil
+	})
+	if err != nil {
+		return nil, types.WrapError(err, "failed to scan application files")
+	}
+	return files, nil
+}
+
+// Check compares the files under dir with rec. It stops between files once
+// ctx ends.
+func Check(ctx context.Context, dir string, rec *Record) (*Report, error) {
+	report := &Report{
+		AppID:      rec.AppID,
+		RecordedAt: rec.RecordedAt,
+		CheckedAt:  time.Now().UTC(),
+		Checked:    len(rec.Files),
+	}
+
+	for _, file := range rec.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, filepath.FromSlash(file.Path))
+		info, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+			report.Drift = append(report.Drift, Drift{Path: file.Path, Kind: DriftMissing})
+			continue
+		}
+		if err != nil {
+			return nil, types.WrapError(err, "failed to check "+file.Path)
+		}
+
+		sum, _, err := hashFile(path)
+		if err != nil {
+			return nil, types.WrapError(err, "failed to check "+file.Path)
+		}
+		if sum != file.SHA256 {
+			report.Drift = append(report.Drift, Drift{
+				Path:     file.Path,
+				Kind:     DriftModified,
+				Expected: file.SHA256,
+				Actual:   sum,
+			})
+		}
+	}
+
+	sort.Slice(report.Drift, func(i, j int) bool {
+		return report.Drift[i].Path < report.Drift[j].Path
+	})
+	return report, nil
+}
+
+// hashFile returns the hex-encoded SHA-256 and the size of a file
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// Store persists the records of applications
+type Store struct {
+	storage types.Storage
+}
+
+// NewStore creates a record store on top of storage
+func NewStore(storage types.Storage) *Store {
+	return &Store{storage: storage}
+}
+
+// key returns the storage key of the record of appID
+func key(appID string) string {
+	return StorageDir + "/" + appID + ".json"
+}
+
+// Save stores rec, replacing the record of the same application
+func (s *Store) Save(ctx context.Context, rec *Record) error {
+	data, err := schema.Marshal(rec)
+	if err != nil {
+		return types.WrapError(err, "failed to marshal integrity record")
+	}
+	if err := s.storage.Save(ctx, key(rec.AppID), data); err != nil {
+		return types.WrapError(err, "failed to save integrity record")
+	}
+	return nil
+}
+
+// Load returns the record of appID, or an error wrapping types.ErrNotFound
+// if none was recorded
+func (s *Store) Load(ctx context.Context, appID string) (*Record, error) {
+	data, err := s.storage.Load(ctx, key(appID))
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return nil, fmt.Errorf("%w: no file checksums recorded for %s", types.ErrNotFound, appID)
+		}
+		return nil, types.WrapError(err, "failed to load integrity record")
+	}
+
+	var rec Record
+	if err := schema.Unmarshal(data, &rec); err != nil {
+		return nil, types.WrapError(err, "failed to parse integrity record")
+	}
+	return &rec, nil
+}
+
+// Delete removes the record of appID, if any
+func (s *Store) Delete(ctx context.Context, appID string) error {
+	if err := s.storage.Delete(ctx, key(appID)); err != nil && !errors.Is(err, types.ErrNotFound) {
+		return types.WrapError(err, "failed to delete integrity record")
+	}
+	return nil
+}
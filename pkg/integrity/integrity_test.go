@@ -0,0 +1,165 @@
+package integrity_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/integrity"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// writeFiles creates files under dir, keyed by slash-separated relative path
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanAndCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"manifest.yaml":   "name: web",
+		"bin/web":         "binary",
+		"static/app.js":   "js",
+		"logs/stdout.log": "runtime output",
+	})
+
+	files, err := integrity.Scan(dir, "logs")
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	want := []string{"bin/web", "manifest.yaml", "static/app.js"}
+	if len(paths) != len(want) {
+		t.Fatalf("Scan() paths = %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("Scan() paths = %v, want %v", paths, want)
+		}
+	}
+
+	rec := &integrity.Record{AppID: "web-1.0.0", RecordedAt: time.Now(), Files: files}
+	report, err := integrity.Check(context.Background(), dir, rec)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !report.OK() || report.Checked != 3 {
+		t.Fatalf("Check() of unchanged files = %+v, want 3 checked without drift", report)
+	}
+
+	// Files the app writes and runtime output are not drift
+	writeFiles(t, dir, map[string]string{
+		"data/cache":      "new",
+		"logs/stdout.log": "more output",
+		"bin/web":         "patched",
+	})
+	if err := os.Remove(filepath.Join(dir, "static", "app.js")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = integrity.Check(context.Background(), dir, rec)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(report.Drift) != 2 {
+		t.Fatalf("Check() drift = %+v, want 2 entries", report.Drift)
+	}
+	if d := report.Drift[0]; d.Path != "bin/web" || d.Kind != integrity.DriftModified || d.Expected == d.Actual {
+		t.Errorf("drift[0] = %+v, want bin/web modified", d)
+	}
+	if d := report.Drift[1]; d.Path != "static/app.js" || d.Kind != integrity.DriftMissing {
+		t.Errorf("drift[1] = %+v, want static/app.js missing", d)
+	}
+}
+
+func TestCheckReplacedByDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config": "x"})
+	files, err := integrity.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "config")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{"config/inner": "x"})
+
+	report, err := integrity.Check(context.Background(), dir, &integrity.Record{Files: files})
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(report.Drift) != 1 || report.Drift[0].Kind != integrity.DriftMissing {
+		t.Errorf("Check() drift = %+v, want config missing", report.Drift)
+	}
+}
+
+func TestCheckCancelled(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config": "x"})
+	files, err := integrity.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := integrity.Check(ctx, dir, &integrity.Record{Files: files}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Check() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	st, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := integrity.NewStore(st)
+
+	if _, err := store.Load(ctx, "web-1.0.0"); !errors.Is(err, types.ErrNotFound) {
+		t.Fatalf("Load() of unknown app error = %v, want ErrNotFound", err)
+	}
+
+	rec := &integrity.Record{
+		AppID:      "web-1.0.0",
+		RecordedAt: time.Now().UTC().Truncate(time.Second),
+		Files:      []integrity.File{{Path: "bin/web", SHA256: "abc", Size: 3}},
+	}
+	if err := store.Save(ctx, rec); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := store.Load(ctx, "web-1.0.0")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !got.RecordedAt.Equal(rec.RecordedAt) || len(got.Files) != 1 || got.Files[0] != rec.Files[0] {
+		t.Errorf("Load() = %+v, want %+v", got, rec)
+	}
+
+	if err := store.Delete(ctx, "web-1.0.0"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete(ctx, "web-1.0.0"); err != nil {
+		t.Errorf("Delete() of deleted record error = %v", err)
+	}
+	if _, err := store.Load(ctx, "web-1.0.0"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Load() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
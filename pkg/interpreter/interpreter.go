@@ -0,0 +1,47 @@
+// Package interpreter finds the interpreters script apps are run with, such
+// as /bin/sh or python3, and names the node labels advertising them.
+package interpreter
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DefaultNames are the interpreters a daemon looks for when none are configured
+var DefaultNames = []string{"sh", "bash", "python3", "node", "perl", "ruby"}
+
+// LabelPrefix prefixes the node labels advertising interpreters, e.g.
+// interpreter.python3=true
+const LabelPrefix = "interpreter."
+
+// Label returns the key of the node label advertising an interpreter given
+// by name or path
+func Label(interpreter string) string {
+	return LabelPrefix + filepath.Base(interpreter)
+}
+
+// Lookup returns the path of an interpreter given by absolute path or by a
+// name searched in PATH. The error wraps types.ErrUnavailable if it is not
+// found on this node.
+func Lookup(interpreter string) (string, error) {
+	path, err := exec.LookPath(interpreter)
+	if err != nil {
+		return "", fmt.Errorf("%w: interpreter %s is not available on this node", types.ErrUnavailable, interpreter)
+	}
+	return path, nil
+}
+
+// Detect returns the labels advertising the interpreters among names found
+// on this node
+func Detect(names []string) map[string]string {
+	labels := make(map[string]string)
+	for _, name := range names {
+		if _, err := Lookup(name); err == nil {
+			labels[Label(name)] = "true"
+		}
+	}
+	return labels
+}
@@ -0,0 +1,35 @@
+package interpreter_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/interpreter"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestLookup(t *testing.T) {
+	path, err := interpreter.Lookup("sh")
+	if err != nil || !filepath.IsAbs(path) {
+		t.Fatalf("Lookup(sh) = %q, %v; want an absolute path", path, err)
+	}
+	if got, err := interpreter.Lookup(path); err != nil || got != path {
+		t.Errorf("Lookup(%s) = %q, %v", path, got, err)
+	}
+	for _, missing := range []string{"no-such-interpreter", "/no/such/interpreter"} {
+		if _, err := interpreter.Lookup(missing); !errors.Is(err, types.ErrUnavailable) {
+			t.Errorf("Lookup(%s) error = %v, want ErrUnavailable", missing, err)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	labels := interpreter.Detect([]string{"sh", "no-such-interpreter"})
+	if len(labels) != 1 || labels["interpreter.sh"] != "true" {
+		t.Errorf("Detect() = %v, want only interpreter.sh=true", labels)
+	}
+	if got := interpreter.Label("/usr/bin/python3"); got != "interpreter.python3" {
+		t.Errorf("Label(/usr/bin/python3) = %q, want interpreter.python3", got)
+	}
+}
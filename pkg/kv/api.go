@@ -0,0 +1,58 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appapi"
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+)
+
+// Register serves the key-value methods of the app API from store.
+// Each app reads and writes the namespace named after it.
+func Register(api *appapi.Server, store *Store) {
+	api.Handle(appsdk.MethodKVGet, func(ctx context.Context, call *appapi.Call) error {
+		var params appsdk.KVParams
+		if err := call.Decode(&params); err != nil {
+			return err
+		}
+		value, err := store.Get(ctx, call.App.Name, params.Key)
+		if err != nil {
+			return err
+		}
+		return call.Reply(&appsdk.KVValue{Value: value})
+	})
+
+	api.Handle(appsdk.MethodKVPut, func(ctx context.Context, call *appapi.Call) error {
+		var params appsdk.KVParams
+		if err := call.Decode(&params); err != nil {
+			return err
+		}
+		if err := store.Put(ctx, call.App.Name, params.Key, params.Value); err != nil {
+			return err
+		}
+		return call.Reply(nil)
+	})
+
+	api.Handle(appsdk.MethodKVDelete, func(ctx context.Context, call *appapi.Call) error {
+		var params appsdk.KVParams
+		if err := call.Decode(&params); err != nil {
+			return err
+		}
+		if err := store.Delete(ctx, call.App.Name, params.Key); err != nil {
+			return err
+		}
+		return call.Reply(nil)
+	})
+
+	api.Handle(appsdk.MethodKVKeys, func(ctx context.Context, call *appapi.Call) error {
+		var params appsdk.KVParams
+		if err := call.Decode(&params); err != nil {
+			return err
+		}
+		keys, err := store.Keys(ctx, call.App.Name, params.Prefix)
+		if err != nil {
+			return err
+		}
+		return call.Reply(&appsdk.KVKeys{Keys: keys})
+	})
+}
@@ -0,0 +1,328 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Limits protecting the daemon from apps storing too much
+const (
+	MaxKeySize   = 256
+	MaxValueSize = 64 * 1024
+	MaxKeys      = 1000
+)
+
+// Storage directories holding one file per namespace
+const (
+	// AppDir holds the per-app stores, one namespace per app name
+	AppDir = "kv"
+
+	// ClusterDir holds the cluster-wide store managed by operators
+	ClusterDir = "cluster-kv"
+)
+
+// DefaultNamespace is the cluster store namespace used when none is given
+const DefaultNamespace = "default"
+
+// Operations of the cluster store protocol (consts.KVProtocolID)
+const (
+	OpGet    = "get"
+	OpPut    = "put"
+	OpDelete = "delete"
+	OpList   = "list"
+)
+
+// Entry is the state of one key. Entries form a last-writer-wins register:
+// the entry with the later Time wins, ties are broken by Node, and deletes are
+// kept as tombstones so they replicate like writes.
+type Entry struct {
+	Value   []byte `json:"value,omitempty"`
+	Time    int64  `json:"time"`
+	Node    string `json:"node"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// namespaceDocument is the stored document of a namespace
+type namespaceDocument struct {
+	Entries map[string]*Entry `json:"entries"`
+}
+
+// schema versions the stored namespaces
+var schema = storage.Schema{
+	Kind: "key-value namespace",
+	Migrations: []storage.Migration{
+		storage.NestUnder("entries"), // 1: the entries moved under entries, next to the schema version
+	},
+}
+
+// newer reports whether e wins over other
+func (e *Entry) newer(other *Entry) bool {
+	if other == nil {
+		return true
+	}
+	if e.Time != other.Time {
+		return e.Time > other.Time
+	}
+	return e.Node > other.Node
+}
+
+// ChangeFunc is called after a local write with the namespace, key and new entry
+type ChangeFunc func(namespace, key string, entry *Entry)
+
+// Store keeps per-namespace key-value pairs in daemon storage.
+// In the app store each app uses its name as namespace, so versions of an app
+// share their data.
+type Store struct {
+	storage  types.Storage
+	dir      string
+	node     string
+	mu       sync.Mutex
+	clock    int64
+	onChange ChangeFunc
+}
+
+// NewStore creates a store kept in dir of storage (AppDir or ClusterDir);
+// node identifies this daemon in written entries
+func NewStore(storage types.Storage, dir, node string) *Store {
+	return &Store{storage: storage, dir: dir, node: node}
+}
+
+// SetOnChange sets the function called after each local write
+func (s *Store) SetOnChange(fn ChangeFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// Get returns the value of key in namespace
+func (s *Store) Get(ctx context.Context, namespace, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	entry := entries[key]
+	if entry == nil || entry.Deleted {
+		return nil, fmt.Errorf("%w: key %q", types.ErrNotFound, key)
+	}
+	return entry.Value, nil
+}
+
+// Put sets key in namespace to value
+func (s *Store) Put(ctx context.Context, namespace, key string, value []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if len(value) > MaxValueSize {
+		return fmt.Errorf("%w: value exceeds %d bytes", types.ErrInvalidInput, MaxValueSize)
+	}
+	return s.write(ctx, namespace, key, &Entry{Value: value})
+}
+
+// Delete removes key from namespace
+func (s *Store) Delete(ctx context.Context, namespace, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	return s.write(ctx, namespace, key, &Entry{Deleted: true})
+}
+
+// Keys returns the live keys in namespace starting with prefix, sorted
+func (s *Store) Keys(ctx context.Context, namespace, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key, entry := range entries {
+		if !entry.Deleted && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Merge applies entries received from another daemon, keeping the newer entry
+// of each key. It returns the number of entries applied.
+func (s *Store) Merge(ctx context.Context, namespace string, incoming map[string]*Entry) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load(ctx, namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for key, entry := range incoming {
+		if entry == nil || validateKey(key) != nil || len(entry.Value) > MaxValueSize {
+			continue
+		}
+		if !entry.newer(entries[key]) {
+			continue
+		}
+		if existing := entries[key]; !entry.Deleted && (existing == nil || existing.Deleted) && liveCount(entries) >= MaxKeys {
+			continue
+		}
+		entries[key] = entry
+		applied++
+
+		// Keep the local clock ahead of every entry seen
+		if entry.Time > s.clock {
+			s.clock = entry.Time
+		}
+	}
+
+	if applied == 0 {
+		return 0, nil
+	}
+	return applied, s.save(ctx, namespace, entries)
+}
+
+// Snapshot returns all entries of namespace, including tombstones
+func (s *Store) Snapshot(ctx context.Context, namespace string) (map[string]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(ctx, namespace)
+}
+
+// Namespaces returns the namespaces holding data
+func (s *Store) Namespaces(ctx context.Context) ([]string, error) {
+	keys, err := s.storage.List(ctx, s.dir)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, types.WrapError(err, "failed to list key-value namespaces")
+	}
+
+	var namespaces []string
+	for _, key := range keys {
+		name := strings.TrimSuffix(filepath.Base(key), ".json")
+		if validateNamespace(name) == nil {
+			namespaces = append(namespaces, name)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// write stamps entry with the next clock value and stores it
+func (s *Store) write(ctx context.Context, namespace, key string, entry *Entry) error {
+	s.mu.Lock()
+	entries, err := s.load(ctx, namespace)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	if existing := entries[key]; !entry.Deleted && (existing == nil || existing.Deleted) && liveCount(entries) >= MaxKeys {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: at most %d keys per app", types.ErrInsufficientStorage, MaxKeys)
+	}
+
+	// A hybrid clock: wall time, but never behind any entry seen
+	s.clock = max(s.clock+1, time.Now().UnixNano())
+	entry.Time = s.clock
+	entry.Node = s.node
+	entries[key] = entry
+
+	if err := s.save(ctx, namespace, entries); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	onChange := s.onChange
+	s.mu.Unlock()
+
+	if onChange != nil {
+		onChange(namespace, key, entry)
+	}
+	return nil
+}
+
+// load reads the entries of namespace. Callers must hold s.mu.
+func (s *Store) load(ctx context.Context, namespace string) (map[string]*Entry, error) {
+	if err := validateNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	data, err := s.storage.Load(ctx, s.storageKey(namespace))
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return make(map[string]*Entry), nil
+		}
+		return nil, types.WrapError(err, "failed to load key-value data")
+	}
+
+	var doc namespaceDocument
+	if err := schema.Unmarshal(data, &doc); err != nil {
+		return nil, types.WrapError(err, "failed to parse key-value data")
+	}
+	if doc.Entries == nil {
+		doc.Entries = make(map[string]*Entry)
+	}
+	return doc.Entries, nil
+}
+
+// save writes the entries of namespace. Callers must hold s.mu.
+func (s *Store) save(ctx context.Context, namespace string, entries map[string]*Entry) error {
+	data, err := schema.Marshal(&namespaceDocument{Entries: entries})
+	if err != nil {
+		return types.WrapError(err, "failed to marshal key-value data")
+	}
+	if err := s.storage.Save(ctx, s.storageKey(namespace), data); err != nil {
+		return types.WrapError(err, "failed to save key-value data")
+	}
+	return nil
+}
+
+// storageKey returns the storage key of a namespace
+func (s *Store) storageKey(namespace string) string {
+	return filepath.Join(s.dir, namespace+".json")
+}
+
+// liveCount returns the number of entries that are not tombstones
+func liveCount(entries map[string]*Entry) int {
+	n := 0
+	for _, entry := range entries {
+		if !entry.Deleted {
+			n++
+		}
+	}
+	return n
+}
+
+// validateNamespace rejects namespaces that are not safe file names
+func validateNamespace(namespace string) error {
+	if namespace == "" || namespace == "." || namespace == ".." || strings.ContainsAny(namespace, `/\`) {
+		return fmt.Errorf("%w: invalid key-value namespace %q", types.ErrInvalidInput, namespace)
+	}
+	return nil
+}
+
+// validateKey rejects empty and oversized keys
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: key must not be empty", types.ErrInvalidInput)
+	}
+	if len(key) > MaxKeySize {
+		return fmt.Errorf("%w: key exceeds %d bytes", types.ErrInvalidInput, MaxKeySize)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/kv"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func newStore(t *testing.T, node string) *kv.Store {
+	t.Helper()
+	fs, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kv.NewStore(fs, kv.AppDir, node)
+}
+
+func TestPutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t, "a")
+
+	if _, err := s.Get(ctx, "app", "k"); !errors.Is(err, types.ErrNotFound) {
+		t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Put(ctx, "app", "k", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "app", "k", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "other", "k", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := s.Get(ctx, "app", "k")
+	if err != nil || string(value) != "v2" {
+		t.Fatalf("Get() = %q, %v, want v2", value, err)
+	}
+
+	if err := s.Delete(ctx, "app", "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "app", "k"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Get(deleted) error = %v, want ErrNotFound", err)
+	}
+	if value, _ := s.Get(ctx, "other", "k"); string(value) != "x" {
+		t.Errorf("namespaces are not isolated: other/k = %q", value)
+	}
+
+	namespaces, err := s.Namespaces(ctx)
+	if err != nil || !reflect.DeepEqual(namespaces, []string{"app", "other"}) {
+		t.Errorf("Namespaces() = %v, %v", namespaces, err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t, "a")
+
+	for _, key := range []string{"user/2", "user/1", "config", "user/3"} {
+		if err := s.Put(ctx, "app", key, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete(ctx, "app", "user/3"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := s.Keys(ctx, "app", "user/")
+	if err != nil || !reflect.DeepEqual(keys, []string{"user/1", "user/2"}) {
+		t.Errorf("Keys(user/) = %v, %v", keys, err)
+	}
+}
+
+func TestMergeLastWriterWins(t *testing.T) {
+	ctx := context.Background()
+	a := newStore(t, "a")
+	b := newStore(t, "b")
+
+	if err := a.Put(ctx, "app", "k", []byte("from a")); err != nil {
+		t.Fatal(err)
+	}
+	snapA, _ := a.Snapshot(ctx, "app")
+	if _, err := b.Merge(ctx, "app", snapA); err != nil {
+		t.Fatal(err)
+	}
+
+	// b has seen a's write, so its own write is newer
+	if err := b.Put(ctx, "app", "k", []byte("from b")); err != nil {
+		t.Fatal(err)
+	}
+	snapB, _ := b.Snapshot(ctx, "app")
+
+	applied, err := a.Merge(ctx, "app", snapB)
+	if err != nil || applied != 1 {
+		t.Fatalf("Merge() = %d, %v, want 1 applied", applied, err)
+	}
+	if value, _ := a.Get(ctx, "app", "k"); string(value) != "from b" {
+		t.Errorf("a.Get() = %q, want newer write from b", value)
+	}
+
+	// Merging the older state back changes nothing
+	applied, err = b.Merge(ctx, "app", snapA)
+	if err != nil || applied != 0 {
+		t.Errorf("Merge(older) = %d, %v, want 0 applied", applied, err)
+	}
+
+	// Deletes replicate as tombstones
+	if err := a.Delete(ctx, "app", "k"); err != nil {
+		t.Fatal(err)
+	}
+	snapA, _ = a.Snapshot(ctx, "app")
+	if _, err := b.Merge(ctx, "app", snapA); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get(ctx, "app", "k"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("b.Get() after replicated delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t, "a")
+
+	tests := []struct {
+		name      string
+		namespace string
+		key       string
+		value     []byte
+		want      error
+	}{
+		{"empty key", "app", "", nil, types.ErrInvalidInput},
+		{"long key", "app", strings.Repeat("k", kv.MaxKeySize+1), nil, types.ErrInvalidInput},
+		{"large value", "app", "k", make([]byte, kv.MaxValueSize+1), types.ErrInvalidInput},
+		{"path namespace", "../app", "k", nil, types.ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Put(ctx, tt.namespace, tt.key, tt.value); !errors.Is(err, tt.want) {
+				t.Errorf("Put() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoresAreIsolated(t *testing.T) {
+	ctx := context.Background()
+	fs, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	apps := kv.NewStore(fs, kv.AppDir, "a")
+	cluster := kv.NewStore(fs, kv.ClusterDir, "a")
+
+	if err := cluster.Put(ctx, kv.DefaultNamespace, "k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apps.Get(ctx, kv.DefaultNamespace, "k"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("app store sees cluster key: error = %v", err)
+	}
+	if namespaces, _ := apps.Namespaces(ctx); len(namespaces) != 0 {
+		t.Errorf("app store Namespaces() = %v, want none", namespaces)
+	}
+}
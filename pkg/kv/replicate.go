@@ -0,0 +1,183 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// AppTopic is the pubsub topic carrying app store updates
+	AppTopic = consts.AppKVTopic
+
+	// ClusterTopic is the pubsub topic carrying cluster store updates
+	ClusterTopic = consts.ClusterKVTopic
+
+	// SyncInterval is how often every namespace is rebroadcast, so daemons
+	// that missed updates or joined later converge
+	SyncInterval = time.Minute
+
+	// maxUpdateSize keeps update messages well below the gossipsub message limit
+	maxUpdateSize = 512 * 1024
+)
+
+// Update carries entries of one namespace between daemons
+type Update struct {
+	Namespace string            `json:"namespace"`
+	Entries   map[string]*Entry `json:"entries"`
+}
+
+// Replicator shares a store with the other daemons over gossipsub.
+// Local writes are published immediately and merged on receipt; periodic
+// full syncs repair anything lost. Merging is last-writer-wins per key.
+type Replicator struct {
+	store  *Store
+	self   peer.ID
+	topic  *pubsub.Topic
+	sub    *pubsub.Subscription
+	logger types.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewReplicator joins topicName on ps to replicate store
+func NewReplicator(ps *pubsub.PubSub, topicName string, self peer.ID, store *Store, logger types.Logger) (*Replicator, error) {
+	topic, err := ps.Join(topicName)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to join key-value topic")
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		_ = topic.Close()
+		return nil, types.WrapError(err, "failed to subscribe to key-value topic")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Replicator{
+		store:  store,
+		self:   self,
+		topic:  topic,
+		sub:    sub,
+		logger: logger.With("topic", topicName),
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Start publishes local writes and merges updates from other daemons
+func (r *Replicator) Start() {
+	r.store.SetOnChange(func(namespace, key string, entry *Entry) {
+		r.publish(&Update{Namespace: namespace, Entries: map[string]*Entry{key: entry}})
+	})
+
+	crash.Loop(r.ctx.Done(), r.logger, "kv.listen", r.listenLoop)
+	crash.Loop(r.ctx.Done(), r.logger, "kv.sync", r.syncLoop)
+}
+
+// Stop stops replicating
+func (r *Replicator) Stop() {
+	r.store.SetOnChange(nil)
+	r.cancel()
+	r.sub.Cancel()
+	if err := r.topic.Close(); err != nil {
+		r.logger.Warn("failed to close key-value topic", "error", err)
+	}
+}
+
+// listenLoop merges updates received from other daemons
+func (r *Replicator) listenLoop() {
+	for {
+		msg, err := r.sub.Next(r.ctx)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+			r.logger.Warn("error receiving key-value update", "error", err)
+			continue
+		}
+		if msg.ReceivedFrom == r.self {
+			continue
+		}
+
+		var update Update
+		if err := json.Unmarshal(msg.Data, &update); err != nil {
+			r.logger.Warn("invalid key-value update", "error", err, "from", msg.ReceivedFrom)
+			continue
+		}
+
+		applied, err := r.store.Merge(r.ctx, update.Namespace, update.Entries)
+		if err != nil {
+			r.logger.Warn("failed to merge key-value update", "namespace", update.Namespace, "error", err)
+			continue
+		}
+		if applied > 0 {
+			r.logger.Debug("merged key-value update", "namespace", update.Namespace, "entries", applied, "from", msg.ReceivedFrom)
+		}
+	}
+}
+
+// syncLoop periodically rebroadcasts every namespace
+func (r *Replicator) syncLoop() {
+	ticker := time.NewTicker(SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.syncAll()
+		}
+	}
+}
+
+// syncAll publishes all entries, split into messages of bounded size
+func (r *Replicator) syncAll() {
+	namespaces, err := r.store.Namespaces(r.ctx)
+	if err != nil {
+		r.logger.Warn("failed to list key-value namespaces", "error", err)
+		return
+	}
+
+	for _, namespace := range namespaces {
+		entries, err := r.store.Snapshot(r.ctx, namespace)
+		if err != nil {
+			r.logger.Warn("failed to read key-value namespace", "namespace", namespace, "error", err)
+			continue
+		}
+
+		batch := &Update{Namespace: namespace, Entries: make(map[string]*Entry)}
+		size := 0
+		for key, entry := range entries {
+			entrySize := len(key) + len(entry.Value)*4/3 + 64
+			if size+entrySize > maxUpdateSize && len(batch.Entries) > 0 {
+				r.publish(batch)
+				batch = &Update{Namespace: namespace, Entries: make(map[string]*Entry)}
+				size = 0
+			}
+			batch.Entries[key] = entry
+			size += entrySize
+		}
+		if len(batch.Entries) > 0 {
+			r.publish(batch)
+		}
+	}
+}
+
+// publish sends an update to the other daemons
+func (r *Replicator) publish(update *Update) {
+	data, err := json.Marshal(update)
+	if err != nil {
+		r.logger.Warn("failed to marshal key-value update", "error", err)
+		return
+	}
+	if err := r.topic.Publish(r.ctx, data); err != nil && r.ctx.Err() == nil {
+		r.logger.Warn("failed to publish key-value update", "namespace", update.Namespace, "error", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package lifecycle starts the subsystems of a daemon in dependency order and
+// stops them in reverse, so that a subsystem failing to start leaves nothing
+// the ones before it acquired behind.
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Component is a subsystem of a daemon. Start runs once the components before
+// it are running, and Stop once the components after it are stopped.
+type Component struct {
+	// Name names the component in logs and status, e.g. "host"
+	Name string
+
+	// Start starts the component
+	Start func() error
+
+	// Stop releases what Start acquired; nil if there is nothing to release
+	Stop func() error
+
+	// Health reports why the running component does not work; nil if it
+	// cannot tell
+	Health func() error
+
+	// Detail describes what the running component does, e.g. the addresses
+	// it listens on; nil if there is nothing to tell
+	Detail func() string
+}
+
+// Group starts and stops components in order. A Group is started once.
+type Group struct {
+	logger     types.Logger
+	components []Component
+
+	mu      sync.Mutex
+	started bool
+	states  []types.ComponentState
+	errs    []error
+}
+
+// New creates a group of components, started in the order given
+func New(logger types.Logger, components ...Component) *Group {
+	states := make([]types.ComponentState, len(components))
+	for i := range states {
+		states[i] = types.ComponentPending
+	}
+	return &Group{
+		logger:     logger,
+		components: components,
+		states:     states,
+		errs:       make([]error, len(components)),
+	}
+}
+
+// Start starts the components in order. If one fails, the components started
+// before it are stopped in reverse order and its error is returned, naming it.
+func (g *Group) Start() error {
+	g.mu.Lock()
+	if g.started {
+		g.mu.Unlock()
+		return fmt.Errorf("%w: components are started already", types.ErrInvalidState)
+	}
+	g.started = true
+	g.mu.Unlock()
+
+	for i, c := range g.components {
+		g.setState(i, types.ComponentStarting, nil)
+		if err := c.Start(); err != nil {
+			g.setState(i, types.ComponentFailed, err)
+			g.logger.Error("component failed to start", "component", c.Name, "error", err)
+			g.mu.Lock()
+			for j := i + 1; j < len(g.components); j++ {
+				g.states[j] = types.ComponentStopped
+			}
+			g.mu.Unlock()
+			if stopErr := g.Stop(); stopErr != nil {
+				g.logger.Warn("failed to stop components after a failed start", "error", stopErr)
+			}
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+		g.setState(i, types.ComponentRunning, nil)
+		g.logger.Debug("component started", "component", c.Name)
+	}
+	return nil
+}
+
+// Stop stops the running components in reverse order, and returns their
+// errors. Stopping again does nothing.
+func (g *Group) Stop() error {
+	var errs []error
+	for i := len(g.components) - 1; i >= 0; i-- {
+		g.mu.Lock()
+		running := g.states[i] == types.ComponentRunning
+		if running {
+			g.states[i] = types.ComponentStopped
+		}
+		g.mu.Unlock()
+		if !running {
+			continue
+		}
+		c := g.components[i]
+		if c.Stop == nil {
+			continue
+		}
+		if err := c.Stop(); err != nil {
+			g.logger.Warn("component failed to stop", "component", c.Name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Status returns the state of each component, in start order. Running
+// components with a failing health check are unhealthy.
+func (g *Group) Status() []*types.ComponentStatus {
+	g.mu.Lock()
+	statuses := make([]*types.ComponentStatus, len(g.components))
+	for i, c := range g.components {
+		statuses[i] = &types.ComponentStatus{Name: c.Name, State: g.states[i]}
+		if g.errs[i] != nil {
+			statuses[i].Error = g.errs[i].Error()
+		}
+	}
+	g.mu.Unlock()
+
+	// Check health outside the lock, since checks may be slow
+	for i, c := range g.components {
+		if statuses[i].State != types.ComponentRunning {
+			continue
+		}
+		if c.Detail != nil {
+			statuses[i].Detail = c.Detail()
+		}
+		if c.Health == nil {
+			continue
+		}
+		if err := c.Health(); err != nil {
+			statuses[i].State = types.ComponentUnhealthy
+			statuses[i].Error = err.Error()
+		}
+	}
+	return statuses
+}
+
+// setState records the state of the i-th component and the error that caused it
+func (g *Group) setState(i int, state types.ComponentState, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.states[i] = state
+	g.errs[i] = err
+}
@@ -0,0 +1,134 @@
+package lifecycle_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/lifecycle"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// recorder records the starts and stops of components
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) component(name string, startErr error) lifecycle.Component {
+	return lifecycle.Component{
+		Name: name,
+		Start: func() error {
+			r.calls = append(r.calls, "start "+name)
+			return startErr
+		},
+		Stop: func() error {
+			r.calls = append(r.calls, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func states(statuses []*types.ComponentStatus) []types.ComponentState {
+	result := make([]types.ComponentState, len(statuses))
+	for i, s := range statuses {
+		result[i] = s.State
+	}
+	return result
+}
+
+func TestStartStopOrder(t *testing.T) {
+	var r recorder
+	g := lifecycle.New(logging.Nop(), r.component("storage", nil), r.component("host", nil), r.component("handlers", nil))
+
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := g.Start(); !errors.Is(err, types.ErrInvalidState) {
+		t.Errorf("second Start() error = %v, want ErrInvalidState", err)
+	}
+	if err := g.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := g.Stop(); err != nil {
+		t.Fatalf("second Stop() error = %v", err)
+	}
+
+	want := []string{"start storage", "start host", "start handlers", "stop handlers", "stop host", "stop storage"}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("calls = %v, want %v", r.calls, want)
+	}
+}
+
+func TestFailedStartStopsStartedComponents(t *testing.T) {
+	var r recorder
+	g := lifecycle.New(logging.Nop(),
+		r.component("storage", nil),
+		r.component("host", nil),
+		r.component("runtime", errors.New("unknown backend")),
+		r.component("handlers", nil),
+	)
+
+	err := g.Start()
+	if err == nil || !strings.Contains(err.Error(), "runtime: unknown backend") {
+		t.Fatalf("Start() error = %v, want the error of runtime", err)
+	}
+	want := []string{"start storage", "start host", "start runtime", "stop host", "stop storage"}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("calls = %v, want %v", r.calls, want)
+	}
+
+	statuses := g.Status()
+	wantStates := []types.ComponentState{types.ComponentStopped, types.ComponentStopped, types.ComponentFailed, types.ComponentStopped}
+	if got := states(statuses); !reflect.DeepEqual(got, wantStates) {
+		t.Errorf("states = %v, want %v", got, wantStates)
+	}
+	if statuses[2].Error != "unknown backend" {
+		t.Errorf("Error = %q, want the start error", statuses[2].Error)
+	}
+
+	if err := g.Stop(); err != nil || len(r.calls) != len(want) {
+		t.Errorf("Stop() after a failed start = %v, calls %v", err, r.calls)
+	}
+}
+
+func TestStatusReportsHealth(t *testing.T) {
+	var healthErr error
+	g := lifecycle.New(logging.Nop(),
+		lifecycle.Component{Name: "storage", Start: func() error { return nil }},
+		lifecycle.Component{
+			Name:   "discovery",
+			Start:  func() error { return nil },
+			Stop:   func() error { return errors.New("still closing") },
+			Health: func() error { return healthErr },
+			Detail: func() string { return "3 peers" },
+		},
+	)
+	if got := states(g.Status()); !reflect.DeepEqual(got, []types.ComponentState{types.ComponentPending, types.ComponentPending}) {
+		t.Errorf("states before Start() = %v", got)
+	}
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := states(g.Status()); !reflect.DeepEqual(got, []types.ComponentState{types.ComponentRunning, types.ComponentRunning}) {
+		t.Errorf("states = %v, want running", got)
+	}
+
+	if detail := g.Status()[1].Detail; detail != "3 peers" {
+		t.Errorf("Detail = %q, want the detail of discovery", detail)
+	}
+
+	healthErr = errors.New("no peers")
+	statuses := g.Status()
+	if statuses[1].State != types.ComponentUnhealthy || statuses[1].Error != "no peers" {
+		t.Errorf("Status() = %+v, want discovery unhealthy", statuses[1])
+	}
+
+	if err := g.Stop(); err == nil || !strings.Contains(err.Error(), "discovery: still closing") {
+		t.Errorf("Stop() error = %v, want the error of discovery", err)
+	}
+	if got := states(g.Status()); !reflect.DeepEqual(got, []types.ComponentState{types.ComponentStopped, types.ComponentStopped}) {
+		t.Errorf("states after Stop() = %v, want stopped", got)
+	}
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"go.uber.org/zap"
+)
+
+// contextKey is the type of context keys defined by this package
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// nopLogger discards everything
+var nopLogger types.Logger = &logger{zap: zap.NewNop()}
+
+// Nop returns a logger that discards all messages
+func Nop() types.Logger {
+	return nopLogger
+}
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l types.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the logger carried by ctx, or a no-op logger if there is none.
+// Loggers attached through WithRequestID already include the request_id field.
+func FromContext(ctx context.Context) types.Logger {
+	return FromContextOr(ctx, nopLogger)
+}
+
+// FromContextOr returns the logger carried by ctx, or fallback if there is none
+func FromContextOr(ctx context.Context, fallback types.Logger) types.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey).(types.Logger); ok {
+			return l
+		}
+	}
+	return fallback
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+// If ctx carries a logger, it is replaced by one that logs the request_id field.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, requestID)
+	if l, ok := ctx.Value(loggerKey).(types.Logger); ok {
+		ctx = NewContext(ctx, l.With("request_id", requestID))
+	}
+	return ctx
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// maxRequestIDLength bounds caller-supplied request IDs
+const maxRequestIDLength = 64
+
+// ValidRequestID reports whether a caller-supplied request ID is safe to log,
+// echo back and use in file names: letters, digits, '-', '_' and '.', with at
+// least one letter or digit so that it is never "." or "..".
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	alphanumeric := false
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			alphanumeric = true
+		case c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return alphanumeric
+}
+
+// NewRequestID generates a short random correlation ID
+func NewRequestID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to a time-based ID; uniqueness matters more than randomness here
+		return fmt.Sprintf("%012x", time.Now().UnixNano()&0xffffffffffff)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,68 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+)
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	base := logging.NewSlog(slog.NewTextHandler(&buf, nil))
+
+	ctx := logging.NewContext(context.Background(), base)
+	ctx = logging.WithRequestID(ctx, "abc123")
+
+	if got := logging.RequestIDFromContext(ctx); got != "abc123" {
+		t.Errorf("RequestIDFromContext() = %q, want abc123", got)
+	}
+
+	logging.FromContext(ctx).Info("handling request")
+	if !strings.Contains(buf.String(), "request_id=abc123") {
+		t.Errorf("expected request_id in log output, got: %s", buf.String())
+	}
+}
+
+func TestFromContextWithoutLogger(t *testing.T) {
+	// Must not panic without a logger in the context
+	logging.FromContext(context.Background()).Info("discarded")
+
+	if logging.RequestIDFromContext(context.Background()) != "" {
+		t.Error("expected empty request ID")
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	a, b := logging.NewRequestID(), logging.NewRequestID()
+	if len(a) != 12 {
+		t.Errorf("request ID length = %d, want 12", len(a))
+	}
+	if a == b {
+		t.Error("expected distinct request IDs")
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := map[string]bool{
+		"abc123":                true,
+		"deploy-1.2_x":          true,
+		logging.NewRequestID():  true,
+		"":                      false,
+		".":                     false,
+		"..":                    false,
+		"-._":                   false,
+		"a/b":                   false,
+		"a b":                   false,
+		"id\n":                  false,
+		strings.Repeat("a", 65): false,
+	}
+	for id, want := range tests {
+		if got := logging.ValidRequestID(id); got != want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
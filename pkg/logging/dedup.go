@@ -0,0 +1,175 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// dedupState is shared by a deduplicating logger and the loggers derived from it with With
+type dedupState struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*repeatedEntry
+}
+
+// repeatedEntry counts the repeats of a message logged within the current window
+type repeatedEntry struct {
+	logger   types.Logger
+	level    string
+	msg      string
+	fields   []interface{}
+	repeated int
+	timer    *time.Timer
+}
+
+// dedupLogger logs a warning or error once per window, and then how many
+// times it was repeated within the window
+type dedupLogger struct {
+	inner types.Logger
+	state *dedupState
+
+	// prefix identifies the fields added with With, since they are part of
+	// what makes two messages the same
+	prefix string
+}
+
+// NewDeduplicated returns a logger that logs a warning or error with the same
+// message and fields once per window. Repeats within the window are counted and
+// summarized as "repeated N times in <window>" when the window ends, so flaky
+// peers and DHT lookups do not flood the log. Debug and info messages are
+// logged as they are. A window of zero or less returns inner unchanged.
+func NewDeduplicated(inner types.Logger, window time.Duration) types.Logger {
+	if window <= 0 {
+		return inner
+	}
+	return &dedupLogger{
+		inner: inner,
+		state: &dedupState{window: window, entries: make(map[string]*repeatedEntry)},
+	}
+}
+
+// Debug logs a debug message
+func (l *dedupLogger) Debug(msg string, fields ...interface{}) {
+	l.inner.Debug(msg, fields...)
+}
+
+// Info logs an info message
+func (l *dedupLogger) Info(msg string, fields ...interface{}) {
+	l.inner.Info(msg, fields...)
+}
+
+// Warn logs a warning message unless it was logged within the window
+func (l *dedupLogger) Warn(msg string, fields ...interface{}) {
+	if l.state.first(l, "warn", msg, fields) {
+		l.inner.Warn(msg, fields...)
+	}
+}
+
+// Error logs an error message unless it was logged within the window
+func (l *dedupLogger) Error(msg string, fields ...interface{}) {
+	if l.state.first(l, "error", msg, fields) {
+		l.inner.Error(msg, fields...)
+	}
+}
+
+// With returns a logger with additional fields, sharing the window
+func (l *dedupLogger) With(fields ...interface{}) types.Logger {
+	return &dedupLogger{
+		inner:  l.inner.With(fields...),
+		state:  l.state,
+		prefix: l.prefix + formatFields(fields),
+	}
+}
+
+// Sync logs the summaries of the repeats counted so far, and flushes the
+// underlying logger if it buffers
+func (l *dedupLogger) Sync() error {
+	l.state.flushAll()
+	if s, ok := l.inner.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// first reports whether the message is the first of its window. Otherwise the
+// repeat is counted for the summary.
+func (s *dedupState) first(l *dedupLogger, level, msg string, fields []interface{}) bool {
+	key := level + "\x00" + l.prefix + "\x00" + msg + "\x00" + formatFields(fields)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.repeated++
+		return false
+	}
+	e := &repeatedEntry{logger: l.inner, level: level, msg: msg, fields: fields}
+	e.timer = time.AfterFunc(s.window, func() { s.flush(key, e) })
+	s.entries[key] = e
+	return true
+}
+
+// flush ends the window of an entry, logging its summary if it was repeated
+func (s *dedupState) flush(key string, e *repeatedEntry) {
+	s.mu.Lock()
+	if s.entries[key] != e {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.entries, key)
+	s.mu.Unlock()
+	s.summarize(e)
+}
+
+// flushAll ends the windows of all entries
+func (s *dedupState) flushAll() {
+	s.mu.Lock()
+	entries := make([]*repeatedEntry, 0, len(s.entries))
+	for key, e := range s.entries {
+		e.timer.Stop()
+		entries = append(entries, e)
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+	for _, e := range entries {
+		s.summarize(e)
+	}
+}
+
+// summarize logs how many times a message was repeated within its window
+func (s *dedupState) summarize(e *repeatedEntry) {
+	if e.repeated == 0 {
+		return
+	}
+	msg := fmt.Sprintf("%s (repeated %d times in %s)", e.msg, e.repeated, formatWindow(s.window))
+	if e.level == "error" {
+		e.logger.Error(msg, e.fields...)
+	} else {
+		e.logger.Warn(msg, e.fields...)
+	}
+}
+
+// formatFields formats key-value pairs for comparison
+func formatFields(fields []interface{}) string {
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%v\x1f", f)
+	}
+	return b.String()
+}
+
+// formatWindow formats a window as "1m" rather than "1m0s"
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,121 @@
+package logging_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// recordingLogger records the messages logged through it
+type recordingLogger struct {
+	mu       *sync.Mutex
+	messages *[]string
+	prefix   string
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{mu: &sync.Mutex{}, messages: &[]string{}}
+}
+
+func (l *recordingLogger) record(level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.messages = append(*l.messages, level+" "+l.prefix+msg)
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...interface{}) { l.record("debug", msg) }
+func (l *recordingLogger) Info(msg string, fields ...interface{})  { l.record("info", msg) }
+func (l *recordingLogger) Warn(msg string, fields ...interface{})  { l.record("warn", msg) }
+func (l *recordingLogger) Error(msg string, fields ...interface{}) { l.record("error", msg) }
+
+func (l *recordingLogger) With(fields ...interface{}) types.Logger {
+	return &recordingLogger{mu: l.mu, messages: l.messages, prefix: l.prefix + fields[1].(string) + ": "}
+}
+
+func (l *recordingLogger) Messages() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), *l.messages...)
+}
+
+func TestDeduplicatedSummarizesRepeats(t *testing.T) {
+	rec := newRecordingLogger()
+	l := logging.NewDeduplicated(rec, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		l.Warn("failed to find peers via DHT", "error", "no peers")
+	}
+	l.Warn("failed to find peers via DHT", "error", "context canceled")
+	l.Info("joined topic")
+	l.Info("joined topic")
+	for i := 0; i < 3; i++ {
+		l.With("peer", "QmA").Error("failed to connect")
+	}
+	l.With("peer", "QmB").Error("failed to connect")
+
+	want := []string{
+		"warn failed to find peers via DHT",
+		"warn failed to find peers via DHT",
+		"info joined topic",
+		"info joined topic",
+		"error QmA: failed to connect",
+		"error QmB: failed to connect",
+	}
+	if got := rec.Messages(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages = %q, want %q", got, want)
+	}
+
+	if s, ok := l.(interface{ Sync() error }); !ok {
+		t.Fatal("deduplicated logger has no Sync")
+	} else if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	got := rec.Messages()[len(want):]
+	wantSummaries := map[string]bool{
+		"warn failed to find peers via DHT (repeated 4 times in 1h)": true,
+		"error QmA: failed to connect (repeated 2 times in 1h)":      true,
+	}
+	if len(got) != len(wantSummaries) {
+		t.Fatalf("summaries = %q, want %d", got, len(wantSummaries))
+	}
+	for _, msg := range got {
+		if !wantSummaries[msg] {
+			t.Errorf("unexpected summary %q", msg)
+		}
+	}
+}
+
+func TestDeduplicatedWindowEnds(t *testing.T) {
+	rec := newRecordingLogger()
+	l := logging.NewDeduplicated(rec, 20*time.Millisecond)
+
+	l.Warn("failed to bootstrap DHT")
+	l.Warn("failed to bootstrap DHT")
+	l.Warn("failed to bootstrap DHT")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.Messages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	want := []string{"warn failed to bootstrap DHT", "warn failed to bootstrap DHT (repeated 2 times in 20ms)"}
+	if got := rec.Messages(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages = %q, want %q", got, want)
+	}
+
+	// A new window starts with the next occurrence
+	l.Warn("failed to bootstrap DHT")
+	if got := rec.Messages(); len(got) != 3 || got[2] != "warn failed to bootstrap DHT" {
+		t.Errorf("messages = %q, want the warning logged again", got)
+	}
+}
+
+func TestDeduplicatedDisabled(t *testing.T) {
+	rec := newRecordingLogger()
+	if l := logging.NewDeduplicated(rec, 0); l != types.Logger(rec) {
+		t.Errorf("NewDeduplicated(0) = %T, want the logger unchanged", l)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/asjdf/p2p-playground-lite/pkg/config"
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
@@ -16,7 +19,8 @@ type logger struct {
 	zap *zap.Logger
 }
 
-// New creates a new logger from configuration
+// New creates a new logger from configuration. With a repeat window, repeated
+// warnings and errors are summarized (see NewDeduplicated).
 func New(cfg *config.LoggingConfig) (types.Logger, error) {
 	// Parse log level
 	level, err := parseLevel(cfg.Level)
@@ -50,6 +54,15 @@ func New(cfg *config.LoggingConfig) (types.Logger, error) {
 		errorOutputPath = "stderr"
 	}
 
+	// Use a rotating file writer when logging to a file with rotation configured
+	if isFilePath(outputPath) && cfg.Rotation.MaxSizeMB > 0 {
+		l, err := newRotatingLogger(cfg, level, encoderCfg, outputPath, errorOutputPath)
+		if err != nil {
+			return nil, err
+		}
+		return NewDeduplicated(l, cfg.RepeatWindow), nil
+	}
+
 	// Create zap config
 	zapCfg := zap.Config{
 		Level:            zap.NewAtomicLevelAt(level),
@@ -66,9 +79,61 @@ func New(cfg *config.LoggingConfig) (types.Logger, error) {
 		return nil, fmt.Errorf("failed to build logger: %w", err)
 	}
 
+	return NewDeduplicated(&logger{zap: zapLogger}, cfg.RepeatWindow), nil
+}
+
+// newRotatingLogger creates a logger writing to a size-rotated file
+func newRotatingLogger(cfg *config.LoggingConfig, level zapcore.Level, encoderCfg zapcore.EncoderConfig, outputPath, errorOutputPath string) (types.Logger, error) {
+	errSink, closeErrSink, err := zap.Open(errorOutputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open error output: %w", err)
+	}
+
+	// Rotation errors go to the error output like zap's own: logging them
+	// would write to the file being rotated
+	writer, err := NewRotatingWriter(expandHome(outputPath), RotateOptions{
+		MaxSizeMB:  cfg.Rotation.MaxSizeMB,
+		MaxAgeDays: cfg.Rotation.MaxAgeDays,
+		MaxBackups: cfg.Rotation.MaxBackups,
+		Compress:   cfg.Rotation.Compress,
+		OnError: func(err error) {
+			_, _ = fmt.Fprintf(errSink, "%v log rotation error: %v\n", time.Now(), err)
+			_ = errSink.Sync()
+		},
+	})
+	if err != nil {
+		closeErrSink()
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	var encoder zapcore.Encoder
+	if cfg.Format == "json" {
+		encoder = zapcore.NewJSONEncoder(encoderCfg)
+	} else {
+		encoder = zapcore.NewConsoleEncoder(encoderCfg)
+	}
+
+	core := zapcore.NewCore(encoder, writer, level)
+	zapLogger := zap.New(core, zap.ErrorOutput(errSink))
+
 	return &logger{zap: zapLogger}, nil
 }
 
+// isFilePath returns true if the output path refers to a file rather than a standard stream
+func isFilePath(path string) bool {
+	return path != "" && path != "stdout" && path != "stderr"
+}
+
+// expandHome expands a leading ~/ in a path to the user's home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
 // NewWithOutput creates a logger with a custom output writer (for testing)
 func NewWithOutput(cfg *config.LoggingConfig, output io.Writer) (types.Logger, error) {
 	// Parse log level
@@ -267,10 +332,14 @@ func InitForCLI(level string) error {
 	return InitFromConfig(cfg)
 }
 
-// RedirectStdLog redirects standard library log to zap
+// RedirectStdLog redirects standard library log to zap.
+// It is a no-op for loggers that are not zap-backed.
 func RedirectStdLog(l types.Logger) func() {
-	zapLogger := l.(*logger).zap
-	return zap.RedirectStdLog(zapLogger)
+	zl, ok := l.(*logger)
+	if !ok {
+		return func() {}
+	}
+	return zap.RedirectStdLog(zl.zap)
 }
 
 // DefaultLogger creates a logger with default settings
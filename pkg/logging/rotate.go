@@ -0,0 +1,324 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// backupTimeFormat is the timestamp format embedded in rotated file
+	// names, in UTC so that pruning by age does not depend on the time zone
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+
+	// compressSuffix is appended to compressed backups
+	compressSuffix = ".gz"
+
+	megabyte = 1024 * 1024
+)
+
+// RotateOptions controls rotation of a log file
+type RotateOptions struct {
+	// MaxSizeMB is the size in megabytes at which the file is rotated (0 disables size rotation)
+	MaxSizeMB int
+
+	// MaxAgeDays is the number of days to retain rotated files (0 keeps them regardless of age)
+	MaxAgeDays int
+
+	// MaxBackups is the number of rotated files to retain (0 keeps all)
+	MaxBackups int
+
+	// Compress gzips rotated files
+	Compress bool
+
+	// OnError is called with the errors of a rotation that do not fail the
+	// write, such as a backup that could not be compressed or pruned (nil
+	// ignores them). It is called from the background, one error at a time.
+	OnError func(err error)
+}
+
+// RotatingWriter is an io.Writer that writes to a file and rotates it by size,
+// pruning old backups by count and age. Backups are compressed and pruned in
+// the background, so that writes do not wait for them.
+type RotatingWriter struct {
+	path string
+	opts RotateOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// cleanups are the compressions and prunings in progress, which
+	// cleanupMu runs one at a time
+	cleanups  sync.WaitGroup
+	cleanupMu sync.Mutex
+
+	// now is overridable for tests
+	now func() time.Time
+}
+
+// NewRotatingWriter opens (or creates) the log file at path
+func NewRotatingWriter(path string, opts RotateOptions) (*RotatingWriter, error) {
+	w := &RotatingWriter{
+		path: path,
+		opts: opts,
+		now:  time.Now,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	if err := w.openExisting(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write writes p to the current file, rotating first if it would exceed the size limit
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	maxSize := int64(w.opts.MaxSizeMB) * megabyte
+	if maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Sync flushes the current file to disk
+func (w *RotatingWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close closes the current file, once the backups rotated so far are
+// compressed and pruned
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+
+	w.cleanups.Wait()
+	return err
+}
+
+// Rotate forces a rotation of the current file
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+// openExisting opens the log file in append mode
+func (w *RotatingWriter) openExisting() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file to a timestamped backup and opens a new one.
+// Callers must hold w.mu.
+func (w *RotatingWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+		w.file = nil
+	}
+
+	// Avoid clobbering a backup created within the same millisecond
+	t := w.now()
+	backup := w.backupName(t)
+	for fileExists(backup) || fileExists(backup+compressSuffix) {
+		t = t.Add(time.Millisecond)
+		backup = w.backupName(t)
+	}
+
+	if err := os.Rename(w.path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := w.openExisting(); err != nil {
+		return err
+	}
+
+	w.cleanups.Add(1)
+	go w.cleanUp(backup)
+	return nil
+}
+
+// cleanUp compresses backup if configured to and prunes the backups. It runs
+// without w.mu held, so that writes go on meanwhile.
+func (w *RotatingWriter) cleanUp(backup string) {
+	defer w.cleanups.Done()
+	w.cleanupMu.Lock()
+	defer w.cleanupMu.Unlock()
+
+	if w.opts.Compress {
+		// A backup pruned by an earlier cleanup is gone already
+		if err := compressFile(backup); err != nil && !os.IsNotExist(err) {
+			w.reportError(fmt.Errorf("failed to compress rotated log %s: %w", backup, err))
+		}
+	}
+
+	if err := w.prune(); err != nil {
+		w.reportError(fmt.Errorf("failed to prune rotated logs: %w", err))
+	}
+}
+
+// reportError passes err to OnError if set
+func (w *RotatingWriter) reportError(err error) {
+	if w.opts.OnError != nil {
+		w.opts.OnError(err)
+	}
+}
+
+// backupName returns the rotated file name for the given time
+func (w *RotatingWriter) backupName(t time.Time) string {
+	dir := filepath.Dir(w.path)
+	base := filepath.Base(w.path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext)
+	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, t.UTC().Format(backupTimeFormat), ext))
+}
+
+// backupFile is a rotated log file discovered on disk
+type backupFile struct {
+	path      string
+	timestamp time.Time
+}
+
+// backups lists rotated files for this writer, newest first
+func (w *RotatingWriter) backups() ([]backupFile, error) {
+	dir := filepath.Dir(w.path)
+	base := filepath.Base(w.path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []backupFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		stamp := strings.TrimPrefix(name, prefix)
+		stamp = strings.TrimSuffix(stamp, compressSuffix)
+		stamp = strings.TrimSuffix(stamp, ext)
+
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.UTC)
+		if err != nil {
+			continue
+		}
+		files = append(files, backupFile{path: filepath.Join(dir, name), timestamp: t})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].timestamp.After(files[j].timestamp)
+	})
+
+	return files, nil
+}
+
+// prune removes backups exceeding MaxBackups or older than MaxAgeDays
+func (w *RotatingWriter) prune() error {
+	if w.opts.MaxBackups <= 0 && w.opts.MaxAgeDays <= 0 {
+		return nil
+	}
+
+	files, err := w.backups()
+	if err != nil {
+		return err
+	}
+
+	cutoff := w.now().Add(-time.Duration(w.opts.MaxAgeDays) * 24 * time.Hour)
+	for i, f := range files {
+		expired := w.opts.MaxAgeDays > 0 && f.timestamp.Before(cutoff)
+		excess := w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups
+		if expired || excess {
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// compressFile gzips src into src.gz and removes src
+func compressFile(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(src+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		_ = gz.Close()
+		_ = out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// fileExists returns true if a file exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,159 @@
+package logging_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+)
+
+func TestRotatingWriterRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "daemon.log")
+
+	w, err := logging.NewRotatingWriter(path, logging.RotateOptions{MaxSizeMB: 1})
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	chunk := []byte(strings.Repeat("x", 600*1024))
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected current file and one backup, got %d entries", len(entries))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat current file: %v", err)
+	}
+	if info.Size() != int64(len(chunk)) {
+		t.Errorf("current file size = %d, want %d", info.Size(), len(chunk))
+	}
+}
+
+func TestRotatingWriterCompressAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "daemon.log")
+
+	w, err := logging.NewRotatingWriter(path, logging.RotateOptions{
+		MaxSizeMB:  1,
+		MaxBackups: 2,
+		Compress:   true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	for i := 0; i < 4; i++ {
+		if _, err := w.Write([]byte("line\n")); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		if err := w.Rotate(); err != nil {
+			t.Fatalf("rotate failed: %v", err)
+		}
+	}
+
+	// Close waits for the backups to be compressed and pruned
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "daemon-*.log.gz"))
+	if err != nil {
+		t.Fatalf("glob failed: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Errorf("expected 2 compressed backups after pruning, got %d: %v", len(matches), matches)
+	}
+}
+
+func TestRotatingWriterAgesBackupsInUTC(t *testing.T) {
+	// Far from UTC, backups named in local time would look 14 hours off
+	local := time.Local
+	time.Local = time.FixedZone("UTC+14", 14*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "daemon.log")
+	const format = "2006-01-02T15-04-05.000"
+	now := time.Now().UTC()
+	recent := filepath.Join(dir, "daemon-"+now.Add(-23*time.Hour).Format(format)+".log")
+	expired := filepath.Join(dir, "daemon-"+now.Add(-25*time.Hour).Format(format)+".log")
+	for _, name := range []string{recent, expired} {
+		if err := os.WriteFile(name, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := logging.NewRotatingWriter(path, logging.RotateOptions{MaxSizeMB: 1, MaxAgeDays: 1})
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("backup of 23 hours ago was pruned: %v", err)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("backup of 25 hours ago was kept: %v", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "daemon-*.log"))
+	if err != nil {
+		t.Fatalf("glob failed: %v", err)
+	}
+	var found bool
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), "daemon-"), ".log")
+		at, err := time.Parse(format, stamp)
+		if err == nil && at.Sub(now).Abs() < time.Minute {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no backup named with the UTC time %s: %v", now.Format(format), matches)
+	}
+}
+
+func TestNewLoggerWithRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	cfg := &config.LoggingConfig{
+		Level:      "info",
+		Format:     "json",
+		OutputPath: path,
+		Rotation:   config.LogRotationConfig{MaxSizeMB: 10},
+	}
+
+	logger, err := logging.New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	logger.Info("rotation enabled")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "rotation enabled") {
+		t.Errorf("expected log line in file, got: %s", data)
+	}
+}
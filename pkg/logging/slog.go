@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// slogLogger wraps slog.Logger to implement types.Logger
+type slogLogger struct {
+	slog *slog.Logger
+}
+
+// NewSlog creates a types.Logger backed by an slog.Handler.
+// This lets programs embedding pkg/daemon or pkg/p2p plug in their own logging
+// backend (slog, or logrus/zerolog via an slog.Handler bridge).
+func NewSlog(h slog.Handler) types.Logger {
+	return &slogLogger{slog: slog.New(h)}
+}
+
+// FromSlog creates a types.Logger backed by an existing slog.Logger
+func FromSlog(l *slog.Logger) types.Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{slog: l}
+}
+
+// Debug logs a debug message
+func (l *slogLogger) Debug(msg string, fields ...interface{}) {
+	l.slog.Debug(msg, fields...)
+}
+
+// Info logs an info message
+func (l *slogLogger) Info(msg string, fields ...interface{}) {
+	l.slog.Info(msg, fields...)
+}
+
+// Warn logs a warning message
+func (l *slogLogger) Warn(msg string, fields ...interface{}) {
+	l.slog.Warn(msg, fields...)
+}
+
+// Error logs an error message
+func (l *slogLogger) Error(msg string, fields ...interface{}) {
+	l.slog.Error(msg, fields...)
+}
+
+// With returns a logger with additional fields
+func (l *slogLogger) With(fields ...interface{}) types.Logger {
+	return &slogLogger{slog: l.slog.With(fields...)}
+}
+
+// Slog returns the underlying slog.Logger
+func (l *slogLogger) Slog() *slog.Logger {
+	return l.slog
+}
+
+// Enabled reports whether the underlying handler emits records at the given level
+func (l *slogLogger) Enabled(level slog.Level) bool {
+	return l.slog.Enabled(context.Background(), level)
+}
+
+// StaticProvider is a types.LoggerProvider that always returns the same logger
+type StaticProvider struct {
+	logger types.Logger
+}
+
+// NewStaticProvider creates a provider returning l
+func NewStaticProvider(l types.Logger) *StaticProvider {
+	return &StaticProvider{logger: l}
+}
+
+// Logger returns the provided logger
+func (p *StaticProvider) Logger() types.Logger {
+	return p.logger
+}
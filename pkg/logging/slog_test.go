@@ -0,0 +1,28 @@
+package logging_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.NewSlog(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	logger.With("component", "test").Info("hello", "key", "value")
+
+	out := buf.String()
+	for _, want := range []string{"msg=hello", "component=test", "key=value"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got: %s", want, out)
+		}
+	}
+
+	// RedirectStdLog must not panic on non-zap loggers
+	restore := logging.RedirectStdLog(logger)
+	restore()
+}
@@ -0,0 +1,224 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appapi"
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// MaxDataSize is the largest message payload apps may send
+const MaxDataSize = 256 * 1024
+
+// InboxSize is the number of messages buffered for each receiver
+const InboxSize = 64
+
+// sendTimeout bounds the delivery of a message to another node
+const sendTimeout = 10 * time.Second
+
+// Transport opens streams to other daemons; *p2p.Host implements it
+type Transport interface {
+	ID() string
+	NewStream(ctx context.Context, peerID string, protocolID string) (types.Stream, error)
+}
+
+// envelope carries a message between daemons. The sending node is taken from
+// the connection, so apps cannot claim to run elsewhere.
+type envelope struct {
+	FromApp string `json:"from_app"`
+	ToApp   string `json:"to_app"`
+	Data    []byte `json:"data"`
+}
+
+// Router delivers messages between apps, locally or through the daemon of
+// the receiving node. Apps are addressed by name, so any running version of
+// an app receives its messages.
+type Router struct {
+	transport Transport
+	logger    types.Logger
+	mu        sync.Mutex
+	receivers map[string][]chan *appsdk.Message
+}
+
+// New creates a router sending through transport
+func New(transport Transport, logger types.Logger) *Router {
+	return &Router{
+		transport: transport,
+		logger:    logger,
+		receivers: make(map[string][]chan *appsdk.Message),
+	}
+}
+
+// Register serves the messaging methods of the app API
+func (r *Router) Register(api *appapi.Server) {
+	api.Handle(appsdk.MethodSend, r.handleSend)
+	api.Handle(appsdk.MethodReceive, r.handleReceive)
+}
+
+// Send delivers data from app fromApp to app toApp on node (empty for the local node)
+func (r *Router) Send(ctx context.Context, fromApp, node, toApp string, data []byte) error {
+	if toApp == "" {
+		return fmt.Errorf("%w: receiving app name is required", types.ErrInvalidInput)
+	}
+	if len(data) > MaxDataSize {
+		return fmt.Errorf("%w: message exceeds %d bytes", types.ErrInvalidInput, MaxDataSize)
+	}
+
+	if node == "" || node == r.transport.ID() {
+		return r.deliver(toApp, &appsdk.Message{FromNode: r.transport.ID(), FromApp: fromApp, Data: data})
+	}
+	return r.forward(ctx, node, &envelope{FromApp: fromApp, ToApp: toApp, Data: data})
+}
+
+// forward sends env to the daemon of node and waits for its verdict
+func (r *Router) forward(ctx context.Context, node string, env *envelope) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	stream, err := r.transport.NewStream(ctx, node, consts.MessagingProtocolID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", types.ErrConnectionFailed, err)
+	}
+	defer func() { _ = stream.Close() }()
+	stop := context.AfterFunc(ctx, func() { _ = stream.Reset() })
+	defer stop()
+
+	if err := wire.WriteMessage(stream, env); err != nil {
+		return err
+	}
+	var resp appsdk.Response
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: node %s did not answer", types.ErrTimeout, node)
+		}
+		return err
+	}
+	if !resp.Success {
+		return types.ErrorFromCode(resp.Code, resp.Error)
+	}
+	return nil
+}
+
+// deliver hands msg to a receiver of app with room in its inbox
+func (r *Router) deliver(app string, msg *appsdk.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	receivers := r.receivers[app]
+	if len(receivers) == 0 {
+		return fmt.Errorf("%w: app %q is not receiving messages", types.ErrNotFound, app)
+	}
+	for _, inbox := range receivers {
+		select {
+		case inbox <- msg:
+			return nil
+		default:
+		}
+	}
+	return fmt.Errorf("%w: inbox of app %q is full", types.ErrUnavailable, app)
+}
+
+// HandleStream accepts a message another daemon sends on
+// consts.MessagingProtocolID
+func (r *Router) HandleStream(stream types.Stream) {
+	defer func() { _ = stream.Close() }()
+
+	var env envelope
+	if err := wire.ReadMessage(stream, &env); err != nil {
+		r.logger.Debug("invalid message from peer", "error", err)
+		return
+	}
+
+	from := p2p.RemotePeer(stream)
+	var err error
+	if len(env.Data) > MaxDataSize {
+		err = fmt.Errorf("%w: message exceeds %d bytes", types.ErrInvalidInput, MaxDataSize)
+	} else {
+		err = r.deliver(env.ToApp, &appsdk.Message{FromNode: from, FromApp: env.FromApp, Data: env.Data})
+	}
+	if err != nil {
+		r.logger.Debug("rejected message from peer", "from", from, "app", env.ToApp, "error", err)
+	}
+
+	_ = wire.WriteMessage(stream, &appsdk.Response{
+		Success: err == nil,
+		Error:   errorMessage(err),
+		Code:    types.ErrorCode(err),
+	})
+}
+
+// handleSend sends a message on behalf of the calling app
+func (r *Router) handleSend(ctx context.Context, call *appapi.Call) error {
+	var params appsdk.SendParams
+	if err := call.Decode(&params); err != nil {
+		return err
+	}
+	if err := r.Send(ctx, call.App.Name, params.Node, params.App, params.Data); err != nil {
+		return err
+	}
+	return call.Reply(nil)
+}
+
+// handleReceive streams the messages sent to the calling app until it hangs up
+func (r *Router) handleReceive(ctx context.Context, call *appapi.Call) error {
+	inbox := make(chan *appsdk.Message, InboxSize)
+	r.addReceiver(call.App.Name, inbox)
+	defer r.removeReceiver(call.App.Name, inbox)
+
+	if err := call.Reply(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-inbox:
+			if err := call.Reply(msg); err != nil {
+				r.logger.Debug("failed to deliver message", "app_id", call.App.ID, "error", err)
+				return nil
+			}
+		}
+	}
+}
+
+// addReceiver registers inbox for messages to app
+func (r *Router) addReceiver(app string, inbox chan *appsdk.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.receivers[app] = append(r.receivers[app], inbox)
+}
+
+// removeReceiver unregisters inbox
+func (r *Router) removeReceiver(app string, inbox chan *appsdk.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	receivers := r.receivers[app]
+	for i, ch := range receivers {
+		if ch == inbox {
+			receivers = append(receivers[:i], receivers[i+1:]...)
+			break
+		}
+	}
+	if len(receivers) == 0 {
+		delete(r.receivers, app)
+	} else {
+		r.receivers[app] = receivers
+	}
+}
+
+// errorMessage returns err's message, or "" for nil
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
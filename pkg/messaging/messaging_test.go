@@ -0,0 +1,203 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appapi"
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/messaging"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// network connects fake transports in memory
+type network struct {
+	mu       sync.Mutex
+	handlers map[string]types.StreamHandler
+}
+
+// pipeStream adapts a net.Conn to types.Stream
+type pipeStream struct{ net.Conn }
+
+func (s pipeStream) Reset() error { return s.Close() }
+
+// transport is one node on a network
+type transport struct {
+	id  string
+	net *network
+}
+
+func (t *transport) ID() string { return t.id }
+
+func (t *transport) NewStream(ctx context.Context, peerID string, protocolID string) (types.Stream, error) {
+	t.net.mu.Lock()
+	handler := t.net.handlers[peerID]
+	t.net.mu.Unlock()
+	if handler == nil {
+		return nil, errors.New("unknown peer")
+	}
+
+	client, server := net.Pipe()
+	go handler(pipeStream{server})
+	return pipeStream{client}, nil
+}
+
+func (t *transport) SetStreamHandler(protocolID string, handler types.StreamHandler) {
+	t.net.mu.Lock()
+	defer t.net.mu.Unlock()
+	t.net.handlers[t.id] = handler
+}
+
+// node is a daemon serving the app API with messaging
+type node struct {
+	api *appapi.Server
+}
+
+func newNode(t *testing.T, n *network, id string) *node {
+	t.Helper()
+	tr := &transport{id: id, net: n}
+	router := messaging.New(tr, logging.Nop())
+	tr.SetStreamHandler(consts.MessagingProtocolID, router.HandleStream)
+	api := appapi.New(appapi.Node{ID: id}, logging.Nop())
+	router.Register(api)
+	return &node{api: api}
+}
+
+// open starts serving an app named name and returns its client
+func (n *node) open(t *testing.T, name string) *appsdk.Client {
+	t.Helper()
+
+	app := types.NewApplication(types.AppSpec{ID: name + "-1.0.0", Name: name, Version: "1.0.0", WorkDir: t.TempDir()})
+	env, err := n.api.Open(context.Background(), app)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { n.api.Close(app) })
+
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, appsdk.EnvSocket+"="); ok {
+			return appsdk.NewClient(value)
+		}
+	}
+	t.Fatalf("Open() env %v does not name the socket", env)
+	return nil
+}
+
+func TestSendAcrossNodes(t *testing.T) {
+	n := &network{handlers: make(map[string]types.StreamHandler)}
+	a := newNode(t, n, "node-a")
+	b := newNode(t, n, "node-b")
+
+	sender := a.open(t, "ping")
+	receiver := b.open(t, "pong")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	messages, err := receiver.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+
+	if err := sender.Send(ctx, "node-b", "pong", []byte("hello")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	select {
+	case msg := <-messages:
+		if msg.FromApp != "ping" || string(msg.Data) != "hello" {
+			t.Errorf("message = %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("no message received")
+	}
+}
+
+func TestSendLocal(t *testing.T) {
+	n := &network{handlers: make(map[string]types.StreamHandler)}
+	a := newNode(t, n, "node-a")
+
+	sender := a.open(t, "ping")
+	receiver := a.open(t, "pong")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	messages, err := receiver.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if err := sender.Send(ctx, "", "pong", []byte("hi")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	select {
+	case msg := <-messages:
+		if msg.FromNode != "node-a" || msg.FromApp != "ping" || string(msg.Data) != "hi" {
+			t.Errorf("message = %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("no message received")
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	n := &network{handlers: make(map[string]types.StreamHandler)}
+	a := newNode(t, n, "node-a")
+	newNode(t, n, "node-b")
+	sender := a.open(t, "ping")
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		node string
+		app  string
+		data []byte
+		want error
+	}{
+		{"no receiver", "node-b", "pong", []byte("x"), types.ErrNotFound},
+		{"no app", "node-b", "", []byte("x"), types.ErrInvalidInput},
+		{"too large", "node-b", "pong", make([]byte, messaging.MaxDataSize+1), types.ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := sender.Send(ctx, tt.node, tt.app, tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Send() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReceiverHangUp(t *testing.T) {
+	n := &network{handlers: make(map[string]types.StreamHandler)}
+	a := newNode(t, n, "node-a")
+	sender := a.open(t, "ping")
+	receiver := a.open(t, "pong")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := receiver.Receive(ctx); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	cancel()
+
+	// The daemon drops the receiver once the app hangs up
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := sender.Send(context.Background(), "", "pong", []byte("x"))
+		if errors.Is(err, types.ErrNotFound) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Send() after hang up error = %v, want ErrNotFound", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Metrics counts the streams handled for each protocol. A nil Metrics counts
+// nothing.
+type Metrics struct {
+	mu     sync.Mutex
+	counts map[string]*counts
+}
+
+// counts are the counters of one protocol
+type counts struct {
+	streams  uint64
+	rejected uint64
+	panics   uint64
+	total    time.Duration
+}
+
+// NewMetrics creates empty metrics
+func NewMetrics() *Metrics {
+	return &Metrics{counts: make(map[string]*counts)}
+}
+
+// Middleware counts the streams of a protocol and the time spent handling them
+func (m *Metrics) Middleware() Middleware {
+	return func(protocol string, next types.StreamHandler) types.StreamHandler {
+		return func(stream types.Stream) {
+			start := time.Now()
+			defer func() {
+				m.add(protocol, func(c *counts) {
+					c.streams++
+					c.total += time.Since(start)
+				})
+			}()
+			next(stream)
+		}
+	}
+}
+
+// Snapshot returns the counters of each protocol, sorted by protocol
+func (m *Metrics) Snapshot() []*types.ProtocolStats {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]*types.ProtocolStats, 0, len(m.counts))
+	for protocol, c := range m.counts {
+		s := &types.ProtocolStats{
+			Protocol: protocol,
+			Streams:  c.streams,
+			Rejected: c.rejected,
+			Panics:   c.panics,
+		}
+		if c.streams > 0 {
+			s.AvgMillis = float64(c.total.Microseconds()) / float64(c.streams) / 1000
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Protocol < stats[j].Protocol })
+	return stats
+}
+
+// rejected counts a stream a guard rejected
+func (m *Metrics) rejected(protocol string) {
+	m.add(protocol, func(c *counts) { c.rejected++ })
+}
+
+// panicked counts a stream whose handler panicked
+func (m *Metrics) panicked(protocol string) {
+	m.add(protocol, func(c *counts) { c.panics++ })
+}
+
+// add updates the counters of protocol
+func (m *Metrics) add(protocol string, update func(*counts)) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.counts[protocol]
+	if !ok {
+		c = &counts{}
+		m.counts[protocol] = c
+	}
+	update(c)
+}
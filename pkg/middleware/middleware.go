@@ -0,0 +1,99 @@
+// Package middleware wraps the stream handlers of the daemon protocols with
+// the concerns they all share: recovering from panics, logging, metrics and
+// guards such as rate limiting, so a new protocol gets them by registering
+// its handler through a chain.
+package middleware
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// Middleware wraps the handler of a protocol
+type Middleware func(protocol string, next types.StreamHandler) types.StreamHandler
+
+// Chain combines middlewares into one. The first is the outermost, so it
+// sees a stream first and returns last.
+func Chain(mws ...Middleware) Middleware {
+	return func(protocol string, next types.StreamHandler) types.StreamHandler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](protocol, next)
+		}
+		return next
+	}
+}
+
+// Recover turns a panic in a handler into a crash report (see package crash),
+// and resets the stream so the peer sees the request fail. Without it a panic
+// takes the whole daemon down. Panics are counted in metrics, which may be nil.
+func Recover(logger types.Logger, metrics *Metrics) Middleware {
+	return func(protocol string, next types.StreamHandler) types.StreamHandler {
+		return func(stream types.Stream) {
+			defer func() {
+				if r := recover(); r != nil {
+					metrics.panicked(protocol)
+					crash.Report(logger.With("peer", p2p.RemotePeer(stream)), "handler "+protocol, r, debug.Stack())
+					_ = stream.Reset()
+				}
+			}()
+			next(stream)
+		}
+	}
+}
+
+// Log logs each stream with the peer that opened it and how long it was handled
+func Log(logger types.Logger) Middleware {
+	return func(protocol string, next types.StreamHandler) types.StreamHandler {
+		return func(stream types.Stream) {
+			start := time.Now()
+			peer := p2p.RemotePeer(stream)
+			logger.Debug("stream opened", "protocol", protocol, "peer", peer)
+			defer func() {
+				logger.Debug("stream closed", "protocol", protocol, "peer", peer, "duration", time.Since(start))
+			}()
+			next(stream)
+		}
+	}
+}
+
+// Check decides whether a peer may open a stream of a protocol. A non-nil
+// error rejects the stream and is returned to the peer.
+type Check func(protocol string, peer string) error
+
+// Guard rejects the streams check refuses. They are answered with an error
+// response, so the peer gets the reason as it would from the handler.
+// Rejections are counted in metrics, which may be nil.
+func Guard(logger types.Logger, check Check, metrics *Metrics) Middleware {
+	return func(protocol string, next types.StreamHandler) types.StreamHandler {
+		return func(stream types.Stream) {
+			peer := p2p.RemotePeer(stream)
+			if err := check(protocol, peer); err != nil {
+				metrics.rejected(protocol)
+				logger.Warn("stream rejected", "protocol", protocol, "peer", peer, "error", err)
+				reject(stream, err)
+				return
+			}
+			next(stream)
+		}
+	}
+}
+
+// rejection is the part every protocol response has in common
+type rejection struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+	Code    string `json:"code"`
+}
+
+// reject answers the request on stream with err and closes it. Not every
+// protocol starts with a request header, so nothing is read first.
+func reject(stream types.Stream, err error) {
+	defer func() { _ = stream.Close() }()
+
+	_ = wire.WriteMessage(stream, rejection{Error: err.Error(), Code: types.ErrorCode(err)})
+}
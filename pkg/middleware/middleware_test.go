@@ -0,0 +1,132 @@
+package middleware_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/middleware"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// fakeStream records what is written to it
+type fakeStream struct {
+	out    bytes.Buffer
+	closed bool
+	reset  bool
+}
+
+func (s *fakeStream) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (s *fakeStream) Write(p []byte) (int, error) { return s.out.Write(p) }
+func (s *fakeStream) Close() error                { s.closed = true; return nil }
+func (s *fakeStream) Reset() error                { s.reset = true; return nil }
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	mw := func(name string) middleware.Middleware {
+		return func(protocol string, next types.StreamHandler) types.StreamHandler {
+			return func(s types.Stream) {
+				calls = append(calls, name+":"+protocol)
+				next(s)
+			}
+		}
+	}
+
+	handler := middleware.Chain(mw("a"), mw("b"))("/test", func(types.Stream) {
+		calls = append(calls, "handler")
+	})
+	handler(&fakeStream{})
+
+	if got, want := strings.Join(calls, ","), "a:/test,b:/test,handler"; got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	metrics := middleware.NewMetrics()
+	handler := middleware.Chain(
+		middleware.Recover(logging.NewNopLogger(), metrics),
+		metrics.Middleware(),
+	)("/test", func(types.Stream) {
+		panic("boom")
+	})
+
+	stream := &fakeStream{}
+	handler(stream)
+
+	if !stream.reset {
+		t.Error("stream was not reset after the panic")
+	}
+	stats := metrics.Snapshot()
+	if len(stats) != 1 || stats[0].Protocol != "/test" || stats[0].Streams != 1 || stats[0].Panics != 1 {
+		t.Errorf("Snapshot() = %+v, want one stream that panicked", stats)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	metrics := middleware.NewMetrics()
+	deny := func(protocol string, peer string) error {
+		return fmt.Errorf("%w: go away", types.ErrRateLimited)
+	}
+	called := false
+	handler := middleware.Chain(
+		metrics.Middleware(),
+		middleware.Guard(logging.NewNopLogger(), deny, metrics),
+	)("/test", func(types.Stream) {
+		called = true
+	})
+
+	stream := &fakeStream{}
+	handler(stream)
+
+	if called {
+		t.Error("handler called for a rejected stream")
+	}
+	if !stream.closed {
+		t.Error("rejected stream was not closed")
+	}
+
+	var resp struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Code    string `json:"code"`
+	}
+	if err := wire.ReadMessage(&stream.out, &resp); err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	if resp.Success || resp.Code != types.CodeRateLimited || !strings.Contains(resp.Error, "go away") {
+		t.Errorf("response = %+v, want a RATE_LIMITED failure", resp)
+	}
+
+	stats := metrics.Snapshot()
+	if len(stats) != 1 || stats[0].Streams != 1 || stats[0].Rejected != 1 {
+		t.Errorf("Snapshot() = %+v, want one rejected stream", stats)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := middleware.NewRateLimiter(0.001, 2)
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.Check("/test", "peer-a"); err != nil {
+			t.Fatalf("Check() %d error = %v, want within burst", i, err)
+		}
+	}
+	if err := limiter.Check("/test", "peer-a"); !errors.Is(err, types.ErrRateLimited) {
+		t.Errorf("Check() over burst error = %v, want ErrRateLimited", err)
+	}
+	if err := limiter.Check("/other", "peer-b"); err != nil {
+		t.Errorf("Check() for another peer error = %v, want nil", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := limiter.Check("/test", p2p.LocalPeer); err != nil {
+			t.Fatalf("Check() for the local peer error = %v, want nil", err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"golang.org/x/time/rate"
+)
+
+// DefaultBurst is the number of streams a peer may open at once when no
+// burst is configured
+const DefaultBurst = 20
+
+// idleLimiter is how long a peer stays unseen before its limiter is dropped
+const idleLimiter = 10 * time.Minute
+
+// RateLimiter limits the streams each peer may open, across all protocols.
+// Peers on the local socket are not limited.
+type RateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	peers     map[string]*peerLimiter
+	lastPrune time.Time
+}
+
+// peerLimiter is the token bucket of one peer
+type peerLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a limiter letting each peer open perSecond streams
+// a second on average, and burst (DefaultBurst if 0 or less) at once
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst <= 0 {
+		burst = DefaultBurst
+	}
+	return &RateLimiter{
+		limit: rate.Limit(perSecond),
+		burst: burst,
+		peers: make(map[string]*peerLimiter),
+	}
+}
+
+// Check implements Check, failing with ErrRateLimited when peer opened too
+// many streams
+func (r *RateLimiter) Check(protocol string, peer string) error {
+	if peer == p2p.LocalPeer {
+		return nil
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.lastPrune) > idleLimiter {
+		for id, p := range r.peers {
+			if now.Sub(p.lastSeen) > idleLimiter {
+				delete(r.peers, id)
+			}
+		}
+		r.lastPrune = now
+	}
+
+	p, ok := r.peers[peer]
+	if !ok {
+		p = &peerLimiter{limiter: rate.NewLimiter(r.limit, r.burst)}
+		r.peers[peer] = p
+	}
+	p.lastSeen = now
+	if !p.limiter.AllowN(now, 1) {
+		return fmt.Errorf("%w: too many requests, at most %g a second are accepted", types.ErrRateLimited, float64(r.limit))
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package netpolicy
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DefaultSubnet is the address range for app namespaces
+const DefaultSubnet = "10.213.0.0/16"
+
+// netnsDir is where "ip netns" mounts named namespaces
+const netnsDir = "/run/netns"
+
+// Manager creates network namespaces and nftables rules enforcing app network policies.
+//
+// An app whose manifest denies egress runs in its own network namespace. Without
+// allow rules the namespace only has a loopback interface. With allow rules it is
+// connected to the host through a veth pair, its traffic is masqueraded, and a
+// per-app nftables table drops everything not explicitly allowed.
+type Manager struct {
+	subnet *net.IPNet
+	logger types.Logger
+	mu     sync.Mutex
+	slots  map[string]int
+}
+
+// New creates a manager allocating /30 networks from subnet
+func New(subnet string, logger types.Logger) (*Manager, error) {
+	if subnet == "" {
+		subnet = DefaultSubnet
+	}
+
+	ip, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("%w: invalid IPv4 network subnet %q", types.ErrInvalidInput, subnet)
+	}
+	if ones, _ := ipNet.Mask.Size(); ones > 30 {
+		return nil, fmt.Errorf("%w: network subnet %s is too small", types.ErrInvalidInput, subnet)
+	}
+
+	return &Manager{
+		subnet: ipNet,
+		logger: logger,
+		slots:  make(map[string]int),
+	}, nil
+}
+
+// Setup creates the network namespace for app and returns its path,
+// or "" if the app's network is unrestricted
+func (m *Manager) Setup(ctx context.Context, app *types.Application) (string, error) {
+	policy := app.Manifest.Network
+	if policy == nil || policy.Egress != types.EgressDeny {
+		return "", nil
+	}
+
+	// Remove leftovers from a previous run of the same app
+	m.Teardown(app)
+
+	ns := namespaceName(app.ID)
+	if err := run(ctx, nil, "ip", "netns", "add", ns); err != nil {
+		return "", err
+	}
+	if err := run(ctx, nil, "ip", "-n", ns, "link", "set", "lo", "up"); err != nil {
+		m.Teardown(app)
+		return "", err
+	}
+
+	if len(policy.Allow) > 0 {
+		if err := m.connect(ctx, app, ns, policy.Allow); err != nil {
+			m.Teardown(app)
+			return "", err
+		}
+	}
+
+	m.logger.Info("network namespace created",
+		"app_id", app.ID,
+		"namespace", ns,
+		"allow_rules", len(policy.Allow),
+	)
+	return filepath.Join(netnsDir, ns), nil
+}
+
+// connect links the namespace to the host and installs the app's nftables table
+func (m *Manager) connect(ctx context.Context, app *types.Application, ns string, rules []types.EgressRule) error {
+	slot, err := m.allocate(app.ID)
+	if err != nil {
+		return err
+	}
+
+	hostIP, appIP := m.addresses(slot)
+	hostIf, appIf := interfaceNames(slot)
+
+	commands := [][]string{
+		{"ip", "link", "add", hostIf, "type", "veth", "peer", "name", appIf, "netns", ns},
+		{"ip", "addr", "add", hostIP + "/30", "dev", hostIf},
+		{"ip", "link", "set", hostIf, "up"},
+		{"ip", "-n", ns, "addr", "add", appIP + "/30", "dev", appIf},
+		{"ip", "-n", ns, "link", "set", appIf, "up"},
+		{"ip", "-n", ns, "route", "add", "default", "via", hostIP},
+	}
+	for _, c := range commands {
+		if err := run(ctx, nil, c[0], c[1:]...); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile("/proc/sys/net/ipv4/ip_forward", []byte("1"), 0644); err != nil {
+		return types.WrapError(err, "failed to enable IP forwarding")
+	}
+
+	ruleset := Ruleset(tableName(slot), hostIf, appIP, rules)
+	if err := run(ctx, strings.NewReader(ruleset), "nft", "-f", "-"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Teardown removes the namespace, interfaces and rules of app. Missing pieces are ignored.
+func (m *Manager) Teardown(app *types.Application) {
+	ctx := context.Background()
+
+	m.mu.Lock()
+	slot, ok := m.slots[app.ID]
+	delete(m.slots, app.ID)
+	m.mu.Unlock()
+
+	if ok {
+		hostIf, _ := interfaceNames(slot)
+		_ = run(ctx, nil, "nft", "delete", "table", "inet", tableName(slot))
+		_ = run(ctx, nil, "ip", "link", "del", hostIf)
+	}
+
+	ns := namespaceName(app.ID)
+	if _, err := os.Stat(filepath.Join(netnsDir, ns)); err == nil {
+		if err := run(ctx, nil, "ip", "netns", "del", ns); err != nil {
+			m.logger.Warn("failed to remove network namespace", "app_id", app.ID, "error", err)
+		}
+	}
+}
+
+// allocate assigns the lowest free /30 slot to appID
+func (m *Manager) allocate(appID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	used := make(map[int]bool, len(m.slots))
+	for _, slot := range m.slots {
+		used[slot] = true
+	}
+
+	ones, bits := m.subnet.Mask.Size()
+	capacity := (1 << (bits - ones)) / 4
+	for slot := 0; slot < capacity; slot++ {
+		if !used[slot] {
+			m.slots[appID] = slot
+			return slot, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: no free addresses in network subnet %s", types.ErrUnavailable, m.subnet)
+}
+
+// addresses returns the host and app addresses of a slot
+func (m *Manager) addresses(slot int) (hostIP, appIP string) {
+	base := binary.BigEndian.Uint32(m.subnet.IP.To4()) + uint32(slot)*4
+
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, base+1)
+	hostIP = ip.String()
+	binary.BigEndian.PutUint32(ip, base+2)
+	appIP = ip.String()
+
+	return hostIP, appIP
+}
+
+// Ruleset returns the nftables table enforcing rules for traffic leaving an app
+// namespace through hostIf. Traffic to the host itself and to other networks is
+// filtered alike; replies to connections made into the app are allowed.
+func Ruleset(table, hostIf, appIP string, rules []types.EgressRule) string {
+	var filter strings.Builder
+	fmt.Fprintf(&filter, "\t\tiifname %q ct state established,related accept\n", hostIf)
+	for _, rule := range rules {
+		for _, match := range ruleMatches(rule) {
+			fmt.Fprintf(&filter, "\t\tiifname %q ip daddr %s%s accept\n", hostIf, rule.CIDR, match)
+		}
+	}
+	fmt.Fprintf(&filter, "\t\tiifname %q drop\n", hostIf)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "table inet %s {\n", table)
+	fmt.Fprintf(&b, "\tchain forward {\n\t\ttype filter hook forward priority 0; policy accept;\n%s\t}\n", filter.String())
+	fmt.Fprintf(&b, "\tchain input {\n\t\ttype filter hook input priority 0; policy accept;\n%s\t}\n", filter.String())
+	fmt.Fprintf(&b, "\tchain postrouting {\n\t\ttype nat hook postrouting priority 100; policy accept;\n")
+	fmt.Fprintf(&b, "\t\tip saddr %s oifname != %q masquerade\n\t}\n", appIP, hostIf)
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// ruleMatches returns the protocol/port matches for a rule, one per nftables rule
+func ruleMatches(rule types.EgressRule) []string {
+	protocols := []string{rule.Protocol}
+	if rule.Protocol == "" && len(rule.Ports) > 0 {
+		protocols = []string{"tcp", "udp"}
+	}
+
+	ports := make([]string, len(rule.Ports))
+	for i, port := range rule.Ports {
+		ports[i] = fmt.Sprint(port)
+	}
+
+	var matches []string
+	for _, proto := range protocols {
+		switch {
+		case proto == "":
+			matches = append(matches, "")
+		case len(ports) == 0:
+			matches = append(matches, " meta l4proto "+proto)
+		default:
+			matches = append(matches, fmt.Sprintf(" %s dport { %s }", proto, strings.Join(ports, ", ")))
+		}
+	}
+	return matches
+}
+
+// namespaceName returns the network namespace name for an app
+func namespaceName(appID string) string {
+	return "p2p-" + strings.ReplaceAll(appID, string(filepath.Separator), "_")
+}
+
+// interfaceNames returns the host and namespace veth names for a slot
+func interfaceNames(slot int) (hostIf, appIf string) {
+	return fmt.Sprintf("p2ph%d", slot), fmt.Sprintf("p2pa%d", slot)
+}
+
+// tableName returns the nftables table name for a slot
+func tableName(slot int) string {
+	return fmt.Sprintf("p2p_app_%d", slot)
+}
+
+// run executes a networking command, returning its output on failure
+func run(ctx context.Context, stdin *strings.Reader, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
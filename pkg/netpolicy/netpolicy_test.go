@@ -0,0 +1,79 @@
+package netpolicy_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/netpolicy"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestNewValidatesSubnet(t *testing.T) {
+	tests := []struct {
+		subnet  string
+		wantErr bool
+	}{
+		{subnet: ""},
+		{subnet: "10.213.0.0/16"},
+		{subnet: "192.168.50.0/30"},
+		{subnet: "192.168.50.0/31", wantErr: true},
+		{subnet: "fd00::/64", wantErr: true},
+		{subnet: "not-a-subnet", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := netpolicy.New(tt.subnet, logging.Nop())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(%q) error = %v, wantErr %v", tt.subnet, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSetupSkipsUnrestrictedApps(t *testing.T) {
+	m, err := netpolicy.New("", logging.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []*types.NetworkPolicy{nil, {Egress: types.EgressAllow}} {
+		app := &types.Application{AppSpec: types.AppSpec{ID: "web-1.0.0", Manifest: &types.Manifest{Network: policy}}}
+		path, err := m.Setup(context.Background(), app)
+		if err != nil || path != "" {
+			t.Errorf("Setup() = %q, %v; want no namespace", path, err)
+		}
+	}
+}
+
+func TestRuleset(t *testing.T) {
+	ruleset := netpolicy.Ruleset("p2p_app_0", "p2ph0", "10.213.0.2", []types.EgressRule{
+		{CIDR: "10.0.0.0/8", Ports: []int{443, 8443}, Protocol: "tcp"},
+		{CIDR: "192.0.2.53/32", Ports: []int{53}},
+		{CIDR: "198.51.100.0/24"},
+	})
+
+	want := []string{
+		"table inet p2p_app_0 {",
+		`iifname "p2ph0" ct state established,related accept`,
+		`iifname "p2ph0" ip daddr 10.0.0.0/8 tcp dport { 443, 8443 } accept`,
+		`iifname "p2ph0" ip daddr 192.0.2.53/32 tcp dport { 53 } accept`,
+		`iifname "p2ph0" ip daddr 192.0.2.53/32 udp dport { 53 } accept`,
+		`iifname "p2ph0" ip daddr 198.51.100.0/24 accept`,
+		`iifname "p2ph0" drop`,
+		"type filter hook forward priority 0",
+		"type filter hook input priority 0",
+		`ip saddr 10.213.0.2 oifname != "p2ph0" masquerade`,
+	}
+	for _, w := range want {
+		if !strings.Contains(ruleset, w) {
+			t.Errorf("ruleset missing %q:\n%s", w, ruleset)
+		}
+	}
+
+	// The drop rule must come after every accept rule in each chain
+	forward := ruleset[strings.Index(ruleset, "chain forward"):strings.Index(ruleset, "chain input")]
+	if strings.LastIndex(forward, "accept") > strings.Index(forward, "drop") {
+		t.Errorf("drop rule precedes accept rules:\n%s", forward)
+	}
+}
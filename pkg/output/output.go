@@ -0,0 +1,157 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Format is the rendering format for command results
+type Format string
+
+const (
+	// FormatText renders results as human-readable text
+	FormatText Format = "text"
+
+	// FormatJSON renders results as JSON documents
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses an output format string
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "", "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("invalid output format: %s (expected text or json)", s)
+	}
+}
+
+// Reporter separates human-facing command output from logging.
+//
+// Results are written to stdout, either as text or as JSON depending on the
+// format. Progress and status messages are written to stderr so that stdout
+// stays clean for piping (e.g. `controller list --output json | jq`).
+type Reporter struct {
+	format Format
+	stdout io.Writer
+	stderr io.Writer
+	mu     sync.Mutex
+}
+
+// New creates a new reporter writing results to stdout and status to stderr
+func New(format Format, stdout, stderr io.Writer) *Reporter {
+	if format == "" {
+		format = FormatText
+	}
+	return &Reporter{
+		format: format,
+		stdout: stdout,
+		stderr: stderr,
+	}
+}
+
+// NewDefault creates a text reporter bound to the process stdout and stderr
+func NewDefault() *Reporter {
+	return New(FormatText, os.Stdout, os.Stderr)
+}
+
+// Format returns the reporter's output format
+func (r *Reporter) Format() Format {
+	return r.format
+}
+
+// IsJSON returns true if results are rendered as JSON
+func (r *Reporter) IsJSON() bool {
+	return r.format == FormatJSON
+}
+
+// Statusf writes a progress or status message to stderr
+func (r *Reporter) Statusf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = fmt.Fprintf(r.stderr, format, args...)
+}
+
+// Statusln writes a progress or status line to stderr
+func (r *Reporter) Statusln(args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = fmt.Fprintln(r.stderr, args...)
+}
+
+// Printf writes human-readable result text to stdout (suppressed in JSON mode)
+func (r *Reporter) Printf(format string, args ...interface{}) {
+	if r.IsJSON() {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = fmt.Fprintf(r.stdout, format, args...)
+}
+
+// Println writes a human-readable result line to stdout (suppressed in JSON mode)
+func (r *Reporter) Println(args ...interface{}) {
+	if r.IsJSON() {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = fmt.Fprintln(r.stdout, args...)
+}
+
+// Result emits a command result.
+// In JSON mode v is encoded to stdout; in text mode render is called instead.
+func (r *Reporter) Result(v interface{}, render func()) error {
+	if !r.IsJSON() {
+		if render != nil {
+			render()
+		}
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	encoder := json.NewEncoder(r.stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	return nil
+}
+
+// Record emits one item of a streamed result.
+// In JSON mode v is encoded as a single line (NDJSON); in text mode render is called instead.
+func (r *Reporter) Record(v interface{}, render func()) error {
+	if !r.IsJSON() {
+		if render != nil {
+			render()
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = fmt.Fprintf(r.stdout, "%s\n", data)
+	return err
+}
+
+// Stdout returns the writer used for results
+func (r *Reporter) Stdout() io.Writer {
+	return r.stdout
+}
+
+// Stderr returns the writer used for status messages
+func (r *Reporter) Stderr() io.Writer {
+	return r.stderr
+}
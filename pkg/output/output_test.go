@@ -0,0 +1,88 @@
+package output_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/output"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    output.Format
+		wantErr bool
+	}{
+		{"", output.FormatText, false},
+		{"text", output.FormatText, false},
+		{"json", output.FormatJSON, false},
+		{"yaml", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := output.ParseFormat(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("format = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReporterTextMode(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	r := output.New(output.FormatText, &stdout, &stderr)
+
+	r.Statusln("Discovering nodes...")
+	if err := r.Result(map[string]string{"app_id": "demo"}, func() {
+		r.Printf("Application ID: %s\n", "demo")
+	}); err != nil {
+		t.Fatalf("result failed: %v", err)
+	}
+
+	if !strings.Contains(stderr.String(), "Discovering nodes") {
+		t.Errorf("expected status on stderr, got: %q", stderr.String())
+	}
+	if strings.Contains(stdout.String(), "Discovering") {
+		t.Errorf("status leaked into stdout: %q", stdout.String())
+	}
+	if stdout.String() != "Application ID: demo\n" {
+		t.Errorf("stdout = %q, want text result", stdout.String())
+	}
+}
+
+func TestReporterJSONMode(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	r := output.New(output.FormatJSON, &stdout, &stderr)
+
+	r.Printf("human text should be suppressed\n")
+	if err := r.Result(map[string]string{"app_id": "demo"}, func() {
+		t.Error("text renderer must not be called in JSON mode")
+	}); err != nil {
+		t.Fatalf("result failed: %v", err)
+	}
+
+	if strings.Contains(stdout.String(), "human text") {
+		t.Errorf("text output leaked into JSON stdout: %q", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), `"app_id": "demo"`) {
+		t.Errorf("expected JSON result, got: %q", stdout.String())
+	}
+}
+
+func TestReporterRecord(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	r := output.New(output.FormatJSON, &stdout, &stderr)
+
+	_ = r.Record(map[string]string{"line": "one"}, nil)
+	_ = r.Record(map[string]string{"line": "two"}, nil)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 NDJSON lines, got %d: %q", len(lines), stdout.String())
+	}
+}
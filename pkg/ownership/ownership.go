@@ -0,0 +1,241 @@
+package ownership
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// StorageKey is the storage key holding ownership records
+const StorageKey = "ownership.json"
+
+// document is the stored document of ownership records
+type document struct {
+	Records map[string]*Record `json:"records"`
+}
+
+// schema versions the stored records
+var schema = storage.Schema{
+	Kind: "ownership records",
+	Migrations: []storage.Migration{
+		storage.NestUnder("records"), // 1: the records moved under records, next to the schema version
+	},
+}
+
+// Record binds an application name to the key that first signed it
+type Record struct {
+	// App is the application name
+	App string `json:"app"`
+
+	// KeyName is the name of the owning key (informational)
+	KeyName string `json:"key_name,omitempty"`
+
+	// PublicKey is the hex-encoded Ed25519 public key of the owner
+	PublicKey string `json:"public_key"`
+
+	// ClaimedAt is when the key first deployed the application
+	ClaimedAt time.Time `json:"claimed_at"`
+
+	// TransferredAt is set when an admin transferred ownership
+	TransferredAt time.Time `json:"transferred_at,omitempty"`
+
+	// PreviousPublicKey is the owner before the last transfer
+	PreviousPublicKey string `json:"previous_public_key,omitempty"`
+}
+
+// Store persists ownership records (trust on first use).
+// Records are re-read on every operation so that changes made by an admin
+// with the daemon CLI take effect without restarting the daemon.
+type Store struct {
+	storage types.Storage
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+// NewStore creates an ownership store on top of storage
+func NewStore(storage types.Storage) *Store {
+	return &Store{
+		storage: storage,
+		now:     time.Now,
+	}
+}
+
+// Verify checks that publicKey may deploy app. A nil key means the package is unsigned.
+// Unowned applications are always allowed; owned ones require the owner's key.
+func (s *Store) Verify(ctx context.Context, app string, publicKey []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	rec, ok := records[app]
+	if !ok {
+		return nil
+	}
+
+	if len(publicKey) == 0 {
+		return fmt.Errorf("%w: application %q is owned by key %s and requires a signed package",
+			types.ErrOwnershipConflict, app, rec.displayKey())
+	}
+	if rec.PublicKey != hex.EncodeToString(publicKey) {
+		return fmt.Errorf("%w: application %q is owned by key %s; ask an admin to transfer ownership",
+			types.ErrOwnershipConflict, app, rec.displayKey())
+	}
+
+	return nil
+}
+
+// Claim records publicKey as the owner of app if it has no owner yet
+func (s *Store) Claim(ctx context.Context, app string, keyName string, publicKey []byte) (bool, error) {
+	if len(publicKey) == 0 {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := records[app]; ok {
+		return false, nil
+	}
+
+	records[app] = &Record{
+		App:       app,
+		KeyName:   keyName,
+		PublicKey: hex.EncodeToString(publicKey),
+		ClaimedAt: s.now().UTC(),
+	}
+
+	return true, s.save(ctx, records)
+}
+
+// Transfer assigns app to a new key. This is an admin operation.
+func (s *Store) Transfer(ctx context.Context, app string, keyName string, publicKey []byte) error {
+	if len(publicKey) == 0 {
+		return fmt.Errorf("%w: public key is required", types.ErrInvalidInput)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := s.now().UTC()
+	rec := &Record{
+		App:       app,
+		KeyName:   keyName,
+		PublicKey: hex.EncodeToString(publicKey),
+		ClaimedAt: now,
+	}
+	if prev, ok := records[app]; ok {
+		rec.ClaimedAt = prev.ClaimedAt
+		rec.TransferredAt = now
+		rec.PreviousPublicKey = prev.PublicKey
+	}
+	records[app] = rec
+
+	return s.save(ctx, records)
+}
+
+// Release removes the owner of app so that the next signed deployment claims it
+func (s *Store) Release(ctx context.Context, app string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := records[app]; !ok {
+		return fmt.Errorf("application %q has no owner: %w", app, types.ErrNotFound)
+	}
+	delete(records, app)
+
+	return s.save(ctx, records)
+}
+
+// List returns all records sorted by application name
+func (s *Store) List(ctx context.Context) ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Record, 0, len(records))
+	for _, rec := range records {
+		list = append(list, rec)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].App < list[j].App
+	})
+
+	return list, nil
+}
+
+// load reads all records. Callers must hold s.mu.
+func (s *Store) load(ctx context.Context) (map[string]*Record, error) {
+	data, err := s.storage.Load(ctx, StorageKey)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return make(map[string]*Record), nil
+		}
+		return nil, types.WrapError(err, "failed to load ownership records")
+	}
+
+	var doc document
+	if err := schema.Unmarshal(data, &doc); err != nil {
+		return nil, types.WrapError(err, "failed to parse ownership records")
+	}
+	if doc.Records == nil {
+		doc.Records = make(map[string]*Record)
+	}
+
+	return doc.Records, nil
+}
+
+// save writes all records. Callers must hold s.mu.
+func (s *Store) save(ctx context.Context, records map[string]*Record) error {
+	data, err := schema.MarshalIndent(&document{Records: records}, "", "  ")
+	if err != nil {
+		return types.WrapError(err, "failed to marshal ownership records")
+	}
+
+	if err := s.storage.Save(ctx, StorageKey, data); err != nil {
+		return types.WrapError(err, "failed to save ownership records")
+	}
+
+	return nil
+}
+
+// displayKey returns the key name if known, otherwise a short fingerprint
+func (r *Record) displayKey() string {
+	fingerprint := r.PublicKey
+	if len(fingerprint) > 16 {
+		fingerprint = fingerprint[:16]
+	}
+	if r.KeyName != "" {
+		return fmt.Sprintf("%q (%s)", r.KeyName, fingerprint)
+	}
+	return fingerprint
+}
@@ -0,0 +1,123 @@
+package ownership_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/ownership"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func newStore(t *testing.T) *ownership.Store {
+	t.Helper()
+	st, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	return ownership.NewStore(st)
+}
+
+func TestTrustOnFirstUse(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := []byte("alice-public-key-0000000000000000")
+	mallory := []byte("mallory-public-key-00000000000000")
+
+	// Unowned apps may be deployed by anyone
+	if err := store.Verify(ctx, "web", mallory); err != nil {
+		t.Fatalf("unowned app rejected: %v", err)
+	}
+
+	claimed, err := store.Claim(ctx, "web", "alice", alice)
+	if err != nil || !claimed {
+		t.Fatalf("Claim() = %v, %v; want true, nil", claimed, err)
+	}
+
+	// A second claim does not change the owner
+	if claimed, _ := store.Claim(ctx, "web", "mallory", mallory); claimed {
+		t.Error("second claim must not take ownership")
+	}
+
+	if err := store.Verify(ctx, "web", alice); err != nil {
+		t.Errorf("owner rejected: %v", err)
+	}
+	if err := store.Verify(ctx, "web", mallory); !errors.Is(err, types.ErrOwnershipConflict) {
+		t.Errorf("expected ErrOwnershipConflict for other key, got: %v", err)
+	}
+	if err := store.Verify(ctx, "web", nil); !errors.Is(err, types.ErrOwnershipConflict) {
+		t.Errorf("expected ErrOwnershipConflict for unsigned package, got: %v", err)
+	}
+}
+
+func TestTransferAndRelease(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := []byte("alice-public-key-0000000000000000")
+	bob := []byte("bob-public-key-00000000000000000")
+
+	if _, err := store.Claim(ctx, "web", "alice", alice); err != nil {
+		t.Fatalf("claim failed: %v", err)
+	}
+
+	if err := store.Transfer(ctx, "web", "bob", bob); err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if err := store.Verify(ctx, "web", bob); err != nil {
+		t.Errorf("new owner rejected: %v", err)
+	}
+	if err := store.Verify(ctx, "web", alice); err == nil {
+		t.Error("previous owner must be rejected after transfer")
+	}
+
+	records, err := store.List(ctx)
+	if err != nil || len(records) != 1 || records[0].PreviousPublicKey == "" {
+		t.Fatalf("unexpected records after transfer: %+v, %v", records, err)
+	}
+
+	if err := store.Release(ctx, "web"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := store.Verify(ctx, "web", alice); err != nil {
+		t.Errorf("released app rejected: %v", err)
+	}
+	if err := store.Release(ctx, "web"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("expected ErrNotFound releasing unowned app, got: %v", err)
+	}
+}
+
+func TestLegacyRecords(t *testing.T) {
+	ctx := context.Background()
+	st, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	// Records written before they carried a schema version
+	legacy := `{"web": {"app": "web", "key_name": "alice", "public_key": "616c696365"}}`
+	if err := st.Save(ctx, ownership.StorageKey, []byte(legacy)); err != nil {
+		t.Fatal(err)
+	}
+	store := ownership.NewStore(st)
+
+	if err := store.Verify(ctx, "web", []byte("alice")); err != nil {
+		t.Fatalf("owner of legacy record rejected: %v", err)
+	}
+	if claimed, err := store.Claim(ctx, "api", "alice", []byte("alice")); err != nil || !claimed {
+		t.Fatalf("Claim() = %v, %v; want true, nil", claimed, err)
+	}
+	data, err := st.Load(ctx, ownership.StorageKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := storage.DocumentVersion(data); err != nil || version != 1 {
+		t.Errorf("records saved with schema version %d, %v; want 1", version, err)
+	}
+
+	if err := st.Save(ctx, ownership.StorageKey, []byte(`{"schema_version": 99, "records": {}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.List(ctx); !errors.Is(err, types.ErrUnsupportedSchema) {
+		t.Errorf("List() error = %v, want ErrUnsupportedSchema", err)
+	}
+}
@@ -0,0 +1,99 @@
+package p2p
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Address families a node listens on and advertises
+const (
+	AddressFamilyV4   = "v4"
+	AddressFamilyV6   = "v6"
+	AddressFamilyDual = "dual"
+)
+
+// ValidateAddressFamily checks that family is empty (dual) or a known address family
+func ValidateAddressFamily(family string) error {
+	switch family {
+	case "", AddressFamilyV4, AddressFamilyV6, AddressFamilyDual:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown address family %q (want %s, %s or %s)",
+		types.ErrInvalidInput, family, AddressFamilyV4, AddressFamilyV6, AddressFamilyDual)
+}
+
+// matchesFamily reports whether addr may be used with family. Addresses
+// without an IP, such as DNS names, match every family.
+func matchesFamily(addr multiaddr.Multiaddr, family string) bool {
+	first, _ := multiaddr.SplitFirst(addr)
+	if first == nil {
+		return true
+	}
+	switch first.Protocol().Code {
+	case multiaddr.P_IP4:
+		return family != AddressFamilyV6
+	case multiaddr.P_IP6:
+		return family != AddressFamilyV4
+	}
+	return true
+}
+
+// addrRank orders addresses from most to least routable
+func addrRank(addr multiaddr.Multiaddr) int {
+	if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+		// Reachable from anywhere, but only through a relay
+		return 1
+	}
+	switch {
+	case manet.IsPublicAddr(addr):
+		return 0
+	case manet.IsIPLoopback(addr):
+		return 4
+	case manet.IsPrivateAddr(addr):
+		return 2
+	}
+	// Link-local and other unusual addresses
+	return 3
+}
+
+// FilterAddrs returns the addresses of addrs that may be used with family
+func FilterAddrs(addrs []multiaddr.Multiaddr, family string) []multiaddr.Multiaddr {
+	result := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, addr := range addrs {
+		if matchesFamily(addr, family) {
+			result = append(result, addr)
+		}
+	}
+	return result
+}
+
+// SortAddrs returns a copy of addrs with the most routable first: public,
+// relayed, private, link-local, then loopback. The order within each group is kept.
+func SortAddrs(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	sorted := append([]multiaddr.Multiaddr(nil), addrs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return addrRank(sorted[i]) < addrRank(sorted[j])
+	})
+	return sorted
+}
+
+// SortAddrStrings orders multiaddr strings like SortAddrs.
+// Unparsable addresses are kept at the end.
+func SortAddrStrings(addrs []string) []string {
+	rank := func(s string) int {
+		addr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			return 5
+		}
+		return addrRank(addr)
+	}
+	sorted := append([]string(nil), addrs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rank(sorted[i]) < rank(sorted[j])
+	})
+	return sorted
+}
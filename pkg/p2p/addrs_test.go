@@ -0,0 +1,92 @@
+package p2p_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestFilterAndSortAddrs(t *testing.T) {
+	addrs := []string{
+		"/ip4/127.0.0.1/tcp/9000",
+		"/ip6/::1/tcp/9000",
+		"/ip4/192.168.1.5/tcp/9000",
+		"/ip6/fe80::1/tcp/9000",
+		"/ip4/203.0.113.10/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ/p2p-circuit",
+		"/ip6/2606:4700::1111/tcp/9000",
+		"/ip4/8.8.8.8/tcp/9000",
+	}
+	var maddrs []multiaddr.Multiaddr
+	for _, addr := range addrs {
+		maddrs = append(maddrs, multiaddr.StringCast(addr))
+	}
+
+	tests := []struct {
+		family string
+		want   []string
+	}{
+		{
+			family: p2p.AddressFamilyDual,
+			want: []string{
+				"/ip6/2606:4700::1111/tcp/9000",
+				"/ip4/8.8.8.8/tcp/9000",
+				"/ip4/203.0.113.10/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ/p2p-circuit",
+				"/ip4/192.168.1.5/tcp/9000",
+				"/ip6/fe80::1/tcp/9000",
+				"/ip4/127.0.0.1/tcp/9000",
+				"/ip6/::1/tcp/9000",
+			},
+		},
+		{
+			family: p2p.AddressFamilyV4,
+			want: []string{
+				"/ip4/8.8.8.8/tcp/9000",
+				"/ip4/203.0.113.10/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ/p2p-circuit",
+				"/ip4/192.168.1.5/tcp/9000",
+				"/ip4/127.0.0.1/tcp/9000",
+			},
+		},
+		{
+			family: p2p.AddressFamilyV6,
+			want: []string{
+				"/ip6/2606:4700::1111/tcp/9000",
+				"/ip6/fe80::1/tcp/9000",
+				"/ip6/::1/tcp/9000",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.family, func(t *testing.T) {
+			var got []string
+			for _, addr := range p2p.SortAddrs(p2p.FilterAddrs(maddrs, tt.family)) {
+				got = append(got, addr.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FilterAddrs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortAddrStrings(t *testing.T) {
+	got := p2p.SortAddrStrings([]string{"not-an-addr", "/ip4/127.0.0.1/tcp/9000", "/ip4/10.0.0.2/tcp/9000", "/dns4/example.com/tcp/9000"})
+	want := []string{"/dns4/example.com/tcp/9000", "/ip4/10.0.0.2/tcp/9000", "/ip4/127.0.0.1/tcp/9000", "not-an-addr"}
+	if !slices.Equal(got, want) {
+		t.Errorf("SortAddrStrings() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateAddressFamily(t *testing.T) {
+	for _, family := range []string{"", p2p.AddressFamilyV4, p2p.AddressFamilyV6, p2p.AddressFamilyDual} {
+		if err := p2p.ValidateAddressFamily(family); err != nil {
+			t.Errorf("ValidateAddressFamily(%q) error = %v", family, err)
+		}
+	}
+	if err := p2p.ValidateAddressFamily("ipx"); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("ValidateAddressFamily(ipx) error = %v, want ErrInvalidInput", err)
+	}
+}
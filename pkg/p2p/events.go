@@ -0,0 +1,143 @@
+package p2p
+
+import (
+	"sync"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// peerEventQueue is the number of peer events waiting for their handlers
+// before further events are dropped
+const peerEventQueue = 256
+
+// PeerHandler is called with the ID of a peer that connected or disconnected
+type PeerHandler func(peerID string)
+
+// peerEvent is a peer that connected or disconnected
+type peerEvent struct {
+	peerID    string
+	connected bool
+}
+
+// peerEvents tracks the connections of each peer to tell the handlers when
+// a peer gets its first connection or loses its last one. Handlers run one
+// event at a time, in order, so they may block without stalling libp2p.
+type peerEvents struct {
+	logger types.Logger
+
+	mu           sync.Mutex
+	conns        map[peer.ID]int
+	connected    []PeerHandler
+	disconnected []PeerHandler
+
+	queue     chan peerEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newPeerEvents creates the peer events of net and starts running their handlers
+func newPeerEvents(net network.Network, logger types.Logger) *peerEvents {
+	e := &peerEvents{
+		logger: logger,
+		conns:  make(map[peer.ID]int),
+		queue:  make(chan peerEvent, peerEventQueue),
+		done:   make(chan struct{}),
+	}
+	net.Notify(e)
+	crash.Loop(e.done, logger, "p2p.events", e.dispatch)
+	return e
+}
+
+// Connected implements network.Notifiee
+func (e *peerEvents) Connected(_ network.Network, c network.Conn) {
+	id := c.RemotePeer()
+	e.mu.Lock()
+	e.conns[id]++
+	first := e.conns[id] == 1
+	e.mu.Unlock()
+	if first {
+		e.push(peerEvent{peerID: id.String(), connected: true})
+	}
+}
+
+// Disconnected implements network.Notifiee
+func (e *peerEvents) Disconnected(_ network.Network, c network.Conn) {
+	id := c.RemotePeer()
+	e.mu.Lock()
+	e.conns[id]--
+	last := e.conns[id] <= 0
+	if last {
+		delete(e.conns, id)
+	}
+	e.mu.Unlock()
+	if last {
+		e.push(peerEvent{peerID: id.String(), connected: false})
+	}
+}
+
+// Listen implements network.Notifiee
+func (e *peerEvents) Listen(network.Network, multiaddr.Multiaddr) {}
+
+// ListenClose implements network.Notifiee
+func (e *peerEvents) ListenClose(network.Network, multiaddr.Multiaddr) {}
+
+// push queues ev for the handlers, dropping it if they fall too far behind
+func (e *peerEvents) push(ev peerEvent) {
+	select {
+	case <-e.done:
+	case e.queue <- ev:
+	default:
+		e.logger.Warn("peer event handlers fall behind, dropping event", "peer", ev.peerID, "connected", ev.connected)
+	}
+}
+
+// dispatch runs the handlers of each queued event until the events are closed
+func (e *peerEvents) dispatch() {
+	for {
+		select {
+		case <-e.done:
+			return
+		case ev := <-e.queue:
+			e.mu.Lock()
+			handlers := e.disconnected
+			if ev.connected {
+				handlers = e.connected
+			}
+			e.mu.Unlock()
+			for _, fn := range handlers {
+				fn(ev.peerID)
+			}
+		}
+	}
+}
+
+// close stops listening to net and running handlers
+func (e *peerEvents) close(net network.Network) {
+	e.closeOnce.Do(func() {
+		net.StopNotify(e)
+		close(e.done)
+	})
+}
+
+// OnPeerConnected registers fn to run when a peer gets its first connection
+// to the host. Handlers run in a goroutine of their own, one event at a
+// time and in the order the events happened; a slow handler delays the
+// following events but not the network. Daemons reached over the local
+// socket are not reported.
+func (h *Host) OnPeerConnected(fn PeerHandler) {
+	h.events.mu.Lock()
+	defer h.events.mu.Unlock()
+	h.events.connected = append(h.events.connected, fn)
+}
+
+// OnPeerDisconnected registers fn to run when a peer loses its last
+// connection to the host, in the same way as OnPeerConnected
+func (h *Host) OnPeerDisconnected(fn PeerHandler) {
+	h.events.mu.Lock()
+	defer h.events.mu.Unlock()
+	h.events.disconnected = append(h.events.disconnected, fn)
+}
@@ -0,0 +1,91 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// LoadOrGenerateIdentity loads the host's private key from path, generating
+// and saving a new Ed25519 key on first use so the peer ID survives restarts
+func LoadOrGenerateIdentity(path string) (crypto.PrivKey, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home dir: %w", err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := crypto.UnmarshalPrivateKey(data)
+		if err != nil {
+			return nil, types.WrapError(err, "failed to parse identity key")
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, types.WrapError(err, "failed to read identity key")
+	}
+
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to generate identity key")
+	}
+	data, err = crypto.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to marshal identity key")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, types.WrapError(err, "failed to create identity directory")
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, types.WrapError(err, "failed to save identity key")
+	}
+	return key, nil
+}
+
+// AnnounceAddrsForIP returns listenAddrs with their IP replaced by ip, for
+// nodes reachable at an address they cannot see, such as behind container NAT.
+// Listen addresses that only differ in their IP yield a single address.
+func AnnounceAddrsForIP(listenAddrs []string, ip string) ([]string, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("%w: invalid IP address %q", types.ErrInvalidInput, ip)
+	}
+	ipComponent := "/ip6/" + parsed.String()
+	if parsed.To4() != nil {
+		ipComponent = "/ip4/" + parsed.String()
+	}
+
+	var result []string
+	for _, addr := range listenAddrs {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiaddr %s: %w", addr, err)
+		}
+		// Keep the transport part, e.g. /tcp/9000 or /udp/9000/quic
+		first, rest := multiaddr.SplitFirst(maddr)
+		if first == nil || len(rest) == 0 {
+			continue
+		}
+		switch first.Protocol().Code {
+		case multiaddr.P_IP4, multiaddr.P_IP6:
+		default:
+			continue
+		}
+		if addr := ipComponent + rest.String(); !slices.Contains(result, addr) {
+			result = append(result, addr)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package p2p_test
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestLoadOrGenerateIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "identity.key")
+
+	first, err := p2p.LoadOrGenerateIdentity(path)
+	if err != nil {
+		t.Fatalf("LoadOrGenerateIdentity() error = %v", err)
+	}
+	second, err := p2p.LoadOrGenerateIdentity(path)
+	if err != nil {
+		t.Fatalf("LoadOrGenerateIdentity() reload error = %v", err)
+	}
+
+	firstID, _ := peer.IDFromPrivateKey(first)
+	secondID, _ := peer.IDFromPrivateKey(second)
+	if firstID != secondID {
+		t.Errorf("peer ID changed on reload: %s != %s", firstID, secondID)
+	}
+}
+
+func TestAnnounceAddrsForIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		listen  []string
+		ip      string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:   "ipv4",
+			listen: []string{"/ip4/0.0.0.0/tcp/9000", "/ip4/0.0.0.0/udp/9000/quic"},
+			ip:     "203.0.113.10",
+			want:   []string{"/ip4/203.0.113.10/tcp/9000", "/ip4/203.0.113.10/udp/9000/quic"},
+		},
+		{
+			name:   "ipv6",
+			listen: []string{"/ip4/0.0.0.0/tcp/9000"},
+			ip:     "2001:db8::1",
+			want:   []string{"/ip6/2001:db8::1/tcp/9000"},
+		},
+		{
+			name:   "dual stack",
+			listen: []string{"/ip4/0.0.0.0/tcp/9000", "/ip6/::/tcp/9000"},
+			ip:     "203.0.113.10",
+			want:   []string{"/ip4/203.0.113.10/tcp/9000"},
+		},
+		{
+			name:    "invalid ip",
+			listen:  []string{"/ip4/0.0.0.0/tcp/9000"},
+			ip:      "not-an-ip",
+			wantErr: types.ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p2p.AnnounceAddrsForIP(tt.listen, tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AnnounceAddrsForIP() error = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("AnnounceAddrsForIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// LocalSocketName is the name of the local socket in the daemon data directory
+const LocalSocketName = "daemon.sock"
+
+// LocalPeer is what RemotePeer returns for streams accepted on the local socket
+const LocalPeer = "local"
+
+// localHandshakeTimeout bounds the exchange of hello and welcome on a new connection
+const localHandshakeTimeout = 10 * time.Second
+
+// localHello opens every connection to the local socket. An empty protocol
+// only asks for the peer ID of the daemon.
+type localHello struct {
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// localWelcome answers a localHello. After a welcome without error, the
+// connection carries the protocol exactly like a libp2p stream would.
+type localWelcome struct {
+	PeerID string `json:"peer_id"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ServeLocal serves the registered stream handlers on a Unix socket at path,
+// so controllers on the same machine can skip the network. The socket is only
+// accessible to the user running the host, which stands in for the PSK and
+// trusted peer checks. It is removed when the host closes.
+func (h *Host) ServeLocal(path string) error {
+	path, err := expandHome(path)
+	if err != nil {
+		return err
+	}
+
+	// A socket left behind by a crashed daemon refuses connections
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%w: local socket %s is in use by another process", types.ErrAlreadyExists, path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return types.WrapError(err, "failed to remove stale local socket")
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return types.WrapError(err, "failed to listen on local socket")
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = listener.Close()
+		return types.WrapError(err, "failed to restrict local socket")
+	}
+
+	h.mu.Lock()
+	h.localListener = listener
+	h.mu.Unlock()
+
+	crash.Loop(nil, h.logger, "p2p.local.accept", func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			crash.Go(h.logger, "p2p.local.conn", func() { h.serveLocalConn(conn) })
+		}
+	})
+
+	h.logger.Info("serving local socket", "path", path)
+	return nil
+}
+
+// serveLocalConn hands a local connection to the handler of the protocol it asks for
+func (h *Host) serveLocalConn(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(localHandshakeTimeout))
+
+	var hello localHello
+	if err := wire.ReadMessage(conn, &hello); err != nil {
+		h.logger.Debug("failed to read local socket handshake", "error", err)
+		_ = conn.Close()
+		return
+	}
+
+	welcome := localWelcome{PeerID: h.ID()}
+	var handler types.StreamHandler
+	if hello.Protocol != "" {
+		h.mu.Lock()
+		handler = h.handlers[hello.Protocol]
+		h.mu.Unlock()
+		if handler == nil {
+			welcome.Error = "no handler for " + hello.Protocol
+		}
+	}
+
+	if err := wire.WriteMessage(conn, &welcome); err != nil || handler == nil {
+		_ = conn.Close()
+		return
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	handler(&localStream{Conn: conn})
+}
+
+// ConnectLocal connects to the daemon serving the local socket at path and
+// returns its peer ID. Streams to that peer are opened over the socket from
+// then on, and Peers lists it first.
+func (h *Host) ConnectLocal(ctx context.Context, path string) (string, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return "", err
+	}
+
+	conn, welcome, err := dialLocal(ctx, path, "")
+	if err != nil {
+		return "", err
+	}
+	_ = conn.Close()
+
+	h.mu.Lock()
+	h.localPeers[welcome.PeerID] = path
+	h.mu.Unlock()
+
+	h.logger.Info("connected to local daemon", "peer", welcome.PeerID, "path", path)
+	return welcome.PeerID, nil
+}
+
+// DialLocal opens a stream for protocolID to the daemon serving the local
+// socket at path, without a host, e.g. for commands run next to the daemon
+func DialLocal(ctx context.Context, path string, protocolID string) (types.Stream, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	conn, _, err := dialLocal(ctx, path, protocolID)
+	if err != nil {
+		return nil, err
+	}
+	return &localStream{Conn: conn}, nil
+}
+
+// IsLocal reports whether peerID is a daemon connected with ConnectLocal
+func (h *Host) IsLocal(peerID string) bool {
+	_, ok := h.localPath(peerID)
+	return ok
+}
+
+// expandHome replaces a leading ~/ in path with the home directory
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home dir: %w", err)
+	}
+	return filepath.Join(home, path[2:]), nil
+}
+
+// localPath returns the local socket of peerID, if it was connected with ConnectLocal
+func (h *Host) localPath(peerID string) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	path, ok := h.localPeers[peerID]
+	return path, ok
+}
+
+// dialLocal opens a connection to the local socket at path for protocolID
+func dialLocal(ctx context.Context, path string, protocolID string) (net.Conn, *localWelcome, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: local socket %s: %w", types.ErrUnavailable, path, err)
+	}
+
+	deadline := time.Now().Add(localHandshakeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	var welcome localWelcome
+	if err := wire.WriteMessage(conn, &localHello{Protocol: protocolID}); err != nil {
+		_ = conn.Close()
+		return nil, nil, types.WrapError(err, "failed to send local socket handshake")
+	}
+	if err := wire.ReadMessage(conn, &welcome); err != nil {
+		_ = conn.Close()
+		return nil, nil, types.WrapError(err, "failed to read local socket handshake")
+	}
+	if welcome.Error != "" {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("%w: %s", types.ErrProtocolNotSupported, welcome.Error)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return conn, &welcome, nil
+}
+
+// localStream is a stream over a connection to the local socket
+type localStream struct {
+	net.Conn
+}
+
+// Reset closes the connection; a Unix socket has no abrupt close
+func (s *localStream) Reset() error {
+	return s.Conn.Close()
+}
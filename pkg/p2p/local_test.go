@@ -0,0 +1,117 @@
+package p2p_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// newTestHost creates a host reachable on loopback only
+func newTestHost(t *testing.T) *p2p.Host {
+	t.Helper()
+	h, err := p2p.NewHost(context.Background(), &p2p.HostConfig{
+		ListenAddrs:         []string{"/ip4/127.0.0.1/tcp/0"},
+		DisableDHT:          true,
+		DisableNATService:   true,
+		DisableAutoRelay:    true,
+		DisableHolePunching: true,
+		DisableRelayService: true,
+	}, logging.Nop())
+	if err != nil {
+		t.Fatalf("NewHost() error = %v", err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	return h
+}
+
+func TestLocalSocket(t *testing.T) {
+	ctx := context.Background()
+	daemon := newTestHost(t)
+	controller := newTestHost(t)
+
+	remote := make(chan string, 1)
+	daemon.SetStreamHandler("/test/echo/1.0.0", func(s types.Stream) {
+		defer func() { _ = s.Close() }()
+		remote <- p2p.RemotePeer(s)
+		_, _ = io.Copy(s, io.LimitReader(s, 5))
+	})
+
+	socket := filepath.Join(t.TempDir(), p2p.LocalSocketName)
+	if err := daemon.ServeLocal(socket); err != nil {
+		t.Fatalf("ServeLocal() error = %v", err)
+	}
+	if err := newTestHost(t).ServeLocal(socket); !errors.Is(err, types.ErrAlreadyExists) {
+		t.Errorf("second ServeLocal() error = %v, want ErrAlreadyExists", err)
+	}
+
+	peerID, err := controller.ConnectLocal(ctx, socket)
+	if err != nil {
+		t.Fatalf("ConnectLocal() error = %v", err)
+	}
+	if peerID != daemon.ID() {
+		t.Errorf("ConnectLocal() = %s, want %s", peerID, daemon.ID())
+	}
+	if peers := controller.Peers(); len(peers) != 1 || peers[0].ID != daemon.ID() {
+		t.Errorf("Peers() = %v, want the local daemon", peers)
+	}
+
+	// The hosts are not connected over the network, so this must use the socket
+	stream, err := controller.NewStream(ctx, peerID, "/test/echo/1.0.0")
+	if err != nil {
+		t.Fatalf("NewStream() error = %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+	if _, err := stream.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	reply := make([]byte, 5)
+	if _, err := io.ReadFull(stream, reply); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if string(reply) != "hello" {
+		t.Errorf("got reply %q, want %q", reply, "hello")
+	}
+	if got := <-remote; got != p2p.LocalPeer {
+		t.Errorf("RemotePeer() = %q, want %q", got, p2p.LocalPeer)
+	}
+}
+
+func TestDialLocal(t *testing.T) {
+	ctx := context.Background()
+	daemon := newTestHost(t)
+	daemon.SetStreamHandler("/test/echo/1.0.0", func(s types.Stream) {
+		defer func() { _ = s.Close() }()
+		_, _ = io.Copy(s, io.LimitReader(s, 5))
+	})
+	socket := filepath.Join(t.TempDir(), p2p.LocalSocketName)
+	if err := daemon.ServeLocal(socket); err != nil {
+		t.Fatalf("ServeLocal() error = %v", err)
+	}
+
+	stream, err := p2p.DialLocal(ctx, socket, "/test/echo/1.0.0")
+	if err != nil {
+		t.Fatalf("DialLocal() error = %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+	if _, err := stream.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	reply := make([]byte, 5)
+	if _, err := io.ReadFull(stream, reply); err != nil || string(reply) != "hello" {
+		t.Errorf("got reply %q, %v, want %q", reply, err, "hello")
+	}
+
+	daemon.RemoveStreamHandler("/test/echo/1.0.0")
+	if _, err := p2p.DialLocal(ctx, socket, "/test/echo/1.0.0"); !errors.Is(err, types.ErrProtocolNotSupported) {
+		t.Errorf("DialLocal() of a removed handler error = %v, want ErrProtocolNotSupported", err)
+	}
+	if _, err := p2p.DialLocal(ctx, filepath.Join(t.TempDir(), "none.sock"), "/test/echo/1.0.0"); !errors.Is(err, types.ErrUnavailable) {
+		t.Errorf("DialLocal() without a daemon error = %v, want ErrUnavailable", err)
+	}
+}
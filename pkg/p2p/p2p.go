@@ -3,22 +3,27 @@ package p2p
 import (
 	"context"
 	"fmt"
+	"net"
+	"runtime/debug"
 	"sync"
 	"time"
 
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
 	"github.com/asjdf/p2p-playground-lite/pkg/security"
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
 	"github.com/libp2p/go-libp2p"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/pnet"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/routing"
-	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	libp2ptls "github.com/libp2p/go-libp2p/p2p/security/tls"
 	"github.com/multiformats/go-multiaddr"
@@ -36,9 +41,19 @@ var DefaultBootstrapPeers = []string{
 
 // Host wraps libp2p host
 type Host struct {
-	host   host.Host
-	dht    *dht.IpfsDHT
-	logger types.Logger
+	host           host.Host
+	dht            *dht.IpfsDHT
+	logger         types.Logger
+	bootstrapPeers []string
+	psk            bool // whether the host is in a private network
+	events         *peerEvents
+
+	mu            sync.Mutex
+	handlers      map[string]types.StreamHandler
+	localPeers    map[string]string // peer ID to local socket path
+	localListener net.Listener
+	onClose       []func()
+	closed        bool
 }
 
 // HostConfig contains configuration for creating a P2P host
@@ -46,6 +61,10 @@ type HostConfig struct {
 	// ListenAddrs are the multiaddrs to listen on
 	ListenAddrs []string
 
+	// AddressFamily limits the IP addresses listened on and advertised to
+	// AddressFamilyV4 or AddressFamilyV6 (default: AddressFamilyDual, both)
+	AddressFamily string
+
 	// PSK is the pre-shared key for private network (hex-encoded)
 	PSK string
 
@@ -64,6 +83,10 @@ type HostConfig struct {
 	// DHTMode is the DHT mode: "client" or "server" (default: "server")
 	DHTMode string
 
+	// DisablePublicBootstrap keeps the DHT from using DefaultBootstrapPeers
+	// when BootstrapPeers is empty
+	DisablePublicBootstrap bool
+
 	// DisableNATService disables NAT traversal service
 	DisableNATService bool
 
@@ -79,19 +102,59 @@ type HostConfig struct {
 	// StaticRelays are static relay addresses for NAT traversal
 	// If provided, these will be used instead of DHT-based relay discovery
 	StaticRelays []string
+
+	// Identity is the host's private key (a fresh key is generated if nil)
+	Identity crypto.PrivKey
+
+	// AnnounceAddrs are advertised to peers instead of the listen addresses
+	AnnounceAddrs []string
+
+	// MaxConnections is the number of connections above which the least
+	// useful ones are closed, down to three quarters of it (libp2p default if 0)
+	MaxConnections int
+
+	// RelayResources overrides the limits of the relay service (libp2p defaults if nil)
+	RelayResources *relay.Resources
+
+	// ForceReachabilityPublic treats the host as publicly reachable without
+	// waiting for AutoNAT; the relay service only runs on public hosts
+	ForceReachabilityPublic bool
+
+	// ExtraLibp2pOptions are appended to the options built from this config,
+	// e.g. custom transports, muxers or a peerstore for programs embedding the
+	// package. Options libp2p accepts only once, such as Identity, fail if the
+	// config sets them too; setting any transport replaces the default ones.
+	ExtraLibp2pOptions []libp2p.Option
 }
 
 // NewHost creates a new P2P host
 func NewHost(ctx context.Context, config *HostConfig, logger types.Logger) (*Host, error) {
-	// Parse listen addresses
+	return NewHostWithOptions(ctx, config, logger)
+}
+
+// NewHostWithOptions creates a new P2P host with additional libp2p options,
+// appended after config.ExtraLibp2pOptions
+func NewHostWithOptions(ctx context.Context, config *HostConfig, logger types.Logger, extra ...libp2p.Option) (*Host, error) {
+	if err := ValidateAddressFamily(config.AddressFamily); err != nil {
+		return nil, err
+	}
+
+	// Parse listen addresses, skipping those of the other address family
 	var maddrs []multiaddr.Multiaddr
 	for _, addr := range config.ListenAddrs {
 		maddr, err := multiaddr.NewMultiaddr(addr)
 		if err != nil {
 			return nil, fmt.Errorf("invalid multiaddr %s: %w", addr, err)
 		}
+		if !matchesFamily(maddr, config.AddressFamily) {
+			logger.Debug("skipping listen address of other address family", "addr", addr, "family", config.AddressFamily)
+			continue
+		}
 		maddrs = append(maddrs, maddr)
 	}
+	if len(config.ListenAddrs) > 0 && len(maddrs) == 0 {
+		return nil, fmt.Errorf("%w: no listen address of address family %s", types.ErrInvalidInput, config.AddressFamily)
+	}
 
 	// Build libp2p options
 	opts := []libp2p.Option{
@@ -101,6 +164,39 @@ func NewHost(ctx context.Context, config *HostConfig, logger types.Logger) (*Hos
 		libp2p.Security(noise.ID, noise.New),
 	}
 
+	if config.Identity != nil {
+		opts = append(opts, libp2p.Identity(config.Identity))
+	}
+
+	if config.MaxConnections > 0 {
+		cm, err := connmgr.NewConnManager(config.MaxConnections*3/4, config.MaxConnections)
+		if err != nil {
+			return nil, types.WrapError(err, "failed to create connection manager")
+		}
+		opts = append(opts, libp2p.ConnectionManager(cm))
+	}
+
+	// Advertise the configured addresses instead of the ones we listen on,
+	// otherwise only the addresses of our address family
+	if len(config.AnnounceAddrs) == 0 {
+		opts = append(opts, libp2p.AddrsFactory(func(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+			return FilterAddrs(addrs, config.AddressFamily)
+		}))
+	} else {
+		var announce []multiaddr.Multiaddr
+		for _, addr := range config.AnnounceAddrs {
+			maddr, err := multiaddr.NewMultiaddr(addr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid announce multiaddr %s: %w", addr, err)
+			}
+			announce = append(announce, maddr)
+		}
+		opts = append(opts, libp2p.AddrsFactory(func([]multiaddr.Multiaddr) []multiaddr.Multiaddr {
+			return announce
+		}))
+		logger.Info("announcing configured addresses", "addrs", config.AnnounceAddrs)
+	}
+
 	// Add NAT traversal options (enabled by default)
 	if !config.DisableNATService {
 		opts = append(opts, libp2p.EnableNATService())
@@ -113,10 +209,18 @@ func NewHost(ctx context.Context, config *HostConfig, logger types.Logger) (*Hos
 
 	// Enable relay service (enabled by default, allows this node to relay connections for others)
 	if !config.DisableRelayService {
-		opts = append(opts, libp2p.EnableRelayService())
+		var relayOpts []relay.Option
+		if config.RelayResources != nil {
+			relayOpts = append(relayOpts, relay.WithResources(*config.RelayResources))
+		}
+		opts = append(opts, libp2p.EnableRelayService(relayOpts...))
 		logger.Info("relay service enabled - this node can relay connections for other peers")
 	}
 
+	if config.ForceReachabilityPublic {
+		opts = append(opts, libp2p.ForceReachabilityPublic())
+	}
+
 	// Add DHT routing (enabled by default)
 	var kadDHT *dht.IpfsDHT
 	if !config.DisableDHT {
@@ -203,7 +307,8 @@ func NewHost(ctx context.Context, config *HostConfig, logger types.Logger) (*Hos
 	}
 
 	// Add PSK if authentication is enabled
-	if config.EnableAuth && config.PSK != "" {
+	privateNetwork := config.EnableAuth && config.PSK != ""
+	if privateNetwork {
 		psk, err := security.DecodePSK(config.PSK)
 		if err != nil {
 			return nil, types.WrapError(err, "failed to decode PSK")
@@ -221,16 +326,25 @@ func NewHost(ctx context.Context, config *HostConfig, logger types.Logger) (*Hos
 		logger.Info("connection gating enabled", "trusted_peers", len(config.TrustedPeers))
 	}
 
+	// Embedder options come last
+	opts = append(opts, config.ExtraLibp2pOptions...)
+	opts = append(opts, extra...)
+
 	// Create libp2p host
 	h, err := libp2p.New(opts...)
 	if err != nil {
+		if kadDHT != nil {
+			_ = kadDHT.Close()
+		}
 		return nil, types.WrapError(err, "failed to create libp2p host")
 	}
+	// Before anything dials, so the handlers see every connection
+	events := newPeerEvents(h.Network(), logger)
 
 	logger.Info("libp2p host created",
 		"id", h.ID().String(),
 		"addrs", h.Addrs(),
-		"psk_enabled", config.EnableAuth && config.PSK != "",
+		"psk_enabled", privateNetwork,
 		"trusted_peers", len(config.TrustedPeers),
 		"dht_enabled", !config.DisableDHT,
 	)
@@ -247,23 +361,44 @@ func NewHost(ctx context.Context, config *HostConfig, logger types.Logger) (*Hos
 	// Connect to bootstrap peers
 	// If DHT is enabled and no bootstrap peers are configured, use default IPFS bootstrap nodes
 	bootstrapPeers := config.BootstrapPeers
-	if !config.DisableDHT && len(bootstrapPeers) == 0 {
+	if !config.DisableDHT && len(bootstrapPeers) == 0 && !config.DisablePublicBootstrap {
 		bootstrapPeers = DefaultBootstrapPeers
 		logger.Info("no bootstrap peers configured, using default IPFS bootstrap nodes")
 	}
 
 	if len(bootstrapPeers) > 0 {
 		logger.Info("connecting to bootstrap peers", "count", len(bootstrapPeers))
-		go connectToBootstrapPeers(ctx, h, bootstrapPeers, logger)
+		crash.Go(logger, "p2p.bootstrap", func() {
+			connectToBootstrapPeers(ctx, h, bootstrapPeers, privateNetwork, logger)
+		})
 	}
 
 	return &Host{
-		host:   h,
-		dht:    kadDHT,
-		logger: logger,
+		host:           h,
+		dht:            kadDHT,
+		logger:         logger,
+		bootstrapPeers: bootstrapPeers,
+		psk:            privateNetwork,
+		events:         events,
+		handlers:       make(map[string]types.StreamHandler),
+		localPeers:     make(map[string]string),
 	}, nil
 }
 
+// Bootstrap connects to the bootstrap peers again and refreshes the DHT
+// routing table, for a host that lost its connections to the network.
+// It returns once the bootstrap peers were dialed.
+func (h *Host) Bootstrap(ctx context.Context) {
+	if h.dht != nil {
+		if err := h.dht.Bootstrap(ctx); err != nil {
+			h.logger.Warn("failed to bootstrap DHT", "error", err)
+		}
+	}
+	if len(h.bootstrapPeers) > 0 {
+		connectToBootstrapPeers(ctx, h.host, h.bootstrapPeers, h.psk, h.logger)
+	}
+}
+
 // ID returns the host's peer ID
 func (h *Host) ID() string {
 	return h.host.ID().String()
@@ -279,9 +414,18 @@ func (h *Host) DHT() *dht.IpfsDHT {
 	return h.dht
 }
 
-// Addrs returns the host's listening addresses
+// Routing returns the DHT for content routing, or nil if the DHT is disabled.
+// Unlike DHT, the result compares equal to nil when there is no DHT.
+func (h *Host) Routing() routing.ContentRouting {
+	if h.dht == nil {
+		return nil
+	}
+	return h.dht
+}
+
+// Addrs returns the host's advertised addresses, the most routable first
 func (h *Host) Addrs() []string {
-	addrs := h.host.Addrs()
+	addrs := SortAddrs(h.host.Addrs())
 	result := make([]string, len(addrs))
 	for i, addr := range addrs {
 		result[i] = addr.String()
@@ -302,7 +446,7 @@ func (h *Host) Connect(ctx context.Context, addr string) error {
 	}
 
 	if err := h.host.Connect(ctx, *peerInfo); err != nil {
-		return types.WrapError(err, "failed to connect to peer")
+		return types.WrapError(explainDialError(ctx, err, h.psk, peerInfo.Addrs), "failed to connect to peer")
 	}
 
 	h.logger.Info("connected to peer", "peer", peerInfo.ID)
@@ -310,8 +454,17 @@ func (h *Host) Connect(ctx context.Context, addr string) error {
 	return nil
 }
 
-// NewStream creates a new stream to a peer
+// NewStream creates a new stream to a peer, over the local socket if the
+// peer was connected with ConnectLocal
 func (h *Host) NewStream(ctx context.Context, peerID string, protocolID string) (types.Stream, error) {
+	if path, ok := h.localPath(peerID); ok {
+		conn, _, err := dialLocal(ctx, path, protocolID)
+		if err == nil {
+			return &localStream{Conn: conn}, nil
+		}
+		h.logger.Debug("local socket unusable, using the network", "peer", peerID, "error", err)
+	}
+
 	pid, err := peer.Decode(peerID)
 	if err != nil {
 		return nil, types.WrapError(err, "invalid peer ID")
@@ -319,37 +472,84 @@ func (h *Host) NewStream(ctx context.Context, peerID string, protocolID string)
 
 	stream, err := h.host.NewStream(ctx, pid, protocol.ID(protocolID))
 	if err != nil {
+		err = explainDialError(ctx, err, h.psk, h.host.Peerstore().Addrs(pid))
 		return nil, types.WrapError(err, "failed to create stream")
 	}
 
 	return &streamWrapper{stream: stream}, nil
 }
 
-// SetStreamHandler registers a handler for incoming streams
+// SetStreamHandler registers a handler for incoming streams. A panic in the
+// handler is reported and resets the stream.
 func (h *Host) SetStreamHandler(protocolID string, handler types.StreamHandler) {
+	recovered := func(s types.Stream) {
+		defer func() {
+			if r := recover(); r != nil {
+				crash.Report(h.logger, "handler "+protocolID, r, debug.Stack())
+				_ = s.Reset()
+			}
+		}()
+		handler(s)
+	}
+
+	h.mu.Lock()
+	h.handlers[protocolID] = recovered
+	h.mu.Unlock()
+
 	h.host.SetStreamHandler(protocol.ID(protocolID), func(s network.Stream) {
-		handler(&streamWrapper{stream: s})
+		recovered(&streamWrapper{stream: s})
 	})
 }
 
-// Close shuts down the host
-func (h *Host) Close() error {
-	return h.host.Close()
+// RemoveStreamHandler stops serving protocolID
+func (h *Host) RemoveStreamHandler(protocolID string) {
+	h.mu.Lock()
+	delete(h.handlers, protocolID)
+	h.mu.Unlock()
+
+	h.host.RemoveStreamHandler(protocol.ID(protocolID))
 }
 
-// EnableMDNS enables mDNS discovery
-func (h *Host) EnableMDNS(ctx context.Context) error {
-	service := mdns.NewMdnsService(h.host, "p2p-playground", &discoveryNotifee{
-		h:      h.host,
-		logger: h.logger,
-	})
+// OnClose registers fn to run when the host closes, for services tied to its lifetime
+func (h *Host) OnClose(fn func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onClose = append(h.onClose, fn)
+}
+
+// Close shuts down the host and everything it owns, the services registered
+// with OnClose first and the libp2p host last. Only the first call does anything.
+func (h *Host) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	onClose := h.onClose
+	h.onClose = nil
+	h.mu.Unlock()
+	for _, fn := range onClose {
+		fn()
+	}
 
-	if err := service.Start(); err != nil {
-		return types.WrapError(err, "failed to start mDNS")
+	h.mu.Lock()
+	if h.localListener != nil {
+		// Also removes the socket file
+		_ = h.localListener.Close()
+		h.localListener = nil
 	}
+	h.mu.Unlock()
 
-	h.logger.Info("mDNS discovery enabled")
-	return nil
+	h.events.close(h.host.Network())
+
+	// The routed host libp2p wraps around the DHT leaves it open
+	if h.dht != nil {
+		if err := h.dht.Close(); err != nil {
+			h.logger.Warn("failed to close DHT", "error", err)
+		}
+	}
+	return h.host.Close()
 }
 
 // PeerInfo contains information about a peer
@@ -358,12 +558,36 @@ type PeerInfo struct {
 	Addrs []string
 }
 
-// Peers returns a list of connected peers
+// Supports reports whether a connected peer serves protocolID, as far as
+// identify has told. Daemons reached over the local socket serve every
+// daemon protocol.
+func (h *Host) Supports(peerID string, protocolID string) bool {
+	if h.IsLocal(peerID) {
+		return true
+	}
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return false
+	}
+	supported, err := h.host.Peerstore().SupportsProtocols(id, protocol.ID(protocolID))
+	return err == nil && len(supported) > 0
+}
+
+// Peers returns a list of connected peers, those reached over the local socket first
 func (h *Host) Peers() []PeerInfo {
 	peers := h.host.Network().Peers()
 	result := make([]PeerInfo, 0, len(peers))
 
+	h.mu.Lock()
+	for id, path := range h.localPeers {
+		result = append(result, PeerInfo{ID: id, Addrs: []string{"/unix" + path}})
+	}
+	h.mu.Unlock()
+
 	for _, p := range peers {
+		if _, ok := h.localPath(p.String()); ok {
+			continue
+		}
 		conns := h.host.Network().ConnsToPeer(p)
 		addrs := make([]string, 0)
 		for _, conn := range conns {
@@ -413,9 +637,18 @@ func (h *Host) GetNetworkStats() NetworkStats {
 	return stats
 }
 
-// StartDiagnosticLogging starts periodic logging of network status
-func (h *Host) StartDiagnosticLogging(ctx context.Context, interval time.Duration) {
-	go func() {
+// DefaultDiagnosticsInterval is how often StartDiagnosticLogging logs when
+// no interval is given
+const DefaultDiagnosticsInterval = 30 * time.Second
+
+// StartDiagnosticLogging starts periodic logging of network status until ctx
+// is done. fields, if not nil, returns key-value pairs logged along with it,
+// such as counters of the caller.
+func (h *Host) StartDiagnosticLogging(ctx context.Context, interval time.Duration, fields func() []interface{}) {
+	if interval <= 0 {
+		interval = DefaultDiagnosticsInterval
+	}
+	crash.Loop(ctx.Done(), h.logger, "p2p.diagnostics", func() {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
@@ -425,11 +658,15 @@ func (h *Host) StartDiagnosticLogging(ctx context.Context, interval time.Duratio
 				return
 			case <-ticker.C:
 				stats := h.GetNetworkStats()
-				h.logger.Info("network status",
+				status := []interface{}{
 					"connected_peers", stats.ConnectedPeers,
 					"dht_routing_table_size", stats.DHTRoutingTable,
 					"dht_mode", stats.DHTMode,
-				)
+				}
+				if fields != nil {
+					status = append(status, fields()...)
+				}
+				h.logger.Info("network status", status...)
 
 				// Log peer details if there are connections
 				peers := h.Peers()
@@ -440,24 +677,29 @@ func (h *Host) StartDiagnosticLogging(ctx context.Context, interval time.Duratio
 				}
 			}
 		}
-	}()
+	})
 }
 
-// discoveryNotifee handles peer discovery
-type discoveryNotifee struct {
-	h      host.Host
-	logger types.Logger
+// RemotePeer returns the peer ID at the other end of a stream opened by or
+// handed to a Host, LocalPeer for streams of the local socket, or "" for other streams
+func RemotePeer(s types.Stream) string {
+	switch s := s.(type) {
+	case *streamWrapper:
+		return s.stream.Conn().RemotePeer().String()
+	case *localStream:
+		return LocalPeer
+	}
+	return ""
 }
 
-func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
-	n.logger.Info("discovered peer via mDNS", "peer", pi.ID)
-
-	if err := n.h.Connect(context.Background(), pi); err != nil {
-		n.logger.Warn("failed to connect to discovered peer",
-			"peer", pi.ID,
-			"error", err,
-		)
+// LibP2PStream returns the libp2p stream s wraps, or false if s came
+// through the local socket
+func LibP2PStream(s types.Stream) (network.Stream, bool) {
+	w, ok := s.(*streamWrapper)
+	if !ok {
+		return nil, false
 	}
+	return w.stream, true
 }
 
 // streamWrapper wraps libp2p stream to implement types.Stream
@@ -553,7 +795,7 @@ func (g *connectionGater) InterceptUpgraded(_ network.Conn) (bool, control.Disco
 }
 
 // connectToBootstrapPeers connects to bootstrap peers in the background
-func connectToBootstrapPeers(ctx context.Context, h host.Host, bootstrapPeers []string, logger types.Logger) {
+func connectToBootstrapPeers(ctx context.Context, h host.Host, bootstrapPeers []string, psk bool, logger types.Logger) {
 	var wg sync.WaitGroup
 
 	for _, addrStr := range bootstrapPeers {
@@ -580,7 +822,7 @@ func connectToBootstrapPeers(ctx context.Context, h host.Host, bootstrapPeers []
 			defer cancel()
 
 			if err := h.Connect(connectCtx, *peerInfo); err != nil {
-				logger.Warn("failed to connect to bootstrap peer", "peer", peerInfo.ID, "error", err)
+				logger.Warn("failed to connect to bootstrap peer", "peer", peerInfo.ID, "error", explainDialError(ctx, err, psk, peerInfo.Addrs))
 				return
 			}
 
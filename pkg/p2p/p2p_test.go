@@ -0,0 +1,262 @@
+package p2p_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
+	"go.uber.org/goleak"
+)
+
+func TestNewHostWithOptions(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &p2p.HostConfig{
+		ListenAddrs:        []string{"/ip4/127.0.0.1/tcp/0"},
+		DisableDHT:         true,
+		DisableAutoRelay:   true,
+		ExtraLibp2pOptions: []libp2p.Option{libp2p.Peerstore(ps)},
+	}
+	h, err := p2p.NewHostWithOptions(context.Background(), cfg, logging.Nop(), libp2p.UserAgent("embedder/1.0"))
+	if err != nil {
+		t.Fatalf("NewHostWithOptions() error = %v", err)
+	}
+	defer func() { _ = h.Close() }()
+
+	if h.LibP2PHost().Peerstore() != ps {
+		t.Error("host does not use the peerstore passed in ExtraLibp2pOptions")
+	}
+}
+
+func TestNewHostConflictingOptions(t *testing.T) {
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &p2p.HostConfig{
+		ListenAddrs:        []string{"/ip4/127.0.0.1/tcp/0"},
+		DisableDHT:         true,
+		DisableAutoRelay:   true,
+		Identity:           key,
+		ExtraLibp2pOptions: []libp2p.Option{libp2p.Identity(other)},
+	}
+	if h, err := p2p.NewHost(context.Background(), cfg, logging.Nop()); err == nil {
+		_ = h.Close()
+		t.Error("NewHost() with two identities succeeded, want error")
+	}
+}
+
+func TestConnectPSKMismatch(t *testing.T) {
+	key1, err := security.GeneratePSK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := security.GeneratePSK()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newHost := func(psk []byte) *p2p.Host {
+		cfg := &p2p.HostConfig{
+			ListenAddrs:      []string{"/ip4/127.0.0.1/tcp/0"},
+			DisableDHT:       true,
+			DisableAutoRelay: true,
+		}
+		if psk != nil {
+			cfg.EnableAuth = true
+			cfg.PSK = security.EncodePSK(psk)
+		}
+		h, err := p2p.NewHost(context.Background(), cfg, logging.Nop())
+		if err != nil {
+			t.Fatalf("NewHost() error = %v", err)
+		}
+		t.Cleanup(func() { _ = h.Close() })
+		return h
+	}
+
+	tests := []struct {
+		name         string
+		local        []byte
+		remote       []byte
+		wantMismatch bool
+		wantHint     string
+	}{
+		{"same key", key1, key1, false, ""},
+		{"different keys", key1, key2, true, "different PSKs"},
+		{"remote without key", key1, nil, true, "remote peer has no PSK"},
+		{"local without key", nil, key1, true, "this node has no PSK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, remote := newHost(tt.local), newHost(tt.remote)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err := local.Connect(ctx, remote.Addrs()[0]+"/p2p/"+remote.ID())
+
+			if !tt.wantMismatch {
+				if err != nil {
+					t.Fatalf("Connect() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, types.ErrPSKMismatch) {
+				t.Fatalf("Connect() error = %v, want ErrPSKMismatch", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantHint) {
+				t.Errorf("Connect() error = %v, want hint %q", err, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestPeerEvents(t *testing.T) {
+	newHost := func() *p2p.Host {
+		cfg := &p2p.HostConfig{
+			ListenAddrs:      []string{"/ip4/127.0.0.1/tcp/0"},
+			DisableDHT:       true,
+			DisableAutoRelay: true,
+		}
+		h, err := p2p.NewHost(context.Background(), cfg, logging.Nop())
+		if err != nil {
+			t.Fatalf("NewHost() error = %v", err)
+		}
+		t.Cleanup(func() { _ = h.Close() })
+		return h
+	}
+	local, remote := newHost(), newHost()
+
+	connected := make(chan string, 4)
+	disconnected := make(chan string, 4)
+	local.OnPeerConnected(func(peerID string) { connected <- peerID })
+	local.OnPeerDisconnected(func(peerID string) { disconnected <- peerID })
+
+	wait := func(events chan string, what string) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != remote.ID() {
+				t.Errorf("%s peer = %s, want %s", what, got, remote.ID())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event", what)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := local.Connect(ctx, remote.Addrs()[0]+"/p2p/"+remote.ID()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	wait(connected, "connected")
+
+	if err := remote.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	wait(disconnected, "disconnected")
+
+	select {
+	case id := <-connected:
+		t.Errorf("unexpected second connected event for %s", id)
+	default:
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	// Goroutines libp2p starts once per process are not the host's to stop
+	warm, err := p2p.NewHost(context.Background(), &p2p.HostConfig{
+		ListenAddrs:      []string{"/ip4/127.0.0.1/tcp/0"},
+		DisableDHT:       true,
+		DisableAutoRelay: true,
+	}, logging.Nop())
+	if err != nil {
+		t.Fatalf("NewHost() error = %v", err)
+	}
+	_ = warm.Close()
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	cfg := &p2p.HostConfig{
+		ListenAddrs:            []string{"/ip4/127.0.0.1/tcp/0"},
+		DisablePublicBootstrap: true,
+	}
+	h, err := p2p.NewHost(context.Background(), cfg, logging.Nop())
+	if err != nil {
+		t.Fatalf("NewHost() error = %v", err)
+	}
+	if h.DHT() == nil {
+		t.Fatal("host has no DHT")
+	}
+	h.OnPeerConnected(func(string) {})
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestUpgradeDirect(t *testing.T) {
+	newHost := func(cfg *p2p.HostConfig) *p2p.Host {
+		cfg.ListenAddrs = []string{"/ip4/127.0.0.1/tcp/0"}
+		cfg.DisableDHT = true
+		cfg.DisableAutoRelay = true
+		h, err := p2p.NewHost(context.Background(), cfg, logging.Nop())
+		if err != nil {
+			t.Fatalf("NewHost() error = %v", err)
+		}
+		t.Cleanup(func() { _ = h.Close() })
+		return h
+	}
+	relayHost := newHost(&p2p.HostConfig{ForceReachabilityPublic: true})
+	target := newHost(&p2p.HostConfig{DisableRelayService: true})
+	dialer := newHost(&p2p.HostConfig{DisableRelayService: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if got := dialer.Route(target.ID()); got != p2p.RouteNone {
+		t.Errorf("Route() before connecting = %s, want none", got)
+	}
+
+	// The target is reachable through the relay only
+	relayInfo := peer.AddrInfo{ID: relayHost.LibP2PHost().ID(), Addrs: relayHost.LibP2PHost().Addrs()}
+	if err := target.LibP2PHost().Connect(ctx, relayInfo); err != nil {
+		t.Fatalf("connecting to relay: %v", err)
+	}
+	if _, err := client.Reserve(ctx, target.LibP2PHost(), relayInfo); err != nil {
+		t.Fatalf("reserving a relay slot: %v", err)
+	}
+	circuit := relayHost.Addrs()[0] + "/p2p/" + relayHost.ID() + "/p2p-circuit/p2p/" + target.ID()
+	if err := dialer.Connect(ctx, circuit); err != nil {
+		t.Fatalf("connecting through the relay: %v", err)
+	}
+	if got := dialer.Route(target.ID()); got != p2p.RouteRelayed {
+		t.Fatalf("Route() through the relay = %s, want relayed", got)
+	}
+
+	if got := dialer.UpgradeDirect(ctx, target.ID(), 5*time.Second); got != p2p.RouteDirect {
+		t.Errorf("UpgradeDirect() = %s, want direct", got)
+	}
+	if got := dialer.Route(target.ID()); got != p2p.RouteDirect {
+		t.Errorf("Route() after the upgrade = %s, want direct", got)
+	}
+}
@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// A private network (PSK) mismatch does not fail with an error of its own:
+// each side encrypts the connection with its key, or not at all, and the
+// other reads garbage where it expects the security handshake, or waits for
+// it until the dial times out. Which side is in a private network shows in
+// the first bytes a peer sends on a new connection: a peer outside one
+// starts protocol negotiation in plain text, a peer inside one starts with a
+// random nonce.
+
+// multistreamHeader is what a peer outside a private network sends first
+var multistreamHeader = []byte("\x13/multistream/1.0.0\n")
+
+// securityNegotiationFailed is where an upgrade fails when the peers do not share a key
+const securityNegotiationFailed = "failed to negotiate security protocol"
+
+// probeTimeout bounds probing a peer after a failed dial
+const probeTimeout = 5 * time.Second
+
+// explainDialError returns err as a types.ErrPSKMismatch telling which side
+// lacks the right PSK if probing the peer at addrs shows the dial failed on
+// a mismatch, otherwise err unchanged. psk reports whether this host has a PSK.
+func explainDialError(ctx context.Context, err error, psk bool, addrs []multiaddr.Multiaddr) error {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	// The dial context has likely expired
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), probeTimeout)
+	defer cancel()
+	remotePSK, known := probePrivateNetwork(ctx, addrs)
+	if !known {
+		return err
+	}
+
+	var hint string
+	switch {
+	case psk && !remotePSK:
+		hint = "the remote peer has no PSK configured"
+	case !psk && remotePSK:
+		hint = "this node has no PSK configured and the remote peer requires one"
+	case psk && (strings.Contains(err.Error(), securityNegotiationFailed) || errors.Is(err, context.DeadlineExceeded)):
+		hint = "the peers likely use different PSKs"
+	default:
+		return err
+	}
+	return fmt.Errorf("%w (%s): %w", types.ErrPSKMismatch, hint, err)
+}
+
+// probePrivateNetwork connects to the first reachable plain TCP address of
+// addrs and reports whether the peer listening there is in a private
+// network. known is false if no address could be probed.
+func probePrivateNetwork(ctx context.Context, addrs []multiaddr.Multiaddr) (private, known bool) {
+	for _, addr := range addrs {
+		// QUIC is not available in private networks, and other transports
+		// such as websockets or relays do not start with the peer's bytes
+		if len(multiaddr.Split(addr)) != 2 {
+			continue
+		}
+		network, address, err := manet.DialArgs(addr)
+		if err != nil || !strings.HasPrefix(network, "tcp") {
+			continue
+		}
+
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err != nil {
+			continue
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetReadDeadline(deadline)
+		}
+		first := make([]byte, len(multistreamHeader))
+		_, err = io.ReadFull(conn, first)
+		_ = conn.Close()
+		if err != nil {
+			continue
+		}
+		return !bytes.Equal(first, multistreamHeader), true
+	}
+	return false, false
+}
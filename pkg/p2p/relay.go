@@ -0,0 +1,20 @@
+package p2p
+
+import (
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+)
+
+// DedicatedRelayResources returns relay limits for a host that does nothing
+// but relay. Circuits have no time or data limit: libp2p treats limited
+// circuits as usable only for hole punching, while nodes that cannot punch
+// through their NAT need the relay to carry deployments too.
+func DedicatedRelayResources() *relay.Resources {
+	r := relay.DefaultResources()
+	r.Limit = nil
+	r.MaxReservations = 4096
+	r.MaxCircuits = 128
+	r.BufferSize = 16 * 1024
+	r.MaxReservationsPerIP = 64
+	r.MaxReservationsPerASN = 256
+	return &r
+}
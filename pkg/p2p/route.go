@@ -0,0 +1,103 @@
+package p2p
+
+import (
+	"context"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Route is how a host reaches a peer
+type Route string
+
+const (
+	// RouteNone means the host has no connection to the peer
+	RouteNone Route = "none"
+
+	// RouteDirect means the host has a connection to the peer that does not
+	// go through a relay
+	RouteDirect Route = "direct"
+
+	// RouteRelayed means every connection to the peer goes through a relay
+	RouteRelayed Route = "relayed"
+
+	// RouteLocal means the peer is a daemon reached over the local socket
+	RouteLocal Route = "local"
+)
+
+// upgradePoll is how often UpgradeDirect checks for a direct connection
+const upgradePoll = 100 * time.Millisecond
+
+// Route returns how the host reaches peerID right now
+func (h *Host) Route(peerID string) Route {
+	if h.IsLocal(peerID) {
+		return RouteLocal
+	}
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return RouteNone
+	}
+
+	route := RouteNone
+	for _, conn := range h.host.Network().ConnsToPeer(id) {
+		if _, err := conn.RemoteMultiaddr().ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+			route = RouteRelayed
+			continue
+		}
+		return RouteDirect
+	}
+	return route
+}
+
+// UpgradeDirect connects to peerID if the host is not connected yet and,
+// if the peer is only reached through a relay, waits up to timeout for a
+// direct connection. It dials the addresses of the peer itself meanwhile,
+// while the peer may hole punch from its side of the relayed connection.
+// Streams opened afterwards use the direct connection. It returns the
+// route the host ends up with.
+func (h *Host) UpgradeDirect(ctx context.Context, peerID string, timeout time.Duration) Route {
+	route := h.Route(peerID)
+	if route == RouteDirect || route == RouteLocal {
+		return route
+	}
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return route
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if route == RouteNone {
+		if err := h.host.Connect(ctx, peer.AddrInfo{ID: id}); err != nil {
+			h.logger.Debug("failed to connect before upgrading", "peer", peerID, "error", err)
+		}
+		if route = h.Route(peerID); route != RouteRelayed {
+			return route
+		}
+	}
+
+	h.logger.Debug("peer reached through a relay, trying a direct connection", "peer", peerID)
+	crash.Go(h.logger, "p2p.upgrade", func() {
+		dialCtx := network.WithForceDirectDial(ctx, "upgrade relayed connection")
+		if _, err := h.host.Network().DialPeer(dialCtx, id); err != nil && ctx.Err() == nil {
+			h.logger.Debug("direct dial failed, waiting for a hole punch", "peer", peerID, "error", err)
+		}
+	})
+
+	ticker := time.NewTicker(upgradePoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return h.Route(peerID)
+		case <-ticker.C:
+			if route := h.Route(peerID); route != RouteRelayed {
+				return route
+			}
+		}
+	}
+}
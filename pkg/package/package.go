@@ -8,13 +8,27 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/asjdf/p2p-playground-lite/pkg/platform"
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
 	"gopkg.in/yaml.v3"
 )
 
+// ChecksumsFile is the file listing the SHA-256 of every other file of a
+// package, in the format of sha256sum. Pack writes it as the first entry of
+// the package, so it is covered by the package signature, and Unpack checks
+// each file against it.
+const ChecksumsFile = "CHECKSUMS.sha256"
+
 // Manager implements package management
 type Manager struct{}
 
@@ -23,17 +37,29 @@ func New() *Manager {
 	return &Manager{}
 }
 
-// Pack creates a tar.gz package from an application directory
+// Pack creates a tar.gz package from an application directory, next to it
 func (m *Manager) Pack(ctx context.Context, appDir string) (string, error) {
+	return m.PackTo(ctx, appDir, filepath.Dir(appDir))
+}
+
+// PackTo creates a tar.gz package from an application directory in outDir
+func (m *Manager) PackTo(ctx context.Context, appDir string, outDir string) (string, error) {
 	// Read manifest
-	manifest, err := m.readManifest(filepath.Join(appDir, "manifest.yaml"))
+	manifest, err := m.ReadManifest(filepath.Join(appDir, "manifest.yaml"))
+	if err != nil {
+		return "", err
+	}
+
+	// Record the platform of the entrypoint, so nodes that cannot run it
+	// refuse the package instead of failing to exec it
+	manifestData, err := recordPlatform(appDir, manifest)
 	if err != nil {
 		return "", err
 	}
 
 	// Create output package path
 	pkgName := fmt.Sprintf("%s-%s.tar.gz", manifest.Name, manifest.Version)
-	pkgPath := filepath.Join(filepath.Dir(appDir), pkgName)
+	pkgPath := filepath.Join(outDir, pkgName)
 
 	// Create tar.gz file
 	outFile, err := os.Create(pkgPath)
@@ -48,6 +74,25 @@ func (m *Manager) Pack(ctx context.Context, appDir string) (string, error) {
 	tarWriter := tar.NewWriter(gzWriter)
 	defer func() { _ = tarWriter.Close() }()
 
+	// List the checksums of the files first, so Unpack can check each file
+	// as it extracts it
+	checksums, err := packChecksums(appDir, manifestData)
+	if err != nil {
+		return "", types.WrapError(err, "failed to calculate file checksums")
+	}
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     ChecksumsFile,
+		Mode:     0644,
+		Size:     int64(len(checksums)),
+		ModTime:  time.Now(),
+	}); err != nil {
+		return "", types.WrapError(err, "failed to pack checksums")
+	}
+	if _, err := tarWriter.Write(checksums); err != nil {
+		return "", types.WrapError(err, "failed to pack checksums")
+	}
+
 	// Walk directory and add files
 	err = filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -60,8 +105,8 @@ func (m *Manager) Pack(ctx context.Context, appDir string) (string, error) {
 			return err
 		}
 
-		// Skip root directory
-		if relPath == "." {
+		// Skip root directory, and checksums left from unpacking a package
+		if relPath == "." || relPath == ChecksumsFile {
 			return nil
 		}
 
@@ -72,6 +117,16 @@ func (m *Manager) Pack(ctx context.Context, appDir string) (string, error) {
 		}
 		header.Name = relPath
 
+		// The manifest with the recorded platform replaces the one on disk
+		if relPath == "manifest.yaml" && manifestData != nil {
+			header.Size = int64(len(manifestData))
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return err
+			}
+			_, err := tarWriter.Write(manifestData)
+			return err
+		}
+
 		// Write header
 		if err := tarWriter.WriteHeader(header); err != nil {
 			return err
@@ -100,7 +155,151 @@ func (m *Manager) Pack(ctx context.Context, appDir string) (string, error) {
 	return pkgPath, nil
 }
 
-// Unpack extracts a package to a destination directory
+// SourceHash calculates a SHA-256 hash of the files PackTo would pack from an
+// application directory: their paths, modes and contents. Modification times
+// are left out, so touching a file does not change the hash.
+func (m *Manager) SourceHash(appDir string) (string, error) {
+	hash := sha256.New()
+	err := filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(appDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." || relPath == ChecksumsFile {
+			return nil
+		}
+		_, _ = fmt.Fprintf(hash, "%s\x00%o\x00", filepath.ToSlash(relPath), info.Mode())
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			_, _ = io.WriteString(hash, target)
+		case info.Mode().IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = file.Close() }()
+
+			_, _ = fmt.Fprintf(hash, "%d\x00", info.Size())
+			if _, err := io.Copy(hash, file); err != nil {
+				return err
+			}
+		}
+		_, _ = hash.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", types.WrapError(err, "failed to hash directory")
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// recordPlatform detects the platform of the entrypoint of a manifest that
+// does not set one, and returns the manifest file of appDir with it added.
+// It returns nil if the manifest is packed unchanged, including when the
+// entrypoint cannot be read.
+func recordPlatform(appDir string, manifest *types.Manifest) ([]byte, error) {
+	if manifest.Platform != "" {
+		return nil, nil
+	}
+	detected, err := platform.Detect(filepath.Join(appDir, manifest.Entrypoint))
+	if err != nil {
+		return nil, nil
+	}
+	manifest.Platform = detected
+
+	data, err := os.ReadFile(filepath.Join(appDir, "manifest.yaml"))
+	if err != nil {
+		return nil, types.WrapError(err, "failed to read manifest")
+	}
+	// Edit the document rather than marshal the manifest, to keep its comments
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse manifest: %w", types.ErrInvalidManifest)
+	}
+	root := doc.Content[0]
+	root.Content = append(root.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: "platform"},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: detected},
+	)
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to write manifest")
+	}
+	return out, nil
+}
+
+// packChecksums returns the checksums file of the regular files PackTo would
+// pack from an application directory, with manifestData as the manifest
+// file unless it is nil
+func packChecksums(appDir string, manifestData []byte) ([]byte, error) {
+	var b strings.Builder
+	err := filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(appDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == ChecksumsFile {
+			return nil
+		}
+		if relPath == "manifest.yaml" && manifestData != nil {
+			sum := sha256.Sum256(manifestData)
+			_, _ = fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(sum[:]), filepath.ToSlash(relPath))
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, file); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(hash.Sum(nil)), filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+// parseChecksums parses a checksums file into the SHA-256 of each path
+func parseChecksums(data []byte) (map[string]string, error) {
+	checksums := make(map[string]string)
+	for i, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok || len(sum) != sha256.Size*2 || name == "" {
+			return nil, fmt.Errorf("%w: malformed line %d of %s", types.ErrInvalidPackage, i+1, ChecksumsFile)
+		}
+		checksums[name] = strings.ToLower(sum)
+	}
+	return checksums, nil
+}
+
+// Unpack extracts a package to a destination directory. If the package
+// lists the checksums of its files, every file is checked against them as it
+// is extracted, and an error wrapping types.ErrInvalidChecksum names the
+// files that do not match, are missing or are not listed.
 func (m *Manager) Unpack(ctx context.Context, pkgPath string, destDir string) (*types.Manifest, error) {
 	// Open package file
 	file, err := os.Open(pkgPath)
@@ -121,7 +320,9 @@ func (m *Manager) Unpack(ctx context.Context, pkgPath string, destDir string) (*
 
 	// Extract files
 	var manifest *types.Manifest
-	for {
+	var checksums map[string]string // nil for packages made before checksums were listed
+	var corrupt []string
+	for entry := 0; ; entry++ {
 		header, err := tarReader.Next()
 		if err == io.EOF {
 			break
@@ -132,6 +333,28 @@ func (m *Manager) Unpack(ctx context.Context, pkgPath string, destDir string) (*
 
 		// Create target path
 		target := filepath.Join(destDir, header.Name)
+		name := filepath.ToSlash(header.Name)
+
+		if name == ChecksumsFile {
+			// Files before the list would go unchecked
+			if entry != 0 {
+				return nil, fmt.Errorf("%w: %s is not the first file of the package", types.ErrInvalidPackage, ChecksumsFile)
+			}
+			data, err := io.ReadAll(tarReader)
+			if err != nil {
+				return nil, types.WrapError(err, "failed to read checksums")
+			}
+			if checksums, err = parseChecksums(data); err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(destDir, 0755); err != nil {
+				return nil, types.WrapError(err, "failed to create directory")
+			}
+			if err := os.WriteFile(target, data, 0644); err != nil {
+				return nil, types.WrapError(err, "failed to write file")
+			}
+			continue
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
@@ -151,19 +374,40 @@ func (m *Manager) Unpack(ctx context.Context, pkgPath string, destDir string) (*
 				return nil, types.WrapError(err, "failed to create file")
 			}
 
-			if _, err := io.Copy(outFile, tarReader); err != nil {
+			hash := sha256.New()
+			if _, err := io.Copy(io.MultiWriter(outFile, hash), tarReader); err != nil {
 				_ = outFile.Close()
 				return nil, types.WrapError(err, "failed to write file")
 			}
 			_ = outFile.Close()
 
+			if checksums != nil {
+				expected, listed := checksums[name]
+				switch {
+				case !listed:
+					corrupt = append(corrupt, name+" (not listed)")
+				case hex.EncodeToString(hash.Sum(nil)) != expected:
+					corrupt = append(corrupt, name+" (modified)")
+				}
+				delete(checksums, name)
+			}
+
 			// Read manifest if this is the manifest file
 			if header.Name == "manifest.yaml" {
-				manifest, _ = m.readManifest(target)
+				manifest, _ = m.ReadManifest(target)
 			}
 		}
 	}
 
+	// Keep extracting after a mismatch, to name every damaged file
+	for name := range checksums {
+		corrupt = append(corrupt, name+" (missing)")
+	}
+	if len(corrupt) > 0 {
+		sort.Strings(corrupt)
+		return nil, fmt.Errorf("%w: files do not match %s: %s", types.ErrInvalidChecksum, ChecksumsFile, strings.Join(corrupt, ", "))
+	}
+
 	if manifest == nil {
 		return nil, types.ErrInvalidManifest
 	}
@@ -227,8 +471,72 @@ func (m *Manager) GetManifest(ctx context.Context, pkgPath string) (*types.Manif
 	return nil, types.ErrInvalidManifest
 }
 
-// readManifest reads a manifest file
-func (m *Manager) readManifest(path string) (*types.Manifest, error) {
+// FileChecksums returns the hex-encoded SHA-256 of each regular file of an
+// application directory or package, by slash-separated path. The checksums
+// file of a package is left out, and the manifest of a directory is the one
+// PackTo would pack.
+func (m *Manager) FileChecksums(ctx context.Context, path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to open package")
+	}
+	if info.IsDir() {
+		manifest, err := m.ReadManifest(filepath.Join(path, "manifest.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		manifestData, err := recordPlatform(path, manifest)
+		if err != nil {
+			return nil, err
+		}
+		data, err := packChecksums(path, manifestData)
+		if err != nil {
+			return nil, types.WrapError(err, "failed to calculate file checksums")
+		}
+		if len(data) == 0 {
+			return map[string]string{}, nil
+		}
+		return parseChecksums(data)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to open package")
+	}
+	defer func() { _ = file.Close() }()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, types.WrapError(err, "invalid gzip format")
+	}
+	defer func() { _ = gzReader.Close() }()
+
+	checksums := make(map[string]string)
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, types.WrapError(err, "failed to read tar")
+		}
+
+		name := filepath.ToSlash(header.Name)
+		if header.Typeflag != tar.TypeReg || name == ChecksumsFile {
+			continue
+		}
+		hash := sha256.New()
+		if _, err := io.Copy(hash, tarReader); err != nil {
+			return nil, types.WrapError(err, "failed to read "+name)
+		}
+		checksums[name] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return checksums, nil
+}
+
+// ReadManifest reads and validates a manifest file
+func (m *Manager) ReadManifest(path string) (*types.Manifest, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, types.WrapError(err, "failed to read manifest")
@@ -239,18 +547,142 @@ func (m *Manager) readManifest(path string) (*types.Manifest, error) {
 		return nil, types.WrapError(err, "failed to parse manifest")
 	}
 
-	// Validate required fields
+	if err := ValidateManifest(&manifest); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// ValidateManifest checks that a manifest has all required fields
+func ValidateManifest(manifest *types.Manifest) error {
 	if manifest.Name == "" {
-		return nil, fmt.Errorf("manifest missing name: %w", types.ErrInvalidManifest)
+		return fmt.Errorf("manifest missing name: %w", types.ErrInvalidManifest)
 	}
 	if manifest.Version == "" {
-		return nil, fmt.Errorf("manifest missing version: %w", types.ErrInvalidManifest)
+		return fmt.Errorf("manifest missing version: %w", types.ErrInvalidManifest)
 	}
 	if manifest.Entrypoint == "" {
-		return nil, fmt.Errorf("manifest missing entrypoint: %w", types.ErrInvalidManifest)
+		return fmt.Errorf("manifest missing entrypoint: %w", types.ErrInvalidManifest)
 	}
+	if manifest.Network != nil {
+		if err := validateNetwork(manifest.Network); err != nil {
+			return err
+		}
+	}
+	for _, device := range manifest.Devices {
+		if err := validateDevice(device); err != nil {
+			return err
+		}
+	}
+	if manifest.Topics != nil {
+		if err := validateTopics(manifest.Topics); err != nil {
+			return err
+		}
+	}
+	if err := validatePorts(manifest); err != nil {
+		return err
+	}
+	if manifest.Interpreter != "" && !filepath.IsAbs(manifest.Interpreter) && strings.ContainsRune(manifest.Interpreter, '/') {
+		return fmt.Errorf("interpreter %q must be an absolute path or a name: %w", manifest.Interpreter, types.ErrInvalidManifest)
+	}
+	if manifest.Platform != "" {
+		if err := platform.Validate(manifest.Platform); err != nil {
+			return fmt.Errorf("platform %q must be any or GOOS/GOARCH: %w", manifest.Platform, types.ErrInvalidManifest)
+		}
+	}
+	switch manifest.OnFailure {
+	case "", types.OnFailureRollback, types.OnFailureLeave:
+	default:
+		return fmt.Errorf("on_failure %q must be %s or %s: %w", manifest.OnFailure, types.OnFailureRollback, types.OnFailureLeave, types.ErrInvalidManifest)
+	}
+	return nil
+}
 
-	return &manifest, nil
+// validatePorts checks the port and volume names of a manifest and the
+// templates of its arguments, which are resolved when the app starts
+func validatePorts(manifest *types.Manifest) error {
+	seen := make(map[string]bool)
+	for _, name := range manifest.Ports {
+		if !portNamePattern.MatchString(name) || seen[name] {
+			return fmt.Errorf("port name %q must be unique and lowercase letters, digits, - or _: %w", name, types.ErrInvalidManifest)
+		}
+		seen[name] = true
+	}
+	volumes := make(map[string]bool)
+	for _, name := range manifest.Volumes {
+		if !portNamePattern.MatchString(name) || volumes[name] {
+			return fmt.Errorf("volume name %q must be unique and lowercase letters, digits, - or _: %w", name, types.ErrInvalidManifest)
+		}
+		volumes[name] = true
+	}
+	for _, arg := range manifest.Args {
+		if !strings.Contains(arg, "{{") {
+			continue
+		}
+		if _, err := template.New("arg").Parse(arg); err != nil {
+			return fmt.Errorf("argument %q is not a valid template: %w", arg, types.ErrInvalidManifest)
+		}
+	}
+	return nil
+}
+
+// portNamePattern matches the names of manifest ports and volumes
+var portNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// validateTopics checks the topic patterns of a manifest
+func validateTopics(acl *types.TopicACL) error {
+	for _, pattern := range append(append([]string{}, acl.Publish...), acl.Subscribe...) {
+		if pattern == "" {
+			return fmt.Errorf("topic pattern must not be empty: %w", types.ErrInvalidManifest)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("topic %q is not a valid pattern: %w", pattern, types.ErrInvalidManifest)
+		}
+	}
+	return nil
+}
+
+// validateDevice checks a manifest device request
+func validateDevice(device string) error {
+	if !strings.HasPrefix(device, "/dev/") || filepath.Clean(device) != device {
+		return fmt.Errorf("device %q must be a clean path under /dev: %w", device, types.ErrInvalidManifest)
+	}
+	if _, err := filepath.Match(device, ""); err != nil {
+		return fmt.Errorf("device %q is not a valid pattern: %w", device, types.ErrInvalidManifest)
+	}
+	return nil
+}
+
+// validateNetwork checks a manifest network policy
+func validateNetwork(policy *types.NetworkPolicy) error {
+	switch policy.Egress {
+	case "", types.EgressAllow, types.EgressDeny:
+	default:
+		return fmt.Errorf("network egress must be %q or %q, got %q: %w", types.EgressAllow, types.EgressDeny, policy.Egress, types.ErrInvalidManifest)
+	}
+
+	if len(policy.Allow) > 0 && policy.Egress != types.EgressDeny {
+		return fmt.Errorf("network allow rules require egress: %s: %w", types.EgressDeny, types.ErrInvalidManifest)
+	}
+
+	for _, rule := range policy.Allow {
+		if ip, _, err := net.ParseCIDR(rule.CIDR); err != nil || ip.To4() == nil {
+			return fmt.Errorf("network allow rule has invalid IPv4 CIDR %q: %w", rule.CIDR, types.ErrInvalidManifest)
+		}
+		switch rule.Protocol {
+		case "", "tcp", "udp":
+		default:
+			return fmt.Errorf("network allow rule protocol must be tcp or udp, got %q: %w", rule.Protocol, types.ErrInvalidManifest)
+		}
+		for _, port := range rule.Ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("network allow rule has invalid port %d: %w", port, types.ErrInvalidManifest)
+			}
+		}
+	}
+
+	return nil
 }
 
 // CalculateChecksum calculates SHA-256 checksum of a package
@@ -0,0 +1,314 @@
+package pex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// MaxPeers bounds the peers shared in one exchange
+const MaxPeers = 100
+
+// maxAddrs bounds the addresses shared for one peer
+const maxAddrs = 8
+
+// exchangeTimeout bounds one exchange, and connecting to each peer learned from it
+const exchangeTimeout = 10 * time.Second
+
+// Peer is a cluster member shared in an exchange
+type Peer struct {
+	ID     string            `json:"id"`
+	Addrs  []string          `json:"addrs"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// peerList is the signed content of an exchange
+type peerList struct {
+	Peers     []Peer `json:"peers"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// message carries a peer list and its sender's signature of it
+type message struct {
+	List      []byte `json:"list"`
+	Signature []byte `json:"signature"`
+}
+
+// Service exchanges the known cluster members with every daemon the host
+// connects to, and connects to the members it learns about. A daemon thereby
+// finds the whole cluster from a single bootstrap peer, without the DHT.
+type Service struct {
+	host   host.Host
+	labels map[string]string
+	client bool
+	logger types.Logger
+
+	mu      sync.Mutex
+	members map[peer.ID]map[string]string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New creates a peer exchange service for a cluster member with the given labels
+func New(h host.Host, labels map[string]string, logger types.Logger) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		host:    h,
+		labels:  labels,
+		logger:  logger,
+		members: make(map[peer.ID]map[string]string),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// NewClient creates a peer exchange service that learns the cluster members
+// without being one itself, as the controller does
+func NewClient(h host.Host, logger types.Logger) *Service {
+	s := New(h, nil, logger)
+	s.client = true
+	return s
+}
+
+// Start starts exchanging with newly identified peers. The exchanges other
+// daemons start are answered by HandleStream, which the owner of a cluster
+// member serves on consts.PeerExchangeProtocolID.
+func (s *Service) Start() error {
+	sub, err := s.host.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted))
+	if err != nil {
+		return types.WrapError(err, "failed to subscribe to identify events")
+	}
+
+	context.AfterFunc(s.ctx, func() { _ = sub.Close() })
+	crash.Loop(s.ctx.Done(), s.logger, "pex.identify", func() {
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case e, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				evt := e.(event.EvtPeerIdentificationCompleted)
+				// The dialing side starts the exchange; both sides learn from it
+				if evt.Conn.Stat().Direction != network.DirOutbound {
+					continue
+				}
+				if !slices.Contains(evt.Protocols, protocol.ID(consts.PeerExchangeProtocolID)) {
+					continue
+				}
+				crash.Go(s.logger, "pex.exchange", func() { s.exchange(evt.Peer) })
+			}
+		}
+	})
+
+	// Catch up with peers identified before we subscribed, such as bootstrap peers
+	for _, p := range s.host.Network().Peers() {
+		if !dialed(s.host, p) {
+			continue
+		}
+		if ok, err := s.host.Peerstore().SupportsProtocols(p, protocol.ID(consts.PeerExchangeProtocolID)); err == nil && len(ok) > 0 {
+			crash.Go(s.logger, "pex.exchange", func() { s.exchange(p) })
+		}
+	}
+	return nil
+}
+
+// dialed reports whether h has an outbound connection to p
+func dialed(h host.Host, p peer.ID) bool {
+	for _, conn := range h.Network().ConnsToPeer(p) {
+		if conn.Stat().Direction == network.DirOutbound {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops exchanging
+func (s *Service) Stop() {
+	s.cancel()
+}
+
+// Peers returns the known cluster members currently connected
+func (s *Service) Peers() []Peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []Peer
+	for id, labels := range s.members {
+		if s.host.Network().Connectedness(id) != network.Connected {
+			continue
+		}
+		result = append(result, Peer{ID: id.String(), Addrs: s.addrs(id), Labels: labels})
+	}
+	return result
+}
+
+// exchange sends our peer list to p and learns from its list
+func (s *Service) exchange(p peer.ID) {
+	ctx, cancel := context.WithTimeout(s.ctx, exchangeTimeout)
+	defer cancel()
+
+	stream, err := s.host.NewStream(ctx, p, protocol.ID(consts.PeerExchangeProtocolID))
+	if err != nil {
+		s.logger.Debug("failed to open peer exchange stream", "peer", p, "error", err)
+		return
+	}
+	defer func() { _ = stream.Close() }()
+	_ = stream.SetDeadline(time.Now().Add(exchangeTimeout))
+
+	if err := s.send(stream); err != nil {
+		s.logger.Debug("failed to send peer list", "peer", p, "error", err)
+		return
+	}
+	if err := s.receive(stream); err != nil {
+		s.logger.Debug("failed to receive peer list", "peer", p, "error", err)
+	}
+}
+
+// HandleStream answers an exchange started by another daemon
+func (s *Service) HandleStream(stream network.Stream) {
+	defer func() { _ = stream.Close() }()
+	_ = stream.SetDeadline(time.Now().Add(exchangeTimeout))
+
+	if err := s.receive(stream); err != nil {
+		s.logger.Debug("failed to receive peer list", "peer", stream.Conn().RemotePeer(), "error", err)
+		return
+	}
+	if err := s.send(stream); err != nil {
+		s.logger.Debug("failed to send peer list", "peer", stream.Conn().RemotePeer(), "error", err)
+	}
+}
+
+// send writes our signed peer list: ourselves and the members we are connected to.
+// Clients only ask, so they send an empty list.
+func (s *Service) send(w io.Writer) error {
+	list := peerList{Timestamp: time.Now().Unix()}
+	if !s.client {
+		list.Peers = append(list.Peers, Peer{ID: s.host.ID().String(), Addrs: s.addrs(s.host.ID()), Labels: s.labels})
+		for _, p := range s.Peers() {
+			if len(list.Peers) >= MaxPeers {
+				break
+			}
+			list.Peers = append(list.Peers, p)
+		}
+	}
+
+	data, err := json.Marshal(&list)
+	if err != nil {
+		return fmt.Errorf("failed to marshal peer list: %w", err)
+	}
+	key := s.host.Peerstore().PrivKey(s.host.ID())
+	if key == nil {
+		return fmt.Errorf("%w: no private key for host", types.ErrInternal)
+	}
+	signature, err := key.Sign(data)
+	if err != nil {
+		return types.WrapError(err, "failed to sign peer list")
+	}
+	return wire.WriteMessage(w, &message{List: data, Signature: signature})
+}
+
+// receive reads the signed peer list of the stream's remote peer and
+// connects to the members we do not know yet
+func (s *Service) receive(stream network.Stream) error {
+	var msg message
+	if err := wire.ReadMessage(stream, &msg); err != nil {
+		return err
+	}
+	from := stream.Conn().RemotePeer()
+	if err := verify(stream.Conn().RemotePublicKey(), &msg); err != nil {
+		return err
+	}
+
+	var list peerList
+	if err := json.Unmarshal(msg.List, &list); err != nil {
+		return fmt.Errorf("%w: invalid peer list: %w", types.ErrInvalidInput, err)
+	}
+	if len(list.Peers) > MaxPeers {
+		list.Peers = list.Peers[:MaxPeers]
+	}
+
+	var learned []peer.AddrInfo
+	for _, p := range list.Peers {
+		id, err := peer.Decode(p.ID)
+		if err != nil || id == s.host.ID() {
+			continue
+		}
+		info := peer.AddrInfo{ID: id}
+		for _, addr := range p.Addrs[:min(len(p.Addrs), maxAddrs)] {
+			if maddr, err := multiaddr.NewMultiaddr(addr); err == nil {
+				info.Addrs = append(info.Addrs, maddr)
+			}
+		}
+
+		s.mu.Lock()
+		s.members[id] = p.Labels
+		s.mu.Unlock()
+
+		if id == from || s.host.Network().Connectedness(id) == network.Connected {
+			continue
+		}
+		learned = append(learned, info)
+	}
+
+	for _, info := range learned {
+		s.host.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.AddressTTL)
+		go func(info peer.AddrInfo) {
+			ctx, cancel := context.WithTimeout(s.ctx, exchangeTimeout)
+			defer cancel()
+			if err := s.host.Connect(ctx, info); err != nil {
+				s.logger.Debug("failed to connect to exchanged peer", "peer", info.ID, "error", err)
+				return
+			}
+			s.logger.Info("connected to peer learned from peer exchange", "peer", info.ID, "via", from)
+		}(info)
+	}
+	return nil
+}
+
+// addrs returns the addresses of p worth sharing
+func (s *Service) addrs(p peer.ID) []string {
+	var maddrs []multiaddr.Multiaddr
+	if p == s.host.ID() {
+		maddrs = s.host.Addrs()
+	} else {
+		maddrs = s.host.Peerstore().Addrs(p)
+	}
+
+	var result []string
+	for _, maddr := range maddrs[:min(len(maddrs), maxAddrs)] {
+		result = append(result, maddr.String())
+	}
+	return result
+}
+
+// verify checks that msg was signed by key
+func verify(key crypto.PubKey, msg *message) error {
+	if key == nil {
+		return fmt.Errorf("%w: unknown public key of peer", types.ErrInvalidSignature)
+	}
+	ok, err := key.Verify(msg.List, msg.Signature)
+	if err != nil || !ok {
+		return fmt.Errorf("%w: peer list is not signed by the sending peer", types.ErrInvalidSignature)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package pex_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/pex"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+func newHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	return h
+}
+
+func start(t *testing.T, s *pex.Service) {
+	t.Helper()
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(s.Stop)
+}
+
+// member starts a cluster member on h, answering exchanges as a daemon does
+func member(t *testing.T, h host.Host, labels map[string]string) *pex.Service {
+	t.Helper()
+	s := pex.New(h, labels, logging.Nop())
+	h.SetStreamHandler(protocol.ID(consts.PeerExchangeProtocolID), s.HandleStream)
+	start(t, s)
+	return s
+}
+
+func connect(t *testing.T, from, to host.Host) {
+	t.Helper()
+	if err := from.Connect(context.Background(), peer.AddrInfo{ID: to.ID(), Addrs: to.Addrs()}); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+}
+
+// waitConnected waits until a is connected to b
+func waitConnected(t *testing.T, a, b host.Host) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for a.Network().Connectedness(b.ID()) != network.Connected {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not connect to %s", a.ID(), b.ID())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestExchangeConnectsCluster(t *testing.T) {
+	seed, a, b := newHost(t), newHost(t), newHost(t)
+	pexSeed := member(t, seed, map[string]string{"role": "seed"})
+	member(t, a, map[string]string{"role": "a"})
+	pexB := member(t, b, map[string]string{"role": "b"})
+
+	// Both only know the seed
+	connect(t, a, seed)
+	waitMember(t, pexSeed, a.ID())
+	connect(t, b, seed)
+
+	waitConnected(t, b, a)
+	waitMember(t, pexB, a.ID())
+
+	for _, p := range pexB.Peers() {
+		if p.ID == a.ID().String() && p.Labels["role"] != "a" {
+			t.Errorf("labels of %s = %v, want role=a", p.ID, p.Labels)
+		}
+	}
+}
+
+func TestClientLearnsCluster(t *testing.T) {
+	seed, a, controller := newHost(t), newHost(t), newHost(t)
+	pexSeed := member(t, seed, nil)
+	pexA := member(t, a, nil)
+	client := pex.NewClient(controller, logging.Nop())
+	start(t, client)
+
+	connect(t, a, seed)
+	waitMember(t, pexSeed, a.ID())
+	connect(t, controller, seed)
+
+	waitConnected(t, controller, a)
+	waitMember(t, client, a.ID())
+
+	// Clients are not cluster members
+	for _, p := range pexA.Peers() {
+		if p.ID == controller.ID().String() {
+			t.Error("client was recorded as a cluster member")
+		}
+	}
+}
+
+// waitMember waits until s lists id as a member
+func waitMember(t *testing.T, s *pex.Service, id peer.ID) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		for _, p := range s.Peers() {
+			if p.ID == id.String() {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("member %s was not learned", id)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,189 @@
+// Package platform describes the operating system and architecture an app
+// is built for, as GOOS/GOARCH, so that packages of binaries are only
+// deployed to and started on nodes that can run them.
+package platform
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Any is the platform of apps that run on every node, such as scripts
+const Any = "any"
+
+// Current returns the platform of this process
+func Current() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// Validate checks that p is Any or GOOS/GOARCH, where either part may be
+// Any, e.g. "linux/arm64" or "darwin/any"
+func Validate(p string) error {
+	if p == Any {
+		return nil
+	}
+	goos, goarch, ok := strings.Cut(p, "/")
+	if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+		return fmt.Errorf("%w: platform %q must be any or GOOS/GOARCH, e.g. linux/arm64", types.ErrInvalidInput, p)
+	}
+	return nil
+}
+
+// Matches reports whether an app built for p runs on a node of platform
+// node. An empty p, as in packages built before platforms were recorded,
+// matches every node.
+func Matches(p, node string) bool {
+	if p == "" || p == Any {
+		return true
+	}
+	goos, goarch, _ := strings.Cut(p, "/")
+	nodeOS, nodeArch, _ := strings.Cut(node, "/")
+	return (goos == Any || goos == nodeOS) && (goarch == Any || goarch == nodeArch)
+}
+
+// Specific reports whether p limits the nodes an app runs on
+func Specific(p string) bool {
+	return !Matches(p, Any+"/"+Any)
+}
+
+// Check returns an error wrapping types.ErrUnavailable unless an app built
+// for p runs on this node
+func Check(p string) error {
+	if !Matches(p, Current()) {
+		return fmt.Errorf("%w: the app is built for %s and cannot run on this %s node", types.ErrUnavailable, p, Current())
+	}
+	return nil
+}
+
+// CheckExecutable checks that the executable at path, recorded as built for
+// p, runs on this node. Without a recorded platform the executable itself is
+// inspected, so packages built before platforms were recorded fail with a
+// clear error too. Files that cannot be read are left for exec to report.
+func CheckExecutable(p string, path string) error {
+	if p == "" {
+		detected, err := Detect(path)
+		if err != nil {
+			return nil
+		}
+		p = detected
+	}
+	return Check(p)
+}
+
+// Detect returns the platform of the executable at path: GOOS/GOARCH for
+// ELF, Mach-O and PE binaries, with Any as the architecture of universal
+// Mach-O binaries, and Any for everything else, such as scripts
+func Detect(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", types.WrapError(err, "failed to open executable")
+	}
+	defer func() { _ = file.Close() }()
+
+	if f, err := elf.NewFile(file); err == nil {
+		return elfOS(f.OSABI) + "/" + elfArch(f), nil
+	}
+	if f, err := macho.NewFile(file); err == nil {
+		return "darwin/" + machoArch(f.Cpu), nil
+	}
+	if _, err := macho.NewFatFile(file); err == nil {
+		return "darwin/" + Any, nil
+	}
+	if f, err := pe.NewFile(file); err == nil {
+		return "windows/" + peArch(f.Machine), nil
+	}
+	return Any, nil
+}
+
+// elfOS returns the GOOS of an ELF OS ABI. Linux binaries mostly carry the
+// System V ABI, and Go marks only the BSDs.
+func elfOS(abi elf.OSABI) string {
+	switch abi {
+	case elf.ELFOSABI_FREEBSD:
+		return "freebsd"
+	case elf.ELFOSABI_NETBSD:
+		return "netbsd"
+	case elf.ELFOSABI_OPENBSD:
+		return "openbsd"
+	default:
+		return "linux"
+	}
+}
+
+// elfArch returns the GOARCH of an ELF binary, or Any if it is not one Go knows
+func elfArch(f *elf.File) string {
+	little := f.ByteOrder.String() == "LittleEndian"
+	is64 := f.Class == elf.ELFCLASS64
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_386:
+		return "386"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_RISCV:
+		if is64 {
+			return "riscv64"
+		}
+	case elf.EM_PPC64:
+		if little {
+			return "ppc64le"
+		}
+		return "ppc64"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_LOONGARCH:
+		return "loong64"
+	case elf.EM_MIPS:
+		switch {
+		case is64 && little:
+			return "mips64le"
+		case is64:
+			return "mips64"
+		case little:
+			return "mipsle"
+		default:
+			return "mips"
+		}
+	}
+	return Any
+}
+
+// machoArch returns the GOARCH of a Mach-O CPU type
+func machoArch(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.Cpu386:
+		return "386"
+	case macho.CpuArm:
+		return "arm"
+	}
+	return Any
+}
+
+// peArch returns the GOARCH of a PE machine type
+func peArch(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64"
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "arm"
+	}
+	return Any
+}
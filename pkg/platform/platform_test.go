@@ -0,0 +1,92 @@
+package platform_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/platform"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestValidate(t *testing.T) {
+	for _, p := range []string{"any", "linux/arm64", "darwin/any", "any/amd64"} {
+		if err := platform.Validate(p); err != nil {
+			t.Errorf("Validate(%q) error = %v", p, err)
+		}
+	}
+	for _, p := range []string{"", "linux", "linux/", "/arm64", "linux/arm/v7"} {
+		if err := platform.Validate(p); !errors.Is(err, types.ErrInvalidInput) {
+			t.Errorf("Validate(%q) error = %v, want ErrInvalidInput", p, err)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		app  string
+		node string
+		want bool
+	}{
+		{"", "linux/amd64", true},
+		{"any", "linux/amd64", true},
+		{"linux/amd64", "linux/amd64", true},
+		{"linux/arm64", "linux/amd64", false},
+		{"darwin/amd64", "linux/amd64", false},
+		{"linux/any", "linux/riscv64", true},
+		{"any/arm64", "darwin/arm64", true},
+	}
+
+	for _, tt := range tests {
+		if got := platform.Matches(tt.app, tt.node); got != tt.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tt.app, tt.node, got, tt.want)
+		}
+	}
+	if platform.Specific("any") || platform.Specific("") || !platform.Specific("linux/any") {
+		t.Error("Specific() must hold only for platforms limiting the nodes")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := platform.Detect(exe); err != nil || got != platform.Current() {
+		t.Errorf("Detect(test binary) = %q, %v; want %q", got, err, platform.Current())
+	}
+
+	script := filepath.Join(t.TempDir(), "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho hello\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := platform.Detect(script); err != nil || got != platform.Any {
+		t.Errorf("Detect(script) = %q, %v; want any", got, err)
+	}
+
+	if _, err := platform.Detect(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Detect() of a missing file succeeded")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := platform.Check(platform.Current()); err != nil {
+		t.Errorf("Check(current) error = %v", err)
+	}
+	if err := platform.Check("plan9/mips"); !errors.Is(err, types.ErrUnavailable) {
+		t.Errorf("Check(plan9/mips) error = %v, want ErrUnavailable", err)
+	}
+
+	// Without a recorded platform the executable is inspected
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := platform.CheckExecutable("", exe); err != nil {
+		t.Errorf("CheckExecutable(test binary) error = %v", err)
+	}
+	if err := platform.CheckExecutable("", filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("CheckExecutable(missing) error = %v, want it left to exec", err)
+	}
+}
@@ -0,0 +1,191 @@
+package policy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule names reported in violations
+const (
+	RuleMaxMemory       = "max_memory_mb"
+	RuleMaxCPU          = "max_cpu_percent"
+	RuleRequiredLabels  = "required_labels"
+	RuleForbiddenEnvKey = "forbidden_env_keys"
+	RuleAllowedSigners  = "allowed_signers"
+)
+
+// Signer identifies the key that signed a package
+type Signer struct {
+	// Name is the key name (public key file name without .pub)
+	Name string
+
+	// PublicKey is the raw Ed25519 public key
+	PublicKey []byte
+}
+
+// Input is what a policy is evaluated against
+type Input struct {
+	// Manifest is the application manifest
+	Manifest *types.Manifest
+
+	// Signed reports whether the package carries a signature
+	Signed bool
+
+	// Signer identifies the signing key, or nil if unknown.
+	// The controller may not know which trusted key matches a detached signature,
+	// in which case signer rules are left to the daemon.
+	Signer *Signer
+}
+
+// Violation describes a single failed rule
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// String returns a human-readable violation
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+}
+
+// Evaluate checks input against the rules and returns all violations
+func Evaluate(rules *config.PolicyConfig, input *Input) []Violation {
+	var violations []Violation
+	m := input.Manifest
+
+	if m.Resources != nil {
+		if rules.MaxMemoryMB > 0 && m.Resources.MemoryMB > rules.MaxMemoryMB {
+			violations = append(violations, Violation{
+				Rule:    RuleMaxMemory,
+				Message: fmt.Sprintf("memory limit %d MB exceeds maximum %d MB", m.Resources.MemoryMB, rules.MaxMemoryMB),
+			})
+		}
+		if rules.MaxCPUPercent > 0 && m.Resources.CPUPercent > rules.MaxCPUPercent {
+			violations = append(violations, Violation{
+				Rule:    RuleMaxCPU,
+				Message: fmt.Sprintf("CPU limit %.0f%% exceeds maximum %.0f%%", m.Resources.CPUPercent, rules.MaxCPUPercent),
+			})
+		}
+	}
+
+	for _, key := range rules.RequiredLabels {
+		if _, ok := m.Labels[key]; !ok {
+			violations = append(violations, Violation{
+				Rule:    RuleRequiredLabels,
+				Message: fmt.Sprintf("missing required label %q", key),
+			})
+		}
+	}
+
+	envKeys := make([]string, 0, len(m.Env))
+	for key := range m.Env {
+		envKeys = append(envKeys, key)
+	}
+	sort.Strings(envKeys)
+
+	for _, key := range envKeys {
+		for _, pattern := range rules.ForbiddenEnvKeys {
+			if matched, _ := path.Match(pattern, key); matched {
+				violations = append(violations, Violation{
+					Rule:    RuleForbiddenEnvKey,
+					Message: fmt.Sprintf("environment variable %q is forbidden", key),
+				})
+				break
+			}
+		}
+	}
+
+	if v, ok := checkSigner(rules, input); !ok {
+		violations = append(violations, v)
+	}
+
+	return violations
+}
+
+// Check evaluates the rules and returns an error wrapping types.ErrPolicyViolation if any fail
+func Check(rules *config.PolicyConfig, input *Input) error {
+	violations := Evaluate(rules, input)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(violations))
+	for i, v := range violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Errorf("%w: %s", types.ErrPolicyViolation, strings.Join(msgs, "; "))
+}
+
+// checkSigner applies the first signer rule matching the application name
+func checkSigner(rules *config.PolicyConfig, input *Input) (Violation, bool) {
+	for _, rule := range rules.AllowedSigners {
+		if matched, _ := path.Match(rule.App, input.Manifest.Name); !matched {
+			continue
+		}
+
+		if !input.Signed {
+			return Violation{
+				Rule:    RuleAllowedSigners,
+				Message: fmt.Sprintf("application %q must be signed by one of: %s", input.Manifest.Name, strings.Join(rule.Signers, ", ")),
+			}, false
+		}
+
+		// Unknown signer: cannot be decided here
+		if input.Signer == nil {
+			return Violation{}, true
+		}
+
+		for _, allowed := range rule.Signers {
+			if input.Signer.matches(allowed) {
+				return Violation{}, true
+			}
+		}
+
+		return Violation{
+			Rule:    RuleAllowedSigners,
+			Message: fmt.Sprintf("signer %q is not allowed to deploy %q", input.Signer.Name, input.Manifest.Name),
+		}, false
+	}
+
+	return Violation{}, true
+}
+
+// matches reports whether the signer is identified by name or hex-encoded public key
+func (s *Signer) matches(id string) bool {
+	if s.Name != "" && s.Name == id {
+		return true
+	}
+	return len(s.PublicKey) > 0 && strings.EqualFold(hex.EncodeToString(s.PublicKey), id)
+}
+
+// IsEmpty reports whether no rules are configured
+func IsEmpty(rules *config.PolicyConfig) bool {
+	return rules.MaxMemoryMB == 0 &&
+		rules.MaxCPUPercent == 0 &&
+		len(rules.RequiredLabels) == 0 &&
+		len(rules.ForbiddenEnvKeys) == 0 &&
+		len(rules.AllowedSigners) == 0
+}
+
+// LoadFile loads policy rules from a standalone YAML file
+func LoadFile(filePath string) (*config.PolicyConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to read policy file")
+	}
+
+	var rules config.PolicyConfig
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, types.WrapError(err, "failed to parse policy file")
+	}
+
+	return &rules, nil
+}
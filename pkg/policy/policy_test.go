@@ -0,0 +1,128 @@
+package policy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/policy"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func testRules() *config.PolicyConfig {
+	return &config.PolicyConfig{
+		MaxMemoryMB:      512,
+		RequiredLabels:   []string{"team"},
+		ForbiddenEnvKeys: []string{"AWS_*", "LD_PRELOAD"},
+		AllowedSigners: []config.SignerRule{
+			{App: "payments-*", Signers: []string{"release"}},
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifest  types.Manifest
+		signed    bool
+		signer    *policy.Signer
+		wantRules []string
+	}{
+		{
+			name:     "compliant",
+			manifest: types.Manifest{Name: "web", Labels: map[string]string{"team": "infra"}},
+		},
+		{
+			name: "too much memory and missing label",
+			manifest: types.Manifest{
+				Name:      "web",
+				Resources: &types.ResourceLimits{MemoryMB: 1024},
+			},
+			wantRules: []string{policy.RuleMaxMemory, policy.RuleRequiredLabels},
+		},
+		{
+			name: "forbidden env",
+			manifest: types.Manifest{
+				Name:   "web",
+				Labels: map[string]string{"team": "infra"},
+				Env:    map[string]string{"AWS_SECRET_ACCESS_KEY": "x"},
+			},
+			wantRules: []string{policy.RuleForbiddenEnvKey},
+		},
+		{
+			name:      "unsigned restricted app",
+			manifest:  types.Manifest{Name: "payments-api", Labels: map[string]string{"team": "pay"}},
+			wantRules: []string{policy.RuleAllowedSigners},
+		},
+		{
+			name:      "wrong signer",
+			manifest:  types.Manifest{Name: "payments-api", Labels: map[string]string{"team": "pay"}},
+			signed:    true,
+			signer:    &policy.Signer{Name: "intern"},
+			wantRules: []string{policy.RuleAllowedSigners},
+		},
+		{
+			name:     "allowed signer",
+			manifest: types.Manifest{Name: "payments-api", Labels: map[string]string{"team": "pay"}},
+			signed:   true,
+			signer:   &policy.Signer{Name: "release"},
+		},
+		{
+			name:     "unknown signer deferred",
+			manifest: types.Manifest{Name: "payments-api", Labels: map[string]string{"team": "pay"}},
+			signed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := policy.Evaluate(testRules(), &policy.Input{
+				Manifest: &tt.manifest,
+				Signed:   tt.signed,
+				Signer:   tt.signer,
+			})
+
+			if len(violations) != len(tt.wantRules) {
+				t.Fatalf("got %d violations %v, want %v", len(violations), violations, tt.wantRules)
+			}
+			for i, v := range violations {
+				if v.Rule != tt.wantRules[i] {
+					t.Errorf("violation %d rule = %s, want %s", i, v.Rule, tt.wantRules[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	err := policy.Check(testRules(), &policy.Input{Manifest: &types.Manifest{Name: "web"}})
+	if !errors.Is(err, types.ErrPolicyViolation) {
+		t.Errorf("expected ErrPolicyViolation, got: %v", err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	content := `max_memory_mb: 256
+required_labels: [team]
+allowed_signers:
+  - app: "Payments-*"
+    signers: [release]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+
+	rules, err := policy.LoadFile(path)
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	if rules.MaxMemoryMB != 256 || len(rules.RequiredLabels) != 1 {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+	if rules.AllowedSigners[0].App != "Payments-*" {
+		t.Errorf("app pattern = %q, case must be preserved", rules.AllowedSigners[0].App)
+	}
+}
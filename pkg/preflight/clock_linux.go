@@ -0,0 +1,14 @@
+package preflight
+
+import "golang.org/x/sys/unix"
+
+// clockSynchronized reports whether the kernel considers the clock
+// synchronized, as NTP daemons report it
+func clockSynchronized() (synced, known bool) {
+	var tx unix.Timex
+	state, err := unix.Adjtimex(&tx)
+	if err != nil {
+		return false, false
+	}
+	return state != unix.TIME_ERROR, true
+}
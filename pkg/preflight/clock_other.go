@@ -0,0 +1,8 @@
+//go:build !linux
+
+package preflight
+
+// clockSynchronized reports whether the clock is synchronized, if known
+func clockSynchronized() (synced, known bool) {
+	return false, false
+}
@@ -0,0 +1,45 @@
+package preflight
+
+import (
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Filesystem describes the filesystem holding a directory
+type Filesystem struct {
+	// ReadOnly is set for filesystems mounted read-only, like the root of
+	// a device booting from a read-only SD card image
+	ReadOnly bool
+
+	// Network is set for filesystems whose files live on another host
+	Network bool
+
+	// Type names the filesystem type of network filesystems
+	Type string
+}
+
+// StatFilesystem describes the filesystem holding dir, or the closest of its
+// parents that exists. It returns an error wrapping types.ErrNotImplemented
+// on platforms where filesystems cannot be told apart.
+func StatFilesystem(dir string) (*Filesystem, error) {
+	existing, err := existingDir(dir)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to check filesystem")
+	}
+	return statFilesystem(existing)
+}
+
+// CheckFilesystem checks that dir, set by the config key, is not on a
+// read-only filesystem, and returns the filesystem for callers to warn about
+// network ones. The error wraps types.ErrInvalidState.
+func CheckFilesystem(key, dir string) (*Filesystem, error) {
+	fs, err := StatFilesystem(dir)
+	if err != nil {
+		return nil, err
+	}
+	if fs.ReadOnly {
+		return fs, fmt.Errorf("%w: %s %s is on a read-only filesystem; point it at a writable one", types.ErrInvalidState, key, dir)
+	}
+	return fs, nil
+}
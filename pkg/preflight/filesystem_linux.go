@@ -0,0 +1,33 @@
+package preflight
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// networkFilesystems names the network filesystem types by their statfs magic number
+var networkFilesystems = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x01021997: "9p",
+	0x00c36400: "ceph",
+	0x5346414f: "afs",
+	0x73757245: "coda",
+}
+
+// statFilesystem describes the filesystem holding the existing dir
+func statFilesystem(dir string) (*Filesystem, error) {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(dir, &fs); err != nil {
+		return nil, fmt.Errorf("failed to stat filesystem of %s: %w", dir, err)
+	}
+	name, network := networkFilesystems[uint32(fs.Type)]
+	return &Filesystem{
+		ReadOnly: fs.Flags&unix.ST_RDONLY != 0,
+		Network:  network,
+		Type:     name,
+	}, nil
+}
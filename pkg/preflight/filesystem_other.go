@@ -0,0 +1,14 @@
+//go:build !linux
+
+package preflight
+
+import (
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// statFilesystem is not supported on this platform
+func statFilesystem(dir string) (*Filesystem, error) {
+	return nil, fmt.Errorf("%w: filesystem types on this platform", types.ErrNotImplemented)
+}
@@ -0,0 +1,325 @@
+// Package preflight checks the prerequisites of a daemon before it starts,
+// so that a broken host fails early with a clear message instead of halfway
+// through startup, or much later when an app is deployed. It also checks
+// that a deployment fits on disk before its package is received.
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// StatusOK indicates the prerequisite is met
+	StatusOK Status = "ok"
+
+	// StatusWarn indicates the daemon can run, with reduced function
+	StatusWarn Status = "warn"
+
+	// StatusFail indicates the daemon cannot run
+	StatusFail Status = "fail"
+
+	// StatusSkipped indicates the check does not apply to this host or config
+	StatusSkipped Status = "skipped"
+)
+
+// MinFileDescriptors is the open file limit below which a daemon with many
+// peers, streams and apps is likely to run out
+const MinFileDescriptors = 4096
+
+// Result is the outcome of a single check
+type Result struct {
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report holds the results of every check, in the order they ran
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// add records the outcome of a check
+func (r *Report) add(check string, status Status, format string, args ...interface{}) {
+	r.Results = append(r.Results, Result{Check: check, Status: status, Message: fmt.Sprintf(format, args...)})
+}
+
+// Count returns how many checks ended with status
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns an error listing the failed checks, nil if none failed
+func (r *Report) Err() error {
+	var failed []string
+	for _, result := range r.Results {
+		if result.Status == StatusFail {
+			failed = append(failed, result.Check+": "+result.Message)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: preflight checks failed: %s", types.ErrInvalidState, strings.Join(failed, "; "))
+}
+
+// Run checks the prerequisites of a daemon configured with cfg
+func Run(cfg *config.DaemonConfig) *Report {
+	report := &Report{}
+	checkDataDirs(report, &cfg.Storage, cfg.Runtime.ScratchDir)
+	checkFilesystems(report, cfg)
+	checkFileDescriptors(report)
+	checkCgroups(report, &cfg.Runtime)
+	checkPorts(report, &cfg.Node)
+	checkClock(report, time.Now())
+	return report
+}
+
+// checkDataDirs checks that the daemon can write to its storage directories
+// and the scratch directory, if set
+func checkDataDirs(report *Report, cfg *config.StorageConfig, scratchDir string) {
+	seen := make(map[string]bool)
+	var failed []string
+	for _, dir := range []string{cfg.DataDir, cfg.PackagesDir, cfg.AppsDir, cfg.KeysDir, scratchDir} {
+		if dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if err := writable(dir); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		report.add("data_dirs", StatusFail, "%s", strings.Join(failed, "; "))
+		return
+	}
+	report.add("data_dirs", StatusOK, "%d directories writable", len(seen))
+}
+
+// checkFilesystems checks that apps are not deployed to a read-only
+// filesystem, and warns about apps and their logs on network filesystems
+func checkFilesystems(report *Report, cfg *config.DaemonConfig) {
+	dirs := []struct{ key, dir string }{
+		{"storage.apps_dir", cfg.Storage.AppsDir},
+		{"runtime.scratch_dir", cfg.Runtime.ScratchDir},
+	}
+	var failed, network []string
+	checked := 0
+	for _, d := range dirs {
+		if d.dir == "" {
+			continue
+		}
+		fs, err := CheckFilesystem(d.key, d.dir)
+		if errors.Is(err, types.ErrNotImplemented) {
+			report.add("filesystems", StatusSkipped, "filesystem types cannot be read on %s", runtime.GOOS)
+			return
+		}
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		checked++
+		if fs.Network {
+			network = append(network, fmt.Sprintf("%s %s (%s)", d.key, d.dir, fs.Type))
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		hint := ""
+		if cfg.Runtime.ScratchDir == "" {
+			hint = "; devices booting from a read-only image can keep apps on writable storage with storage.apps_dir, and logs and scratch space with runtime.scratch_dir"
+		}
+		report.add("filesystems", StatusFail, "%s%s", strings.Join(failed, "; "), hint)
+	case len(network) > 0:
+		report.add("filesystems", StatusWarn, "on a network filesystem: %s; app files and logs may be slow and unavailable when the network is",
+			strings.Join(network, ", "))
+	default:
+		report.add("filesystems", StatusOK, "%d directories on local writable filesystems", checked)
+	}
+}
+
+// writable checks that dir, or the directory it will be created in, is writable.
+// Nothing is created that the daemon would create with other permissions.
+func writable(dir string) error {
+	existing, err := existingDir(dir)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(existing, ".preflight-*")
+	if err != nil {
+		if existing != dir {
+			return fmt.Errorf("cannot create %s: %w", dir, err)
+		}
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	_ = file.Close()
+	return os.Remove(file.Name())
+}
+
+// existingDir returns dir, or the closest of its parents that exists if dir
+// has not been created yet
+func existingDir(dir string) (string, error) {
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				return "", fmt.Errorf("%s is not a directory", existing)
+			}
+			return existing, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return "", err
+		}
+		existing = parent
+	}
+}
+
+// checkCgroups checks that the manifest resource limits can be enforced
+func checkCgroups(report *Report, cfg *config.RuntimeConfig) {
+	switch {
+	case !cfg.EnableResourceLimits:
+		report.add("cgroups", StatusSkipped, "resource limits are disabled")
+	case cfg.Backend != "systemd":
+		report.add("cgroups", StatusSkipped, "resource limits are only enforced with runtime.backend systemd")
+	case runtime.GOOS != "linux":
+		report.add("cgroups", StatusWarn, "resource limits require Linux cgroup v2 and are not enforced on %s", runtime.GOOS)
+	default:
+		if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err != nil {
+			report.add("cgroups", StatusWarn, "cgroup v2 is not mounted at /sys/fs/cgroup; resource limits are not enforced")
+			return
+		}
+		report.add("cgroups", StatusOK, "cgroup v2 available")
+	}
+}
+
+// checkPorts checks that nothing else listens on the ports of the listen addresses
+func checkPorts(report *Report, cfg *config.NodeConfig) {
+	addrs := make([]multiaddr.Multiaddr, 0, len(cfg.ListenAddrs))
+	for _, s := range cfg.ListenAddrs {
+		addr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			report.add("ports", StatusFail, "invalid listen address %s: %v", s, err)
+			return
+		}
+		addrs = append(addrs, addr)
+	}
+
+	seen := make(map[string]bool)
+	var checked, inUse, warnings []string
+	for _, addr := range p2p.FilterAddrs(addrs, cfg.AddressFamily) {
+		network, hostport, ok := socketAddr(addr)
+		if !ok || seen[network+" "+hostport] {
+			continue
+		}
+		seen[network+" "+hostport] = true
+		checked = append(checked, network+" "+hostport)
+
+		if err := bind(network, hostport); err != nil {
+			if errors.Is(err, syscall.EADDRINUSE) {
+				inUse = append(inUse, network+" "+hostport)
+			} else {
+				warnings = append(warnings, fmt.Sprintf("%s %s: %v", network, hostport, err))
+			}
+		}
+	}
+
+	switch {
+	case len(inUse) > 0:
+		report.add("ports", StatusFail, "already in use: %s", strings.Join(inUse, ", "))
+	case len(warnings) > 0:
+		report.add("ports", StatusWarn, "cannot listen on %s", strings.Join(warnings, "; "))
+	case len(checked) == 0:
+		report.add("ports", StatusSkipped, "no fixed ports to check")
+	default:
+		report.add("ports", StatusOK, "available: %s", strings.Join(checked, ", "))
+	}
+}
+
+// socketAddr returns the socket a listen address binds, if it has a fixed port
+func socketAddr(addr multiaddr.Multiaddr) (network, hostport string, ok bool) {
+	ip, err := addr.ValueForProtocol(multiaddr.P_IP4)
+	if err != nil {
+		if ip, err = addr.ValueForProtocol(multiaddr.P_IP6); err != nil {
+			return "", "", false
+		}
+	}
+	network = "tcp"
+	port, err := addr.ValueForProtocol(multiaddr.P_TCP)
+	if err != nil {
+		network = "udp"
+		if port, err = addr.ValueForProtocol(multiaddr.P_UDP); err != nil {
+			return "", "", false
+		}
+	}
+	if port == "0" {
+		return "", "", false
+	}
+	return network, net.JoinHostPort(ip, port), true
+}
+
+// bind briefly listens on a socket
+func bind(network, hostport string) error {
+	if network == "tcp" {
+		listener, err := net.Listen(network, hostport)
+		if err != nil {
+			return err
+		}
+		return listener.Close()
+	}
+	conn, err := net.ListenPacket(network, hostport)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// minClock is a time the clock of a working host is past: signatures, join
+// tokens and TLS certificates are all checked against the clock
+var minClock = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// checkClock checks that the clock is plausible and synchronized
+func checkClock(report *Report, now time.Time) {
+	floor := minClock
+	if built, err := time.Parse(time.RFC3339, version.Get().Date); err == nil && built.After(floor) {
+		floor = built
+	}
+	if now.Before(floor) {
+		report.add("clock", StatusWarn, "clock reads %s, before %s when this build was made; token expiry and TLS checks will fail",
+			now.UTC().Format(time.RFC3339), floor.Format(time.RFC3339))
+		return
+	}
+	if synced, known := clockSynchronized(); known && !synced {
+		report.add("clock", StatusWarn, "clock is not synchronized; enable NTP to keep token expiry and TLS checks reliable")
+		return
+	}
+	report.add("clock", StatusOK, "%s", now.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package preflight
+
+// checkFileDescriptors checks the open file limit of the daemon. Other
+// platforms have no per-process limit to check.
+func checkFileDescriptors(report *Report) {
+	report.add("file_descriptors", StatusSkipped, "no open file limit on this platform")
+}
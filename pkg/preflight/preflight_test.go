@@ -0,0 +1,242 @@
+package preflight_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/p2p"
+	"github.com/asjdf/p2p-playground-lite/pkg/preflight"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// testConfig returns a daemon config with storage below a temporary
+// directory and no fixed listen ports
+func testConfig(t *testing.T) *config.DaemonConfig {
+	dir := filepath.Join(t.TempDir(), "data")
+	cfg := &config.DaemonConfig{}
+	cfg.Storage.DataDir = dir
+	cfg.Storage.PackagesDir = filepath.Join(dir, "packages")
+	cfg.Storage.AppsDir = filepath.Join(dir, "apps")
+	cfg.Storage.KeysDir = filepath.Join(dir, "keys")
+	cfg.Node.ListenAddrs = []string{"/ip4/127.0.0.1/tcp/0"}
+	return cfg
+}
+
+// result returns the result of check in report
+func result(t *testing.T, report *preflight.Report, check string) preflight.Result {
+	t.Helper()
+	for _, r := range report.Results {
+		if r.Check == check {
+			return r
+		}
+	}
+	t.Fatalf("no %s check in %+v", check, report.Results)
+	return preflight.Result{}
+}
+
+func TestRun(t *testing.T) {
+	cfg := testConfig(t)
+	report := preflight.Run(cfg)
+
+	if err := report.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if r := result(t, report, "data_dirs"); r.Status != preflight.StatusOK {
+		t.Errorf("data_dirs = %+v, want ok", r)
+	}
+	if r := result(t, report, "ports"); r.Status != preflight.StatusSkipped {
+		t.Errorf("ports = %+v, want skipped for port 0", r)
+	}
+	if r := result(t, report, "cgroups"); r.Status != preflight.StatusSkipped {
+		t.Errorf("cgroups = %+v, want skipped with resource limits disabled", r)
+	}
+	result(t, report, "file_descriptors")
+	result(t, report, "clock")
+	if r := result(t, report, "filesystems"); r.Status == preflight.StatusFail {
+		t.Errorf("filesystems = %+v, want no failure", r)
+	}
+
+	// Checking must not create the directories
+	if _, err := os.Stat(cfg.Storage.DataDir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("data dir stat error = %v, want not exist", err)
+	}
+}
+
+func TestRunDataDirNotWritable(t *testing.T) {
+	cfg := testConfig(t)
+
+	// A file where a directory is expected cannot be written to
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Storage.KeysDir = filepath.Join(blocker, "keys")
+
+	report := preflight.Run(cfg)
+	if r := result(t, report, "data_dirs"); r.Status != preflight.StatusFail || !strings.Contains(r.Message, blocker) {
+		t.Errorf("data_dirs = %+v, want fail naming %s", r, blocker)
+	}
+	err := report.Err()
+	if !errors.Is(err, types.ErrInvalidState) {
+		t.Fatalf("Err() = %v, want ErrInvalidState", err)
+	}
+	if !strings.Contains(err.Error(), "data_dirs") {
+		t.Errorf("Err() = %v, does not name the failed check", err)
+	}
+}
+
+func TestStatFilesystem(t *testing.T) {
+	// Directories not created yet are on the filesystem of their parent
+	fs, err := preflight.StatFilesystem(filepath.Join(t.TempDir(), "not", "created"))
+	if errors.Is(err, types.ErrNotImplemented) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("StatFilesystem() error = %v", err)
+	}
+	if fs.ReadOnly {
+		t.Errorf("StatFilesystem() = %+v, want a writable temporary directory", fs)
+	}
+}
+
+// readOnlyMount returns a directory on a read-only filesystem of this host,
+// skipping the test if there is none
+func readOnlyMount(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile("/proc/mounts")
+	if err != nil {
+		t.Skip("no /proc/mounts to find a read-only filesystem in")
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[3], "ro,") && fields[3] != "ro" {
+			continue
+		}
+		if info, err := os.Stat(fields[1]); err == nil && info.IsDir() {
+			return fields[1]
+		}
+	}
+	t.Skip("no read-only filesystem mounted")
+	return ""
+}
+
+func TestRunAppsDirReadOnly(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Storage.AppsDir = filepath.Join(readOnlyMount(t), "apps")
+
+	_, err := preflight.CheckFilesystem("storage.apps_dir", cfg.Storage.AppsDir)
+	if !errors.Is(err, types.ErrInvalidState) || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("CheckFilesystem() error = %v, want ErrInvalidState naming the read-only filesystem", err)
+	}
+
+	report := preflight.Run(cfg)
+	r := result(t, report, "filesystems")
+	if r.Status != preflight.StatusFail || !strings.Contains(r.Message, "storage.apps_dir") || !strings.Contains(r.Message, "runtime.scratch_dir") {
+		t.Errorf("filesystems = %+v, want fail naming storage.apps_dir and suggesting runtime.scratch_dir", r)
+	}
+}
+
+func TestRunPortInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	cfg := testConfig(t)
+	cfg.Node.ListenAddrs = []string{
+		fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port),
+		fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port),
+	}
+	report := preflight.Run(cfg)
+
+	r := result(t, report, "ports")
+	if r.Status != preflight.StatusFail {
+		t.Fatalf("ports = %+v, want fail", r)
+	}
+	if want := fmt.Sprintf("tcp 127.0.0.1:%d", port); strings.Count(r.Message, want) != 1 {
+		t.Errorf("ports message %q, want %s listed once", r.Message, want)
+	}
+	if report.Count(preflight.StatusFail) != 1 {
+		t.Errorf("Count(fail) = %d, want 1", report.Count(preflight.StatusFail))
+	}
+}
+
+func TestRunPortAddressFamily(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Addresses of the other family are not listened on, nor checked
+	cfg := testConfig(t)
+	cfg.Node.AddressFamily = p2p.AddressFamilyV6
+	cfg.Node.ListenAddrs = []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", listener.Addr().(*net.TCPAddr).Port)}
+	report := preflight.Run(cfg)
+
+	if r := result(t, report, "ports"); r.Status != preflight.StatusSkipped {
+		t.Errorf("ports = %+v, want skipped", r)
+	}
+}
+
+func TestRunInvalidListenAddr(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Node.ListenAddrs = []string{"not-a-multiaddr"}
+	report := preflight.Run(cfg)
+
+	if r := result(t, report, "ports"); r.Status != preflight.StatusFail {
+		t.Errorf("ports = %+v, want fail", r)
+	}
+}
+
+func TestRunResourceLimits(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Runtime.EnableResourceLimits = true
+	report := preflight.Run(cfg)
+
+	// The exec backend does not enforce limits, so cgroups are not needed
+	if r := result(t, report, "cgroups"); r.Status != preflight.StatusSkipped {
+		t.Errorf("cgroups = %+v, want skipped with the exec backend", r)
+	}
+
+	cfg.Runtime.Backend = "systemd"
+	report = preflight.Run(cfg)
+	if r := result(t, report, "cgroups"); r.Status == preflight.StatusSkipped || r.Status == preflight.StatusFail {
+		t.Errorf("cgroups = %+v, want ok or warn with the systemd backend", r)
+	}
+}
+
+func TestCheckSpace(t *testing.T) {
+	dir := t.TempDir()
+	apps := filepath.Join(dir, "apps", "not-created-yet")
+
+	err := preflight.CheckSpace(preflight.SpaceNeed{Dir: dir, Bytes: 1}, preflight.SpaceNeed{Dir: apps, Bytes: 1})
+	if errors.Is(err, types.ErrNotImplemented) {
+		t.Skip("free disk space is not available on this platform")
+	}
+	if err != nil {
+		t.Fatalf("CheckSpace() of 2 bytes error = %v", err)
+	}
+
+	// Both directories are on the same filesystem, so their needs add up
+	half := int64(1) << 61
+	err = preflight.CheckSpace(preflight.SpaceNeed{Dir: dir, Bytes: half}, preflight.SpaceNeed{Dir: apps, Bytes: half})
+	if !errors.Is(err, types.ErrInsufficientStorage) {
+		t.Fatalf("CheckSpace() error = %v, want ErrInsufficientStorage", err)
+	}
+	var spaceErr *preflight.InsufficientSpaceError
+	if !errors.As(err, &spaceErr) {
+		t.Fatalf("CheckSpace() error = %T, want *InsufficientSpaceError", err)
+	}
+	if spaceErr.Needed != 2*half || spaceErr.Available <= 0 || spaceErr.Available >= spaceErr.Needed {
+		t.Errorf("CheckSpace() error = %+v, want %d bytes needed and fewer available", spaceErr, 2*half)
+	}
+}
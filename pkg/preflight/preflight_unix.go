@@ -0,0 +1,20 @@
+//go:build unix
+
+package preflight
+
+import "syscall"
+
+// checkFileDescriptors checks the open file limit of the daemon
+func checkFileDescriptors(report *Report) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		report.add("file_descriptors", StatusWarn, "cannot read the open file limit: %v", err)
+		return
+	}
+	if limit.Cur < MinFileDescriptors {
+		report.add("file_descriptors", StatusWarn, "open file limit is %d, below %d; raise it with ulimit -n or LimitNOFILE",
+			limit.Cur, MinFileDescriptors)
+		return
+	}
+	report.add("file_descriptors", StatusOK, "open file limit is %d", limit.Cur)
+}
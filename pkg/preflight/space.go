@@ -0,0 +1,79 @@
+package preflight
+
+import (
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// UnpackFactor is how many times the size of a package its unpacked files
+// are assumed to take. Packages compress their files, and the factor leaves
+// headroom on top for what the app writes once it runs.
+const UnpackFactor = 3
+
+// SpaceNeed is the free space an operation needs in a directory
+type SpaceNeed struct {
+	Dir   string
+	Bytes int64
+}
+
+// InsufficientSpaceError reports a filesystem without the free space an
+// operation needs. It matches types.ErrInsufficientStorage.
+type InsufficientSpaceError struct {
+	// Dir is a directory on the filesystem
+	Dir string
+
+	// Needed and Available are in bytes
+	Needed    int64
+	Available int64
+}
+
+// Error implements the error interface
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("%v: %d bytes needed in %s, %d available", types.ErrInsufficientStorage, e.Needed, e.Dir, e.Available)
+}
+
+// Unwrap makes the error match types.ErrInsufficientStorage
+func (e *InsufficientSpaceError) Unwrap() error {
+	return types.ErrInsufficientStorage
+}
+
+// CheckSpace checks that the filesystems holding the directories of needs
+// have the space needed free, adding up the needs of directories on the
+// same filesystem. It returns an *InsufficientSpaceError for the first
+// filesystem short of space, and an error wrapping types.ErrNotImplemented
+// on platforms where free space cannot be read.
+func CheckSpace(needs ...SpaceNeed) error {
+	type filesystem struct {
+		dir       string
+		needed    int64
+		available int64
+	}
+	var order []uint64
+	filesystems := make(map[uint64]*filesystem)
+
+	for _, need := range needs {
+		dir, err := existingDir(need.Dir)
+		if err != nil {
+			return types.WrapError(err, "failed to check free space")
+		}
+		id, available, err := diskSpace(dir)
+		if err != nil {
+			return err
+		}
+		fs, ok := filesystems[id]
+		if !ok {
+			fs = &filesystem{dir: need.Dir, available: available}
+			filesystems[id] = fs
+			order = append(order, id)
+		}
+		fs.needed += need.Bytes
+	}
+
+	for _, id := range order {
+		if fs := filesystems[id]; fs.needed > fs.available {
+			return &InsufficientSpaceError{Dir: fs.dir, Needed: fs.needed, Available: fs.available}
+		}
+	}
+	return nil
+}
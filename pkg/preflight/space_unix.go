@@ -0,0 +1,30 @@
+//go:build unix
+
+package preflight
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// diskSpace returns the device of the filesystem holding dir and the bytes
+// free on it for unprivileged users
+func diskSpace(dir string) (uint64, int64, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return 0, 0, types.WrapError(err, "failed to stat directory")
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, types.WrapError(types.ErrNotImplemented, "failed to read the device of "+dir)
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return 0, 0, types.WrapError(err, "failed to stat filesystem")
+	}
+	// Space reserved for root does not count, since apps cannot use it
+	return uint64(stat.Dev), int64(fs.Bavail) * int64(fs.Bsize), nil
+}
@@ -0,0 +1,302 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// StorageKey is the storage key holding quota usage
+const StorageKey = "quota.json"
+
+// schema versions the stored quota usage
+var schema = storage.Schema{
+	Kind: "quota usage",
+	Migrations: []storage.Migration{
+		nil, // 1: the schema version was added
+	},
+}
+
+// Unsigned is the operator unsigned packages count towards
+const Unsigned = "unsigned"
+
+// window is the period deployments are counted over
+const window = time.Hour
+
+// Operator identifies who deploys a package
+type Operator struct {
+	// ID is the hex public key of the signing key, or Unsigned
+	ID string
+
+	// Name is the name of the signing key, if known
+	Name string
+}
+
+// app is a deployed app as far as quotas are concerned
+type app struct {
+	Operator     string `json:"operator"`
+	Name         string `json:"name,omitempty"`
+	PackageBytes int64  `json:"package_bytes"`
+}
+
+// state is what the tracker persists
+type state struct {
+	// Apps are the deployed apps by app name
+	Apps map[string]*app `json:"apps"`
+
+	// Deploys are the times of the deployments of the last hour by operator
+	Deploys map[string][]time.Time `json:"deploys"`
+}
+
+// Tracker accounts deployments to operators and enforces their quotas.
+// Usage is kept for every operator even without limits, so that limits
+// configured later apply to what is already deployed.
+type Tracker struct {
+	cfg     *config.QuotaConfig
+	storage types.Storage
+	mu      sync.Mutex
+	pending map[*Reservation]bool
+	now     func() time.Time
+}
+
+// Reservation holds quota for a deployment in progress until it is
+// committed or canceled
+type Reservation struct {
+	tracker  *Tracker
+	operator Operator
+	app      string
+	size     int64
+}
+
+// New creates a tracker on top of storage
+func New(cfg *config.QuotaConfig, storage types.Storage) *Tracker {
+	return &Tracker{
+		cfg:     cfg,
+		storage: storage,
+		pending: make(map[*Reservation]bool),
+		now:     time.Now,
+	}
+}
+
+// Limits returns the limits of op
+func (t *Tracker) Limits(op Operator) config.QuotaLimits {
+	// Keys are case-insensitive since the config loader lowercases them
+	for key, limits := range t.cfg.Operators {
+		if strings.EqualFold(key, op.ID) || (op.Name != "" && strings.EqualFold(key, op.Name)) {
+			return limits
+		}
+	}
+	return t.cfg.Default
+}
+
+// Reserve checks that op may deploy a package of size bytes as app, and holds
+// the quota for it. deployed are the names of the apps on the node; records
+// of apps no longer deployed are dropped. Deploying a new version of an app
+// the operator already has replaces its package size rather than adding to it.
+func (t *Tracker) Reserve(ctx context.Context, op Operator, appName string, size int64, deployed []string) (*Reservation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st, err := t.load(ctx, deployed)
+	if err != nil {
+		return nil, err
+	}
+	usage := t.usage(st, op)
+	limits := t.Limits(op)
+
+	// A new version replaces what the operator deployed before under this name
+	newApp := true
+	if prev, ok := st.Apps[appName]; ok && prev.Operator == op.ID {
+		newApp = false
+		usage.PackageBytes -= prev.PackageBytes
+	}
+	for r := range t.pending {
+		if r.operator.ID == op.ID && r.app == appName {
+			newApp = false
+		}
+	}
+
+	who := displayName(op)
+	if newApp && limits.MaxApps > 0 && usage.Apps+1 > limits.MaxApps {
+		return nil, fmt.Errorf("%w: %s has %d of %d apps deployed", types.ErrQuotaExceeded, who, usage.Apps, limits.MaxApps)
+	}
+	if limits.MaxPackageBytes > 0 && usage.PackageBytes+size > limits.MaxPackageBytes {
+		return nil, fmt.Errorf("%w: %s would use %d of %d package bytes", types.ErrQuotaExceeded, who, usage.PackageBytes+size, limits.MaxPackageBytes)
+	}
+	if limits.MaxDeploysPerHour > 0 && usage.DeploysLastHour+1 > limits.MaxDeploysPerHour {
+		return nil, fmt.Errorf("%w: %s made %d of %d deployments in the last hour", types.ErrQuotaExceeded, who, usage.DeploysLastHour, limits.MaxDeploysPerHour)
+	}
+
+	r := &Reservation{tracker: t, operator: op, app: appName, size: size}
+	t.pending[r] = true
+	return r, nil
+}
+
+// Commit accounts the deployment to the operator
+func (r *Reservation) Commit(ctx context.Context) error {
+	t := r.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, r)
+
+	st, err := t.load(ctx, nil)
+	if err != nil {
+		return err
+	}
+	st.Apps[r.app] = &app{Operator: r.operator.ID, Name: r.operator.Name, PackageBytes: r.size}
+	st.Deploys[r.operator.ID] = append(st.Deploys[r.operator.ID], t.now().UTC())
+	return t.save(ctx, st)
+}
+
+// Cancel releases the quota held for a deployment that did not happen
+func (r *Reservation) Cancel() {
+	r.tracker.mu.Lock()
+	defer r.tracker.mu.Unlock()
+	delete(r.tracker.pending, r)
+}
+
+// Usage returns the usage of every operator with apps or recent deployments,
+// sorted by name. deployed are the names of the apps on the node.
+func (t *Tracker) Usage(ctx context.Context, deployed []string) ([]*types.QuotaUsage, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st, err := t.load(ctx, deployed)
+	if err != nil {
+		return nil, err
+	}
+
+	operators := make(map[string]Operator)
+	for _, a := range st.Apps {
+		operators[a.Operator] = Operator{ID: a.Operator, Name: a.Name}
+	}
+	for id := range st.Deploys {
+		if _, ok := operators[id]; !ok {
+			operators[id] = Operator{ID: id}
+		}
+	}
+
+	list := make([]*types.QuotaUsage, 0, len(operators))
+	for _, op := range operators {
+		list = append(list, t.usage(st, op))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Operator < list[j].Operator
+	})
+	return list, nil
+}
+
+// usage sums what op uses in st and in pending reservations. Callers must hold t.mu.
+func (t *Tracker) usage(st *state, op Operator) *types.QuotaUsage {
+	limits := t.Limits(op)
+	usage := &types.QuotaUsage{
+		Operator:          op.ID,
+		Name:              op.Name,
+		DeploysLastHour:   len(st.Deploys[op.ID]),
+		MaxApps:           limits.MaxApps,
+		MaxPackageBytes:   limits.MaxPackageBytes,
+		MaxDeploysPerHour: limits.MaxDeploysPerHour,
+	}
+	for _, a := range st.Apps {
+		if a.Operator == op.ID {
+			usage.Apps++
+			usage.PackageBytes += a.PackageBytes
+		}
+	}
+
+	for r := range t.pending {
+		if r.operator.ID != op.ID {
+			continue
+		}
+		usage.DeploysLastHour++
+		if prev, ok := st.Apps[r.app]; !ok || prev.Operator != op.ID {
+			usage.Apps++
+			usage.PackageBytes += r.size
+		}
+	}
+	return usage
+}
+
+// load reads the state, dropping deployments older than an hour and, unless
+// deployed is nil, apps that are no longer deployed. Callers must hold t.mu.
+func (t *Tracker) load(ctx context.Context, deployed []string) (*state, error) {
+	st := &state{}
+	data, err := t.storage.Load(ctx, StorageKey)
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+	case err != nil:
+		return nil, types.WrapError(err, "failed to load quota usage")
+	default:
+		if err := schema.Unmarshal(data, st); err != nil {
+			return nil, types.WrapError(err, "failed to parse quota usage")
+		}
+	}
+	if st.Apps == nil {
+		st.Apps = make(map[string]*app)
+	}
+	if st.Deploys == nil {
+		st.Deploys = make(map[string][]time.Time)
+	}
+
+	if deployed != nil {
+		current := make(map[string]bool, len(deployed))
+		for _, name := range deployed {
+			current[name] = true
+		}
+		for name := range st.Apps {
+			if !current[name] {
+				delete(st.Apps, name)
+			}
+		}
+	}
+
+	cutoff := t.now().Add(-window)
+	for id, times := range st.Deploys {
+		recent := times[:0]
+		for _, at := range times {
+			if at.After(cutoff) {
+				recent = append(recent, at)
+			}
+		}
+		if len(recent) == 0 {
+			delete(st.Deploys, id)
+		} else {
+			st.Deploys[id] = recent
+		}
+	}
+	return st, nil
+}
+
+// save writes the state. Callers must hold t.mu.
+func (t *Tracker) save(ctx context.Context, st *state) error {
+	data, err := schema.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return types.WrapError(err, "failed to marshal quota usage")
+	}
+	if err := t.storage.Save(ctx, StorageKey, data); err != nil {
+		return types.WrapError(err, "failed to save quota usage")
+	}
+	return nil
+}
+
+// displayName names an operator in error messages
+func displayName(op Operator) string {
+	if op.Name != "" {
+		return fmt.Sprintf("operator %q", op.Name)
+	}
+	if len(op.ID) > 16 {
+		return "operator " + op.ID[:16]
+	}
+	return "operator " + op.ID
+}
@@ -0,0 +1,142 @@
+package quota_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/quota"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func newTracker(t *testing.T, cfg *config.QuotaConfig) *quota.Tracker {
+	t.Helper()
+	st, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	return quota.New(cfg, st)
+}
+
+// deploy reserves and commits a deployment
+func deploy(t *testing.T, tracker *quota.Tracker, op quota.Operator, app string, size int64, deployed []string) error {
+	t.Helper()
+	ctx := context.Background()
+	r, err := tracker.Reserve(ctx, op, app, size, deployed)
+	if err != nil {
+		return err
+	}
+	if err := r.Commit(ctx); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	return nil
+}
+
+func TestMaxApps(t *testing.T) {
+	tracker := newTracker(t, &config.QuotaConfig{Default: config.QuotaLimits{MaxApps: 2}})
+	alice := quota.Operator{ID: "aa", Name: "alice"}
+	bob := quota.Operator{ID: "bb", Name: "bob"}
+
+	if err := deploy(t, tracker, alice, "web", 10, nil); err != nil {
+		t.Fatalf("first app rejected: %v", err)
+	}
+	if err := deploy(t, tracker, alice, "db", 10, []string{"web"}); err != nil {
+		t.Fatalf("second app rejected: %v", err)
+	}
+	deployed := []string{"web", "db"}
+
+	// A new version of an app does not count as another app
+	if err := deploy(t, tracker, alice, "web", 10, deployed); err != nil {
+		t.Errorf("new version rejected: %v", err)
+	}
+	if err := deploy(t, tracker, alice, "cache", 10, deployed); !errors.Is(err, types.ErrQuotaExceeded) {
+		t.Errorf("third app: error = %v, want ErrQuotaExceeded", err)
+	}
+
+	// Quotas are per operator
+	if err := deploy(t, tracker, bob, "cache", 10, deployed); err != nil {
+		t.Errorf("other operator rejected: %v", err)
+	}
+
+	// Apps removed from the node no longer count
+	if err := deploy(t, tracker, alice, "queue", 10, []string{"web", "cache"}); err != nil {
+		t.Errorf("app after removal rejected: %v", err)
+	}
+}
+
+func TestMaxPackageBytesAndDeploys(t *testing.T) {
+	tracker := newTracker(t, &config.QuotaConfig{
+		Operators: map[string]config.QuotaLimits{
+			"alice":        {MaxPackageBytes: 100},
+			quota.Unsigned: {MaxDeploysPerHour: 2},
+		},
+	})
+	alice := quota.Operator{ID: "aa", Name: "Alice"}
+	unsigned := quota.Operator{ID: quota.Unsigned}
+
+	if err := deploy(t, tracker, alice, "web", 60, nil); err != nil {
+		t.Fatalf("first package rejected: %v", err)
+	}
+	// Replacing the version replaces its size
+	if err := deploy(t, tracker, alice, "web", 90, []string{"web"}); err != nil {
+		t.Errorf("new version rejected: %v", err)
+	}
+	if err := deploy(t, tracker, alice, "db", 20, []string{"web"}); !errors.Is(err, types.ErrQuotaExceeded) {
+		t.Errorf("over package bytes: error = %v, want ErrQuotaExceeded", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := deploy(t, tracker, unsigned, "demo", 1, nil); err != nil {
+			t.Fatalf("deployment %d rejected: %v", i+1, err)
+		}
+	}
+	if err := deploy(t, tracker, unsigned, "demo", 1, nil); !errors.Is(err, types.ErrQuotaExceeded) {
+		t.Errorf("over deploys per hour: error = %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestReservationsCount(t *testing.T) {
+	ctx := context.Background()
+	tracker := newTracker(t, &config.QuotaConfig{Default: config.QuotaLimits{MaxApps: 1}})
+	alice := quota.Operator{ID: "aa"}
+
+	r, err := tracker.Reserve(ctx, alice, "web", 10, nil)
+	if err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	// A concurrent deployment of another app sees the pending one
+	if _, err := tracker.Reserve(ctx, alice, "db", 10, nil); !errors.Is(err, types.ErrQuotaExceeded) {
+		t.Errorf("concurrent reservation: error = %v, want ErrQuotaExceeded", err)
+	}
+
+	r.Cancel()
+	if _, err := tracker.Reserve(ctx, alice, "db", 10, nil); err != nil {
+		t.Errorf("reservation after cancel rejected: %v", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tracker := newTracker(t, &config.QuotaConfig{Default: config.QuotaLimits{MaxApps: 5}})
+	alice := quota.Operator{ID: "aa", Name: "alice"}
+
+	if err := deploy(t, tracker, alice, "web", 60, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := deploy(t, tracker, alice, "db", 40, []string{"web"}); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := tracker.Usage(context.Background(), []string{"web", "db"})
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	if len(usage) != 1 {
+		t.Fatalf("Usage() = %d operators, want 1", len(usage))
+	}
+	got := usage[0]
+	if got.Name != "alice" || got.Apps != 2 || got.PackageBytes != 100 || got.DeploysLastHour != 2 || got.MaxApps != 5 {
+		t.Errorf("Usage() = %+v", got)
+	}
+}
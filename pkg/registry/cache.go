@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DefaultCacheMB is the size limit of the package cache of a daemon unless configured
+const DefaultCacheMB = 1024
+
+// Cache keeps the packages a daemon fetched from the registry, by digest,
+// so that deploying one again, e.g. after a rollback, does not download it
+// again. The least recently used packages are evicted beyond its size.
+type Cache struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+}
+
+// NewCache returns the cache of packages in dir, holding up to maxBytes
+func NewCache(dir string, maxBytes int64) *Cache {
+	return &Cache{dir: dir, maxBytes: maxBytes}
+}
+
+// path returns the path of the file of the package with hex digest sum
+// with suffix ext
+func (c *Cache) path(sum, ext string) string {
+	return filepath.Join(c.dir, sum+ext)
+}
+
+// Stat returns the cached package with digest. The error wraps
+// types.ErrNotFound if it is not cached.
+func (c *Cache) Stat(digest string) (*Package, error) {
+	sum, err := ParseDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stat(sum)
+}
+
+// stat reads the metadata of the cached package with hex digest sum. The
+// caller holds c.mu.
+func (c *Cache) stat(sum string) (*Package, error) {
+	data, err := os.ReadFile(c.path(sum, ".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: package %s%s is not cached", types.ErrNotFound, DigestPrefix, sum)
+	}
+	if err != nil {
+		return nil, types.WrapError(err, "failed to read cached package")
+	}
+	var pkg Package
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, types.WrapError(err, "failed to parse cached package")
+	}
+	return &pkg, nil
+}
+
+// Fetch copies the package with digest to dst, downloading it from bucket
+// unless it is cached, and returns it. A downloaded package must match its
+// digest. Bucket may be nil to use only the cached packages.
+func (c *Cache) Fetch(ctx context.Context, bucket *Bucket, digest string, dst io.Writer) (*Package, error) {
+	sum, err := ParseDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	pkg, err := c.stat(sum)
+	c.mu.Unlock()
+	if errors.Is(err, types.ErrNotFound) {
+		if bucket == nil {
+			return nil, fmt.Errorf("%w: package %s is not cached and no registry is configured", types.ErrUnavailable, digest)
+		}
+		pkg, err = c.download(ctx, bucket, sum)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy under the lock, so that no other fetch evicts the package meanwhile
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := os.Open(c.path(sum, ".tar.gz"))
+	if err != nil {
+		return nil, types.WrapError(err, "failed to open cached package")
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(dst, f); err != nil {
+		return nil, types.WrapError(err, "failed to copy cached package")
+	}
+	now := time.Now()
+	_ = os.Chtimes(f.Name(), now, now)
+	return pkg, nil
+}
+
+// download downloads the package with hex digest sum from bucket into the
+// cache, evicting the least recently used packages beyond the cache size
+func (c *Cache) download(ctx context.Context, bucket *Bucket, sum string) (*Package, error) {
+	pkg, err := bucket.Stat(ctx, DigestPrefix+sum)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return nil, types.WrapError(err, "failed to create package cache")
+	}
+
+	body, err := bucket.Open(ctx, pkg.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+	f, err := os.CreateTemp(c.dir, ".download-*")
+	if err != nil {
+		return nil, types.WrapError(err, "failed to create cached package")
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(body, pkg.Size+1))
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, types.WrapError(err, "failed to download package "+pkg.Digest)
+	}
+	if n != pkg.Size || hex.EncodeToString(hash.Sum(nil)) != sum {
+		return nil, fmt.Errorf("%w: package %s downloaded from %s does not match its digest", types.ErrInvalidChecksum, pkg.Digest, bucket)
+	}
+	meta, err := json.Marshal(pkg)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to marshal package")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Rename(f.Name(), c.path(sum, ".tar.gz")); err != nil {
+		return nil, types.WrapError(err, "failed to cache package")
+	}
+	// The metadata is written last, so only complete packages are found
+	if err := os.WriteFile(c.path(sum, ".json"), meta, 0600); err != nil {
+		return nil, types.WrapError(err, "failed to cache package")
+	}
+	c.evict(sum)
+	return pkg, nil
+}
+
+// evict deletes the least recently used packages, except the one with hex
+// digest keep, until the cache fits its size. The caller holds c.mu.
+func (c *Cache) evict(keep string) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		sum  string
+		size int64
+		used time.Time
+	}
+	var packages []cached
+	var total int64
+	for _, entry := range entries {
+		sum, ok := strings.CutSuffix(entry.Name(), ".tar.gz")
+		if !ok || strings.HasPrefix(sum, ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		packages = append(packages, cached{sum: sum, size: info.Size(), used: info.ModTime()})
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].used.Before(packages[j].used) })
+	for _, pkg := range packages {
+		if total <= c.maxBytes {
+			return
+		}
+		if pkg.sum == keep {
+			continue
+		}
+		_ = os.Remove(c.path(pkg.sum, ".json"))
+		_ = os.Remove(c.path(pkg.sum, ".tar.gz"))
+		total -= pkg.size
+	}
+}
@@ -0,0 +1,230 @@
+// Package registry keeps signed packages in an S3-compatible bucket, by the
+// SHA-256 digest of the package, so that daemons can fetch them from durable
+// storage rather than only from the controller or other nodes.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/s3"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DigestPrefix is the algorithm prefix of package digests
+const DigestPrefix = "sha256:"
+
+// maxMetaSize bounds the metadata of a package read from a bucket
+const maxMetaSize = 1 << 20
+
+// Package describes a package kept in the registry
+type Package struct {
+	// Digest is the SHA-256 of the package file, sha256:<hex>
+	Digest string `json:"digest"`
+
+	// FileName is the file name the package was pushed with
+	FileName string `json:"file_name"`
+
+	// Name and Version are those of the manifest of the package
+	Name    string `json:"name"`
+	Version string `json:"version"`
+
+	// Size is the size of the package file
+	Size int64 `json:"size"`
+
+	// Signature is the Ed25519 signature of the package, if signed
+	Signature []byte `json:"signature,omitempty"`
+
+	// PushedAt is when the package was pushed
+	PushedAt time.Time `json:"pushed_at"`
+}
+
+// Validate checks that p names a package file and has a valid digest
+func (p *Package) Validate() error {
+	if _, err := ParseDigest(p.Digest); err != nil {
+		return err
+	}
+	if p.FileName == "" || p.FileName != filepath.Base(p.FileName) || p.FileName == "." || p.FileName == ".." {
+		return fmt.Errorf("%w: invalid package file name %q", types.ErrInvalidInput, p.FileName)
+	}
+	if p.Size <= 0 {
+		return fmt.Errorf("%w: package %s has no size", types.ErrInvalidInput, p.Digest)
+	}
+	return nil
+}
+
+// ParseDigest returns the hex SHA-256 of a digest, given with or without
+// its sha256: prefix
+func ParseDigest(digest string) (string, error) {
+	sum := strings.TrimPrefix(digest, DigestPrefix)
+	if len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("%w: digest %q is not sha256:<64 hex digits>", types.ErrInvalidInput, digest)
+	}
+	if _, err := hex.DecodeString(sum); err != nil || strings.ToLower(sum) != sum {
+		return "", fmt.Errorf("%w: digest %q is not sha256:<64 hex digits>", types.ErrInvalidInput, digest)
+	}
+	return sum, nil
+}
+
+// FileDigest returns the digest of the file at path
+func FileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", types.WrapError(err, "failed to open package")
+	}
+	defer func() { _ = f.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", types.WrapError(err, "failed to read package")
+	}
+	return DigestPrefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Bucket keeps packages in an S3-compatible bucket, each as its file
+// <prefix>sha256/<hex>.tar.gz next to its metadata <prefix>sha256/<hex>.json.
+// A package is never replaced, since its key is its content.
+type Bucket struct {
+	client *s3.Client
+	prefix string
+}
+
+// New returns the bucket cfg configures, or nil if it configures none
+func New(cfg *config.S3Config) (*Bucket, error) {
+	if cfg.Endpoint == "" {
+		return nil, nil
+	}
+	client, err := s3.New(s3.Config{
+		Endpoint:        cfg.Endpoint,
+		Region:          cfg.Region,
+		Bucket:          cfg.Bucket,
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Bucket{client: client, prefix: cfg.Prefix}, nil
+}
+
+// String returns the location of the bucket, for logs and messages
+func (b *Bucket) String() string {
+	return b.client.String() + "/" + b.prefix
+}
+
+// key returns the key of the object of the package with hex digest sum
+func (b *Bucket) key(sum, ext string) string {
+	return b.prefix + "sha256/" + sum + ext
+}
+
+// Push uploads pkg, whose file is read from r, unless the bucket already
+// has it, and reports whether it was uploaded
+func (b *Bucket) Push(ctx context.Context, pkg *Package, r io.Reader) (bool, error) {
+	if err := pkg.Validate(); err != nil {
+		return false, err
+	}
+	sum, _ := ParseDigest(pkg.Digest)
+	if _, err := b.Stat(ctx, pkg.Digest); err == nil {
+		return false, nil
+	} else if !errors.Is(err, types.ErrNotFound) {
+		return false, err
+	}
+
+	meta, err := json.Marshal(pkg)
+	if err != nil {
+		return false, types.WrapError(err, "failed to marshal package")
+	}
+	if err := b.client.Put(ctx, b.key(sum, ".tar.gz"), r, pkg.Size); err != nil {
+		return false, err
+	}
+	// The metadata is uploaded last, so only complete packages are found
+	if err := b.client.Put(ctx, b.key(sum, ".json"), bytes.NewReader(meta), int64(len(meta))); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Stat returns the package with digest. The error wraps types.ErrNotFound
+// if the bucket does not have it.
+func (b *Bucket) Stat(ctx context.Context, digest string) (*Package, error) {
+	sum, err := ParseDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := b.meta(ctx, b.key(sum, ".json"))
+	if errors.Is(err, types.ErrNotFound) {
+		return nil, fmt.Errorf("%w: no package %s in %s", types.ErrNotFound, digest, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pkg.Digest != DigestPrefix+sum {
+		return nil, fmt.Errorf("%w: metadata of package %s in %s names %s", types.ErrInvalidInput, digest, b, pkg.Digest)
+	}
+	if err := pkg.Validate(); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// Open returns a reader of the file of the package with digest, which the
+// caller closes
+func (b *Bucket) Open(ctx context.Context, digest string) (io.ReadCloser, error) {
+	sum, err := ParseDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	body, _, err := b.client.Get(ctx, b.key(sum, ".tar.gz"))
+	return body, err
+}
+
+// List returns the packages in the bucket, most recently pushed first
+func (b *Bucket) List(ctx context.Context) ([]*Package, error) {
+	objects, err := b.client.List(ctx, b.prefix+"sha256/")
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []*Package
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, ".json") {
+			continue
+		}
+		pkg, err := b.meta(ctx, object.Key)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+	}
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].PushedAt.After(packages[j].PushedAt)
+	})
+	return packages, nil
+}
+
+// meta reads the package metadata at key
+func (b *Bucket) meta(ctx context.Context, key string) (*Package, error) {
+	body, _, err := b.client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	var pkg Package
+	if err := json.NewDecoder(io.LimitReader(body, maxMetaSize)).Decode(&pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package %s: %w", key, err)
+	}
+	return &pkg, nil
+}
@@ -0,0 +1,178 @@
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/registry"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// objectServer keeps the objects of a bucket in memory, path-style, and
+// counts the downloads of packages
+type objectServer struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	downloads int
+}
+
+func (s *objectServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == http.MethodPut:
+		s.objects[key], _ = io.ReadAll(r.Body)
+	case r.Method == http.MethodGet && key == "":
+		type content struct{ Key string }
+		var result struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []content
+		}
+		for k := range s.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				result.Contents = append(result.Contents, content{k})
+			}
+		}
+		_ = xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if strings.HasSuffix(key, ".tar.gz") {
+			s.downloads++
+		}
+		_, _ = w.Write(data)
+	}
+}
+
+// newBucket returns a registry backed by an in-memory object server
+func newBucket(t *testing.T) (*registry.Bucket, *objectServer) {
+	t.Helper()
+	server := &objectServer{objects: make(map[string][]byte)}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	bucket, err := registry.New(&config.S3Config{Endpoint: ts.URL, Bucket: "bucket", Prefix: "packages/", AccessKeyID: "key", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bucket, server
+}
+
+// newPackage returns a package of content
+func newPackage(name, content string) *registry.Package {
+	sum := sha256.Sum256([]byte(content))
+	return &registry.Package{
+		Digest:    registry.DigestPrefix + hex.EncodeToString(sum[:]),
+		FileName:  name + "-1.0.0.tar.gz",
+		Name:      name,
+		Version:   "1.0.0",
+		Size:      int64(len(content)),
+		Signature: []byte("signature"),
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	for _, digest := range []string{"sha256:" + sum, sum} {
+		if got, err := registry.ParseDigest(digest); err != nil || got != sum {
+			t.Errorf("ParseDigest(%q) = %q, %v", digest, got, err)
+		}
+	}
+	for _, digest := range []string{"", "sha256:abc", "sha256:" + strings.Repeat("AB", 32), "md5:" + sum, "sha256:../" + sum[3:]} {
+		if _, err := registry.ParseDigest(digest); !errors.Is(err, types.ErrInvalidInput) {
+			t.Errorf("ParseDigest(%q) error = %v, want ErrInvalidInput", digest, err)
+		}
+	}
+}
+
+func TestBucket(t *testing.T) {
+	if b, err := registry.New(&config.S3Config{}); b != nil || err != nil {
+		t.Fatalf("New() without an endpoint = %v, %v, want nil", b, err)
+	}
+	bucket, _ := newBucket(t)
+	ctx := context.Background()
+
+	pkg := newPackage("web", "package of web")
+	if pushed, err := bucket.Push(ctx, pkg, strings.NewReader("package of web")); err != nil || !pushed {
+		t.Fatalf("Push() = %v, %v", pushed, err)
+	}
+	if pushed, err := bucket.Push(ctx, pkg, strings.NewReader("package of web")); err != nil || pushed {
+		t.Errorf("Push() of a package the registry has = %v, %v, want not pushed", pushed, err)
+	}
+
+	got, err := bucket.Stat(ctx, pkg.Digest)
+	if err != nil || got.FileName != pkg.FileName || string(got.Signature) != "signature" {
+		t.Errorf("Stat() = %+v, %v", got, err)
+	}
+	if _, err := bucket.Stat(ctx, newPackage("db", "other").Digest); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Stat() of a missing package error = %v, want ErrNotFound", err)
+	}
+	packages, err := bucket.List(ctx)
+	if err != nil || len(packages) != 1 || packages[0].Digest != pkg.Digest {
+		t.Errorf("List() = %v, %v", packages, err)
+	}
+}
+
+func TestCacheFetch(t *testing.T) {
+	bucket, server := newBucket(t)
+	ctx := context.Background()
+	web := newPackage("web", "package of web")
+	db := newPackage("db", "package of db")
+	for _, pkg := range []*registry.Package{web, db} {
+		if _, err := bucket.Push(ctx, pkg, strings.NewReader("package of "+pkg.Name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The cache fits one package
+	cache := registry.NewCache(t.TempDir(), web.Size)
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		got, err := cache.Fetch(ctx, bucket, web.Digest, &buf)
+		if err != nil || got.Digest != web.Digest || buf.String() != "package of web" {
+			t.Fatalf("Fetch() = %+v, %q, %v", got, buf.String(), err)
+		}
+	}
+	if server.downloads != 1 {
+		t.Errorf("package downloaded %d times, want once and then cached", server.downloads)
+	}
+	if _, err := cache.Fetch(ctx, nil, web.Digest, io.Discard); err != nil {
+		t.Errorf("Fetch() of a cached package without a registry error = %v", err)
+	}
+
+	if _, err := cache.Fetch(ctx, bucket, db.Digest, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Stat(web.Digest); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Stat() of the evicted package error = %v, want ErrNotFound", err)
+	}
+	if _, err := cache.Fetch(ctx, nil, web.Digest, io.Discard); !errors.Is(err, types.ErrUnavailable) {
+		t.Errorf("Fetch() of an uncached package without a registry error = %v, want ErrUnavailable", err)
+	}
+
+	// A package replaced in the bucket no longer matches its digest
+	server.mu.Lock()
+	for key := range server.objects {
+		if strings.HasSuffix(key, ".tar.gz") && strings.Contains(key, strings.TrimPrefix(web.Digest, registry.DigestPrefix)) {
+			server.objects[key] = []byte("package of evil")
+		}
+	}
+	server.mu.Unlock()
+	if _, err := cache.Fetch(ctx, bucket, web.Digest, io.Discard); !errors.Is(err, types.ErrInvalidChecksum) {
+		t.Errorf("Fetch() of a replaced package error = %v, want ErrInvalidChecksum", err)
+	}
+}
@@ -0,0 +1,188 @@
+// Package revision numbers the deployments of each application on a node, so
+// that a deployment can be referred to, and rolled back to, by its revision.
+package revision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// StorageDir is the storage directory holding the revision history of each application
+const StorageDir = "revisions"
+
+// DefaultLimit is how many revisions of an application are kept
+const DefaultLimit = 20
+
+// schema versions the stored revision histories
+var schema = storage.Schema{
+	Kind: "revision history",
+	Migrations: []storage.Migration{
+		nil, // 1: the schema version was added
+	},
+}
+
+// Revision is one deployment of an application
+type Revision struct {
+	// Number increases with every deployment of the application on the node
+	Number int64 `json:"number"`
+
+	// AppID is the application instance deployed
+	AppID string `json:"app_id"`
+
+	// Version is the version deployed
+	Version string `json:"version"`
+
+	// Package is the file name of the package in the packages directory
+	Package string `json:"package"`
+
+	// SHA256 is the checksum of the package as received
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Size is the size of the package as received
+	Size int64 `json:"size,omitempty"`
+
+	// Signer is the name of the key that signed the package, if any
+	Signer string `json:"signer,omitempty"`
+
+	// Signature is the signature of the package by Signer, so that it can
+	// be sent on to other nodes
+	Signature []byte `json:"signature,omitempty"`
+
+	// RequestID is the request that deployed it
+	RequestID string `json:"request_id,omitempty"`
+
+	// DeployedAt is when the deployment completed
+	DeployedAt time.Time `json:"deployed_at"`
+
+	// RollbackTo is the earlier revision this deployment rolled back to, if any
+	RollbackTo int64 `json:"rollback_to,omitempty"`
+}
+
+// history is the stored revision history of an application
+type history struct {
+	// Last is the number of the latest revision, kept when older ones are dropped
+	Last int64 `json:"last"`
+
+	// Revisions are the kept revisions, oldest first
+	Revisions []*Revision `json:"revisions"`
+}
+
+// Store persists the revision history of each application. Callers
+// serialize the deployments of an application, so numbers are not handed
+// out twice.
+type Store struct {
+	storage types.Storage
+	limit   int
+	mu      sync.Mutex
+}
+
+// NewStore creates a revision store on top of storage keeping limit
+// revisions of each application, DefaultLimit if limit is not positive
+func NewStore(storage types.Storage, limit int) *Store {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return &Store{storage: storage, limit: limit}
+}
+
+// key returns the storage key of the history of app
+func key(app string) string {
+	return StorageDir + "/" + app + ".json"
+}
+
+// Next returns the number the next revision of app gets
+func (s *Store) Next(ctx context.Context, app string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.load(ctx, app)
+	if err != nil {
+		return 0, err
+	}
+	return h.Last + 1, nil
+}
+
+// Record appends rev to the history of app, dropping the oldest revisions
+// beyond the limit. rev.Number must be above those already recorded.
+func (s *Store) Record(ctx context.Context, app string, rev *Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.load(ctx, app)
+	if err != nil {
+		return err
+	}
+	if rev.Number <= h.Last {
+		return fmt.Errorf("%w: revision %d of %s is not after revision %d", types.ErrInvalidState, rev.Number, app, h.Last)
+	}
+	h.Last = rev.Number
+	h.Revisions = append(h.Revisions, rev)
+	if len(h.Revisions) > s.limit {
+		h.Revisions = h.Revisions[len(h.Revisions)-s.limit:]
+	}
+
+	data, err := schema.Marshal(h)
+	if err != nil {
+		return types.WrapError(err, "failed to marshal revisions")
+	}
+	if err := s.storage.Save(ctx, key(app), data); err != nil {
+		return types.WrapError(err, "failed to save revisions")
+	}
+	return nil
+}
+
+// List returns the kept revisions of app, oldest first
+func (s *Store) List(ctx context.Context, app string) ([]*Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.load(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+	return h.Revisions, nil
+}
+
+// Get returns revision number of app, or with number 0 the revision before
+// the latest. The error wraps types.ErrNotFound if it is not kept.
+func (s *Store) Get(ctx context.Context, app string, number int64) (*Revision, error) {
+	revisions, err := s.List(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+	if number == 0 {
+		if len(revisions) < 2 {
+			return nil, fmt.Errorf("%w: %s has no revision before the current one", types.ErrNotFound, app)
+		}
+		return revisions[len(revisions)-2], nil
+	}
+	for _, rev := range revisions {
+		if rev.Number == number {
+			return rev, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: revision %d of %s is not kept", types.ErrNotFound, number, app)
+}
+
+// load reads the history of app, empty if none was recorded. s.mu must be held.
+func (s *Store) load(ctx context.Context, app string) (*history, error) {
+	data, err := s.storage.Load(ctx, key(app))
+	if errors.Is(err, types.ErrNotFound) {
+		return &history{}, nil
+	}
+	if err != nil {
+		return nil, types.WrapError(err, "failed to load revisions")
+	}
+
+	var h history
+	if err := schema.Unmarshal(data, &h); err != nil {
+		return nil, types.WrapError(err, "failed to parse revisions")
+	}
+	return &h, nil
+}
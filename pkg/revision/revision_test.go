@@ -0,0 +1,104 @@
+package revision_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/revision"
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func newStore(t *testing.T, limit int) *revision.Store {
+	t.Helper()
+	st, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	return revision.NewStore(st, limit)
+}
+
+// deploy records the next revision of app with version
+func deploy(t *testing.T, store *revision.Store, app, version string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	n, err := store.Next(ctx, app)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	rev := &revision.Revision{Number: n, AppID: app, Version: version, Package: app + "-" + version + ".tar.gz", DeployedAt: time.Now()}
+	if err := store.Record(ctx, app, rev); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	return n
+}
+
+func TestRevisionsIncrease(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, 0)
+
+	for i, version := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		if n := deploy(t, store, "web", version); n != int64(i+1) {
+			t.Errorf("revision of %s = %d, want %d", version, n, i+1)
+		}
+	}
+	// Each application is numbered on its own
+	if n := deploy(t, store, "db", "1.0.0"); n != 1 {
+		t.Errorf("first revision of db = %d, want 1", n)
+	}
+
+	rev, err := store.Get(ctx, "web", 2)
+	if err != nil || rev.Version != "1.1.0" {
+		t.Errorf("Get(2) = %+v, %v; want version 1.1.0", rev, err)
+	}
+	// Revision 0 is the one before the latest
+	rev, err = store.Get(ctx, "web", 0)
+	if err != nil || rev.Number != 2 {
+		t.Errorf("Get(0) = %+v, %v; want revision 2", rev, err)
+	}
+
+	// Numbers are never reused
+	err = store.Record(ctx, "web", &revision.Revision{Number: 3, AppID: "web"})
+	if !errors.Is(err, types.ErrInvalidState) {
+		t.Errorf("Record() of a used number error = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestRevisionLimit(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, 2)
+
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		deploy(t, store, "web", version)
+	}
+
+	revisions, err := store.List(ctx, "web")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Number != 2 || revisions[1].Number != 3 {
+		t.Errorf("List() kept %+v, want revisions 2 and 3", revisions)
+	}
+	if _, err := store.Get(ctx, "web", 1); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Get() of a dropped revision error = %v, want ErrNotFound", err)
+	}
+	// Dropping old revisions does not restart the numbering
+	if n := deploy(t, store, "web", "1.3.0"); n != 4 {
+		t.Errorf("revision after the limit = %d, want 4", n)
+	}
+}
+
+func TestNoPreviousRevision(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, 0)
+
+	if _, err := store.Get(ctx, "web", 0); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Get(0) without revisions error = %v, want ErrNotFound", err)
+	}
+	deploy(t, store, "web", "1.0.0")
+	if _, err := store.Get(ctx, "web", 0); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Get(0) with one revision error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
+)
+
+// argsData is what templates in manifest args are resolved against, e.g.
+// ["--listen", "{{ .Addr \"web\" }}"]
+type argsData struct {
+	AppID   string
+	Name    string
+	Version string
+	WorkDir string
+
+	ports   map[string]int
+	volumes map[string]string
+}
+
+// Port returns the port allocated to the named port of the manifest
+func (d *argsData) Port(name string) (int, error) {
+	port, ok := d.ports[name]
+	if !ok {
+		return 0, fmt.Errorf("port %q is not declared in the manifest ports", name)
+	}
+	return port, nil
+}
+
+// Addr returns the address to listen on all interfaces with the port
+// allocated to name, e.g. ":34567"
+func (d *argsData) Addr(name string) (string, error) {
+	port, err := d.Port(name)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort("", strconv.Itoa(port)), nil
+}
+
+// Volume returns the directory of the named volume of the manifest
+func (d *argsData) Volume(name string) (string, error) {
+	dir, ok := d.volumes[name]
+	if !ok {
+		return "", fmt.Errorf("volume %q is not declared in the manifest volumes", name)
+	}
+	return dir, nil
+}
+
+// allocatePorts picks a free TCP port for each name. The ports are free when
+// picked but not reserved, so the app should bind them soon after it starts.
+func allocatePorts(names []string) (map[string]int, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	// Hold every port until all are picked, so none is picked twice
+	listeners := make([]net.Listener, 0, len(names))
+	defer func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}()
+
+	ports := make(map[string]int, len(names))
+	for _, name := range names {
+		l, err := net.Listen("tcp", ":0")
+		if err != nil {
+			return nil, types.WrapError(err, "failed to allocate port "+name)
+		}
+		listeners = append(listeners, l)
+		ports[name] = l.Addr().(*net.TCPAddr).Port
+	}
+	return ports, nil
+}
+
+// portEnv returns the environment variable holding the port allocated to
+// name, e.g. P2P_PORT_WEB for "web"
+func portEnv(name string) string {
+	return "P2P_PORT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// expandArgs resolves the templates in args against data. Arguments without
+// a template are passed as they are.
+func expandArgs(args []string, data *argsData) ([]string, error) {
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		if !strings.Contains(arg, "{{") {
+			expanded[i] = arg
+			continue
+		}
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: argument %q: %v", types.ErrInvalidManifest, arg, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("%w: argument %q: %v", types.ErrInvalidManifest, arg, err)
+		}
+		expanded[i] = b.String()
+	}
+	return expanded, nil
+}
+
+// prepareVolumes creates the volumes of app that do not exist yet, and
+// returns the directory of each
+func (r *Runtime) prepareVolumes(app *types.Application) (map[string]string, error) {
+	if len(app.Manifest.Volumes) == 0 {
+		return nil, nil
+	}
+	if r.volumes == "" {
+		return nil, fmt.Errorf("%w: the runtime keeps no volumes", types.ErrNotImplemented)
+	}
+	dirs := make(map[string]string, len(app.Manifest.Volumes))
+	for _, name := range app.Manifest.Volumes {
+		dir := volume.Dir(r.volumes, app.Name, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, types.WrapError(err, "failed to create volume "+name)
+		}
+		dirs[name] = dir
+	}
+	return dirs, nil
+}
@@ -0,0 +1,184 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appuser"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Backend starts app processes and supervises them on behalf of the runtime,
+// which keeps track of apps, their health and their hooks
+type Backend interface {
+	// Name identifies the backend in logs and configuration
+	Name() string
+
+	// Start starts the process described by spec
+	Start(ctx context.Context, spec *ProcessSpec) (Process, error)
+}
+
+// Process is an app process started by a Backend
+type Process interface {
+	// PID returns the process ID, or 0 if unknown
+	PID() int
+
+	// Terminate asks the process to shut down gracefully
+	Terminate() error
+
+	// Kill stops the process immediately
+	Kill() error
+
+	// Pause suspends the process, keeping its state, until Resume
+	Pause() error
+
+	// Resume continues a paused process
+	Resume() error
+
+	// Wait waits for the process to exit and returns why it did, nil if it
+	// exited with status 0. It must be called exactly once.
+	Wait() error
+}
+
+// ProcessSpec describes an app process to start
+type ProcessSpec struct {
+	// App is the application the process runs
+	App *types.Application
+
+	// Path is the executable, Args its arguments
+	Path string
+	Args []string
+
+	// Dir is the working directory
+	Dir string
+
+	// Env is the complete environment
+	Env []string
+
+	// StdoutPath and StderrPath are the log files output is appended to
+	StdoutPath string
+	StderrPath string
+
+	// LogIdentifier, if set, tags the journal entries of the app. Backends
+	// able to do so send output to the journal instead of the log files.
+	LogIdentifier string
+
+	// Credential is the user the process runs as, nil for the daemon user,
+	// with Groups as its supplementary groups
+	Credential *appuser.Credential
+	Groups     []uint32
+
+	// NetNS is the network namespace to run in, "" for the host network
+	NetNS string
+
+	// Resources limits the process, if the backend enforces limits
+	Resources *types.ResourceLimits
+
+	// CoreDumps lifts the core file size limit of the process, so that it
+	// dumps core when it crashes
+	CoreDumps bool
+}
+
+// WithBackend starts app processes with b instead of as children of the daemon
+func WithBackend(b Backend) Option {
+	return func(r *Runtime) {
+		r.backend = b
+	}
+}
+
+// execBackend runs app processes as children of the daemon
+type execBackend struct{}
+
+// NewExecBackend returns the backend running app processes as children of the
+// daemon. It is the default; resource limits are not enforced.
+func NewExecBackend() Backend {
+	return execBackend{}
+}
+
+// Name implements Backend
+func (execBackend) Name() string {
+	return "exec"
+}
+
+// Start implements Backend
+func (execBackend) Start(ctx context.Context, spec *ProcessSpec) (Process, error) {
+	// The process is not tied to ctx: the app must outlive the request starting it
+	cmd := exec.Command(spec.Path, spec.Args...)
+	cmd.Dir = spec.Dir
+	cmd.Env = spec.Env
+
+	if spec.Credential != nil {
+		if err := setCredential(cmd, spec.Credential, spec.Groups); err != nil {
+			return nil, err
+		}
+	}
+
+	stdoutFile, err := os.OpenFile(spec.StdoutPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to create stdout log")
+	}
+	stderrFile, err := os.OpenFile(spec.StderrPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		_ = stdoutFile.Close()
+		return nil, types.WrapError(err, "failed to create stderr log")
+	}
+	cmd.Stdout = stdoutFile
+	cmd.Stderr = stderrFile
+
+	if spec.NetNS != "" {
+		err = startInNetns(spec.NetNS, cmd.Start)
+	} else {
+		err = cmd.Start()
+	}
+	if err != nil {
+		_ = stdoutFile.Close()
+		_ = stderrFile.Close()
+		return nil, types.WrapError(err, "failed to start process")
+	}
+
+	return &execProcess{cmd: cmd, logs: []*os.File{stdoutFile, stderrFile}}, nil
+}
+
+// execProcess is a child process of the daemon
+type execProcess struct {
+	cmd  *exec.Cmd
+	logs []*os.File
+}
+
+// PID implements Process
+func (p *execProcess) PID() int {
+	return p.cmd.Process.Pid
+}
+
+// Terminate implements Process
+func (p *execProcess) Terminate() error {
+	return p.cmd.Process.Signal(syscall.SIGTERM)
+}
+
+// Kill implements Process
+func (p *execProcess) Kill() error {
+	return p.cmd.Process.Kill()
+}
+
+// Pause implements Process. Only the process itself is stopped, with
+// SIGSTOP; processes it started keep running.
+func (p *execProcess) Pause() error {
+	return pauseProcess(p.cmd.Process)
+}
+
+// Resume implements Process
+func (p *execProcess) Resume() error {
+	return resumeProcess(p.cmd.Process)
+}
+
+// Wait implements Process
+func (p *execProcess) Wait() error {
+	defer func() {
+		for _, file := range p.logs {
+			_ = file.Close()
+		}
+	}()
+	return p.cmd.Wait()
+}
@@ -0,0 +1,22 @@
+package runtime
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// setCoreLimit lifts the core file size limit of the process pid, as far as
+// the hard limit of the daemon unless it may raise it
+func setCoreLimit(pid int) error {
+	limit := unix.Rlimit{Cur: unix.RLIM_INFINITY, Max: unix.RLIM_INFINITY}
+	err := unix.Prlimit(pid, unix.RLIMIT_CORE, &limit, nil)
+	if !errors.Is(err, unix.EPERM) {
+		return err
+	}
+	if err := unix.Getrlimit(unix.RLIMIT_CORE, &limit); err != nil {
+		return err
+	}
+	limit.Cur = limit.Max
+	return unix.Prlimit(pid, unix.RLIMIT_CORE, &limit, nil)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// setCoreLimit is not supported on this platform
+func setCoreLimit(pid int) error {
+	return fmt.Errorf("%w: core dumps of apps", types.ErrNotImplemented)
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package runtime
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appuser"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// setCredential is not supported on this platform
+func setCredential(cmd *exec.Cmd, cred *appuser.Credential, groups []uint32) error {
+	return fmt.Errorf("%w: running apps as other users", types.ErrNotImplemented)
+}
+
+// deviceGroups is not needed on this platform, where apps run as the daemon user
+func deviceGroups(devices []string) ([]uint32, error) {
+	return nil, nil
+}
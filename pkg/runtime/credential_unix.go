@@ -0,0 +1,46 @@
+//go:build unix
+
+package runtime
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appuser"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// setCredential makes cmd run as cred with only the given supplementary groups
+func setCredential(cmd *exec.Cmd, cred *appuser.Credential, groups []uint32) error {
+	if groups == nil {
+		groups = []uint32{}
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{
+			Uid:    cred.UID,
+			Gid:    cred.GID,
+			Groups: groups,
+		},
+	}
+	return nil
+}
+
+// deviceGroups returns the groups owning devices, without duplicates
+func deviceGroups(devices []string) ([]uint32, error) {
+	seen := make(map[uint32]bool)
+	var groups []uint32
+	for _, path := range devices {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, types.WrapError(err, "failed to stat device")
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok || seen[stat.Gid] {
+			continue
+		}
+		seen[stat.Gid] = true
+		groups = append(groups, stat.Gid)
+	}
+	return groups, nil
+}
@@ -0,0 +1,25 @@
+package runtime
+
+import (
+	"context"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// initiatorKey is the context key of the initiator of an operation
+type initiatorKey struct{}
+
+// WithInitiator returns a copy of ctx telling Start, Stop and Restart who
+// asked for them. The app status and the events they cause carry it.
+func WithInitiator(ctx context.Context, initiator types.Initiator) context.Context {
+	return context.WithValue(ctx, initiatorKey{}, &initiator)
+}
+
+// InitiatorFromContext returns the initiator carried by ctx, or nil if there is none
+func InitiatorFromContext(ctx context.Context) *types.Initiator {
+	if ctx == nil {
+		return nil
+	}
+	initiator, _ := ctx.Value(initiatorKey{}).(*types.Initiator)
+	return initiator
+}
@@ -0,0 +1,154 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// CheckJournal reports whether app logs can be read from the systemd journal
+func CheckJournal() error {
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		return fmt.Errorf("%w: journal logs require journalctl in PATH", types.ErrUnavailable)
+	}
+	return nil
+}
+
+// logIdentifier returns the syslog identifier tagging an app's journal entries
+func logIdentifier(appID string) string {
+	name := strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.':
+			return c
+		default:
+			return '_'
+		}
+	}, appID)
+	return "p2p-app-" + name
+}
+
+// journalEntry is an entry as printed by journalctl --output=json
+type journalEntry struct {
+	Cursor  string          `json:"__CURSOR"`
+	Message json.RawMessage `json:"MESSAGE"`
+}
+
+// text returns the message of the entry. Messages that are not valid UTF-8
+// are printed as arrays of bytes.
+func (e *journalEntry) text() string {
+	var s string
+	if err := json.Unmarshal(e.Message, &s); err == nil {
+		return s
+	}
+	var b []byte
+	_ = json.Unmarshal(e.Message, &b)
+	return string(b)
+}
+
+// readJournal returns a stream of the journal messages tagged with identifier.
+// Following resumes after the cursor of the last entry read, so no entry is
+// lost or repeated between polls.
+func readJournal(ctx context.Context, identifier string, opts types.LogOptions) (io.ReadCloser, error) {
+	if err := CheckJournal(); err != nil {
+		return nil, err
+	}
+
+	match := "--identifier=" + identifier
+	var since []string
+	if !opts.Since.IsZero() {
+		since = []string{fmt.Sprintf("--since=@%d", opts.Since.Unix())}
+	}
+
+	return streamLogs(ctx, opts.Follow, func(ctx context.Context, w io.Writer) error {
+		args := append([]string{match}, since...)
+		if opts.Tail > 0 {
+			args = append(args, fmt.Sprintf("--lines=%d", opts.Tail))
+		}
+		cursor, err := queryJournal(ctx, w, args)
+		if err != nil || !opts.Follow {
+			return err
+		}
+
+		ticker := time.NewTicker(logPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			// Until an entry was read, everything matching is new
+			args := []string{match}
+			if cursor != "" {
+				args = append(args, "--after-cursor="+cursor)
+			} else {
+				args = append(args, since...)
+			}
+			last, err := queryJournal(ctx, w, args)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			if last != "" {
+				cursor = last
+			}
+		}
+	}), nil
+}
+
+// queryJournal writes the messages of the journal entries selected by args to
+// w, one per line, and returns the cursor of the last one, "" if there were none
+func queryJournal(ctx context.Context, w io.Writer, args []string) (string, error) {
+	args = append([]string{"--no-pager", "--quiet", "--output=json", "--output-fields=MESSAGE"}, args...)
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", types.WrapError(err, "failed to run journalctl")
+	}
+	if err := cmd.Start(); err != nil {
+		return "", types.WrapError(err, "failed to run journalctl")
+	}
+
+	var cursor string
+	decoder := json.NewDecoder(stdout)
+	for {
+		var entry journalEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if err != io.EOF {
+				_ = cmd.Process.Kill()
+				_ = cmd.Wait()
+				return "", types.WrapError(err, "failed to parse journalctl output")
+			}
+			break
+		}
+		if _, err := io.WriteString(w, entry.text()+"\n"); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return "", err
+		}
+		cursor = entry.Cursor
+	}
+
+	if err := cmd.Wait(); err != nil {
+		// Some versions report that no entry matched with a failure status alone
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() == 0 {
+			return cursor, nil
+		}
+		return "", fmt.Errorf("journalctl: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return cursor, nil
+}
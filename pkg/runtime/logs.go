@@ -0,0 +1,356 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// logPollInterval is how often followed logs are checked for new lines
+const logPollInterval = 500 * time.Millisecond
+
+// WithJournalLogs reads app logs from the systemd journal instead of the log
+// files in the app working directories. Backends able to do so send app output
+// to the journal, and every app process is told the identifier to tag its own
+// journal entries with in P2P_LOG_IDENTIFIER. See CheckJournal.
+func WithJournalLogs() Option {
+	return func(r *Runtime) {
+		r.journal = true
+	}
+}
+
+// Logs returns a stream of the app's log lines selected by opts. Log files
+// carry no timestamps, so opts.Since requires journal logs.
+func (r *Runtime) Logs(ctx context.Context, appID string, opts types.LogOptions) (io.ReadCloser, error) {
+	r.mu.RLock()
+	info, exists := r.apps[appID]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, types.ErrNotFound
+	}
+
+	if r.journal {
+		return readJournal(ctx, logIdentifier(appID), opts)
+	}
+	if !opts.Since.IsZero() {
+		return nil, fmt.Errorf("%w: log files have no timestamps to filter by; set runtime.log_source to journal", types.ErrInvalidInput)
+	}
+
+	path := filepath.Join(r.LogDir(info.app), "stdout.log")
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to open log file")
+	}
+	return streamLogs(ctx, opts.Follow, func(ctx context.Context, w io.Writer) error {
+		return readLogFile(ctx, path, file, opts, w)
+	}), nil
+}
+
+// readLogFile writes the lines of the log file at path selected by opts to
+// w, starting with the already opened file. Following continues across
+// truncation and rotation of the file, like tail -F.
+func readLogFile(ctx context.Context, path string, file *os.File, opts types.LogOptions, w io.Writer) error {
+	f := &logFollower{path: path, file: file, reader: bufio.NewReader(file)}
+	defer func() { _ = f.file.Close() }()
+
+	// Lines already written, only the last opts.Tail of them if set
+	var backlog []string
+	emit := func(line string) error {
+		if opts.Tail == 0 {
+			_, err := io.WriteString(w, line)
+			return err
+		}
+		if strings.TrimSpace(line) != "" {
+			backlog = append(backlog, line)
+			if len(backlog) > opts.Tail {
+				backlog = backlog[1:]
+			}
+		}
+		return nil
+	}
+	if err := f.drain(ctx, emit); err != nil {
+		return err
+	}
+	if !opts.Follow && f.pending != "" {
+		if err := emit(f.pending + "\n"); err != nil {
+			return err
+		}
+	}
+	for _, line := range backlog {
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	if !opts.Follow {
+		return nil
+	}
+
+	// Keep checking for new lines
+	write := func(line string) error {
+		_, err := io.WriteString(w, line)
+		return err
+	}
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := f.drain(ctx, write); err != nil {
+			return err
+		}
+		if err := f.reopen(write); err != nil {
+			return err
+		}
+	}
+}
+
+// logFollower reads the lines of a log file as they are written
+type logFollower struct {
+	path    string
+	file    *os.File
+	reader  *bufio.Reader
+	offset  int64  // bytes of file read
+	pending string // start of a line still being written
+}
+
+// next returns the next complete line, or false at the end of the file
+func (f *logFollower) next() (string, bool, error) {
+	chunk, err := f.reader.ReadString('\n')
+	f.offset += int64(len(chunk))
+	if err == io.EOF {
+		f.pending += chunk
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	line := f.pending + chunk
+	f.pending = ""
+	return line, true, nil
+}
+
+// drain passes every complete line up to the end of the file to emit
+func (f *logFollower) drain(ctx context.Context, emit func(line string) error) error {
+	for ctx.Err() == nil {
+		line, ok, err := f.next()
+		if err != nil || !ok {
+			return err
+		}
+		if err := emit(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reopen starts over when the file was truncated, and switches to the new
+// file when it was rotated, once the old one is drained. The last line of a
+// rotated file is complete even without a newline, so it is passed to emit.
+func (f *logFollower) reopen(emit func(line string) error) error {
+	current, err := f.file.Stat()
+	if err != nil {
+		return types.WrapError(err, "failed to stat log file")
+	}
+	latest, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		// Rotated away and not created again yet
+		return nil
+	}
+	if err != nil {
+		return types.WrapError(err, "failed to stat log file")
+	}
+
+	if os.SameFile(current, latest) {
+		if latest.Size() < f.offset {
+			if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+				return types.WrapError(err, "failed to rewind truncated log file")
+			}
+			f.reader.Reset(f.file)
+			f.offset, f.pending = 0, ""
+		}
+		return nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return types.WrapError(err, "failed to open rotated log file")
+	}
+	if f.pending != "" {
+		if err := emit(f.pending + "\n"); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	_ = f.file.Close()
+	f.file = file
+	f.reader.Reset(file)
+	f.offset, f.pending = 0, ""
+	return nil
+}
+
+// maxBufferedLogBytes bounds the log output a stream buffers for its reader
+const maxBufferedLogBytes = 1 << 20
+
+// streamLogs returns a stream of what produce writes, run in the background
+// until it returns or the stream is closed. A followed stream never holds up
+// produce: once its reader falls maxBufferedLogBytes behind, the oldest lines
+// are dropped and a line saying how many takes their place. Otherwise produce
+// waits for the reader.
+func streamLogs(ctx context.Context, follow bool, produce func(ctx context.Context, w io.Writer) error) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	buf := newLogBuffer(ctx, maxBufferedLogBytes, follow)
+	go func() {
+		buf.finish(produce(ctx, buf))
+	}()
+	return &logStream{buf: buf, cancel: cancel}
+}
+
+// logStream stops producing logs when it is closed
+type logStream struct {
+	buf    *logBuffer
+	cancel context.CancelFunc
+}
+
+// Read implements io.Reader
+func (s *logStream) Read(p []byte) (int, error) {
+	return s.buf.Read(p)
+}
+
+// Close implements io.Closer
+func (s *logStream) Close() error {
+	s.cancel()
+	s.buf.close()
+	return nil
+}
+
+// logBuffer queues the writes of a log producer, each a line or more, for
+// the reader of a log stream
+type logBuffer struct {
+	ctx        context.Context
+	limit      int
+	dropOldest bool
+
+	mu      sync.Mutex
+	changed chan struct{} // closed and replaced whenever the buffer changes
+	chunks  [][]byte
+	size    int
+	current []byte // rest of the chunk being read
+	dropped int    // chunks dropped since the reader last caught up
+	done    bool
+	err     error // why the producer finished, io.EOF if it simply did
+	closed  bool
+}
+
+// newLogBuffer returns a buffer holding up to limit bytes, dropping the
+// oldest chunks beyond it if dropOldest is set, else holding up writers
+func newLogBuffer(ctx context.Context, limit int, dropOldest bool) *logBuffer {
+	return &logBuffer{ctx: ctx, limit: limit, dropOldest: dropOldest, changed: make(chan struct{})}
+}
+
+// broadcast wakes whoever waits for the buffer to change. b.mu must be held.
+func (b *logBuffer) broadcast() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Write implements io.Writer
+func (b *logBuffer) Write(p []byte) (int, error) {
+	chunk := bytes.Clone(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for !b.closed && !b.dropOldest && len(b.chunks) > 0 && b.size+len(chunk) > b.limit {
+		changed := b.changed
+		b.mu.Unlock()
+		select {
+		case <-changed:
+		case <-b.ctx.Done():
+		}
+		b.mu.Lock()
+		if b.ctx.Err() != nil {
+			return 0, b.ctx.Err()
+		}
+	}
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	b.chunks = append(b.chunks, chunk)
+	b.size += len(chunk)
+	for b.size > b.limit && len(b.chunks) > 1 {
+		b.size -= len(b.chunks[0])
+		b.chunks[0] = nil
+		b.chunks = b.chunks[1:]
+		b.dropped++
+	}
+	b.broadcast()
+	return len(p), nil
+}
+
+// Read implements io.Reader
+func (b *logBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.current) == 0 {
+		switch {
+		case b.closed:
+			return 0, io.ErrClosedPipe
+		case b.dropped > 0:
+			b.current = fmt.Appendf(nil, "[%d log lines dropped: the reader fell behind]\n", b.dropped)
+			b.dropped = 0
+		case len(b.chunks) > 0:
+			b.current = b.chunks[0]
+			b.chunks[0] = nil
+			b.chunks = b.chunks[1:]
+			b.size -= len(b.current)
+			b.broadcast()
+		case b.done:
+			return 0, b.err
+		default:
+			changed := b.changed
+			b.mu.Unlock()
+			<-changed
+			b.mu.Lock()
+		}
+	}
+
+	n := copy(p, b.current)
+	b.current = b.current[n:]
+	return n, nil
+}
+
+// finish records that the producer returned err. Being stopped is no error.
+func (b *logBuffer) finish(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil || b.ctx.Err() != nil {
+		err = io.EOF
+	}
+	b.done, b.err = true, err
+	b.broadcast()
+}
+
+// close discards what is buffered and fails further reads and writes
+func (b *logBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.chunks, b.size, b.current = nil, 0, nil
+	b.broadcast()
+}
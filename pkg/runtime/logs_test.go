@@ -0,0 +1,194 @@
+package runtime_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// startLogging starts an app and returns the path of its stdout log, holding lines
+func startLogging(t *testing.T, lines ...string) (types.Runtime, string) {
+	t.Helper()
+	rt, _ := newRuntime(t)
+	app := newApp(t, "web")
+	if err := rt.Start(context.Background(), app); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = rt.Stop(context.Background(), "web") })
+
+	path := filepath.Join(app.WorkDir, "logs", "stdout.log")
+	writeLines(t, path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, lines...)
+	return rt, path
+}
+
+// writeLines writes lines to the file at path opened with flag
+func writeLines(t *testing.T, path string, flag int, lines ...string) {
+	t.Helper()
+	file, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(file, line); err != nil {
+			t.Fatalf("failed to write log: %v", err)
+		}
+	}
+}
+
+// follow starts following the logs of the app and returns its lines
+func follow(t *testing.T, rt types.Runtime) (<-chan string, io.Closer) {
+	t.Helper()
+	stream, err := rt.Logs(context.Background(), "web", types.LogOptions{Follow: true})
+	if err != nil {
+		t.Fatalf("Logs() error = %v", err)
+	}
+	lines := make(chan string, 100)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stream)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	t.Cleanup(func() { _ = stream.Close() })
+	return lines, stream
+}
+
+// expect fails the test unless the next lines are want
+func expect(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got, ok := <-lines:
+			if !ok {
+				t.Fatalf("log stream ended, want %q", w)
+			}
+			if got != w {
+				t.Fatalf("log line = %q, want %q", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no log line within 5s, want %q", w)
+		}
+	}
+}
+
+func TestFollowTruncatedLog(t *testing.T) {
+	rt, path := startLogging(t, "one", "two")
+	lines, _ := follow(t, rt)
+	expect(t, lines, "one", "two")
+
+	// Truncated in place, as by copytruncate
+	writeLines(t, path, os.O_TRUNC|os.O_WRONLY, "three")
+	expect(t, lines, "three")
+}
+
+func TestFollowRotatedLog(t *testing.T) {
+	rt, path := startLogging(t, "one")
+	lines, _ := follow(t, rt)
+	expect(t, lines, "one")
+
+	// The old file gets the rest of its lines after being renamed
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("failed to rotate log: %v", err)
+	}
+	writeLines(t, path+".1", os.O_APPEND|os.O_WRONLY, "two")
+	time.Sleep(100 * time.Millisecond)
+	writeLines(t, path, os.O_CREATE|os.O_WRONLY, "three")
+	expect(t, lines, "two", "three")
+}
+
+func TestFollowDropsOldestForSlowReader(t *testing.T) {
+	rt, path := startLogging(t)
+	stream, err := rt.Logs(context.Background(), "web", types.LogOptions{Follow: true})
+	if err != nil {
+		t.Fatalf("Logs() error = %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Write well over the buffer limit while nothing reads
+	line := strings.Repeat("x", 1000)
+	many := make([]string, 3000)
+	for i := range many {
+		many[i] = fmt.Sprintf("%04d %s", i, line)
+	}
+	writeLines(t, path, os.O_APPEND|os.O_WRONLY, many...)
+	time.Sleep(2 * time.Second)
+	writeLines(t, path, os.O_APPEND|os.O_WRONLY, "last")
+
+	scanner := bufio.NewScanner(stream)
+	if !scanner.Scan() {
+		t.Fatalf("log stream ended: %v", scanner.Err())
+	}
+	if first := scanner.Text(); !strings.Contains(first, "log lines dropped") {
+		t.Fatalf("first line = %.40q, want the dropped lines marker", first)
+	}
+
+	// The newest lines are kept
+	deadline := time.After(5 * time.Second)
+	got := make(chan string)
+	go func() {
+		for scanner.Scan() {
+			if scanner.Text() == "last" {
+				got <- "last"
+				return
+			}
+		}
+	}()
+	select {
+	case <-got:
+	case <-deadline:
+		t.Fatal("the newest line was not kept")
+	}
+}
+
+func TestReadDoesNotDrop(t *testing.T) {
+	line := strings.Repeat("y", 1000)
+	many := make([]string, 3000)
+	for i := range many {
+		many[i] = line
+	}
+	rt, _ := startLogging(t, many...)
+
+	stream, err := rt.Logs(context.Background(), "web", types.LogOptions{})
+	if err != nil {
+		t.Fatalf("Logs() error = %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Let the producer fill the buffer before reading
+	time.Sleep(100 * time.Millisecond)
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if n := strings.Count(string(data), "\n"); n != len(many) {
+		t.Errorf("read %d lines, want %d", n, len(many))
+	}
+}
+
+func TestCloseEndsFollow(t *testing.T) {
+	rt, _ := startLogging(t, "one")
+	lines, stream := follow(t, rt)
+	expect(t, lines, "one")
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	select {
+	case _, ok := <-lines:
+		if ok {
+			t.Error("read a line after Close()")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("reading did not end after Close()")
+	}
+}
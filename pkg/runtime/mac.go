@@ -0,0 +1,152 @@
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// MACConfig configures mandatory access control (AppArmor / SELinux) for app processes
+type MACConfig struct {
+	// AppArmorProfile is the default AppArmor profile
+	AppArmorProfile string
+
+	// SELinuxContext is the default SELinux context
+	SELinuxContext string
+
+	// Require fails the start unless a profile or context is applied
+	Require bool
+}
+
+// Paths used to probe the MAC frameworks
+const (
+	appArmorEnabledPath  = "/sys/module/apparmor/parameters/enabled"
+	appArmorProfilesPath = "/sys/kernel/security/apparmor/profiles"
+	selinuxEnforcePath   = "/sys/fs/selinux/enforce"
+)
+
+// applyMAC wraps spec so that the process starts confined by the configured
+// AppArmor profile or SELinux context. Manifest settings override the defaults.
+//
+// Each requested profile is applied only if its framework is active, so a manifest
+// may name both an AppArmor profile and an SELinux context to run on either kind
+// of host. With Require set, the start fails unless one of them was applied.
+func (r *Runtime) applyMAC(spec *ProcessSpec, app *types.Application) error {
+	profile, seContext := r.mac.AppArmorProfile, r.mac.SELinuxContext
+	if sec := app.Manifest.Security; sec != nil {
+		if sec.AppArmorProfile != "" {
+			profile = sec.AppArmorProfile
+		}
+		if sec.SELinuxContext != "" {
+			seContext = sec.SELinuxContext
+		}
+	}
+
+	if profile == "" && seContext == "" {
+		if r.mac.Require {
+			return fmt.Errorf("%w: security.require_mac is set but no AppArmor profile or SELinux context is configured", types.ErrUnauthorized)
+		}
+		return nil
+	}
+
+	var problems []string
+
+	if profile != "" {
+		if err := appArmorUsable(profile); err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			wrapCommand(spec, "aa-exec", "-p", profile, "--")
+			r.logger.Info("applying AppArmor profile", "app_id", app.ID, "profile", profile)
+			return nil
+		}
+	}
+
+	if seContext != "" {
+		if err := selinuxUsable(); err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			wrapCommand(spec, "runcon", seContext)
+			r.logger.Info("applying SELinux context", "app_id", app.ID, "context", seContext)
+			return nil
+		}
+	}
+
+	if r.mac.Require {
+		return fmt.Errorf("%w: cannot confine process: %s", types.ErrUnauthorized, strings.Join(problems, "; "))
+	}
+
+	r.logger.Warn("running application unconfined", "app_id", app.ID, "reason", strings.Join(problems, "; "))
+	return nil
+}
+
+// wrapCommand makes spec run through a launcher binary
+func wrapCommand(spec *ProcessSpec, launcher string, launcherArgs ...string) {
+	args := append(append([]string{}, launcherArgs...), spec.Path)
+
+	// The launcher was resolved by the usability check
+	spec.Path, _ = exec.LookPath(launcher)
+	spec.Args = append(args, spec.Args...)
+}
+
+// appArmorUsable checks that AppArmor is enabled, profile is loaded and aa-exec is installed
+func appArmorUsable(profile string) error {
+	enabled, err := os.ReadFile(appArmorEnabledPath)
+	if err != nil || !strings.HasPrefix(strings.TrimSpace(string(enabled)), "Y") {
+		return fmt.Errorf("AppArmor is not enabled on this host")
+	}
+
+	loaded, err := appArmorProfileLoaded(profile)
+	if err != nil {
+		return fmt.Errorf("cannot list AppArmor profiles: %v", err)
+	}
+	if !loaded {
+		return fmt.Errorf("AppArmor profile %q is not loaded", profile)
+	}
+
+	if _, err := exec.LookPath("aa-exec"); err != nil {
+		return fmt.Errorf("aa-exec not found in PATH")
+	}
+
+	return nil
+}
+
+// appArmorProfileLoaded reports whether profile appears in the kernel's profile list
+func appArmorProfileLoaded(profile string) (bool, error) {
+	file, err := os.Open(appArmorProfilesPath)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = file.Close() }()
+
+	// Lines look like "profile-name (enforce)"
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.LastIndex(line, " ("); i >= 0 {
+			line = line[:i]
+		}
+		if line == profile {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// selinuxUsable checks that SELinux is enabled and runcon is installed.
+// Whether the context itself is valid is only known when runcon executes.
+func selinuxUsable() error {
+	if _, err := os.Stat(selinuxEnforcePath); err != nil {
+		return fmt.Errorf("SELinux is not enabled on this host")
+	}
+
+	if _, err := exec.LookPath("runcon"); err != nil {
+		return fmt.Errorf("runcon not found in PATH")
+	}
+
+	return nil
+}
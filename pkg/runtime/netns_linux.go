@@ -0,0 +1,46 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	goruntime "runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// startInNetns starts a process inside the network namespace at nsPath.
+// The calling thread joins the namespace, so the forked child inherits it,
+// and then returns to its original namespace.
+func startInNetns(nsPath string, start func() error) error {
+	goruntime.LockOSThread()
+
+	origin, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+	if err != nil {
+		goruntime.UnlockOSThread()
+		return fmt.Errorf("failed to open current network namespace: %w", err)
+	}
+	defer func() { _ = origin.Close() }()
+
+	target, err := os.Open(nsPath)
+	if err != nil {
+		goruntime.UnlockOSThread()
+		return fmt.Errorf("failed to open network namespace: %w", err)
+	}
+	defer func() { _ = target.Close() }()
+
+	if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+		goruntime.UnlockOSThread()
+		return fmt.Errorf("failed to enter network namespace: %w", err)
+	}
+
+	startErr := start()
+
+	// A thread stuck in the wrong namespace must not be reused; leaving it
+	// locked makes the Go runtime discard it when the goroutine exits
+	if err := unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET); err != nil {
+		return fmt.Errorf("failed to restore network namespace: %w", err)
+	}
+	goruntime.UnlockOSThread()
+
+	return startErr
+}
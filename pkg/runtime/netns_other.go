@@ -0,0 +1,14 @@
+//go:build !linux
+
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// startInNetns is not supported on this platform
+func startInNetns(nsPath string, start func() error) error {
+	return fmt.Errorf("%w: network namespaces", types.ErrNotImplemented)
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// pauseProcess is not supported on this platform
+func pauseProcess(p *os.Process) error {
+	return fmt.Errorf("%w: pausing apps", types.ErrNotImplemented)
+}
+
+// resumeProcess is not supported on this platform
+func resumeProcess(p *os.Process) error {
+	return fmt.Errorf("%w: resuming apps", types.ErrNotImplemented)
+}
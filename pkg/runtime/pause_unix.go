@@ -0,0 +1,18 @@
+//go:build unix
+
+package runtime
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseProcess stops p until resumeProcess
+func pauseProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGSTOP)
+}
+
+// resumeProcess continues p after pauseProcess
+func resumeProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGCONT)
+}
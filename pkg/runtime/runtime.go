@@ -1,42 +1,323 @@
 package runtime
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
-	"syscall"
 	"time"
 
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+	"github.com/asjdf/p2p-playground-lite/pkg/appuser"
+	"github.com/asjdf/p2p-playground-lite/pkg/coredump"
+	"github.com/asjdf/p2p-playground-lite/pkg/crash"
+	"github.com/asjdf/p2p-playground-lite/pkg/device"
 	"github.com/asjdf/p2p-playground-lite/pkg/health"
+	"github.com/asjdf/p2p-playground-lite/pkg/interpreter"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/platform"
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
 )
 
 // appInfo holds application runtime information
 type appInfo struct {
+	// mu guards the runtime status of app, cancelHealth, stopping, labels
+	// and cpu, which change while the app is registered; the other fields
+	// are set before
+	mu            sync.Mutex
 	app           *types.Application
+	proc          Process
+	exited        chan struct{} // closed once proc has exited
 	healthChecker *health.Checker
 	cancelHealth  context.CancelFunc
 	autoRestart   bool
+	stopping      bool              // the process was asked to stop, so its exit is no failure
+	labels        map[string]string // the labels of app since they were set, if they were
+	cpu           cpuSample         // the CPU time proc had used when last measured
 }
 
-// Runtime manages application processes
+// cpuSample is the CPU time a process had used at some time
+type cpuSample struct {
+	used time.Duration
+	at   time.Time
+}
+
+// minCPUWindow is the shortest period CPU usage is measured over; measuring
+// again sooner reports the usage since the measurement before
+const minCPUWindow = time.Second
+
+// cpuPercent returns the CPU time the process of info used since it was last
+// measured, or since it started, as a percentage of one CPU
+func (i *appInfo) cpuPercent(pid int, startedAt time.Time) (float64, error) {
+	used, err := processCPUTime(pid)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	prev := i.cpu
+	if prev.at.IsZero() {
+		prev = cpuSample{at: startedAt}
+	}
+	elapsed := now.Sub(prev.at)
+	if elapsed >= minCPUWindow {
+		i.cpu = cpuSample{used: used, at: now}
+	}
+	if elapsed <= 0 {
+		return 0, nil
+	}
+	return float64(used-prev.used) / float64(elapsed) * 100, nil
+}
+
+// snapshot returns a copy of the app that later status changes do not affect
+func (i *appInfo) snapshot() *types.Application {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.copyLocked()
+}
+
+// copyLocked returns a copy of the app with its current labels. i.mu must be held.
+func (i *appInfo) copyLocked() *types.Application {
+	app := *i.app
+	if i.labels != nil {
+		app.Labels = i.labels
+	}
+	return &app
+}
+
+// status returns the process state of the app
+func (i *appInfo) status() types.AppStatusType {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.app.Status
+}
+
+// exit records that the process is gone with status and returns a snapshot
+// of the app, stopping health monitoring. A process asked to stop counts as
+// stopped whatever its exit status, and keeps the initiator of the stop;
+// otherwise the app itself initiated the exit, for reason.
+func (i *appInfo) exit(status types.AppStatusType, reason string) *types.Application {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.stopping {
+		status = types.AppStatusStopped
+	} else {
+		i.app.Initiator = &types.Initiator{Kind: types.InitiatorApp, Reason: reason}
+	}
+	if i.cancelHealth != nil {
+		i.cancelHealth()
+		i.cancelHealth = nil
+	}
+	i.app.Status = status
+	i.app.PID = 0
+	return i.copyLocked()
+}
+
+// Hooks are called around application processes
+type Hooks struct {
+	// BeforeStart runs before each process start, including restarts.
+	// Returning an error aborts the start.
+	BeforeStart func(ctx context.Context, app *types.Application) error
+
+	// AfterExit runs after a process has exited or been stopped
+	AfterExit func(app *types.Application)
+}
+
+// Runtime manages application processes. Operations on an app are
+// serialized by a lock of its own, so stopping one app, which may take until
+// stopTimeout, holds up neither other apps nor List and Status.
 type Runtime struct {
-	apps   map[string]*appInfo
-	mu     sync.RWMutex
-	logger types.Logger
+	apps    map[string]*appInfo
+	ops     map[string]*appOp // serializes the operations on each app ID
+	mu      sync.RWMutex      // guards apps and ops
+	logger  types.Logger
+	hooks   Hooks
+	mac     MACConfig
+	user    UserFunc
+	network NetworkSandbox
+	devices []string
+	appAPI  AppAPI
+	events  EventFunc
+	backend Backend
+	journal bool
+	scratch string // holds the logs and temporary files of apps, if set
+	cores   string // glob of the core dumps of apps in their working directory, if collected
+	volumes string // holds the volumes of apps, if set
+
+	resourceLimits bool
+}
+
+// Runtime implements the application runtime interface
+var _ types.Runtime = (*Runtime)(nil)
+
+// stopTimeout is how long a stopped app may take to exit before it is killed
+const stopTimeout = 10 * time.Second
+
+// healthPollInterval is how often WaitHealthy checks a starting app
+const healthPollInterval = time.Second
+
+// healthSettle is how long an app without a health check must keep running to count as healthy
+const healthSettle = 2 * time.Second
+
+// failureLogLines is how many lines of stderr a health failure includes
+const failureLogLines = 20
+
+// failureLogBytes bounds how much of stderr is read to find those lines
+const failureLogBytes = 16 * 1024
+
+// keepCoreDumps is how many core dumps of an app are kept, the newest
+const keepCoreDumps = 3
+
+// AppAPI serves the local API apps use to talk to their daemon
+type AppAPI interface {
+	// Open starts serving the app and returns the environment variables
+	// telling its process how to connect
+	Open(ctx context.Context, app *types.Application) ([]string, error)
+
+	// Stopping tells the app it will be stopped within grace
+	Stopping(app *types.Application, reason string, grace time.Duration)
+
+	// Close stops serving the app. app is the application Open was called
+	// with, which identifies the instance: a later instance of the app ID
+	// is left alone.
+	Close(app *types.Application)
+
+	// Health returns the health the app last reported, or nil if it reported none
+	Health(appID string) *appsdk.Health
+
+	// Metrics returns the metrics the app published
+	Metrics(appID string) map[string]float64
+}
+
+// NetworkSandbox isolates app processes according to their network policy
+type NetworkSandbox interface {
+	// Setup prepares the app's network namespace and returns its path,
+	// or "" if the process should share the host network
+	Setup(ctx context.Context, app *types.Application) (string, error)
+
+	// Teardown releases everything Setup created for the app
+	Teardown(app *types.Application)
+}
+
+// EventFunc receives application lifecycle events, with a snapshot of the
+// app. It may be called with an operation on the app in progress and must
+// not call back into the runtime.
+type EventFunc func(app *types.Application, event types.AppEvent, message string)
+
+// UserFunc returns the credential an app process runs as, or nil for the daemon user
+type UserFunc func(ctx context.Context, app *types.Application) (*appuser.Credential, error)
+
+// Option configures optional runtime behavior
+type Option func(*Runtime)
+
+// WithMAC confines app processes with AppArmor or SELinux
+func WithMAC(cfg MACConfig) Option {
+	return func(r *Runtime) {
+		r.mac = cfg
+	}
+}
+
+// WithUser runs app processes as the users returned by fn.
+// The app's working directory is handed over to that user before each start.
+func WithUser(fn UserFunc) Option {
+	return func(r *Runtime) {
+		r.user = fn
+	}
+}
+
+// WithNetwork enforces manifest network policies with sb
+func WithNetwork(sb NetworkSandbox) Option {
+	return func(r *Runtime) {
+		r.network = sb
+	}
+}
+
+// WithDevices offers the given host devices to apps that request them in their manifest.
+// Apps run in the host mount namespace, so access is granted by adding the groups
+// owning the devices to the process when it runs as an unprivileged app user.
+func WithDevices(devices []string) Option {
+	return func(r *Runtime) {
+		r.devices = devices
+	}
+}
+
+// WithAppAPI serves api to app processes
+func WithAppAPI(api AppAPI) Option {
+	return func(r *Runtime) {
+		r.appAPI = api
+	}
+}
+
+// WithEvents reports application lifecycle events to fn
+func WithEvents(fn EventFunc) Option {
+	return func(r *Runtime) {
+		r.events = fn
+	}
+}
+
+// WithResourceLimits passes the resource limits of app manifests to the
+// backend, which enforces them if it can
+func WithResourceLimits(enabled bool) Option {
+	return func(r *Runtime) {
+		r.resourceLimits = enabled
+	}
+}
+
+// WithScratchDir keeps the logs and temporary files of each app in a
+// directory of its own below dir instead of its working directory. Apps find
+// theirs in TMPDIR, emptied before each start.
+func WithScratchDir(dir string) Option {
+	return func(r *Runtime) {
+		r.scratch = dir
+	}
+}
+
+// WithCoreDumps collects the core dumps apps asking for them in their
+// manifest leave in their working directory, found with glob (see
+// coredump.Glob). Without it, such apps still dump core, wherever the core
+// pattern of the kernel says.
+func WithCoreDumps(glob string) Option {
+	return func(r *Runtime) {
+		r.cores = glob
+	}
+}
+
+// WithVolumes keeps the volumes apps declare in their manifest below dir, in
+// a directory per app name (see volume.Dir), created before each start.
+// Without it, apps declaring volumes fail to start.
+func WithVolumes(dir string) Option {
+	return func(r *Runtime) {
+		r.volumes = dir
+	}
 }
 
 // New creates a new runtime
-func New(logger types.Logger) *Runtime {
-	return &Runtime{
-		apps:   make(map[string]*appInfo),
-		logger: logger,
+func New(logger types.Logger, opts ...Option) *Runtime {
+	r := &Runtime{
+		apps:    make(map[string]*appInfo),
+		ops:     make(map[string]*appOp),
+		logger:  logger,
+		backend: NewExecBackend(),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
+}
+
+// SetHooks sets the process hooks. It must be called before any application is started.
+func (r *Runtime) SetHooks(hooks Hooks) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = hooks
 }
 
 // Start starts an application
@@ -49,105 +330,267 @@ func (r *Runtime) StartWithAutoRestart(ctx context.Context, app *types.Applicati
 	return r.start(ctx, app, true)
 }
 
+// appOp is the lock of the operations on an app ID
+type appOp struct {
+	sync.Mutex
+	refs int // operations holding or waiting for the lock, guarded by Runtime.mu
+}
+
+// lockApp locks the operations on the app with ID appID and returns the unlock
+// function. The lock is dropped once no operation holds or waits for it, so
+// IDs that are gone, such as those of removed instances, do not keep one.
+func (r *Runtime) lockApp(appID string) func() {
+	r.mu.Lock()
+	op, exists := r.ops[appID]
+	if !exists {
+		op = &appOp{}
+		r.ops[appID] = op
+	}
+	op.refs++
+	r.mu.Unlock()
+
+	op.Lock()
+	return func() {
+		op.Unlock()
+		r.mu.Lock()
+		op.refs--
+		if op.refs == 0 {
+			delete(r.ops, appID)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// lookup returns the info of the app with ID appID, or nil if there is none
+func (r *Runtime) lookup(appID string) *appInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.apps[appID]
+}
+
 // start is the internal start implementation
 func (r *Runtime) start(ctx context.Context, app *types.Application, autoRestart bool) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	unlock := r.lockApp(app.ID)
+	defer unlock()
+	return r.startLocked(ctx, app, autoRestart)
+}
 
+// startLocked starts app. The runtime keeps a copy of app, whose runtime
+// status is copied back to app before it returns. The caller holds the
+// lock of the app.
+func (r *Runtime) startLocked(ctx context.Context, caller *types.Application, autoRestart bool) error {
+	log := logging.FromContextOr(ctx, r.logger)
 	// Check if already running
-	if existing, exists := r.apps[app.ID]; exists {
-		if existing.app.Status == types.AppStatusRunning {
+	if existing := r.lookup(caller.ID); existing != nil {
+		if status := existing.status(); status == types.AppStatusRunning || status == types.AppStatusPaused {
 			return types.ErrAppAlreadyRunning
 		}
 	}
 
+	// Work on a copy, as the caller's app may be a snapshot still shared
+	// with the stopped process
+	own := *caller
+	app := &own
+	registered := false
+	defer func() {
+		if !registered {
+			caller.AppRuntimeStatus = app.AppRuntimeStatus
+		}
+	}()
+
 	// Update status
 	app.Status = types.AppStatusStarting
+	app.Initiator = InitiatorFromContext(ctx)
+
+	if r.hooks.BeforeStart != nil {
+		if err := r.hooks.BeforeStart(ctx, app); err != nil {
+			app.Status = types.AppStatusFailed
+			return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
+		}
+	}
+
+	// Allocate the named ports of the app and resolve them in its arguments
+	ports, err := allocatePorts(app.Manifest.Ports)
+	if err != nil {
+		app.Status = types.AppStatusFailed
+		r.afterExit(app, app)
+		return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
+	}
+	volumes, err := r.prepareVolumes(app)
+	if err != nil {
+		app.Status = types.AppStatusFailed
+		r.afterExit(app, app)
+		return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
+	}
+	args, err := expandArgs(app.Manifest.Args, &argsData{
+		AppID:   app.ID,
+		Name:    app.Name,
+		Version: app.Version,
+		WorkDir: app.WorkDir,
+		ports:   ports,
+		volumes: volumes,
+	})
+	if err != nil {
+		app.Status = types.AppStatusFailed
+		r.afterExit(app, app)
+		return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
+	}
+	app.Ports = ports
+
+	// Build the process
+	spec := &ProcessSpec{
+		App:  app,
+		Path: filepath.Join(app.WorkDir, app.Manifest.Entrypoint),
+		Args: args,
+		Dir:  app.WorkDir,
+	}
+	if r.resourceLimits {
+		spec.Resources = app.Manifest.Resources
+	}
+	spec.CoreDumps = app.Manifest.CoreDumps
+
+	// Refuse an entrypoint built for another platform, which exec would only
+	// report as an exec format error
+	if err := platform.CheckExecutable(app.Manifest.Platform, spec.Path); err != nil {
+		app.Status = types.AppStatusFailed
+		r.afterExit(app, app)
+		return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
+	}
 
-	// Build command
-	cmdPath := filepath.Join(app.WorkDir, app.Manifest.Entrypoint)
-	cmd := exec.CommandContext(ctx, cmdPath, app.Manifest.Args...)
-	cmd.Dir = app.WorkDir
+	// Run a script entrypoint with its interpreter
+	if app.Manifest.Interpreter != "" {
+		path, err := interpreter.Lookup(app.Manifest.Interpreter)
+		if err != nil {
+			app.Status = types.AppStatusFailed
+			r.afterExit(app, app)
+			return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
+		}
+		spec.Args = append([]string{spec.Path}, spec.Args...)
+		spec.Path = path
+	}
+
+	// Confine the process with AppArmor or SELinux if configured
+	if err := r.applyMAC(spec, app); err != nil {
+		app.Status = types.AppStatusFailed
+		r.afterExit(app, app)
+		return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
+	}
+
+	// Resolve requested devices against those this node offers
+	devices, err := device.Resolve(app.Manifest.Devices, r.devices)
+	if err != nil {
+		app.Status = types.AppStatusFailed
+		r.afterExit(app, app)
+		return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
+	}
 
 	// Set environment variables
-	cmd.Env = os.Environ()
+	spec.Env = os.Environ()
 	for k, v := range app.Manifest.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+		spec.Env = append(spec.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	if len(devices) > 0 {
+		spec.Env = append(spec.Env, "P2P_DEVICES="+strings.Join(devices, ","))
+	}
+	for name, port := range ports {
+		spec.Env = append(spec.Env, fmt.Sprintf("%s=%d", portEnv(name), port))
+	}
+	for name, dir := range volumes {
+		spec.Env = append(spec.Env, volume.Env(name)+"="+dir)
 	}
 
 	// Create log directory
-	logDir := filepath.Join(app.WorkDir, "logs")
+	logDir := r.LogDir(app)
 	if err := os.MkdirAll(logDir, 0755); err != nil {
+		app.Status = types.AppStatusFailed
+		r.afterExit(app, app)
 		return types.WrapError(err, "failed to create log directory")
 	}
+	if r.scratch != "" {
+		tmpDir := filepath.Join(r.scratch, app.ID, "tmp")
+		if err := os.RemoveAll(tmpDir); err != nil {
+			app.Status = types.AppStatusFailed
+			r.afterExit(app, app)
+			return types.WrapError(err, "failed to empty scratch directory")
+		}
+		if err := os.MkdirAll(tmpDir, 0755); err != nil {
+			app.Status = types.AppStatusFailed
+			r.afterExit(app, app)
+			return types.WrapError(err, "failed to create scratch directory")
+		}
+		spec.Env = append(spec.Env, "TMPDIR="+tmpDir)
+	}
+	spec.StdoutPath = filepath.Join(logDir, "stdout.log")
+	spec.StderrPath = filepath.Join(logDir, "stderr.log")
+	if r.journal {
+		spec.LogIdentifier = logIdentifier(app.ID)
+		spec.Env = append(spec.Env, "P2P_LOG_IDENTIFIER="+spec.LogIdentifier)
+	}
 
-	// Set up log files
-	stdoutLog := filepath.Join(logDir, "stdout.log")
-	stderrLog := filepath.Join(logDir, "stderr.log")
-
-	stdoutFile, err := os.OpenFile(stdoutLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return types.WrapError(err, "failed to create stdout log")
+	// Serve the app API; the socket lives in the working directory handed to the app user
+	if r.appAPI != nil {
+		apiEnv, err := r.appAPI.Open(ctx, app)
+		if err != nil {
+			app.Status = types.AppStatusFailed
+			r.afterExit(app, app)
+			return fmt.Errorf("%w: failed to open app API: %w", types.ErrAppStartFailed, err)
+		}
+		spec.Env = append(spec.Env, apiEnv...)
 	}
 
-	stderrFile, err := os.OpenFile(stderrLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		_ = stdoutFile.Close()
-		return types.WrapError(err, "failed to create stderr log")
+	// Run as the app's unprivileged user
+	if err := r.applyUser(ctx, spec, app, devices); err != nil {
+		app.Status = types.AppStatusFailed
+		r.afterExit(app, app)
+		return fmt.Errorf("%w: %w", types.ErrAppStartFailed, err)
 	}
 
-	cmd.Stdout = stdoutFile
-	cmd.Stderr = stderrFile
+	// Isolate the process network if its manifest restricts egress
+	if r.network != nil {
+		spec.NetNS, err = r.network.Setup(ctx, app)
+		if err != nil {
+			app.Status = types.AppStatusFailed
+			r.afterExit(app, app)
+			return fmt.Errorf("%w: failed to set up network policy: %w", types.ErrAppStartFailed, err)
+		}
+	}
 
 	// Start process
-	if err := cmd.Start(); err != nil {
-		_ = stdoutFile.Close()
-		_ = stderrFile.Close()
-		return types.WrapError(err, "failed to start process")
+	proc, err := r.backend.Start(ctx, spec)
+	if err != nil {
+		app.Status = types.AppStatusFailed
+		r.afterExit(app, app)
+		return err
+	}
+
+	// Let the process dump core; backends that can do so already did
+	if spec.CoreDumps && proc.PID() > 0 {
+		if err := setCoreLimit(proc.PID()); err != nil {
+			log.Warn("failed to enable core dumps", "app_id", app.ID, "error", err)
+		}
 	}
 
 	// Update application info
-	app.PID = cmd.Process.Pid
+	app.PID = proc.PID()
 	app.Status = types.AppStatusRunning
 	app.StartedAt = time.Now()
+	app.CoreDump = ""
 
 	// Create appInfo
 	info := &appInfo{
 		app:         app,
+		proc:        proc,
+		exited:      make(chan struct{}),
 		autoRestart: autoRestart,
 	}
 
 	// Set up health monitoring if configured
 	if app.Manifest.HealthCheck != nil {
 		healthCfg := convertHealthCheckConfig(app.Manifest.HealthCheck)
-		checker := health.New(healthCfg, app.PID, r.logger)
-
-		healthCtx, healthCancel := context.WithCancel(context.Background())
-		info.healthChecker = checker
-		info.cancelHealth = healthCancel
-
-		// Start health monitoring in background
-		go checker.StartMonitoring(healthCtx, func(result *health.Result) {
-			r.logger.Warn("application unhealthy, triggering restart",
-				"app_id", app.ID,
-				"message", result.Message,
-				"failures", result.FailureCount,
-			)
+		info.healthChecker = health.New(healthCfg, app.PID, r.logger)
+		info.cancelHealth = r.watchHealth(info)
 
-			// Auto-restart if enabled
-			if autoRestart {
-				go func() {
-					if err := r.Restart(context.Background(), app.ID); err != nil {
-						r.logger.Error("failed to auto-restart application",
-							"app_id", app.ID,
-							"error", err,
-						)
-					}
-				}()
-			}
-		})
-
-		r.logger.Info("health monitoring started",
+		log.Info("health monitoring started",
 			"app_id", app.ID,
 			"type", healthCfg.Type,
 			"interval", healthCfg.Interval,
@@ -155,48 +598,197 @@ func (r *Runtime) start(ctx context.Context, app *types.Application, autoRestart
 	}
 
 	// Store application info
+	caller.AppRuntimeStatus = app.AppRuntimeStatus
+	registered = true
+	r.mu.Lock()
 	r.apps[app.ID] = info
+	r.mu.Unlock()
 
 	// Monitor process in background
-	go func() {
-		defer func() { _ = stdoutFile.Close() }()
-		defer func() { _ = stderrFile.Close() }()
+	crash.Go(r.logger, "runtime.monitor", func() { r.monitor(info) })
 
-		err := cmd.Wait()
+	log.Info("application started",
+		"app_id", app.ID,
+		"pid", app.PID,
+		"backend", r.backend.Name(),
+	)
+	r.emit(info.snapshot(), types.AppEventStarted, fmt.Sprintf("pid %d", app.PID))
 
-		r.mu.Lock()
-		defer r.mu.Unlock()
+	return nil
+}
 
-		if info, exists := r.apps[app.ID]; exists {
-			// Cancel health monitoring
-			if info.cancelHealth != nil {
-				info.cancelHealth()
-			}
+// watchHealth starts monitoring the health of the app of info, which has a
+// health checker, in the background and returns the function stopping it
+func (r *Runtime) watchHealth(info *appInfo) context.CancelFunc {
+	onUnhealthy := r.onUnhealthy(info, info.autoRestart)
+	ctx, cancel := context.WithCancel(context.Background())
+	crash.Loop(ctx.Done(), r.logger, "runtime.health", func() {
+		info.healthChecker.StartMonitoring(ctx, onUnhealthy)
+	})
+	return cancel
+}
+
+// onUnhealthy returns what health monitoring calls when the app of info turns
+// unhealthy: it records the event and restarts the app if autoRestart is set
+func (r *Runtime) onUnhealthy(info *appInfo, autoRestart bool) func(result *health.Result) {
+	app := info.snapshot()
+	return func(result *health.Result) {
+		r.logger.Warn("application unhealthy, triggering restart",
+			"app_id", app.ID,
+			"message", result.Message,
+			"failures", result.FailureCount,
+		)
+		unhealthy := info.snapshot()
+		unhealthy.Initiator = &types.Initiator{Kind: types.InitiatorHealthMonitor, Reason: result.Message}
+		r.emit(unhealthy, types.AppEventUnhealthy, result.Message)
+
+		// Auto-restart if enabled
+		if autoRestart {
+			ctx := WithInitiator(context.Background(), types.Initiator{Kind: types.InitiatorRestartPolicy, Reason: "unhealthy: " + result.Message})
+			crash.Go(r.logger, "runtime.restart", func() {
+				if err := r.Restart(ctx, app.ID); err != nil {
+					r.logger.Error("failed to auto-restart application",
+						"app_id", app.ID,
+						"error", err,
+					)
+				}
+			})
+		}
+	}
+}
+
+// monitor waits for the process of info to exit and records its exit. The
+// exit is only reported, and AfterExit only run, if the app was neither
+// restarted nor removed in the meantime.
+func (r *Runtime) monitor(info *appInfo) {
+	err := info.proc.Wait()
+	close(info.exited)
+
+	status, reason := types.AppStatusStopped, "exit status 0"
+	message := reason
+	if err != nil {
+		status, reason, message = types.AppStatusFailed, err.Error(), err.Error()
+		if core := r.collectCoreDump(info); core != "" {
+			message += "; core dump " + core
+		}
+	}
+	app := info.exit(status, reason)
 
+	current := r.lookup(app.ID)
+	if current == info {
+		if err != nil {
+			r.logger.Error("application exited with error",
+				"app_id", app.ID,
+				"error", err,
+			)
+			r.emit(app, types.AppEventExited, message)
+		} else {
+			r.logger.Info("application stopped",
+				"app_id", app.ID,
+			)
+			r.emit(app, types.AppEventExited, "exit status 0")
+		}
+	}
+
+	// Skip the hook if the application was restarted in the meantime. The
+	// app API identifies the instance by the application it was opened for,
+	// not by the snapshot.
+	if current == nil || current == info {
+		r.afterExit(app, info.app)
+	}
+}
+
+// collectCoreDump records the core dump the crashed process of info left in
+// its working directory, if its manifest asks for core dumps, and removes
+// all but the keepCoreDumps newest. It returns the name of the dump, "" if
+// the process left none.
+func (r *Runtime) collectCoreDump(info *appInfo) string {
+	app := info.snapshot()
+	if r.cores == "" || !app.Manifest.CoreDumps {
+		return ""
+	}
+
+	dumps, err := coredump.List(app.WorkDir, r.cores)
+	if err != nil {
+		r.logger.Warn("failed to look for core dumps", "app_id", app.ID, "error", err)
+		return ""
+	}
+	if len(dumps) == 0 || dumps[0].Time.Before(app.StartedAt) {
+		return ""
+	}
+	if err := coredump.Prune(app.WorkDir, r.cores, keepCoreDumps); err != nil {
+		r.logger.Warn("failed to remove old core dumps", "app_id", app.ID, "error", err)
+	}
+
+	info.mu.Lock()
+	info.app.CoreDump = dumps[0].Name
+	info.mu.Unlock()
+	r.logger.Info("application dumped core", "app_id", app.ID, "core_dump", dumps[0].Name, "size", dumps[0].Size)
+	return dumps[0].Name
+}
+
+// applyUser switches spec to the app's user and gives that user its working directory.
+// The user joins the groups owning devices so it can open them.
+func (r *Runtime) applyUser(ctx context.Context, spec *ProcessSpec, app *types.Application, devices []string) error {
+	if r.user == nil {
+		return nil
+	}
+
+	cred, err := r.user(ctx, app)
+	if err != nil || cred == nil {
+		return err
+	}
+
+	// Only the app's user may read its files; the daemon keeps access as root
+	dirs := []string{app.WorkDir}
+	if r.scratch != "" {
+		dirs = append(dirs, filepath.Join(r.scratch, app.ID))
+	}
+	if len(app.Manifest.Volumes) > 0 {
+		dirs = append(dirs, filepath.Join(r.volumes, app.Name))
+	}
+	for _, dir := range dirs {
+		err = filepath.WalkDir(dir, func(path string, _ os.DirEntry, err error) error {
 			if err != nil {
-				info.app.Status = types.AppStatusFailed
-				r.logger.Error("application exited with error",
-					"app_id", info.app.ID,
-					"error", err,
-				)
-			} else {
-				info.app.Status = types.AppStatusStopped
-				r.logger.Info("application stopped",
-					"app_id", info.app.ID,
-				)
+				return err
 			}
-			info.app.PID = 0
+			return os.Lchown(path, int(cred.UID), int(cred.GID))
+		})
+		if err != nil {
+			return types.WrapError(err, "failed to change owner of app directory")
 		}
-	}()
+		if err := os.Chmod(dir, 0700); err != nil {
+			return types.WrapError(err, "failed to restrict app directory")
+		}
+	}
 
-	r.logger.Info("application started",
-		"app_id", app.ID,
-		"pid", app.PID,
-	)
+	groups, err := deviceGroups(devices)
+	if err != nil {
+		return err
+	}
+
+	spec.Credential = cred
+	spec.Groups = groups
 
+	logging.FromContextOr(ctx, r.logger).Info("running application as unprivileged user", "app_id", app.ID, "uid", cred.UID, "gid", cred.GID, "groups", groups)
 	return nil
 }
 
+// afterExit releases the app's network sandbox and API socket, and runs the
+// AfterExit hook, if any. opened is the application the app API was opened
+// for; app may be a snapshot of it.
+func (r *Runtime) afterExit(app, opened *types.Application) {
+	if r.network != nil {
+		r.network.Teardown(app)
+	}
+	if r.appAPI != nil {
+		r.appAPI.Close(opened)
+	}
+	if r.hooks.AfterExit != nil {
+		r.hooks.AfterExit(app)
+	}
+}
+
 // convertHealthCheckConfig converts manifest health check config to health package config
 func convertHealthCheckConfig(hc *types.HealthCheckConfig) *health.Config {
 	cfg := &health.Config{
@@ -219,127 +811,274 @@ func convertHealthCheckConfig(hc *types.HealthCheckConfig) *health.Config {
 
 	// Parse endpoint for HTTP/TCP
 	if hc.Endpoint != "" {
-		// Simple parsing: port number for TCP, full URL for HTTP
+		port, path := parseEndpoint(hc.Endpoint)
 		switch cfg.Type {
 		case health.CheckTypeHTTP:
-			// Extract port from endpoint (assuming format like ":8080/health")
-			// For now, use default port 8080
-			cfg.HTTPPort = 8080
-			cfg.HTTPPath = hc.Endpoint
+			cfg.HTTPPort = port
+			cfg.HTTPPath = path
 		case health.CheckTypeTCP:
-			// Extract port from endpoint (assuming format like ":8080")
-			cfg.TCPPort = 8080
+			cfg.TCPPort = port
 		}
 	}
 
 	return cfg
 }
 
+// defaultHealthPort is checked when a health check endpoint names no port
+const defaultHealthPort = 8080
+
+// parseEndpoint returns the port and path of a health check endpoint given as
+// a URL ("http://localhost:8080/health"), an address with optional path
+// (":8080/health", "127.0.0.1:9000") or a bare port ("9000"). Checks always
+// connect to localhost, so the host is ignored.
+func parseEndpoint(endpoint string) (int, string) {
+	if u, err := url.Parse(endpoint); err == nil && u.Scheme != "" && u.Host != "" {
+		port, err := strconv.Atoi(u.Port())
+		if err != nil {
+			port = defaultHealthPort
+		}
+		return port, u.Path
+	}
+
+	addr, path := endpoint, ""
+	if i := strings.Index(endpoint, "/"); i >= 0 {
+		addr, path = endpoint[:i], endpoint[i:]
+	}
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		addr = addr[i+1:]
+	}
+	port, err := strconv.Atoi(addr)
+	if err != nil {
+		port = defaultHealthPort
+	}
+	return port, path
+}
+
 // Stop stops a running application
 func (r *Runtime) Stop(ctx context.Context, appID string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	unlock := r.lockApp(appID)
+	defer unlock()
+	return r.stopLocked(ctx, appID)
+}
 
-	info, exists := r.apps[appID]
-	if !exists {
+// stopLocked stops a running application. The caller holds the lock of the app.
+func (r *Runtime) stopLocked(ctx context.Context, appID string) error {
+	log := logging.FromContextOr(ctx, r.logger)
+	info := r.lookup(appID)
+	if info == nil {
 		return types.ErrNotFound
 	}
 
-	if info.app.Status != types.AppStatusRunning {
+	info.mu.Lock()
+	paused := info.app.Status == types.AppStatusPaused
+	if info.app.Status != types.AppStatusRunning && !paused {
+		info.mu.Unlock()
 		return types.ErrAppNotRunning
 	}
 
+	info.stopping = true
+	info.app.Initiator = InitiatorFromContext(ctx)
+
 	// Cancel health monitoring
 	if info.cancelHealth != nil {
 		info.cancelHealth()
 		info.cancelHealth = nil
 	}
+	info.mu.Unlock()
 
-	// Find process
-	process, err := os.FindProcess(info.app.PID)
-	if err != nil {
-		return types.WrapError(err, "failed to find process")
+	// Tell the app why it is signalled and when it will be killed
+	reason := "stop requested"
+	if initiator := InitiatorFromContext(ctx); initiator != nil {
+		reason += " by " + initiator.String()
+	}
+	if r.appAPI != nil {
+		r.appAPI.Stopping(info.snapshot(), reason, stopTimeout)
 	}
 
-	// Send SIGTERM
-	if err := process.Signal(syscall.SIGTERM); err != nil {
+	// A paused process only handles the signal once resumed
+	if paused {
+		if err := info.proc.Resume(); err != nil {
+			log.Warn("failed to resume paused application before stopping it", "app_id", appID, "error", err)
+		}
+	}
+
+	if err := info.proc.Terminate(); err != nil {
 		return types.WrapError(err, "failed to stop process")
 	}
 
 	// Wait for graceful shutdown (with timeout)
-	done := make(chan struct{})
-	go func() {
-		_, _ = process.Wait()
-		close(done)
-	}()
-
 	select {
-	case <-done:
-		r.logger.Info("application stopped gracefully", "app_id", appID)
-	case <-time.After(10 * time.Second):
+	case <-info.exited:
+		log.Info("application stopped gracefully", "app_id", appID)
+	case <-time.After(stopTimeout):
 		// Force kill
-		r.logger.Warn("application did not stop gracefully, forcing kill", "app_id", appID)
-		_ = process.Kill()
+		log.Warn("application did not stop gracefully, forcing kill", "app_id", appID)
+		_ = info.proc.Kill()
+	}
+
+	r.emit(info.exit(types.AppStatusStopped, ""), types.AppEventStopped, "stop requested")
+
+	return nil
+}
+
+// emit reports a lifecycle event, if events are reported
+func (r *Runtime) emit(app *types.Application, event types.AppEvent, message string) {
+	if r.events != nil {
+		r.events(app, event, message)
 	}
+}
 
-	info.app.Status = types.AppStatusStopped
-	info.app.PID = 0
+// Remove forgets a stopped application, as when its deployment is rolled back
+func (r *Runtime) Remove(ctx context.Context, appID string) error {
+	unlock := r.lockApp(appID)
+	defer unlock()
+
+	info := r.lookup(appID)
+	if info == nil {
+		return types.ErrNotFound
+	}
+	if status := info.status(); status == types.AppStatusRunning || status == types.AppStatusRestarting || status == types.AppStatusPaused {
+		return fmt.Errorf("%w: %s is %s", types.ErrAppAlreadyRunning, appID, status)
+	}
 
+	r.mu.Lock()
+	delete(r.apps, appID)
+	r.mu.Unlock()
 	return nil
 }
 
+// restartDelay is how long Restart waits between stopping and starting an app
+const restartDelay = time.Second
+
 // Restart restarts an application
 func (r *Runtime) Restart(ctx context.Context, appID string) error {
-	// Get autoRestart setting before stopping
-	r.mu.RLock()
-	info, exists := r.apps[appID]
-	r.mu.RUnlock()
+	unlock := r.lockApp(appID)
+	defer unlock()
 
-	if !exists {
+	info := r.lookup(appID)
+	if info == nil {
 		return types.ErrNotFound
 	}
 
-	autoRestart := info.autoRestart
-
 	// Stop first
-	if err := r.Stop(ctx, appID); err != nil && err != types.ErrAppNotRunning {
+	if err := r.stopLocked(ctx, appID); err != nil && err != types.ErrAppNotRunning {
 		return err
 	}
 
 	// Wait a bit
-	time.Sleep(time.Second)
+	time.Sleep(restartDelay)
 
-	// Get app info again
-	r.mu.RLock()
-	info, exists = r.apps[appID]
-	r.mu.RUnlock()
+	// Start again with same autoRestart setting
+	return r.startLocked(ctx, info.snapshot(), info.autoRestart)
+}
 
-	if !exists {
+// Pause suspends a running application, keeping the state of its process,
+// until Resume. Its health is not monitored meanwhile, so a paused app is
+// not restarted for failing its health check.
+func (r *Runtime) Pause(ctx context.Context, appID string) error {
+	unlock := r.lockApp(appID)
+	defer unlock()
+
+	info := r.lookup(appID)
+	if info == nil {
 		return types.ErrNotFound
 	}
+	switch status := info.status(); status {
+	case types.AppStatusRunning:
+	case types.AppStatusPaused:
+		return fmt.Errorf("%w: %s is already paused", types.ErrInvalidState, appID)
+	default:
+		return fmt.Errorf("%w: %s is %s", types.ErrAppNotRunning, appID, status)
+	}
 
-	// Start again with same autoRestart setting
-	return r.start(ctx, info.app, autoRestart)
+	if err := info.proc.Pause(); err != nil {
+		return types.WrapError(err, "failed to pause process")
+	}
+
+	info.mu.Lock()
+	// The process may have exited before it was paused
+	if info.app.Status != types.AppStatusRunning {
+		status := info.app.Status
+		info.mu.Unlock()
+		return fmt.Errorf("%w: %s is %s", types.ErrAppNotRunning, appID, status)
+	}
+	if info.cancelHealth != nil {
+		info.cancelHealth()
+		info.cancelHealth = nil
+	}
+	info.app.Status = types.AppStatusPaused
+	info.app.Initiator = InitiatorFromContext(ctx)
+	app := info.copyLocked()
+	info.mu.Unlock()
+
+	logging.FromContextOr(ctx, r.logger).Info("application paused", "app_id", appID, "pid", app.PID)
+	r.emit(app, types.AppEventPaused, "pause requested")
+	return nil
+}
+
+// Resume continues a paused application and monitors its health again
+func (r *Runtime) Resume(ctx context.Context, appID string) error {
+	unlock := r.lockApp(appID)
+	defer unlock()
+
+	info := r.lookup(appID)
+	if info == nil {
+		return types.ErrNotFound
+	}
+	if status := info.status(); status != types.AppStatusPaused {
+		return fmt.Errorf("%w: %s is %s, not paused", types.ErrInvalidState, appID, status)
+	}
+
+	if err := info.proc.Resume(); err != nil {
+		return types.WrapError(err, "failed to resume process")
+	}
+
+	info.mu.Lock()
+	if info.app.Status != types.AppStatusPaused {
+		status := info.app.Status
+		info.mu.Unlock()
+		return fmt.Errorf("%w: %s is %s", types.ErrAppNotRunning, appID, status)
+	}
+	info.app.Status = types.AppStatusRunning
+	info.app.Initiator = InitiatorFromContext(ctx)
+	app := info.copyLocked()
+	info.mu.Unlock()
+
+	if info.healthChecker != nil {
+		cancel := r.watchHealth(info)
+		info.mu.Lock()
+		if info.app.Status == types.AppStatusRunning {
+			info.cancelHealth = cancel
+		} else {
+			// The process exited in the meantime
+			cancel()
+		}
+		info.mu.Unlock()
+	}
+
+	logging.FromContextOr(ctx, r.logger).Info("application resumed", "app_id", appID, "pid", app.PID)
+	r.emit(app, types.AppEventResumed, "resume requested")
+	return nil
 }
 
 // Status returns the status of an application
 func (r *Runtime) Status(ctx context.Context, appID string) (*types.AppStatus, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	info, exists := r.apps[appID]
-	if !exists {
+	info := r.lookup(appID)
+	if info == nil {
 		return nil, types.ErrNotFound
 	}
 
+	app := info.snapshot()
 	status := &types.AppStatus{
-		App:     info.app,
-		Healthy: info.app.Status == types.AppStatusRunning,
-		Message: string(info.app.Status),
+		App: app,
+		AppHealth: types.AppHealth{
+			Healthy: app.Status == types.AppStatusRunning,
+			Message: string(app.Status),
+		},
 	}
 
-	// Include health check information if available
-	if info.healthChecker != nil {
+	// Include health check information if available; it is stale once the process is gone
+	if info.healthChecker != nil && app.Status == types.AppStatusRunning {
 		lastResult := info.healthChecker.LastResult()
 		if lastResult != nil {
 			status.Healthy = lastResult.Healthy
@@ -348,75 +1087,159 @@ func (r *Runtime) Status(ctx context.Context, appID string) (*types.AppStatus, e
 		}
 	}
 
-	return status, nil
-}
-
-// Logs returns a stream of application logs
-func (r *Runtime) Logs(ctx context.Context, appID string, follow bool) (io.ReadCloser, error) {
-	r.mu.RLock()
-	info, exists := r.apps[appID]
-	r.mu.RUnlock()
+	if (app.Status == types.AppStatusRunning || app.Status == types.AppStatusPaused) && app.PID > 0 {
+		if usage, err := processUsage(app.PID); err == nil {
+			if cpu, err := info.cpuPercent(app.PID, app.StartedAt); err == nil {
+				usage.CPUPercent = cpu
+			}
+			status.ResourceUsage = usage
+		}
+	}
 
-	if !exists {
-		return nil, types.ErrNotFound
+	// What the app reports about itself can only make the status worse
+	if r.appAPI != nil {
+		if reported := r.appAPI.Health(appID); reported != nil {
+			status.Reported = &types.ReportedHealth{
+				Status:    reported.Status,
+				Message:   reported.Message,
+				UpdatedAt: reported.UpdatedAt,
+			}
+			if status.Healthy && reported.Status != appsdk.HealthHealthy {
+				status.Healthy = reported.Status != appsdk.HealthUnhealthy
+				status.Message = reported.Status
+				if reported.Message != "" {
+					status.Message += ": " + reported.Message
+				}
+			}
+		}
+		status.Metrics = r.appAPI.Metrics(appID)
 	}
 
-	logPath := filepath.Join(info.app.WorkDir, "logs", "stdout.log")
+	return status, nil
+}
 
-	if !follow {
-		// Just return the file
-		return os.Open(logPath)
+// SetLabels replaces the labels of a registered application, the one part
+// of its spec that changes after it is deployed, and returns a snapshot of
+// it. The app API keeps telling the app the labels it was started with.
+func (r *Runtime) SetLabels(appID string, labels map[string]string) (*types.Application, error) {
+	info := r.lookup(appID)
+	if info == nil {
+		return nil, types.ErrNotFound
 	}
-
-	// Follow logs (tail -f style)
-	file, err := os.Open(logPath)
-	if err != nil {
-		return nil, types.WrapError(err, "failed to open log file")
+	if labels == nil {
+		labels = map[string]string{}
 	}
+	info.mu.Lock()
+	info.labels = labels
+	info.mu.Unlock()
+	return info.snapshot(), nil
+}
 
-	// Seek to end
-	_, _ = file.Seek(0, io.SeekEnd)
+// WaitHealthy waits until a started application passes its health check or,
+// if it has none, has kept running for a moment. It fails as soon as the
+// application exits, and when ctx is done, with the last check result and the
+// tail of the application's stderr.
+func (r *Runtime) WaitHealthy(ctx context.Context, appID string) error {
+	return r.waitHealthy(ctx, appID, true)
+}
 
-	pr, pw := io.Pipe()
+// WaitStarted waits until a started application has kept running for a
+// moment, without waiting for its health check. It fails as soon as the
+// application exits, with the tail of the application's stderr.
+func (r *Runtime) WaitStarted(ctx context.Context, appID string) error {
+	return r.waitHealthy(ctx, appID, false)
+}
 
-	go func() {
-		defer func() { _ = file.Close() }()
-		defer func() { _ = pw.Close() }()
+// waitHealthy implements WaitHealthy, and WaitStarted unless probe is set
+func (r *Runtime) waitHealthy(ctx context.Context, appID string, probe bool) error {
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+
+	started := time.Now()
+	message := "not checked yet"
+	for {
+		info := r.lookup(appID)
+		if info == nil {
+			return types.ErrNotFound
+		}
+		status, checker, logDir := info.status(), info.healthChecker, r.LogDir(info.app)
+		if !probe {
+			checker = nil
+		}
 
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			_, _ = pw.Write(scanner.Bytes())
-			_, _ = pw.Write([]byte("\n"))
+		if status != types.AppStatusRunning && status != types.AppStatusRestarting {
+			return fmt.Errorf("%w: %s is %s%s", types.ErrAppUnhealthy, appID, status, stderrTail(logDir))
 		}
 
-		// Keep checking for new lines
-		ticker := time.NewTicker(500 * time.Millisecond)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				for scanner.Scan() {
-					_, _ = pw.Write(scanner.Bytes())
-					_, _ = pw.Write([]byte("\n"))
-				}
+		if checker == nil {
+			if status == types.AppStatusRunning && time.Since(started) >= healthSettle {
+				return nil
+			}
+		} else {
+			result, err := checker.Probe(ctx)
+			switch {
+			case ctx.Err() != nil:
+				// Keep the result of the last probe that ran to completion
+			case err != nil:
+				message = err.Error()
+			case result.Healthy:
+				return nil
+			default:
+				message = result.Message
 			}
 		}
-	}()
 
-	return pr, nil
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s did not become healthy: %s%s", types.ErrAppUnhealthy, appID, message, stderrTail(logDir))
+		case <-ticker.C:
+		}
+	}
+}
+
+// LogDir returns the directory holding the log files of app
+func (r *Runtime) LogDir(app *types.Application) string {
+	if r.scratch != "" {
+		return filepath.Join(r.scratch, app.ID, "logs")
+	}
+	return filepath.Join(app.WorkDir, "logs")
+}
+
+// stderrTail returns the last lines of the stderr log in logDir, on lines of their own
+func stderrTail(logDir string) string {
+	file, err := os.Open(filepath.Join(logDir, "stderr.log"))
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = file.Close() }()
+
+	// The last lines are all we need, however long the log has grown
+	if info, err := file.Stat(); err == nil && info.Size() > failureLogBytes {
+		_, _ = file.Seek(-failureLogBytes, io.SeekEnd)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > failureLogLines {
+		lines = lines[len(lines)-failureLogLines:]
+	}
+	return "\nstderr:\n  " + strings.Join(lines, "\n  ")
 }
 
-// List returns all managed applications
+// List returns snapshots of all managed applications
 func (r *Runtime) List(ctx context.Context) ([]*types.Application, error) {
 	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	apps := make([]*types.Application, 0, len(r.apps))
+	infos := make([]*appInfo, 0, len(r.apps))
 	for _, info := range r.apps {
-		apps = append(apps, info.app)
+		infos = append(infos, info)
+	}
+	r.mu.RUnlock()
+
+	apps := make([]*types.Application, 0, len(infos))
+	for _, info := range infos {
+		apps = append(apps, info.snapshot())
 	}
 
 	return apps, nil
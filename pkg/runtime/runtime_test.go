@@ -0,0 +1,679 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appapi"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/runtime"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// fakeBackend starts fake processes that run until terminated or killed
+type fakeBackend struct {
+	mu    sync.Mutex
+	procs map[string]*fakeProcess // latest process of each app ID
+	pid   atomic.Int64
+
+	// stubborn apps ignore Terminate, like a process stuck in shutdown
+	stubborn map[string]bool
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{procs: make(map[string]*fakeProcess), stubborn: make(map[string]bool)}
+}
+
+func (b *fakeBackend) Name() string {
+	return "fake"
+}
+
+func (b *fakeBackend) Start(ctx context.Context, spec *runtime.ProcessSpec) (runtime.Process, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := &fakeProcess{
+		pid:        int(b.pid.Add(1)),
+		spec:       spec,
+		stubborn:   b.stubborn[spec.App.ID],
+		terminated: make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	b.procs[spec.App.ID] = p
+	return p, nil
+}
+
+// process returns the latest process started for appID
+func (b *fakeBackend) process(appID string) *fakeProcess {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.procs[appID]
+}
+
+type fakeProcess struct {
+	pid        int
+	spec       *runtime.ProcessSpec
+	stubborn   bool
+	termOnce   sync.Once
+	terminated chan struct{} // closed once Terminate was called
+	exitOnce   sync.Once
+	done       chan struct{} // closed once the process exited
+	paused     atomic.Bool
+}
+
+func (p *fakeProcess) PID() int {
+	return p.pid
+}
+
+func (p *fakeProcess) Terminate() error {
+	p.termOnce.Do(func() { close(p.terminated) })
+	if !p.stubborn {
+		p.exit()
+	}
+	return nil
+}
+
+func (p *fakeProcess) Kill() error {
+	p.exit()
+	return nil
+}
+
+func (p *fakeProcess) Pause() error {
+	p.paused.Store(true)
+	return nil
+}
+
+func (p *fakeProcess) Resume() error {
+	p.paused.Store(false)
+	return nil
+}
+
+// Wait reports a process that was terminated as killed by the signal, like exec does
+func (p *fakeProcess) Wait() error {
+	<-p.done
+	select {
+	case <-p.terminated:
+		return errors.New("signal: terminated")
+	default:
+		return nil
+	}
+}
+
+func (p *fakeProcess) exit() {
+	p.exitOnce.Do(func() { close(p.done) })
+}
+
+func newRuntime(t *testing.T, opts ...runtime.Option) (*runtime.Runtime, *fakeBackend) {
+	t.Helper()
+	backend := newFakeBackend()
+	opts = append(opts, runtime.WithBackend(backend))
+	return runtime.New(logging.NewNopLogger(), opts...), backend
+}
+
+func newApp(t *testing.T, id string) *types.Application {
+	t.Helper()
+	return types.NewApplication(types.AppSpec{
+		ID:       id,
+		Name:     id,
+		Version:  "1.0.0",
+		Manifest: &types.Manifest{Name: id, Version: "1.0.0", Entrypoint: "app"},
+		WorkDir:  t.TempDir(),
+	})
+}
+
+// within fails the test unless fn returns within d
+func within(t *testing.T, d time.Duration, what string, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("%s blocked for more than %s", what, d)
+	}
+}
+
+func TestStopDoesNotBlockOtherApps(t *testing.T) {
+	ctx := context.Background()
+	rt, backend := newRuntime(t)
+	backend.stubborn["slow"] = true
+
+	for _, id := range []string{"slow", "fast"} {
+		if err := rt.Start(ctx, newApp(t, id)); err != nil {
+			t.Fatalf("Start(%s) error = %v", id, err)
+		}
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- rt.Stop(ctx, "slow") }()
+
+	// Wait until Stop waits for the stubborn process to exit
+	slow := backend.process("slow")
+	select {
+	case <-slow.terminated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not terminate the process")
+	}
+
+	within(t, time.Second, "List", func() {
+		if apps, err := rt.List(ctx); err != nil || len(apps) != 2 {
+			t.Errorf("List() = %d apps, %v; want 2", len(apps), err)
+		}
+	})
+	within(t, time.Second, "Status", func() {
+		if status, err := rt.Status(ctx, "fast"); err != nil || status.App.Status != types.AppStatusRunning {
+			t.Errorf("Status(fast) = %+v, %v; want running", status, err)
+		}
+	})
+	within(t, time.Second, "Stop of another app", func() {
+		if err := rt.Stop(ctx, "fast"); err != nil {
+			t.Errorf("Stop(fast) error = %v", err)
+		}
+	})
+
+	// The stuck app is reported as running until it is gone
+	if status, err := rt.Status(ctx, "slow"); err != nil || status.App.Status != types.AppStatusRunning {
+		t.Errorf("Status(slow) while stopping = %+v, %v; want running", status, err)
+	}
+
+	slow.exit()
+	if err := <-stopped; err != nil {
+		t.Errorf("Stop(slow) error = %v", err)
+	}
+	if status, err := rt.Status(ctx, "slow"); err != nil || status.App.Status != types.AppStatusStopped {
+		t.Errorf("Status(slow) after stop = %+v, %v; want stopped", status, err)
+	}
+}
+
+func TestAppAPIClosed(t *testing.T) {
+	ctx := context.Background()
+	api := appapi.New(appapi.Node{ID: "node"}, logging.NewNopLogger())
+	rt, backend := newRuntime(t, runtime.WithAppAPI(api))
+
+	exists := func(app *types.Application) bool {
+		_, err := os.Stat(filepath.Join(app.WorkDir, appapi.SocketName))
+		return err == nil
+	}
+	// The session is closed once the exit of the process was handled
+	waitClosed := func(app *types.Application) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for exists(app) && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if exists(app) {
+			t.Errorf("socket of %s still exists", app.ID)
+		}
+	}
+
+	stopped, exiting := newApp(t, "stopped"), newApp(t, "exiting")
+	for _, app := range []*types.Application{stopped, exiting} {
+		if err := rt.Start(ctx, app); err != nil {
+			t.Fatalf("Start(%s) error = %v", app.ID, err)
+		}
+		if !exists(app) {
+			t.Fatalf("Start(%s) did not open the app API", app.ID)
+		}
+	}
+
+	// A restart replaces the session, which the exit of the first process leaves alone
+	if err := rt.Restart(ctx, "stopped"); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !exists(stopped) {
+		t.Fatal("socket removed by the exit of the process before the restart")
+	}
+
+	if err := rt.Stop(ctx, "stopped"); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	waitClosed(stopped)
+
+	backend.process("exiting").exit()
+	waitClosed(exiting)
+}
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	rt, _ := newRuntime(t)
+
+	app := newApp(t, "web")
+	if err := rt.Start(ctx, app); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if app.Status != types.AppStatusRunning || app.PID == 0 {
+		t.Errorf("Start() left the app %s with pid %d, want running with a pid", app.Status, app.PID)
+	}
+
+	apps, err := rt.List(ctx)
+	if err != nil || len(apps) != 1 {
+		t.Fatalf("List() = %v, %v", apps, err)
+	}
+	listed := apps[0]
+
+	if err := rt.Stop(ctx, "web"); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	// What was returned earlier is not changed behind the caller's back
+	if listed.Status != types.AppStatusRunning || app.Status != types.AppStatusRunning {
+		t.Errorf("earlier results changed to %s and %s by Stop()", listed.Status, app.Status)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	ctx := context.Background()
+	rt, backend := newRuntime(t)
+
+	if err := rt.Start(ctx, newApp(t, "web")); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	first := backend.process("web")
+
+	if err := rt.Restart(ctx, "web"); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	second := backend.process("web")
+	if second == first {
+		t.Fatal("Restart() did not start a new process")
+	}
+
+	// The exit of the first process must not affect the second
+	status, err := rt.Status(ctx, "web")
+	if err != nil || status.App.Status != types.AppStatusRunning || status.App.PID != second.PID() {
+		t.Errorf("Status() after restart = %+v, %v; want running with pid %d", status, err, second.PID())
+	}
+	if err := rt.Restart(ctx, "missing"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Restart() of a missing app error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var events []types.AppEvent
+	rt, backend := newRuntime(t, runtime.WithEvents(func(app *types.Application, event types.AppEvent, message string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}))
+
+	if err := rt.Pause(ctx, "web"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Pause() of a missing app error = %v, want ErrNotFound", err)
+	}
+	if err := rt.Start(ctx, newApp(t, "web")); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	proc := backend.process("web")
+	if err := rt.Resume(ctx, "web"); !errors.Is(err, types.ErrInvalidState) {
+		t.Errorf("Resume() of a running app error = %v, want ErrInvalidState", err)
+	}
+
+	pause := types.Initiator{Kind: types.InitiatorOperator, ID: "alice", Reason: "pause, request 1"}
+	if err := rt.Pause(runtime.WithInitiator(ctx, pause), "web"); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	status, err := rt.Status(ctx, "web")
+	if err != nil || status.App.Status != types.AppStatusPaused || !proc.paused.Load() {
+		t.Fatalf("Status() after Pause() = %+v, %v; want the process paused", status, err)
+	}
+	if status.App.Initiator == nil || *status.App.Initiator != pause || status.Healthy {
+		t.Errorf("paused app initiated by %v, healthy %v; want %v, unhealthy", status.App.Initiator, status.Healthy, &pause)
+	}
+	if err := rt.Pause(ctx, "web"); !errors.Is(err, types.ErrInvalidState) {
+		t.Errorf("Pause() of a paused app error = %v, want ErrInvalidState", err)
+	}
+	if err := rt.Start(ctx, newApp(t, "web")); !errors.Is(err, types.ErrAppAlreadyRunning) {
+		t.Errorf("Start() of a paused app error = %v, want ErrAppAlreadyRunning", err)
+	}
+
+	if err := rt.Resume(ctx, "web"); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if status, err := rt.Status(ctx, "web"); err != nil || status.App.Status != types.AppStatusRunning || proc.paused.Load() {
+		t.Fatalf("Status() after Resume() = %+v, %v; want the process running", status, err)
+	}
+
+	// A paused app is resumed to be stopped
+	if err := rt.Pause(ctx, "web"); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if err := rt.Stop(ctx, "web"); err != nil {
+		t.Fatalf("Stop() of a paused app error = %v", err)
+	}
+	if proc.paused.Load() {
+		t.Error("Stop() left the process paused")
+	}
+	if err := rt.Pause(ctx, "web"); !errors.Is(err, types.ErrAppNotRunning) {
+		t.Errorf("Pause() of a stopped app error = %v, want ErrAppNotRunning", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []types.AppEvent{types.AppEventStarted, types.AppEventPaused, types.AppEventResumed, types.AppEventPaused}
+	if len(events) < len(want) || !slices.Equal(events[:len(want)], want) {
+		t.Errorf("events = %v, want them to start with %v", events, want)
+	}
+}
+
+func TestSetLabels(t *testing.T) {
+	ctx := context.Background()
+	rt, _ := newRuntime(t)
+
+	app := newApp(t, "web")
+	app.Labels = map[string]string{"env": "prod"}
+	if err := rt.Start(ctx, app); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	updated, err := rt.SetLabels("web", map[string]string{"env": "canary"})
+	if err != nil || updated.Labels["env"] != "canary" {
+		t.Fatalf("SetLabels() = %+v, %v; want env=canary", updated, err)
+	}
+	if app.Labels["env"] != "prod" {
+		t.Errorf("SetLabels() changed the labels of the app started")
+	}
+
+	// The labels outlive restarts
+	if err := rt.Restart(ctx, "web"); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	status, err := rt.Status(ctx, "web")
+	if err != nil || status.App.Labels["env"] != "canary" {
+		t.Errorf("Status() after restart = %+v, %v; want env=canary", status, err)
+	}
+
+	if _, err := rt.SetLabels("missing", nil); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("SetLabels() of a missing app error = %v, want ErrNotFound", err)
+	}
+}
+
+// TestConcurrentOperations interleaves every operation on a few apps; run
+// it with -race
+func TestConcurrentOperations(t *testing.T) {
+	ctx := context.Background()
+	rt, _ := newRuntime(t)
+
+	const numApps = 4
+	const rounds = 50
+	apps := make([]*types.Application, numApps)
+	for i := range apps {
+		apps[i] = newApp(t, fmt.Sprintf("app-%d", i))
+	}
+
+	// Errors saying an app is not in the state an operation needs are expected
+	expected := func(err error) bool {
+		return err == nil ||
+			errors.Is(err, types.ErrAppAlreadyRunning) ||
+			errors.Is(err, types.ErrAppNotRunning) ||
+			errors.Is(err, types.ErrNotFound)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, numApps*rounds*4)
+	for i, app := range apps {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				a := *app
+				if err := rt.Start(ctx, &a); !expected(err) {
+					errs <- fmt.Errorf("Start(%s): %w", app.ID, err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				if err := rt.Stop(ctx, app.ID); !expected(err) {
+					errs <- fmt.Errorf("Stop(%s): %w", app.ID, err)
+				}
+				if err := rt.Remove(ctx, app.ID); !expected(err) {
+					errs <- fmt.Errorf("Remove(%s): %w", app.ID, err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			// Restarts wait between stopping and starting, so only a few
+			if i%2 == 0 {
+				if err := rt.Restart(ctx, app.ID); !expected(err) {
+					errs <- fmt.Errorf("Restart(%s): %w", app.ID, err)
+				}
+			}
+			for range rounds {
+				if _, err := rt.Status(ctx, app.ID); !expected(err) {
+					errs <- fmt.Errorf("Status(%s): %w", app.ID, err)
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range rounds {
+			listed, err := rt.List(ctx)
+			if err != nil {
+				errs <- fmt.Errorf("List: %w", err)
+			}
+			for _, app := range listed {
+				_ = app.Status
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Whatever is left running can still be stopped
+	listed, err := rt.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	for _, app := range listed {
+		if app.Status != types.AppStatusRunning {
+			continue
+		}
+		if err := rt.Stop(ctx, app.ID); err != nil {
+			t.Errorf("Stop(%s) error = %v", app.ID, err)
+		}
+	}
+}
+
+func TestScratchDir(t *testing.T) {
+	ctx := context.Background()
+	scratch := t.TempDir()
+	rt, backend := newRuntime(t, runtime.WithScratchDir(scratch))
+
+	app := newApp(t, "web")
+	tmpDir := filepath.Join(scratch, "web", "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "stale"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Start(ctx, app); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = rt.Stop(ctx, "web") }()
+
+	spec := backend.process("web").spec
+	logDir := filepath.Join(scratch, "web", "logs")
+	if rt.LogDir(app) != logDir || filepath.Dir(spec.StdoutPath) != logDir || filepath.Dir(spec.StderrPath) != logDir {
+		t.Errorf("logs in %s, %s and %s, want %s", rt.LogDir(app), spec.StdoutPath, spec.StderrPath, logDir)
+	}
+	if !slices.Contains(spec.Env, "TMPDIR="+tmpDir) {
+		t.Errorf("Env = %v, want TMPDIR=%s", spec.Env, tmpDir)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "stale")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale scratch file stat error = %v, want it removed on start", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.WorkDir, "logs")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("logs directory in the working directory stat error = %v, want none", err)
+	}
+}
+
+func TestStartRefusesOtherPlatform(t *testing.T) {
+	rt, backend := newRuntime(t)
+
+	app := newApp(t, "web")
+	app.Manifest.Platform = "plan9/mips"
+	err := rt.Start(context.Background(), app)
+	if !errors.Is(err, types.ErrAppStartFailed) || !errors.Is(err, types.ErrUnavailable) {
+		t.Fatalf("Start() error = %v, want ErrAppStartFailed and ErrUnavailable", err)
+	}
+	if backend.process("web") != nil {
+		t.Error("Start() started a process built for another platform")
+	}
+	if app.Status != types.AppStatusFailed {
+		t.Errorf("Start() left the app %s, want failed", app.Status)
+	}
+}
+
+func TestStartWithInterpreter(t *testing.T) {
+	ctx := context.Background()
+	rt, backend := newRuntime(t)
+
+	app := newApp(t, "script")
+	app.Manifest.Interpreter = "sh"
+	app.Manifest.Args = []string{"--verbose"}
+	if err := rt.Start(ctx, app); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = rt.Stop(ctx, "script") }()
+
+	spec := backend.process("script").spec
+	want := []string{filepath.Join(app.WorkDir, "app"), "--verbose"}
+	if filepath.Base(spec.Path) != "sh" || !slices.Equal(spec.Args, want) {
+		t.Errorf("started %s %v, want sh %v", spec.Path, spec.Args, want)
+	}
+
+	missing := newApp(t, "missing")
+	missing.Manifest.Interpreter = "no-such-interpreter"
+	if err := rt.Start(ctx, missing); !errors.Is(err, types.ErrAppStartFailed) || !errors.Is(err, types.ErrUnavailable) {
+		t.Errorf("Start() with a missing interpreter error = %v, want ErrAppStartFailed and ErrUnavailable", err)
+	}
+}
+
+func TestArgTemplates(t *testing.T) {
+	ctx := context.Background()
+	rt, backend := newRuntime(t)
+
+	app := newApp(t, "web")
+	app.Manifest.Ports = []string{"http", "admin-api"}
+	app.Manifest.Args = []string{"--listen", `{{ .Addr "http" }}`, `--admin={{ .Port "admin-api" }}`, "--id={{ .AppID }}", "plain"}
+	if err := rt.Start(ctx, app); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = rt.Stop(ctx, "web") }()
+
+	http, admin := app.Ports["http"], app.Ports["admin-api"]
+	if http == 0 || admin == 0 || http == admin {
+		t.Fatalf("Ports = %v, want two different ports", app.Ports)
+	}
+	spec := backend.process("web").spec
+	want := []string{"--listen", fmt.Sprintf(":%d", http), fmt.Sprintf("--admin=%d", admin), "--id=web", "plain"}
+	if !slices.Equal(spec.Args, want) {
+		t.Errorf("Args = %v, want %v", spec.Args, want)
+	}
+	for _, env := range []string{fmt.Sprintf("P2P_PORT_HTTP=%d", http), fmt.Sprintf("P2P_PORT_ADMIN_API=%d", admin)} {
+		if !slices.Contains(spec.Env, env) {
+			t.Errorf("Env does not contain %s", env)
+		}
+	}
+
+	undeclared := newApp(t, "undeclared")
+	undeclared.Manifest.Args = []string{`{{ .Port "http" }}`}
+	if err := rt.Start(ctx, undeclared); !errors.Is(err, types.ErrAppStartFailed) || !errors.Is(err, types.ErrInvalidManifest) {
+		t.Errorf("Start() with an undeclared port error = %v, want ErrAppStartFailed and ErrInvalidManifest", err)
+	}
+}
+
+func TestWaitStarted(t *testing.T) {
+	ctx := context.Background()
+	rt, backend := newRuntime(t)
+
+	// The health check never passes, which WaitStarted does not wait for
+	app := newApp(t, "web")
+	app.Manifest.HealthCheck = &types.HealthCheckConfig{Type: "tcp", Endpoint: "127.0.0.1:1", Interval: time.Hour}
+	if err := rt.Start(ctx, app); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := rt.WaitStarted(waitCtx, "web"); err != nil {
+		t.Errorf("WaitStarted() error = %v, want the running app started", err)
+	}
+
+	if err := rt.Start(ctx, newApp(t, "crash")); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	backend.process("crash").exit()
+	within(t, 2*time.Second, "WaitStarted() of an exited app", func() {
+		if err := rt.WaitStarted(ctx, "crash"); !errors.Is(err, types.ErrAppUnhealthy) {
+			t.Errorf("WaitStarted() error = %v, want ErrAppUnhealthy", err)
+		}
+	})
+}
+
+func TestInitiator(t *testing.T) {
+	var mu sync.Mutex
+	initiators := make(map[types.AppEvent]*types.Initiator)
+	rt, backend := newRuntime(t, runtime.WithEvents(func(app *types.Application, event types.AppEvent, message string) {
+		mu.Lock()
+		defer mu.Unlock()
+		initiators[event] = app.Initiator
+	}))
+	eventInitiator := func(event types.AppEvent) *types.Initiator {
+		mu.Lock()
+		defer mu.Unlock()
+		return initiators[event]
+	}
+
+	deploy := types.Initiator{Kind: types.InitiatorOperator, ID: "alice", Reason: "deploy, request 1"}
+	app := newApp(t, "web")
+	if err := rt.Start(runtime.WithInitiator(context.Background(), deploy), app); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if app.Initiator == nil || *app.Initiator != deploy || *eventInitiator(types.AppEventStarted) != deploy {
+		t.Errorf("started by %v, want %v", app.Initiator, &deploy)
+	}
+
+	stop := types.Initiator{Kind: types.InitiatorOperator, ID: "bob", Reason: "deploy, request 2"}
+	if err := rt.Stop(runtime.WithInitiator(context.Background(), stop), "web"); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	status, err := rt.Status(context.Background(), "web")
+	if err != nil || status.App.Initiator == nil || *status.App.Initiator != stop {
+		t.Fatalf("Status() after Stop() = %+v, %v; want stopped by %v", status, err, &stop)
+	}
+	if got := eventInitiator(types.AppEventStopped); got == nil || *got != stop {
+		t.Errorf("stopped event initiated by %v, want %v", got, &stop)
+	}
+
+	// An exit nobody asked for is initiated by the app itself
+	if err := rt.Start(context.Background(), app); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	backend.process("web").exit()
+	want := types.Initiator{Kind: types.InitiatorApp, Reason: "exit status 0"}
+	within(t, 2*time.Second, "the exited event initiated by the app", func() {
+		for got := eventInitiator(types.AppEventExited); got == nil || *got != want; got = eventInitiator(types.AppEventExited) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
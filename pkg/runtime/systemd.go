@@ -0,0 +1,269 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// systemdBootedPath exists on hosts booted with systemd (see sd_booted(3))
+const systemdBootedPath = "/run/systemd/system"
+
+// systemdPIDTimeout bounds how long to wait for a transient unit to report its main PID
+const systemdPIDTimeout = 5 * time.Second
+
+// SystemdConfig configures the systemd backend
+type SystemdConfig struct {
+	// Slice is the slice units are placed in, e.g. "p2p-playground.slice";
+	// empty for the default slice
+	Slice string
+
+	// User runs units under the per-user service manager of the daemon user
+	// instead of the system manager, for daemons not running as root
+	User bool
+}
+
+// systemdBackend runs app processes as transient systemd services
+type systemdBackend struct {
+	cfg SystemdConfig
+}
+
+// NewSystemdBackend returns the backend running each app process as a
+// transient systemd service started with systemd-run. Systemd supervises the
+// process, accounts its resources in its own cgroup, where the manifest's
+// resource limits are enforced, and collects the unit once it exits.
+// It fails unless the host was booted with systemd and systemd-run is installed.
+func NewSystemdBackend(cfg SystemdConfig) (Backend, error) {
+	if _, err := os.Stat(systemdBootedPath); err != nil {
+		return nil, fmt.Errorf("%w: the systemd backend requires a host booted with systemd", types.ErrUnavailable)
+	}
+	for _, tool := range []string{"systemd-run", "systemctl"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return nil, fmt.Errorf("%w: the systemd backend requires %s in PATH", types.ErrUnavailable, tool)
+		}
+	}
+	return &systemdBackend{cfg: cfg}, nil
+}
+
+// Name implements Backend
+func (b *systemdBackend) Name() string {
+	return "systemd"
+}
+
+// Start implements Backend
+func (b *systemdBackend) Start(ctx context.Context, spec *ProcessSpec) (Process, error) {
+	unit := unitName(spec.App.ID)
+
+	// A unit left behind by a previous daemon, still running or failed, would
+	// keep the name taken; the runtime starts the app afresh
+	_ = b.systemctl("stop", unit).Run()
+	_ = b.systemctl("reset-failed", unit).Run()
+
+	// --wait keeps systemd-run running until the service exits, with its exit status
+	args := b.scope()
+	args = append(args,
+		"--unit="+unit,
+		"--description=p2p-playground app "+spec.App.ID,
+		"--collect",
+		"--wait",
+		"--quiet",
+		"--service-type=exec",
+		"--working-directory="+spec.Dir,
+	)
+	if spec.LogIdentifier != "" {
+		args = append(args,
+			"--property=StandardOutput=journal",
+			"--property=StandardError=journal",
+			"--property=SyslogIdentifier="+spec.LogIdentifier,
+		)
+	} else {
+		args = append(args,
+			"--property=StandardOutput=append:"+spec.StdoutPath,
+			"--property=StandardError=append:"+spec.StderrPath,
+		)
+	}
+	if b.cfg.Slice != "" {
+		args = append(args, "--slice="+b.cfg.Slice)
+	}
+	if spec.Credential != nil {
+		args = append(args, fmt.Sprintf("--uid=%d", spec.Credential.UID), fmt.Sprintf("--gid=%d", spec.Credential.GID))
+		if len(spec.Groups) > 0 {
+			groups := make([]string, len(spec.Groups))
+			for i, gid := range spec.Groups {
+				groups[i] = strconv.FormatUint(uint64(gid), 10)
+			}
+			args = append(args, "--property=SupplementaryGroups="+strings.Join(groups, " "))
+		}
+	}
+	if spec.NetNS != "" {
+		args = append(args, "--property=NetworkNamespacePath="+spec.NetNS)
+	}
+	if spec.CoreDumps {
+		args = append(args, "--property=LimitCORE=infinity")
+	}
+	if res := spec.Resources; res != nil {
+		if res.MemoryMB > 0 {
+			args = append(args, fmt.Sprintf("--property=MemoryMax=%dM", res.MemoryMB))
+		}
+		if res.CPUPercent > 0 {
+			args = append(args, fmt.Sprintf("--property=CPUQuota=%g%%", res.CPUPercent))
+		}
+	}
+	for _, env := range spec.Env {
+		args = append(args, "--setenv="+env)
+	}
+	args = append(args, "--", spec.Path)
+	args = append(args, spec.Args...)
+
+	// systemd-run is not tied to ctx: the service must outlive the request starting it
+	cmd := exec.Command("systemd-run", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, types.WrapError(err, "failed to run systemd-run")
+	}
+
+	p := &systemdProcess{backend: b, unit: unit, cmd: cmd, stderr: &stderr, done: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+
+	// The service runs once systemd reports its main PID
+	pid, ok := p.mainPID(ctx)
+	if !ok {
+		_ = p.Kill()
+		_ = cmd.Process.Kill()
+		<-p.done
+		return nil, fmt.Errorf("%w: unit %s did not start: %s", types.ErrAppStartFailed, unit, strings.TrimSpace(stderr.String()))
+	}
+	p.pid = pid
+	return p, nil
+}
+
+// scope returns the systemd-run/systemctl arguments selecting the service manager
+func (b *systemdBackend) scope() []string {
+	if b.cfg.User {
+		return []string{"--user"}
+	}
+	return nil
+}
+
+// systemctl returns a systemctl command for the configured service manager
+func (b *systemdBackend) systemctl(args ...string) *exec.Cmd {
+	return exec.Command("systemctl", append(b.scope(), args...)...)
+}
+
+// systemdProcess is an app process running as a transient service
+type systemdProcess struct {
+	backend *systemdBackend
+	unit    string
+	pid     int
+	cmd     *exec.Cmd
+	stderr  *bytes.Buffer
+
+	// done is closed once systemd-run, and so the service, has exited with err
+	done chan struct{}
+	err  error
+}
+
+// mainPID waits for the service to report its main PID. It reports 0 if the
+// service exited at once, leaving it to Wait to tell why, and false if it did
+// not start within systemdPIDTimeout.
+func (p *systemdProcess) mainPID(ctx context.Context) (int, bool) {
+	ctx, cancel := context.WithTimeout(ctx, systemdPIDTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		out, err := p.backend.systemctl("show", "--property=MainPID", "--value", p.unit).Output()
+		if err == nil {
+			if pid, _ := strconv.Atoi(strings.TrimSpace(string(out))); pid > 0 {
+				return pid, true
+			}
+		}
+
+		select {
+		case <-p.done:
+			return 0, true
+		case <-ctx.Done():
+			return 0, false
+		case <-ticker.C:
+		}
+	}
+}
+
+// PID implements Process
+func (p *systemdProcess) PID() int {
+	return p.pid
+}
+
+// Terminate implements Process. Every process of the service is signalled.
+func (p *systemdProcess) Terminate() error {
+	return p.kill("SIGTERM")
+}
+
+// Kill implements Process
+func (p *systemdProcess) Kill() error {
+	return p.kill("SIGKILL")
+}
+
+// kill sends signal to the processes of the service
+func (p *systemdProcess) kill(signal string) error {
+	out, err := p.backend.systemctl("kill", "--signal="+signal, p.unit).CombinedOutput()
+	if err != nil {
+		select {
+		case <-p.done:
+			// Nothing left to signal
+			return nil
+		default:
+		}
+		return fmt.Errorf("systemctl kill %s: %s", p.unit, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Pause implements Process. The cgroup freezer suspends every process of the service.
+func (p *systemdProcess) Pause() error {
+	return p.freezer("freeze")
+}
+
+// Resume implements Process
+func (p *systemdProcess) Resume() error {
+	return p.freezer("thaw")
+}
+
+// freezer freezes or thaws the service
+func (p *systemdProcess) freezer(verb string) error {
+	out, err := p.backend.systemctl(verb, p.unit).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s %s: %s", verb, p.unit, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Wait implements Process
+func (p *systemdProcess) Wait() error {
+	<-p.done
+	var exitErr *exec.ExitError
+	if errors.As(p.err, &exitErr) {
+		if msg := strings.TrimSpace(p.stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", p.err, msg)
+		}
+	}
+	return p.err
+}
+
+// unitName returns the name of the transient service running an app
+func unitName(appID string) string {
+	return logIdentifier(appID) + ".service"
+}
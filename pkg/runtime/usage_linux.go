@@ -0,0 +1,72 @@
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// userHZ is the unit of the CPU times in /proc/<pid>/stat, in ticks per second
+const userHZ = 100
+
+// processUsage returns the resident memory of the process pid, read from
+// /proc. CPU usage takes samples over time and is not measured here.
+func processUsage(pid int) (*types.ResourceUsage, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "VmRSS:")
+		if !ok {
+			continue
+		}
+		// e.g. "    1234 kB"
+		kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse VmRSS of process %d: %w", pid, err)
+		}
+		return &types.ResourceUsage{MemoryMB: kb / 1024, Timestamp: time.Now()}, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: no VmRSS for process %d", types.ErrNotFound, pid)
+}
+
+// processCPUTime returns the CPU time the process pid has used, in user and
+// kernel mode, read from /proc
+func processCPUTime(pid int) (time.Duration, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	// The fields follow the command name, in parentheses, which may contain
+	// anything; utime and stime are the 14th and 15th
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return 0, fmt.Errorf("failed to parse stat of process %d", pid)
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("failed to parse stat of process %d", pid)
+	}
+	var ticks int64
+	for _, field := range fields[11:13] {
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse CPU time of process %d: %w", pid, err)
+		}
+		ticks += n
+	}
+	return time.Duration(ticks) * time.Second / userHZ, nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package runtime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// processUsage is not supported on this platform
+func processUsage(pid int) (*types.ResourceUsage, error) {
+	return nil, fmt.Errorf("%w: process resource usage", types.ErrNotImplemented)
+}
+
+// processCPUTime is not supported on this platform
+func processCPUTime(pid int) (time.Duration, error) {
+	return 0, fmt.Errorf("%w: process CPU time", types.ErrNotImplemented)
+}
@@ -0,0 +1,140 @@
+// Package schema generates JSON Schemas of the YAML files users write, such
+// as manifests and configs, from the Go types that read them, so editors can
+// complete and validate them and external tooling stays in sync with the types.
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema version generated
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches the durations time.ParseDuration accepts, e.g. "1m30s"
+const durationPattern = `^-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$|^0$`
+
+// Schema is a JSON Schema
+type Schema struct {
+	Schema string `json:"$schema,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Type   string `json:"type,omitempty"`
+
+	// Properties are the fields of an object
+	Properties map[string]*Schema `json:"properties,omitempty"`
+
+	// Required are the properties an object must have
+	Required []string `json:"required,omitempty"`
+
+	// AdditionalProperties is false for structs, which only have the listed
+	// properties, or the schema of the values of a map
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+
+	// Items is the schema of the elements of an array
+	Items *Schema `json:"items,omitempty"`
+
+	Pattern string `json:"pattern,omitempty"`
+	Minimum *int   `json:"minimum,omitempty"`
+}
+
+// Options controls how a schema is generated
+type Options struct {
+	// Title names the document
+	Title string
+
+	// RequireFields marks struct fields whose yaml tag lacks omitempty as
+	// required, for types such as manifests whose optional fields all have it
+	RequireFields bool
+}
+
+// Generate returns the schema of the YAML documents v is read from. Field
+// names come from the yaml tags; durations are strings such as "30s".
+func Generate(v interface{}, opts Options) *Schema {
+	g := &generator{opts: opts, visiting: make(map[reflect.Type]bool)}
+	s := g.schema(reflect.TypeOf(v))
+	s.Schema = Draft
+	s.Title = opts.Title
+	return s
+}
+
+// generator builds the schema of a type and the types it contains
+type generator struct {
+	opts Options
+
+	// visiting are the struct types being generated, to stop at recursive types
+	visiting map[reflect.Type]bool
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// schema returns the schema of t
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return &Schema{Type: "string", Pattern: durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if g.visiting[t] {
+			return &Schema{Type: "object"}
+		}
+		g.visiting[t] = true
+		defer delete(g.visiting, t)
+
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+		g.fields(t, s)
+		return s
+	default:
+		// Interfaces and the like hold any value
+		return &Schema{}
+	}
+}
+
+// fields adds the fields of struct type t to s, those of inlined structs included
+func (g *generator) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			inner := field.Type
+			if inner.Kind() == reflect.Pointer {
+				inner = inner.Elem()
+			}
+			g.fields(inner, s)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		s.Properties[name] = g.schema(field.Type)
+		if g.opts.RequireFields && !strings.Contains(options, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/config"
+	"github.com/asjdf/p2p-playground-lite/pkg/schema"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+type inner struct {
+	Port int `yaml:"port"`
+}
+
+type document struct {
+	Name     string            `yaml:"name"`
+	Timeout  time.Duration     `yaml:"timeout,omitempty"`
+	Tags     []string          `yaml:"tags,omitempty"`
+	Env      map[string]string `yaml:"env,omitempty"`
+	Inner    *inner            `yaml:"inner,omitempty"`
+	Embedded inner             `yaml:",inline"`
+	Skipped  string            `yaml:"-"`
+	Untagged bool
+}
+
+func TestGenerate(t *testing.T) {
+	s := schema.Generate(&document{}, schema.Options{Title: "doc", RequireFields: true})
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["$schema"] != schema.Draft || got["title"] != "doc" || got["additionalProperties"] != false {
+		t.Errorf("Generate() = %s", data)
+	}
+
+	props := got["properties"].(map[string]interface{})
+	want := map[string]string{
+		"name":     "string",
+		"timeout":  "string",
+		"tags":     "array",
+		"env":      "object",
+		"inner":    "object",
+		"port":     "integer",
+		"untagged": "boolean",
+	}
+	if len(props) != len(want) {
+		t.Errorf("properties = %v, want %v", props, want)
+	}
+	for name, typ := range want {
+		prop, ok := props[name].(map[string]interface{})
+		if !ok || prop["type"] != typ {
+			t.Errorf("property %s = %v, want type %s", name, props[name], typ)
+		}
+	}
+
+	required, _ := json.Marshal(got["required"])
+	if string(required) != `["name","port","untagged"]` {
+		t.Errorf("required = %s, want the fields without omitempty", required)
+	}
+}
+
+// checkKeys reports the keys of node that s has no property for
+func checkKeys(t *testing.T, s *schema.Schema, node interface{}, path string) {
+	t.Helper()
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, value := range m {
+		prop, ok := s.Properties[key]
+		if s.Properties == nil {
+			prop, ok = nil, true
+			if values, isSchema := s.AdditionalProperties.(*schema.Schema); isSchema {
+				prop = values
+			}
+		}
+		if !ok {
+			t.Errorf("%s%s is not in the schema", path, key)
+			continue
+		}
+		if prop != nil {
+			checkKeys(t, prop, value, path+key+".")
+		}
+	}
+}
+
+// The example configs only use keys the schemas know
+func TestExampleConfigsMatchSchema(t *testing.T) {
+	tests := []struct {
+		file string
+		v    interface{}
+	}{
+		{"daemon.example.yaml", &config.DaemonConfig{}},
+		{"controller.example.yaml", &config.ControllerConfig{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("..", "..", "configs", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc map[string]interface{}
+			if err := yaml.Unmarshal(data, &doc); err != nil {
+				t.Fatal(err)
+			}
+			checkKeys(t, schema.Generate(tt.v, schema.Options{}), doc, "")
+		})
+	}
+}
+
+// The example apps only use manifest fields the schema knows
+func TestExampleManifestsMatchSchema(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "examples", "*", "manifest.yaml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example manifests found: %v", err)
+	}
+	s := schema.Generate(&types.Manifest{}, schema.Options{RequireFields: true})
+	for _, file := range files {
+		t.Run(filepath.Base(filepath.Dir(file)), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc map[string]interface{}
+			if err := yaml.Unmarshal(data, &doc); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range s.Required {
+				if _, ok := doc[name]; !ok {
+					t.Errorf("required field %s is missing", name)
+				}
+			}
+			checkKeys(t, s, doc, "")
+		})
+	}
+}
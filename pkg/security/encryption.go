@@ -0,0 +1,272 @@
+package security
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+const (
+	// DataKeySize is the size of the at-rest encryption key in bytes (AES-256)
+	DataKeySize = 32
+
+	// DataKeyFile is the file name of the per-node data key in KeysDir
+	DataKeyFile = "data.key"
+
+	// EncryptedSuffix is appended to the names of files encrypted at rest
+	EncryptedSuffix = ".enc"
+
+	// encryptedMagic identifies the encrypted file format
+	encryptedMagic = "P2PENC1\n"
+
+	// encryptedChunkSize is the plaintext size of each encrypted chunk
+	encryptedChunkSize = 64 * 1024
+
+	// dataKeyInfo separates the derived data key from other uses of the PSK
+	dataKeyInfo = "p2p-playground at-rest encryption"
+)
+
+// LoadOrGenerateDataKey loads the per-node data key from dir, generating it on first use
+func LoadOrGenerateDataKey(dir string) ([]byte, error) {
+	keyPath := filepath.Join(dir, DataKeyFile)
+
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != DataKeySize {
+			return nil, fmt.Errorf("invalid data key in %s", keyPath)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, types.WrapError(err, "failed to read data key")
+	}
+
+	key := make([]byte, DataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, types.WrapError(err, "failed to generate data key")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, types.WrapError(err, "failed to create keys directory")
+	}
+	if err := os.WriteFile(keyPath, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return nil, types.WrapError(err, "failed to write data key")
+	}
+
+	return key, nil
+}
+
+// DeriveDataKey derives the data key from a hex-encoded PSK.
+// All nodes sharing the PSK derive the same key.
+func DeriveDataKey(encodedPSK string) ([]byte, error) {
+	psk, err := DecodePSK(encodedPSK)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hkdf.Key(sha256.New, psk, nil, dataKeyInfo, DataKeySize)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to derive data key")
+	}
+	return key, nil
+}
+
+// EncryptFile encrypts src into dst with AES-256-GCM.
+// The file is split into chunks that are authenticated with their index and a
+// final-chunk flag, so reordered or truncated files fail to decrypt.
+func EncryptFile(src, dst string, key []byte) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return types.WrapError(err, "failed to open file")
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return types.WrapError(err, "failed to create encrypted file")
+	}
+	defer func() { _ = out.Close() }()
+
+	noncePrefix := make([]byte, aead.NonceSize()-8)
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return types.WrapError(err, "failed to generate nonce")
+	}
+
+	if _, err := out.Write([]byte(encryptedMagic)); err != nil {
+		return fmt.Errorf("%w: %w", types.ErrStorageWrite, err)
+	}
+	if _, err := out.Write(noncePrefix); err != nil {
+		return fmt.Errorf("%w: %w", types.ErrStorageWrite, err)
+	}
+
+	buf := make([]byte, encryptedChunkSize)
+	next := make([]byte, encryptedChunkSize)
+	n, err := io.ReadFull(in, buf)
+	for index := uint64(0); ; index++ {
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return types.WrapError(err, "failed to read file")
+		}
+
+		// Read ahead to learn whether this is the final chunk
+		var nextN int
+		var nextErr error
+		if err == nil {
+			nextN, nextErr = io.ReadFull(in, next)
+		}
+		final := err != nil || (nextN == 0 && errors.Is(nextErr, io.EOF))
+
+		sealed := aead.Seal(nil, chunkNonce(noncePrefix, index), buf[:n], chunkAAD(index, final))
+		if err := binary.Write(out, binary.BigEndian, uint32(len(sealed))); err != nil {
+			return fmt.Errorf("%w: %w", types.ErrStorageWrite, err)
+		}
+		if _, err := out.Write(sealed); err != nil {
+			return fmt.Errorf("%w: %w", types.ErrStorageWrite, err)
+		}
+
+		if final {
+			break
+		}
+		buf, next = next, buf
+		n, err = nextN, nextErr
+	}
+
+	if err := out.Sync(); err != nil {
+		return fmt.Errorf("%w: %w", types.ErrStorageWrite, err)
+	}
+	return nil
+}
+
+// DecryptFile decrypts a file written by EncryptFile into dst
+func DecryptFile(src, dst string, key []byte, perm os.FileMode) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return types.WrapError(err, "failed to open encrypted file")
+	}
+	defer func() { _ = in.Close() }()
+
+	header := make([]byte, len(encryptedMagic)+aead.NonceSize()-8)
+	if _, err := io.ReadFull(in, header); err != nil || !bytes.HasPrefix(header, []byte(encryptedMagic)) {
+		return fmt.Errorf("%w: %s is not an encrypted file", types.ErrInvalidPackage, src)
+	}
+	noncePrefix := header[len(encryptedMagic):]
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return types.WrapError(err, "failed to create decrypted file")
+	}
+	defer func() { _ = out.Close() }()
+
+	maxSealed := uint32(encryptedChunkSize + aead.Overhead())
+	for index := uint64(0); ; index++ {
+		var size uint32
+		if err := binary.Read(in, binary.BigEndian, &size); err != nil {
+			_ = os.Remove(dst)
+			return fmt.Errorf("%w: %s is truncated", types.ErrInvalidChecksum, src)
+		}
+		if size > maxSealed {
+			_ = os.Remove(dst)
+			return fmt.Errorf("%w: %s has an invalid chunk", types.ErrInvalidChecksum, src)
+		}
+
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(in, sealed); err != nil {
+			_ = os.Remove(dst)
+			return fmt.Errorf("%w: %s is truncated", types.ErrInvalidChecksum, src)
+		}
+
+		// A chunk opens with exactly one of the two flags; the final one ends the file
+		final := false
+		plain, err := aead.Open(nil, chunkNonce(noncePrefix, index), sealed, chunkAAD(index, false))
+		if err != nil {
+			plain, err = aead.Open(nil, chunkNonce(noncePrefix, index), sealed, chunkAAD(index, true))
+			final = true
+		}
+		if err != nil {
+			_ = os.Remove(dst)
+			return fmt.Errorf("%w: failed to decrypt %s (wrong key or modified file)", types.ErrInvalidChecksum, src)
+		}
+
+		if _, err := out.Write(plain); err != nil {
+			return fmt.Errorf("%w: %w", types.ErrStorageWrite, err)
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// EncryptInPlace encrypts path to path+EncryptedSuffix and removes the plaintext.
+// The encrypted file keeps the permissions of the original.
+func EncryptInPlace(path string, key []byte) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", types.WrapError(err, "failed to stat file")
+	}
+
+	encPath := path + EncryptedSuffix
+	if err := EncryptFile(path, encPath, key); err != nil {
+		_ = os.Remove(encPath)
+		return "", err
+	}
+	if err := os.Chmod(encPath, info.Mode().Perm()); err != nil {
+		return "", types.WrapError(err, "failed to set file permissions")
+	}
+	if err := os.Remove(path); err != nil {
+		return "", types.WrapError(err, "failed to remove plaintext file")
+	}
+	return encPath, nil
+}
+
+// newAEAD creates the AES-256-GCM cipher for key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != DataKeySize {
+		return nil, fmt.Errorf("%w: data key must be %d bytes", types.ErrInvalidInput, DataKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to create cipher")
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce builds the nonce for a chunk from the file's random prefix and the chunk index
+func chunkNonce(prefix []byte, index uint64) []byte {
+	nonce := make([]byte, len(prefix)+8)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[len(prefix):], index)
+	return nonce
+}
+
+// chunkAAD binds a chunk to its position and whether it ends the file
+func chunkAAD(index uint64, final bool) []byte {
+	aad := make([]byte, 9)
+	binary.BigEndian.PutUint64(aad, index)
+	if final {
+		aad[8] = 1
+	}
+	return aad
+}
@@ -0,0 +1,115 @@
+package security_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	key, err := security.LoadOrGenerateDataKey(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	sizes := []int{0, 1, 64 * 1024, 64*1024 + 1, 200 * 1024}
+	for _, size := range sizes {
+		dir := t.TempDir()
+		plain := make([]byte, size)
+		_, _ = rand.Read(plain)
+
+		src := filepath.Join(dir, "app.tar.gz")
+		if err := os.WriteFile(src, plain, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		encPath, err := security.EncryptInPlace(src, key)
+		if err != nil {
+			t.Fatalf("size %d: encrypt failed: %v", size, err)
+		}
+		if _, err := os.Stat(src); !os.IsNotExist(err) {
+			t.Errorf("size %d: plaintext was not removed", size)
+		}
+
+		dst := filepath.Join(dir, "decrypted")
+		if err := security.DecryptFile(encPath, dst, key, 0600); err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		got, _ := os.ReadFile(dst)
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: decrypted content differs", size)
+		}
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := security.LoadOrGenerateDataKey(dir)
+	otherKey, _ := security.DeriveDataKey("0000000000000000000000000000000000000000000000000000000000000001")
+
+	plain := make([]byte, 150*1024)
+	src := filepath.Join(dir, "file")
+	_ = os.WriteFile(src, plain, 0644)
+	encPath, err := security.EncryptInPlace(src, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := os.ReadFile(encPath)
+
+	tests := []struct {
+		name string
+		data []byte
+		key  []byte
+	}{
+		{"wrong key", enc, otherKey},
+		{"flipped byte", flipByte(enc, len(enc)/2), key},
+		{"truncated", enc[:len(enc)-100], key},
+		{"last chunk dropped", enc[:len(enc)-(150*1024-128*1024)-16-4], key},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.enc")
+			_ = os.WriteFile(path, tt.data, 0600)
+
+			err := security.DecryptFile(path, filepath.Join(t.TempDir(), "out"), tt.key, 0600)
+			if !errors.Is(err, types.ErrInvalidChecksum) {
+				t.Errorf("expected ErrInvalidChecksum, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestDataKeyIsStable(t *testing.T) {
+	dir := t.TempDir()
+	first, err := security.LoadOrGenerateDataKey(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := security.LoadOrGenerateDataKey(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("data key changed between loads")
+	}
+
+	psk := "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"
+	a, _ := security.DeriveDataKey(psk)
+	b, _ := security.DeriveDataKey(psk)
+	if len(a) != security.DataKeySize || !bytes.Equal(a, b) {
+		t.Error("PSK-derived key is not deterministic")
+	}
+}
+
+func flipByte(data []byte, i int) []byte {
+	out := append([]byte(nil), data...)
+	out[i] ^= 0xff
+	return out
+}
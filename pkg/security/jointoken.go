@@ -0,0 +1,110 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// JoinTokenPrefix marks a join token and its format version
+const JoinTokenPrefix = "p2pjoin1."
+
+// JoinToken is what a fresh node needs to join a cluster
+type JoinToken struct {
+	// Cluster is the name of the cluster
+	Cluster string `json:"cluster"`
+
+	// BootstrapPeers are the multiaddrs the node connects to first
+	BootstrapPeers []string `json:"bootstrap_peers"`
+
+	// PSKFingerprint identifies the cluster PSK without revealing it (empty if the cluster has no PSK)
+	PSKFingerprint string `json:"psk_fingerprint,omitempty"`
+
+	// IssuerKey is the Ed25519 public key that signed the token; nodes joining
+	// with it trust packages signed by the same key
+	IssuerKey []byte `json:"issuer_key"`
+
+	// ExpiresAt is when the token stops being accepted
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// checkClusterName checks that name can name the issuer key of the cluster,
+// which joining nodes install in their trusted keys directory
+func checkClusterName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%w: invalid cluster name %q", types.ErrInvalidInput, name)
+	}
+	return nil
+}
+
+// CreateJoinToken signs token with signer, which becomes its issuer
+func CreateJoinToken(signer *Signer, token *JoinToken) (string, error) {
+	if err := checkClusterName(token.Cluster); err != nil {
+		return "", err
+	}
+	token.IssuerKey = signer.PublicKey()
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal join token: %w", err)
+	}
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return "", types.WrapError(err, "failed to sign join token")
+	}
+	return JoinTokenPrefix + base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ParseJoinToken decodes a join token, checking that it is intact and not expired at now.
+// The token is only as trustworthy as the channel it was received through.
+func ParseJoinToken(s string, now time.Time) (*JoinToken, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(s), JoinTokenPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: not a join token", types.ErrInvalidInput)
+	}
+	encodedPayload, encodedSignature, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed join token", types.ErrInvalidInput)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed join token: %w", types.ErrInvalidInput, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed join token: %w", types.ErrInvalidInput, err)
+	}
+
+	var token JoinToken
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return nil, fmt.Errorf("%w: malformed join token: %w", types.ErrInvalidInput, err)
+	}
+	if len(token.IssuerKey) != ed25519.PublicKeySize || !ed25519.Verify(token.IssuerKey, payload, signature) {
+		return nil, fmt.Errorf("%w: join token signature does not match its issuer", types.ErrInvalidSignature)
+	}
+	if now.After(token.ExpiresAt) {
+		return nil, fmt.Errorf("%w: join token expired at %s", types.ErrUnauthorized, token.ExpiresAt.Format(time.RFC3339))
+	}
+	// Anyone can sign a token, so its content is checked like any input
+	if err := checkClusterName(token.Cluster); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// PSKFingerprint returns a short fingerprint of a hex-encoded PSK
+func PSKFingerprint(encodedPSK string) (string, error) {
+	psk, err := DecodePSK(encodedPSK)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(psk)
+	return hex.EncodeToString(sum[:8]), nil
+}
@@ -0,0 +1,125 @@
+package security_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/security"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestJoinTokenRoundTrip(t *testing.T) {
+	signer, err := security.NewSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	encoded, err := security.CreateJoinToken(signer, &security.JoinToken{
+		Cluster:        "lab",
+		BootstrapPeers: []string{"/ip4/192.0.2.1/tcp/9000/p2p/12D3KooWExample"},
+		PSKFingerprint: "0011223344556677",
+		ExpiresAt:      now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateJoinToken() error = %v", err)
+	}
+
+	token, err := security.ParseJoinToken(encoded, now)
+	if err != nil {
+		t.Fatalf("ParseJoinToken() error = %v", err)
+	}
+	if token.Cluster != "lab" || len(token.BootstrapPeers) != 1 || token.PSKFingerprint != "0011223344556677" {
+		t.Errorf("ParseJoinToken() = %+v", token)
+	}
+	if string(token.IssuerKey) != string(signer.PublicKey()) {
+		t.Error("issuer key does not match the signer")
+	}
+}
+
+func TestParseJoinTokenRejects(t *testing.T) {
+	signer, err := security.NewSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	valid, err := security.CreateJoinToken(signer, &security.JoinToken{Cluster: "lab", ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := security.CreateJoinToken(signer, &security.JoinToken{Cluster: "lab", ExpiresAt: now.Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token signed with any key is intact, so its cluster name is checked too
+	payload, err := json.Marshal(&security.JoinToken{Cluster: "../../etc/x", IssuerKey: signer.PublicKey(), ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	escaping := security.JoinTokenPrefix + base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	// Flip a character of the payload
+	encodedPayload, encodedSignature, _ := strings.Cut(strings.TrimPrefix(valid, security.JoinTokenPrefix), ".")
+	tampered := security.JoinTokenPrefix + encodedPayload[:10] + string(encodedPayload[10]^1) + encodedPayload[11:] + "." + encodedSignature
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"not a token", "hello", types.ErrInvalidInput},
+		{"missing signature", security.JoinTokenPrefix + encodedPayload, types.ErrInvalidInput},
+		{"tampered", tampered, types.ErrInvalidInput},
+		{"expired", expired, types.ErrUnauthorized},
+		{"cluster escaping the keys directory", escaping, types.ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := security.ParseJoinToken(tt.token, now); !errors.Is(err, tt.want) && !errors.Is(err, types.ErrInvalidSignature) {
+				t.Errorf("ParseJoinToken() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPSKFingerprint(t *testing.T) {
+	psk, err := security.GeneratePSK()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := security.PSKFingerprint(security.EncodePSK(psk))
+	if err != nil {
+		t.Fatalf("PSKFingerprint() error = %v", err)
+	}
+	b, _ := security.PSKFingerprint(security.EncodePSK(psk))
+	if a != b || len(a) != 16 {
+		t.Errorf("PSKFingerprint() = %q, %q; want equal 16-char fingerprints", a, b)
+	}
+	if _, err := security.PSKFingerprint("not-hex"); err == nil {
+		t.Error("PSKFingerprint() accepted an invalid PSK")
+	}
+}
+
+func TestCreateJoinTokenRejectsClusterName(t *testing.T) {
+	signer, err := security.NewSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cluster := range []string{"", ".", "..", "../x", "a/b", `a\b`, ".hidden"} {
+		_, err := security.CreateJoinToken(signer, &security.JoinToken{Cluster: cluster, ExpiresAt: time.Now().Add(time.Hour)})
+		if !errors.Is(err, types.ErrInvalidInput) {
+			t.Errorf("CreateJoinToken() with cluster %q error = %v, want ErrInvalidInput", cluster, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// SchemaVersionField is the field of persisted documents holding the version
+// of the schema they were written with
+const SchemaVersionField = "schema_version"
+
+// Migration upgrades a document from one schema version to the next
+type Migration func(data []byte) ([]byte, error)
+
+// Schema describes the versions of a kind of document the daemon persists.
+// Documents are JSON objects carrying their version in SchemaVersionField.
+// Those written before the field was added have version 0.
+type Schema struct {
+	// Kind names the documents in errors, e.g. "revision history"
+	Kind string
+
+	// Migrations upgrade documents one version at a time: Migrations[i] turns
+	// a document of version i into one of version i+1, and is nil if only the
+	// version changed. The current version is len(Migrations).
+	Migrations []Migration
+}
+
+// Version returns the current schema version
+func (s *Schema) Version() int {
+	return len(s.Migrations)
+}
+
+// Marshal encodes v, which must encode as a JSON object, with the current
+// schema version
+func (s *Schema) Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 || data[0] != '{' {
+		return nil, fmt.Errorf("%w: %s is not a JSON object", types.ErrInvalidInput, s.Kind)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `{"%s":%d`, SchemaVersionField, s.Version())
+	if len(data) > 2 {
+		b.WriteByte(',')
+	}
+	b.Write(data[1:])
+	return b.Bytes(), nil
+}
+
+// MarshalIndent is like Marshal but indents the output as json.MarshalIndent
+func (s *Schema) MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	data, err := s.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, data, prefix, indent); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Unmarshal decodes data into v, migrating it from the schema version it was
+// written with. A document written by a newer build is refused with an error
+// wrapping types.ErrUnsupportedSchema, rather than read in part and then
+// overwritten without the fields this build does not know.
+func (s *Schema) Unmarshal(data []byte, v interface{}) error {
+	version, err := DocumentVersion(data)
+	if err != nil {
+		return err
+	}
+	if version > s.Version() {
+		return fmt.Errorf("%w: %s has schema version %d, this daemon reads up to version %d; run a newer daemon",
+			types.ErrUnsupportedSchema, s.Kind, version, s.Version())
+	}
+	for ; version < s.Version(); version++ {
+		migrate := s.Migrations[version]
+		if migrate == nil {
+			continue
+		}
+		if data, err = migrate(data); err != nil {
+			return fmt.Errorf("failed to migrate %s from schema version %d: %w", s.Kind, version, err)
+		}
+	}
+	return json.Unmarshal(data, v)
+}
+
+// DocumentVersion returns the schema version data was written with, 0 for
+// documents without one, such as those written before versioning or that are
+// not JSON objects. It fails if data is not JSON.
+func DocumentVersion(data []byte) (int, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		if !json.Valid(data) {
+			return 0, fmt.Errorf("%w: invalid JSON document", types.ErrInvalidInput)
+		}
+		return 0, nil
+	}
+
+	var doc struct {
+		Version json.RawMessage `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return 0, err
+	}
+	if doc.Version == nil {
+		return 0, nil
+	}
+	// Maps written before versioning may have a key of that name, which is
+	// not a version unless it holds one
+	version, err := strconv.Atoi(string(doc.Version))
+	if err != nil || version < 0 {
+		return 0, nil
+	}
+	return version, nil
+}
+
+// NestUnder returns a migration that moves a whole document into field of a
+// new object, for documents that were maps or arrays before they had to carry
+// a schema version
+func NestUnder(field string) Migration {
+	return func(data []byte) ([]byte, error) {
+		name, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		b.WriteByte('{')
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(data)
+		b.WriteByte('}')
+		return b.Bytes(), nil
+	}
+}
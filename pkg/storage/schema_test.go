@@ -0,0 +1,110 @@
+package storage_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+type document struct {
+	Apps  map[string]int `json:"apps"`
+	Owner string         `json:"owner,omitempty"`
+}
+
+// schema is at version 2: the apps moved under "apps", then "user" was renamed "owner"
+var schema = storage.Schema{
+	Kind: "test document",
+	Migrations: []storage.Migration{
+		storage.NestUnder("apps"),
+		func(data []byte) ([]byte, error) {
+			return bytes.Replace(data, []byte(`"user":`), []byte(`"owner":`), 1), nil
+		},
+	},
+}
+
+func TestSchemaRoundTrip(t *testing.T) {
+	want := document{Apps: map[string]int{"web": 1}, Owner: "alice"}
+	data, err := schema.Marshal(&want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := storage.DocumentVersion(data); err != nil || version != 2 {
+		t.Fatalf("DocumentVersion(%s) = %d, %v; want 2", data, version, err)
+	}
+
+	var got document
+	if err := schema.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+
+	if data, err := schema.Marshal(&struct{}{}); err != nil || string(data) != `{"schema_version":2}` {
+		t.Errorf("Marshal(empty) = %s, %v", data, err)
+	}
+	if _, err := schema.Marshal([]int{1}); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("Marshal(array) error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestSchemaMigrates(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want document
+	}{
+		{"unversioned", `{"web":1,"api":2}`, document{Apps: map[string]int{"web": 1, "api": 2}}},
+		{"version 1", `{"schema_version":1,"apps":{"web":1},"user":"alice"}`, document{Apps: map[string]int{"web": 1}, Owner: "alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got document
+			if err := schema.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocumentVersion(t *testing.T) {
+	tests := []struct {
+		data string
+		want int
+	}{
+		{`{"schema_version":4}`, 4},
+		{`{"web":{"uid":1}}`, 0},
+		{`{"schema_version":{"value":"x"}}`, 0},
+		{`[1,2]`, 0},
+	}
+	for _, tt := range tests {
+		if got, err := storage.DocumentVersion([]byte(tt.data)); err != nil || got != tt.want {
+			t.Errorf("DocumentVersion(%s) = %d, %v; want %d", tt.data, got, err, tt.want)
+		}
+	}
+	if _, err := storage.DocumentVersion([]byte(`[1,`)); err == nil {
+		t.Error("DocumentVersion(truncated) succeeded")
+	}
+}
+
+func TestSchemaRefusesNewer(t *testing.T) {
+	var got document
+	err := schema.Unmarshal([]byte(`{"schema_version":3,"apps":{"web":1}}`), &got)
+	if !errors.Is(err, types.ErrUnsupportedSchema) {
+		t.Fatalf("Unmarshal() error = %v, want ErrUnsupportedSchema", err)
+	}
+	if got.Apps != nil {
+		t.Errorf("Unmarshal() decoded %+v from a newer document", got)
+	}
+
+	if err := schema.Unmarshal([]byte(`{"apps":`), &got); err == nil || errors.Is(err, types.ErrUnsupportedSchema) {
+		t.Errorf("Unmarshal(truncated) error = %v, want a parse error", err)
+	}
+}
@@ -0,0 +1,185 @@
+package topics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appapi"
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// TopicPrefix keeps app topics apart from the topics daemons use themselves
+const TopicPrefix = consts.AppTopicPrefix
+
+// MaxTopicLength is the longest topic name apps may use
+const MaxTopicLength = 128
+
+// MaxDataSize is the largest payload apps may publish
+const MaxDataSize = 256 * 1024
+
+// payload is what is published on the gossipsub topic
+type payload struct {
+	FromApp string `json:"from_app"`
+	Data    []byte `json:"data"`
+}
+
+// Bridge lets apps publish and subscribe to gossipsub topics through the
+// daemon, limited to the topics their manifest allows
+type Bridge struct {
+	ps     *pubsub.PubSub
+	logger types.Logger
+	mu     sync.Mutex
+	topics map[string]*pubsub.Topic
+}
+
+// New creates a bridge on ps
+func New(ps *pubsub.PubSub, logger types.Logger) *Bridge {
+	return &Bridge{
+		ps:     ps,
+		logger: logger,
+		topics: make(map[string]*pubsub.Topic),
+	}
+}
+
+// Register serves the topic methods of the app API
+func (b *Bridge) Register(api *appapi.Server) {
+	api.Handle(appsdk.MethodPublish, b.handlePublish)
+	api.Handle(appsdk.MethodSubscribe, b.handleSubscribe)
+}
+
+// Allowed reports whether topic matches one of patterns
+func Allowed(patterns []string, topic string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, topic); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePublish publishes on behalf of the calling app
+func (b *Bridge) handlePublish(ctx context.Context, call *appapi.Call) error {
+	var params appsdk.TopicParams
+	if err := call.Decode(&params); err != nil {
+		return err
+	}
+	if err := validateTopic(params.Topic); err != nil {
+		return err
+	}
+	if len(params.Data) > MaxDataSize {
+		return fmt.Errorf("%w: message exceeds %d bytes", types.ErrInvalidInput, MaxDataSize)
+	}
+	if acl := topicACL(call.App); acl == nil || !Allowed(acl.Publish, params.Topic) {
+		return fmt.Errorf("%w: app %q may not publish to topic %q (manifest topics.publish)", types.ErrUnauthorized, call.App.Name, params.Topic)
+	}
+
+	topic, err := b.join(params.Topic)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&payload{FromApp: call.App.Name, Data: params.Data})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	if err := topic.Publish(ctx, data); err != nil {
+		return types.WrapError(err, "failed to publish")
+	}
+	return call.Reply(nil)
+}
+
+// handleSubscribe streams the messages of a topic to the calling app until it hangs up
+func (b *Bridge) handleSubscribe(ctx context.Context, call *appapi.Call) error {
+	var params appsdk.TopicParams
+	if err := call.Decode(&params); err != nil {
+		return err
+	}
+	if err := validateTopic(params.Topic); err != nil {
+		return err
+	}
+	if acl := topicACL(call.App); acl == nil || !Allowed(acl.Subscribe, params.Topic) {
+		return fmt.Errorf("%w: app %q may not subscribe to topic %q (manifest topics.subscribe)", types.ErrUnauthorized, call.App.Name, params.Topic)
+	}
+
+	topic, err := b.join(params.Topic)
+	if err != nil {
+		return err
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		return types.WrapError(err, "failed to subscribe")
+	}
+	defer sub.Cancel()
+
+	if err := call.Reply(nil); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return nil
+		}
+
+		var p payload
+		if err := json.Unmarshal(msg.Data, &p); err != nil {
+			b.logger.Debug("invalid topic message", "topic", params.Topic, "from", msg.GetFrom(), "error", err)
+			continue
+		}
+
+		if err := call.Reply(&appsdk.TopicMessage{
+			Topic:    params.Topic,
+			FromNode: msg.GetFrom().String(),
+			FromApp:  p.FromApp,
+			Data:     p.Data,
+		}); err != nil {
+			b.logger.Debug("failed to deliver topic message", "app_id", call.App.ID, "error", err)
+			return nil
+		}
+	}
+}
+
+// join returns the gossipsub topic of an app topic, joining it on first use.
+// Topics stay joined, since pubsub allows a single handle per topic.
+func (b *Bridge) join(name string) (*pubsub.Topic, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if topic := b.topics[name]; topic != nil {
+		return topic, nil
+	}
+	topic, err := b.ps.Join(TopicPrefix + name)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to join topic")
+	}
+	b.topics[name] = topic
+	return topic, nil
+}
+
+// topicACL returns the topic ACL of app's manifest, or nil if it has none
+func topicACL(app *types.Application) *types.TopicACL {
+	if app.Manifest == nil {
+		return nil
+	}
+	return app.Manifest.Topics
+}
+
+// validateTopic rejects empty, oversized and malformed topic names
+func validateTopic(topic string) error {
+	if topic == "" || len(topic) > MaxTopicLength {
+		return fmt.Errorf("%w: topic must be 1 to %d characters", types.ErrInvalidInput, MaxTopicLength)
+	}
+	for _, c := range topic {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '/':
+		default:
+			return fmt.Errorf("%w: topic %q may only contain letters, digits and -_./", types.ErrInvalidInput, topic)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package topics_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/appapi"
+	"github.com/asjdf/p2p-playground-lite/pkg/appsdk"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/topics"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+func newServer(t *testing.T) *appapi.Server {
+	t.Helper()
+
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+
+	ps, err := pubsub.NewGossipSub(context.Background(), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := appapi.New(appapi.Node{ID: h.ID().String()}, logging.Nop())
+	topics.New(ps, logging.Nop()).Register(api)
+	return api
+}
+
+// open starts serving an app with the given topic ACL and returns its client
+func open(t *testing.T, api *appapi.Server, name string, acl *types.TopicACL) *appsdk.Client {
+	t.Helper()
+
+	app := types.NewApplication(types.AppSpec{
+		ID:       name + "-1.0.0",
+		Name:     name,
+		Version:  "1.0.0",
+		WorkDir:  t.TempDir(),
+		Manifest: &types.Manifest{Name: name, Topics: acl},
+	})
+	env, err := api.Open(context.Background(), app)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { api.Close(app) })
+
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, appsdk.EnvSocket+"="); ok {
+			return appsdk.NewClient(value)
+		}
+	}
+	t.Fatalf("Open() env %v does not name the socket", env)
+	return nil
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	api := newServer(t)
+	sensor := open(t, api, "sensor", &types.TopicACL{Publish: []string{"sensors/*"}})
+	display := open(t, api, "display", &types.TopicACL{Subscribe: []string{"sensors/*"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	messages, err := display.Subscribe(ctx, "sensors/temperature")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := sensor.Publish(ctx, "sensors/temperature", []byte("21.5")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	select {
+	case msg := <-messages:
+		if msg.Topic != "sensors/temperature" || msg.FromApp != "sensor" || string(msg.Data) != "21.5" {
+			t.Errorf("message = %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("no message received")
+	}
+}
+
+func TestACL(t *testing.T) {
+	api := newServer(t)
+	app := open(t, api, "app", &types.TopicACL{Publish: []string{"events"}, Subscribe: []string{"events"}})
+	ctx := context.Background()
+
+	if err := app.Publish(ctx, "other", []byte("x")); !errors.Is(err, types.ErrUnauthorized) {
+		t.Errorf("Publish(other) error = %v, want ErrUnauthorized", err)
+	}
+	if _, err := app.Subscribe(ctx, "other"); !errors.Is(err, types.ErrUnauthorized) {
+		t.Errorf("Subscribe(other) error = %v, want ErrUnauthorized", err)
+	}
+	if err := app.Publish(ctx, "bad topic", []byte("x")); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("Publish(bad topic) error = %v, want ErrInvalidInput", err)
+	}
+
+	none := open(t, api, "none", nil)
+	if err := none.Publish(ctx, "events", []byte("x")); !errors.Is(err, types.ErrUnauthorized) {
+		t.Errorf("Publish() without ACL error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		topic    string
+		want     bool
+	}{
+		{[]string{"sensors/*"}, "sensors/temp", true},
+		{[]string{"sensors/*"}, "sensors/a/b", false},
+		{[]string{"events"}, "events", true},
+		{nil, "events", false},
+	}
+
+	for _, tt := range tests {
+		if got := topics.Allowed(tt.patterns, tt.topic); got != tt.want {
+			t.Errorf("Allowed(%v, %q) = %v, want %v", tt.patterns, tt.topic, got, tt.want)
+		}
+	}
+}
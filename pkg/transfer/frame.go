@@ -0,0 +1,130 @@
+package transfer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// Framed transfers split the data into frames of a 4-byte length, the CRC32
+// (IEEE) of the data, and the data itself, big endian. A corrupted chunk is
+// detected as soon as it arrives instead of by the checksum of the whole file.
+
+// MaxFrameSize is the largest frame data a FrameReader accepts
+const MaxFrameSize = 1 << 20
+
+// frameHeaderSize is the size of the length and CRC ahead of each frame
+const frameHeaderSize = 8
+
+// ChunkError reports a frame whose data does not match its CRC. It wraps
+// types.ErrInvalidChecksum.
+type ChunkError struct {
+	Offset int64 // Offset of the frame in the transferred data
+	Length int   // Length of the frame data
+}
+
+// Error implements the error interface
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("%v: chunk of bytes %d-%d corrupted in transit", types.ErrInvalidChecksum, e.Offset, e.Offset+int64(e.Length)-1)
+}
+
+// Unwrap returns types.ErrInvalidChecksum
+func (e *ChunkError) Unwrap() error {
+	return types.ErrInvalidChecksum
+}
+
+// FrameWriter writes data in CRC frames, one frame per Write call, or more
+// if it exceeds MaxFrameSize
+type FrameWriter struct {
+	w      io.Writer
+	header [frameHeaderSize]byte
+}
+
+// NewFrameWriter creates a FrameWriter writing to w
+func NewFrameWriter(w io.Writer) *FrameWriter {
+	return &FrameWriter{w: w}
+}
+
+// Write implements io.Writer
+func (fw *FrameWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > MaxFrameSize {
+			chunk = chunk[:MaxFrameSize]
+		}
+		binary.BigEndian.PutUint32(fw.header[:4], uint32(len(chunk)))
+		binary.BigEndian.PutUint32(fw.header[4:], crc32.ChecksumIEEE(chunk))
+		if _, err := fw.w.Write(fw.header[:]); err != nil {
+			return written, err
+		}
+		if _, err := fw.w.Write(chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// FrameReader reads the data written by a FrameWriter. A Read fails with a
+// *ChunkError once a frame does not match its CRC, before any of its data is
+// returned.
+type FrameReader struct {
+	r       io.Reader
+	buf     []byte
+	pending []byte
+	offset  int64 // Offset of the next frame
+}
+
+// NewFrameReader creates a FrameReader reading from r
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{r: r}
+}
+
+// Read implements io.Reader
+func (fr *FrameReader) Read(p []byte) (int, error) {
+	if len(fr.pending) == 0 {
+		if err := fr.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, fr.pending)
+	fr.pending = fr.pending[n:]
+	return n, nil
+}
+
+// next reads and checks the next frame
+func (fr *FrameReader) next() error {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(fr.r, header[:]); err != nil {
+		return err // io.EOF between frames is the end of the data
+	}
+	size := binary.BigEndian.Uint32(header[:4])
+	sum := binary.BigEndian.Uint32(header[4:])
+	if size > MaxFrameSize {
+		return fmt.Errorf("%w: frame of %d bytes at offset %d exceeds %d bytes", types.ErrInvalidInput, size, fr.offset, MaxFrameSize)
+	}
+
+	if cap(fr.buf) < int(size) {
+		fr.buf = make([]byte, size)
+	}
+	data := fr.buf[:size]
+	if _, err := io.ReadFull(fr.r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if crc32.ChecksumIEEE(data) != sum {
+		return &ChunkError{Offset: fr.offset, Length: int(size)}
+	}
+
+	fr.offset += int64(size)
+	fr.pending = data
+	return nil
+}
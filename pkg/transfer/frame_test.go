@@ -0,0 +1,77 @@
+package transfer_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/transfer"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// framed writes data in frames of chunk bytes
+func framed(t *testing.T, data []byte, chunk int) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w := transfer.NewFrameWriter(&out)
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	return out.Bytes()
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+
+	got, err := io.ReadAll(transfer.NewFrameReader(bytes.NewReader(framed(t, data, 4096))))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("read %d bytes, want the %d written", len(got), len(data))
+	}
+}
+
+func TestFrameCorruption(t *testing.T) {
+	data := bytes.Repeat([]byte{0xab}, 3000)
+	stream := framed(t, data, 1000)
+
+	// Flip a bit in the data of the second frame
+	stream[(8+1000)+8+10] ^= 0x01
+
+	r := transfer.NewFrameReader(bytes.NewReader(stream))
+	got, err := io.ReadAll(r)
+	if len(got) != 1000 {
+		t.Errorf("read %d bytes before the corrupted frame, want 1000", len(got))
+	}
+	var chunkErr *transfer.ChunkError
+	if !errors.As(err, &chunkErr) || !errors.Is(err, types.ErrInvalidChecksum) {
+		t.Fatalf("ReadAll() error = %v, want a ChunkError", err)
+	}
+	if chunkErr.Offset != 1000 || chunkErr.Length != 1000 {
+		t.Errorf("ChunkError = %+v, want bytes 1000-1999", chunkErr)
+	}
+}
+
+func TestFrameTruncated(t *testing.T) {
+	stream := framed(t, make([]byte, 100), 100)
+
+	_, err := io.ReadAll(transfer.NewFrameReader(bytes.NewReader(stream[:50])))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadAll() error = %v, want ErrUnexpectedEOF", err)
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	header := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+
+	_, err := io.ReadAll(transfer.NewFrameReader(bytes.NewReader(header)))
+	if !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("ReadAll() error = %v, want ErrInvalidInput", err)
+	}
+}
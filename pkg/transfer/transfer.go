@@ -2,172 +2,156 @@ package transfer
 
 import (
 	"context"
-	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
+	"time"
 
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
 )
 
-const (
-	protocolID  = "/p2p-playground/transfer/1.0.0"
-	chunkSize   = 64 * 1024          // 64KB chunks
-	maxFileSize = 1024 * 1024 * 1024 // 1GB max
-)
+// DefaultChunkSize is the size of the chunks files are sent and received in
+const DefaultChunkSize = 64 * 1024
+
+// CheckSize returns an error wrapping types.ErrPackageTooLarge if a file of
+// size bytes exceeds limit, or types.ErrInvalidInput if size is negative.
+// A limit of 0 or less means no limit.
+func CheckSize(size int64, limit int64) error {
+	if size < 0 {
+		return fmt.Errorf("%w: invalid file size %d", types.ErrInvalidInput, size)
+	}
+	if limit > 0 && size > limit {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", types.ErrPackageTooLarge, size, limit)
+	}
+	return nil
+}
+
+// Request opens a transfer stream. With To set, it asks the node to push
+// the package Ref names to the node To; otherwise the package it describes
+// follows it in CRC frames, to be deployed.
+type Request struct {
+	// To and Ref name the node and package of a push
+	To  string `json:"to,omitempty"`
+	Ref string `json:"ref,omitempty"`
+
+	// FileName, FileSize, SHA256 and Signature describe the package that
+	// follows the request
+	FileName  string `json:"file_name,omitempty"`
+	FileSize  int64  `json:"file_size,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+
+	// AutoStart starts the app once deployed
+	AutoStart bool `json:"auto_start,omitempty"`
+
+	RequestID string `json:"request_id,omitempty"` // Optional caller-supplied correlation ID
+}
 
-// Manager handles file transfers over P2P
+// Response answers a Request once the package is deployed, or the push is done
+type Response struct {
+	Success  bool          `json:"success"`
+	AppID    string        `json:"app_id,omitempty"`    // App instance deployed
+	FileName string        `json:"file_name,omitempty"` // Package sent
+	Bytes    int64         `json:"bytes,omitempty"`     // Bytes of the package sent
+	Elapsed  time.Duration `json:"elapsed,omitempty"`   // Time spent sending the package
+
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code,omitempty"` // Machine-readable error code (types.Code*)
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Manager sends packages to other nodes over the transfer protocol
 type Manager struct {
-	host   types.Host
-	logger types.Logger
+	host        types.Host
+	logger      types.Logger
+	maxFileSize int64
+	chunkSize   int
 }
 
-// New creates a new transfer manager
-func New(host types.Host, logger types.Logger) *Manager {
-	m := &Manager{
-		host:   host,
-		logger: logger,
-	}
+// Option configures optional transfer manager behavior
+type Option func(*Manager)
 
-	// Set up stream handler for receiving files
-	host.SetStreamHandler(protocolID, m.handleIncomingStream)
+// WithChunkSize sends files in chunks of size bytes instead of
+// DefaultChunkSize, e.g. smaller ones on devices short of memory
+func WithChunkSize(size int) Option {
+	return func(m *Manager) {
+		if size > 0 {
+			m.chunkSize = size
+		}
+	}
+}
 
+// New creates a new transfer manager that sends files of up to maxFileSize
+// bytes (0 or less for no limit)
+func New(host types.Host, logger types.Logger, maxFileSize int64, opts ...Option) *Manager {
+	m := &Manager{
+		host:        host,
+		logger:      logger,
+		maxFileSize: maxFileSize,
+		chunkSize:   DefaultChunkSize,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
 	return m
 }
 
-// Send sends a file to a peer
-func (m *Manager) Send(ctx context.Context, peerID string, filePath string, progress types.ProgressCallback) error {
-	// Open file
+// Send sends the file at filePath to a peer, described by req, in CRC frames,
+// and returns the response of the peer once it has deployed it. The size of
+// the file is filled in.
+func (m *Manager) Send(ctx context.Context, peerID string, filePath string, req Request) (*Response, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return types.WrapError(err, "failed to open file")
+		return nil, types.WrapError(err, "failed to open file")
 	}
 	defer func() { _ = file.Close() }()
 
-	// Get file info
 	fileInfo, err := file.Stat()
 	if err != nil {
-		return types.WrapError(err, "failed to get file info")
+		return nil, types.WrapError(err, "failed to get file info")
 	}
-
-	fileSize := fileInfo.Size()
-	if fileSize > maxFileSize {
-		return fmt.Errorf("file too large: %d bytes", fileSize)
+	req.FileSize = fileInfo.Size()
+	if err := CheckSize(req.FileSize, m.maxFileSize); err != nil {
+		return nil, err
 	}
 
-	// Create stream to peer
-	stream, err := m.host.NewStream(ctx, peerID, protocolID)
+	stream, err := m.host.NewStream(ctx, peerID, consts.TransferProtocolID)
 	if err != nil {
-		return types.WrapError(err, "failed to create stream")
+		return nil, types.WrapError(err, "failed to create stream")
 	}
 	defer func() { _ = stream.Close() }()
 
-	// Send file size first
-	if err := binary.Write(stream, binary.BigEndian, fileSize); err != nil {
-		return types.WrapError(err, "failed to send file size")
-	}
-
-	// Send file in chunks
-	buf := make([]byte, chunkSize)
-	var sent int64
-
-	for {
-		n, err := file.Read(buf)
-		if err != nil && err != io.EOF {
-			return types.WrapError(err, "failed to read file")
-		}
-
-		if n == 0 {
-			break
-		}
-
-		// Write chunk
-		if _, err := stream.Write(buf[:n]); err != nil {
-			return types.WrapError(err, "failed to send chunk")
-		}
-
-		sent += int64(n)
+	// Unblock reads and writes once ctx is done
+	stop := context.AfterFunc(ctx, func() { _ = stream.Reset() })
+	defer stop()
 
-		// Report progress
-		if progress != nil {
-			progress(sent, fileSize)
-		}
+	if err := wire.WriteMessage(stream, &req); err != nil {
+		return nil, err
 	}
 
-	m.logger.Info("file sent successfully",
-		"peer", peerID,
-		"file", filePath,
-		"size", fileSize,
-	)
-
-	return nil
-}
-
-// Receive receives a file from a stream
-func (m *Manager) Receive(ctx context.Context, stream types.Stream, destPath string, progress types.ProgressCallback) error {
-	// Read file size
-	var fileSize int64
-	if err := binary.Read(stream, binary.BigEndian, &fileSize); err != nil {
-		return types.WrapError(err, "failed to read file size")
+	start := time.Now()
+	buf := make([]byte, m.chunkSize)
+	if _, err := io.CopyBuffer(NewFrameWriter(stream), io.LimitReader(file, req.FileSize), buf); err != nil {
+		return nil, types.WrapError(err, "failed to send file")
 	}
+	elapsed := time.Since(start)
 
-	if fileSize > maxFileSize {
-		return fmt.Errorf("file too large: %d bytes", fileSize)
+	var resp Response
+	if err := wire.ReadMessage(stream, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
-
-	// Create destination file
-	file, err := os.Create(destPath)
-	if err != nil {
-		return types.WrapError(err, "failed to create file")
+	if !resp.Success {
+		return nil, types.ErrorFromCode(resp.Code, resp.Error)
 	}
-	defer func() { _ = file.Close() }()
-
-	// Receive file in chunks
-	buf := make([]byte, chunkSize)
-	var received int64
-
-	for received < fileSize {
-		n, err := stream.Read(buf)
-		if err != nil && err != io.EOF {
-			return types.WrapError(err, "failed to read chunk")
-		}
-
-		if n == 0 {
-			break
-		}
-
-		// Write to file
-		if _, err := file.Write(buf[:n]); err != nil {
-			return types.WrapError(err, "failed to write file")
-		}
-
-		received += int64(n)
+	resp.FileName, resp.Bytes, resp.Elapsed = req.FileName, req.FileSize, elapsed
 
-		// Report progress
-		if progress != nil {
-			progress(received, fileSize)
-		}
-	}
-
-	if received != fileSize {
-		return fmt.Errorf("incomplete transfer: received %d of %d bytes", received, fileSize)
-	}
-
-	m.logger.Info("file received successfully",
-		"file", destPath,
-		"size", fileSize,
+	m.logger.Info("file sent successfully",
+		"peer", peerID,
+		"file", filePath,
+		"size", req.FileSize,
+		"app_id", resp.AppID,
 	)
-
-	return nil
-}
-
-// handleIncomingStream handles incoming transfer streams
-func (m *Manager) handleIncomingStream(stream types.Stream) {
-	defer func() { _ = stream.Close() }()
-
-	m.logger.Info("incoming file transfer")
-
-	// For now, just close the stream
-	// In a real implementation, you'd coordinate with the daemon
-	// to determine where to save the file
+	return &resp, nil
 }
@@ -0,0 +1,99 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/consts"
+	"github.com/asjdf/p2p-playground-lite/pkg/logging"
+	"github.com/asjdf/p2p-playground-lite/pkg/transfer"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+// pipeStream is a stream over one end of a net.Pipe
+type pipeStream struct {
+	net.Conn
+}
+
+// Reset closes the pipe
+func (s pipeStream) Reset() error {
+	return s.Close()
+}
+
+// pipeHost opens streams to a handler running on the other end of a pipe
+type pipeHost struct {
+	types.Host
+	handle func(stream types.Stream)
+}
+
+// NewStream implements types.Host
+func (h *pipeHost) NewStream(ctx context.Context, peerID string, protocol string) (types.Stream, error) {
+	if protocol != consts.TransferProtocolID {
+		return nil, errors.New("unexpected protocol " + protocol)
+	}
+	local, remote := net.Pipe()
+	go func() {
+		defer func() { _ = remote.Close() }()
+		h.handle(pipeStream{remote})
+	}()
+	return pipeStream{local}, nil
+}
+
+func TestSend(t *testing.T) {
+	content := bytes.Repeat([]byte("package"), 50_000)
+	path := filepath.Join(t.TempDir(), "web-1.0.0.tar.gz")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got transfer.Request
+	var received []byte
+	host := &pipeHost{handle: func(stream types.Stream) {
+		if err := wire.ReadMessage(stream, &got); err != nil {
+			t.Errorf("ReadMessage() error = %v", err)
+			return
+		}
+		received, _ = io.ReadAll(io.LimitReader(transfer.NewFrameReader(stream), got.FileSize))
+		_ = wire.WriteMessage(stream, &transfer.Response{Success: true, AppID: "web-1.0.0"})
+	}}
+	logger := logging.NewNopLogger()
+	m := transfer.New(host, logger, 0, transfer.WithChunkSize(4096))
+
+	resp, err := m.Send(context.Background(), "peer", path, transfer.Request{FileName: "web-1.0.0.tar.gz", AutoStart: true})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if resp.AppID != "web-1.0.0" || resp.Bytes != int64(len(content)) || resp.FileName != "web-1.0.0.tar.gz" {
+		t.Errorf("Send() = %+v", resp)
+	}
+	if got.FileName != "web-1.0.0.tar.gz" || got.FileSize != int64(len(content)) || !got.AutoStart {
+		t.Errorf("request = %+v", got)
+	}
+	if !bytes.Equal(received, content) {
+		t.Errorf("received %d bytes, want the %d of the file", len(received), len(content))
+	}
+
+	// The error of the receiving node is returned
+	host.handle = func(stream types.Stream) {
+		var req transfer.Request
+		_ = wire.ReadMessage(stream, &req)
+		_, _ = io.Copy(io.Discard, io.LimitReader(transfer.NewFrameReader(stream), req.FileSize))
+		_ = wire.WriteMessage(stream, &transfer.Response{Code: types.CodePackageNotSigned, Error: "unsigned packages are not allowed"})
+	}
+	if _, err := m.Send(context.Background(), "peer", path, transfer.Request{FileName: "web-1.0.0.tar.gz"}); !errors.Is(err, types.ErrPackageNotSigned) {
+		t.Errorf("Send() error = %v, want ErrPackageNotSigned", err)
+	}
+
+	// Files beyond the limit are refused before sending
+	small := transfer.New(host, logger, 10)
+	if _, err := small.Send(context.Background(), "peer", path, transfer.Request{}); !errors.Is(err, types.ErrPackageTooLarge) {
+		t.Errorf("Send() of an oversized file error = %v, want ErrPackageTooLarge", err)
+	}
+}
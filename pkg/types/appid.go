@@ -0,0 +1,153 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Placeholders of an app ID format
+const (
+	// AppIDName is replaced by the name of the app, which must be in the format
+	AppIDName = "{name}"
+
+	// AppIDVersion is replaced by the version deployed
+	AppIDVersion = "{version}"
+
+	// AppIDHash is replaced by the first AppIDHashLength hex digits of the
+	// SHA-256 of the package
+	AppIDHash = "{hash}"
+
+	// AppIDInstance is replaced by the revision number of the deployment on
+	// the node, e.g. "r7", so that every deployment gets an ID of its own
+	AppIDInstance = "{instance}"
+)
+
+// DefaultAppIDFormat is the format of the IDs of apps on nodes that do not
+// configure one: the ID is the same for every deployment of a version
+const DefaultAppIDFormat = AppIDName + "-" + AppIDVersion
+
+// AppIDHashLength is how many hex digits of the package checksum an ID keeps
+const AppIDHashLength = 8
+
+// appIDPlaceholder matches the placeholders of an app ID format
+var appIDPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// AppIDParts are what the ID of a deployment is made of
+type AppIDParts struct {
+	// Name is the name of the app
+	Name string
+
+	// Version is the version deployed
+	Version string
+
+	// SHA256 is the hex-encoded checksum of the package
+	SHA256 string
+
+	// Instance is the revision number of the deployment on the node
+	Instance int64
+}
+
+// ValidateAppIDFormat checks that format names the app and uses known
+// placeholders only. An empty format is DefaultAppIDFormat.
+func ValidateAppIDFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	if !strings.Contains(format, AppIDName) {
+		return fmt.Errorf("%w: app ID format %q must contain %s", ErrInvalidInput, format, AppIDName)
+	}
+	for _, p := range appIDPlaceholder.FindAllString(format, -1) {
+		switch p {
+		case AppIDName, AppIDVersion, AppIDHash, AppIDInstance:
+		default:
+			return fmt.Errorf("%w: app ID format %q has unknown placeholder %s, want %s, %s, %s or %s",
+				ErrInvalidInput, format, p, AppIDName, AppIDVersion, AppIDHash, AppIDInstance)
+		}
+	}
+	rest := appIDPlaceholder.ReplaceAllString(format, "")
+	if strings.ContainsAny(rest, "/\\{} ") {
+		return fmt.Errorf("%w: app ID format %q may only join placeholders with characters allowed in file names", ErrInvalidInput, format)
+	}
+	return nil
+}
+
+// FormatAppID returns the ID of a deployment under format, DefaultAppIDFormat
+// if empty. The text before the first placeholder and after the last is kept
+// as is. A part that is unknown, such as the checksum of a package that could
+// not be read, is left out together with one separator: the one before it, or
+// the one after it if no part is written before it. "{hash}-{name}-{version}"
+// thus gives "web-1.2.0" without a checksum, as "{name}-{hash}-{version}" and
+// "{name}-{version}-{hash}" do.
+func FormatAppID(format string, parts AppIDParts) string {
+	if format == "" {
+		format = DefaultAppIDFormat
+	}
+	hash := parts.SHA256
+	if len(hash) > AppIDHashLength {
+		hash = hash[:AppIDHashLength]
+	}
+	instance := ""
+	if parts.Instance > 0 {
+		instance = "r" + strconv.FormatInt(parts.Instance, 10)
+	}
+	values := map[string]string{
+		AppIDName:     parts.Name,
+		AppIDVersion:  parts.Version,
+		AppIDHash:     hash,
+		AppIDInstance: instance,
+	}
+
+	var b strings.Builder
+	last, written := 0, false
+	for i, loc := range appIDPlaceholder.FindAllStringIndex(format, -1) {
+		text := format[last:loc[0]]
+		last = loc[1]
+		if i == 0 {
+			b.WriteString(text)
+		}
+		value := values[format[loc[0]:loc[1]]]
+		if value == "" {
+			continue
+		}
+		if written {
+			b.WriteString(text)
+		}
+		b.WriteString(value)
+		written = true
+	}
+	b.WriteString(format[last:])
+	return b.String()
+}
+
+// LegacyAppID returns <name>-<version>, the ID of apps deployed under
+// DefaultAppIDFormat. It addresses the deployments of a version whatever the
+// format, and keys what outlives redeploying a version, such as the labels
+// operators set.
+func LegacyAppID(name, version string) string {
+	return name + "-" + version
+}
+
+// ResolveApp returns the app of apps that ref names. An operator names an
+// app by its ID, its name, or its legacy ID <name>-<version>: an ID names that
+// app, and a name or legacy ID the latest deployment, by revision, of those
+// it matches.
+func ResolveApp(apps []*Application, ref string) (*Application, error) {
+	var found *Application
+	for _, app := range apps {
+		if app.ID == ref {
+			return app, nil
+		}
+		if app.Name != ref && LegacyAppID(app.Name, app.Version) != ref {
+			continue
+		}
+		if found == nil || app.Revision > found.Revision {
+			found = app
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: no application %s", ErrNotFound, ref)
+	}
+	return found, nil
+}
@@ -0,0 +1,94 @@
+package types_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func TestFormatAppID(t *testing.T) {
+	parts := types.AppIDParts{Name: "web", Version: "1.2.0", SHA256: "0123456789abcdef", Instance: 7}
+	tests := []struct {
+		format string
+		parts  types.AppIDParts
+		want   string
+	}{
+		{"", parts, "web-1.2.0"},
+		{types.DefaultAppIDFormat, parts, "web-1.2.0"},
+		{"{name}-{version}-{hash}-{instance}", parts, "web-1.2.0-01234567-r7"},
+		{"{name}.{instance}", parts, "web.r7"},
+		{"app-{name}.d", parts, "app-web.d"},
+	}
+	for _, tt := range tests {
+		if got := types.FormatAppID(tt.format, tt.parts); got != tt.want {
+			t.Errorf("FormatAppID(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAppIDEmptyParts(t *testing.T) {
+	parts := types.AppIDParts{Name: "web", Version: "1.2.0"}
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"leading", "{hash}-{name}-{version}", "web-1.2.0"},
+		{"leading after text", "app-{instance}.{name}-{version}", "app-web-1.2.0"},
+		{"leading pair", "{instance}_{hash}-{name}", "web"},
+		{"middle", "{name}-{hash}.{version}", "web.1.2.0"},
+		{"middle pair", "{name}-{hash}_{instance}.{version}", "web.1.2.0"},
+		{"trailing", "{name}-{version}-{hash}", "web-1.2.0"},
+		{"trailing before text", "{name}-{instance}.app", "web.app"},
+		{"trailing pair", "{name}.{hash}-{instance}", "web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := types.FormatAppID(tt.format, parts); got != tt.want {
+				t.Errorf("FormatAppID(%q) = %q, want %q", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAppIDFormat(t *testing.T) {
+	for _, format := range []string{"", "{name}", "{name}-{version}-{hash}-{instance}", "{name}_{instance}"} {
+		if err := types.ValidateAppIDFormat(format); err != nil {
+			t.Errorf("ValidateAppIDFormat(%q) error = %v", format, err)
+		}
+	}
+	for _, format := range []string{"{version}-{hash}", "{name}-{revision}", "{name}/{version}", "{name} {version}"} {
+		if err := types.ValidateAppIDFormat(format); !errors.Is(err, types.ErrInvalidInput) {
+			t.Errorf("ValidateAppIDFormat(%q) error = %v, want ErrInvalidInput", format, err)
+		}
+	}
+}
+
+func TestResolveApp(t *testing.T) {
+	app := func(id, name, version string, revision int64) *types.Application {
+		return &types.Application{AppSpec: types.AppSpec{ID: id, Name: name, Version: version, Revision: revision}}
+	}
+	apps := []*types.Application{
+		app("web-1.0.0", "web", "1.0.0", 1),
+		app("web-1.1.0-r2", "web", "1.1.0", 2),
+		app("web-1.1.0-r3", "web", "1.1.0", 3),
+		app("worker-2.0.0", "worker", "2.0.0", 1),
+	}
+	tests := map[string]string{
+		"web-1.1.0-r2": "web-1.1.0-r2",
+		"web":          "web-1.1.0-r3",
+		"web-1.1.0":    "web-1.1.0-r3",
+		"web-1.0.0":    "web-1.0.0",
+		"worker":       "worker-2.0.0",
+	}
+	for ref, want := range tests {
+		got, err := types.ResolveApp(apps, ref)
+		if err != nil || got.ID != want {
+			t.Errorf("ResolveApp(%q) = %v, %v, want %s", ref, got, err, want)
+		}
+	}
+	if _, err := types.ResolveApp(apps, "db"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("ResolveApp(db) error = %v, want ErrNotFound", err)
+	}
+}
@@ -36,6 +36,9 @@ var (
 
 	// ErrInvalidState indicates an operation cannot be performed in the current state
 	ErrInvalidState = errors.New("invalid state")
+
+	// ErrLocked indicates that another process holds a lock on shared state
+	ErrLocked = errors.New("locked")
 )
 
 // Application-specific errors
@@ -54,6 +57,9 @@ var (
 
 	// ErrAppUnhealthy indicates an application failed health checks
 	ErrAppUnhealthy = errors.New("application unhealthy")
+
+	// ErrAppLocked indicates another operation on the application is in progress
+	ErrAppLocked = errors.New("application locked")
 )
 
 // Package-specific errors
@@ -72,6 +78,21 @@ var (
 
 	// ErrPackageNotSigned indicates a package is not signed
 	ErrPackageNotSigned = errors.New("package not signed")
+
+	// ErrAdmissionDenied indicates an admission hook rejected a deployment
+	ErrAdmissionDenied = errors.New("admission denied")
+
+	// ErrPolicyViolation indicates a manifest violates the configured policy
+	ErrPolicyViolation = errors.New("policy violation")
+
+	// ErrOwnershipConflict indicates an application is owned by a different signing key
+	ErrOwnershipConflict = errors.New("ownership conflict")
+
+	// ErrQuotaExceeded indicates a deployment would exceed the quota of its operator
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// ErrPackageTooLarge indicates a package exceeds the size a node accepts
+	ErrPackageTooLarge = errors.New("package too large")
 )
 
 // P2P-specific errors
@@ -87,6 +108,13 @@ var (
 
 	// ErrProtocolNotSupported indicates a protocol is not supported
 	ErrProtocolNotSupported = errors.New("protocol not supported")
+
+	// ErrPSKMismatch indicates a peer could not be connected because the two
+	// sides do not share the private network key (PSK)
+	ErrPSKMismatch = errors.New("private network key mismatch")
+
+	// ErrRateLimited indicates a peer made more requests than a node accepts
+	ErrRateLimited = errors.New("rate limited")
 )
 
 // Version-specific errors
@@ -111,8 +139,97 @@ var (
 
 	// ErrStorageDelete indicates a storage delete operation failed
 	ErrStorageDelete = errors.New("storage delete failed")
+
+	// ErrInsufficientStorage indicates there is not enough disk space
+	ErrInsufficientStorage = errors.New("insufficient storage")
+
+	// ErrUnsupportedSchema indicates persisted state was written with a
+	// schema version newer than this build reads
+	ErrUnsupportedSchema = errors.New("unsupported schema version")
 )
 
+// Error codes carried in protocol responses so clients can tell failures apart
+const (
+	CodeNotFound            = "NOT_FOUND"
+	CodeAlreadyExists       = "ALREADY_EXISTS"
+	CodeInvalidInput        = "INVALID_INPUT"
+	CodeUnauthorized        = "UNAUTHORIZED"
+	CodeTimeout             = "TIMEOUT"
+	CodeCanceled            = "CANCELED"
+	CodeInternal            = "INTERNAL"
+	CodeNotImplemented      = "NOT_IMPLEMENTED"
+	CodeUnavailable         = "UNAVAILABLE"
+	CodeInvalidState        = "INVALID_STATE"
+	CodeLocked              = "LOCKED"
+	CodeAppNotRunning       = "APP_NOT_RUNNING"
+	CodeAppAlreadyRunning   = "APP_ALREADY_RUNNING"
+	CodeAppStartFailed      = "APP_START_FAILED"
+	CodeAppStopFailed       = "APP_STOP_FAILED"
+	CodeAppUnhealthy        = "APP_UNHEALTHY"
+	CodeAppLocked           = "APP_LOCKED"
+	CodeInvalidManifest     = "INVALID_MANIFEST"
+	CodeInvalidPackage      = "INVALID_PACKAGE"
+	CodeInvalidSignature    = "INVALID_SIGNATURE"
+	CodeInvalidChecksum     = "INVALID_CHECKSUM"
+	CodePackageNotSigned    = "PACKAGE_NOT_SIGNED"
+	CodeAdmissionDenied     = "ADMISSION_DENIED"
+	CodePolicyViolation     = "POLICY_VIOLATION"
+	CodeOwnershipConflict   = "OWNERSHIP_CONFLICT"
+	CodeQuotaExceeded       = "QUOTA_EXCEEDED"
+	CodePackageTooLarge     = "PACKAGE_TOO_LARGE"
+	CodeStreamClosed        = "STREAM_CLOSED"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeInvalidVersion      = "INVALID_VERSION"
+	CodeVersionConflict     = "VERSION_CONFLICT"
+	CodeStorageRead         = "STORAGE_READ_FAILED"
+	CodeStorageWrite        = "STORAGE_WRITE_FAILED"
+	CodeInsufficientStorage = "INSUFFICIENT_STORAGE"
+	CodeUnsupportedSchema   = "UNSUPPORTED_SCHEMA"
+)
+
+// codeErrors maps error codes to sentinel errors.
+// More specific errors come first since the first match wins.
+var codeErrors = []struct {
+	code string
+	err  error
+}{
+	{CodeInvalidSignature, ErrInvalidSignature},
+	{CodePackageNotSigned, ErrPackageNotSigned},
+	{CodeAdmissionDenied, ErrAdmissionDenied},
+	{CodePolicyViolation, ErrPolicyViolation},
+	{CodeOwnershipConflict, ErrOwnershipConflict},
+	{CodeQuotaExceeded, ErrQuotaExceeded},
+	{CodePackageTooLarge, ErrPackageTooLarge},
+	{CodeInvalidChecksum, ErrInvalidChecksum},
+	{CodeInvalidManifest, ErrInvalidManifest},
+	{CodeInvalidPackage, ErrInvalidPackage},
+	{CodeInsufficientStorage, ErrInsufficientStorage},
+	{CodeUnsupportedSchema, ErrUnsupportedSchema},
+	{CodeStorageRead, ErrStorageRead},
+	{CodeStorageWrite, ErrStorageWrite},
+	{CodeAppNotRunning, ErrAppNotRunning},
+	{CodeAppAlreadyRunning, ErrAppAlreadyRunning},
+	{CodeAppStartFailed, ErrAppStartFailed},
+	{CodeAppStopFailed, ErrAppStopFailed},
+	{CodeAppUnhealthy, ErrAppUnhealthy},
+	{CodeAppLocked, ErrAppLocked},
+	{CodeInvalidVersion, ErrInvalidVersion},
+	{CodeVersionConflict, ErrVersionConflict},
+	{CodeStreamClosed, ErrStreamClosed},
+	{CodeRateLimited, ErrRateLimited},
+	{CodeNotFound, ErrNotFound},
+	{CodeAlreadyExists, ErrAlreadyExists},
+	{CodeInvalidInput, ErrInvalidInput},
+	{CodeUnauthorized, ErrUnauthorized},
+	{CodeTimeout, ErrTimeout},
+	{CodeCanceled, ErrCanceled},
+	{CodeNotImplemented, ErrNotImplemented},
+	{CodeUnavailable, ErrUnavailable},
+	{CodeInvalidState, ErrInvalidState},
+	{CodeLocked, ErrLocked},
+	{CodeInternal, ErrInternal},
+}
+
 // AppError provides structured error information
 type AppError struct {
 	// Code is the error code
@@ -141,8 +258,14 @@ func (e *AppError) Unwrap() error {
 	return e.Err
 }
 
-// Is checks if the error matches the target
+// Is checks if the error matches the target.
+// An AppError without an underlying error matches the sentinel for its code,
+// so errors reconstructed from a protocol response still work with errors.Is.
 func (e *AppError) Is(target error) bool {
+	if e.Err == nil {
+		sentinel := sentinelForCode(e.Code)
+		return sentinel != nil && sentinel == target
+	}
 	return errors.Is(e.Err, target)
 }
 
@@ -162,6 +285,50 @@ func (e *AppError) WithField(key string, value interface{}) *AppError {
 	return e
 }
 
+// ErrorCode returns the error code for err.
+// AppError codes take precedence; otherwise the code of the first matching
+// sentinel error is used, falling back to CodeInternal.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var appErr *AppError
+	if errors.As(err, &appErr) && appErr.Code != "" {
+		return appErr.Code
+	}
+
+	for _, ce := range codeErrors {
+		if errors.Is(err, ce.err) {
+			return ce.code
+		}
+	}
+
+	return CodeInternal
+}
+
+// ErrorFromCode reconstructs a typed error from a code and message received over the wire
+func ErrorFromCode(code, message string) error {
+	if code == "" {
+		code = CodeInternal
+	}
+	return &AppError{
+		Code:    code,
+		Message: message,
+		Fields:  make(map[string]interface{}),
+	}
+}
+
+// sentinelForCode returns the sentinel error for code, or nil if unknown
+func sentinelForCode(code string) error {
+	for _, ce := range codeErrors {
+		if ce.code == code {
+			return ce.err
+		}
+	}
+	return nil
+}
+
 // WrapError wraps an error with additional context
 func WrapError(err error, message string) error {
 	if err == nil {
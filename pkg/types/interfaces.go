@@ -48,11 +48,17 @@ type Runtime interface {
 	// Restart restarts an application
 	Restart(ctx context.Context, appID string) error
 
+	// Pause suspends a running application, keeping its state
+	Pause(ctx context.Context, appID string) error
+
+	// Resume continues a paused application
+	Resume(ctx context.Context, appID string) error
+
 	// Status returns the status of an application
 	Status(ctx context.Context, appID string) (*AppStatus, error)
 
-	// Logs returns a stream of application logs
-	Logs(ctx context.Context, appID string, follow bool) (io.ReadCloser, error)
+	// Logs returns a stream of the application log lines selected by opts
+	Logs(ctx context.Context, appID string, opts LogOptions) (io.ReadCloser, error)
 
 	// List returns all managed applications
 	List(ctx context.Context) ([]*Application, error)
@@ -121,22 +127,23 @@ type Storage interface {
 	Exists(ctx context.Context, key string) (bool, error)
 }
 
-// VersionManager manages application versions
+// VersionManager manages application versions. Versions are specs: the
+// runtime status of an instance is not persisted.
 type VersionManager interface {
 	// Store stores a new version of an application
-	Store(ctx context.Context, app *Application) error
+	Store(ctx context.Context, spec *AppSpec) error
 
 	// Get retrieves a specific version of an application
-	Get(ctx context.Context, name, version string) (*Application, error)
+	Get(ctx context.Context, name, version string) (*AppSpec, error)
 
 	// List returns all versions of an application
-	List(ctx context.Context, name string) ([]*Application, error)
+	List(ctx context.Context, name string) ([]*AppSpec, error)
 
 	// Delete removes a specific version
 	Delete(ctx context.Context, name, version string) error
 
 	// GetLatest returns the latest version of an application
-	GetLatest(ctx context.Context, name string) (*Application, error)
+	GetLatest(ctx context.Context, name string) (*AppSpec, error)
 }
 
 // TransferManager handles file transfers over P2P network
@@ -169,6 +176,13 @@ type Logger interface {
 	With(fields ...interface{}) Logger
 }
 
+// LoggerProvider supplies the Logger used by a component.
+// Programs embedding the daemon or P2P host implement it to plug in their own logging backend.
+type LoggerProvider interface {
+	// Logger returns the logger to use
+	Logger() Logger
+}
+
 // Config represents configuration for the application
 type Config interface {
 	// GetString retrieves a string configuration value
@@ -0,0 +1,144 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// AppFilter selects applications by their status, labels and name. The zero
+// value selects them all.
+type AppFilter struct {
+	// Statuses are the statuses to keep, empty for all
+	Statuses []AppStatusType `json:"statuses,omitempty"`
+
+	// Labels are the labels an application must all have
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Name is a pattern the name or the ID must match, e.g. "web*" or
+	// "web-1.2.*" (path.Match syntax)
+	Name string `json:"name,omitempty"`
+}
+
+// Validate checks the statuses and name pattern of f
+func (f *AppFilter) Validate() error {
+	for _, status := range f.Statuses {
+		switch status {
+		case AppStatusStopped, AppStatusStarting, AppStatusRunning, AppStatusFailed, AppStatusRestarting, AppStatusPaused:
+		default:
+			return fmt.Errorf("%w: unknown app status %q", ErrInvalidInput, status)
+		}
+	}
+	if _, err := path.Match(f.Name, ""); err != nil {
+		return fmt.Errorf("%w: name pattern %q: %w", ErrInvalidInput, f.Name, err)
+	}
+	return nil
+}
+
+// Matches reports whether app passes f
+func (f *AppFilter) Matches(app *Application) bool {
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, status := range f.Statuses {
+			found = found || app.Status == status
+		}
+		if !found {
+			return false
+		}
+	}
+	for key, value := range f.Labels {
+		if app.Labels[key] != value {
+			return false
+		}
+	}
+	if f.Name != "" {
+		byName, _ := path.Match(f.Name, app.Name)
+		byID, _ := path.Match(f.Name, app.ID)
+		if !byName && !byID {
+			return false
+		}
+	}
+	return true
+}
+
+// Orders of listed applications, all of them then by ID
+const (
+	// AppOrderID orders applications by ID, the default
+	AppOrderID = "id"
+
+	// AppOrderName orders applications by name
+	AppOrderName = "name"
+
+	// AppOrderStarted puts the most recently started applications first
+	AppOrderStarted = "started"
+
+	// AppOrderMemory puts the applications using the most memory first
+	AppOrderMemory = "memory"
+)
+
+// SortAppStatuses sorts statuses in the given AppOrder*, by ID if empty
+func SortAppStatuses(statuses []*AppStatus, order string) error {
+	var less func(a, b *AppStatus) bool
+	switch order {
+	case "", AppOrderID:
+		less = func(a, b *AppStatus) bool { return false }
+	case AppOrderName:
+		less = func(a, b *AppStatus) bool { return a.App.Name < b.App.Name }
+	case AppOrderStarted:
+		less = func(a, b *AppStatus) bool { return a.App.StartedAt.After(b.App.StartedAt) }
+	case AppOrderMemory:
+		less = func(a, b *AppStatus) bool { return memoryMB(a) > memoryMB(b) }
+	default:
+		return fmt.Errorf("%w: unknown order %q, want id, name, started or memory", ErrInvalidInput, order)
+	}
+
+	sort.SliceStable(statuses, func(i, j int) bool {
+		a, b := statuses[i], statuses[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.App.ID < b.App.ID
+	})
+	return nil
+}
+
+// PageAppStatuses returns the statuses, sorted in the given order, that
+// follow the one of the app with the ID cursor, at most limit of them (0 for
+// all), and the cursor of those the limit left out. In the order of IDs the
+// cursor app need not be there anymore; in other orders it must be, as
+// where it was cannot be told otherwise.
+func PageAppStatuses(statuses []*AppStatus, order string, cursor string, limit int) ([]*AppStatus, string, error) {
+	if cursor != "" {
+		start := -1
+		if order == "" || order == AppOrderID {
+			start = sort.Search(len(statuses), func(i int) bool { return statuses[i].App.ID > cursor })
+		} else {
+			for i, status := range statuses {
+				if status.App.ID == cursor {
+					start = i + 1
+					break
+				}
+			}
+		}
+		if start < 0 {
+			return nil, "", fmt.Errorf("%w: the app at the cursor, %s, is no longer listed; list again without the cursor", ErrNotFound, cursor)
+		}
+		statuses = statuses[start:]
+	}
+	if limit > 0 && len(statuses) > limit {
+		statuses = statuses[:limit]
+		return statuses, statuses[len(statuses)-1].App.ID, nil
+	}
+	return statuses, "", nil
+}
+
+// memoryMB returns the memory an application was last measured to use
+func memoryMB(status *AppStatus) int64 {
+	if status.ResourceUsage == nil {
+		return 0
+	}
+	return status.ResourceUsage.MemoryMB
+}
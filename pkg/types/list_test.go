@@ -0,0 +1,110 @@
+package types_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+func listedApp(id, name string, status types.AppStatusType, memoryMB int64, startedAt time.Time, labels map[string]string) *types.AppStatus {
+	app := &types.Application{
+		AppSpec:          types.AppSpec{ID: id, Name: name, Labels: labels},
+		AppRuntimeStatus: types.AppRuntimeStatus{Status: status, StartedAt: startedAt},
+	}
+	return &types.AppStatus{App: app, AppHealth: types.AppHealth{ResourceUsage: &types.ResourceUsage{MemoryMB: memoryMB}}}
+}
+
+func ids(statuses []*types.AppStatus) []string {
+	var ids []string
+	for _, status := range statuses {
+		ids = append(ids, status.App.ID)
+	}
+	return ids
+}
+
+func TestAppFilter(t *testing.T) {
+	prod := map[string]string{"env": "prod", "tier": "web"}
+	web := listedApp("web-1.0.0", "web", types.AppStatusRunning, 0, time.Time{}, prod).App
+	worker := listedApp("worker-1.0.0", "worker", types.AppStatusFailed, 0, time.Time{}, nil).App
+
+	tests := []struct {
+		name   string
+		filter types.AppFilter
+		want   []bool // Whether web and worker pass
+	}{
+		{"empty", types.AppFilter{}, []bool{true, true}},
+		{"status", types.AppFilter{Statuses: []types.AppStatusType{types.AppStatusFailed, types.AppStatusStopped}}, []bool{false, true}},
+		{"labels", types.AppFilter{Labels: map[string]string{"env": "prod"}}, []bool{true, false}},
+		{"name", types.AppFilter{Name: "w*r"}, []bool{false, true}},
+		{"id", types.AppFilter{Name: "web-1.*"}, []bool{true, false}},
+		{"all", types.AppFilter{Statuses: []types.AppStatusType{types.AppStatusRunning}, Labels: prod, Name: "web"}, []bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got := []bool{tt.filter.Matches(web), tt.filter.Matches(worker)}; got[0] != tt.want[0] || got[1] != tt.want[1] {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, filter := range []types.AppFilter{{Statuses: []types.AppStatusType{"sleeping"}}, {Name: "web["}} {
+		if err := filter.Validate(); !errors.Is(err, types.ErrInvalidInput) {
+			t.Errorf("Validate(%+v) error = %v, want ErrInvalidInput", filter, err)
+		}
+	}
+}
+
+func TestSortAndPageAppStatuses(t *testing.T) {
+	now := time.Now()
+	statuses := []*types.AppStatus{
+		listedApp("c", "beta", types.AppStatusRunning, 10, now.Add(-time.Hour), nil),
+		listedApp("a", "gamma", types.AppStatusRunning, 300, now, nil),
+		listedApp("b", "alpha", types.AppStatusRunning, 10, now.Add(-time.Minute), nil),
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{"", []string{"a", "b", "c"}},
+		{types.AppOrderName, []string{"b", "c", "a"}},
+		{types.AppOrderStarted, []string{"a", "b", "c"}},
+		{types.AppOrderMemory, []string{"a", "b", "c"}}, // Ties by ID
+	}
+	for _, tt := range tests {
+		if err := types.SortAppStatuses(statuses, tt.order); err != nil {
+			t.Fatalf("SortAppStatuses(%q) error = %v", tt.order, err)
+		}
+		if got := ids(statuses); len(got) != 3 || got[0] != tt.want[0] || got[1] != tt.want[1] || got[2] != tt.want[2] {
+			t.Errorf("SortAppStatuses(%q) = %v, want %v", tt.order, got, tt.want)
+		}
+	}
+	if err := types.SortAppStatuses(statuses, "size"); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("SortAppStatuses() of an unknown order error = %v, want ErrInvalidInput", err)
+	}
+
+	// Paging by name
+	_ = types.SortAppStatuses(statuses, types.AppOrderName)
+	page, next, err := types.PageAppStatuses(statuses, types.AppOrderName, "", 2)
+	if err != nil || next != "c" || len(page) != 2 {
+		t.Fatalf("first page = %v, %q, %v; want [b c] and cursor c", ids(page), next, err)
+	}
+	page, next, err = types.PageAppStatuses(statuses, types.AppOrderName, next, 2)
+	if err != nil || next != "" || len(page) != 1 || page[0].App.ID != "a" {
+		t.Fatalf("second page = %v, %q, %v; want [a] and no cursor", ids(page), next, err)
+	}
+	if _, _, err := types.PageAppStatuses(statuses, types.AppOrderName, "gone", 2); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("PageAppStatuses() past a removed app error = %v, want ErrNotFound", err)
+	}
+
+	// In the order of IDs, the cursor app may be gone
+	_ = types.SortAppStatuses(statuses, "")
+	if page, _, err := types.PageAppStatuses(statuses, "", "aa", 0); err != nil || len(page) != 2 || page[0].App.ID != "b" {
+		t.Errorf("PageAppStatuses() past a removed app = %v, %v; want [b c]", ids(page), err)
+	}
+}
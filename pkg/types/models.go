@@ -4,8 +4,26 @@ import (
 	"time"
 )
 
-// Application represents a deployed application
+// Application is a deployed application instance: the spec it was deployed
+// with and the runtime status of its process. Its JSON is the flat object of
+// version 1 of the protocols; see AppRecord for version 2.
 type Application struct {
+	AppSpec
+	AppRuntimeStatus
+}
+
+// NewApplication returns a stopped instance of spec
+func NewApplication(spec AppSpec) *Application {
+	return &Application{
+		AppSpec:          spec,
+		AppRuntimeStatus: AppRuntimeStatus{Status: AppStatusStopped},
+	}
+}
+
+// AppSpec is what an application instance was deployed as. The daemon sets
+// it when the package is deployed and, but for its labels, it does not change
+// afterwards.
+type AppSpec struct {
 	// ID is the unique identifier for this application instance
 	ID string `json:"id"`
 
@@ -21,6 +39,20 @@ type Application struct {
 	// Manifest contains application metadata
 	Manifest *Manifest `json:"manifest"`
 
+	// Labels are key-value pairs for organization: those of the manifest,
+	// with the changes operators made since (see package applabel)
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// WorkDir is the working directory for the application
+	WorkDir string `json:"work_dir"`
+
+	// Revision numbers this deployment among those of the application on the node
+	Revision int64 `json:"revision,omitempty"`
+}
+
+// AppRuntimeStatus is the state of an application's process. Only the
+// runtime changes it.
+type AppRuntimeStatus struct {
 	// Status is the current status of the application
 	Status AppStatusType `json:"status"`
 
@@ -30,11 +62,59 @@ type Application struct {
 	// StartedAt is when the application was started
 	StartedAt time.Time `json:"started_at,omitempty"`
 
-	// Labels are key-value pairs for organization
-	Labels map[string]string `json:"labels,omitempty"`
+	// Ports are the ports allocated to the named ports of the manifest when
+	// the application was last started
+	Ports map[string]int `json:"ports,omitempty"`
 
-	// WorkDir is the working directory for the application
-	WorkDir string `json:"work_dir"`
+	// Initiator is who or what brought about the current status, if known
+	Initiator *Initiator `json:"initiator,omitempty"`
+
+	// CoreDump is the file name, in the working directory, of the core dump
+	// the process left when it last crashed, if any
+	CoreDump string `json:"core_dump,omitempty"`
+}
+
+// Initiator is who or what set off a lifecycle transition of an application
+type Initiator struct {
+	// Kind is one of the Initiator* kinds
+	Kind string `json:"kind"`
+
+	// ID identifies an operator: the name of the key that signed the
+	// package, or else the peer ID of the controller
+	ID string `json:"id,omitempty"`
+
+	// Reason is why, e.g. the request or the failed health check
+	Reason string `json:"reason,omitempty"`
+}
+
+// Kinds of initiators
+const (
+	// InitiatorOperator is an operator deploying or rolling back an app
+	InitiatorOperator = "operator"
+
+	// InitiatorHealthMonitor is the health check of the app failing
+	InitiatorHealthMonitor = "health-monitor"
+
+	// InitiatorRestartPolicy is the node restarting an app that turned unhealthy
+	InitiatorRestartPolicy = "restart-policy"
+
+	// InitiatorApp is the app process exiting on its own
+	InitiatorApp = "app"
+)
+
+// String describes the initiator, e.g. "operator alice (deploy, request 1a2b)"
+func (i *Initiator) String() string {
+	if i == nil {
+		return ""
+	}
+	s := i.Kind
+	if i.ID != "" {
+		s += " " + i.ID
+	}
+	if i.Reason != "" {
+		s += " (" + i.Reason + ")"
+	}
+	return s
 }
 
 // AppStatusType represents the status of an application
@@ -55,6 +135,10 @@ const (
 
 	// AppStatusRestarting indicates the application is restarting
 	AppStatusRestarting AppStatusType = "restarting"
+
+	// AppStatusPaused indicates the application process is suspended,
+	// keeping its state, until it is resumed
+	AppStatusPaused AppStatusType = "paused"
 )
 
 // AppStatus contains detailed status information
@@ -62,6 +146,11 @@ type AppStatus struct {
 	// App is the application reference
 	App *Application `json:"app"`
 
+	AppHealth
+}
+
+// AppHealth is how an application is doing beyond the state of its process
+type AppHealth struct {
 	// Healthy indicates if the application passed health checks
 	Healthy bool `json:"healthy"`
 
@@ -73,6 +162,123 @@ type AppStatus struct {
 
 	// ResourceUsage contains current resource usage
 	ResourceUsage *ResourceUsage `json:"resource_usage,omitempty"`
+
+	// Reported is the health the application reports about itself, if any
+	Reported *ReportedHealth `json:"reported,omitempty"`
+
+	// Metrics are the gauge values published by the application
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+// AppRecord is how version 2 of the protocols reports an application: its
+// spec, the runtime status of its process and its health apart
+type AppRecord struct {
+	Spec    *AppSpec          `json:"spec"`
+	Runtime *AppRuntimeStatus `json:"runtime"`
+	Health  *AppHealth        `json:"health,omitempty"`
+}
+
+// Record converts s to the version 2 form
+func (s *AppStatus) Record() *AppRecord {
+	record := &AppRecord{Health: &s.AppHealth}
+	if s.App != nil {
+		record.Spec = &s.App.AppSpec
+		record.Runtime = &s.App.AppRuntimeStatus
+	}
+	return record
+}
+
+// Fields of an AppRecord that version 3 of the list protocol can select
+const (
+	// RecordFieldManifest is the manifest in the spec
+	RecordFieldManifest = "manifest"
+
+	// RecordFieldRuntime is the runtime status
+	RecordFieldRuntime = "runtime"
+
+	// RecordFieldHealth is the health less the metrics
+	RecordFieldHealth = "health"
+
+	// RecordFieldMetrics is the metrics in the health
+	RecordFieldMetrics = "metrics"
+)
+
+// Select returns a copy of r with only the given RecordField* fields. The
+// rest of the spec, which identifies the app, is always kept. No fields
+// select them all; unknown fields are ignored, so controllers can ask nodes
+// older than them for fields they do not know.
+func (r *AppRecord) Select(fields []string) *AppRecord {
+	if len(fields) == 0 {
+		return r
+	}
+	selected := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		selected[field] = true
+	}
+
+	record := &AppRecord{}
+	if r.Spec != nil {
+		spec := *r.Spec
+		if !selected[RecordFieldManifest] {
+			spec.Manifest = nil
+		}
+		record.Spec = &spec
+	}
+	if selected[RecordFieldRuntime] {
+		record.Runtime = r.Runtime
+	}
+	if r.Health != nil && (selected[RecordFieldHealth] || selected[RecordFieldMetrics]) {
+		health := AppHealth{}
+		if selected[RecordFieldHealth] {
+			health = *r.Health
+			health.Metrics = nil
+		}
+		if selected[RecordFieldMetrics] {
+			health.Metrics = r.Health.Metrics
+		}
+		record.Health = &health
+	}
+	return record
+}
+
+// Status converts r to the version 1 form
+func (r *AppRecord) Status() *AppStatus {
+	app := &Application{}
+	if r.Spec != nil {
+		app.AppSpec = *r.Spec
+	}
+	if r.Runtime != nil {
+		app.AppRuntimeStatus = *r.Runtime
+	}
+	status := &AppStatus{App: app}
+	if r.Health != nil {
+		status.AppHealth = *r.Health
+	}
+	return status
+}
+
+// ReportedHealth is the health an application reports through the app API
+type ReportedHealth struct {
+	// Status is "healthy", "degraded" or "unhealthy"
+	Status string `json:"status"`
+
+	// Message explains the status, e.g. "queue backlog 10k"
+	Message string `json:"message,omitempty"`
+
+	// UpdatedAt is when the daemon received the report
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LogOptions selects the application log lines to read
+type LogOptions struct {
+	// Follow keeps the stream open and appends new lines as they are written
+	Follow bool
+
+	// Tail limits the lines already written to the last Tail, 0 for all
+	Tail int
+
+	// Since skips lines written before it, if set
+	Since time.Time
 }
 
 // Manifest describes an application package
@@ -89,7 +295,8 @@ type Manifest struct {
 	// Entrypoint is the main executable path (relative to package)
 	Entrypoint string `yaml:"entrypoint" json:"entrypoint"`
 
-	// Args are command-line arguments
+	// Args are command-line arguments. Go templates in them are resolved when
+	// the application starts, e.g. {{ .Port "web" }}, see Ports.
 	Args []string `yaml:"args,omitempty" json:"args,omitempty"`
 
 	// Env contains environment variables
@@ -109,8 +316,65 @@ type Manifest struct {
 
 	// Labels are key-value pairs for organization
 	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
+	// Security contains process confinement settings
+	Security *AppSecurity `yaml:"security,omitempty" json:"security,omitempty"`
+
+	// Network restricts the application's network access
+	Network *NetworkPolicy `yaml:"network,omitempty" json:"network,omitempty"`
+
+	// SensitiveFiles are glob patterns (relative to the package root) of files
+	// that are encrypted at rest when the daemon enables storage encryption
+	SensitiveFiles []string `yaml:"sensitive_files,omitempty" json:"sensitive_files,omitempty"`
+
+	// Devices are host device paths the application needs, e.g. "/dev/dri" or
+	// "/dev/nvidia*". A directory matches the devices below it. The app is only
+	// placed on nodes providing a device for each entry.
+	Devices []string `yaml:"devices,omitempty" json:"devices,omitempty"`
+
+	// Ports names the TCP ports allocated to the application when it starts.
+	// Each is passed as P2P_PORT_<NAME> and can be used in Args as
+	// {{ .Port "name" }}, or {{ .Addr "name" }} for a listen address.
+	Ports []string `yaml:"ports,omitempty" json:"ports,omitempty"`
+
+	// Interpreter runs a script entrypoint, e.g. "/bin/sh" or "python3",
+	// found by absolute path or in PATH on the node. The app is only placed
+	// on nodes advertising it with an interpreter.<name> label.
+	Interpreter string `yaml:"interpreter,omitempty" json:"interpreter,omitempty"`
+
+	// Platform is the GOOS/GOARCH the entrypoint is built for, e.g.
+	// "linux/arm64", or "any". Pack records it from the entrypoint when it
+	// is not set. The app is only placed and started on matching nodes.
+	Platform string `yaml:"platform,omitempty" json:"platform,omitempty"`
+
+	// Topics lists the pub/sub topics the application may use through pkg/appsdk
+	Topics *TopicACL `yaml:"topics,omitempty" json:"topics,omitempty"`
+
+	// OnFailure is what a node does when a newly deployed version fails to
+	// start or become healthy: OnFailureRollback (the default) or OnFailureLeave
+	OnFailure string `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+
+	// CoreDumps lets the application processes dump core into the working
+	// directory when they crash with a signal, for offline analysis
+	CoreDumps bool `yaml:"core_dumps,omitempty" json:"core_dumps,omitempty"`
+
+	// Volumes names the persistent directories of the application, kept by
+	// the node per app name across redeploys and upgrades. Each is passed as
+	// P2P_VOLUME_<NAME> and can be used in Args as {{ .Volume "name" }}.
+	// Snapshots of them are backed up with `controller backup`.
+	Volumes []string `yaml:"volumes,omitempty" json:"volumes,omitempty"`
 }
 
+const (
+	// OnFailureRollback restores the previous version of an app when the
+	// deployed one fails to start or become healthy
+	OnFailureRollback = "rollback"
+
+	// OnFailureLeave keeps a deployed version that failed to start or become
+	// healthy in place, for inspection
+	OnFailureLeave = "leave"
+)
+
 // ResourceLimits specifies resource constraints
 type ResourceLimits struct {
 	// CPUPercent is the CPU limit as a percentage (0-100 per core)
@@ -168,11 +432,66 @@ type LifecycleHooks struct {
 	PostStop string `yaml:"post_stop,omitempty" json:"post_stop,omitempty"`
 }
 
+// AppSecurity specifies how an application process is confined.
+// Settings left empty fall back to the daemon's security defaults.
+type AppSecurity struct {
+	// AppArmorProfile is the AppArmor profile to run the process under
+	AppArmorProfile string `yaml:"apparmor_profile,omitempty" json:"apparmor_profile,omitempty"`
+
+	// SELinuxContext is the SELinux security context to run the process in
+	SELinuxContext string `yaml:"selinux_context,omitempty" json:"selinux_context,omitempty"`
+}
+
+// Egress policies
+const (
+	// EgressAllow leaves outbound traffic unrestricted (default)
+	EgressAllow = "allow"
+
+	// EgressDeny blocks outbound traffic except for the Allow rules
+	EgressDeny = "deny"
+)
+
+// NetworkPolicy restricts an application's network access.
+// Restricted applications run in their own network namespace, so they are
+// reachable only at the namespace address, not on the node's localhost.
+type NetworkPolicy struct {
+	// Egress is the outbound policy: "allow" (default) or "deny"
+	Egress string `yaml:"egress,omitempty" json:"egress,omitempty"`
+
+	// Allow lists the destinations reachable when Egress is "deny"
+	Allow []EgressRule `yaml:"allow,omitempty" json:"allow,omitempty"`
+}
+
+// EgressRule allows outbound traffic to a destination
+type EgressRule struct {
+	// CIDR is the destination network, e.g. 10.0.0.0/8 or 192.0.2.1/32
+	CIDR string `yaml:"cidr" json:"cidr"`
+
+	// Ports limits the rule to destination ports (all ports if empty)
+	Ports []int `yaml:"ports,omitempty" json:"ports,omitempty"`
+
+	// Protocol is "tcp", "udp" or empty for both
+	Protocol string `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+}
+
+// TopicACL lists the pub/sub topics an application may use as glob patterns,
+// e.g. "sensors/*". Topics not matched by a pattern are denied.
+type TopicACL struct {
+	// Publish are the topics the application may publish to
+	Publish []string `yaml:"publish,omitempty" json:"publish,omitempty"`
+
+	// Subscribe are the topics the application may subscribe to
+	Subscribe []string `yaml:"subscribe,omitempty" json:"subscribe,omitempty"`
+}
+
 // NodeInfo represents information about a node
 type NodeInfo struct {
 	// ID is the node's unique identifier
 	ID string `json:"id"`
 
+	// Name is the configured node name
+	Name string `json:"name,omitempty"`
+
 	// Addrs are the node's listening addresses
 	Addrs []string `json:"addrs"`
 
@@ -187,6 +506,187 @@ type NodeInfo struct {
 
 	// Version is the daemon version
 	Version string `json:"version"`
+
+	// Commit is the VCS revision the daemon was built from
+	Commit string `json:"commit,omitempty"`
+
+	// StartedAt is when the daemon started
+	StartedAt time.Time `json:"started_at"`
+
+	// UptimeSeconds is how long the daemon has been running
+	UptimeSeconds int64 `json:"uptime_seconds"`
+
+	// Quotas is the quota usage of each operator that deployed to the node
+	Quotas []*QuotaUsage `json:"quotas,omitempty"`
+
+	// Capacity is what the node offers to applications
+	Capacity *NodeCapacity `json:"capacity,omitempty"`
+
+	// Alerts summarizes the alerts firing on the node
+	Alerts []string `json:"alerts,omitempty"`
+
+	// Protocols counts the streams the node handled for each protocol since it started
+	Protocols []*ProtocolStats `json:"protocols,omitempty"`
+
+	// Crashes counts the panics the daemon recovered from since it started, by
+	// goroutine, e.g. "runtime.monitor" or "handler <protocol>"
+	Crashes map[string]uint64 `json:"crashes,omitempty"`
+
+	// Advertisements counts the DHT advertisements of the node since it
+	// started; nil if it does not advertise in the DHT
+	Advertisements *AdvertisementStats `json:"advertisements,omitempty"`
+
+	// Components is the state of each subsystem of the daemon, in the order
+	// they start
+	Components []*ComponentStatus `json:"components,omitempty"`
+}
+
+// ComponentState is the state of a subsystem of a daemon
+type ComponentState string
+
+const (
+	// ComponentPending indicates the subsystem is not started yet
+	ComponentPending ComponentState = "pending"
+
+	// ComponentStarting indicates the subsystem is being started
+	ComponentStarting ComponentState = "starting"
+
+	// ComponentRunning indicates the subsystem is started and not stopped
+	ComponentRunning ComponentState = "running"
+
+	// ComponentUnhealthy indicates the subsystem runs but its health check fails
+	ComponentUnhealthy ComponentState = "unhealthy"
+
+	// ComponentFailed indicates the subsystem failed to start
+	ComponentFailed ComponentState = "failed"
+
+	// ComponentStopped indicates the subsystem is stopped, or was never
+	// started because one before it failed
+	ComponentStopped ComponentState = "stopped"
+)
+
+// ComponentStatus is the state of a subsystem of a daemon
+type ComponentStatus struct {
+	// Name names the subsystem, e.g. "host" or "discovery"
+	Name string `json:"name"`
+
+	// State is the state of the subsystem
+	State ComponentState `json:"state"`
+
+	// Detail describes what the running subsystem does, e.g. the addresses
+	// the host listens on
+	Detail string `json:"detail,omitempty"`
+
+	// Error is why the subsystem failed to start, or is unhealthy
+	Error string `json:"error,omitempty"`
+}
+
+// AdvertisementStats counts the provider records a node published in the DHT
+type AdvertisementStats struct {
+	// Active are the namespaces the node advertises now
+	Active []string `json:"active,omitempty"`
+
+	// Advertised is the number of records published, renewals included
+	Advertised uint64 `json:"advertised"`
+
+	// Failed is the number of records that failed to publish
+	Failed uint64 `json:"failed,omitempty"`
+
+	// Withdrawn is the number of namespaces no longer advertised, whose
+	// records are left to expire
+	Withdrawn uint64 `json:"withdrawn,omitempty"`
+
+	// StaleProviders is the number of provider records found for peers that
+	// have no addresses or could not be reached
+	StaleProviders uint64 `json:"stale_providers,omitempty"`
+}
+
+// ProtocolStats counts the streams a node handled for one protocol
+type ProtocolStats struct {
+	// Protocol is the protocol ID
+	Protocol string `json:"protocol"`
+
+	// Streams is the number of streams opened, rejected ones included
+	Streams uint64 `json:"streams"`
+
+	// Rejected is the number of streams refused before reaching the handler,
+	// e.g. by rate limiting
+	Rejected uint64 `json:"rejected,omitempty"`
+
+	// Panics is the number of streams whose handler panicked
+	Panics uint64 `json:"panics,omitempty"`
+
+	// AvgMillis is the average time spent handling a stream
+	AvgMillis float64 `json:"avg_ms"`
+}
+
+// NodeResources is an amount of the resources of a node. Zero is unknown
+// for totals.
+type NodeResources struct {
+	// CPUPercent is CPU time, 100 per core
+	CPUPercent float64 `json:"cpu_percent"`
+
+	// MemoryMB is memory in megabytes
+	MemoryMB int64 `json:"memory_mb"`
+
+	// DiskMB is disk space in megabytes on the filesystem apps are deployed to
+	DiskMB int64 `json:"disk_mb"`
+}
+
+// NodeCapacity is what a node offers to applications
+type NodeCapacity struct {
+	// Total is what the node has
+	Total NodeResources `json:"total"`
+
+	// Reserved is kept for the daemon and the operating system
+	Reserved NodeResources `json:"reserved"`
+
+	// Allocatable is Total less Reserved: what apps may use
+	Allocatable NodeResources `json:"allocatable"`
+
+	// Allocated is the CPU and memory the running apps declare in their
+	// resource limits, and the disk space in use
+	Allocated NodeResources `json:"allocated"`
+
+	// Enforced reports whether the node refuses deployments that do not fit
+	Enforced bool `json:"enforced,omitempty"`
+}
+
+// Free returns what is left of Allocatable after Allocated, never below zero
+func (c *NodeCapacity) Free() NodeResources {
+	return NodeResources{
+		CPUPercent: max(c.Allocatable.CPUPercent-c.Allocated.CPUPercent, 0),
+		MemoryMB:   max(c.Allocatable.MemoryMB-c.Allocated.MemoryMB, 0),
+		DiskMB:     max(c.Allocatable.DiskMB-c.Allocated.DiskMB, 0),
+	}
+}
+
+// QuotaUsage is what an operator, identified by the key signing its
+// packages, uses of its quota on a node. Zero limits are unlimited.
+type QuotaUsage struct {
+	// Operator is the hex public key of the signing key, or "unsigned"
+	Operator string `json:"operator"`
+
+	// Name is the name of the signing key, if known
+	Name string `json:"name,omitempty"`
+
+	// Apps is how many apps the operator has deployed
+	Apps int `json:"apps"`
+
+	// PackageBytes is the total package size of those apps
+	PackageBytes int64 `json:"package_bytes"`
+
+	// DeploysLastHour is how many deployments the operator made in the last hour
+	DeploysLastHour int `json:"deploys_last_hour"`
+
+	// MaxApps limits Apps
+	MaxApps int `json:"max_apps,omitempty"`
+
+	// MaxPackageBytes limits PackageBytes
+	MaxPackageBytes int64 `json:"max_package_bytes,omitempty"`
+
+	// MaxDeploysPerHour limits DeploysLastHour
+	MaxDeploysPerHour int `json:"max_deploys_per_hour,omitempty"`
 }
 
 // DeploymentConfig specifies how to deploy an application
@@ -304,3 +804,70 @@ type PackageInfo struct {
 	// Signed indicates if the package is signed
 	Signed bool `json:"signed"`
 }
+
+// DeployStage is a step of a deployment reported to the controller while it is in progress
+type DeployStage string
+
+const (
+	// DeployStageReceived indicates the node has received the whole package
+	DeployStageReceived DeployStage = "received"
+
+	// DeployStageVerified indicates the package passed signature, policy and admission checks
+	DeployStageVerified DeployStage = "verified"
+
+	// DeployStageUnpacking indicates the package is being unpacked
+	DeployStageUnpacking DeployStage = "unpacking"
+
+	// DeployStageStarting indicates the application is being started
+	DeployStageStarting DeployStage = "starting"
+
+	// DeployStageHealthy indicates the started application reports healthy
+	DeployStageHealthy DeployStage = "healthy"
+
+	// DeployStageHeartbeat carries no progress; it shows the node is still working
+	DeployStageHeartbeat DeployStage = "heartbeat"
+)
+
+// AppEvent is a lifecycle event of an application
+type AppEvent string
+
+const (
+	// AppEventDeployed indicates a new version of the application was deployed
+	AppEventDeployed AppEvent = "deployed"
+
+	// AppEventStarted indicates the application process was started
+	AppEventStarted AppEvent = "started"
+
+	// AppEventExited indicates the application process exited on its own or was killed
+	AppEventExited AppEvent = "exited"
+
+	// AppEventStopped indicates the application was stopped on request
+	AppEventStopped AppEvent = "stopped"
+
+	// AppEventUnhealthy indicates the application failed its health check
+	AppEventUnhealthy AppEvent = "unhealthy"
+
+	// AppEventAlert indicates an alert about the application fired or resolved
+	AppEventAlert AppEvent = "alert"
+
+	// AppEventRolledBack indicates a deployed version failed to start or
+	// become healthy and the previous version was restored
+	AppEventRolledBack AppEvent = "rolled_back"
+
+	// AppEventPaused indicates the application process was suspended on request
+	AppEventPaused AppEvent = "paused"
+
+	// AppEventResumed indicates a paused application process was resumed
+	AppEventResumed AppEvent = "resumed"
+)
+
+// AppAction is an operation on the process of a deployed application
+type AppAction string
+
+const (
+	// AppActionPause suspends the process, keeping its state
+	AppActionPause AppAction = "pause"
+
+	// AppActionResume continues a paused process
+	AppActionResume AppAction = "resume"
+)
@@ -1,7 +1,12 @@
 package types_test
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/asjdf/p2p-playground-lite/pkg/types"
 )
@@ -17,6 +22,7 @@ func TestAppStatusTypes(t *testing.T) {
 		{"running", types.AppStatusRunning, "running"},
 		{"failed", types.AppStatusFailed, "failed"},
 		{"restarting", types.AppStatusRestarting, "restarting"},
+		{"paused", types.AppStatusPaused, "paused"},
 	}
 
 	for _, tt := range tests {
@@ -92,13 +98,17 @@ func TestManifestDefaults(t *testing.T) {
 
 func TestApplication(t *testing.T) {
 	app := &types.Application{
-		ID:      "app-123",
-		Name:    "test-app",
-		Version: "1.0.0",
-		Status:  types.AppStatusRunning,
-		PID:     12345,
-		Labels: map[string]string{
-			"env": "test",
+		AppSpec: types.AppSpec{
+			ID:      "app-123",
+			Name:    "test-app",
+			Version: "1.0.0",
+			Labels: map[string]string{
+				"env": "test",
+			},
+		},
+		AppRuntimeStatus: types.AppRuntimeStatus{
+			Status: types.AppStatusRunning,
+			PID:    12345,
 		},
 	}
 
@@ -114,3 +124,127 @@ func TestApplication(t *testing.T) {
 		t.Errorf("label env = %v, want 'test'", app.Labels["env"])
 	}
 }
+
+// The JSON of an Application stays the flat object of version 1 of the protocols
+func TestApplicationJSON(t *testing.T) {
+	app := types.NewApplication(types.AppSpec{ID: "web-1.0.0", Name: "web", Version: "1.0.0", WorkDir: "/apps/web-1.0.0"})
+	app.PID = 42
+
+	data, err := json.Marshal(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"id", "name", "version", "package_path", "manifest", "work_dir", "status", "pid", "started_at"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("JSON %s has no %q", data, key)
+		}
+	}
+	if fields["status"] != string(types.AppStatusStopped) {
+		t.Errorf("status = %v, want %s", fields["status"], types.AppStatusStopped)
+	}
+}
+
+func TestAppRecordConversion(t *testing.T) {
+	status := &types.AppStatus{
+		App: &types.Application{
+			AppSpec: types.AppSpec{ID: "web-1.0.0", Name: "web", Labels: map[string]string{"env": "lab"}},
+			AppRuntimeStatus: types.AppRuntimeStatus{
+				Status:    types.AppStatusRunning,
+				PID:       42,
+				StartedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		AppHealth: types.AppHealth{Healthy: true, Message: "ok", Metrics: map[string]float64{"queue": 3}},
+	}
+
+	// Through the wire, as a node speaking version 2 sends it
+	data, err := json.Marshal(status.Record())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record types.AppRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Spec == nil || record.Spec.ID != "web-1.0.0" || record.Runtime == nil || record.Runtime.PID != 42 {
+		t.Fatalf("record = %s, want the spec and runtime status apart", data)
+	}
+	if got := record.Status(); !reflect.DeepEqual(got, status) {
+		t.Errorf("Status() = %+v, want %+v", got, status)
+	}
+
+	if got := (&types.AppRecord{}).Status(); got.App == nil {
+		t.Errorf("Status() of an empty record has no app")
+	}
+}
+
+func TestAppRecordSelect(t *testing.T) {
+	record := &types.AppRecord{
+		Spec:    &types.AppSpec{ID: "web-1.0.0", Name: "web", Manifest: &types.Manifest{Name: "web"}},
+		Runtime: &types.AppRuntimeStatus{Status: types.AppStatusRunning, PID: 42},
+		Health:  &types.AppHealth{Healthy: true, Message: "ok", Metrics: map[string]float64{"queue": 3}},
+	}
+
+	if got := record.Select(nil); got != record {
+		t.Errorf("Select() of no fields = %+v, want the whole record", got)
+	}
+
+	got := record.Select([]string{types.RecordFieldRuntime, types.RecordFieldMetrics, "future"})
+	if got.Spec == nil || got.Spec.ID != "web-1.0.0" || got.Spec.Manifest != nil {
+		t.Errorf("selected spec = %+v, want it without the manifest", got.Spec)
+	}
+	if got.Runtime == nil || got.Runtime.PID != 42 {
+		t.Errorf("selected runtime = %+v, want it kept", got.Runtime)
+	}
+	if got.Health == nil || got.Health.Healthy || got.Health.Metrics["queue"] != 3 {
+		t.Errorf("selected health = %+v, want only the metrics", got.Health)
+	}
+	if record.Spec.Manifest == nil || record.Health.Message != "ok" {
+		t.Errorf("Select() changed the record")
+	}
+
+	got = record.Select([]string{types.RecordFieldHealth})
+	if got.Runtime != nil || got.Health == nil || !got.Health.Healthy || got.Health.Metrics != nil {
+		t.Errorf("Select(health) = %+v, want the health less the metrics", got)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"sentinel", types.ErrNotFound, types.CodeNotFound},
+		{"wrapped", fmt.Errorf("verify: %w", types.ErrInvalidSignature), types.CodeInvalidSignature},
+		{"app error", types.NewAppError(types.CodeUnauthorized, "denied", nil), types.CodeUnauthorized},
+		{"unknown", errors.New("boom"), types.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := types.ErrorCode(tt.err); got != tt.want {
+				t.Errorf("ErrorCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorFromCode(t *testing.T) {
+	err := types.ErrorFromCode(types.CodePackageNotSigned, "unsigned packages are not allowed")
+
+	if !errors.Is(err, types.ErrPackageNotSigned) {
+		t.Error("expected reconstructed error to match ErrPackageNotSigned")
+	}
+	if errors.Is(err, types.ErrInvalidSignature) {
+		t.Error("reconstructed error must not match unrelated sentinels")
+	}
+	if types.ErrorCode(err) != types.CodePackageNotSigned {
+		t.Errorf("ErrorCode() = %q, want %q", types.ErrorCode(err), types.CodePackageNotSigned)
+	}
+}
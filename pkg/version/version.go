@@ -0,0 +1,80 @@
+// Package version reports the build version of the binaries.
+//
+// Release builds set the variables with the linker, e.g.
+//
+//	go build -ldflags "-X github.com/asjdf/p2p-playground-lite/pkg/version.Version=v1.2.0 \
+//	  -X github.com/asjdf/p2p-playground-lite/pkg/version.Commit=$(git rev-parse HEAD)"
+//
+// Otherwise the module version and VCS revision recorded by the Go toolchain are used.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags "-X ..."
+var (
+	// Version is the release version
+	Version = "dev"
+
+	// Commit is the VCS revision the binary was built from
+	Commit = ""
+
+	// Date is when the binary was built (RFC 3339)
+	Date = ""
+)
+
+// Info describes a build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the build info of the running binary
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// String returns the version followed by the short commit, e.g. "v1.2.0 (3f2a1bc)"
+func (i Info) String() string {
+	if i.Commit == "" {
+		return i.Version
+	}
+	commit := i.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return fmt.Sprintf("%s (%s)", i.Version, commit)
+}
@@ -0,0 +1,41 @@
+package version_test
+
+import (
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/version"
+)
+
+func TestInfoString(t *testing.T) {
+	tests := []struct {
+		name string
+		info version.Info
+		want string
+	}{
+		{"version only", version.Info{Version: "dev"}, "dev"},
+		{"short commit", version.Info{Version: "v1.2.0", Commit: "abc"}, "v1.2.0 (abc)"},
+		{"long commit", version.Info{Version: "v1.2.0", Commit: "3f2a1bc9d8e7"}, "v1.2.0 (3f2a1bc)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	version.Version = "v9.9.9"
+	version.Commit = "0123456789"
+	defer func() { version.Version, version.Commit = "dev", "" }()
+
+	info := version.Get()
+	if info.Version != "v9.9.9" || info.Commit != "0123456789" {
+		t.Errorf("Get() = %+v, want the linker-set version and commit", info)
+	}
+	if info.GoVersion == "" || info.Platform == "" {
+		t.Errorf("Get() = %+v, want Go version and platform", info)
+	}
+}
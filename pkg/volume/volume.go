@@ -0,0 +1,533 @@
+// Package volume keeps the persistent volumes of applications, directories
+// named in the manifest that outlive app instances, so that an upgrade finds
+// the data of the version before it. Volumes are kept per app name. Snapshots
+// archive them, to be backed up to other nodes or object storage and
+// restored, e.g. when an app moves to another node.
+package volume
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/storage"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// DirName is the directory of the data directory holding the volumes of apps
+const DirName = "volumes"
+
+// schema versions the stored snapshot metadata
+var schema = storage.Schema{
+	Kind: "snapshot metadata",
+	Migrations: []storage.Migration{
+		nil, // 1: the schema version was added
+	},
+}
+
+// SnapshotsDirName is the directory of the data directory holding snapshots
+const SnapshotsDirName = "snapshots"
+
+// DefaultKeep is how many snapshots of each app are kept
+const DefaultKeep = 5
+
+// Dir returns the directory of volume name of app below root
+func Dir(root, app, name string) string {
+	return filepath.Join(root, app, name)
+}
+
+// Env returns the environment variable holding the directory of volume
+// name, e.g. P2P_VOLUME_DATA for "data"
+func Env(name string) string {
+	return "P2P_VOLUME_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Snapshot is an archive of the volumes of an app
+type Snapshot struct {
+	// ID names the snapshot, <app>-<time taken>
+	ID string `json:"id"`
+
+	// App is the app name
+	App string `json:"app"`
+
+	// Node is the peer ID of the node the volumes were on
+	Node string `json:"node,omitempty"`
+
+	// Volumes are the volumes archived
+	Volumes []string `json:"volumes"`
+
+	// Size is the size of the archive
+	Size int64 `json:"size"`
+
+	// SHA256 is the checksum of the archive
+	SHA256 string `json:"sha256"`
+
+	// CreatedAt is when the snapshot was taken
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FileName returns the file name of the archive of the snapshot
+func (s *Snapshot) FileName() string {
+	return s.ID + ".tar.gz"
+}
+
+// Validate checks that the names of the snapshot can be used in paths
+func (s *Snapshot) Validate() error {
+	if err := checkName(s.App); err != nil {
+		return fmt.Errorf("%w: app %q", err, s.App)
+	}
+	if err := checkName(s.ID); err != nil || !strings.HasPrefix(s.ID, s.App+"-") {
+		return fmt.Errorf("%w: snapshot ID %q", types.ErrInvalidInput, s.ID)
+	}
+	for _, name := range s.Volumes {
+		if err := checkName(name); err != nil {
+			return fmt.Errorf("%w: volume %q", err, name)
+		}
+	}
+	return nil
+}
+
+// checkName checks that name is a single path element
+func checkName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return types.ErrInvalidInput
+	}
+	return nil
+}
+
+// Store keeps snapshots in a directory, in a directory per app holding the
+// archive of each snapshot next to its metadata
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewStore creates a snapshot store in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// archivePath returns the path of the archive of snap
+func (s *Store) archivePath(snap *Snapshot) string {
+	return filepath.Join(s.dir, snap.App, snap.FileName())
+}
+
+// metaPath returns the path of the metadata of snap
+func (s *Store) metaPath(snap *Snapshot) string {
+	return filepath.Join(s.dir, snap.App, snap.ID+".json")
+}
+
+// Create takes a snapshot of the named volumes of app, kept below root,
+// taken on the node with peer ID node. The volumes are archived as they
+// are, so an app writing to them meanwhile may leave them inconsistent.
+func (s *Store) Create(root, app string, names []string, node string) (*Snapshot, error) {
+	now := time.Now().UTC()
+	snap := &Snapshot{
+		ID:        app + "-" + now.Format("20060102T150405.000Z"),
+		App:       app,
+		Node:      node,
+		Volumes:   names,
+		CreatedAt: now,
+	}
+	if err := snap.Validate(); err != nil {
+		return nil, err
+	}
+
+	return snap, s.write(snap, "", func(w io.Writer) error {
+		return archive(w, filepath.Join(root, app), names)
+	})
+}
+
+// Add keeps snap, whose archive is read from r, e.g. a snapshot backed up
+// from another node. The archive must match the size and checksum of snap.
+func (s *Store) Add(snap *Snapshot, r io.Reader) error {
+	if err := snap.Validate(); err != nil {
+		return err
+	}
+	want := *snap
+	return s.write(snap, want.SHA256, func(w io.Writer) error {
+		n, err := io.Copy(w, io.LimitReader(r, want.Size))
+		if err != nil {
+			return types.WrapError(err, "failed to receive snapshot")
+		}
+		if n != want.Size {
+			return fmt.Errorf("%w: snapshot %s truncated at %d of %d bytes", types.ErrInvalidChecksum, want.ID, n, want.Size)
+		}
+		return nil
+	})
+}
+
+// write writes the archive of snap with fill, then its metadata, setting
+// its size and checksum. Unless checksum is empty, the archive must match it.
+func (s *Store) write(snap *Snapshot, checksum string, fill func(w io.Writer) error) error {
+	dir := filepath.Join(s.dir, snap.App)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return types.WrapError(err, "failed to create snapshot directory")
+	}
+	f, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return types.WrapError(err, "failed to create snapshot")
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	hash := sha256.New()
+	counter := &countWriter{w: io.MultiWriter(f, hash)}
+	err = fill(counter)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = types.WrapError(closeErr, "failed to write snapshot")
+	}
+	if err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if checksum != "" && sum != checksum {
+		return fmt.Errorf("%w: snapshot %s does not match its checksum", types.ErrInvalidChecksum, snap.ID)
+	}
+	snap.Size, snap.SHA256 = counter.n, sum
+
+	meta, err := schema.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return types.WrapError(err, "failed to marshal snapshot")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Rename(f.Name(), s.archivePath(snap)); err != nil {
+		return types.WrapError(err, "failed to save snapshot")
+	}
+	// The metadata is written last, so only complete snapshots are listed
+	if err := os.WriteFile(s.metaPath(snap), meta, 0600); err != nil {
+		_ = os.Remove(s.archivePath(snap))
+		return types.WrapError(err, "failed to save snapshot")
+	}
+	return nil
+}
+
+// List returns the snapshots of app, or of all apps if app is empty,
+// newest first
+func (s *Store) List(app string) ([]*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pattern := filepath.Join(s.dir, "*", "*.json")
+	if app != "" {
+		if err := checkName(app); err != nil {
+			return nil, fmt.Errorf("%w: app %q", err, app)
+		}
+		pattern = filepath.Join(s.dir, app, "*.json")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, types.WrapError(err, "failed to list snapshots")
+	}
+
+	snapshots := make([]*Snapshot, 0, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, types.WrapError(err, "failed to read snapshot")
+		}
+		var snap Snapshot
+		if err := schema.Unmarshal(data, &snap); err != nil {
+			return nil, types.WrapError(err, "failed to parse snapshot "+filepath.Base(p))
+		}
+		snapshots = append(snapshots, &snap)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Get returns the snapshot id of app, or its latest snapshot if id is
+// empty. The error wraps types.ErrNotFound if there is none.
+func (s *Store) Get(app, id string) (*Snapshot, error) {
+	snapshots, err := s.List(app)
+	if err != nil {
+		return nil, err
+	}
+	for _, snap := range snapshots {
+		if id == "" || snap.ID == id {
+			return snap, nil
+		}
+	}
+	if id == "" {
+		return nil, fmt.Errorf("%w: no snapshot of %s", types.ErrNotFound, app)
+	}
+	return nil, fmt.Errorf("%w: no snapshot %s of %s", types.ErrNotFound, id, app)
+}
+
+// Open opens the archive of snap
+func (s *Store) Open(snap *Snapshot) (*os.File, error) {
+	f, err := os.Open(s.archivePath(snap))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: archive of snapshot %s", types.ErrNotFound, snap.ID)
+	}
+	if err != nil {
+		return nil, types.WrapError(err, "failed to open snapshot")
+	}
+	return f, nil
+}
+
+// Prune deletes the snapshots of app beyond the keep newest, and returns them
+func (s *Store) Prune(app string, keep int) ([]*Snapshot, error) {
+	snapshots, err := s.List(app)
+	if err != nil || len(snapshots) <= keep {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pruned := snapshots[keep:]
+	for _, snap := range pruned {
+		if err := os.Remove(s.metaPath(snap)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, types.WrapError(err, "failed to delete snapshot")
+		}
+		if err := os.Remove(s.archivePath(snap)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, types.WrapError(err, "failed to delete snapshot")
+		}
+	}
+	return pruned, nil
+}
+
+// countWriter counts the bytes written through it
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// archive writes the named volumes below dir as a tar.gz to w. Volumes not
+// created yet are archived empty.
+func archive(w io.Writer, dir string, names []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, name := range names {
+		volumeDir := filepath.Join(dir, name)
+		if _, err := os.Stat(volumeDir); errors.Is(err, fs.ErrNotExist) {
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0755, ModTime: time.Now()}); err != nil {
+				return types.WrapError(err, "failed to archive volume "+name)
+			}
+			continue
+		}
+		err := filepath.WalkDir(volumeDir, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			link := ""
+			if info.Mode()&fs.ModeSymlink != 0 {
+				if link, err = os.Readlink(p); err != nil {
+					return err
+				}
+			} else if !info.Mode().IsRegular() && !info.IsDir() {
+				// Sockets, pipes and devices are not data
+				return nil
+			}
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(rel)
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = f.Close() }()
+			_, err = io.CopyN(tw, f, header.Size)
+			return err
+		})
+		if err != nil {
+			return types.WrapError(err, "failed to archive volume "+name)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return types.WrapError(err, "failed to archive volumes")
+	}
+	if err := gz.Close(); err != nil {
+		return types.WrapError(err, "failed to archive volumes")
+	}
+	return nil
+}
+
+// Restore replaces the volumes of the app of snap below root with those of
+// its archive, read from r. The archive is checked against the checksum of
+// snap before anything is replaced. The app must not be running.
+func Restore(root string, snap *Snapshot, r io.Reader) error {
+	if err := snap.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return types.WrapError(err, "failed to create volumes directory")
+	}
+	tmp, err := os.MkdirTemp(root, "."+snap.App+".restore-*")
+	if err != nil {
+		return types.WrapError(err, "failed to create restore directory")
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	hash := sha256.New()
+	tee := io.TeeReader(r, hash)
+	if err := extract(tee, tmp); err != nil {
+		return err
+	}
+	// Hash what gzip left unread, e.g. its trailer padding
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return types.WrapError(err, "failed to read snapshot")
+	}
+	if snap.SHA256 != "" && hex.EncodeToString(hash.Sum(nil)) != snap.SHA256 {
+		return fmt.Errorf("%w: snapshot %s does not match its checksum", types.ErrInvalidChecksum, snap.ID)
+	}
+
+	dir := filepath.Join(root, snap.App)
+	old := filepath.Join(root, "."+snap.App+".old")
+	if err := os.RemoveAll(old); err != nil {
+		return types.WrapError(err, "failed to remove old volumes")
+	}
+	if err := os.Rename(dir, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return types.WrapError(err, "failed to replace volumes")
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		_ = os.Rename(old, dir)
+		return types.WrapError(err, "failed to replace volumes")
+	}
+	if err := os.RemoveAll(old); err != nil {
+		return types.WrapError(err, "failed to remove old volumes")
+	}
+	return nil
+}
+
+// extract extracts the tar.gz read from r into dir, which is empty. Entries
+// cannot reach outside dir: names are resolved within it, and symbolic
+// links are created after everything else, so nothing is written through them.
+func extract(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: snapshot is not gzipped: %v", types.ErrInvalidInput, err)
+	}
+	defer func() { _ = gz.Close() }()
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return types.WrapError(err, "failed to open restore directory")
+	}
+	defer func() { _ = root.Close() }()
+
+	var links []*tar.Header
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return types.WrapError(err, "failed to read snapshot")
+		}
+		name := path.Clean(header.Name)
+		if !fs.ValidPath(name) || name == "." {
+			return fmt.Errorf("%w: snapshot entry %q is outside the volumes", types.ErrInvalidInput, header.Name)
+		}
+		name = filepath.FromSlash(name)
+		mode := fs.FileMode(header.Mode) & fs.ModePerm
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := mkdirAll(root, name); err != nil {
+				return err
+			}
+			// No symbolic link exists yet to be followed
+			if err := os.Chmod(filepath.Join(dir, name), mode|0700); err != nil {
+				return types.WrapError(err, "failed to restore "+header.Name)
+			}
+		case tar.TypeReg:
+			if err := mkdirAll(root, filepath.Dir(name)); err != nil {
+				return err
+			}
+			f, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return types.WrapError(err, "failed to restore "+header.Name)
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return types.WrapError(err, "failed to restore "+header.Name)
+			}
+		case tar.TypeSymlink:
+			h := *header
+			h.Name = name
+			links = append(links, &h)
+		}
+	}
+
+	for _, link := range links {
+		if err := mkdirAll(root, filepath.Dir(link.Name)); err != nil {
+			return err
+		}
+		if err := os.Symlink(link.Linkname, filepath.Join(dir, link.Name)); err != nil {
+			return types.WrapError(err, "failed to restore "+link.Name)
+		}
+	}
+	return nil
+}
+
+// mkdirAll creates directory name and its parents within root. Each must
+// be a directory, not a symbolic link.
+func mkdirAll(root *os.Root, name string) error {
+	if name == "." {
+		return nil
+	}
+	if err := mkdirAll(root, filepath.Dir(name)); err != nil {
+		return err
+	}
+	info, err := root.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := root.Mkdir(name, 0755); err != nil {
+			return types.WrapError(err, "failed to restore "+name)
+		}
+		return nil
+	}
+	if err != nil {
+		return types.WrapError(err, "failed to restore "+name)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: snapshot entry %s is not a directory", types.ErrInvalidInput, name)
+	}
+	return nil
+}
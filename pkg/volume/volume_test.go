@@ -0,0 +1,160 @@
+package volume_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/volume"
+)
+
+// writeFile writes content to path, creating its directory
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "volumes")
+	writeFile(t, filepath.Join(volume.Dir(root, "web", "data"), "db", "records"), "v1")
+	if err := os.Symlink("db/records", filepath.Join(volume.Dir(root, "web", "data"), "current")); err != nil {
+		t.Fatal(err)
+	}
+	store := volume.NewStore(filepath.Join(t.TempDir(), "snapshots"))
+
+	snap, err := store.Create(root, "web", []string{"data", "cache"}, "peer-1")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if snap.Size == 0 || len(snap.SHA256) != 64 || snap.Node != "peer-1" {
+		t.Errorf("Create() = %+v", snap)
+	}
+
+	// Change the volumes, then restore them
+	writeFile(t, filepath.Join(volume.Dir(root, "web", "data"), "db", "records"), "v2")
+	writeFile(t, filepath.Join(volume.Dir(root, "web", "data"), "new"), "added later")
+
+	got, err := store.Get("web", "")
+	if err != nil || got.ID != snap.ID {
+		t.Fatalf("Get() = %+v, %v, want %s", got, err, snap.ID)
+	}
+	f, err := store.Open(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := volume.Restore(root, got, f); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(volume.Dir(root, "web", "data"), "current"))
+	if err != nil || string(data) != "v1" {
+		t.Errorf("restored records = %q, %v, want v1 through the link", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(volume.Dir(root, "web", "data"), "new")); !os.IsNotExist(err) {
+		t.Errorf("file added after the snapshot is still there: %v", err)
+	}
+	if info, err := os.Stat(volume.Dir(root, "web", "cache")); err != nil || !info.IsDir() {
+		t.Errorf("volume missing when the snapshot was taken is not restored empty: %v", err)
+	}
+}
+
+// gzipTar returns a tar.gz of the entries written by fill
+func gzipTar(t *testing.T, fill func(tw *tar.Writer)) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	fill(tw)
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestoreRejects(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "outside")
+	snap := &volume.Snapshot{ID: "web-1", App: "web", Volumes: []string{"data"}}
+
+	tests := map[string]func(tw *tar.Writer){
+		"parent": func(tw *tar.Writer) {
+			_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "../escape", Mode: 0644})
+		},
+		"through link": func(tw *tar.Writer) {
+			_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "data/out", Linkname: filepath.Dir(outside)})
+			_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "data/out/outside", Linkname: "/etc/passwd"})
+		},
+	}
+	for name, fill := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := volume.Restore(root, snap, bytes.NewReader(gzipTar(t, fill))); !errors.Is(err, types.ErrInvalidInput) {
+				t.Errorf("Restore() error = %v, want ErrInvalidInput", err)
+			}
+			if _, err := os.Lstat(outside); !os.IsNotExist(err) {
+				t.Errorf("Restore() wrote outside the volumes: %v", err)
+			}
+		})
+	}
+
+	// A damaged archive leaves the volumes alone
+	writeFile(t, filepath.Join(volume.Dir(root, "web", "data"), "kept"), "kept")
+	archive := gzipTar(t, func(tw *tar.Writer) {
+		_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "data/", Mode: 0755})
+	})
+	snap.SHA256 = "0000"
+	if err := volume.Restore(root, snap, bytes.NewReader(archive)); !errors.Is(err, types.ErrInvalidChecksum) {
+		t.Errorf("Restore() of a damaged archive error = %v, want ErrInvalidChecksum", err)
+	}
+	if _, err := os.Stat(filepath.Join(volume.Dir(root, "web", "data"), "kept")); err != nil {
+		t.Errorf("volumes changed by a failed restore: %v", err)
+	}
+}
+
+func TestStoreAddPrune(t *testing.T) {
+	store := volume.NewStore(t.TempDir())
+	archive := []byte("archive of web")
+	sum := sha256.Sum256(archive)
+
+	for _, id := range []string{"web-1", "web-2", "web-3"} {
+		snap := &volume.Snapshot{ID: id, App: "web", Size: int64(len(archive)), SHA256: hex.EncodeToString(sum[:])}
+		snap.CreatedAt = snap.CreatedAt.AddDate(0, 0, int(id[4]-'0'))
+		if err := store.Add(snap, bytes.NewReader(archive)); err != nil {
+			t.Fatalf("Add(%s) error = %v", id, err)
+		}
+	}
+	bad := &volume.Snapshot{ID: "web-4", App: "web", Size: int64(len(archive)), SHA256: "0000"}
+	if err := store.Add(bad, bytes.NewReader(archive)); !errors.Is(err, types.ErrInvalidChecksum) {
+		t.Errorf("Add() with a wrong checksum error = %v, want ErrInvalidChecksum", err)
+	}
+	if err := store.Add(&volume.Snapshot{ID: "../web-5", App: "web"}, bytes.NewReader(nil)); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("Add() with a path as ID error = %v, want ErrInvalidInput", err)
+	}
+
+	pruned, err := store.Prune("web", 2)
+	if err != nil || len(pruned) != 1 || pruned[0].ID != "web-1" {
+		t.Errorf("Prune() = %v, %v, want web-1", pruned, err)
+	}
+	snapshots, err := store.List("")
+	if err != nil || len(snapshots) != 2 || snapshots[0].ID != "web-3" {
+		t.Errorf("List() = %v, %v, want web-3 and web-2", snapshots, err)
+	}
+	if _, err := store.Get("web", "web-1"); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Get() of a pruned snapshot error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Package wire frames the JSON messages of the daemon protocols and of the
+// app API: each message is JSON prefixed with its 4-byte big-endian length.
+package wire
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// MaxMessageSize bounds every message. It leaves room for the largest
+// responses, such as the deploy history or the snapshots of an app.
+const MaxMessageSize = 16 << 20
+
+// WriteMessage writes v as JSON prefixed with its 4-byte big-endian length.
+// A message longer than MaxMessageSize is refused with an error wrapping
+// types.ErrInvalidInput.
+func WriteMessage(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	if len(data) > MaxMessageSize {
+		return fmt.Errorf("%w: message of %d bytes exceeds %d", types.ErrInvalidInput, len(data), MaxMessageSize)
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(data))); err != nil {
+		return fmt.Errorf("failed to write message length: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// ReadMessage reads a message written by WriteMessage into v. A message
+// longer than MaxMessageSize is refused with an error wrapping
+// types.ErrInvalidInput before it is read.
+func ReadMessage(r io.Reader, v interface{}) error {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return fmt.Errorf("failed to read message length: %w", err)
+	}
+	if length > MaxMessageSize {
+		return fmt.Errorf("%w: message of %d bytes exceeds %d", types.ErrInvalidInput, length, MaxMessageSize)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return fmt.Errorf("failed to read message: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package wire_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+	"github.com/asjdf/p2p-playground-lite/pkg/wire"
+)
+
+func TestMessages(t *testing.T) {
+	type message struct {
+		Name string `json:"name"`
+	}
+
+	var buf bytes.Buffer
+	for _, name := range []string{"first", "second"} {
+		if err := wire.WriteMessage(&buf, &message{Name: name}); err != nil {
+			t.Fatalf("WriteMessage() error = %v", err)
+		}
+	}
+	for _, want := range []string{"first", "second"} {
+		var got message
+		if err := wire.ReadMessage(&buf, &got); err != nil || got.Name != want {
+			t.Errorf("ReadMessage() = %+v, %v; want %q", got, err, want)
+		}
+	}
+
+	var got message
+	if err := wire.ReadMessage(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}), &got); !errors.Is(err, types.ErrInvalidInput) {
+		t.Errorf("ReadMessage() of an oversized message error = %v, want ErrInvalidInput", err)
+	}
+	huge := &message{Name: strings.Repeat("x", wire.MaxMessageSize)}
+	if err := wire.WriteMessage(&buf, huge); !errors.Is(err, types.ErrInvalidInput) || buf.Len() != 0 {
+		t.Errorf("WriteMessage() of an oversized message error = %v, wrote %d bytes", err, buf.Len())
+	}
+	if err := wire.ReadMessage(bytes.NewReader([]byte{0, 0, 0, 4, '{'}), &got); err == nil {
+		t.Error("ReadMessage() of a truncated message succeeded")
+	}
+}
@@ -0,0 +1,319 @@
+//go:build e2e
+
+// Package e2e deploys the reference apps in examples/ to a local daemon and
+// checks that the daemon runs each of them as its manifest describes.
+//
+// Run with: make e2e
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	pkgmanager "github.com/asjdf/p2p-playground-lite/pkg/package"
+	"github.com/asjdf/p2p-playground-lite/pkg/types"
+)
+
+// repoRoot is the repository root relative to this package
+const repoRoot = "../.."
+
+// cluster is a local daemon and a controller configured to reach it
+type cluster struct {
+	dir        string
+	controller string
+	config     string
+	node       string
+}
+
+func TestExamples(t *testing.T) {
+	c := startCluster(t)
+
+	tests := []struct {
+		app    string
+		status types.AppStatusType
+		probe  func() error
+	}{
+		{"http-server", types.AppStatusRunning, probeHTTP("http://127.0.0.1:18080/")},
+		{"tcp-echo", types.AppStatusRunning, probeEcho("127.0.0.1:18081")},
+		{"resource-hog", types.AppStatusRunning, nil},
+		{"crasher", types.AppStatusFailed, nil},
+		{"batch-job", types.AppStatusStopped, nil},
+	}
+
+	for _, tt := range tests {
+		c.deploy(t, tt.app)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.app, func(t *testing.T) {
+			c.waitStatus(t, tt.app, tt.status, time.Minute)
+			if tt.probe == nil {
+				return
+			}
+			if err := tt.probe(); err != nil {
+				t.Errorf("probe failed: %v", err)
+			}
+		})
+	}
+}
+
+// startCluster builds the binaries and starts a daemon in a temporary directory
+func startCluster(t *testing.T) *cluster {
+	t.Helper()
+
+	dir := t.TempDir()
+	daemonBin := filepath.Join(dir, "p2p-daemon")
+	controllerBin := filepath.Join(dir, "controller")
+	goBuild(t, daemonBin, "./cmd/daemon")
+	goBuild(t, controllerBin, "./cmd/controller")
+
+	port := freePort(t)
+	daemonLog := filepath.Join(dir, "daemon.log")
+	daemonConfig := filepath.Join(dir, "daemon.yaml")
+	writeFile(t, daemonConfig, fmt.Sprintf(`node:
+  name: e2e
+  listen_addrs: [/ip4/127.0.0.1/tcp/%d]
+  enable_mdns: false
+  disable_dht: true
+storage:
+  data_dir: %[2]s/daemon
+  packages_dir: %[2]s/daemon/packages
+  apps_dir: %[2]s/daemon/apps
+  keys_dir: %[2]s/daemon/keys
+logging:
+  level: info
+  format: json
+  output_path: %[3]s
+  error_output_path: %[3]s
+security:
+  allow_unsigned_packages: true
+  public_keys_dir: %[2]s/daemon/keys/trusted
+`, port, dir, daemonLog))
+
+	daemonOutput, err := os.Create(filepath.Join(dir, "daemon.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = daemonOutput.Close() }()
+
+	daemon := exec.Command(daemonBin, "daemon", "run", "-c", daemonConfig)
+	daemon.Stdout = daemonOutput
+	daemon.Stderr = daemonOutput
+	if err := daemon.Start(); err != nil {
+		t.Fatalf("failed to start daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = daemon.Process.Signal(os.Interrupt)
+		done := make(chan struct{})
+		go func() { _ = daemon.Wait(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(15 * time.Second):
+			_ = daemon.Process.Kill()
+		}
+		if t.Failed() {
+			for _, name := range []string{"daemon.log", "daemon.out"} {
+				if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+					t.Logf("%s:\n%s", name, data)
+				}
+			}
+		}
+	})
+
+	node := waitPeerID(t, daemonLog)
+
+	controllerConfig := filepath.Join(dir, "controller.yaml")
+	writeFile(t, controllerConfig, fmt.Sprintf(`node:
+  listen_addrs: [/ip4/127.0.0.1/tcp/0]
+  enable_mdns: false
+  disable_dht: true
+  bootstrap_peers: [/ip4/127.0.0.1/tcp/%d/p2p/%s]
+storage:
+  data_dir: %[3]s/controller
+  packages_dir: %[3]s/controller/packages
+  keys_dir: %[3]s/controller/keys
+logging:
+  level: warn
+  format: console
+  output_path: stderr
+  error_output_path: stderr
+`, port, node, dir))
+
+	return &cluster{dir: dir, controller: controllerBin, config: controllerConfig, node: node}
+}
+
+// deploy builds and packages an example and deploys it to the daemon
+func (c *cluster) deploy(t *testing.T, app string) {
+	t.Helper()
+
+	appDir := filepath.Join(c.dir, "apps", app)
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := os.ReadFile(filepath.Join(repoRoot, "examples", app, "manifest.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(appDir, "manifest.yaml"), string(manifest))
+	goBuild(t, filepath.Join(appDir, "bin", app), "./examples/"+app)
+
+	pkg, err := pkgmanager.New().Pack(context.Background(), appDir)
+	if err != nil {
+		t.Fatalf("failed to package %s: %v", app, err)
+	}
+
+	if _, err := c.run("deploy", pkg, "--node", c.node); err != nil {
+		t.Fatalf("failed to deploy %s: %v", app, err)
+	}
+}
+
+// waitStatus polls the daemon until app reaches status
+func (c *cluster) waitStatus(t *testing.T, app string, status types.AppStatusType, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	var last types.AppStatusType
+	for time.Now().Before(deadline) {
+		out, err := c.run("list", "--node", c.node)
+		if err != nil {
+			t.Fatalf("failed to list apps: %v", err)
+		}
+
+		var apps []*types.Application
+		if err := json.Unmarshal(out, &apps); err != nil {
+			t.Fatalf("failed to parse app list: %v\n%s", err, out)
+		}
+		for _, a := range apps {
+			if a.Name == app {
+				last = a.Status
+			}
+		}
+		if last == status {
+			return
+		}
+		time.Sleep(2 * time.Second)
+	}
+	t.Fatalf("%s status = %q after %s, want %q", app, last, timeout, status)
+}
+
+// run runs a controller command with JSON output and returns its stdout
+func (c *cluster) run(args ...string) ([]byte, error) {
+	args = append([]string{"-c", c.config, "--output", "json"}, args...)
+	cmd := exec.Command(c.controller, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("controller %v: %w\n%s", args, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// peerIDPattern finds the daemon's peer ID in its JSON log
+var peerIDPattern = regexp.MustCompile(`"peer_id":"(\w+)"`)
+
+// waitPeerID waits for the daemon to log its peer ID
+func waitPeerID(t *testing.T, logPath string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		data, _ := os.ReadFile(logPath)
+		if m := peerIDPattern.FindSubmatch(data); m != nil {
+			return string(m[1])
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	t.Fatal("daemon did not log its peer ID")
+	return ""
+}
+
+// probeHTTP checks that url answers 200
+func probeHTTP(url string) func() error {
+	return func() error {
+		resp, err := http.Get(url)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		return nil
+	}
+}
+
+// probeEcho checks that addr echoes what it receives
+func probeEcho(addr string) func() error {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = conn.Close() }()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		want := []byte("ping\n")
+		if _, err := conn.Write(want); err != nil {
+			return err
+		}
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(conn, got); err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("echo = %q, want %q", got, want)
+		}
+		return nil
+	}
+}
+
+// goBuild builds pkg (relative to the repository root) to out
+func goBuild(t *testing.T, out, pkg string) {
+	t.Helper()
+
+	abs, err := filepath.Abs(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "build", "-o", abs, pkg)
+	cmd.Dir = repoRoot
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build %s: %v\n%s", pkg, err, output)
+	}
+}
+
+// freePort returns a TCP port that was free a moment ago
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// writeFile writes content to path, creating its directory
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}